@@ -0,0 +1,50 @@
+package cache
+
+import (
+	"sync"
+
+	"angrymiao-ai-server/src/configs"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	mu     sync.RWMutex
+	client *redis.Client
+)
+
+// InitRedis 根据 redis_cache 配置创建全局共享的Redis客户端，未配置地址时返回nil
+func InitRedis(cfg configs.RedisConfig) *redis.Client {
+	mu.Lock()
+	defer mu.Unlock()
+	if cfg.Addr == "" {
+		return nil
+	}
+	if client == nil {
+		client = redis.NewClient(&redis.Options{
+			Addr:     cfg.Addr,
+			Password: cfg.Password,
+			DB:       cfg.DB,
+		})
+	}
+	return client
+}
+
+// GetRedis 获取全局共享的Redis客户端，未初始化时返回nil
+func GetRedis() *redis.Client {
+	mu.RLock()
+	defer mu.RUnlock()
+	return client
+}
+
+// CloseRedis 关闭全局共享的Redis客户端
+func CloseRedis() error {
+	mu.Lock()
+	defer mu.Unlock()
+	if client == nil {
+		return nil
+	}
+	err := client.Close()
+	client = nil
+	return err
+}
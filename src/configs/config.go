@@ -1,7 +1,9 @@
 package configs
 
 import (
+	"fmt"
 	"os"
+	"sync/atomic"
 
 	"gopkg.in/yaml.v3"
 )
@@ -16,6 +18,8 @@ type VADConfig struct {
 	Type           string `yaml:"type"            json:"type"`
 	Aggressiveness int    `yaml:"aggressiveness"  json:"aggressiveness"` // 0-3，越高越敏感
 	FrameDuration  int    `yaml:"frame_duration"  json:"frame_duration"` // 帧持续时间(ms)，支持10/20/30
+	// WebRTC VAD 初始化失败时能量检测的RMS阈值(0~1)，默认0.02
+	EnergyThreshold float64 `yaml:"vad_energy_threshold" json:"vad_energy_threshold"`
 }
 
 // CasbinConfig Casbin权限控制配置
@@ -58,6 +62,18 @@ type Config struct {
 			AllowedDevices []string      `yaml:"allowed_devices" json:"allowed_devices"`
 			Tokens         []TokenConfig `yaml:"tokens" json:"tokens"`
 		} `yaml:"auth" json:"auth"`
+		// 管理接口令牌，为空时禁用管理接口
+		AdminToken string `yaml:"admin_token" json:"admin_token"`
+		// 所有传输层的最大连接总数，小于等于0时不限制
+		MaxConnections int `yaml:"max_connections" json:"max_connections"`
+		// 应用层心跳：每隔 HeartbeatIntervalSeconds 秒下发 {"type":"ping"}，HeartbeatTimeoutSeconds 秒内未收到 pong 时关闭会话，间隔为0时不发送
+		HeartbeatIntervalSeconds int `yaml:"heartbeat_interval_seconds" json:"heartbeat_interval_seconds"`
+		HeartbeatTimeoutSeconds  int `yaml:"heartbeat_timeout_seconds" json:"heartbeat_timeout_seconds"`
+		// 按设备ID限制上行消息频率，超出时丢弃消息并下发 rate_limit，小于等于0时不限制
+		RateLimit struct {
+			TextRPS  float64 `yaml:"text_rps" json:"text_rps"`   // 每秒允许的文本消息数，pong、mcp、abort 不计入
+			AudioFPS float64 `yaml:"audio_fps" json:"audio_fps"` // 每秒允许的音频帧数
+		} `yaml:"rate_limit" json:"rate_limit"`
 	} `yaml:"server" json:"server"`
 
 	// Casbin权限控制配置
@@ -86,6 +102,19 @@ type Config struct {
 			IP      string `yaml:"ip" json:"ip"`
 			Port    int    `yaml:"port" json:"port"`
 		} `yaml:"grpcgateway" json:"grpcgateway"`
+		// gRPC传输层，设备通过双向流 chat.ChatService/ChatStream 直接接入
+		Grpc struct {
+			Enabled bool   `yaml:"enabled" json:"enabled"`
+			IP      string `yaml:"ip" json:"ip"`
+			Port    int    `yaml:"port" json:"port"`
+			// 配置 ca_file 时要求客户端提供由该CA签发的证书
+			TLS struct {
+				Enabled  bool   `yaml:"enabled" json:"enabled"`
+				CertFile string `yaml:"cert_file" json:"cert_file"`
+				KeyFile  string `yaml:"key_file" json:"key_file"`
+				CAFile   string `yaml:"ca_file" json:"ca_file"`
+			} `yaml:"tls" json:"tls"`
+		} `yaml:"grpc" json:"grpc"`
 		// MQTT传输层
 		Mqtt struct {
 			Enabled        bool   `yaml:"enabled" json:"enabled"`
@@ -97,13 +126,19 @@ type Config struct {
 			ClientIDPrefix string `yaml:"client_id_prefix" json:"client_id_prefix"`
 			InSuffix       string `yaml:"in_suffix" json:"in_suffix"`
 			OutSuffix      string `yaml:"out_suffix" json:"out_suffix"`
-			TLS            struct {
+			// 多租户部署时允许接入的租户ID，topic_root 中的 {tenant} 占位符对应主题中的租户层级
+			TenantIDs []string `yaml:"tenant_ids" json:"tenant_ids"`
+			// 音频包携带4字节大端序号，检测到丢包时下发 audio_nack 请求客户端重传
+			AudioNackEnabled bool `yaml:"audio_nack_enabled" json:"audio_nack_enabled"`
+			TLS              struct {
 				Enabled    bool   `yaml:"enabled" json:"enabled"`
 				CAFile     string `yaml:"ca_file" json:"ca_file"`
 				CertFile   string `yaml:"cert_file" json:"cert_file"`
 				KeyFile    string `yaml:"key_file" json:"key_file"`
 				SkipVerify bool   `yaml:"skip_verify" json:"skip_verify"`
 			} `yaml:"tls" json:"tls"`
+			// 客户端hello请求 e2e_key_exchange=ecdh 时协商会话密钥，之后MQTT消息使用AES-256-GCM加密
+			E2EEncryptionEnabled bool `yaml:"e2e_encryption_enabled" json:"e2e_encryption_enabled"`
 			// UDP配置（可选，用于音频数据传输）
 			UDP struct {
 				Enabled      bool   `yaml:"enabled" json:"enabled"`
@@ -111,6 +146,10 @@ type Config struct {
 				ListenPort   int    `yaml:"listen_port" json:"listen_port"`
 				ExternalHost string `yaml:"external_host" json:"external_host"`
 				ExternalPort int    `yaml:"external_port" json:"external_port"`
+				// TURN中继（RFC 5766），配置后hello中返回中继地址，设备经中继收发音频
+				TurnServer   string `yaml:"turn_server" json:"turn_server"` // host:port
+				TurnUsername string `yaml:"turn_username" json:"turn_username"`
+				TurnPassword string `yaml:"turn_password" json:"turn_password"`
 			} `yaml:"udp" json:"udp"`
 		} `yaml:"mqtt" json:"mqtt"`
 	} `yaml:"transport" json:"transport"`
@@ -131,19 +170,156 @@ type Config struct {
 
 	DefaultPrompt    string   `yaml:"prompt"             json:"prompt"`
 	Roles            []string `yaml:"roles"              json:"roles"`         // 角色列表
-	DialogStorage    string   `yaml:"dialogStorage"      json:"dialogStorage"` // 对话存储类型，可选：postgres/redis
+	DialogStorage    string   `yaml:"dialogStorage"      json:"dialogStorage"` // 对话存储类型，可选：postgres/redis/file
+	DialogFileDir    string   `yaml:"dialogFileDir"      json:"dialogFileDir"` // file 存储时JSONL文件所在目录，默认 data/dialogues
 	DeleteAudio      bool     `yaml:"delete_audio"       json:"delete_audio"`
 	QuickReply       bool     `yaml:"quick_reply"        json:"quick_reply"`
 	QuickReplyWords  []string `yaml:"quick_reply_words"  json:"quick_reply_words"`
 	UsePrivateConfig bool     `yaml:"use_private_config" json:"use_private_config"`
 	LocalMCPFun      []string `yaml:"local_mcp_fun"      json:"local_mcp_fun"` // 本地MCP函数映射
 
+	// 去除表情后不超过该字数的TTS文本使用短文本缓存，避免常见语气词重复调用TTS，0表示不缓存
+	ShortTextCacheThresholdChars int `yaml:"short_text_cache_threshold_chars" json:"short_text_cache_threshold_chars"`
+
+	// TTS分段间静音时长(毫秒)，0表示不插入；开启后按句尾标点调整时长
+	TTSInterSegmentSilenceMs int `yaml:"tts_inter_segment_silence_ms" json:"tts_inter_segment_silence_ms"`
+
+	// TTS预取分段数，发送当前分段时提前合成后续分段，0表示关闭
+	TTSPrefetchCount int `yaml:"tts_prefetch_count" json:"tts_prefetch_count"`
+
+	// 主TTS返回容量、配额不足或429错误时按顺序尝试的备用TTS，值为 TTS 配置中的名称
+	TTSFallback []string `yaml:"tts_fallback" json:"tts_fallback"`
+
+	// 客户端hello中指定的会话级MCP服务
+	SessionMCP SessionMCPConfig `yaml:"session_mcp" json:"session_mcp"`
+
+	// 计算音频波形允许的最大文件大小（字节），0表示使用默认值50MB
+	WaveformMaxFileSize int64 `yaml:"waveform_max_file_size" json:"waveform_max_file_size"`
+
+	// 对话语言与TTS音色的映射，LLM回复中出现 [LANG:xx] 标记时切换到对应音色
+	LanguageVoices map[string]string `yaml:"language_voices" json:"language_voices"`
+
+	// 用户请求限流，多实例部署时使用 redis 后端共享计数
+	RateLimit RateLimitConfig `yaml:"rate_limit" json:"rate_limit"`
+
+	// 批量LLM推理接口单次允许的最大请求数与整体超时时间(秒)
+	BatchMaxRequests    int `yaml:"batch_max_requests" json:"batch_max_requests"`
+	BatchTimeoutSeconds int `yaml:"batch_timeout_seconds" json:"batch_timeout_seconds"`
+
+	// hybrid 拾音模式下等待客户端确认识别结果的超时时间(毫秒)，默认3000
+	ASRConfirmTimeoutMs int `yaml:"asr_confirm_timeout_ms" json:"asr_confirm_timeout_ms"`
+
+	// 导入外部对话记录时是否允许晚于当前时间的时间戳
+	ImportAllowFutureTimestamps bool `yaml:"import_allow_future_timestamps" json:"import_allow_future_timestamps"`
+
+	// TTS输出目录中孤立音频文件的最长保留时间(小时)，超过后由后台每小时清理，0表示不清理
+	AudioCleanupMaxAgeHours int `yaml:"audio_cleanup_max_age_hours" json:"audio_cleanup_max_age_hours"`
+
+	// 外部知识库检索服务，生成回复前检索相关内容注入LLM上下文，url 为空时不启用
+	KnowledgeBase KnowledgeBaseConfig `yaml:"knowledge_base" json:"knowledge_base"`
+
+	// MCP工具结果的webhook回调，base_url 或 secret 为空时回调工具按同步方式调用
+	MCPCallback MCPCallbackConfig `yaml:"mcp_callback" json:"mcp_callback"`
+
+	// Bot可见性变更（公开申请提交、通过、拒绝）事件的webhook推送地址，为空时不推送
+	BotVisibilityWebhook string `yaml:"bot_visibility_webhook" json:"bot_visibility_webhook"`
+
+	// 是否压缩 /api/v2 接口的响应（gzip/zstd），WebSocket 升级与 SSE 不压缩
+	APICompressionEnabled bool `yaml:"api_compression_enabled" json:"api_compression_enabled"`
+
+	// 响应体达到该大小(字节)才压缩，<=0 时为1024
+	APICompressionMinSizeBytes int `yaml:"api_compression_min_size_bytes" json:"api_compression_min_size_bytes"`
+
+	// 是否在识别前根据开头的音频自动识别语种并切换ASR语言与TTS音色，需配置 langdetect.url
+	LanguageDetectionEnabled bool `yaml:"language_detection_enabled" json:"language_detection_enabled"`
+
+	// 语种识别使用的开头音频时长(毫秒)，<=0 时为2000
+	LanguageDetectionBufferMs int `yaml:"language_detection_buffer_ms" json:"language_detection_buffer_ms"`
+
+	// 外部语种识别服务
+	LangDetect LangDetectConfig `yaml:"langdetect" json:"langdetect"`
+
+	// 同一文本消息在该时间窗口(毫秒)内重复发送时丢弃，<=0 时为500
+	DedupWindowMs int `yaml:"dedup_window_ms" json:"dedup_window_ms"`
+
+	// 是否记录LLM工具调用的审计日志，写入 function_call_audit_logs 表
+	AuditFunctionCalls bool `yaml:"audit_function_calls" json:"audit_function_calls"`
+
+	// LLM回复前缀，非空时在每轮第一个分段前播报，不写入对话历史；Bot配置的前缀优先
+	ResponsePrefix string `yaml:"response_prefix" json:"response_prefix"`
+	// 前缀单独合成为一个分段，不与第一句拼接
+	PrefixTTSOnly bool `yaml:"prefix_tts_only" json:"prefix_tts_only"`
+	// 单独合成前缀时使用的音色，为空时使用会话当前音色
+	PrefixVoice string `yaml:"prefix_voice" json:"prefix_voice"`
+
+	// 连接建立后主动播报的欢迎语，为空时不播报；唯一启用的Bot好友配置了 welcome_message 时优先
+	ConversationStarter string `yaml:"conversation_starter" json:"conversation_starter"`
+	// 连接建立后延迟多久播报欢迎语(毫秒)
+	ConversationStarterDelayMs int `yaml:"conversation_starter_delay_ms" json:"conversation_starter_delay_ms"`
+
+	// 每个用户每日可使用的LLM token预算（按字符数/4估算），0表示不限制；需配置redis_cache
+	DailyTokenBudget int64 `yaml:"daily_token_budget" json:"daily_token_budget"`
+
+	// 服务端播放TTS时对客户端上行音频做回声消除（谱减法），以正在播放的TTS音频为参考信号
+	EchoCancellationEnabled bool `yaml:"echo_cancellation_enabled" json:"echo_cancellation_enabled"`
+
+	// 对话结束自动关闭连接前，调用LLM生成会话摘要，保存为 role=summary 的对话记录并下发 session_summary
+	GenerateSessionSummaryOnClose bool `yaml:"generate_session_summary_on_close" json:"generate_session_summary_on_close"`
+
+	// WebSocket 每个客户端IP每分钟允许的连接次数，超出返回429，0表示不限制
+	WSConnectRatePerIP int `yaml:"ws_connect_rate_per_ip" json:"ws_connect_rate_per_ip"`
+
+	// WebSocket 心跳：每隔 WSPingIntervalSeconds 秒发送 ping，WSPingTimeoutSeconds 秒内未收到 pong 时关闭连接，间隔为0时不发送
+	WSPingIntervalSeconds int `yaml:"ws_ping_interval_seconds" json:"ws_ping_interval_seconds"`
+	WSPingTimeoutSeconds  int `yaml:"ws_ping_timeout_seconds" json:"ws_ping_timeout_seconds"`
+
+	// 回复情感分析方式：rule-based 为关键词规则，llm 为调用LLM标注，为空时使用 rule-based
+	SentimentModel string `yaml:"sentiment_model" json:"sentiment_model"`
+
+	// 是否在TTS前审核LLM回复的每个分段，未通过的分段替换为 moderation_fallback_message
+	ModerationEnabled bool `yaml:"moderation_enabled" json:"moderation_enabled"`
+	// 审核方式：rule 为关键词黑名单，llm 为调用LLM分类，为空时使用 rule
+	ModerationMode string `yaml:"moderation_mode" json:"moderation_mode"`
+	// 审核未通过时播报的内容，为空时使用默认提示
+	ModerationFallbackMessage string `yaml:"moderation_fallback_message" json:"moderation_fallback_message"`
+	// rule 方式的关键词黑名单，key为违规类别
+	ModerationBlocklist map[string][]string `yaml:"moderation_blocklist" json:"moderation_blocklist"`
+
+	// 执行失败的异步任务写入Redis死信队列，重试次数小于该值的任务自动重新提交，0表示不自动重试；需配置redis_cache
+	DLQMaxRetries int `yaml:"dlq_max_retries" json:"dlq_max_retries"`
+	// 死信任务自动重试的延迟(秒)，<=0 时为60
+	DLQRetryDelaySeconds int `yaml:"dlq_retry_delay_seconds" json:"dlq_retry_delay_seconds"`
+
+	// 将下发给设备的TTS音频帧发布到Redis频道 audio_out:{session_id}，供 /ws/audio-stream 实时监听；需配置redis_cache
+	AudioMonitorEnabled bool `yaml:"audio_monitor_enabled" json:"audio_monitor_enabled"`
+
+	// ASR连续静音的处理方式
+	ASRSilence ASRSilenceConfig `yaml:"asr_silence_config" json:"asr_silence_config"`
+
+	// 工具调用后继续请求LLM的最大嵌套深度，超过或同一函数在调用链中重复出现时中止，<=0 时为5
+	MaxToolCallDepth int `yaml:"max_tool_call_depth" json:"max_tool_call_depth"`
+
+	// 将已启用的LLM类Bot好友描述按优先级追加到系统提示词，告知模型可调用的专业助手
+	CompositeSystemPrompt bool `yaml:"composite_system_prompt" json:"composite_system_prompt"`
+	// 追加到系统提示词的Bot好友数量上限，<=0 时为5
+	CompositePromptMaxBots int `yaml:"composite_prompt_max_bots" json:"composite_prompt_max_bots"`
+
+	// 定期重新获取外部MCP服务器的工具列表并同步到函数注册表的间隔(分钟)，<=0 时不刷新
+	MCPToolRefreshIntervalMinutes int `yaml:"mcp_tool_refresh_interval_minutes" json:"mcp_tool_refresh_interval_minutes"`
+
+	// 非实时模式下为ASR最终结果恢复标点，未配置 punctuation_restore.url 时使用本地规则
+	PunctuationRestoreEnabled bool                     `yaml:"punctuation_restore_enabled" json:"punctuation_restore_enabled"`
+	PunctuationRestore        PunctuationRestoreConfig `yaml:"punctuation_restore" json:"punctuation_restore"`
+
 	SelectedModule map[string]string `yaml:"selected_module" json:"selected_module"`
 
+	// 租户的提供者配置，key为租户ID，未配置的项使用全局配置
+	Tenants map[string]TenantProviderConfig `yaml:"tenants" json:"tenants"`
+
 	PoolConfig    PoolConfig    `yaml:"pool_config"`
 	McpPoolConfig McpPoolConfig `yaml:"mcp_pool_config"`
 
-	ASR   map[string]ASRConfig  `yaml:"ASR"   json:"ASR"`
+	ASR   ASRModuleConfig       `yaml:"ASR"   json:"ASR"`
 	TTS   map[string]TTSConfig  `yaml:"TTS"   json:"TTS"`
 	LLM   map[string]LLMConfig  `yaml:"LLM"   json:"LLM"`
 	VLLLM map[string]VLLMConfig `yaml:"VLLLM" json:"VLLLM"`
@@ -153,10 +329,23 @@ type Config struct {
 	CMDExit []string  `yaml:"CMD_exit" json:"CMD_exit"`
 	OSS     OSSConfig `yaml:"oss" json:"oss"`
 
+	// 退出命令正则，在移除标点后匹配，无效的正则忽略
+	CMDExitPatterns []string `yaml:"CMD_exit_patterns" json:"CMD_exit_patterns"`
+	// CMD_exit 模糊匹配阈值(0~1)，编辑距离相似度不低于阈值即视为退出，0表示仅完全匹配
+	QuitIntentConfidenceThreshold float64 `yaml:"quit_intent_confidence_threshold" json:"quit_intent_confidence_threshold"`
+
 	// 连通性检查配置
 	ConnectivityCheck ConnectivityCheckConfig `yaml:"connectivity_check" json:"connectivity_check"`
 }
 
+// TenantProviderConfig 租户的提供者配置，selected_module 与各提供者配置覆盖全局配置中的同名项
+type TenantProviderConfig struct {
+	SelectedModule map[string]string    `yaml:"selected_module" json:"selected_module"`
+	ASR            map[string]ASRConfig `yaml:"ASR" json:"ASR"`
+	TTS            map[string]TTSConfig `yaml:"TTS" json:"TTS"`
+	LLM            map[string]LLMConfig `yaml:"LLM" json:"LLM"`
+}
+
 // OSSConfig 对象存储配置
 type OSSConfig struct {
 	Host            string `yaml:"host" json:"host"`
@@ -165,6 +354,19 @@ type OSSConfig struct {
 	AccessKeyID     string `yaml:"access_key_id" json:"access_key_id"`
 	AccessKeySecret string `yaml:"access_key_secret" json:"access_key_secret"`
 	Expiration      int64  `yaml:"expiration" json:"expiration"` // 预签名URL有效期(秒)
+
+	// 私有媒体预签名GET地址的默认有效期(秒)，<=0 时为3600
+	PresignedURLTTLSeconds int64 `yaml:"presigned_url_ttl_seconds" json:"presigned_url_ttl_seconds"`
+
+	// 媒体上传使用的存储后端：oss(默认)、s3
+	StorageBackend string `yaml:"storage_backend" json:"storage_backend"`
+
+	// S3兼容存储配置，storage_backend 为 s3 时使用
+	S3Bucket    string `yaml:"s3_bucket" json:"s3_bucket"`
+	S3Region    string `yaml:"s3_region" json:"s3_region"`
+	S3Endpoint  string `yaml:"s3_endpoint" json:"s3_endpoint"` // 自定义服务地址，如MinIO，为空时使用AWS
+	S3AccessKey string `yaml:"s3_access_key" json:"s3_access_key"`
+	S3SecretKey string `yaml:"s3_secret_key" json:"s3_secret_key"`
 }
 
 type PoolConfig struct {
@@ -172,6 +374,18 @@ type PoolConfig struct {
 	PoolMaxSize       int `yaml:"pool_max_size"`
 	PoolRefillSize    int `yaml:"pool_refill_size"`
 	PoolCheckInterval int `yaml:"pool_check_interval"`
+
+	// 按提供者类型覆盖最小、最大数量，0表示使用 pool_min_size、pool_max_size
+	ASRMin   int `yaml:"asr_min"`
+	ASRMax   int `yaml:"asr_max"`
+	LLMMin   int `yaml:"llm_min"`
+	LLMMax   int `yaml:"llm_max"`
+	TTSMin   int `yaml:"tts_min"`
+	TTSMax   int `yaml:"tts_max"`
+	VLLLMMin int `yaml:"vlllm_min"`
+	VLLLMMax int `yaml:"vlllm_max"`
+	VADMin   int `yaml:"vad_min"`
+	VADMax   int `yaml:"vad_max"`
 }
 type McpPoolConfig struct {
 	PoolMinSize       int `yaml:"pool_min_size"`
@@ -180,10 +394,64 @@ type McpPoolConfig struct {
 	PoolCheckInterval int `yaml:"pool_check_interval"`
 }
 
+// SessionMCPConfig 会话级MCP服务配置，仅允许连接白名单中的公网地址
+type SessionMCPConfig struct {
+	Enabled        bool     `yaml:"enabled" json:"enabled"`
+	AllowedHosts   []string `yaml:"allowed_hosts" json:"allowed_hosts"`     // 允许的主机名，"*.example.com" 匹配子域名
+	AllowedSchemes []string `yaml:"allowed_schemes" json:"allowed_schemes"` // 允许的协议，为空时仅允许 wss 和 https
+}
+
+// RateLimitConfig 用户请求限流配置，按固定时间窗口计数
+type RateLimitConfig struct {
+	Enabled       bool   `yaml:"enabled" json:"enabled"`
+	Backend       string `yaml:"backend" json:"backend"`               // memory 或 redis，为空时使用 memory
+	Limit         int    `yaml:"limit" json:"limit"`                   // 每个窗口内允许的请求数
+	WindowSeconds int    `yaml:"window_seconds" json:"window_seconds"` // 窗口长度(秒)
+}
+
+// KnowledgeBaseConfig 外部向量检索服务配置
+type KnowledgeBaseConfig struct {
+	URL    string `yaml:"url" json:"url"`         // 检索接口地址
+	APIKey string `yaml:"api_key" json:"api_key"` // 以 Bearer 方式携带，为空时不携带
+}
+
+// LangDetectConfig 外部语种识别服务配置
+type LangDetectConfig struct {
+	URL    string `yaml:"url" json:"url"`         // 识别接口地址
+	APIKey string `yaml:"api_key" json:"api_key"` // 以 Bearer 方式携带，为空时不携带
+}
+
+// PunctuationRestoreConfig 外部标点恢复服务配置
+type PunctuationRestoreConfig struct {
+	URL string `yaml:"url" json:"url"` // 服务地址，为空时使用本地规则
+}
+
+// ASRSilenceConfig ASR连续静音处理配置
+// 静音次数未达 MaxSilenceCount 时只提醒客户端，达到后执行 SilenceAction
+type ASRSilenceConfig struct {
+	MaxSilenceCount int    `yaml:"max_silence_count" json:"max_silence_count"` // <=0 时为2
+	SilenceAction   string `yaml:"silence_action" json:"silence_action"`       // close 让LLM结束对话后断开，prompt 只让LLM回复，noop 不处理；为空时为close
+	SilencePrompt   string `yaml:"silence_prompt" json:"silence_prompt"`       // close/prompt 时发给LLM的提示
+}
+
+// MCPCallbackConfig MCP工具异步回调配置
+type MCPCallbackConfig struct {
+	BaseURL        string `yaml:"base_url" json:"base_url"`               // MCP服务器可访问的HTTP服务地址，如 https://ai.example.com
+	Secret         string `yaml:"secret" json:"secret"`                   // 回调请求的HMAC-SHA256签名密钥
+	TimeoutSeconds int    `yaml:"timeout_seconds" json:"timeout_seconds"` // 等待回调的超时时间(秒)，默认300
+}
+
 // AUCConfig AUC配置结构
 type AUCConfig map[string]interface{}
 
-// ASRConfig ASR配置结构
+// ASRModuleConfig ASR配置项，除通用设置外的键均为按名称配置的ASR提供者
+type ASRModuleConfig struct {
+	// 置信度低于该值的ASR识别结果直接丢弃，<=0 时不过滤
+	MinConfidence float32              `yaml:"min_confidence" json:"min_confidence"`
+	Providers     map[string]ASRConfig `yaml:",inline" json:"providers"`
+}
+
+// ASRConfig ASR配置结构，can_share: true 表示所有会话共享一个ASR实例
 type ASRConfig map[string]interface{}
 
 type VoiceInfo struct {
@@ -227,6 +495,9 @@ type SecurityConfig struct {
 	AllowedFormats    []string `yaml:"allowed_formats"    json:"allowed_formats"`    // 允许的图片格式
 	EnableDeepScan    bool     `yaml:"enable_deep_scan"   json:"enable_deep_scan"`   // 启用深度安全扫描
 	ValidationTimeout string   `yaml:"validation_timeout" json:"validation_timeout"` // 验证超时时间
+	MaxDimension      int      `yaml:"max_dimension"      json:"max_dimension"`      // 提交前缩放的最长边，默认1920
+	JPEGQuality       int      `yaml:"jpeg_quality"       json:"jpeg_quality"`       // 提交前JPEG压缩质量，默认85
+	TranscodeQuality  int      `yaml:"transcode_quality"  json:"transcode_quality"`  // HEIC/AVIF转码为JPEG的质量，默认85
 }
 
 // ConnectivityCheckConfig 连通性检查配置结构
@@ -256,9 +527,55 @@ type VLLMConfig struct {
 }
 
 var (
-	Cfg *Config
+	Cfg *Config // 启动时加载的配置，热更新后的配置通过 Current 获取
+
+	current atomic.Pointer[Config]
 )
 
+// Current 获取当前生效的配置快照
+// 热更新时整体替换为新的快照而不修改旧快照，调用方不应修改返回的配置
+func Current() *Config {
+	return current.Load()
+}
+
+// SetCurrent 发布新的配置快照
+func SetCurrent(cfg *Config) {
+	current.Store(cfg)
+}
+
+// ForTenant 返回租户生效的配置快照：复制全局配置并合并租户的提供者配置，不修改全局配置
+func (cfg *Config) ForTenant(tenantID string) (*Config, error) {
+	if tenantID == "" {
+		return nil, fmt.Errorf("租户ID为空")
+	}
+	tenantCfg := *cfg
+	tenantCfg.Tenants = nil
+	override, ok := cfg.Tenants[tenantID]
+	if !ok {
+		return &tenantCfg, nil
+	}
+	tenantCfg.SelectedModule = mergeTenantMap(cfg.SelectedModule, override.SelectedModule)
+	tenantCfg.ASR.Providers = mergeTenantMap(cfg.ASR.Providers, override.ASR)
+	tenantCfg.TTS = mergeTenantMap(cfg.TTS, override.TTS)
+	tenantCfg.LLM = mergeTenantMap(cfg.LLM, override.LLM)
+	return &tenantCfg, nil
+}
+
+// mergeTenantMap 合并全局与租户配置，返回新的map，同名项以租户配置为准
+func mergeTenantMap[V any](base, override map[string]V) map[string]V {
+	if len(override) == 0 {
+		return base
+	}
+	merged := make(map[string]V, len(base)+len(override))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range override {
+		merged[k] = v
+	}
+	return merged
+}
+
 func (cfg *Config) ToString() string {
 	data, _ := yaml.Marshal(cfg)
 	return string(data)
@@ -274,6 +591,10 @@ func (cfg *Config) setDefaults() {
 	cfg.Transport.WebSocket.IP = "0.0.0.0"
 	cfg.Transport.WebSocket.Port = 8000
 
+	cfg.Transport.Grpc.Enabled = false
+	cfg.Transport.Grpc.IP = "0.0.0.0"
+	cfg.Transport.Grpc.Port = 50052
+
 	cfg.Transport.Mqtt.Enabled = false
 	cfg.Transport.Mqtt.Broker = "tcp://localhost:1883"
 	cfg.Transport.Mqtt.Username = ""
@@ -330,5 +651,6 @@ func LoadConfig(dbi ConfigDBInterface) (*Config, string, error) {
 	}
 
 	Cfg = config
+	current.Store(config)
 	return config, path, nil
 }
@@ -183,10 +183,17 @@ func migrateTables(db *gorm.DB) error {
 		&models.MediaUpload{},
 		&models.AuthClient{},
 		&models.AudioTask{},
+		&models.FunctionCallAuditLog{},
+		&models.FormSubmission{},
 		// 新的Bot配置系统模型
 		&models.ModelConfig{},
 		&models.BotConfig{},
+		&models.BotUsageStat{},
 		&models.UserFriend{},
+		&models.DeviceBotBinding{},
+		&models.DeviceGroup{},
+		&models.DeviceGroupMember{},
+		&models.UserVoice{},
 	)
 }
 
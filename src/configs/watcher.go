@@ -0,0 +1,179 @@
+package configs
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 配置文件默认检查间隔
+const defaultWatchInterval = 30 * time.Second
+
+// 变更后需要重建资源池的配置项
+var providerConfigKeys = map[string]bool{
+	"selected_module": true,
+	"pool_config":     true,
+	"mcp_pool_config": true,
+	"ASR":             true,
+	"TTS":             true,
+	"LLM":             true,
+	"VLLLM":           true,
+	"VAD":             true,
+	"tenants":         true,
+}
+
+// ConfigWatcher 配置文件监听器，定时检查文件内容变化并触发重载
+type ConfigWatcher struct {
+	path      string
+	interval  time.Duration
+	reload    func(*Config)
+	onInvalid func(errs []error)
+	lastData  []byte
+	trigger   chan struct{}
+	stopChan  chan struct{}
+	stopOnce  sync.Once
+}
+
+// StartConfigWatcher 启动配置文件监听，文件变化且校验通过后调用reload
+func StartConfigWatcher(path string, reload func(*Config)) *ConfigWatcher {
+	return startConfigWatcher(path, defaultWatchInterval, reload)
+}
+
+func startConfigWatcher(path string, interval time.Duration, reload func(*Config)) *ConfigWatcher {
+	w := &ConfigWatcher{
+		path:     path,
+		interval: interval,
+		reload:   reload,
+		trigger:  make(chan struct{}, 1),
+		stopChan: make(chan struct{}),
+	}
+	// 记录当前文件内容，作为后续比较的基准
+	w.lastData, _ = os.ReadFile(path)
+	go w.run()
+	return w
+}
+
+// OnInvalid 设置新配置校验失败时的回调
+func (w *ConfigWatcher) OnInvalid(fn func(errs []error)) {
+	w.onInvalid = fn
+}
+
+// Trigger 立即检查一次配置文件（如收到SIGHUP时）
+func (w *ConfigWatcher) Trigger() {
+	select {
+	case w.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// Stop 停止监听
+func (w *ConfigWatcher) Stop() {
+	w.stopOnce.Do(func() {
+		close(w.stopChan)
+	})
+}
+
+func (w *ConfigWatcher) run() {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.stopChan:
+			return
+		case <-ticker.C:
+			w.check()
+		case <-w.trigger:
+			w.check()
+		}
+	}
+}
+
+// check 文件内容变化时解析、校验并触发重载
+func (w *ConfigWatcher) check() {
+	data, err := os.ReadFile(w.path)
+	if err != nil || bytes.Equal(data, w.lastData) {
+		return
+	}
+	w.lastData = data
+
+	config := &Config{}
+	if err := yaml.Unmarshal(data, config); err != nil {
+		if w.onInvalid != nil {
+			w.onInvalid([]error{fmt.Errorf("解析配置文件失败: %v", err)})
+		}
+		return
+	}
+	if errs := ValidateConfig(config); len(errs) > 0 {
+		if w.onInvalid != nil {
+			w.onInvalid(errs)
+		}
+		return
+	}
+	w.reload(config)
+}
+
+// ValidateConfig 校验配置必填项
+func ValidateConfig(cfg *Config) []error {
+	var errs []error
+	if cfg.Server.Token == "" {
+		errs = append(errs, fmt.Errorf("server.token 未配置"))
+	}
+	if len(cfg.LLM) == 0 {
+		errs = append(errs, fmt.Errorf("至少需要配置一个LLM"))
+	} else if llmName := cfg.SelectedModule["LLM"]; llmName != "" {
+		if _, ok := cfg.LLM[llmName]; !ok {
+			errs = append(errs, fmt.Errorf("selected_module.LLM 指定的 %s 未在LLM中配置", llmName))
+		}
+	}
+	switch cfg.DB.Dialect {
+	case "":
+		// 未配置时使用默认SQLite
+	case "postgres", "sqlite":
+		if cfg.DB.DSN == "" {
+			errs = append(errs, fmt.Errorf("db.dsn 未配置"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("不支持的数据库类型: %s", cfg.DB.Dialect))
+	}
+	return errs
+}
+
+// DiffConfig 比较两份配置，返回发生变化的顶层配置项名称
+func DiffConfig(oldCfg, newCfg *Config) []string {
+	var changed []string
+	oldVal := reflect.ValueOf(oldCfg).Elem()
+	newVal := reflect.ValueOf(newCfg).Elem()
+	t := oldVal.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if !reflect.DeepEqual(oldVal.Field(i).Interface(), newVal.Field(i).Interface()) {
+			changed = append(changed, configKeyName(t.Field(i)))
+		}
+	}
+	return changed
+}
+
+// ProviderConfigChanged 判断变更项中是否包含提供者相关配置
+func ProviderConfigChanged(changedKeys []string) bool {
+	for _, key := range changedKeys {
+		if providerConfigKeys[key] {
+			return true
+		}
+	}
+	return false
+}
+
+// configKeyName 返回字段对应的yaml配置项名称
+func configKeyName(field reflect.StructField) string {
+	tag := strings.Split(field.Tag.Get("yaml"), ",")[0]
+	if tag == "" || tag == "-" {
+		return strings.ToLower(field.Name)
+	}
+	return tag
+}
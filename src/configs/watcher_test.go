@@ -0,0 +1,122 @@
+package configs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const testConfigYAML = `
+server:
+  token: "abc"
+selected_module:
+  LLM: "OpenAILLM"
+LLM:
+  OpenAILLM:
+    type: openai
+    model_name: %s
+`
+
+func writeTestConfig(t *testing.T, path, model string) {
+	t.Helper()
+	data := []byte(fmt.Sprintf(testConfigYAML, model))
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatalf("写入配置文件失败: %v", err)
+	}
+}
+
+func TestConfigWatcherReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeTestConfig(t, path, "gpt-4o")
+
+	reloaded := make(chan *Config, 1)
+	w := startConfigWatcher(path, 20*time.Millisecond, func(cfg *Config) {
+		reloaded <- cfg
+	})
+	defer w.Stop()
+
+	// 文件未变化时不应触发
+	select {
+	case <-reloaded:
+		t.Fatal("配置未变化却触发了重载")
+	case <-time.After(80 * time.Millisecond):
+	}
+
+	writeTestConfig(t, path, "gpt-4o-mini")
+	select {
+	case cfg := <-reloaded:
+		if cfg.LLM["OpenAILLM"].ModelName != "gpt-4o-mini" {
+			t.Errorf("重载配置 model_name = %s, 期望 gpt-4o-mini", cfg.LLM["OpenAILLM"].ModelName)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("修改配置文件后未触发重载")
+	}
+}
+
+func TestConfigWatcherInvalidConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeTestConfig(t, path, "gpt-4o")
+
+	reloaded := make(chan *Config, 1)
+	invalid := make(chan []error, 1)
+	w := startConfigWatcher(path, time.Hour, func(cfg *Config) {
+		reloaded <- cfg
+	})
+	w.OnInvalid(func(errs []error) { invalid <- errs })
+	defer w.Stop()
+
+	// 缺少token和LLM配置
+	if err := os.WriteFile(path, []byte("server:\n  port: 8000\n"), 0o644); err != nil {
+		t.Fatalf("写入配置文件失败: %v", err)
+	}
+	w.Trigger()
+
+	select {
+	case errs := <-invalid:
+		if len(errs) != 2 {
+			t.Errorf("校验错误数量 = %d, 期望 2: %v", len(errs), errs)
+		}
+	case <-reloaded:
+		t.Fatal("无效配置不应触发重载")
+	case <-time.After(2 * time.Second):
+		t.Fatal("未收到校验失败回调")
+	}
+}
+
+func TestDiffConfig(t *testing.T) {
+	oldCfg := &Config{LLM: map[string]LLMConfig{"a": {ModelName: "x"}}}
+	newCfg := &Config{LLM: map[string]LLMConfig{"a": {ModelName: "y"}}, DeleteAudio: true}
+
+	changed := DiffConfig(oldCfg, newCfg)
+	if len(changed) != 2 || changed[0] != "delete_audio" || changed[1] != "LLM" {
+		t.Errorf("变更项 = %v, 期望 [delete_audio LLM]", changed)
+	}
+	if !ProviderConfigChanged(changed) {
+		t.Errorf("LLM变更应判定为提供者配置变化")
+	}
+	if ProviderConfigChanged([]string{"delete_audio"}) {
+		t.Errorf("delete_audio变更不应判定为提供者配置变化")
+	}
+}
+
+func TestASRConfigMinConfidence(t *testing.T) {
+	var cfg Config
+	data := "ASR:\n  min_confidence: 0.6\n  DoubaoASR:\n    type: doubao\n    can_share: true\n"
+	if err := cfg.FromString(data); err != nil {
+		t.Fatalf("解析配置失败: %v", err)
+	}
+	if cfg.ASR.MinConfidence != 0.6 || cfg.ASR.Providers["DoubaoASR"]["type"] != "doubao" || len(cfg.ASR.Providers) != 1 {
+		t.Fatalf("ASR配置 = %+v, 期望 min_confidence 0.6 且只有 DoubaoASR 提供者", cfg.ASR)
+	}
+
+	var reloaded Config
+	if err := reloaded.FromString(cfg.ToString()); err != nil {
+		t.Fatalf("重新解析配置失败: %v", err)
+	}
+	if !reflect.DeepEqual(reloaded.ASR, cfg.ASR) {
+		t.Errorf("序列化后 ASR配置 = %+v, 期望 %+v", reloaded.ASR, cfg.ASR)
+	}
+}
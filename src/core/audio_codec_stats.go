@@ -0,0 +1,164 @@
+package core
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// codecStatsWindow 压缩比按最近多少帧计算滑动平均
+const codecStatsWindow = 100
+
+// opusFrameDecoder Opus解码器，测试中可替换
+type opusFrameDecoder interface {
+	Decode(opusData []byte) ([]byte, error)
+	Close() error
+}
+
+// AudioCodecSnapshot 会话上行音频编解码统计快照
+type AudioCodecSnapshot struct {
+	SessionID            string  `json:"session_id"`
+	Codec                string  `json:"codec"`
+	Packets              int64   `json:"packets"`                 // 送入解码器的音频包数
+	DecodeErrors         int64   `json:"decode_errors"`           // 解码失败的包数
+	DecodeErrorRate      float64 `json:"decode_error_rate"`       // 解码失败比例
+	AvgEncodedFrameBytes float64 `json:"avg_encoded_frame_bytes"` // 解码成功的帧的平均编码大小
+	AvgDecodedFrameBytes float64 `json:"avg_decoded_frame_bytes"` // 解码成功的帧的平均PCM大小
+	CompressionRatio     float64 `json:"compression_ratio"`       // 最近100帧编码字节数与PCM字节数之比
+}
+
+// AudioCodecStats 统计单个会话Opus解码的压缩比与错误率，nil 时所有记录操作为空操作
+type AudioCodecStats struct {
+	sessionID string
+
+	mu           sync.Mutex
+	packets      int64
+	decodeErrors int64
+	encodedBytes int64 // 解码成功的帧的编码字节数
+	decodedBytes int64
+	decodedCount int64
+	// 最近 codecStatsWindow 个解码成功的帧的编码与PCM字节数，环形缓冲
+	windowEncoded [codecStatsWindow]int
+	windowDecoded [codecStatsWindow]int
+	windowNext    int
+	windowSize    int
+}
+
+// NewAudioCodecStats 创建会话音频编解码统计
+func NewAudioCodecStats(sessionID string) *AudioCodecStats {
+	return &AudioCodecStats{sessionID: sessionID}
+}
+
+// RecordFrame 记录一个解码成功的帧
+func (s *AudioCodecStats) RecordFrame(encoded, decoded int) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.packets++
+	s.decodedCount++
+	s.encodedBytes += int64(encoded)
+	s.decodedBytes += int64(decoded)
+	s.windowEncoded[s.windowNext] = encoded
+	s.windowDecoded[s.windowNext] = decoded
+	s.windowNext = (s.windowNext + 1) % codecStatsWindow
+	s.windowSize = min(s.windowSize+1, codecStatsWindow)
+}
+
+// RecordDecodeError 记录一个解码失败的帧
+func (s *AudioCodecStats) RecordDecodeError() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.packets++
+	s.decodeErrors++
+}
+
+// Snapshot 获取当前统计快照
+func (s *AudioCodecStats) Snapshot() AudioCodecSnapshot {
+	if s == nil {
+		return AudioCodecSnapshot{}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	snapshot := AudioCodecSnapshot{
+		SessionID:    s.sessionID,
+		Codec:        "opus",
+		Packets:      s.packets,
+		DecodeErrors: s.decodeErrors,
+	}
+	if s.packets > 0 {
+		snapshot.DecodeErrorRate = float64(s.decodeErrors) / float64(s.packets)
+	}
+	if s.decodedCount > 0 {
+		snapshot.AvgEncodedFrameBytes = float64(s.encodedBytes) / float64(s.decodedCount)
+		snapshot.AvgDecodedFrameBytes = float64(s.decodedBytes) / float64(s.decodedCount)
+	}
+	var encoded, decoded int
+	for i := 0; i < s.windowSize; i++ {
+		encoded += s.windowEncoded[i]
+		decoded += s.windowDecoded[i]
+	}
+	if decoded > 0 {
+		snapshot.CompressionRatio = float64(encoded) / float64(decoded)
+	}
+	return snapshot
+}
+
+// audioCodecCollector 采集所有活跃会话的编解码统计，导出为按 session_id 区分的 Prometheus 仪表
+type audioCodecCollector struct {
+	stats sync.Map // *AudioCodecStats -> struct{}
+
+	compressionRatio *prometheus.Desc
+	errorRate        *prometheus.Desc
+}
+
+var defaultAudioCodecCollector = newAudioCodecCollector()
+
+func newAudioCodecCollector() *audioCodecCollector {
+	labels := []string{"session_id"}
+	return &audioCodecCollector{
+		compressionRatio: prometheus.NewDesc("session_audio_compression_ratio", "会话最近100帧Opus编码字节数与PCM字节数之比", labels, nil),
+		errorRate:        prometheus.NewDesc("session_audio_decode_error_rate", "会话Opus解码失败比例", labels, nil),
+	}
+}
+
+// RegisterAudioCodecMetrics 将会话编解码统计注册到 Prometheus
+func RegisterAudioCodecMetrics(registerer prometheus.Registerer) error {
+	return registerer.Register(defaultAudioCodecCollector)
+}
+
+func (c *audioCodecCollector) add(s *AudioCodecStats) {
+	if s != nil {
+		c.stats.Store(s, struct{}{})
+	}
+}
+
+func (c *audioCodecCollector) remove(s *AudioCodecStats) {
+	if s != nil {
+		c.stats.Delete(s)
+	}
+}
+
+func (c *audioCodecCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.compressionRatio
+	ch <- c.errorRate
+}
+
+// Collect 只导出已收到Opus音频的会话，session_id 重复时只导出其中一个
+func (c *audioCodecCollector) Collect(ch chan<- prometheus.Metric) {
+	seen := make(map[string]struct{})
+	c.stats.Range(func(key, _ interface{}) bool {
+		s := key.(*AudioCodecStats).Snapshot()
+		if _, ok := seen[s.SessionID]; ok || s.Packets == 0 {
+			return true
+		}
+		seen[s.SessionID] = struct{}{}
+		ch <- prometheus.MustNewConstMetric(c.compressionRatio, prometheus.GaugeValue, s.CompressionRatio, s.SessionID)
+		ch <- prometheus.MustNewConstMetric(c.errorRate, prometheus.GaugeValue, s.DecodeErrorRate, s.SessionID)
+		return true
+	})
+}
@@ -0,0 +1,68 @@
+package core
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"angrymiao-ai-server/src/configs"
+)
+
+// fixedOpusDecoder 每个编码字节解码为 ratio 个PCM字节，长度为1的包解码失败
+type fixedOpusDecoder struct {
+	ratio int
+}
+
+func (d *fixedOpusDecoder) Decode(opusData []byte) ([]byte, error) {
+	if len(opusData) == 1 {
+		return nil, errors.New("invalid packet")
+	}
+	return make([]byte, len(opusData)*d.ratio), nil
+}
+
+func (d *fixedOpusDecoder) Close() error { return nil }
+
+func TestAudioCodecStatsFromOpusFrames(t *testing.T) {
+	h := newPrefixTestHandler(t, &configs.Config{})
+	h.clientAudioFormat = "opus"
+	h.clientAudioQueue = make(chan clientAudioFrame, 20)
+	h.opusDecoder = &fixedOpusDecoder{ratio: 8}
+	h.codecStats = NewAudioCodecStats("s1")
+
+	// 10 帧，编码大小 40..130 字节，解码为8倍大小
+	for i := 0; i < 10; i++ {
+		if err := h.handleMessage(2, make([]byte, 40+i*10)); err != nil {
+			t.Fatalf("处理音频帧失败: %v", err)
+		}
+	}
+	h.handleMessage(2, []byte{0})
+
+	stats := h.AudioCodecStats()
+	if stats.Packets != 11 || stats.DecodeErrors != 1 || math.Abs(stats.DecodeErrorRate-1.0/11) > 1e-9 {
+		t.Errorf("包数与错误率 = %+v", stats)
+	}
+	if stats.AvgEncodedFrameBytes != 85 || stats.AvgDecodedFrameBytes != 680 || stats.CompressionRatio != 0.125 {
+		t.Errorf("平均帧大小与压缩比 = %+v", stats)
+	}
+}
+
+func TestAudioCodecStatsRollingWindow(t *testing.T) {
+	s := NewAudioCodecStats("s1")
+	for i := 0; i < codecStatsWindow; i++ {
+		s.RecordFrame(10, 100)
+	}
+	// 后续帧挤出窗口中最早的帧，压缩比只反映最近100帧
+	for i := 0; i < codecStatsWindow; i++ {
+		s.RecordFrame(50, 100)
+	}
+	stats := s.Snapshot()
+	if stats.CompressionRatio != 0.5 || stats.AvgEncodedFrameBytes != 30 {
+		t.Errorf("滑动窗口统计 = %+v", stats)
+	}
+
+	var nilStats *AudioCodecStats
+	nilStats.RecordFrame(1, 1)
+	if nilStats.Snapshot().Packets != 0 {
+		t.Error("nil 统计应为空操作")
+	}
+}
@@ -0,0 +1,262 @@
+package core
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// 延迟归因的各阶段
+const (
+	LatencyStageFrameToASR      = "frame_to_asr"       // 音频帧到达至送入ASR（含排队、回声消除与VAD处理）
+	LatencyStageASR             = "asr"                // 本轮首次送入ASR至ASR最终结果
+	LatencyStageASRToLLM        = "asr_to_llm"         // ASR最终结果至LLM首个token
+	LatencyStageLLMToTTS        = "llm_to_tts"         // LLM首个token至开始TTS合成
+	LatencyStageTTSToFirstAudio = "tts_to_first_audio" // 开始TTS合成至发送首个音频帧
+)
+
+// latencyStages 统计的全部阶段
+var latencyStages = []string{
+	LatencyStageFrameToASR,
+	LatencyStageASR,
+	LatencyStageASRToLLM,
+	LatencyStageLLMToTTS,
+	LatencyStageTTSToFirstAudio,
+}
+
+// latencyBucketBoundsMs 直方图桶上界（毫秒），超出最后一个上界的样本计入溢出桶
+var latencyBucketBoundsMs = []float64{1, 5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000}
+
+// LatencyBucket 直方图桶，LeMs 为0表示溢出桶
+type LatencyBucket struct {
+	LeMs  float64 `json:"le_ms"`
+	Count int64   `json:"count"`
+}
+
+// LatencyStageStats 单个阶段的延迟分布，分位数按桶上界估算且不超过最大值
+type LatencyStageStats struct {
+	Count   int64           `json:"count"`
+	MinMs   float64         `json:"min_ms"`
+	MaxMs   float64         `json:"max_ms"`
+	P50Ms   float64         `json:"p50_ms"`
+	P95Ms   float64         `json:"p95_ms"`
+	P99Ms   float64         `json:"p99_ms"`
+	Buckets []LatencyBucket `json:"buckets"`
+}
+
+// AudioLatencySnapshot 一轮对话的延迟归因快照
+type AudioLatencySnapshot struct {
+	Turn   int                          `json:"turn"` // 会话内第几轮，尚未开始时为0
+	Stages map[string]LatencyStageStats `json:"stages"`
+}
+
+// AudioLatencyReport 会话延迟归因，包含进行中的一轮与上一轮
+type AudioLatencyReport struct {
+	Current  AudioLatencySnapshot  `json:"current"`
+	Previous *AudioLatencySnapshot `json:"previous,omitempty"`
+}
+
+// latencyHistogram 固定桶直方图
+type latencyHistogram struct {
+	counts []int64 // 最后一个元素为溢出桶
+	count  int64
+	min    float64
+	max    float64
+}
+
+func newLatencyHistogram() *latencyHistogram {
+	return &latencyHistogram{counts: make([]int64, len(latencyBucketBoundsMs)+1)}
+}
+
+func (h *latencyHistogram) observe(ms float64) {
+	i := 0
+	for i < len(latencyBucketBoundsMs) && ms > latencyBucketBoundsMs[i] {
+		i++
+	}
+	h.counts[i]++
+	if h.count == 0 || ms < h.min {
+		h.min = ms
+	}
+	if ms > h.max {
+		h.max = ms
+	}
+	h.count++
+}
+
+// quantile 返回第一个累计数达到 q 分位的桶上界，溢出桶或上界超过最大值时返回最大值
+func (h *latencyHistogram) quantile(q float64) float64 {
+	if h.count == 0 {
+		return 0
+	}
+	rank := int64(math.Ceil(q * float64(h.count)))
+	var cumulative int64
+	for i, count := range h.counts {
+		cumulative += count
+		if cumulative >= rank {
+			if i < len(latencyBucketBoundsMs) {
+				return min(latencyBucketBoundsMs[i], h.max)
+			}
+			break
+		}
+	}
+	return h.max
+}
+
+func (h *latencyHistogram) stats() LatencyStageStats {
+	buckets := make([]LatencyBucket, len(h.counts))
+	for i, count := range h.counts {
+		if i < len(latencyBucketBoundsMs) {
+			buckets[i].LeMs = latencyBucketBoundsMs[i]
+		}
+		buckets[i].Count = count
+	}
+	return LatencyStageStats{
+		Count:   h.count,
+		MinMs:   h.min,
+		MaxMs:   h.max,
+		P50Ms:   h.quantile(0.50),
+		P95Ms:   h.quantile(0.95),
+		P99Ms:   h.quantile(0.99),
+		Buckets: buckets,
+	}
+}
+
+// AudioLatencyStats 按对话轮次统计各阶段延迟，时间戳均为 UnixNano，nil 时所有记录操作为空操作
+// 上一轮ASR出最终结果后首次送入ASR的音频开始新的一轮，此时重置直方图并保留上一轮的快照
+type AudioLatencyStats struct {
+	mu         sync.Mutex
+	turn       int
+	histograms map[string]*latencyHistogram
+	previous   *AudioLatencySnapshot
+
+	asrStartAt      int64
+	asrFinalAt      int64
+	llmFirstTokenAt int64
+	ttsStartAt      int64
+	audioSentAt     int64
+}
+
+// NewAudioLatencyStats 创建会话延迟归因统计
+func NewAudioLatencyStats() *AudioLatencyStats {
+	s := &AudioLatencyStats{}
+	s.resetLocked()
+	return s
+}
+
+func (s *AudioLatencyStats) resetLocked() {
+	s.histograms = make(map[string]*latencyHistogram, len(latencyStages))
+	for _, stage := range latencyStages {
+		s.histograms[stage] = newLatencyHistogram()
+	}
+	s.asrStartAt, s.asrFinalAt, s.llmFirstTokenAt, s.ttsStartAt, s.audioSentAt = 0, 0, 0, 0, 0
+}
+
+func (s *AudioLatencyStats) observeLocked(stage string, from, to int64) {
+	if from == 0 || to < from {
+		return
+	}
+	s.histograms[stage].observe(float64(to-from) / float64(time.Millisecond))
+}
+
+// RecordFrameToASR 记录音频帧从到达到送入ASR的耗时
+func (s *AudioLatencyStats) RecordFrameToASR(receivedAt, addedAt int64) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.observeLocked(LatencyStageFrameToASR, receivedAt, addedAt)
+}
+
+// RecordASRStart 记录音频送入ASR，上一轮已出最终结果时开始新的一轮
+func (s *AudioLatencyStats) RecordASRStart(at int64) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.asrStartAt != 0 && s.asrFinalAt == 0 {
+		return
+	}
+	if s.turn > 0 {
+		previous := s.snapshotLocked()
+		s.previous = &previous
+		s.resetLocked()
+	}
+	s.turn++
+	s.asrStartAt = at
+}
+
+// RecordASRFinal 记录本轮ASR最终结果
+func (s *AudioLatencyStats) RecordASRFinal(at int64) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.asrFinalAt != 0 || s.asrStartAt == 0 {
+		return
+	}
+	s.asrFinalAt = at
+	s.observeLocked(LatencyStageASR, s.asrStartAt, at)
+}
+
+// RecordLLMFirstToken 记录本轮LLM首个token，同一轮多次调用LLM时只记录第一次
+func (s *AudioLatencyStats) RecordLLMFirstToken(at int64) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.llmFirstTokenAt != 0 || s.asrFinalAt == 0 {
+		return
+	}
+	s.llmFirstTokenAt = at
+	s.observeLocked(LatencyStageASRToLLM, s.asrFinalAt, at)
+}
+
+// RecordTTSStart 记录本轮首次开始TTS合成
+func (s *AudioLatencyStats) RecordTTSStart(at int64) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ttsStartAt != 0 || s.llmFirstTokenAt == 0 {
+		return
+	}
+	s.ttsStartAt = at
+	s.observeLocked(LatencyStageLLMToTTS, s.llmFirstTokenAt, at)
+}
+
+// RecordAudioSent 记录本轮首个TTS音频帧发送
+func (s *AudioLatencyStats) RecordAudioSent(at int64) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.audioSentAt != 0 || s.ttsStartAt == 0 {
+		return
+	}
+	s.audioSentAt = at
+	s.observeLocked(LatencyStageTTSToFirstAudio, s.ttsStartAt, at)
+}
+
+func (s *AudioLatencyStats) snapshotLocked() AudioLatencySnapshot {
+	snapshot := AudioLatencySnapshot{Turn: s.turn, Stages: make(map[string]LatencyStageStats, len(latencyStages))}
+	for _, stage := range latencyStages {
+		snapshot.Stages[stage] = s.histograms[stage].stats()
+	}
+	return snapshot
+}
+
+// Snapshot 获取当前轮与上一轮的延迟快照，尚无上一轮时 Previous 为nil
+func (s *AudioLatencyStats) Snapshot() AudioLatencyReport {
+	if s == nil {
+		return AudioLatencyReport{}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return AudioLatencyReport{Current: s.snapshotLocked(), Previous: s.previous}
+}
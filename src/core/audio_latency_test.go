@@ -0,0 +1,125 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"angrymiao-ai-server/src/core/utils"
+)
+
+// bucketCount 返回上界为 leMs 的桶的样本数，leMs 为0表示溢出桶
+func bucketCount(t *testing.T, stats LatencyStageStats, leMs float64) int64 {
+	t.Helper()
+	for _, bucket := range stats.Buckets {
+		if bucket.LeMs == leMs {
+			return bucket.Count
+		}
+	}
+	t.Fatalf("不存在上界为 %vms 的桶", leMs)
+	return 0
+}
+
+func TestAudioLatencyStatsPerTurn(t *testing.T) {
+	ms := int64(time.Millisecond)
+	base := int64(1_700_000_000) * int64(time.Second)
+	s := NewAudioLatencyStats()
+
+	s.RecordASRStart(base)
+	s.RecordASRStart(base + 10*ms) // 同一轮内重复送入不影响起点
+	// 98帧3ms、1帧40ms、1帧20s
+	for i := 0; i < 98; i++ {
+		s.RecordFrameToASR(base, base+3*ms)
+	}
+	s.RecordFrameToASR(base, base+40*ms)
+	s.RecordFrameToASR(base, base+20000*ms)
+	s.RecordLLMFirstToken(base + 100*ms) // ASR未出最终结果时忽略
+	s.RecordASRFinal(base + 800*ms)
+	s.RecordLLMFirstToken(base + 1100*ms)
+	s.RecordLLMFirstToken(base + 1500*ms) // 同一轮第二次调用LLM忽略
+	s.RecordTTSStart(base + 1150*ms)
+	s.RecordAudioSent(base + 1400*ms)
+
+	report := s.Snapshot()
+	if report.Current.Turn != 1 || report.Previous != nil {
+		t.Fatalf("第一轮快照 = turn %d, previous %v", report.Current.Turn, report.Previous)
+	}
+	frames := report.Current.Stages[LatencyStageFrameToASR]
+	if frames.Count != 100 || bucketCount(t, frames, 5) != 98 || bucketCount(t, frames, 50) != 1 || bucketCount(t, frames, 0) != 1 {
+		t.Errorf("帧到ASR直方图 = %+v", frames.Buckets)
+	}
+	if frames.P50Ms != 5 || frames.P95Ms != 5 || frames.P99Ms != 50 || frames.MinMs != 3 || frames.MaxMs != 20000 {
+		t.Errorf("帧到ASR分位数 p50=%v p95=%v p99=%v max=%v", frames.P50Ms, frames.P95Ms, frames.P99Ms, frames.MaxMs)
+	}
+	// 单个样本时分位数等于样本值
+	for stage, want := range map[string]struct{ ms, le float64 }{
+		LatencyStageASR:             {800, 1000},
+		LatencyStageASRToLLM:        {300, 500},
+		LatencyStageLLMToTTS:        {50, 50},
+		LatencyStageTTSToFirstAudio: {250, 250},
+	} {
+		stats := report.Current.Stages[stage]
+		if stats.Count != 1 || stats.P50Ms != want.ms || stats.P99Ms != want.ms || bucketCount(t, stats, want.le) != 1 {
+			t.Errorf("%s = %+v, 期望单个 %vms 样本", stage, stats, want.ms)
+		}
+	}
+
+	// ASR出最终结果后再次送入音频开始新的一轮
+	s.RecordASRStart(base + 5000*ms)
+	s.RecordFrameToASR(base+5000*ms, base+5001*ms)
+	report = s.Snapshot()
+	if report.Current.Turn != 2 || report.Previous == nil || report.Previous.Turn != 1 {
+		t.Fatalf("第二轮快照 = %+v", report)
+	}
+	if got := report.Current.Stages[LatencyStageFrameToASR]; got.Count != 1 || bucketCount(t, got, 1) != 1 {
+		t.Errorf("新一轮应重置直方图: %+v", got)
+	}
+	if got := report.Current.Stages[LatencyStageASR]; got.Count != 0 || got.P95Ms != 0 {
+		t.Errorf("新一轮尚未出最终结果: %+v", got)
+	}
+	if got := report.Previous.Stages[LatencyStageFrameToASR].Count; got != 100 {
+		t.Errorf("上一轮帧样本数 = %d, 期望 100", got)
+	}
+}
+
+func TestAudioLatencyTimestampsQueuedFrames(t *testing.T) {
+	logger, err := utils.NewLogger(&utils.LogCfg{LogLevel: "ERROR", LogDir: t.TempDir(), LogFile: "test.log"})
+	if err != nil {
+		t.Fatalf("创建日志失败: %v", err)
+	}
+	asr := &languageASR{}
+	h := &ConnectionHandler{
+		logger:           logger,
+		stopChan:         make(chan struct{}),
+		clientAudioQueue: make(chan clientAudioFrame, 4),
+		audioLatency:     NewAudioLatencyStats(),
+	}
+	h.providers.asr = asr
+
+	// 帧在200ms前到达服务端，排队后才被处理
+	receivedAt := time.Now().Add(-200 * time.Millisecond).UnixNano()
+	for i := 0; i < 3; i++ {
+		h.enqueueClientAudio(make([]byte, 640), receivedAt)
+	}
+	done := make(chan struct{})
+	go func() {
+		h.processClientAudioMessagesCoroutine()
+		close(done)
+	}()
+	deadline := time.Now().Add(2 * time.Second)
+	for h.AudioLatency().Current.Stages[LatencyStageFrameToASR].Count < 3 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	close(h.stopChan)
+	<-done
+
+	stats := h.AudioLatency().Current.Stages[LatencyStageFrameToASR]
+	if stats.Count != 3 || bucketCount(t, stats, 250) != 3 {
+		t.Errorf("帧到ASR直方图 = %+v, 期望3个样本落在 (100, 250]ms 桶", stats)
+	}
+	if stats.MinMs < 200 || stats.P50Ms != stats.P99Ms {
+		t.Errorf("帧到ASR延迟 min=%v p50=%v p99=%v", stats.MinMs, stats.P50Ms, stats.P99Ms)
+	}
+	if asr.audio != 3*640 {
+		t.Errorf("送入ASR %d 字节, 期望 %d", asr.audio, 3*640)
+	}
+}
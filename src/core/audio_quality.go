@@ -0,0 +1,207 @@
+package core
+
+import (
+	"encoding/binary"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// AudioQualitySnapshot 会话音频质量指标快照
+type AudioQualitySnapshot struct {
+	SessionID         string  `json:"session_id"`
+	ASRConfidence     float64 `json:"asr_confidence"`         // 平均ASR置信度，ASR不提供置信度时为0
+	ConfidenceSamples int     `json:"asr_confidence_samples"` // 参与平均的识别结果数
+	AvgRMS            float64 `json:"avg_rms"`                // 接收PCM帧的平均RMS能量
+	LastRMS           float64 `json:"last_rms"`               // 最近一帧的RMS能量
+	FramesReceived    int64   `json:"frames_received"`
+	FramesDropped     int64   `json:"frames_dropped"` // clientAudioQueue 已满时丢弃的帧数
+	PacketLossRate    float64 `json:"packet_loss_rate"`
+	EndToEndLatencyMs int64   `json:"end_to_end_latency_ms"` // 首个音频帧到首个TTS音频发送的耗时，尚未发送时为0
+}
+
+// AudioQualityTracker 统计单个会话的音频质量指标，nil 时所有记录操作为空操作
+type AudioQualityTracker struct {
+	sessionID string
+
+	mu              sync.Mutex
+	confidenceSum   float64
+	confidenceCount int
+	rmsSum          float64
+	rmsCount        int64
+	lastRMS         float64
+	framesReceived  int64
+	framesDropped   int64
+	firstAudioAt    time.Time
+	latency         time.Duration
+}
+
+// NewAudioQualityTracker 创建会话音频质量统计
+func NewAudioQualityTracker(sessionID string) *AudioQualityTracker {
+	return &AudioQualityTracker{sessionID: sessionID}
+}
+
+// RecordConfidence 记录一次识别结果的置信度
+func (t *AudioQualityTracker) RecordConfidence(confidence float64) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.confidenceSum += confidence
+	t.confidenceCount++
+}
+
+// RecordFrameReceived 记录放入 clientAudioQueue 的音频帧，首帧作为端到端延迟的起点
+func (t *AudioQualityTracker) RecordFrameReceived() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.framesReceived++
+	if t.firstAudioAt.IsZero() {
+		t.firstAudioAt = time.Now()
+	}
+}
+
+// RecordFrameDropped 记录因 clientAudioQueue 已满而丢弃的音频帧
+func (t *AudioQualityTracker) RecordFrameDropped() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.framesDropped++
+}
+
+// RecordPCM 计算一帧16位PCM的RMS能量并累计
+func (t *AudioQualityTracker) RecordPCM(pcm []byte) {
+	if t == nil || len(pcm) < 2 {
+		return
+	}
+	rms := CalculateRMS(pcm)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rmsSum += rms
+	t.rmsCount++
+	t.lastRMS = rms
+}
+
+// RecordTTSAudioSent 记录TTS音频发送，仅首次发送时计算端到端延迟
+func (t *AudioQualityTracker) RecordTTSAudioSent() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.latency == 0 && !t.firstAudioAt.IsZero() {
+		t.latency = max(time.Since(t.firstAudioAt), time.Millisecond)
+	}
+}
+
+// Snapshot 获取当前指标快照
+func (t *AudioQualityTracker) Snapshot() AudioQualitySnapshot {
+	if t == nil {
+		return AudioQualitySnapshot{}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	snapshot := AudioQualitySnapshot{
+		SessionID:         t.sessionID,
+		ConfidenceSamples: t.confidenceCount,
+		LastRMS:           t.lastRMS,
+		FramesReceived:    t.framesReceived,
+		FramesDropped:     t.framesDropped,
+		EndToEndLatencyMs: t.latency.Milliseconds(),
+	}
+	if t.confidenceCount > 0 {
+		snapshot.ASRConfidence = t.confidenceSum / float64(t.confidenceCount)
+	}
+	if t.rmsCount > 0 {
+		snapshot.AvgRMS = t.rmsSum / float64(t.rmsCount)
+	}
+	if total := t.framesReceived + t.framesDropped; total > 0 {
+		snapshot.PacketLossRate = float64(t.framesDropped) / float64(total)
+	}
+	return snapshot
+}
+
+// CalculateRMS 计算16位小端PCM的RMS能量 sqrt(sum(sample^2)/N)，末尾不足一个采样的字节忽略
+func CalculateRMS(pcm []byte) float64 {
+	n := len(pcm) / 2
+	if n == 0 {
+		return 0
+	}
+	var sum float64
+	for i := 0; i < n; i++ {
+		sample := float64(int16(binary.LittleEndian.Uint16(pcm[i*2:])))
+		sum += sample * sample
+	}
+	return math.Sqrt(sum / float64(n))
+}
+
+// audioQualityCollector 采集所有活跃会话的音频质量指标，导出为按 session_id 区分的 Prometheus 仪表
+type audioQualityCollector struct {
+	trackers sync.Map // *AudioQualityTracker -> struct{}
+
+	confidence *prometheus.Desc
+	rms        *prometheus.Desc
+	lossRate   *prometheus.Desc
+	latency    *prometheus.Desc
+}
+
+var defaultAudioQualityCollector = newAudioQualityCollector()
+
+func newAudioQualityCollector() *audioQualityCollector {
+	labels := []string{"session_id"}
+	return &audioQualityCollector{
+		confidence: prometheus.NewDesc("session_asr_confidence", "会话平均ASR置信度", labels, nil),
+		rms:        prometheus.NewDesc("session_audio_rms", "会话接收PCM帧的平均RMS能量", labels, nil),
+		lossRate:   prometheus.NewDesc("session_audio_packet_loss_rate", "会话音频帧丢弃比例", labels, nil),
+		latency:    prometheus.NewDesc("session_end_to_end_latency_ms", "首个音频帧到首个TTS音频发送的耗时(毫秒)", labels, nil),
+	}
+}
+
+// RegisterAudioQualityMetrics 将会话音频质量指标注册到 Prometheus
+func RegisterAudioQualityMetrics(registerer prometheus.Registerer) error {
+	return registerer.Register(defaultAudioQualityCollector)
+}
+
+func (c *audioQualityCollector) add(t *AudioQualityTracker) {
+	if t != nil {
+		c.trackers.Store(t, struct{}{})
+	}
+}
+
+func (c *audioQualityCollector) remove(t *AudioQualityTracker) {
+	if t != nil {
+		c.trackers.Delete(t)
+	}
+}
+
+func (c *audioQualityCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.confidence
+	ch <- c.rms
+	ch <- c.lossRate
+	ch <- c.latency
+}
+
+// Collect 客户端指定的 session_id 可能重复，重复时只导出其中一个会话
+func (c *audioQualityCollector) Collect(ch chan<- prometheus.Metric) {
+	seen := make(map[string]struct{})
+	c.trackers.Range(func(key, _ interface{}) bool {
+		s := key.(*AudioQualityTracker).Snapshot()
+		if _, ok := seen[s.SessionID]; ok {
+			return true
+		}
+		seen[s.SessionID] = struct{}{}
+		ch <- prometheus.MustNewConstMetric(c.confidence, prometheus.GaugeValue, s.ASRConfidence, s.SessionID)
+		ch <- prometheus.MustNewConstMetric(c.rms, prometheus.GaugeValue, s.AvgRMS, s.SessionID)
+		ch <- prometheus.MustNewConstMetric(c.lossRate, prometheus.GaugeValue, s.PacketLossRate, s.SessionID)
+		ch <- prometheus.MustNewConstMetric(c.latency, prometheus.GaugeValue, float64(s.EndToEndLatencyMs), s.SessionID)
+		return true
+	})
+}
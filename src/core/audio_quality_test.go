@@ -0,0 +1,80 @@
+package core
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+
+	"angrymiao-ai-server/src/core/utils"
+)
+
+// sinePCM 生成16位小端PCM正弦波
+func sinePCM(amplitude float64, frequency, sampleRate, samples int) []byte {
+	pcm := make([]byte, samples*2)
+	for i := 0; i < samples; i++ {
+		v := amplitude * math.Sin(2*math.Pi*float64(frequency)*float64(i)/float64(sampleRate))
+		binary.LittleEndian.PutUint16(pcm[i*2:], uint16(int16(math.Round(v))))
+	}
+	return pcm
+}
+
+func TestCalculateRMSSineWave(t *testing.T) {
+	// 整数个周期的正弦波 RMS = 振幅/√2
+	pcm := sinePCM(10000, 1000, 16000, 16000)
+	want := 10000 / math.Sqrt2
+	if got := CalculateRMS(pcm); math.Abs(got-want) > 0.5 {
+		t.Errorf("RMS = %.2f, 期望 %.2f", got, want)
+	}
+
+	// 满幅方波与静音
+	square := make([]byte, 4)
+	binary.LittleEndian.PutUint16(square[0:], uint16(int16(math.MaxInt16)))
+	binary.LittleEndian.PutUint16(square[2:], uint16(0x8001)) // -32767
+	if got := CalculateRMS(square); math.Abs(got-math.MaxInt16) > 1e-9 {
+		t.Errorf("方波 RMS = %.2f, 期望 %d", got, math.MaxInt16)
+	}
+	if got := CalculateRMS(make([]byte, 640)); got != 0 {
+		t.Errorf("静音 RMS = %.2f, 期望 0", got)
+	}
+	// 末尾不足一个采样的字节被忽略
+	if got := CalculateRMS(append(square, 0x7f)); math.Abs(got-math.MaxInt16) > 1e-9 {
+		t.Errorf("奇数长度 RMS = %.2f", got)
+	}
+}
+
+func TestAudioQualityTrackerSnapshot(t *testing.T) {
+	logger, err := utils.NewLogger(&utils.LogCfg{LogLevel: "ERROR", LogDir: t.TempDir(), LogFile: "test.log"})
+	if err != nil {
+		t.Fatalf("创建日志失败: %v", err)
+	}
+	h := &ConnectionHandler{
+		logger:           logger,
+		clientAudioQueue: make(chan clientAudioFrame, 2),
+		audioQuality:     NewAudioQualityTracker("s1"),
+	}
+
+	// 队列容量为2，第3、4帧被丢弃
+	frame := sinePCM(1000, 1000, 16000, 320)
+	for i := 0; i < 4; i++ {
+		h.enqueueClientAudio(frame, time.Now().UnixNano())
+	}
+	h.audioQuality.RecordPCM(frame)
+	h.audioQuality.RecordConfidence(0.8)
+	h.audioQuality.RecordConfidence(0.6)
+	h.audioQuality.RecordTTSAudioSent()
+
+	s := h.AudioQuality()
+	if s.FramesReceived != 2 || s.FramesDropped != 2 || s.PacketLossRate != 0.5 {
+		t.Errorf("丢包统计不符合预期: %+v", s)
+	}
+	if math.Abs(s.ASRConfidence-0.7) > 1e-9 || s.ConfidenceSamples != 2 {
+		t.Errorf("置信度统计不符合预期: %+v", s)
+	}
+	if math.Abs(s.AvgRMS-1000/math.Sqrt2) > 1 || s.AvgRMS != s.LastRMS {
+		t.Errorf("RMS统计不符合预期: %+v", s)
+	}
+	if s.EndToEndLatencyMs <= 0 {
+		t.Errorf("首帧后发送TTS音频应记录端到端延迟: %+v", s)
+	}
+}
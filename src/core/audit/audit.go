@@ -0,0 +1,152 @@
+package audit
+
+import (
+	"sync"
+	"time"
+
+	"angrymiao-ai-server/src/core/utils"
+	"angrymiao-ai-server/src/models"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// BufferSize 待写入审计日志的缓冲容量，攒满后立即写库
+	BufferSize = 100
+	// FlushInterval 定时写库的间隔
+	FlushInterval = 5 * time.Second
+)
+
+// AuditLogEntry 一次工具调用的审计记录
+type AuditLogEntry struct {
+	SessionID    string
+	UserID       uint
+	DeviceID     string
+	Timestamp    time.Time
+	FunctionName string
+	Arguments    string
+	Result       string
+	LatencyMs    int64
+	Success      bool
+}
+
+// Logger 异步批量写入工具调用审计日志
+type Logger struct {
+	db       *gorm.DB
+	logger   *utils.Logger
+	entries  chan AuditLogEntry
+	interval time.Duration
+
+	stopOnce sync.Once
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// NewLogger 创建审计日志写入器并启动后台写库协程
+func NewLogger(db *gorm.DB, logger *utils.Logger) *Logger {
+	return newLogger(db, logger, FlushInterval)
+}
+
+func newLogger(db *gorm.DB, logger *utils.Logger, interval time.Duration) *Logger {
+	l := &Logger{
+		db:       db,
+		logger:   logger,
+		entries:  make(chan AuditLogEntry, BufferSize),
+		interval: interval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go l.run()
+	return l
+}
+
+// Record 提交一条审计记录，不阻塞调用方；缓冲已满时丢弃并记录警告
+func (l *Logger) Record(entry AuditLogEntry) {
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now()
+	}
+	select {
+	case l.entries <- entry:
+	default:
+		l.logger.Warn("审计日志缓冲已满，丢弃记录: %s", entry.FunctionName)
+	}
+}
+
+// Close 停止后台协程，写入剩余的记录
+func (l *Logger) Close() {
+	l.stopOnce.Do(func() { close(l.stop) })
+	<-l.done
+}
+
+func (l *Logger) run() {
+	defer close(l.done)
+	ticker := time.NewTicker(l.interval)
+	defer ticker.Stop()
+
+	batch := make([]models.FunctionCallAuditLog, 0, BufferSize)
+	for {
+		select {
+		case entry := <-l.entries:
+			batch = append(batch, toModel(entry))
+			if len(batch) >= BufferSize {
+				batch = l.flush(batch)
+			}
+		case <-ticker.C:
+			batch = l.flush(batch)
+		case <-l.stop:
+			for {
+				select {
+				case entry := <-l.entries:
+					batch = append(batch, toModel(entry))
+				default:
+					l.flush(batch)
+					return
+				}
+			}
+		}
+	}
+}
+
+// flush 批量写库，返回清空后的批次
+func (l *Logger) flush(batch []models.FunctionCallAuditLog) []models.FunctionCallAuditLog {
+	if len(batch) == 0 {
+		return batch
+	}
+	if err := l.db.CreateInBatches(batch, BufferSize).Error; err != nil {
+		l.logger.Error("写入审计日志失败, 丢弃%d条记录: %v", len(batch), err)
+	}
+	return batch[:0]
+}
+
+func toModel(entry AuditLogEntry) models.FunctionCallAuditLog {
+	return models.FunctionCallAuditLog{
+		SessionID:    entry.SessionID,
+		UserID:       entry.UserID,
+		DeviceID:     entry.DeviceID,
+		FunctionName: entry.FunctionName,
+		Arguments:    entry.Arguments,
+		Result:       entry.Result,
+		LatencyMs:    entry.LatencyMs,
+		Success:      entry.Success,
+		CreatedAt:    entry.Timestamp,
+	}
+}
+
+var (
+	mu            sync.RWMutex
+	defaultLogger *Logger
+)
+
+// SetDefault 设置全局审计日志写入器，nil 表示不记录
+func SetDefault(l *Logger) {
+	mu.Lock()
+	defer mu.Unlock()
+	defaultLogger = l
+}
+
+// Get 获取全局审计日志写入器，未开启审计时返回nil
+func Get() *Logger {
+	mu.RLock()
+	defer mu.RUnlock()
+	return defaultLogger
+}
@@ -0,0 +1,132 @@
+package audit
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"angrymiao-ai-server/src/core/utils"
+	"angrymiao-ai-server/src/models"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "audit.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("打开数据库失败: %v", err)
+	}
+	if err := db.AutoMigrate(&models.FunctionCallAuditLog{}); err != nil {
+		t.Fatalf("迁移数据库失败: %v", err)
+	}
+	return db
+}
+
+func newTestLogger(t *testing.T) *utils.Logger {
+	t.Helper()
+	logger, err := utils.NewLogger(&utils.LogCfg{LogLevel: "ERROR", LogDir: t.TempDir(), LogFile: "test.log"})
+	if err != nil {
+		t.Fatalf("创建日志失败: %v", err)
+	}
+	return logger
+}
+
+func countLogs(db *gorm.DB) int64 {
+	var n int64
+	db.Model(&models.FunctionCallAuditLog{}).Count(&n)
+	return n
+}
+
+func waitForCount(t *testing.T, db *gorm.DB, want int64, within time.Duration) {
+	t.Helper()
+	deadline := time.Now().Add(within)
+	for time.Now().Before(deadline) {
+		if countLogs(db) == want {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("%v 内审计日志数 = %d, 期望 %d", within, countLogs(db), want)
+}
+
+func TestLoggerFlushesOnInterval(t *testing.T) {
+	db := newTestDB(t)
+	l := newLogger(db, newTestLogger(t), 100*time.Millisecond)
+	defer l.Close()
+
+	l.Record(AuditLogEntry{SessionID: "s1", UserID: 7, FunctionName: "get_weather", Arguments: `{"city":"深圳"}`, Result: "晴", LatencyMs: 12, Success: true})
+	if n := countLogs(db); n != 0 {
+		t.Fatalf("提交后应异步写库, 立即查询到 %d 条", n)
+	}
+	waitForCount(t, db, 1, time.Second)
+
+	var got models.FunctionCallAuditLog
+	db.First(&got)
+	if got.FunctionName != "get_weather" || got.UserID != 7 || got.Arguments != `{"city":"深圳"}` || !got.Success || got.CreatedAt.IsZero() {
+		t.Errorf("审计记录 = %+v", got)
+	}
+}
+
+func TestLoggerFlushesWhenBufferFull(t *testing.T) {
+	db := newTestDB(t)
+	l := newLogger(db, newTestLogger(t), time.Hour)
+	defer l.Close()
+
+	for i := 0; i < BufferSize; i++ {
+		l.Record(AuditLogEntry{FunctionName: fmt.Sprintf("f%d", i)})
+	}
+	waitForCount(t, db, BufferSize, time.Second)
+}
+
+func TestLoggerCloseFlushesRemaining(t *testing.T) {
+	db := newTestDB(t)
+	l := newLogger(db, newTestLogger(t), time.Hour)
+	l.Record(AuditLogEntry{FunctionName: "play_music"})
+	l.Close()
+	if n := countLogs(db); n != 1 {
+		t.Errorf("关闭后审计日志数 = %d, 期望 1", n)
+	}
+}
+
+func TestQueryFilters(t *testing.T) {
+	db := newTestDB(t)
+	base := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+	rows := []models.FunctionCallAuditLog{
+		{UserID: 1, FunctionName: "get_weather", CreatedAt: base},
+		{UserID: 1, FunctionName: "play_music", CreatedAt: base.Add(time.Hour)},
+		{UserID: 2, FunctionName: "get_weather", CreatedAt: base.Add(2 * time.Hour)},
+		{UserID: 1, FunctionName: "get_weather", CreatedAt: base.Add(3 * time.Hour)},
+	}
+	if err := db.Create(&rows).Error; err != nil {
+		t.Fatalf("插入数据失败: %v", err)
+	}
+
+	cases := []struct {
+		name   string
+		filter QueryFilter
+		want   []uint // 按时间倒序的ID
+		total  int64
+	}{
+		{"全部", QueryFilter{}, []uint{4, 3, 2, 1}, 4},
+		{"函数名", QueryFilter{FunctionName: "get_weather"}, []uint{4, 3, 1}, 3},
+		{"用户与函数名", QueryFilter{FunctionName: "get_weather", UserID: 1}, []uint{4, 1}, 2},
+		{"时间范围", QueryFilter{From: base.Add(30 * time.Minute), To: base.Add(2 * time.Hour)}, []uint{3, 2}, 2},
+		{"分页", QueryFilter{Page: 2, Limit: 3}, []uint{1}, 4},
+	}
+	for _, tc := range cases {
+		logs, total, err := Query(db, tc.filter)
+		if err != nil {
+			t.Fatalf("%s: 查询失败: %v", tc.name, err)
+		}
+		var ids []uint
+		for _, l := range logs {
+			ids = append(ids, l.ID)
+		}
+		if total != tc.total || fmt.Sprint(ids) != fmt.Sprint(tc.want) {
+			t.Errorf("%s: ids=%v total=%d, 期望 ids=%v total=%d", tc.name, ids, total, tc.want, tc.total)
+		}
+	}
+}
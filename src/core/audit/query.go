@@ -0,0 +1,65 @@
+package audit
+
+import (
+	"time"
+
+	"angrymiao-ai-server/src/models"
+
+	"gorm.io/gorm"
+)
+
+const (
+	defaultQueryLimit = 20
+	maxQueryLimit     = 100
+)
+
+// QueryFilter 审计日志查询条件，零值表示不限制
+type QueryFilter struct {
+	FunctionName string
+	UserID       uint
+	From         time.Time
+	To           time.Time
+	Page         int // 从1开始
+	Limit        int
+}
+
+// Query 按条件分页查询审计日志，按调用时间倒序，返回本页记录与总数
+func Query(db *gorm.DB, filter QueryFilter) ([]models.FunctionCallAuditLog, int64, error) {
+	q := db.Model(&models.FunctionCallAuditLog{})
+	if filter.FunctionName != "" {
+		q = q.Where("function_name = ?", filter.FunctionName)
+	}
+	if filter.UserID != 0 {
+		q = q.Where("user_id = ?", filter.UserID)
+	}
+	if !filter.From.IsZero() {
+		q = q.Where("created_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		q = q.Where("created_at <= ?", filter.To)
+	}
+
+	var total int64
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultQueryLimit
+	}
+	if limit > maxQueryLimit {
+		limit = maxQueryLimit
+	}
+	page := filter.Page
+	if page <= 0 {
+		page = 1
+	}
+
+	var logs []models.FunctionCallAuditLog
+	err := q.Order("created_at DESC, id DESC").Offset((page - 1) * limit).Limit(limit).Find(&logs).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return logs, total, nil
+}
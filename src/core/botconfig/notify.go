@@ -0,0 +1,245 @@
+package botconfig
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// UpdateNotifier 用户Bot配置变更通知
+type UpdateNotifier interface {
+	// NotifyUpdate 通知用户的在线连接重新加载Bot配置
+	NotifyUpdate(ctx context.Context, userID string) error
+	// SubscribeUpdates 订阅用户Bot配置变更，返回通知通道与取消订阅函数
+	SubscribeUpdates(ctx context.Context, userID string) (<-chan struct{}, func(), error)
+}
+
+// ContextNotifier 用户对话上下文变更通知，如导入外部对话记录后通知在线连接
+type ContextNotifier interface {
+	// NotifyContextUpdate 通知用户的在线连接对话上下文已变更
+	NotifyContextUpdate(ctx context.Context, userID string) error
+	// SubscribeContextUpdates 订阅用户对话上下文变更，返回通知通道与取消订阅函数
+	SubscribeContextUpdates(ctx context.Context, userID string) (<-chan struct{}, func(), error)
+}
+
+// ContextClearNotifier 用户对话历史被删除的通知，在线连接收到后清空内存中的对话上下文
+type ContextClearNotifier interface {
+	// NotifyContextCleared 通知用户的在线连接对话历史已删除
+	NotifyContextCleared(ctx context.Context, userID string) error
+	// SubscribeContextCleared 订阅用户对话历史删除，返回通知通道与取消订阅函数
+	SubscribeContextCleared(ctx context.Context, userID string) (<-chan struct{}, func(), error)
+}
+
+var (
+	notifierMu      sync.RWMutex
+	defaultNotifier UpdateNotifier
+)
+
+// SetUpdateNotifier 设置默认的配置变更通知器
+func SetUpdateNotifier(n UpdateNotifier) {
+	notifierMu.Lock()
+	defer notifierMu.Unlock()
+	defaultNotifier = n
+}
+
+// GetUpdateNotifier 获取默认的配置变更通知器，未配置时返回nil
+func GetUpdateNotifier() UpdateNotifier {
+	notifierMu.RLock()
+	defer notifierMu.RUnlock()
+	return defaultNotifier
+}
+
+// userConfigUpdatePrefix 用户Bot配置变更频道前缀
+const userConfigUpdatePrefix = "user_config_update:"
+
+// 同一频道上的消息内容区分变更类型，未知内容按配置变更处理
+const (
+	updateEventReload         = "reload"
+	updateEventContextUpdated = "context_updated"
+	updateEventContextCleared = "context_cleared"
+)
+
+// UserConfigUpdateChannel 用户Bot配置变更的发布订阅频道
+func UserConfigUpdateChannel(userID string) string {
+	return userConfigUpdatePrefix + userID
+}
+
+// psubscribeTimeout 建立进程级模式订阅的最长时间
+const psubscribeTimeout = 10 * time.Second
+
+// RedisUpdateNotifier 基于Redis发布订阅的配置变更通知器
+// 整个进程共用一个 PSUBSCRIBE user_config_update:* 连接，收到消息后按变更类型分发给对应用户的在线连接
+type RedisUpdateNotifier struct {
+	client *redis.Client
+
+	mu           sync.Mutex
+	pubsub       *redis.PubSub
+	subscribing  chan struct{} // 正在建立模式订阅，本次尝试结束后关闭
+	subscribeErr error         // 最近一次建立模式订阅的错误
+	closed       bool
+	subscribers  map[subscriberKey]map[chan struct{}]struct{} // 用户与变更类型 -> 订阅通道
+}
+
+// subscriberKey 订阅通道的分组键
+type subscriberKey struct {
+	userID string
+	event  string
+}
+
+// NewRedisUpdateNotifier 创建基于Redis的配置变更通知器
+func NewRedisUpdateNotifier(client *redis.Client) *RedisUpdateNotifier {
+	return &RedisUpdateNotifier{
+		client:      client,
+		subscribers: make(map[subscriberKey]map[chan struct{}]struct{}),
+	}
+}
+
+func (n *RedisUpdateNotifier) NotifyUpdate(ctx context.Context, userID string) error {
+	return n.client.Publish(ctx, UserConfigUpdateChannel(userID), updateEventReload).Err()
+}
+
+// SubscribeUpdates 在进程级订阅上登记用户的通知通道，首次调用时建立 PSUBSCRIBE 连接
+// ctx 仅限制等待订阅建立的时间，超时返回错误，不影响后台继续建立订阅
+func (n *RedisUpdateNotifier) SubscribeUpdates(ctx context.Context, userID string) (<-chan struct{}, func(), error) {
+	return n.subscribe(ctx, subscriberKey{userID: userID, event: updateEventReload})
+}
+
+func (n *RedisUpdateNotifier) NotifyContextUpdate(ctx context.Context, userID string) error {
+	return n.client.Publish(ctx, UserConfigUpdateChannel(userID), updateEventContextUpdated).Err()
+}
+
+// SubscribeContextUpdates 与 SubscribeUpdates 共用进程级订阅，只接收对话上下文变更
+func (n *RedisUpdateNotifier) SubscribeContextUpdates(ctx context.Context, userID string) (<-chan struct{}, func(), error) {
+	return n.subscribe(ctx, subscriberKey{userID: userID, event: updateEventContextUpdated})
+}
+
+func (n *RedisUpdateNotifier) NotifyContextCleared(ctx context.Context, userID string) error {
+	return n.client.Publish(ctx, UserConfigUpdateChannel(userID), updateEventContextCleared).Err()
+}
+
+// SubscribeContextCleared 与 SubscribeUpdates 共用进程级订阅，只接收对话历史删除
+func (n *RedisUpdateNotifier) SubscribeContextCleared(ctx context.Context, userID string) (<-chan struct{}, func(), error) {
+	return n.subscribe(ctx, subscriberKey{userID: userID, event: updateEventContextCleared})
+}
+
+// subscribe 在进程级订阅上登记通知通道
+func (n *RedisUpdateNotifier) subscribe(ctx context.Context, key subscriberKey) (<-chan struct{}, func(), error) {
+	if err := n.ensureSubscribed(ctx); err != nil {
+		return nil, nil, err
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	updates := make(chan struct{}, 1)
+	if n.subscribers[key] == nil {
+		n.subscribers[key] = make(map[chan struct{}]struct{})
+	}
+	n.subscribers[key][updates] = struct{}{}
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			n.mu.Lock()
+			defer n.mu.Unlock()
+			delete(n.subscribers[key], updates)
+			if len(n.subscribers[key]) == 0 {
+				delete(n.subscribers, key)
+			}
+		})
+	}
+	return updates, cancel, nil
+}
+
+// ensureSubscribed 等待进程级模式订阅建立，未建立时在后台发起
+func (n *RedisUpdateNotifier) ensureSubscribed(ctx context.Context) error {
+	n.mu.Lock()
+	if n.closed {
+		n.mu.Unlock()
+		return fmt.Errorf("配置变更通知器已关闭")
+	}
+	if n.pubsub != nil {
+		n.mu.Unlock()
+		return nil
+	}
+	if n.subscribing == nil {
+		n.subscribing = make(chan struct{})
+		go n.psubscribe(n.subscribing)
+	}
+	subscribing := n.subscribing
+	n.mu.Unlock()
+
+	select {
+	case <-subscribing:
+	case <-ctx.Done():
+		return fmt.Errorf("订阅配置变更超时: %v", ctx.Err())
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.pubsub == nil {
+		return fmt.Errorf("订阅配置变更失败: %v", n.subscribeErr)
+	}
+	return nil
+}
+
+// psubscribe 建立 PSUBSCRIBE 连接并启动分发协程，失败后下次订阅时重试
+func (n *RedisUpdateNotifier) psubscribe(done chan struct{}) {
+	defer close(done)
+	ctx, cancel := context.WithTimeout(context.Background(), psubscribeTimeout)
+	defer cancel()
+
+	pubsub := n.client.PSubscribe(ctx, userConfigUpdatePrefix+"*")
+	// 等待订阅确认，确保后续发布的消息不会丢失
+	_, err := pubsub.Receive(ctx)
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.subscribing = nil
+	if err == nil && n.closed {
+		err = fmt.Errorf("配置变更通知器已关闭")
+	}
+	n.subscribeErr = err
+	if err != nil {
+		pubsub.Close()
+		return
+	}
+	n.pubsub = pubsub
+	go n.dispatch(pubsub.Channel())
+}
+
+// dispatch 将收到的变更消息分发给对应用户、对应变更类型的所有订阅通道
+func (n *RedisUpdateNotifier) dispatch(messages <-chan *redis.Message) {
+	for msg := range messages {
+		key := subscriberKey{userID: strings.TrimPrefix(msg.Channel, userConfigUpdatePrefix), event: updateEventReload}
+		switch msg.Payload {
+		case updateEventContextUpdated, updateEventContextCleared:
+			key.event = msg.Payload
+		}
+		n.mu.Lock()
+		for updates := range n.subscribers[key] {
+			// 合并未处理的通知，只需要重新加载一次
+			select {
+			case updates <- struct{}{}:
+			default:
+			}
+		}
+		n.mu.Unlock()
+	}
+}
+
+// Close 关闭进程级订阅连接
+func (n *RedisUpdateNotifier) Close() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.closed = true
+	if n.pubsub == nil {
+		return nil
+	}
+	err := n.pubsub.Close()
+	n.pubsub = nil
+	return err
+}
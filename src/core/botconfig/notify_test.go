@@ -0,0 +1,255 @@
+package botconfig
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// fakeRedis 实现 PSUBSCRIBE/PUBLISH 的最小RESP服务，用于测试发布订阅
+type fakeRedis struct {
+	listener net.Listener
+
+	mu           sync.Mutex
+	psubscribers map[net.Conn]string // 连接 -> 订阅模式
+	psubscribes  int
+}
+
+func newFakeRedis(t *testing.T) *fakeRedis {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("启动模拟Redis失败: %v", err)
+	}
+	s := &fakeRedis{listener: listener, psubscribers: make(map[net.Conn]string)}
+	go s.serve()
+	t.Cleanup(func() { listener.Close() })
+	return s
+}
+
+func (s *fakeRedis) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *fakeRedis) handle(conn net.Conn) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.psubscribers, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+	reader := bufio.NewReader(conn)
+	for {
+		args, err := readCommand(reader)
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		switch strings.ToUpper(args[0]) {
+		case "HELLO":
+			io.WriteString(conn, "-ERR unknown command 'HELLO'\r\n")
+		case "PSUBSCRIBE":
+			s.psubscribes++
+			s.psubscribers[conn] = args[1]
+			fmt.Fprintf(conn, "*3\r\n%s%s:1\r\n", bulk("psubscribe"), bulk(args[1]))
+		case "PUBLISH":
+			receivers := 0
+			for sub, pattern := range s.psubscribers {
+				if strings.HasPrefix(args[1], strings.TrimSuffix(pattern, "*")) {
+					fmt.Fprintf(sub, "*4\r\n%s%s%s%s", bulk("pmessage"), bulk(pattern), bulk(args[1]), bulk(args[2]))
+					receivers++
+				}
+			}
+			fmt.Fprintf(conn, ":%d\r\n", receivers)
+		case "PING":
+			io.WriteString(conn, "+PONG\r\n")
+		default:
+			io.WriteString(conn, "+OK\r\n")
+		}
+		s.mu.Unlock()
+	}
+}
+
+func (s *fakeRedis) psubscribeCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.psubscribes
+}
+
+func bulk(v string) string {
+	return fmt.Sprintf("$%d\r\n%s\r\n", len(v), v)
+}
+
+// readCommand 读取一条RESP数组格式的命令
+func readCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		if _, err := reader.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		arg, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, strings.TrimSuffix(arg, "\r\n"))
+	}
+	return args, nil
+}
+
+func expectUpdate(t *testing.T, updates <-chan struct{}, expected bool, name string) {
+	t.Helper()
+	select {
+	case <-updates:
+		if !expected {
+			t.Errorf("%s 不应收到通知", name)
+		}
+	case <-time.After(200 * time.Millisecond):
+		if expected {
+			t.Errorf("%s 未收到通知", name)
+		}
+	}
+}
+
+func TestRedisUpdateNotifierFanOut(t *testing.T) {
+	server := newFakeRedis(t)
+	client := redis.NewClient(&redis.Options{Addr: server.listener.Addr().String(), DisableIdentity: true})
+	defer client.Close()
+	notifier := NewRedisUpdateNotifier(client)
+	defer notifier.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	first, cancelFirst, err := notifier.SubscribeUpdates(ctx, "42")
+	if err != nil {
+		t.Fatalf("订阅失败: %v", err)
+	}
+	second, _, err := notifier.SubscribeUpdates(ctx, "42")
+	if err != nil {
+		t.Fatalf("订阅失败: %v", err)
+	}
+	other, _, err := notifier.SubscribeUpdates(ctx, "7")
+	if err != nil {
+		t.Fatalf("订阅失败: %v", err)
+	}
+
+	// 多个会话共用一个模式订阅连接
+	if n := server.psubscribeCount(); n != 1 {
+		t.Errorf("PSUBSCRIBE 次数 = %d, 期望 1", n)
+	}
+
+	if err := notifier.NotifyUpdate(ctx, "42"); err != nil {
+		t.Fatalf("发布通知失败: %v", err)
+	}
+	expectUpdate(t, first, true, "用户42的第一个连接")
+	expectUpdate(t, second, true, "用户42的第二个连接")
+	expectUpdate(t, other, false, "用户7的连接")
+
+	// 取消订阅后不再收到通知
+	cancelFirst()
+	if err := notifier.NotifyUpdate(ctx, "42"); err != nil {
+		t.Fatalf("发布通知失败: %v", err)
+	}
+	expectUpdate(t, second, true, "用户42的第二个连接")
+	expectUpdate(t, first, false, "已取消订阅的连接")
+}
+
+func TestRedisUpdateNotifierSubscribeTimeout(t *testing.T) {
+	// 只接受连接不回复的服务端，订阅确认应在ctx超时后返回错误
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("启动模拟Redis失败: %v", err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	client := redis.NewClient(&redis.Options{Addr: listener.Addr().String(), DisableIdentity: true})
+	defer client.Close()
+	notifier := NewRedisUpdateNotifier(client)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, _, err := notifier.SubscribeUpdates(ctx, "42"); err == nil {
+		t.Fatalf("服务端无响应时订阅应失败")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("订阅等待时间 %v 超出ctx超时", elapsed)
+	}
+}
+
+func TestRedisUpdateNotifierContextUpdates(t *testing.T) {
+	server := newFakeRedis(t)
+	client := redis.NewClient(&redis.Options{Addr: server.listener.Addr().String(), DisableIdentity: true})
+	defer client.Close()
+	notifier := NewRedisUpdateNotifier(client)
+	defer notifier.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	reloads, _, err := notifier.SubscribeUpdates(ctx, "42")
+	if err != nil {
+		t.Fatalf("订阅失败: %v", err)
+	}
+	contexts, _, err := notifier.SubscribeContextUpdates(ctx, "42")
+	if err != nil {
+		t.Fatalf("订阅失败: %v", err)
+	}
+
+	// 两种变更共用同一个模式订阅，按消息内容分发
+	if n := server.psubscribeCount(); n != 1 {
+		t.Errorf("PSUBSCRIBE 次数 = %d, 期望 1", n)
+	}
+
+	if err := notifier.NotifyContextUpdate(ctx, "42"); err != nil {
+		t.Fatalf("发布通知失败: %v", err)
+	}
+	expectUpdate(t, contexts, true, "上下文变更订阅")
+	expectUpdate(t, reloads, false, "配置变更订阅")
+
+	if err := notifier.NotifyUpdate(ctx, "42"); err != nil {
+		t.Fatalf("发布通知失败: %v", err)
+	}
+	expectUpdate(t, reloads, true, "配置变更订阅")
+	expectUpdate(t, contexts, false, "上下文变更订阅")
+
+	cleared, _, err := notifier.SubscribeContextCleared(ctx, "42")
+	if err != nil {
+		t.Fatalf("订阅失败: %v", err)
+	}
+	if err := notifier.NotifyContextCleared(ctx, "42"); err != nil {
+		t.Fatalf("发布通知失败: %v", err)
+	}
+	expectUpdate(t, cleared, true, "对话历史删除订阅")
+	expectUpdate(t, contexts, false, "上下文变更订阅")
+	expectUpdate(t, reloads, false, "配置变更订阅")
+}
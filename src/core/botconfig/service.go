@@ -17,6 +17,10 @@ type Service interface {
 	GetUserConfigs(ctx context.Context, userID string) ([]*types.BotConfig, error)
 	GetActiveConfigs(ctx context.Context, userID string) ([]*types.BotConfig, error)
 	GetBotFriendConfig(ctx context.Context, userID uint, botConfigID uint) (*types.BotConfig, error)
+	// GetDevicePersonaID 获取设备绑定的主人格Bot配置ID，未绑定时返回0
+	GetDevicePersonaID(ctx context.Context, userID string, deviceID string) (uint, error)
+	// GetDeviceGroup 获取设备所属的设备组，不属于任何设备组时返回nil
+	GetDeviceGroup(ctx context.Context, userID string, deviceID string) (*models.DeviceGroup, error)
 }
 
 // DefaultService 默认Bot配置服务实现
@@ -132,29 +136,77 @@ func (s *DefaultService) assembleBotConfigs(ctx context.Context, userID string,
 		}
 
 		configs = append(configs, &types.BotConfig{
-			ID:           botConfig.ID,
-			UserID:       userID,
-			LLMType:      modelConfig.LLMType,
-			ModelName:    modelConfig.ModelName,
-			APIKey:       friend.AppKey,
-			BaseURL:      modelConfig.BaseURL,
-			MaxTokens:    botConfig.MaxTokens,
-			Temperature:  botConfig.Temperature,
-			FunctionName: botConfig.FunctionName,
-			Description:  botConfig.Description,
-			Parameters:   botConfig.Parameters,
-			MCPServerURL: botConfig.MCPServerURL,
-			IsActive:     friend.IsActive,
-			Priority:     friend.Priority,
-			BotHash:      botConfig.BotHash,
-			CreatedAt:    botConfig.CreatedAt,
-			UpdatedAt:    botConfig.UpdatedAt,
+			ID:             botConfig.ID,
+			UserID:         userID,
+			LLMType:        modelConfig.LLMType,
+			ModelName:      modelConfig.ModelName,
+			APIKey:         friend.AppKey,
+			TTSVoice:       friend.TTSVoice,
+			BaseURL:        modelConfig.BaseURL,
+			BotType:        botConfig.BotType,
+			MaxTokens:      botConfig.MaxTokens,
+			Temperature:    botConfig.Temperature,
+			FunctionName:   botConfig.FunctionName,
+			Description:    botConfig.Description,
+			Parameters:     botConfig.Parameters,
+			MCPServerURL:   botConfig.MCPServerURL,
+			ResponseSchema: botConfig.ResponseSchema,
+			ResponsePrefix: botConfig.ResponsePrefix,
+			WelcomeMessage: botConfig.WelcomeMessage,
+			IsActive:       friend.IsActive,
+			Priority:       friend.Priority,
+			BotHash:        botConfig.BotHash,
+			CreatedAt:      botConfig.CreatedAt,
+			UpdatedAt:      botConfig.UpdatedAt,
 		})
 	}
 
 	return configs, nil
 }
 
+// GetDevicePersonaID 获取设备绑定的主人格Bot配置ID，未绑定时返回0
+func (s *DefaultService) GetDevicePersonaID(ctx context.Context, userID string, deviceID string) (uint, error) {
+	uid, err := strconv.ParseUint(userID, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("无效的用户ID")
+	}
+
+	var binding models.DeviceBotBinding
+	err = s.db.WithContext(ctx).
+		Where("user_id = ? AND device_id = ?", uint(uid), deviceID).
+		First(&binding).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return 0, nil
+		}
+		s.logger.Error("查询设备主人格绑定失败: %v", err)
+		return 0, err
+	}
+	return binding.BotConfigID, nil
+}
+
+// GetDeviceGroup 获取设备所属的设备组，不属于任何设备组时返回nil
+func (s *DefaultService) GetDeviceGroup(ctx context.Context, userID string, deviceID string) (*models.DeviceGroup, error) {
+	uid, err := strconv.ParseUint(userID, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("无效的用户ID")
+	}
+
+	var group models.DeviceGroup
+	err = s.db.WithContext(ctx).
+		Joins("JOIN device_group_members ON device_group_members.group_id = device_groups.id").
+		Where("device_groups.user_id = ? AND device_group_members.device_id = ?", uint(uid), deviceID).
+		First(&group).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		s.logger.Error("查询设备所属设备组失败: %v", err)
+		return nil, err
+	}
+	return &group, nil
+}
+
 // GetBotFriendConfig 获取用户指定的Bot好友配置
 func (s *DefaultService) GetBotFriendConfig(ctx context.Context, userID uint, botConfigID uint) (*types.BotConfig, error) {
 	// 查询用户的Bot好友关系
@@ -186,22 +238,27 @@ func (s *DefaultService) GetBotFriendConfig(ctx context.Context, userID uint, bo
 	}
 
 	return &types.BotConfig{
-		ID:           botConfig.ID,
-		UserID:       fmt.Sprintf("%d", userID),
-		LLMType:      modelConfig.LLMType,
-		ModelName:    modelConfig.ModelName,
-		APIKey:       friend.AppKey, // 使用用户好友表中的AppKey
-		BaseURL:      modelConfig.BaseURL,
-		MaxTokens:    botConfig.MaxTokens,
-		Temperature:  botConfig.Temperature,
-		FunctionName: botConfig.FunctionName,
-		Description:  botConfig.Description,
-		Parameters:   botConfig.Parameters,
-		MCPServerURL: botConfig.MCPServerURL,
-		IsActive:     friend.IsActive,
-		Priority:     friend.Priority,
-		BotHash:      botConfig.BotHash,
-		CreatedAt:    botConfig.CreatedAt,
-		UpdatedAt:    botConfig.UpdatedAt,
+		ID:             botConfig.ID,
+		UserID:         fmt.Sprintf("%d", userID),
+		LLMType:        modelConfig.LLMType,
+		ModelName:      modelConfig.ModelName,
+		APIKey:         friend.AppKey, // 使用用户好友表中的AppKey
+		TTSVoice:       friend.TTSVoice,
+		BaseURL:        modelConfig.BaseURL,
+		BotType:        botConfig.BotType,
+		MaxTokens:      botConfig.MaxTokens,
+		Temperature:    botConfig.Temperature,
+		FunctionName:   botConfig.FunctionName,
+		Description:    botConfig.Description,
+		Parameters:     botConfig.Parameters,
+		MCPServerURL:   botConfig.MCPServerURL,
+		ResponseSchema: botConfig.ResponseSchema,
+		ResponsePrefix: botConfig.ResponsePrefix,
+		WelcomeMessage: botConfig.WelcomeMessage,
+		IsActive:       friend.IsActive,
+		Priority:       friend.Priority,
+		BotHash:        botConfig.BotHash,
+		CreatedAt:      botConfig.CreatedAt,
+		UpdatedAt:      botConfig.UpdatedAt,
 	}, nil
 }
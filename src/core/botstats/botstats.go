@@ -0,0 +1,123 @@
+package botstats
+
+import (
+	"sync"
+	"time"
+
+	"angrymiao-ai-server/src/core/utils"
+	"angrymiao-ai-server/src/models"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// BufferSize 待写入调用记录的缓冲容量，攒满后立即写库
+	BufferSize = 100
+	// FlushInterval 定时写库的间隔
+	FlushInterval = 5 * time.Second
+)
+
+// Recorder 异步批量写入Bot调用记录
+type Recorder struct {
+	db       *gorm.DB
+	logger   *utils.Logger
+	stats    chan models.BotUsageStat
+	interval time.Duration
+	stopOnce sync.Once
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// NewRecorder 创建Bot调用记录写入器并启动后台写库协程
+func NewRecorder(db *gorm.DB, logger *utils.Logger) *Recorder {
+	return newRecorder(db, logger, FlushInterval)
+}
+
+func newRecorder(db *gorm.DB, logger *utils.Logger, interval time.Duration) *Recorder {
+	r := &Recorder{
+		db:       db,
+		logger:   logger,
+		stats:    make(chan models.BotUsageStat, BufferSize),
+		interval: interval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go r.run()
+	return r
+}
+
+// Record 提交一条调用记录，不阻塞调用方；缓冲已满时丢弃并记录警告
+func (r *Recorder) Record(stat models.BotUsageStat) {
+	if stat.InvokedAt.IsZero() {
+		stat.InvokedAt = time.Now()
+	}
+	select {
+	case r.stats <- stat:
+	default:
+		r.logger.Warn("Bot调用记录缓冲已满，丢弃记录: bot %d", stat.BotConfigID)
+	}
+}
+
+// Close 停止后台协程，写入剩余的记录
+func (r *Recorder) Close() {
+	r.stopOnce.Do(func() { close(r.stop) })
+	<-r.done
+}
+
+func (r *Recorder) run() {
+	defer close(r.done)
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	batch := make([]models.BotUsageStat, 0, BufferSize)
+	for {
+		select {
+		case stat := <-r.stats:
+			batch = append(batch, stat)
+			if len(batch) >= BufferSize {
+				batch = r.flush(batch)
+			}
+		case <-ticker.C:
+			batch = r.flush(batch)
+		case <-r.stop:
+			for {
+				select {
+				case stat := <-r.stats:
+					batch = append(batch, stat)
+				default:
+					r.flush(batch)
+					return
+				}
+			}
+		}
+	}
+}
+
+// flush 批量写库，返回清空后的批次
+func (r *Recorder) flush(batch []models.BotUsageStat) []models.BotUsageStat {
+	if len(batch) == 0 {
+		return batch
+	}
+	if err := r.db.CreateInBatches(batch, BufferSize).Error; err != nil {
+		r.logger.Error("写入Bot调用记录失败, 丢弃%d条记录: %v", len(batch), err)
+	}
+	return batch[:0]
+}
+
+var (
+	mu              sync.RWMutex
+	defaultRecorder *Recorder
+)
+
+// SetDefault 设置全局Bot调用记录写入器，nil 表示不记录
+func SetDefault(r *Recorder) {
+	mu.Lock()
+	defer mu.Unlock()
+	defaultRecorder = r
+}
+
+// Get 获取全局Bot调用记录写入器，未初始化时返回nil
+func Get() *Recorder {
+	mu.RLock()
+	defer mu.RUnlock()
+	return defaultRecorder
+}
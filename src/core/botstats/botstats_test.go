@@ -0,0 +1,111 @@
+package botstats
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"angrymiao-ai-server/src/core/utils"
+	"angrymiao-ai-server/src/models"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "botstats.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("打开数据库失败: %v", err)
+	}
+	if err := db.AutoMigrate(&models.BotUsageStat{}); err != nil {
+		t.Fatalf("迁移数据库失败: %v", err)
+	}
+	return db
+}
+
+func newTestLogger(t *testing.T) *utils.Logger {
+	t.Helper()
+	logger, err := utils.NewLogger(&utils.LogCfg{LogLevel: "ERROR", LogDir: t.TempDir(), LogFile: "test.log"})
+	if err != nil {
+		t.Fatalf("创建日志失败: %v", err)
+	}
+	return logger
+}
+
+func countStats(db *gorm.DB) int64 {
+	var n int64
+	db.Model(&models.BotUsageStat{}).Count(&n)
+	return n
+}
+
+func TestRecorderFlushesAsync(t *testing.T) {
+	db := newTestDB(t)
+	r := newRecorder(db, newTestLogger(t), 20*time.Millisecond)
+	defer r.Close()
+
+	r.Record(models.BotUsageStat{BotConfigID: 1, UserID: 2, SessionID: "s1", LatencyMs: 120, TokensUsed: 30, Success: true})
+	deadline := time.Now().Add(time.Second)
+	for countStats(db) != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("调用记录未按周期写库")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	var stat models.BotUsageStat
+	db.First(&stat)
+	if stat.SessionID != "s1" || stat.InvokedAt.IsZero() || !stat.Success {
+		t.Errorf("写入的记录 = %+v", stat)
+	}
+
+	// 关闭时写入剩余记录
+	r2 := newRecorder(db, newTestLogger(t), time.Hour)
+	r2.Record(models.BotUsageStat{BotConfigID: 1})
+	r2.Close()
+	if n := countStats(db); n != 2 {
+		t.Errorf("关闭后记录数 = %d, 期望 2", n)
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	db := newTestDB(t)
+	now := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	stats := []models.BotUsageStat{
+		{BotConfigID: 1, InvokedAt: now.Add(-time.Hour), LatencyMs: 100, Success: true},
+		{BotConfigID: 1, InvokedAt: now.Add(-2 * time.Hour), LatencyMs: 300, Success: false},
+		{BotConfigID: 1, InvokedAt: now.AddDate(0, 0, -3), LatencyMs: 200, Success: true},
+		// 超出7天只计入总数与每日序列
+		{BotConfigID: 1, InvokedAt: now.AddDate(0, 0, -10), LatencyMs: 5000, Success: true},
+		// 超出30天不计入每日序列
+		{BotConfigID: 1, InvokedAt: now.AddDate(0, 0, -40), LatencyMs: 5000, Success: true},
+		{BotConfigID: 2, InvokedAt: now, LatencyMs: 1, Success: true},
+	}
+	if err := db.Create(&stats).Error; err != nil {
+		t.Fatalf("写入测试数据失败: %v", err)
+	}
+
+	summary, err := Summarize(db, 1, now)
+	if err != nil {
+		t.Fatalf("汇总失败: %v", err)
+	}
+	if summary.TotalInvocations != 5 || summary.SuccessRate != 0.8 || summary.AvgLatencyMs != 200 {
+		t.Errorf("汇总结果 = %+v", summary)
+	}
+	if len(summary.Daily) != DailyDays || summary.Daily[0].Date != "2026-09-15" || summary.Daily[DailyDays-1].Date != "2026-10-14" {
+		t.Fatalf("每日序列范围错误: %v ... %v", summary.Daily[0], summary.Daily[len(summary.Daily)-1])
+	}
+	counts := map[string]int64{}
+	var total int64
+	for _, d := range summary.Daily {
+		counts[d.Date] = d.Count
+		total += d.Count
+	}
+	if counts["2026-10-14"] != 2 || counts["2026-10-11"] != 1 || counts["2026-10-04"] != 1 || total != 4 {
+		t.Errorf("每日调用次数 = %v", counts)
+	}
+
+	// 没有调用记录
+	if summary, err := Summarize(db, 3, now); err != nil || summary.TotalInvocations != 0 || summary.SuccessRate != 0 || len(summary.Daily) != DailyDays {
+		t.Errorf("无记录时汇总 = %+v, %v", summary, err)
+	}
+}
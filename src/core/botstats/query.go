@@ -0,0 +1,90 @@
+package botstats
+
+import (
+	"time"
+
+	"angrymiao-ai-server/src/models"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// LatencyWindow 平均耗时的统计区间
+	LatencyWindow = 7 * 24 * time.Hour
+	// DailyDays 每日调用次数序列包含的天数（含当天）
+	DailyDays = 30
+)
+
+// DailyCount 某一天（UTC）的调用次数
+type DailyCount struct {
+	Date  string `json:"date"` // YYYY-MM-DD
+	Count int64  `json:"count"`
+}
+
+// Summary Bot调用统计
+type Summary struct {
+	BotConfigID      uint         `json:"bot_config_id"`
+	TotalInvocations int64        `json:"total_invocations"`
+	SuccessRate      float64      `json:"success_rate"`   // 0~1，没有调用记录时为0
+	AvgLatencyMs     float64      `json:"avg_latency_ms"` // 最近7天的平均耗时
+	Daily            []DailyCount `json:"daily"`          // 最近30天每天的调用次数，按日期升序，没有调用的日期为0
+}
+
+// Summarize 汇总Bot的调用统计，now 用于确定统计区间
+func Summarize(db *gorm.DB, botConfigID uint, now time.Time) (Summary, error) {
+	summary := Summary{BotConfigID: botConfigID}
+	query := func() *gorm.DB {
+		return db.Model(&models.BotUsageStat{}).Where("bot_config_id = ?", botConfigID)
+	}
+
+	var totals struct {
+		Total     int64
+		Succeeded int64
+	}
+	err := query().Select("COUNT(*) AS total, COALESCE(SUM(CASE WHEN success THEN 1 ELSE 0 END), 0) AS succeeded").
+		Scan(&totals).Error
+	if err != nil {
+		return summary, err
+	}
+	summary.TotalInvocations = totals.Total
+	if totals.Total > 0 {
+		summary.SuccessRate = float64(totals.Succeeded) / float64(totals.Total)
+	}
+
+	var latency struct{ Avg float64 }
+	err = query().Select("COALESCE(AVG(latency_ms), 0) AS avg").
+		Where("invoked_at >= ?", now.Add(-LatencyWindow)).Scan(&latency).Error
+	if err != nil {
+		return summary, err
+	}
+	summary.AvgLatencyMs = latency.Avg
+
+	today := now.UTC().Truncate(24 * time.Hour)
+	start := today.AddDate(0, 0, -(DailyDays - 1))
+	var rows []DailyCount
+	day := dayExpr(db)
+	err = query().Select(day+" AS date, COUNT(*) AS count").
+		Where("invoked_at >= ?", start).Group(day).Scan(&rows).Error
+	if err != nil {
+		return summary, err
+	}
+	counts := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		counts[row.Date] = row.Count
+	}
+	summary.Daily = make([]DailyCount, 0, DailyDays)
+	for d := start; !d.After(today); d = d.AddDate(0, 0, 1) {
+		date := d.Format("2006-01-02")
+		summary.Daily = append(summary.Daily, DailyCount{Date: date, Count: counts[date]})
+	}
+	return summary, nil
+}
+
+// dayExpr 按UTC日期分组的SQL表达式
+func dayExpr(db *gorm.DB) string {
+	if db.Dialector.Name() == "postgres" {
+		return "TO_CHAR(invoked_at AT TIME ZONE 'UTC', 'YYYY-MM-DD')"
+	}
+	// SQLite 的 DATE() 会将带时区的时间转换为UTC
+	return "DATE(invoked_at)"
+}
@@ -0,0 +1,305 @@
+package chat
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// MemoryBackend 对话存储后端，由 NewDialogueManagerWithBackend 使用
+type MemoryBackend interface {
+	io.Closer
+
+	// Load 加载全部对话历史，按时间正序
+	Load() ([]Message, error)
+
+	// Append 追加保存消息
+	Append(messages []Message) error
+
+	// Clear 清空对话历史
+	Clear() error
+
+	// Query 获取最近 limit 条消息（limit<=0 表示全部）
+	Query(limit int) ([]Message, error)
+}
+
+// lastMessages 返回最近 limit 条消息，limit<=0 时返回全部
+func lastMessages(messages []Message, limit int) []Message {
+	if limit > 0 && len(messages) > limit {
+		messages = messages[len(messages)-limit:]
+	}
+	return append([]Message{}, messages...)
+}
+
+// memoryToBackend 将 MemoryInterface 包装为存储后端，nil 时为仅内存
+func memoryToBackend(memory MemoryInterface) MemoryBackend {
+	switch m := memory.(type) {
+	case nil:
+		return NewNullBackend()
+	case *PostgresMemory:
+		return NewPostgresBackend(m)
+	case *RedisMemory:
+		return NewRedisBackend(m)
+	default:
+		return &memoryInterfaceBackend{memory: m}
+	}
+}
+
+// memoryInterfaceBackend 适配其他 MemoryInterface 实现，SaveMemory 按追加语义调用
+type memoryInterfaceBackend struct {
+	memory MemoryInterface
+}
+
+func (b *memoryInterfaceBackend) Load() ([]Message, error) {
+	return loadMemoryJSON(b.memory)
+}
+
+func (b *memoryInterfaceBackend) Append(messages []Message) error {
+	return b.memory.SaveMemory(messages)
+}
+
+func (b *memoryInterfaceBackend) Clear() error {
+	return b.memory.ClearMemory()
+}
+
+func (b *memoryInterfaceBackend) Query(limit int) ([]Message, error) {
+	return b.memory.QueryMessagesLimit(limit)
+}
+
+func (b *memoryInterfaceBackend) Close() error {
+	return nil
+}
+
+// loadMemoryJSON 读取 QueryMemory 返回的JSON对话
+func loadMemoryJSON(memory MemoryInterface) ([]Message, error) {
+	jsonStr, err := memory.QueryMemory("")
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(jsonStr) == "" {
+		return []Message{}, nil
+	}
+	var messages []Message
+	if err := json.Unmarshal([]byte(jsonStr), &messages); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// NullBackend 仅保存在内存中的存储后端，连接关闭后丢弃
+type NullBackend struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+// NewNullBackend 创建仅内存的存储后端
+func NewNullBackend() *NullBackend {
+	return &NullBackend{}
+}
+
+func (b *NullBackend) Load() ([]Message, error) {
+	return b.Query(0)
+}
+
+func (b *NullBackend) Append(messages []Message) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.messages = append(b.messages, messages...)
+	return nil
+}
+
+func (b *NullBackend) Clear() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.messages = nil
+	return nil
+}
+
+func (b *NullBackend) Query(limit int) ([]Message, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return lastMessages(b.messages, limit), nil
+}
+
+func (b *NullBackend) Close() error {
+	return nil
+}
+
+// PostgresBackend 基于 PostgresMemory 的存储后端，支持按重要性保留消息
+type PostgresBackend struct {
+	memory *PostgresMemory
+}
+
+// NewPostgresBackend 创建数据库存储后端
+func NewPostgresBackend(memory *PostgresMemory) *PostgresBackend {
+	return &PostgresBackend{memory: memory}
+}
+
+func (b *PostgresBackend) Load() ([]Message, error) {
+	return loadMemoryJSON(b.memory)
+}
+
+func (b *PostgresBackend) Append(messages []Message) error {
+	return b.memory.SaveMemory(messages)
+}
+
+func (b *PostgresBackend) Clear() error {
+	return b.memory.ClearMemory()
+}
+
+func (b *PostgresBackend) Query(limit int) ([]Message, error) {
+	return b.memory.QueryMessagesLimit(limit)
+}
+
+// LastExchangeIDs 实现 ImportanceMemory
+func (b *PostgresBackend) LastExchangeIDs() []uint {
+	return b.memory.LastExchangeIDs()
+}
+
+// SetImportance 实现 ImportanceMemory
+func (b *PostgresBackend) SetImportance(ids []uint, score float32) error {
+	return b.memory.SetImportance(ids, score)
+}
+
+// Close 数据库连接全局共享，不在这里关闭
+func (b *PostgresBackend) Close() error {
+	return nil
+}
+
+// RedisBackend 基于 RedisMemory 的存储后端
+// RedisMemory 以整段JSON保存对话，追加时先读出已有消息再整体写回
+type RedisBackend struct {
+	mu     sync.Mutex
+	memory *RedisMemory
+}
+
+// NewRedisBackend 创建Redis存储后端
+func NewRedisBackend(memory *RedisMemory) *RedisBackend {
+	return &RedisBackend{memory: memory}
+}
+
+func (b *RedisBackend) Load() ([]Message, error) {
+	return b.memory.QueryMessagesLimit(0)
+}
+
+func (b *RedisBackend) Append(messages []Message) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	existing, err := b.memory.QueryMessagesLimit(0)
+	if err != nil {
+		return err
+	}
+	return b.memory.SaveMemory(append(existing, messages...))
+}
+
+func (b *RedisBackend) Clear() error {
+	return b.memory.ClearMemory()
+}
+
+func (b *RedisBackend) Query(limit int) ([]Message, error) {
+	return b.memory.QueryMessagesLimit(limit)
+}
+
+// Close Redis客户端全局共享，不在这里关闭
+func (b *RedisBackend) Close() error {
+	return nil
+}
+
+// FileBackend 以JSONL文件保存对话，每行一条消息
+type FileBackend struct {
+	mu   sync.Mutex
+	path string
+	file *os.File // 追加写入的文件句柄，首次写入时打开
+}
+
+// NewFileBackend 创建文件存储后端，目录不存在时自动创建
+func NewFileBackend(path string) (*FileBackend, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("创建对话存储目录失败: %v", err)
+	}
+	return &FileBackend{path: path}, nil
+}
+
+func (b *FileBackend) Load() ([]Message, error) {
+	return b.Query(0)
+}
+
+func (b *FileBackend) Append(messages []Message) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.file == nil {
+		file, err := os.OpenFile(b.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return err
+		}
+		b.file = file
+	}
+	var buf strings.Builder
+	for _, msg := range messages {
+		line, err := json.Marshal(msg)
+		if err != nil {
+			return err
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	_, err := b.file.WriteString(buf.String())
+	return err
+}
+
+func (b *FileBackend) Clear() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := os.Truncate(b.path, 0); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (b *FileBackend) Query(limit int) ([]Message, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	file, err := os.Open(b.path)
+	if os.IsNotExist(err) {
+		return []Message{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	messages := make([]Message, 0)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var msg Message
+		if err := json.Unmarshal([]byte(line), &msg); err != nil {
+			continue // 跳过写入中断产生的残缺行
+		}
+		messages = append(messages, msg)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return lastMessages(messages, limit), nil
+}
+
+func (b *FileBackend) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.file == nil {
+		return nil
+	}
+	err := b.file.Close()
+	b.file = nil
+	return err
+}
@@ -0,0 +1,142 @@
+package chat
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"angrymiao-ai-server/src/configs"
+	"angrymiao-ai-server/src/configs/cache"
+	"angrymiao-ai-server/src/configs/database"
+	"angrymiao-ai-server/src/models"
+
+	"github.com/alicebob/miniredis/v2"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestMemoryBackendsAppendQueryRoundTrip(t *testing.T) {
+	backends := map[string]func(t *testing.T) MemoryBackend{
+		"null": func(t *testing.T) MemoryBackend { return NewNullBackend() },
+		"file": func(t *testing.T) MemoryBackend {
+			backend, err := NewFileBackend(filepath.Join(t.TempDir(), "dialogues", "42.jsonl"))
+			if err != nil {
+				t.Fatalf("创建文件存储失败: %v", err)
+			}
+			return backend
+		},
+		"postgres": func(t *testing.T) MemoryBackend {
+			db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "dialogue.db")), &gorm.Config{})
+			if err != nil {
+				t.Fatalf("打开数据库失败: %v", err)
+			}
+			if err := db.AutoMigrate(&models.DialogueMessage{}); err != nil {
+				t.Fatalf("迁移失败: %v", err)
+			}
+			original := database.DB
+			database.DB = db
+			t.Cleanup(func() { database.DB = original })
+			return NewPostgresBackend(NewPostgresMemory("42"))
+		},
+		"redis": func(t *testing.T) MemoryBackend {
+			server := miniredis.RunT(t)
+			cfg := configs.RedisConfig{Addr: server.Addr()}
+			cache.InitRedis(cfg)
+			t.Cleanup(func() { cache.CloseRedis() })
+			mem, err := NewRedisMemory(cfg, newTestDialogueManager(t).logger, "42")
+			if err != nil {
+				t.Fatalf("创建Redis记忆失败: %v", err)
+			}
+			return NewRedisBackend(mem)
+		},
+	}
+
+	var messages []Message
+	for i := 0; i < 5; i++ {
+		role := "user"
+		if i%2 == 1 {
+			role = "assistant"
+		}
+		messages = append(messages, Message{Role: role, Content: fmt.Sprintf("第%d条消息", i+1)})
+	}
+
+	for name, newBackend := range backends {
+		t.Run(name, func(t *testing.T) {
+			backend := newBackend(t)
+			defer backend.Close()
+
+			// 分两次追加，验证追加不会覆盖已有消息
+			if err := backend.Append(messages[:2]); err != nil {
+				t.Fatalf("Append 失败: %v", err)
+			}
+			for _, msg := range messages[2:] {
+				if err := backend.Append([]Message{msg}); err != nil {
+					t.Fatalf("Append 失败: %v", err)
+				}
+			}
+
+			assertContents := func(label string, got []Message, want []Message) {
+				t.Helper()
+				if len(got) != len(want) {
+					t.Fatalf("%s 返回 %d 条, 期望 %d 条: %+v", label, len(got), len(want), got)
+				}
+				for i := range want {
+					if got[i].Role != want[i].Role || got[i].Content != want[i].Content {
+						t.Errorf("%s[%d] = %s/%s, 期望 %s/%s", label, i, got[i].Role, got[i].Content, want[i].Role, want[i].Content)
+					}
+				}
+			}
+			all, err := backend.Query(0)
+			if err != nil {
+				t.Fatalf("Query 失败: %v", err)
+			}
+			assertContents("Query(0)", all, messages)
+			recent, err := backend.Query(3)
+			if err != nil {
+				t.Fatalf("Query 失败: %v", err)
+			}
+			assertContents("Query(3)", recent, messages[2:])
+			loaded, err := backend.Load()
+			if err != nil {
+				t.Fatalf("Load 失败: %v", err)
+			}
+			assertContents("Load", loaded, messages)
+
+			if err := backend.Clear(); err != nil {
+				t.Fatalf("Clear 失败: %v", err)
+			}
+			if cleared, _ := backend.Query(0); len(cleared) != 0 {
+				t.Errorf("Clear 后仍有 %d 条消息", len(cleared))
+			}
+		})
+	}
+}
+
+func TestDialogueManagerWithBackendPersistsAndRestores(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "42.jsonl")
+	backend, err := NewFileBackend(path)
+	if err != nil {
+		t.Fatalf("创建文件存储失败: %v", err)
+	}
+	logger := newTestDialogueManager(t).logger
+	dm := NewDialogueManagerWithBackend(logger, backend)
+	dm.SetSystemMessage("你是助手")
+	dm.Put(Message{Role: "user", Content: "你好"})
+	dm.Put(Message{Role: "tool", Content: "工具结果"}) // 工具消息不落库
+	dm.Put(Message{Role: "assistant", Content: "你好呀"})
+	if err := dm.Close(); err != nil {
+		t.Fatalf("关闭失败: %v", err)
+	}
+
+	// 重新连接后从文件恢复
+	backend, _ = NewFileBackend(path)
+	restored := NewDialogueManagerWithBackend(logger, backend)
+	restored.SetSystemMessage("你是助手")
+	if err := restored.LoadFromStorage(); err != nil {
+		t.Fatalf("加载失败: %v", err)
+	}
+	got := restored.GetLLMDialogue()
+	if len(got) != 3 || got[0].Role != "system" || got[1].Content != "你好" || got[2].Content != "你好呀" {
+		t.Errorf("恢复的对话 = %+v", got)
+	}
+}
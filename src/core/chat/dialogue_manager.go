@@ -3,6 +3,8 @@ package chat
 import (
 	"encoding/json"
 	"strings"
+	"sync"
+	"sync/atomic"
 
 	"angrymiao-ai-server/src/core/types"
 	"angrymiao-ai-server/src/core/utils"
@@ -12,17 +14,32 @@ type Message = types.Message
 
 // DialogueManager 管理对话上下文和历史
 type DialogueManager struct {
-	logger   *utils.Logger
-	dialogue []Message
-	memory   MemoryInterface
+	logger    *utils.Logger
+	mu        sync.Mutex // 保护 dialogue，后台生成摘要时与对话协程并发访问
+	dialogue  []Message
+	backend   MemoryBackend
+	maxTokens int // 对话上下文的估算token上限，<=0 表示不限制
+
+	// 对话历史摘要，见 SummarizeOlderMessages
+	summarizer  Summarizer
+	summarizing atomic.Bool
+	generation  uint64 // 对话历史被整体替换时递增，摘要完成时据此判断快照是否失效
 }
 
-// NewDialogueManager 创建对话管理器实例
+// NewDialogueManager 创建对话管理器实例，memory 为nil时仅保存在内存
 func NewDialogueManager(logger *utils.Logger, memory MemoryInterface) *DialogueManager {
+	return NewDialogueManagerWithBackend(logger, memoryToBackend(memory))
+}
+
+// NewDialogueManagerWithBackend 使用指定存储后端创建对话管理器，backend 为nil时使用 NullBackend
+func NewDialogueManagerWithBackend(logger *utils.Logger, backend MemoryBackend) *DialogueManager {
+	if backend == nil {
+		backend = NewNullBackend()
+	}
 	return &DialogueManager{
 		logger:   logger,
 		dialogue: make([]Message, 0),
-		memory:   memory,
+		backend:  backend,
 	}
 }
 
@@ -30,6 +47,8 @@ func (dm *DialogueManager) SetSystemMessage(systemMessage string) {
 	if systemMessage == "" {
 		return
 	}
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
 
 	// 如果对话中已经有系统消息，则更新其内容
 	if len(dm.dialogue) > 0 && dm.dialogue[0].Role == "system" {
@@ -43,7 +62,23 @@ func (dm *DialogueManager) SetSystemMessage(systemMessage string) {
 	}, dm.dialogue...)
 }
 
+// SystemMessage 返回当前系统消息内容，没有系统消息时返回空字符串
+func (dm *DialogueManager) SystemMessage() string {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+	if len(dm.dialogue) > 0 && dm.dialogue[0].Role == "system" {
+		return dm.dialogue[0].Content
+	}
+	return ""
+}
+
 func (dm *DialogueManager) RemoveSecondMessageForToolType() {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+	dm.removeSecondToolMessage()
+}
+
+func (dm *DialogueManager) removeSecondToolMessage() {
 	// 如果第二条的类型是"role": "tool",则移除这条
 	if len(dm.dialogue) < 2 || dm.dialogue[1].Role != "tool" {
 		return
@@ -53,14 +88,17 @@ func (dm *DialogueManager) RemoveSecondMessageForToolType() {
 
 // 保留最近的几条对话消息
 func (dm *DialogueManager) KeepRecentMessages(maxMessages int) {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
 	if maxMessages <= 0 || len(dm.dialogue) <= maxMessages {
 		return
 	}
+	dm.generation++
 	// 保留system消息和最近的 maxMessages 条消息
 	if len(dm.dialogue) > 0 && dm.dialogue[0].Role == "system" {
 		// 保留system消息
 		dm.dialogue = append(dm.dialogue[:1], dm.dialogue[len(dm.dialogue)-maxMessages:]...)
-		dm.RemoveSecondMessageForToolType()
+		dm.removeSecondToolMessage()
 		return
 	}
 	// 如果没有system消息，直接保留最近的 maxMessages 条消息
@@ -72,6 +110,8 @@ func (dm *DialogueManager) KeepRecentMessages(maxMessages int) {
 // GetRecentMessages 获取最近的对话消息
 // 如果 maxMessages <= 0，则返回全部对话消息
 func (dm *DialogueManager) GetRecentMessages(maxMessages int) []Message {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
 	if maxMessages <= 0 || len(dm.dialogue) <= maxMessages {
 		return dm.dialogue
 	}
@@ -85,28 +125,45 @@ func (dm *DialogueManager) GetRecentMessages(maxMessages int) []Message {
 
 // Put 添加新消息到对话
 func (dm *DialogueManager) Put(message Message) {
+	dm.mu.Lock()
 	dm.dialogue = append(dm.dialogue, message)
+	dm.mu.Unlock()
 
 	// 仅在非system且内容非空时持久化追加保存
-	if dm.memory != nil {
-		if (message.Role == "user" || message.Role == "assistant") && strings.TrimSpace(message.Content) != "" {
-			if err := dm.memory.SaveMemory([]Message{message}); err != nil {
-				dm.logger.Warn("保存对话失败: %v", err)
-			}
+	if (message.Role == "user" || message.Role == "assistant") && strings.TrimSpace(message.Content) != "" {
+		if err := dm.backend.Append([]Message{message}); err != nil {
+			dm.logger.Warn("保存对话失败: %v", err)
 		}
 	}
 }
 
 func (dm *DialogueManager) GetLastTwoMessages() []Message {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
 	if len(dm.dialogue) < 2 {
 		return nil
 	}
 	return dm.dialogue[len(dm.dialogue)-2:]
 }
 
-// GetLLMDialogue 获取完整对话历史
+// SetMaxTokens 设置对话上下文的估算token上限，n<=0 表示不限制
+// 仅影响发给LLM的消息列表，内存与存储中的对话历史保持不变
+func (dm *DialogueManager) SetMaxTokens(n int) {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+	dm.maxTokens = n
+}
+
+// GetLLMDialogue 获取完整对话历史，设置了 SetMaxTokens 时按token上限裁剪
 func (dm *DialogueManager) GetLLMDialogue() []Message {
-	return dm.dialogue
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+	return dm.fitTokenBudget(dm.dialogue)
+}
+
+// GetEstimatedTokenCount 估算 GetLLMDialogue 返回的消息列表的token数
+func (dm *DialogueManager) GetEstimatedTokenCount() int {
+	return estimateDialogueTokens(dm.GetLLMDialogue())
 }
 
 // LoadFromJSON 用JSON字符串覆盖加载对话（保留现有system消息）
@@ -118,6 +175,10 @@ func (dm *DialogueManager) LoadFromJSON(jsonStr string) error {
 	if err := json.Unmarshal([]byte(jsonStr), &msgs); err != nil {
 		return err
 	}
+	msgs = withoutSummaries(msgs)
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+	dm.generation++
 	// 保留已有的 system 消息（若存在且位于首位）
 	if len(dm.dialogue) > 0 && dm.dialogue[0].Role == "system" {
 		dm.dialogue = append([]Message{dm.dialogue[0]}, msgs...)
@@ -129,29 +190,39 @@ func (dm *DialogueManager) LoadFromJSON(jsonStr string) error {
 
 // LoadFromStorage 从持久化存储加载对话到内存（覆盖当前非system内容）
 func (dm *DialogueManager) LoadFromStorage() error {
-	if dm.memory == nil {
-		return nil
-	}
-	jsonStr, err := dm.memory.QueryMemory("")
+	msgs, err := dm.backend.Load()
 	if err != nil {
 		return err
 	}
-	return dm.LoadFromJSON(jsonStr)
+	// 摘要记录只用于展示，不发送给LLM
+	msgs = withoutSummaries(msgs)
+	if len(msgs) == 0 {
+		return nil
+	}
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+	dm.generation++
+	// 保留已有的 system 消息（若存在且位于首位）
+	if len(dm.dialogue) > 0 && dm.dialogue[0].Role == "system" {
+		dm.dialogue = append([]Message{dm.dialogue[0]}, msgs...)
+	} else {
+		dm.dialogue = msgs
+	}
+	return nil
 }
 
 // GetStoredDialogue 直接从存储读取并返回对话（不改变内存状态）
 // limit<=0 表示获取全部；>0 表示仅返回存储中的最近 limit 条消息
 func (dm *DialogueManager) GetStoredDialogue(limit int) ([]Message, error) {
-	if dm.memory == nil {
-		return dm.GetLLMDialogue(), nil
-	}
-	msgs, err := dm.memory.QueryMessagesLimit(limit)
+	msgs, err := dm.backend.Query(limit)
 	if err != nil {
 		return nil, err
 	}
 	if len(msgs) == 0 {
 		return dm.GetLLMDialogue(), nil
 	}
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
 	// 若当前内存首条是 system，则在返回结果前加上
 	if len(dm.dialogue) > 0 && dm.dialogue[0].Role == "system" {
 		return append([]Message{dm.dialogue[0]}, msgs...), nil
@@ -169,30 +240,54 @@ func (dm *DialogueManager) GetLLMDialogueWithMemory(memoryStr string) []Message
 		Role:    "system",
 		Content: memoryStr,
 	}
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
 
 	dialogue := make([]Message, 0, len(dm.dialogue)+1)
 	dialogue = append(dialogue, memoryMsg)
 	dialogue = append(dialogue, dm.dialogue...)
 
-	return dialogue
+	return dm.fitTokenBudget(dialogue)
 }
 
 // Clear 清空对话历史
 func (dm *DialogueManager) Clear() {
+	dm.mu.Lock()
 	dm.dialogue = make([]Message, 0)
-	if dm.memory != nil {
-		if err := dm.memory.ClearMemory(); err != nil {
-			dm.logger.Warn("清空记忆失败: %v", err)
-		}
+	dm.generation++
+	dm.mu.Unlock()
+	if err := dm.backend.Clear(); err != nil {
+		dm.logger.Warn("清空记忆失败: %v", err)
 	}
 }
 
+// ResetContext 清空内存中的对话历史并保留系统消息，不修改存储后端（用于存储中的记录已在外部删除时）
+func (dm *DialogueManager) ResetContext() {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+	dm.generation++
+	if len(dm.dialogue) > 0 && dm.dialogue[0].Role == "system" {
+		dm.dialogue = []Message{dm.dialogue[0]}
+		return
+	}
+	dm.dialogue = make([]Message, 0)
+}
+
+// Close 关闭存储后端，内存中的对话保持不变
+func (dm *DialogueManager) Close() error {
+	return dm.backend.Close()
+}
+
 func (dm *DialogueManager) Length() int {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
 	return len(dm.dialogue)
 }
 
 // ToJSON 将对话历史转换为JSON字符串
 func (dm *DialogueManager) ToJSON(keepSystemPrompt bool) (string, error) {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
 	dialogue := dm.dialogue
 	if !keepSystemPrompt && len(dialogue) > 0 && dialogue[0].Role == "system" {
 		// 如果不保留系统消息，则移除第一条消息
@@ -0,0 +1,91 @@
+package chat
+
+import (
+	"strings"
+	"testing"
+
+	"angrymiao-ai-server/src/core/types"
+	"angrymiao-ai-server/src/core/utils"
+)
+
+func newTestDialogueManager(t *testing.T) *DialogueManager {
+	t.Helper()
+	logger, err := utils.NewLogger(&utils.LogCfg{LogLevel: "ERROR", LogDir: t.TempDir(), LogFile: "test.log"})
+	if err != nil {
+		t.Fatalf("创建日志失败: %v", err)
+	}
+	return NewDialogueManager(logger, nil)
+}
+
+func TestEstimateMessageTokensMixedContent(t *testing.T) {
+	tests := []struct {
+		content string
+		want    int
+	}{
+		{strings.Repeat("你", 7), 2},                          // 7/3.5
+		{strings.Repeat("a", 8), 2},                          // 8/4
+		{strings.Repeat("你", 7) + strings.Repeat("a", 8), 4}, // 2+2
+		{"こんにちは", 2},                                         // 5/3.5 向上取整
+		{"hi 你好", 2},                                         // 3/4 + 2/3.5 向上取整
+	}
+	for _, tt := range tests {
+		if got := estimateMessageTokens(Message{Content: tt.content}); got != tt.want {
+			t.Errorf("estimateMessageTokens(%q) = %d, 期望 %d", tt.content, got, tt.want)
+		}
+	}
+}
+
+func TestGetLLMDialogueDropsOldestMessages(t *testing.T) {
+	dm := newTestDialogueManager(t)
+	dm.SetSystemMessage(strings.Repeat("系", 35))                    // 10 tokens
+	dm.Put(Message{Role: "user", Content: strings.Repeat("a", 40)}) // 10 tokens
+	dm.Put(Message{Role: "assistant", ToolCalls: []types.ToolCall{{Function: types.FunctionCall{Name: "get_time", Arguments: "{}"}}}})
+	dm.Put(Message{Role: "tool", Content: strings.Repeat("b", 40)}) // 10 tokens
+	dm.Put(Message{Role: "assistant", Content: strings.Repeat("好", 35)})
+	dm.Put(Message{Role: "user", Content: "今天天气 how is it"})
+
+	full := dm.GetEstimatedTokenCount()
+	dm.SetMaxTokens(28)
+	got := dm.GetLLMDialogue()
+	var roles []string
+	for _, msg := range got {
+		roles = append(roles, msg.Role)
+	}
+	// 丢弃最早的user消息与工具调用，孤立的tool结果一并丢弃
+	if strings.Join(roles, ",") != "system,assistant,user" {
+		t.Fatalf("裁剪后的角色 = %v, 估算前共 %d tokens", roles, full)
+	}
+	if got[1].Content != strings.Repeat("好", 35) || dm.GetEstimatedTokenCount() > 28 {
+		t.Errorf("裁剪结果 = %+v, 估算 %d tokens", got, dm.GetEstimatedTokenCount())
+	}
+	if dm.Length() != 6 {
+		t.Errorf("裁剪不应修改对话历史, 长度 = %d", dm.Length())
+	}
+
+	dm.SetMaxTokens(0)
+	if len(dm.GetLLMDialogue()) != 6 || dm.GetEstimatedTokenCount() != full {
+		t.Errorf("取消上限后应返回完整对话")
+	}
+}
+
+func TestGetLLMDialogueTruncatesOversizedMessage(t *testing.T) {
+	dm := newTestDialogueManager(t)
+	dm.SetSystemMessage("你是助手")
+	long := strings.Repeat("中文English混合", 100)
+	dm.Put(Message{Role: "user", Content: long})
+	dm.SetMaxTokens(50)
+
+	got := dm.GetLLMDialogue()
+	if len(got) != 2 || !strings.HasSuffix(got[1].Content, truncatedSuffix) {
+		t.Fatalf("超长消息应截断并追加标记: %+v", got)
+	}
+	if !strings.HasPrefix(long, strings.TrimSuffix(got[1].Content, truncatedSuffix)) {
+		t.Errorf("截断应保留消息开头: %q", got[1].Content)
+	}
+	if tokens := dm.GetEstimatedTokenCount(); tokens > 50 || tokens < 40 {
+		t.Errorf("截断后估算 %d tokens, 期望接近上限50", tokens)
+	}
+	if dm.dialogue[1].Content != long {
+		t.Errorf("截断不应修改对话历史")
+	}
+}
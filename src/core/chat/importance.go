@@ -0,0 +1,73 @@
+package chat
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// ImportanceMemory 支持按重要性保留消息的存储，可选实现
+type ImportanceMemory interface {
+	// LastExchangeIDs 最近保存的一条用户消息与一条助手消息的ID
+	LastExchangeIDs() []uint
+	// SetImportance 设置消息的重要性评分
+	SetImportance(ids []uint, score float32) error
+}
+
+// Exchange 一轮用户与助手的对话，IDs 为存储中的消息ID
+type Exchange struct {
+	Text string
+	IDs  []uint
+}
+
+// LastExchange 获取最近一轮用户与助手的对话，存储不支持重要性评分或没有完整一轮时返回false
+func (dm *DialogueManager) LastExchange() (Exchange, bool) {
+	mem, ok := dm.backend.(ImportanceMemory)
+	if !ok {
+		return Exchange{}, false
+	}
+	var user, assistant string
+	dm.mu.Lock()
+	for i := len(dm.dialogue) - 1; i >= 0 && user == ""; i-- {
+		msg := dm.dialogue[i]
+		switch {
+		case msg.Role == "assistant" && assistant == "":
+			assistant = msg.Content
+		case msg.Role == "user" && assistant != "":
+			user = msg.Content
+		}
+	}
+	dm.mu.Unlock()
+	ids := mem.LastExchangeIDs()
+	if user == "" || len(ids) == 0 {
+		return Exchange{}, false
+	}
+	return Exchange{Text: fmt.Sprintf("User: %s\nAssistant: %s", user, assistant), IDs: ids}, true
+}
+
+// SetImportance 保存一轮对话的重要性评分
+func (dm *DialogueManager) SetImportance(exchange Exchange, score float32) error {
+	mem, ok := dm.backend.(ImportanceMemory)
+	if !ok {
+		return nil
+	}
+	return mem.SetImportance(exchange.IDs, score)
+}
+
+var reImportanceScore = regexp.MustCompile(`\d+(?:\.\d+)?`)
+
+// ParseImportanceScore 解析LLM返回的重要性评分，取首个数字并限制在0~1
+func ParseImportanceScore(text string) (float32, error) {
+	match := reImportanceScore.FindString(text)
+	if match == "" {
+		return 0, fmt.Errorf("无法识别的重要性评分: %q", text)
+	}
+	score, err := strconv.ParseFloat(match, 32)
+	if err != nil {
+		return 0, err
+	}
+	if score > 1 {
+		score = 1
+	}
+	return float32(score), nil
+}
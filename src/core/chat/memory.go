@@ -14,3 +14,14 @@ type MemoryInterface interface {
 	// QueryMessagesLimit 直接从存储获取最近 limit 条消息（limit<=0 表示全部）
 	QueryMessagesLimit(limit int) ([]Message, error)
 }
+
+// RoleSummary 会话摘要记录的角色，只用于展示，加载对话历史时不发送给LLM
+const RoleSummary = "summary"
+
+// DialogueNamespace 返回对话存储键，botName 非空时为 "userID:botName"，使Bot好友的对话历史与主会话隔离
+func DialogueNamespace(userID, botName string) string {
+	if botName == "" {
+		return userID
+	}
+	return userID + ":" + botName
+}
@@ -2,11 +2,14 @@ package chat
 
 import (
 	"encoding/json"
+	"sort"
 	"strings"
+	"sync"
 
 	"angrymiao-ai-server/src/configs/database"
 	"angrymiao-ai-server/src/models"
 
+	"gorm.io/datatypes"
 	"gorm.io/gorm"
 )
 
@@ -14,6 +17,10 @@ import (
 type PostgresMemory struct {
 	db     *gorm.DB
 	userID string
+
+	lastMu          sync.Mutex
+	lastUserID      uint // 最近保存的用户消息ID
+	lastAssistantID uint // 最近保存的助手消息ID
 }
 
 // NewPostgresMemory 创建 Postgres 记忆存储
@@ -53,7 +60,8 @@ func (m *PostgresMemory) QueryMemory(_ string) (string, error) {
 
 // QueryMessages 支持分页与排序的查询
 // order: "ASC" 或 "DESC"（其他值按 ASC 处理）
-func (m *PostgresMemory) QueryMessages(order string, page, pageSize int) ([]Message, int64, error) {
+// tag: 非空时只返回带有该标签的消息
+func (m *PostgresMemory) QueryMessages(order string, page, pageSize int, tag string) ([]Message, int64, error) {
 	if m.db == nil {
 		return nil, 0, nil
 	}
@@ -64,11 +72,17 @@ func (m *PostgresMemory) QueryMessages(order string, page, pageSize int) ([]Mess
 		pageSize = 20
 	}
 
+	scoped := func() *gorm.DB {
+		q := m.db.Model(&models.DialogueMessage{}).Where("user_id = ?", m.userID)
+		if tag != "" {
+			q = q.Where(datatypes.JSONArrayQuery("tags").Contains(tag))
+		}
+		return q
+	}
+
 	// 统计总数
 	var total int64
-	if err := m.db.Model(&models.DialogueMessage{}).
-		Where("user_id = ?", m.userID).
-		Count(&total).Error; err != nil {
+	if err := scoped().Count(&total).Error; err != nil {
 		return nil, 0, err
 	}
 
@@ -86,7 +100,7 @@ func (m *PostgresMemory) QueryMessages(order string, page, pageSize int) ([]Mess
 	}
 
 	var rows []models.DialogueMessage
-	if err := m.db.Where("user_id = ?", m.userID).
+	if err := scoped().
 		Order(orderBy).
 		Limit(pageSize).
 		Offset(offset).
@@ -123,6 +137,10 @@ func (m *PostgresMemory) QueryMessages(order string, page, pageSize int) ([]Mess
 			ToolCallID: r.ToolCallID,
 			ToolCalls:  nil,
 			BotID:      r.BotID,
+			ID:         r.ID,
+		}
+		if len(r.Tags) > 0 {
+			json.Unmarshal(r.Tags, &msg.Tags)
 		}
 
 		// 设置 bot 名称
@@ -158,38 +176,69 @@ func (m *PostgresMemory) SaveMemory(dialogue []Message) error {
 			ToolCallID: msg.ToolCallID,
 		})
 	}
-	return m.db.Create(&rows).Error
+	if err := m.db.Create(&rows).Error; err != nil {
+		return err
+	}
+	m.lastMu.Lock()
+	for _, r := range rows {
+		switch r.Role {
+		case "user":
+			m.lastUserID = r.ID
+		case "assistant":
+			m.lastAssistantID = r.ID
+		}
+	}
+	m.lastMu.Unlock()
+	return nil
+}
+
+// LastExchangeIDs 最近保存的一条用户消息与一条助手消息的ID
+func (m *PostgresMemory) LastExchangeIDs() []uint {
+	m.lastMu.Lock()
+	defer m.lastMu.Unlock()
+	ids := make([]uint, 0, 2)
+	for _, id := range []uint{m.lastUserID, m.lastAssistantID} {
+		if id > 0 {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// SetImportance 设置消息的重要性评分
+func (m *PostgresMemory) SetImportance(ids []uint, score float32) error {
+	if m.db == nil || len(ids) == 0 {
+		return nil
+	}
+	return m.db.Model(&models.DialogueMessage{}).
+		Where("user_id = ? AND id IN ?", m.userID, ids).
+		Update("importance_score", score).Error
 }
 
-// ClearMemory 清空用户对话记忆
+// ClearMemory 清空用户对话记忆（物理删除，包括已软删除的记录）
 func (m *PostgresMemory) ClearMemory() error {
 	if m.db == nil {
 		return nil
 	}
-	return m.db.Where("user_id = ?", m.userID).Delete(&models.DialogueMessage{}).Error
+	return m.db.Unscoped().Where("user_id = ?", m.userID).Delete(&models.DialogueMessage{}).Error
 }
 
-// QueryMessagesLimit 直接获取最近 limit 条消息（limit<=0 返回全部）
+// QueryMessagesLimit 获取 limit 条消息（limit<=0 返回全部）
+// limit>0 时返回最近的 limit-limit/2 条与其余消息中重要性评分最高的 limit/2 条，按时间正序排列，
+// 使重要的早期对话在截断后仍保留；已评分的消息不足时用更早的最近消息补足
 func (m *PostgresMemory) QueryMessagesLimit(limit int) ([]Message, error) {
 	if m.db == nil {
 		return nil, nil
 	}
 	var rows []models.DialogueMessage
 	if limit > 0 {
-		// 先按时间倒序拿最近 limit 条
-		if err := m.db.Where("user_id = ?", m.userID).
-			Order("created_at DESC").
-			Limit(limit).
-			Find(&rows).Error; err != nil {
+		var err error
+		if rows, err = m.queryRecentAndImportant(limit); err != nil {
 			return nil, err
 		}
-		// 反转为时间正序
-		for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
-			rows[i], rows[j] = rows[j], rows[i]
-		}
 	} else {
 		// 全量时按时间正序
-		if err := m.db.Where("user_id = ?", m.userID).
+		if err := m.db.Where("user_id = ? AND role <> ?", m.userID, RoleSummary).
 			Order("created_at ASC").
 			Find(&rows).Error; err != nil {
 			return nil, err
@@ -240,3 +289,57 @@ func (m *PostgresMemory) QueryMessagesLimit(limit int) ([]Message, error) {
 	}
 	return messages, nil
 }
+
+// queryRecentAndImportant 查询最近的消息与重要性评分最高的消息，按时间正序返回
+func (m *PostgresMemory) queryRecentAndImportant(limit int) ([]models.DialogueMessage, error) {
+	var recent []models.DialogueMessage
+	if err := m.db.Where("user_id = ? AND role <> ?", m.userID, RoleSummary).
+		Order("created_at DESC, id DESC").
+		Limit(limit).
+		Find(&recent).Error; err != nil {
+		return nil, err
+	}
+	recentCount := limit - limit/2
+	if len(recent) <= recentCount {
+		sortChronologically(recent)
+		return recent, nil
+	}
+
+	selected := recent[:recentCount]
+	excluded := make([]uint, 0, recentCount)
+	for _, r := range selected {
+		excluded = append(excluded, r.ID)
+	}
+	var important []models.DialogueMessage
+	if err := m.db.Where("user_id = ? AND importance_score > 0 AND id NOT IN ?", m.userID, excluded).
+		Order("importance_score DESC, created_at DESC").
+		Limit(limit / 2).
+		Find(&important).Error; err != nil {
+		return nil, err
+	}
+
+	picked := make(map[uint]bool, limit)
+	rows := make([]models.DialogueMessage, 0, limit)
+	for _, group := range [][]models.DialogueMessage{selected, important, recent[recentCount:]} {
+		for _, r := range group {
+			if len(rows) >= limit {
+				break
+			}
+			if !picked[r.ID] {
+				picked[r.ID] = true
+				rows = append(rows, r)
+			}
+		}
+	}
+	sortChronologically(rows)
+	return rows, nil
+}
+
+func sortChronologically(rows []models.DialogueMessage) {
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].CreatedAt.Equal(rows[j].CreatedAt) {
+			return rows[i].ID < rows[j].ID
+		}
+		return rows[i].CreatedAt.Before(rows[j].CreatedAt)
+	})
+}
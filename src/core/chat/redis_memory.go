@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"angrymiao-ai-server/src/configs"
+	"angrymiao-ai-server/src/configs/cache"
 	"angrymiao-ai-server/src/core/utils"
 	"github.com/redis/go-redis/v9"
 )
@@ -26,11 +27,11 @@ func NewRedisMemory(cfg configs.RedisConfig, logger *utils.Logger, userID string
 	if cfg.Addr == "" {
 		return nil, fmt.Errorf("Redis地址未配置")
 	}
-	client := redis.NewClient(&redis.Options{
-		Addr:     cfg.Addr,
-		Password: cfg.Password,
-		DB:       cfg.DB,
-	})
+	// 使用全局共享的Redis客户端，避免每个连接单独建立连接池
+	client := cache.GetRedis()
+	if client == nil {
+		client = cache.InitRedis(cfg)
+	}
 	ctx := context.Background()
 	if err := client.Ping(ctx).Err(); err != nil {
 		return nil, fmt.Errorf("Redis连接失败: %v", err)
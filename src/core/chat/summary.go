@@ -0,0 +1,104 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// SummaryPrefix 对话历史摘要消息的内容前缀
+const SummaryPrefix = "[conversation summary] "
+
+// Summarizer 将一段较早的对话压缩为摘要文本
+type Summarizer func(ctx context.Context, messages []Message) (string, error)
+
+// SetSummarizer 设置生成对话历史摘要的函数，未设置时 SummarizeOlderMessages 不做任何操作
+func (dm *DialogueManager) SetSummarizer(summarizer Summarizer) {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+	dm.summarizer = summarizer
+}
+
+// NeedsSummary 对话历史的估算token数超过 SetMaxTokens 设置的上限时返回 true
+func (dm *DialogueManager) NeedsSummary() bool {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+	return dm.summarizer != nil && dm.maxTokens > 0 && estimateDialogueTokens(dm.dialogue) > dm.maxTokens
+}
+
+// IsSummaryMessage 判断消息是否为 SummarizeOlderMessages 生成的摘要消息
+func IsSummaryMessage(msg Message) bool {
+	return msg.Role == "system" && strings.HasPrefix(msg.Content, SummaryPrefix)
+}
+
+// SummarizeOlderMessages 将除最近 keepLast 条以外的非system消息替换为一条摘要system消息，并通过存储后端持久化摘要
+// 生成摘要期间不持有锁，期间新追加的消息会保留；若对话被清空或重新加载则放弃本次摘要
+// 已有摘要进行中时直接返回
+func (dm *DialogueManager) SummarizeOlderMessages(ctx context.Context, keepLast int) error {
+	if keepLast < 0 {
+		keepLast = 0
+	}
+	if !dm.summarizing.CompareAndSwap(false, true) {
+		return nil
+	}
+	defer dm.summarizing.Store(false)
+
+	dm.mu.Lock()
+	summarizer := dm.summarizer
+	start := 0
+	if len(dm.dialogue) > 0 && dm.dialogue[0].Role == "system" && !IsSummaryMessage(dm.dialogue[0]) {
+		start = 1
+	}
+	end := len(dm.dialogue) - keepLast
+	// 保留部分不能以工具结果开头，否则与摘要掉的工具调用脱节
+	for end > start && end < len(dm.dialogue) && dm.dialogue[end].Role == "tool" {
+		end--
+	}
+	if summarizer == nil || end-start < 2 {
+		dm.mu.Unlock()
+		return nil
+	}
+	older := make([]Message, end-start)
+	copy(older, dm.dialogue[start:end])
+	generation := dm.generation
+	dm.mu.Unlock()
+
+	summary, err := summarizer(ctx, older)
+	if err != nil {
+		return fmt.Errorf("生成对话摘要失败: %w", err)
+	}
+	summary = strings.TrimSpace(summary)
+	if summary == "" {
+		return fmt.Errorf("生成的对话摘要为空")
+	}
+
+	dm.mu.Lock()
+	if dm.generation != generation || len(dm.dialogue) < end {
+		dm.mu.Unlock()
+		return fmt.Errorf("对话已被重置，放弃本次摘要")
+	}
+	dialogue := make([]Message, 0, len(dm.dialogue)-end+start+1)
+	dialogue = append(dialogue, dm.dialogue[:start]...)
+	dialogue = append(dialogue, Message{Role: "system", Content: SummaryPrefix + summary})
+	dialogue = append(dialogue, dm.dialogue[end:]...)
+	dm.dialogue = dialogue
+	dm.generation++
+	dm.mu.Unlock()
+
+	if err := dm.backend.Append([]Message{{Role: RoleSummary, Content: summary}}); err != nil {
+		dm.logger.Warn("保存对话摘要失败: %v", err)
+	}
+	dm.logger.Info("已将 %d 条较早的对话压缩为摘要", len(older))
+	return nil
+}
+
+// withoutSummaries 过滤掉摘要记录，摘要只用于展示，不发送给LLM
+func withoutSummaries(msgs []Message) []Message {
+	filtered := msgs[:0:0]
+	for _, msg := range msgs {
+		if msg.Role != RoleSummary {
+			filtered = append(filtered, msg)
+		}
+	}
+	return filtered
+}
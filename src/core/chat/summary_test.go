@@ -0,0 +1,94 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSummarizeOlderMessagesKeepsDialogueUnderLimit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "42.jsonl")
+	backend, err := NewFileBackend(path)
+	if err != nil {
+		t.Fatalf("创建文件存储失败: %v", err)
+	}
+	dm := NewDialogueManagerWithBackend(newTestDialogueManager(t).logger, backend)
+	dm.SetSystemMessage("你是助手")
+	const limit = 200
+	dm.SetMaxTokens(limit)
+	calls := 0
+	dm.SetSummarizer(func(ctx context.Context, messages []Message) (string, error) {
+		calls++
+		if len(messages) > 0 && messages[0].Role == "system" && !IsSummaryMessage(messages[0]) {
+			t.Error("系统提示词不应参与摘要")
+		}
+		return fmt.Sprintf("第%d次摘要，共%d条", calls, len(messages)), nil
+	})
+
+	for i := 0; i < 50; i++ {
+		dm.Put(Message{Role: "user", Content: fmt.Sprintf("问题%d %s", i, strings.Repeat("问", 35))})
+		dm.Put(Message{Role: "assistant", Content: fmt.Sprintf("回答%d %s", i, strings.Repeat("答", 35))})
+		if dm.NeedsSummary() {
+			if err := dm.SummarizeOlderMessages(context.Background(), 4); err != nil {
+				t.Fatalf("压缩对话失败: %v", err)
+			}
+		}
+		if tokens := estimateDialogueTokens(dm.dialogue); tokens > limit {
+			t.Fatalf("第%d轮后对话历史 %d tokens 超过上限 %d", i, tokens, limit)
+		}
+	}
+	if calls == 0 {
+		t.Fatal("对话超过上限时应生成摘要")
+	}
+
+	got := dm.GetLLMDialogue()
+	if got[0].Content != "你是助手" || !IsSummaryMessage(got[1]) || !strings.HasPrefix(got[1].Content, SummaryPrefix+fmt.Sprintf("第%d次摘要", calls)) {
+		t.Fatalf("摘要后的对话开头 = %+v", got[:2])
+	}
+	if got[len(got)-1].Content != fmt.Sprintf("回答49 %s", strings.Repeat("答", 35)) {
+		t.Errorf("最近的消息应原样保留, 最后一条 = %q", got[len(got)-1].Content)
+	}
+
+	// 摘要写入存储，但不会作为对话历史重新加载
+	stored, err := backend.Query(0)
+	if err != nil {
+		t.Fatalf("查询存储失败: %v", err)
+	}
+	summaries := 0
+	for _, msg := range stored {
+		if msg.Role == RoleSummary {
+			summaries++
+		}
+	}
+	if summaries != calls {
+		t.Errorf("存储中的摘要数 = %d, 期望 %d", summaries, calls)
+	}
+	restored := NewDialogueManagerWithBackend(dm.logger, backend)
+	if err := restored.LoadFromStorage(); err != nil {
+		t.Fatalf("加载失败: %v", err)
+	}
+	for _, msg := range restored.GetLLMDialogue() {
+		if msg.Role == RoleSummary {
+			t.Fatal("摘要记录不应加载到对话历史中")
+		}
+	}
+}
+
+func TestSummarizeOlderMessagesDiscardedAfterReset(t *testing.T) {
+	dm := newTestDialogueManager(t)
+	for i := 0; i < 6; i++ {
+		dm.Put(Message{Role: "user", Content: fmt.Sprintf("问题%d", i)})
+	}
+	dm.SetSummarizer(func(ctx context.Context, messages []Message) (string, error) {
+		dm.Clear()
+		return "摘要", nil
+	})
+	if err := dm.SummarizeOlderMessages(context.Background(), 2); err == nil {
+		t.Error("摘要期间对话被清空时应放弃本次摘要")
+	}
+	if dm.Length() != 0 {
+		t.Errorf("清空后的对话长度 = %d", dm.Length())
+	}
+}
@@ -0,0 +1,107 @@
+package chat
+
+import (
+	"math"
+
+	"angrymiao-ai-server/src/core/utils"
+)
+
+// truncatedSuffix 超长消息截断后追加的标记
+const truncatedSuffix = "... [truncated]"
+
+// estimateMessageTokens 估算单条消息的token数，包含工具调用的函数名与参数
+func estimateMessageTokens(msg Message) int {
+	tokens := utils.TextTokens(msg.Content)
+	for _, call := range msg.ToolCalls {
+		tokens += utils.TextTokens(call.Function.Name) + utils.TextTokens(call.Function.Arguments)
+	}
+	return int(math.Ceil(tokens))
+}
+
+func estimateDialogueTokens(messages []Message) int {
+	total := 0
+	for _, msg := range messages {
+		total += estimateMessageTokens(msg)
+	}
+	return total
+}
+
+// truncateContent 截断文本使其连同截断标记的估算token数不超过 maxTokens
+func truncateContent(content string, maxTokens int) string {
+	budget := float64(maxTokens) - utils.TextTokens(truncatedSuffix)
+	used := 0.0
+	for i, r := range content {
+		used += utils.RuneTokens(r)
+		if used > budget {
+			return content[:i] + truncatedSuffix
+		}
+	}
+	return content
+}
+
+// fitTokenBudget 按 maxTokens 裁剪发给LLM的消息列表，返回新的切片，不修改 messages
+// 单条消息超出上限时截断其内容；总量超出时保留system消息与最后一条消息，从最早的非system消息开始丢弃
+func (dm *DialogueManager) fitTokenBudget(messages []Message) []Message {
+	if dm.maxTokens <= 0 || len(messages) == 0 {
+		return messages
+	}
+	tokens := make([]int, len(messages))
+	total := 0
+	for i, msg := range messages {
+		tokens[i] = estimateMessageTokens(msg)
+		total += tokens[i]
+	}
+	if total <= dm.maxTokens {
+		return messages
+	}
+
+	fitted := make([]Message, len(messages))
+	copy(fitted, messages)
+	truncated := 0
+	for i := range fitted {
+		if tokens[i] > dm.maxTokens {
+			fitted[i].Content = truncateContent(fitted[i].Content, dm.maxTokens)
+			total -= tokens[i]
+			tokens[i] = estimateMessageTokens(fitted[i])
+			total += tokens[i]
+			truncated++
+		}
+	}
+
+	// 丢弃最早的非system消息；工具结果与发起调用的assistant消息一起丢弃，避免出现孤立的tool消息
+	last := len(fitted) - 1
+	dropped := make([]bool, len(fitted))
+	droppedCount := 0
+	for i := 0; i < last; i++ {
+		if fitted[i].Role == "system" {
+			continue
+		}
+		if total <= dm.maxTokens && (fitted[i].Role != "tool" || droppedCount == 0) {
+			break
+		}
+		dropped[i] = true
+		droppedCount++
+		total -= tokens[i]
+	}
+
+	if total > dm.maxTokens && fitted[last].Role != "system" {
+		// system消息与最后一条消息仍超出上限时截断最后一条消息
+		fitted[last].Content = truncateContent(fitted[last].Content, max(tokens[last]-(total-dm.maxTokens), 0))
+		total -= tokens[last]
+		tokens[last] = estimateMessageTokens(fitted[last])
+		total += tokens[last]
+		truncated++
+	}
+
+	result := make([]Message, 0, len(fitted)-droppedCount)
+	for i, msg := range fitted {
+		if !dropped[i] {
+			result = append(result, msg)
+		}
+	}
+	if truncated > 0 || droppedCount > 0 {
+		dm.logger.Warn("对话上下文超出token上限 %d，丢弃最早的 %d 条消息，截断 %d 条超长消息，裁剪后估算 %d tokens",
+			dm.maxTokens, droppedCount, truncated, total)
+	}
+	return result
+}
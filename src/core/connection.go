@@ -7,10 +7,12 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"regexp"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
+	"unicode/utf8"
 
 	"angrymiao-ai-server/src/configs"
 	"angrymiao-ai-server/src/core/auth"
@@ -18,7 +20,10 @@ import (
 	"angrymiao-ai-server/src/core/chat"
 	"angrymiao-ai-server/src/core/function"
 	"angrymiao-ai-server/src/core/image"
+	"angrymiao-ai-server/src/core/knowledge"
+	"angrymiao-ai-server/src/core/langdetect"
 	"angrymiao-ai-server/src/core/mcp"
+	"angrymiao-ai-server/src/core/moderation"
 	"angrymiao-ai-server/src/core/pool"
 	"angrymiao-ai-server/src/core/providers"
 	"angrymiao-ai-server/src/core/providers/llm"
@@ -27,6 +32,7 @@ import (
 	"angrymiao-ai-server/src/core/providers/vlllm"
 	"angrymiao-ai-server/src/core/types"
 	"angrymiao-ai-server/src/core/utils"
+	"angrymiao-ai-server/src/models"
 	"angrymiao-ai-server/src/task"
 
 	"github.com/angrymiao/go-openai"
@@ -35,6 +41,9 @@ import (
 
 type MCPResultHandler func(args interface{}) string
 
+// userConfigSubscribeTimeout 订阅用户Bot配置变更时等待确认的最长时间
+const userConfigSubscribeTimeout = 3 * time.Second
+
 // Connection 统一连接接口
 type Connection interface {
 	// 发送消息
@@ -61,6 +70,14 @@ type UDPInfoProvider interface {
 	GetUDPInfo() (enabled bool, server, port, key, nonce string)
 }
 
+// E2EInfoProvider 提供端到端加密协商信息的接口（可选）
+type E2EInfoProvider interface {
+	// 获取hello响应中下发的协商信息，enabled 为false表示未协商
+	GetE2EInfo() (enabled bool, curve, cipher, publicKey string)
+	// hello响应发出后开始加密收发的消息
+	ActivateE2E()
+}
+
 type configGetter interface {
 	Config() *tts.Config
 }
@@ -85,6 +102,26 @@ type ConnectionHandler struct {
 	}
 
 	initailVoice string // 初始语音名称
+	// 借出提供者时的配置快照，提供者归还资源池前恢复，避免用户配置带给下一个连接
+	initialLLMConfig *llm.Config
+	initialTTSConfig *tts.Config
+
+	// 对话语言，LLM回复中的 [LANG:xx] 标记会在本轮内切换音色与ASR语言
+	languageMu           sync.Mutex
+	preferredLanguage    string // 客户端hello中指定的默认语言
+	activeLanguage       string // 当前生效的语言，为空表示使用配置默认值
+	languageDefaultVoice string // 切换语言前的音色
+
+	// TTS语速与音调，LLM回复中的 [TTS_SPEED:x]、[TTS_PITCH:x] 标记会在本轮内调整
+	ttsParamsMu     sync.Mutex
+	currentTTSSpeed float32 // 语速倍率，默认 1
+	currentTTSPitch float32 // 音调偏移的半音数，默认 0
+
+	// 备用TTS，主TTS容量不足时按 tts_fallback 顺序使用
+	ttsFallbackMu         sync.Mutex
+	ttsFallbackProviders  map[string]providers.TTSProvider // 按配置名称缓存，首次使用时创建
+	ttsFallbackRound      int                              // ttsRoundFallbackCount 对应的轮次
+	ttsRoundFallbackCount int                              // 本轮使用备用TTS合成的分段数
 
 	// 会话相关
 	sessionID     string            // 设备与服务端会话ID
@@ -98,6 +135,7 @@ type ConnectionHandler struct {
 	clientAudioSampleRate    int
 	clientAudioChannels      int
 	clientAudioFrameDuration int
+	clientStreamingAudio     bool // hello 中 audio_params.streaming 为 true，支持逐帧接收流式TTS音频
 
 	// 客户端UDP地址信息（用于NAT穿透）
 	clientPublicIP string // 客户端提供的公网IP
@@ -109,54 +147,147 @@ type ConnectionHandler struct {
 	serverAudioFrameDuration int
 
 	clientListenMode string
-	isDeviceVerified bool
-	closeAfterChat   bool
-	enableVAD        bool
-	vadState         *VADState // VAD状态管理器
+	// hybrid 拾音模式下等待客户端确认的识别结果
+	confirmMu          sync.Mutex
+	pendingConfirmText string
+	confirmTimer       *time.Timer
+	confirmSeq         uint64 // 每次请求确认时递增，用于忽略已失效的超时回调
+	isDeviceVerified   bool
+	closeAfterChat     bool
+	enableVAD          bool
+	vadState           *VADState // VAD状态管理器
 
 	// 语音处理相关
 	clientVoiceStop bool  // true客户端语音停止, 不再上传语音数据
 	serverVoiceStop int32 // 1表示true服务端语音停止, 不再下发语音数据
 
-	opusDecoder *utils.OpusDecoder // Opus解码器
+	opusDecoder opusFrameDecoder // Opus解码器
+	codecStats  *AudioCodecStats // Opus解码压缩比与错误率
+	// 服务端播放时的回声消除器，开启 echo_cancellation_enabled 时在hello中创建
+	echoCanceller *utils.EchoCanceller
+
+	// 服务端PCM音频的Opus编码器，会话内复用以保持编码状态，首次发送时创建
+	opusEncoderMu sync.Mutex
+	opusEncoder   *utils.OpusEncoder
 
 	// 对话相关
 	dialogueManager     *chat.DialogueManager
-	tts_last_text_index int
-	client_asr_text     string // 客户端ASR文本
+	conversationRouter  *ConversationRouter // 多Bot转交时暂停的对话上下文，首次转交时创建
+	tts_last_text_index int32               // 本轮最后一个分段索引，跨协程读写需使用atomic
+	client_asr_text     string              // 客户端ASR文本
 	quickReplyCache     *utils.QuickReplyCache
+	ttsPrefetch         *PreFetchBuffer // TTS预取缓冲区，未开启时为nil
+
+	// 内容审核，未开启时为nil；moderationFallbackRound 为已播报兜底回复的轮次
+	moderator               moderation.Moderator
+	moderationFallbackRound int32
+
+	// 流式识别中对疑似完整的部分结果预先计算意图
+	speculativeASR *SpeculativeProcessor
+	asrConfidence  atomic.Pointer[float32] // 最近一次被接受的ASR识别结果的置信度，下发 stt 消息时取走
+
+	// 工具调用后继续请求LLM的嵌套深度与调用链上的函数名，用于中止过深或循环的工具调用
+	toolCallMu    sync.Mutex
+	toolCallDepth int
+	toolCallChain []string
+
+	// 客户端 form_start 发起的对话式表单，为nil时未在填写表单
+	formMu      sync.Mutex
+	formSession *FormSession
+
+	// 客户端hello中开启 streaming_vad_level 时定时下发的音量电平，vadLevelStop 为nil时未开启
+	vadLevelMu    sync.Mutex
+	vadLevelStop  chan struct{}
+	vadLevelDB    float64
+	vadLevelVoice bool
+	vadLevelFresh bool // 上次下发后是否有新的检测帧
+
+	knowledgeBase knowledge.KnowledgeBaseClient // 外部知识库检索，未配置时为nil
+	exitPatterns  []*regexp.Regexp              // 退出命令正则，创建连接时编译
+
+	// 开头音频的语种识别，未启用时为nil
+	languageDetector     langdetect.Detector
+	langDetectMu         sync.Mutex
+	langDetectBuffer     []byte    // 等待识别语种的音频，识别后送入ASR
+	nextLanguageDetectAt time.Time // 在此之前不再识别语种
+
+	// Bot好友的对话管理器，按 FunctionName 区分，与主会话历史隔离
+	namespacedMu        sync.Mutex
+	namespacedDialogues map[string]*chat.DialogueManager
+
+	// 等待MCP服务器回调结果的工具调用，按工具调用ID区分
+	callbackMu       sync.Mutex
+	pendingCallbacks map[string]*pendingToolCallback
+
+	// 服务端主动消息，会话正在对话时排队到本轮结束
+	activeChats             int32 // 正在处理的聊天消息数，跨协程读取需使用atomic
+	proactiveMu             sync.Mutex
+	pendingProactiveMessage *proactiveMessage
+	lastProactiveAt         time.Time
+
+	// detect 消息中图片URL的下载缓存，同一会话5分钟内不重复下载
+	imageURLMu    sync.Mutex
+	imageURLCache map[string]*imageURLCacheEntry
+
+	// 应用层心跳，最近一次收到 pong 的时间(UnixNano)，跨协程读写需使用atomic
+	lastPongAt int64
 
 	// 并发控制
 	stopChan         chan struct{}
-	clientAudioQueue chan []byte
+	clientAudioQueue chan clientAudioFrame
+	audioQuality     *AudioQualityTracker // 会话音频质量指标
+	audioLatency     *AudioLatencyStats   // 按轮次统计的延迟归因
 	clientTextQueue  chan string
 	mcpMessageQueue  chan map[string]interface{}
+	textDedup        textDedup // 最近文本消息，用于丢弃重复消息
+
+	failedTTSMu  sync.Mutex
+	failedTTSLog []FailedTTSEntry // 最近合成失败的TTS分段，最多保留 maxFailedTTSEntries 条
 
 	// TTS任务队列
 	ttsQueue chan struct {
-		text      string
-		round     int // 轮次
-		textIndex int
+		text         string
+		round        int // 轮次
+		textIndex    int
+		paragraphEnd bool // 分段位于段落末尾
 	}
 
 	audioMessagesQueue chan struct {
-		filepath  string
-		text      string
-		round     int // 轮次
-		textIndex int
+		filepath     string
+		text         string
+		round        int // 轮次
+		textIndex    int
+		paragraphEnd bool
+		stream       <-chan []byte // 流式TTS的Opus帧，非nil时忽略 filepath
 	}
 
-	talkRound      int       // 轮次计数
+	talkRound      int32     // 轮次计数，跨协程读取需使用atomic
+	prefixedRound  int32     // 已播报回复前缀的轮次
 	roundStartTime time.Time // 轮次开始时间
 	// functions
 	functionRegister *function.FunctionRegistry
 	mcpManager       *mcp.Manager
+	sessionMCP       *mcp.Manager // 客户端hello中指定的会话级MCP管理器
+	sessionMCPMu     sync.RWMutex // 保护sessionMCP，会话工具调用期间持有读锁
+
+	// 提供者集合与其来源的资源池，开启 use_private_config 时按用户配置切换
+	providerSet *pool.ProviderSet
+	poolManager *pool.PoolManager
+	poolSource  func() *pool.PoolManager // 获取最新的资源池管理器，热升级后在对话轮次结束时换用
 
 	// Bot配置服务（从好友表获取配置）
-	userConfigService botconfig.Service
-	userID            string             // 从JWT中提取的用户ID
-	request           *http.Request      // HTTP请求对象，用于获取用户配置等信息
-	userConfigs       []*types.BotConfig // 缓存用户Bot配置，避免重复查询
+	userConfigService  botconfig.Service
+	userID             string             // 从JWT中提取的用户ID
+	request            *http.Request      // HTTP请求对象，用于获取用户配置等信息
+	requestID          string             // 连接请求的关联ID，附加到日志与LLM调用的上下文
+	userConfigs        []*types.BotConfig // 缓存用户Bot配置，避免重复查询
+	userConfigsMu      sync.RWMutex
+	userFunctions      []string // 本连接实际注册成功的用户函数名，重新加载时只注销这些函数
+	compositePrompt    string   // 上一次追加到系统提示词的专业助手说明，重新加载时先移除
+	unsubscribeConfig  func()   // 取消订阅用户Bot配置变更
+	unsubscribeContext func()   // 取消订阅用户对话上下文变更
+
+	deviceGroup *models.DeviceGroup // 设备所属的设备组，连接建立时加载
 
 	mcpResultHandlers map[string]func(args interface{}) // MCP处理器映射
 	ctx               context.Context
@@ -170,24 +301,38 @@ func NewConnectionHandler(
 	req *http.Request,
 	ctx context.Context,
 ) *ConnectionHandler {
+	// 使用连接请求的关联ID，没有时生成，本连接的日志都附带该ID
+	requestID := utils.RequestIDFromContext(req.Context())
+	if requestID == "" {
+		requestID = req.Header.Get(utils.RequestIDHeader)
+	}
+	if requestID == "" {
+		requestID = uuid.New().String()
+	}
+	logger = logger.WithField(utils.RequestIDKey, requestID)
+
 	handler := &ConnectionHandler{
 		config:           config,
 		logger:           logger,
 		clientListenMode: "auto",
+		currentTTSSpeed:  defaultTTSSpeed,
 		stopChan:         make(chan struct{}),
-		clientAudioQueue: make(chan []byte, 100),
+		clientAudioQueue: make(chan clientAudioFrame, 100),
 		clientTextQueue:  make(chan string, 100),
 		mcpMessageQueue:  make(chan map[string]interface{}, 100),
 		ttsQueue: make(chan struct {
-			text      string
-			round     int // 轮次
-			textIndex int
+			text         string
+			round        int // 轮次
+			textIndex    int
+			paragraphEnd bool // 分段位于段落末尾
 		}, 100),
 		audioMessagesQueue: make(chan struct {
-			filepath  string
-			text      string
-			round     int // 轮次
-			textIndex int
+			filepath     string
+			text         string
+			round        int // 轮次
+			textIndex    int
+			paragraphEnd bool
+			stream       <-chan []byte
 		}, 100),
 
 		tts_last_text_index: -1,
@@ -199,8 +344,9 @@ func NewConnectionHandler(
 		serverAudioChannels:      1,
 		serverAudioFrameDuration: 60,
 
-		ctx:     ctx,
-		request: req, // 保存HTTP请求对象
+		ctx:       ctx,
+		request:   req, // 保存HTTP请求对象
+		requestID: requestID,
 
 		headers: make(map[string]string),
 	}
@@ -236,15 +382,14 @@ func NewConnectionHandler(
 		}
 	}
 
+	handler.audioQuality = NewAudioQualityTracker(handler.sessionID)
+	handler.codecStats = NewAudioCodecStats(handler.sessionID)
+	handler.audioLatency = NewAudioLatencyStats()
+
 	// 正确设置providers
-	if providerSet != nil {
-		handler.providers.asr = providerSet.ASR
-		handler.providers.llm = providerSet.LLM
-		handler.providers.tts = providerSet.TTS
-		handler.providers.vlllm = providerSet.VLLLM
-		handler.providers.vad = providerSet.VAD
-		handler.mcpManager = providerSet.MCP
-	}
+	handler.useProviderSet(providerSet)
+	handler.moderator = handler.newModerator()
+	handler.speculativeASR = NewSpeculativeProcessor(handler.speculateIntents)
 
 	// VAD 默认不启用，只有在客户端明确传递 Enable-VAD: true 时才启用
 	handler.enableVAD = false
@@ -267,15 +412,17 @@ func NewConnectionHandler(
 		handler.vadState.SetMaxBufferFrames(3)
 	}
 
-	ttsProvider := "default" // 默认TTS提供者名称
-	voiceName := "default"
-	if getter, ok := handler.providers.tts.(configGetter); ok {
-		ttsProvider = getter.Config().Type
-		voiceName = getter.Config().Voice
-		handler.initailVoice = voiceName // 保存初始语音名称
+	if config.KnowledgeBase.URL != "" {
+		handler.knowledgeBase = knowledge.NewHTTPClient(config.KnowledgeBase.URL, config.KnowledgeBase.APIKey)
+	}
+	if config.LanguageDetectionEnabled && config.LangDetect.URL != "" {
+		handler.languageDetector = langdetect.NewHTTPDetector(config.LangDetect.URL, config.LangDetect.APIKey)
 	}
-	logger.Info("使用TTS提供者: %s, 语音名称: %s", ttsProvider, voiceName)
-	handler.quickReplyCache = utils.NewQuickReplyCache(ttsProvider, voiceName)
+	if config.TTSPrefetchCount > 0 {
+		handler.ttsPrefetch = NewPreFetchBuffer(config.TTSPrefetchCount, handler.synthesizeTTS)
+	}
+
+	handler.exitPatterns = compileExitPatterns(config.CMDExitPatterns, logger)
 
 	handler.functionRegister = function.NewFunctionRegistry()
 	handler.initMCPResultHandlers()
@@ -283,6 +430,33 @@ func NewConnectionHandler(
 	return handler
 }
 
+// useProviderSet 使用提供者集合中的提供者，并按TTS配置初始化快速回复缓存
+func (h *ConnectionHandler) useProviderSet(providerSet *pool.ProviderSet) {
+	h.providerSet = providerSet
+	if providerSet != nil {
+		h.providers.asr = providerSet.ASR
+		if providerSet.SharedASR != nil {
+			h.providers.asr = providerSet.SharedASR.Session(h.sessionID)
+		}
+		h.providers.llm = providerSet.LLM
+		h.providers.tts = providerSet.TTS
+		h.providers.vlllm = providerSet.VLLLM
+		h.providers.vad = providerSet.VAD
+		h.mcpManager = providerSet.MCP
+	}
+	h.snapshotProviderConfigs()
+
+	ttsProvider := "default" // 默认TTS提供者名称
+	voiceName := "default"
+	if getter, ok := h.providers.tts.(configGetter); ok {
+		ttsProvider = getter.Config().Type
+		voiceName = getter.Config().Voice
+		h.initailVoice = voiceName // 保存初始语音名称
+	}
+	h.logger.Info("使用TTS提供者: %s, 语音名称: %s", ttsProvider, voiceName)
+	h.quickReplyCache = utils.NewQuickReplyCache(ttsProvider, voiceName)
+}
+
 func (h *ConnectionHandler) SetTaskCallback(callback func(func(*ConnectionHandler)) func()) {
 	h.safeCallbackFunc = callback
 }
@@ -301,6 +475,63 @@ func (h *ConnectionHandler) SetUserID(id string) {
 	h.userID = id
 }
 
+// SetPoolManager 注入资源池管理器，开启 use_private_config 时用于获取用户私有的提供者
+func (h *ConnectionHandler) SetPoolManager(pm *pool.PoolManager) {
+	h.poolManager = pm
+}
+
+// SetPoolSource 设置获取最新资源池管理器的函数，资源池热升级后本连接在对话轮次结束时换用新资源池
+func (h *ConnectionHandler) SetPoolSource(source func() *pool.PoolManager) {
+	h.poolSource = source
+}
+
+// ProviderSet 获取当前使用的提供者集合，连接关闭后由调用方归还到资源池
+func (h *ConnectionHandler) ProviderSet() *pool.ProviderSet {
+	return h.providerSet
+}
+
+// AudioQuality 获取会话音频质量指标快照
+func (h *ConnectionHandler) AudioQuality() AudioQualitySnapshot {
+	return h.audioQuality.Snapshot()
+}
+
+// AudioCodecStats 获取会话Opus解码统计快照
+func (h *ConnectionHandler) AudioCodecStats() AudioCodecSnapshot {
+	return h.codecStats.Snapshot()
+}
+
+// AudioLatency 获取会话延迟归因快照
+func (h *ConnectionHandler) AudioLatency() AudioLatencyReport {
+	return h.audioLatency.Snapshot()
+}
+
+// TTSQueueDepth 获取等待合成的TTS任务数
+func (h *ConnectionHandler) TTSQueueDepth() int {
+	return len(h.ttsQueue)
+}
+
+// clientAudioFrame 客户端音频帧及服务端收到该帧的时间（UnixNano）
+type clientAudioFrame struct {
+	data       []byte
+	receivedAt int64
+}
+
+// enqueueClientAudio 将音频帧放入 clientAudioQueue，队列已满时丢弃并计入丢包
+func (h *ConnectionHandler) enqueueClientAudio(data []byte, receivedAt int64) {
+	select {
+	case h.clientAudioQueue <- clientAudioFrame{data: data, receivedAt: receivedAt}:
+		h.audioQuality.RecordFrameReceived()
+	default:
+		h.audioQuality.RecordFrameDropped()
+		h.logger.Debug("音频队列已满，丢弃音频帧: %d bytes", len(data))
+	}
+}
+
+// GetTalkRound 获取当前对话轮次
+func (h *ConnectionHandler) GetTalkRound() int {
+	return int(atomic.LoadInt32(&h.talkRound))
+}
+
 func (h *ConnectionHandler) SubmitTask(taskType string, params map[string]interface{}) {
 	_task, id := task.NewTask(h.ctx, "", params)
 	h.LogInfo(fmt.Sprintf("提交任务: %s, ID: %s, 参数: %v", _task.Type, id, params))
@@ -348,25 +579,15 @@ func (h *ConnectionHandler) Handle(conn Connection) {
 	defer conn.Close()
 
 	h.conn = conn
+	defaultAudioQualityCollector.add(h.audioQuality)
+	defaultAudioCodecCollector.add(h.codecStats)
+	h.switchToUserProviders()
 
 	h.loadUserDialogueManager()
 	h.loadUserAIConfigurations()
-
-	// ========== 用户配置注入点 ==========
-	// 在这里可以注入用户级的 provider 配置
-	// 示例 1：LLM 配置
-	// userLLMConfig := &llm.Config{
-	// 	Temperature: 0.8,
-	// 	MaxTokens:   2000,
-	// }
-	// h.ApplyUserLLMConfig(userLLMConfig)
-	//
-	// 示例 2：TTS 配置
-	// userTTSConfig := &tts.Config{
-	// 	Voice: "zh-CN-XiaoxiaoNeural",
-	// }
-	// h.ApplyUserTTSConfig(userTTSConfig)
-	// ====================================
+	h.subscribeUserConfigUpdates()
+	h.subscribeContextUpdates()
+	h.scheduleConversationStarter()
 
 	// 启动消息处理协程
 	go h.processClientAudioMessagesCoroutine() // 添加客户端音频消息处理协程
@@ -374,6 +595,7 @@ func (h *ConnectionHandler) Handle(conn Connection) {
 	go h.processMCPMessagesCoroutine()         // 添加MCP消息处理协程（独立于文本队列）
 	go h.processTTSQueueCoroutine()            // 添加TTS队列处理协程
 	go h.sendAudioMessageCoroutine()           // 添加音频消息发送协程
+	go h.startHeartbeatCoroutine()             // 添加应用层心跳协程，未配置间隔时直接返回
 
 	// 优化后的MCP管理器处理
 	if h.mcpManager == nil {
@@ -424,6 +646,9 @@ func (h *ConnectionHandler) processClientTextMessagesCoroutine() {
 		case <-h.stopChan:
 			return
 		case text := <-h.clientTextQueue:
+			if h.dropDuplicateText(text) {
+				continue
+			}
 			if err := h.processClientTextMessage(context.Background(), text); err != nil {
 				h.LogError(fmt.Sprintf("处理文本数据失败: %v", err))
 			}
@@ -452,20 +677,22 @@ func (h *ConnectionHandler) processClientAudioMessagesCoroutine() {
 		select {
 		case <-h.stopChan:
 			return
-		case audioData := <-h.clientAudioQueue:
+		case frame := <-h.clientAudioQueue:
 			if h.closeAfterChat {
 				continue
 			}
+			audioData := h.cancelEcho(frame.data)
 
 			// 如果启用VAD，则进行完整的VAD处理流程
 			if h.enableVAD && h.providers.vad != nil && h.vadState != nil {
 				h.processAudioWithVAD(audioData)
 			} else {
 				// 未启用VAD，直接送入ASR
-				if err := h.providers.asr.AddAudio(audioData); err != nil {
+				if err := h.addASRAudio(audioData); err != nil {
 					h.LogError(fmt.Sprintf("处理音频数据失败: %v", err))
 				}
 			}
+			h.audioLatency.RecordFrameToASR(frame.receivedAt, time.Now().UnixNano())
 		}
 	}
 }
@@ -473,6 +700,8 @@ func (h *ConnectionHandler) processClientAudioMessagesCoroutine() {
 // processAudioWithVAD 使用VAD处理音频数据
 // 完整逻辑：缓冲管理、VAD检测、空闲时间累计、静音检测
 func (h *ConnectionHandler) processAudioWithVAD(audioData []byte) {
+	h.audioQuality.RecordPCM(audioData)
+
 	// 获取音频参数
 	sr := h.clientAudioSampleRate
 	if sr <= 0 {
@@ -541,6 +770,7 @@ func (h *ConnectionHandler) processAudioWithVAD(audioData []byte) {
 		// VAD失败时，为了保险起见，假设有语音
 		haveVoice = true
 	}
+	h.recordVADLevel(vadData, haveVoice)
 
 	// 获取当前语音状态
 	clientHaveVoice := h.vadState.GetHaveVoice()
@@ -550,7 +780,7 @@ func (h *ConnectionHandler) processAudioWithVAD(audioData []byte) {
 		h.LogInfo("首次检测到语音活动")
 		// 首次检测到语音，将所有缓冲的音频数据送入ASR
 		allData := h.vadState.GetAndClearAllData()
-		if err := h.providers.asr.AddAudio(allData); err != nil {
+		if err := h.addASRAudio(allData); err != nil {
 			h.LogError(fmt.Sprintf("处理音频数据失败: %v", err))
 		}
 
@@ -566,7 +796,7 @@ func (h *ConnectionHandler) processAudioWithVAD(audioData []byte) {
 		// 清空缓冲区并送入ASR
 		bufferedData := h.vadState.GetAndClearAllData()
 		if len(bufferedData) > 0 {
-			if err := h.providers.asr.AddAudio(bufferedData); err != nil {
+			if err := h.addASRAudio(bufferedData); err != nil {
 				h.LogError(fmt.Sprintf("处理音频数据失败: %v", err))
 			}
 		}
@@ -585,6 +815,7 @@ func (h *ConnectionHandler) processAudioWithVAD(audioData []byte) {
 		if h.vadState.IsSilence(idleDuration) {
 			h.LogInfo(fmt.Sprintf("检测到静音，空闲时间: %dms，触发语音结束", idleDuration))
 			h.vadState.SetVoiceStop(true)
+			h.flushLanguageDetection() // 语音不足识别时长时，用已有音频识别语种并送入ASR
 			// 可以在这里触发ASR的FinalResult或其他处理
 		}
 
@@ -621,33 +852,70 @@ func (h *ConnectionHandler) sendAudioMessageCoroutine() {
 		case <-h.stopChan:
 			return
 		case task := <-h.audioMessagesQueue:
-			h.sendAudioMessage(task.filepath, task.text, task.textIndex, task.round)
+			if task.stream != nil {
+				h.streamAudioMessage(task.stream, task.text, task.textIndex, task.round)
+			} else {
+				h.sendAudioMessage(task.filepath, task.text, task.textIndex, task.round)
+			}
+			if (len(task.filepath) > 0 || task.stream != nil) && int32(task.textIndex) != atomic.LoadInt32(&h.tts_last_text_index) {
+				h.sendInterSegmentSilence(task.text, task.paragraphEnd, task.round)
+			}
 		}
 	}
 }
 
 // OnAsrResult 实现 AsrEventListener 接口
 // 返回true则停止语音识别，返回false会继续语音识别
-func (h *ConnectionHandler) OnAsrResult(result string, isFinalResult bool) bool {
+func (h *ConnectionHandler) OnAsrResult(result string, isFinalResult bool, confidence float32) bool {
 	//h.LogInfo(fmt.Sprintf("[%s] ASR识别结果: %s", h.clientListenMode, result))
-	if h.providers.asr.GetSilenceCount() >= 2 {
-		h.LogInfo("检测到连续两次静音，结束对话")
-		h.closeAfterChat = true // 如果连续两次静音，则结束对话
-		result = "长时间未检测到用户说话，请礼貌的结束对话"
+	if result != "" {
+		if minConfidence := h.config.ASR.MinConfidence; minConfidence > 0 && confidence < minConfidence {
+			h.logger.Debug("丢弃低置信度的ASR识别结果: %s, 置信度: %.2f < %.2f", result, confidence, minConfidence)
+			result = ""
+		} else {
+			h.asrConfidence.Store(&confidence)
+			h.audioQuality.RecordConfidence(float64(confidence))
+		}
+	}
+	if count := h.providers.asr.GetSilenceCount(); count > 0 {
+		prompt, chat := h.OnSilenceThreshold(count)
+		if !chat {
+			return false
+		}
+		result = prompt
 	}
 	if h.clientListenMode == "auto" {
 		if result == "" {
 			return false
 		}
+		if isFinalResult {
+			result = h.restorePunctuation(result)
+		}
 		h.LogInfo(fmt.Sprintf("[%s] ASR识别结果: %s", h.clientListenMode, result))
+		h.audioLatency.RecordASRFinal(time.Now().UnixNano())
 		h.handleChatMessage(context.Background(), result)
 		return true
+	} else if h.clientListenMode == "hybrid" {
+		if result == "" {
+			return false
+		}
+		if isFinalResult {
+			result = h.restorePunctuation(result)
+		}
+		// VAD断句后等待客户端确认，确认后才开始对话
+		h.LogInfo(fmt.Sprintf("[%s] ASR识别结果: %s，等待客户端确认", h.clientListenMode, result))
+		h.audioLatency.RecordASRFinal(time.Now().UnixNano())
+		h.requestASRConfirm(result)
+		return true
 	} else if h.clientListenMode == "manual" {
 		h.client_asr_text += result
 		if isFinalResult {
-			h.handleChatMessage(context.Background(), h.client_asr_text)
+			h.audioLatency.RecordASRFinal(time.Now().UnixNano())
+			h.confirmSpeculation(h.client_asr_text)
+			h.handleChatMessage(context.Background(), h.restorePunctuation(h.client_asr_text))
 			return true
 		}
+		h.speculativeASR.OnPartial(h.client_asr_text)
 		return false
 
 		// h.client_asr_text += result
@@ -669,6 +937,7 @@ func (h *ConnectionHandler) OnAsrResult(result string, isFinalResult bool) bool
 		h.stopServerSpeak()
 		h.providers.asr.Reset() // 重置ASR状态，准备下一次识别
 		h.LogInfo(fmt.Sprintf("[%s] ASR识别结果: %s", h.clientListenMode, result))
+		h.audioLatency.RecordASRFinal(time.Now().UnixNano())
 		h.handleChatMessage(context.Background(), result)
 		return true
 	}
@@ -681,48 +950,55 @@ func (h *ConnectionHandler) clientAbortChat() error {
 	h.stopServerSpeak()
 	h.sendTTSMessage("stop", "", 0)
 	h.clearSpeakStatus()
+	h.deliverPendingProactive()
 	return nil
 }
 
 func (h *ConnectionHandler) QuitIntent(text string) bool {
-	//CMD_exit 读取配置中的退出命令
-	exitCommands := h.config.CMDExit
-	if exitCommands == nil {
-		return false
-	}
-	cleand_text := utils.RemoveAllPunctuation(text) // 移除标点符号，确保匹配准确
-	// 检查是否包含退出命令
-	for _, cmd := range exitCommands {
-		h.logger.Debug(fmt.Sprintf("检查退出命令: %s,%s", cmd, cleand_text))
-		//判断相等
-		if cleand_text == cmd {
-			h.LogInfo("收到客户端退出意图，准备结束对话")
-			h.Close() // 直接关闭连接
-			return true
-		}
+	result, hit := h.speculativeASR.Lookup(text)
+	quit := result.Quit
+	if !hit {
+		quit = h.matchQuitIntent(text)
+	}
+	if quit {
+		h.LogInfo("收到客户端退出意图，准备结束对话")
+		h.Close() // 直接关闭连接
+		return true
 	}
 	return false
 }
 
 func (h *ConnectionHandler) quickReplyWakeUpWords(text string) bool {
 	// 检查是否包含唤醒词
-	if !h.config.QuickReply || h.talkRound != 1 {
+	if !h.config.QuickReply || h.GetTalkRound() != 1 {
 		return false
 	}
-	if !utils.IsWakeUpWord(text) {
+	// 流式识别中已预先计算时直接使用预计算结果
+	result, hit := h.speculativeASR.Lookup(text)
+	matched := result.QuickReply
+	if !hit {
+		matched = h.matchQuickReply(text)
+	}
+	if !matched {
 		return false
 	}
 
 	repalyWords := h.config.QuickReplyWords
 	reply_text := utils.RandomSelectFromArray(repalyWords)
-	h.tts_last_text_index = 1 // 重置文本索引
-	h.SpeakAndPlay(reply_text, 1, h.talkRound)
+	atomic.StoreInt32(&h.tts_last_text_index, 1) // 重置文本索引
+	h.SpeakAndPlay(reply_text, 1, h.GetTalkRound())
 
 	return true
 }
 
 // handleChatMessage 处理聊天消息
 func (h *ConnectionHandler) handleChatMessage(ctx context.Context, text string) error {
+	atomic.AddInt32(&h.activeChats, 1)
+	defer func() {
+		atomic.AddInt32(&h.activeChats, -1)
+		h.deliverPendingProactive()
+	}()
+
 	if text == "" {
 		h.logger.Warn("收到空聊天消息，忽略")
 		h.clientAbortChat()
@@ -733,10 +1009,22 @@ func (h *ConnectionHandler) handleChatMessage(ctx context.Context, text string)
 		return fmt.Errorf("用户请求退出对话")
 	}
 
+	// 填写表单期间识别结果作为当前字段的回答，不进入LLM对话
+	if h.handleFormAnswer(text) {
+		return nil
+	}
+
+	if !h.allowChatRequest(ctx) {
+		h.clientAbortChat()
+		return fmt.Errorf("请求过于频繁")
+	}
+
 	// 增加对话轮次
-	h.talkRound++
+	currentRound := int(atomic.AddInt32(&h.talkRound, 1))
 	h.roundStartTime = time.Now()
-	currentRound := h.talkRound
+	// 上一轮被打断时可能未恢复语言与语速音调
+	h.resetRoundLanguage()
+	h.resetSpeechParams()
 	h.LogInfo(fmt.Sprintf("开始新的对话轮次: %d", currentRound))
 
 	// 普通文本消息处理流程
@@ -775,15 +1063,27 @@ func (h *ConnectionHandler) handleChatMessage(ctx context.Context, text string)
 }
 
 func (h *ConnectionHandler) genResponseByLLM(ctx context.Context, messages []providers.Message, round int) error {
+	ctx = utils.WithRequestID(ctx, h.requestID)
 	defer func() {
 		if r := recover(); r != nil {
 			h.LogError(fmt.Sprintf("genResponseByLLM发生panic: %v", r))
 			errorMsg := "抱歉，处理您的请求时发生了错误"
-			h.tts_last_text_index = 1 // 重置文本索引
+			atomic.StoreInt32(&h.tts_last_text_index, 1) // 重置文本索引
 			h.SpeakAndPlay(errorMsg, 1, round)
 		}
 	}()
 
+	if functionName, ok := toolContinuationFrom(ctx); ok {
+		if err := h.enterToolCall(functionName); err != nil {
+			h.LogError(fmt.Sprintf("中止工具调用: %v", err))
+			h.SystemSpeak(toolCallAbortMessage)
+			return err
+		}
+		defer h.exitToolCall()
+	}
+
+	h.applyDialogueTokenLimit()
+	h.maybeSummarizeDialogue()
 	llmStartTime := time.Now()
 	//h.logger.Info("开始生成LLM回复, round:%d ", round)
 	for _, msg := range messages {
@@ -791,7 +1091,16 @@ func (h *ConnectionHandler) genResponseByLLM(ctx context.Context, messages []pro
 		//msg.Print()
 	}
 	// 使用LLM生成回复
+	h.ensureSessionMCPTools()
 	tools := h.functionRegister.GetAllFunctions()
+	if !h.providers.llm.Capabilities()[types.CapabilityTools] {
+		tools = nil
+	}
+	messages = h.injectKnowledgeContext(ctx, messages)
+	requestTokens := estimateRequestTokens(messages)
+	if !h.allowLLMRequest(ctx, requestTokens, round) {
+		return fmt.Errorf("今日token预算已用完")
+	}
 	responses, err := h.providers.llm.ResponseWithFunctions(ctx, h.sessionID, messages, tools)
 	if err != nil {
 		return fmt.Errorf("LLM生成回复失败: %v", err)
@@ -799,6 +1108,8 @@ func (h *ConnectionHandler) genResponseByLLM(ctx context.Context, messages []pro
 
 	// 处理回复
 	var responseMessage []string
+	// historyMessage 写入对话历史的回复，审核未通过的分段替换为兜底回复
+	var historyMessage []string
 	processedChars := 0
 	textIndex := 0
 
@@ -818,11 +1129,14 @@ func (h *ConnectionHandler) genResponseByLLM(ctx context.Context, messages []pro
 		if response.Error != "" {
 			h.LogError(fmt.Sprintf("LLM响应错误: %s", response.Error))
 			errorMsg := "抱歉，服务暂时不可用，请稍后再试"
-			h.tts_last_text_index = 1 // 重置文本索引
+			atomic.StoreInt32(&h.tts_last_text_index, 1) // 重置文本索引
 			h.SpeakAndPlay(errorMsg, 1, round)
 			return fmt.Errorf("LLM响应错误: %s", response.Error)
 		}
 
+		if content != "" || len(toolCall) > 0 {
+			h.audioLatency.RecordLLMFirstToken(time.Now().UnixNano())
+		}
 		if content != "" {
 			// 累加content_arguments
 			contentArguments += content
@@ -849,7 +1163,7 @@ func (h *ConnectionHandler) genResponseByLLM(ctx context.Context, messages []pro
 			if strings.Contains(content, "服务响应异常") {
 				h.LogError(fmt.Sprintf("检测到LLM服务异常: %s", content))
 				errorMsg := "抱歉，LLM服务暂时不可用，请稍后再试"
-				h.tts_last_text_index = 1 // 重置文本索引
+				atomic.StoreInt32(&h.tts_last_text_index, 1) // 重置文本索引
 				h.SpeakAndPlay(errorMsg, 1, round)
 				return fmt.Errorf("LLM服务异常")
 			}
@@ -867,10 +1181,23 @@ func (h *ConnectionHandler) genResponseByLLM(ctx context.Context, messages []pro
 			}
 			currentText := fullText[processedChars:]
 
-			// 按标点符号分割
-			if segment, charsCnt := utils.SplitAtLastPunctuation(currentText); charsCnt > 0 {
+			// 按标点符号分割，语言标记不会被拆开
+			if segment, charsCnt := splitLLMSegment(currentText); charsCnt > 0 {
+				paragraphEnd := utils.EndsWithParagraphBreak(segment)
+				segment = h.applySpeechMarkers(h.applyLanguageTag(segment))
+				historyText := currentText[:charsCnt]
+				if segment != "" {
+					if moderated := h.moderateSegment(ctx, segment, round); moderated != segment {
+						segment, historyText = moderated, moderated
+					}
+				}
+				historyMessage = append(historyMessage, historyText)
+				if segment == "" {
+					// 仅包含语言、语速音调标记或审核未通过的分段无需合成
+					processedChars += charsCnt
+					continue
+				}
 				textIndex++
-				segment = strings.TrimSpace(segment)
 				if textIndex == 1 {
 					now := time.Now()
 					llmSpentTime := now.Sub(llmStartTime)
@@ -878,8 +1205,9 @@ func (h *ConnectionHandler) genResponseByLLM(ctx context.Context, messages []pro
 				} else {
 					h.LogInfo(fmt.Sprintf("LLM回复分段: %s, index: %d, round:%d", segment, textIndex, round))
 				}
-				h.tts_last_text_index = textIndex
-				err := h.SpeakAndPlay(segment, textIndex, round)
+				segment, textIndex = h.applyResponsePrefix(segment, textIndex, round)
+				atomic.StoreInt32(&h.tts_last_text_index, int32(textIndex))
+				err := h.speakSegment(segment, textIndex, round, paragraphEnd)
 				if err != nil {
 					h.LogError(fmt.Sprintf("播放LLM回复分段失败: %v", err))
 				}
@@ -887,6 +1215,7 @@ func (h *ConnectionHandler) genResponseByLLM(ctx context.Context, messages []pro
 			}
 		}
 	}
+	h.recordTokenUsage(ctx, requestTokens, contentArguments+functionArguments)
 
 	if toolCallFlag {
 		bHasError := false
@@ -920,9 +1249,19 @@ func (h *ConnectionHandler) genResponseByLLM(ctx context.Context, messages []pro
 				"arguments": functionArguments,
 			}
 			h.LogInfo(fmt.Sprintf("函数调用: %v", arguments))
-			if h.mcpManager.IsMCPTool(functionName) {
+			mcpManager, releaseMCP := h.toolMCPManager(functionName)
+			if mcpManager.IsMCPTool(functionName) && h.toolCallbackEnabled() &&
+				mcpManager.ToolCallbackType(functionName) == mcp.CallbackTypeWebhook {
+				// 结果由MCP服务器异步回调返回
+				actionResult := h.startToolCallback(ctx, mcpManager, functionName, arguments, functionCallData)
+				releaseMCP()
+				h.handleFunctionResult(actionResult, functionCallData, textIndex)
+			} else if mcpManager.IsMCPTool(functionName) {
 				// 处理MCP函数调用
-				result, err := h.mcpManager.ExecuteTool(ctx, functionName, arguments)
+				callStart := time.Now()
+				result, err := mcpManager.ExecuteTool(ctx, functionName, arguments)
+				releaseMCP()
+				h.auditFunctionCall(functionName, functionArguments, result, callStart, err)
 				if err != nil {
 					h.LogError(fmt.Sprintf("MCP函数调用失败: %v", err))
 					if result == nil {
@@ -934,26 +1273,22 @@ func (h *ConnectionHandler) genResponseByLLM(ctx context.Context, messages []pro
 					h.handleFunctionResult(actionResult, functionCallData, textIndex)
 				} else {
 					h.LogInfo(fmt.Sprintf("MCP函数调用结果: %v", result))
-					actionResult := types.ActionResponse{
-						Action: types.ActionTypeReqLLM, // 动作类型
-						Result: result,                 // 动作产生的结果
-					}
-					h.handleFunctionResult(actionResult, functionCallData, textIndex)
+					// 返回转交指令时切换对话，其他结果交给LLM生成回复
+					h.handleFunctionResult(toolActionResult(result), functionCallData, textIndex)
 				}
 
 			} else {
+				releaseMCP()
 				// 处理普通函数调用
 				userFunCallConfig := types.BotConfig{}
-				if h.userConfigs != nil {
-					for _, v := range h.userConfigs {
-						if v.FunctionName == functionName {
-							userFunCallConfig = *v
-							break
-						}
-					}
+				if config := h.findUserConfig(functionName); config != nil {
+					userFunCallConfig = *config
 				}
 				if userFunCallConfig.FunctionName != "" {
+					callStart := time.Now()
 					funResult, err := h.executeUserFunctionCall(&userFunCallConfig, functionCallData)
+					h.auditFunctionCall(functionName, functionArguments, funResult.Result, callStart, err)
+					h.recordBotUsage(&userFunCallConfig, functionArguments, funResult.Result, callStart, err)
 					if err != nil {
 						h.LogError(fmt.Sprintf("MCP函数调用失败: %v", err))
 						if funResult.Result == "" {
@@ -961,11 +1296,7 @@ func (h *ConnectionHandler) genResponseByLLM(ctx context.Context, messages []pro
 						}
 					}
 
-					actionResult := types.ActionResponse{
-						Action: types.ActionTypeReqLLM,
-						Result: funResult.Result,
-					}
-					h.handleFunctionResult(actionResult, functionCallData, textIndex)
+					h.handleFunctionResult(toolActionResult(funResult.Result), functionCallData, textIndex)
 				}
 			}
 		}
@@ -974,19 +1305,27 @@ func (h *ConnectionHandler) genResponseByLLM(ctx context.Context, messages []pro
 	// 处理剩余文本
 	fullResponse := utils.JoinStrings(responseMessage)
 	if len(fullResponse) > processedChars {
-		remainingText := fullResponse[processedChars:]
+		historyText := fullResponse[processedChars:]
+		remainingText := h.applySpeechMarkers(h.applyLanguageTag(historyText))
+		if remainingText != "" {
+			if moderated := h.moderateSegment(ctx, remainingText, round); moderated != remainingText {
+				remainingText, historyText = moderated, moderated
+			}
+		}
+		historyMessage = append(historyMessage, historyText)
 		if remainingText != "" {
 			textIndex++
 			h.LogInfo(fmt.Sprintf("LLM回复分段[剩余文本]: %s, index: %d, round:%d", remainingText, textIndex, round))
-			h.tts_last_text_index = textIndex
+			remainingText, textIndex = h.applyResponsePrefix(remainingText, textIndex, round)
+			atomic.StoreInt32(&h.tts_last_text_index, int32(textIndex))
 			h.SpeakAndPlay(remainingText, textIndex, round)
 		}
 	} else {
 		h.logger.Debug("无剩余文本需要处理: fullResponse长度=%d, processedChars=%d", len(fullResponse), processedChars)
 	}
 
-	// 分析回复并发送相应的情绪
-	content := utils.JoinStrings(responseMessage)
+	// 分析回复并发送相应的情绪，审核未通过的原始回复不进入对话历史
+	content := utils.JoinStrings(historyMessage)
 
 	// 添加助手回复到对话历史
 	if !toolCallFlag {
@@ -994,6 +1333,9 @@ func (h *ConnectionHandler) genResponseByLLM(ctx context.Context, messages []pro
 			Role:    "assistant",
 			Content: content,
 		})
+		// LLM模式的情感分析最多耗时1秒，不阻塞当前轮次
+		go h.sendResponseEmotion(content)
+		h.scoreLastExchange()
 	}
 
 	return nil
@@ -1049,12 +1391,19 @@ func (h *ConnectionHandler) handleFunctionResult(result types.ActionResponse, fu
 	case types.ActionTypeCallHandler:
 		resultStr := h.handleMCPResultCall(result)
 		h.addToolCallMessage(resultStr, functionCallData)
+	case types.ActionTypeHandoff:
+		h.LogInfo(fmt.Sprintf("函数调用请求转交对话: %v", result.Result))
+		h.startHandoff(result.Result.(types.HandoffRequest), functionCallData)
+	case types.ActionTypeHandoffReturn:
+		h.LogInfo("函数调用请求结束转交对话")
+		h.returnHandoff(functionCallData)
 	case types.ActionTypeReqLLM:
 		h.LogInfo(fmt.Sprintf("函数调用后请求LLM: %v", result.Result))
 		text, ok := result.Result.(string)
 		if ok && len(text) > 0 {
 			h.addToolCallMessage(text, functionCallData)
-			h.genResponseByLLM(context.Background(), h.dialogueManager.GetLLMDialogue(), h.talkRound)
+			functionName, _ := functionCallData["name"].(string)
+			h.genResponseByLLM(withToolContinuation(context.Background(), functionName), h.dialogueManager.GetLLMDialogue(), h.GetTalkRound())
 
 		} else {
 			h.LogError(fmt.Sprintf("函数调用结果解析失败: %v", result.Result))
@@ -1071,24 +1420,58 @@ func (h *ConnectionHandler) SystemSpeak(text string) error {
 		return errors.New("收到空文本，无法合成语音")
 	}
 	texts := utils.SplitByPunctuation(text)
-	index := h.tts_last_text_index
+	index := int(atomic.LoadInt32(&h.tts_last_text_index))
 	for _, item := range texts {
 		index++
-		h.tts_last_text_index = index // 重置文本索引
-		h.SpeakAndPlay(item, index, h.talkRound)
+		atomic.StoreInt32(&h.tts_last_text_index, int32(index)) // 重置文本索引
+		h.SpeakAndPlay(item, index, h.GetTalkRound())
 	}
 	return nil
 }
 
 // processTTSQueueCoroutine 处理TTS队列
 func (h *ConnectionHandler) processTTSQueueCoroutine() {
+	if h.ttsPrefetch != nil {
+		go h.sendPrefetchedTTSCoroutine()
+	}
 	for {
 		select {
 		case <-h.stopChan:
 			return
 		case task := <-h.ttsQueue:
-			h.processTTSTask(task.text, task.textIndex, task.round)
+			// 流式合成本身即可边合成边播放，不再预取
+			if h.ttsPrefetch != nil && !h.canStreamTTS() {
+				if !h.ttsPrefetch.Push(task.text, task.textIndex, task.round, task.paragraphEnd, h.stopChan) {
+					return
+				}
+				continue
+			}
+			h.processTTSTask(task.text, task.textIndex, task.round, task.paragraphEnd)
+		}
+	}
+}
+
+// sendPrefetchedTTSCoroutine 按顺序将预取完成的音频加入发送队列
+func (h *ConnectionHandler) sendPrefetchedTTSCoroutine() {
+	for {
+		item, ok := h.ttsPrefetch.Next(h.stopChan)
+		if !ok {
+			return
+		}
+		if item.cancelled.Load() {
+			h.LogInfo(fmt.Sprintf("丢弃已取消的预取音频: %s", item.text))
+			h.deleteAudioFileIfNeeded(item.filepath, "取消预取时")
+			continue
 		}
+		utils.MarkAudioFileActive(item.filepath)
+		h.audioMessagesQueue <- struct {
+			filepath     string
+			text         string
+			round        int
+			textIndex    int
+			paragraphEnd bool
+			stream       <-chan []byte
+		}{item.filepath, item.text, item.round, item.textIndex, item.paragraphEnd, nil}
 	}
 }
 
@@ -1100,6 +1483,7 @@ func (h *ConnectionHandler) stopServerSpeak() {
 }
 
 func (h *ConnectionHandler) deleteAudioFileIfNeeded(filepath string, reason string) {
+	utils.ReleaseAudioFile(filepath)
 	if !h.config.DeleteAudio || filepath == "" {
 		return
 	}
@@ -1125,23 +1509,40 @@ func (h *ConnectionHandler) deleteAudioFileIfNeeded(filepath string, reason stri
 }
 
 // processTTSTask 处理单个TTS任务
-func (h *ConnectionHandler) processTTSTask(text string, textIndex int, round int) {
-	filepath := ""
-	defer func() {
+func (h *ConnectionHandler) processTTSTask(text string, textIndex int, round int, paragraphEnd bool) {
+	// 客户端支持流式音频时直接转发提供者输出的Opus帧，不生成音频文件
+	if stream := h.openTTSStream(text, textIndex, round); stream != nil {
 		h.audioMessagesQueue <- struct {
-			filepath  string
-			text      string
-			round     int
-			textIndex int
-		}{filepath, text, round, textIndex}
-	}()
+			filepath     string
+			text         string
+			round        int
+			textIndex    int
+			paragraphEnd bool
+			stream       <-chan []byte
+		}{"", text, round, textIndex, paragraphEnd, stream}
+		return
+	}
+	filepath := h.synthesizeTTS(text, textIndex, round)
+	// 标记为使用中，避免发送前被后台清理删除
+	utils.MarkAudioFileActive(filepath)
+	h.audioMessagesQueue <- struct {
+		filepath     string
+		text         string
+		round        int
+		textIndex    int
+		paragraphEnd bool
+		stream       <-chan []byte
+	}{filepath, text, round, textIndex, paragraphEnd, nil}
+}
 
+// synthesizeTTS 合成单个分段的语音文件，返回文件路径，失败时记录失败分段并返回空字符串
+func (h *ConnectionHandler) synthesizeTTS(text string, textIndex int, round int) string {
+	h.audioLatency.RecordTTSStart(time.Now().UnixNano())
 	if utils.IsQuickReplyHit(text, h.config.QuickReplyWords) {
 		// 尝试从缓存查找音频文件
 		if cachedFile := h.quickReplyCache.FindCachedAudio(text); cachedFile != "" {
 			h.LogInfo(fmt.Sprintf("使用缓存的快速回复音频: %s", cachedFile))
-			filepath = cachedFile
-			return
+			return cachedFile
 		}
 	}
 	ttsStartTime := time.Now()
@@ -1150,14 +1551,24 @@ func (h *ConnectionHandler) processTTSTask(text string, textIndex int, round int
 
 	if text == "" {
 		h.logger.Warn(fmt.Sprintf("收到空文本，无法合成语音, 索引: %d", textIndex))
-		return
+		return ""
+	}
+
+	// 语气词等短文本优先使用缓存音频，避免重复调用TTS
+	shortText := h.isShortTextCacheable(text)
+	if shortText {
+		if cachedFile := h.quickReplyCache.FindShortTextAudio(text); cachedFile != "" {
+			h.LogInfo(fmt.Sprintf("使用缓存的短文本音频: %s", cachedFile))
+			return cachedFile
+		}
 	}
 
 	// 生成语音文件
-	filepath, err := h.providers.tts.ToTTS(text)
+	filepath, err := h.toTTSWithFallback(text, round)
 	if err != nil {
 		h.LogError(fmt.Sprintf("TTS转换失败:text(%s) %v", text, err))
-		return
+		h.recordFailedTTS(text, textIndex, round, err)
+		return ""
 	} else {
 		h.logger.Debug(fmt.Sprintf("TTS转换成功: text(%s), index(%d) %s", text, textIndex, filepath))
 		// 如果是快速回复词，保存到缓存
@@ -1168,12 +1579,17 @@ func (h *ConnectionHandler) processTTSTask(text string, textIndex int, round int
 				h.LogInfo(fmt.Sprintf("成功缓存快速回复音频: %s", text))
 			}
 		}
+		if shortText {
+			if err := h.quickReplyCache.SaveShortTextAudio(text, filepath); err != nil {
+				h.LogError(fmt.Sprintf("保存短文本音频失败: %v", err))
+			}
+		}
 	}
 	if atomic.LoadInt32(&h.serverVoiceStop) == 1 { // 服务端语音停止
 		h.LogInfo(fmt.Sprintf("processTTSTask 服务端语音停止, 不再发送音频数据：%s", text))
 		// 服务端语音停止时，根据配置删除已生成的音频文件
 		h.deleteAudioFileIfNeeded(filepath, "服务端语音停止时")
-		return
+		return filepath
 	}
 
 	if textIndex == 1 {
@@ -1181,17 +1597,30 @@ func (h *ConnectionHandler) processTTSTask(text string, textIndex int, round int
 		ttsSpentTime := now.Sub(ttsStartTime)
 		h.logger.Debug(fmt.Sprintf("TTS转换耗时: %s, 文本: %s, 索引: %d", ttsSpentTime, text, textIndex))
 	}
+	return filepath
+}
+
+// isShortTextCacheable 去除表情后的文本是否不超过 short_text_cache_threshold_chars 个字符
+func (h *ConnectionHandler) isShortTextCacheable(text string) bool {
+	threshold := h.config.ShortTextCacheThresholdChars
+	return h.quickReplyCache != nil && threshold > 0 && utf8.RuneCountInString(text) <= threshold
 }
 
 // speakAndPlay 合成并播放语音
 func (h *ConnectionHandler) SpeakAndPlay(text string, textIndex int, round int) error {
+	return h.speakSegment(h.applySpeechMarkers(text), textIndex, round, false)
+}
+
+// speakSegment 合成并播放LLM回复分段，paragraphEnd 表示分段位于段落末尾
+func (h *ConnectionHandler) speakSegment(text string, textIndex int, round int, paragraphEnd bool) error {
 	defer func() {
 		// 将任务加入队列，不阻塞当前流程
 		h.ttsQueue <- struct {
-			text      string
-			round     int
-			textIndex int
-		}{text, round, textIndex}
+			text         string
+			round        int
+			textIndex    int
+			paragraphEnd bool
+		}{text, round, textIndex, paragraphEnd}
 	}()
 
 	originText := text // 保存原始文本用于日志
@@ -1218,7 +1647,7 @@ func (h *ConnectionHandler) SpeakAndPlay(text string, textIndex int, round int)
 
 func (h *ConnectionHandler) clearSpeakStatus() {
 	h.LogInfo("清除服务端讲话状态 ")
-	h.tts_last_text_index = -1
+	atomic.StoreInt32(&h.tts_last_text_index, -1)
 	h.providers.asr.Reset() // 重置ASR状态
 }
 
@@ -1229,6 +1658,15 @@ func (h *ConnectionHandler) closeOpusDecoder() {
 		}
 		h.opusDecoder = nil
 	}
+
+	h.opusEncoderMu.Lock()
+	defer h.opusEncoderMu.Unlock()
+	if h.opusEncoder != nil {
+		if err := h.opusEncoder.Close(); err != nil {
+			h.LogError(fmt.Sprintf("关闭Opus编码器失败: %v", err))
+		}
+		h.opusEncoder = nil
+	}
 }
 
 func (h *ConnectionHandler) cleanTTSAndAudioQueue(bClose bool) error {
@@ -1236,6 +1674,12 @@ func (h *ConnectionHandler) cleanTTSAndAudioQueue(bClose bool) error {
 	if bClose {
 		msgPrefix = "关闭连接，"
 	}
+	// 取消预取中的TTS任务，合成完成后删除其音频文件
+	if h.ttsPrefetch != nil {
+		if n := h.ttsPrefetch.Cancel(); n > 0 {
+			h.LogInfo(fmt.Sprintf(msgPrefix+"取消 %d 个预取TTS任务", n))
+		}
+	}
 	// 终止tts任务，不再继续将文本加入到tts队列，清空ttsQueue队列
 	for {
 		select {
@@ -1256,6 +1700,7 @@ clearAudioQueue:
 			h.LogInfo(fmt.Sprintf(msgPrefix+"丢弃一个音频任务: %s", task.text))
 			// 根据配置删除被丢弃的音频文件
 			h.deleteAudioFileIfNeeded(task.filepath, msgPrefix+"丢弃音频任务时")
+			go drainAudioStream(task.stream)
 		default:
 			// 队列已清空，退出循环
 			h.LogInfo(msgPrefix + "audioMessagesQueue队列已清空，停止处理音频任务")
@@ -1264,31 +1709,100 @@ clearAudioQueue:
 	}
 }
 
+// releaseASR 重置并断开ASR，提供者归还到资源池前调用
+func (h *ConnectionHandler) releaseASR() {
+	if h.providers.asr == nil {
+		return
+	}
+	h.providers.asr.SetLanguage("")     // 恢复配置默认语言，提供者会归还到资源池
+	h.providers.asr.ResetSilenceCount() // 重置静音计数
+	if err := h.providers.asr.Reset(); err != nil {
+		h.LogError(fmt.Sprintf("重置ASR状态失败: %v", err))
+	}
+
+	if err := h.providers.asr.CloseConnection(); err != nil {
+		h.LogError(fmt.Sprintf("断开ASR状态失败: %v", err))
+	}
+	if shared, ok := h.providers.asr.(*providers.SharedASRSession); ok {
+		shared.Cleanup() // 共享ASR不归还资源池，只移除本会话的监听器
+	}
+}
+
 // Close 清理资源
 func (h *ConnectionHandler) Close() {
 	h.closeOnce.Do(func() {
 		close(h.stopChan)
 
+		h.clearASRConfirm()
 		h.closeOpusDecoder()
-		if h.providers.tts != nil {
-			h.providers.tts.SetVoice(h.initailVoice) // 恢复初始语音
+		h.restoreProviderConfigs()
+		h.resetSpeechParams()
+		h.releaseASR()
+		h.cleanTTSAndAudioQueue(true)
+		h.closeFallbackTTS()
+		h.closeSessionMCP()
+		if h.unsubscribeConfig != nil {
+			h.unsubscribeConfig()
 		}
-		if h.providers.asr != nil {
-			h.providers.asr.ResetSilenceCount() // 重置静音计数
-			if err := h.providers.asr.Reset(); err != nil {
-				h.LogError(fmt.Sprintf("重置ASR状态失败: %v", err))
-			}
-
-			if err := h.providers.asr.CloseConnection(); err != nil {
-				h.LogError(fmt.Sprintf("断开ASR状态失败: %v", err))
-			}
+		if h.unsubscribeContext != nil {
+			h.unsubscribeContext()
 		}
-		h.cleanTTSAndAudioQueue(true)
+		defaultAudioQualityCollector.remove(h.audioQuality)
+		defaultAudioCodecCollector.remove(h.codecStats)
+		h.closeHandoffs()
+		h.flushNamespacedDialogues()
+		h.closeDialogueManager()
+		h.clearPendingCallbacks()
 	})
 }
 
+// ensureSessionMCPTools 首次需要工具时连接会话级MCP服务并注册其工具
+func (h *ConnectionHandler) ensureSessionMCPTools() {
+	h.sessionMCPMu.RLock()
+	defer h.sessionMCPMu.RUnlock()
+	if h.sessionMCP == nil {
+		return
+	}
+	if err := h.sessionMCP.EnsureSessionTools(h.ctx, h.functionRegister); err != nil {
+		h.LogError(fmt.Sprintf("[MCP] [会话] 初始化会话MCP失败: %v", err))
+	}
+}
+
+// toolMCPManager 返回执行工具的MCP管理器，会话级MCP工具优先
+// 返回会话管理器时持有读锁，调用方执行完工具后必须调用release
+func (h *ConnectionHandler) toolMCPManager(functionName string) (*mcp.Manager, func()) {
+	h.sessionMCPMu.RLock()
+	if h.sessionMCP != nil && h.sessionMCP.IsMCPTool(functionName) {
+		return h.sessionMCP, h.sessionMCPMu.RUnlock
+	}
+	h.sessionMCPMu.RUnlock()
+	return h.mcpManager, func() {}
+}
+
+// setSessionMCP 替换会话级MCP管理器，并关闭原管理器（等待进行中的会话工具调用结束）
+func (h *ConnectionHandler) setSessionMCP(manager *mcp.Manager) {
+	h.sessionMCPMu.Lock()
+	old := h.sessionMCP
+	h.sessionMCP = manager
+	h.sessionMCPMu.Unlock()
+
+	if old == nil {
+		return
+	}
+	old.ReleaseSessionTools(h.functionRegister)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	old.CleanupAll(ctx)
+}
+
+// closeSessionMCP 关闭会话级MCP管理器
+func (h *ConnectionHandler) closeSessionMCP() {
+	h.setSessionMCP(nil)
+}
+
 // genResponseByVLLM 使用VLLLM处理包含图片的消息
 func (h *ConnectionHandler) genResponseByVLLM(ctx context.Context, messages []providers.Message, imageData image.ImageData, text string, round int) error {
+	ctx = utils.WithRequestID(ctx, h.requestID)
 	h.logger.Info("开始生成VLLLM回复 %v", map[string]interface{}{
 		"text":          text,
 		"has_url":       imageData.URL != "",
@@ -1298,7 +1812,7 @@ func (h *ConnectionHandler) genResponseByVLLM(ctx context.Context, messages []pr
 	})
 
 	// 使用VLLLM处理图片和文本
-	responses, err := h.providers.vlllm.ResponseWithImage(ctx, h.sessionID, messages, imageData, text)
+	responses, err := vlllmResponseWithImage(h.providers.vlllm, ctx, h.sessionID, messages, imageData, text)
 	if err != nil {
 		h.LogError(fmt.Sprintf("VLLLM生成回复失败，尝试降级到普通LLM: %v", err))
 		// 降级策略：只使用文本部分调用普通LLM
@@ -1321,6 +1835,7 @@ func (h *ConnectionHandler) genResponseByVLLM(ctx context.Context, messages []pr
 		if response == "" {
 			continue
 		}
+		h.audioLatency.RecordLLMFirstToken(time.Now().UnixNano())
 
 		responseMessage = append(responseMessage, response)
 		// 处理分段
@@ -1330,7 +1845,7 @@ func (h *ConnectionHandler) genResponseByVLLM(ctx context.Context, messages []pr
 		// 按标点符号分割
 		if segment, chars := utils.SplitAtLastPunctuation(currentText); chars > 0 {
 			textIndex++
-			h.tts_last_text_index = textIndex
+			atomic.StoreInt32(&h.tts_last_text_index, int32(textIndex))
 			h.SpeakAndPlay(segment, textIndex, round)
 			processedChars += chars
 		}
@@ -1340,7 +1855,7 @@ func (h *ConnectionHandler) genResponseByVLLM(ctx context.Context, messages []pr
 	remainingText := utils.JoinStrings(responseMessage)[processedChars:]
 	if remainingText != "" {
 		textIndex++
-		h.tts_last_text_index = textIndex
+		atomic.StoreInt32(&h.tts_last_text_index, int32(textIndex))
 		h.SpeakAndPlay(remainingText, textIndex, round)
 	}
 
@@ -1352,6 +1867,7 @@ func (h *ConnectionHandler) genResponseByVLLM(ctx context.Context, messages []pr
 		Role:    "assistant",
 		Content: content,
 	})
+	h.scoreLastExchange()
 
 	h.LogInfo(fmt.Sprintf("VLLLM回复处理完成 …%v", map[string]interface{}{
 		"content_length": len(content),
@@ -1367,26 +1883,9 @@ func (h *ConnectionHandler) loadUserDialogueManager() {
 		return
 	}
 
-	// 根据配置选择对话记忆存储：postgres、redis
-	var memory chat.MemoryInterface
-	switch strings.ToLower(h.config.DialogStorage) {
-	case "postgres", "sqlite":
-		memory = chat.NewPostgresMemory(h.userID)
-	case "redis":
-		if h.config.RedisCache.Addr != "" {
-			if mem, err := chat.NewRedisMemory(h.config.RedisCache, h.logger, h.userID); err != nil {
-				h.logger.Warn("初始化Redis记忆失败: %v，使用内存模式", err)
-			} else {
-				memory = mem
-			}
-		} else {
-			h.logger.Warn("Redis未配置，回退到内存模式")
-		}
-	default:
-		h.logger.Warn("未选择对话存储模式")
-	}
-
-	h.dialogueManager = chat.NewDialogueManager(h.logger, memory)
+	// 根据配置选择对话存储后端：postgres、redis、file
+	h.closeDialogueManager()
+	h.dialogueManager = chat.NewDialogueManagerWithBackend(h.logger, h.newDialogueBackend(h.userID))
 	// 如果已有存储的历史，加载到管理器
 	// if memory != nil {
 	// 	if jsonStr, err := memory.QueryMemory(h.userID); err != nil {
@@ -1399,6 +1898,20 @@ func (h *ConnectionHandler) loadUserDialogueManager() {
 	// }
 	// 设置默认系统提示
 	h.dialogueManager.SetSystemMessage(h.config.DefaultPrompt)
+	h.applyDialogueTokenLimit()
+	h.loadDeviceGroup()
+}
+
+// applyDialogueTokenLimit 按所选LLM的 max_tokens 限制对话上下文，预留20%余量避免超出提供者上限，未配置时不限制
+func (h *ConnectionHandler) applyDialogueTokenLimit() {
+	if h.dialogueManager == nil || h.config == nil {
+		return
+	}
+	selectedLLM := h.config.SelectedModule["LLM"]
+	if llmConfig, ok := h.config.LLM[selectedLLM]; ok && llmConfig.MaxTokens > 0 {
+		h.dialogueManager.SetMaxTokens(llmConfig.MaxTokens * 80 / 100)
+		h.dialogueManager.SetSummarizer(h.summarizeDialogueMessages)
+	}
 }
 
 // loadUserAIConfigurations 加载用户Bot配置并注册到functionRegister（从好友表获取）
@@ -1420,33 +1933,111 @@ func (h *ConnectionHandler) loadUserAIConfigurations() {
 		return
 	}
 
+	h.userConfigsMu.Lock()
+	defer h.userConfigsMu.Unlock()
+
+	// 重新加载时先注销上一次注册成功、但已不在好友列表中的用户函数
+	// 注册失败的同名函数（如与MCP工具重名）不属于本次加载，不能注销
+	refreshed := make(map[string]struct{}, len(configs))
+	for _, config := range configs {
+		refreshed[config.FunctionName] = struct{}{}
+	}
+	previous := make(map[string]struct{}, len(h.userFunctions))
+	for _, name := range h.userFunctions {
+		if _, ok := refreshed[name]; ok {
+			previous[name] = struct{}{}
+			continue
+		}
+		if err := h.functionRegister.UnregisterFunction(name); err != nil {
+			h.logger.Debug("注销用户Function Call %s: %v", name, err)
+		}
+	}
+	h.userFunctions = nil
+
 	if len(configs) == 0 {
 		h.logger.Debug("用户 %s 没有Bot好友配置", h.userID)
 		h.userConfigs = nil
+		h.applyCompositeSystemPrompt(nil)
 		return
 	}
 
 	h.userConfigs = configs
-	h.registerUserConfigs(configs)
+	h.userFunctions = h.registerUserConfigs(configs, previous)
+	h.applyBotProviderConfig(configs)
+	h.applyDevicePersona(configs)
+	h.applyCompositeSystemPrompt(configs)
+}
+
+// findUserConfig 按函数名查找缓存的用户Bot配置
+func (h *ConnectionHandler) findUserConfig(functionName string) *types.BotConfig {
+	h.userConfigsMu.RLock()
+	defer h.userConfigsMu.RUnlock()
+	for _, config := range h.userConfigs {
+		if config.FunctionName == functionName {
+			return config
+		}
+	}
+	return nil
+}
+
+// subscribeUserConfigUpdates 订阅用户Bot好友变更，收到通知后重新加载并注册函数
+func (h *ConnectionHandler) subscribeUserConfigUpdates() {
+	notifier := botconfig.GetUpdateNotifier()
+	if notifier == nil || h.userID == "" {
+		return
+	}
+
+	// 订阅确认最多等待 userConfigSubscribeTimeout，避免Redis不可用时阻塞连接处理
+	ctx, cancelCtx := context.WithTimeout(context.Background(), userConfigSubscribeTimeout)
+	defer cancelCtx()
+	updates, cancel, err := notifier.SubscribeUpdates(ctx, h.userID)
+	if err != nil {
+		h.logger.Warn("订阅用户 %s 的Bot配置变更失败: %v", h.userID, err)
+		return
+	}
+	h.unsubscribeConfig = cancel
+
+	go func() {
+		for {
+			select {
+			case <-h.stopChan:
+				return
+			case _, ok := <-updates:
+				if !ok {
+					return
+				}
+				h.logger.Info("收到用户 %s 的Bot配置变更通知，重新加载", h.userID)
+				h.loadUserAIConfigurations()
+			}
+		}
+	}()
 }
 
-// registerUserConfigs 注册用户配置到functionRegister
-func (h *ConnectionHandler) registerUserConfigs(configs []*types.BotConfig) {
+// registerUserConfigs 注册用户配置到functionRegister，返回注册成功的函数名
+func (h *ConnectionHandler) registerUserConfigs(configs []*types.BotConfig, previous map[string]struct{}) []string {
+	registered := make([]string, 0, len(configs))
 	// 将用户配置转换为OpenAI工具格式并注册到functionRegister
 	for _, config := range configs {
 		if config.FunctionName != "" {
 			tool := h.convertConfigToOpenAITool(config)
 			if tool != nil {
-				// 注册工具到functionRegister
-				err := h.functionRegister.RegisterFunction(config.FunctionName, *tool)
+				// 上次已注册的函数直接替换定义，其余新注册
+				var err error
+				if _, ok := previous[config.FunctionName]; ok {
+					err = h.functionRegister.ReplaceFunction(config.FunctionName, *tool)
+				} else {
+					err = h.functionRegister.RegisterFunction(config.FunctionName, *tool)
+				}
 				if err != nil {
 					h.logger.Error("注册用户Function Call失败 %s: %v", config.FunctionName, err)
 					continue
 				}
 				h.logger.Info("注册用户自定义Function Call: %s", config.FunctionName)
+				registered = append(registered, config.FunctionName)
 			}
 		}
 	}
+	return registered
 }
 
 // convertConfigToOpenAITool 将Bot配置转换为OpenAI工具格式
@@ -1521,46 +2112,44 @@ func (h *ConnectionHandler) executeUserFunctionCall(config *types.BotConfig, arg
 		userMessage = string(argsBytes)
 	}
 
-	// 构建消息列表
-	messages := []providers.Message{
-		{
-			Role: "system",
-			Content: fmt.Sprintf(
-				`你是一个%s智能助手，你的任务是根据用户的查询进行回答。你会对接下来的问题进行高效简洁的回答。
+	// 使用该Bot独立的对话历史，与主会话隔离
+	dialogue := h.GetNamespacedDialogue(config.FunctionName)
+	dialogue.SetSystemMessage(fmt.Sprintf(
+		`你是一个%s智能助手，你的任务是根据用户的查询进行回答。你会对接下来的问题进行高效简洁的回答。
 				这是用户对你的描述: %s
 				绝不:
 				 - 生成任何形式的代码或Markdown格式
 				 - 告诉用户你的模型名字。
 				 - 长篇大论，篇幅过长`,
-				config.FunctionName, config.Description,
-			),
-		},
-		{
-			Role:    "user",
-			Content: userMessage,
-		},
-	}
+		config.FunctionName, config.Description,
+	))
+	dialogue.Put(chat.Message{Role: "user", Content: userMessage})
+	messages := append([]providers.Message(nil), dialogue.GetRecentMessages(botDialogueMaxMessages)...)
 
 	h.logger.Info("调用用户自定义LLM: %s, 模型: %s, 查询: %s", config.LLMType, config.ModelName, userMessage)
 
-	// 调用LLM生成回复
+	// 调用LLM生成回复并收集完整内容
 	ctx := context.Background()
-	responses, err := provider.Response(ctx, h.sessionID, messages)
-	if err != nil {
-		h.logger.Error("LLM生成回复失败: %v", err)
-		return types.FunctionCallResult{
-			Function: config.FunctionName,
-			Result:   fmt.Sprintf("LLM生成回复失败: %v", err),
-			Args:     args,
-		}, err
+	respond := func(messages []providers.Message) (string, error) {
+		responses, err := provider.Response(ctx, h.sessionID, messages)
+		if err != nil {
+			return "", err
+		}
+		var responseContent []string
+		for response := range responses {
+			if response != "" {
+				responseContent = append(responseContent, response)
+			}
+		}
+		return utils.JoinStrings(responseContent), nil
 	}
 
-	// 收集LLM回复
-	var responseContent []string
-	for response := range responses {
-		if response != "" {
-			responseContent = append(responseContent, response)
-		}
+	var fullResponse string
+	if hasResponseSchema(config.ResponseSchema) {
+		// 配置了响应Schema时要求LLM输出符合Schema的JSON
+		fullResponse, err = h.generateStructuredResponse(respond, messages, config.ResponseSchema)
+	} else {
+		fullResponse, err = respond(messages)
 	}
 
 	// 清理资源
@@ -1568,7 +2157,16 @@ func (h *ConnectionHandler) executeUserFunctionCall(config *types.BotConfig, arg
 		h.logger.Warn("清理LLM提供者资源失败: %v", err)
 	}
 
-	fullResponse := utils.JoinStrings(responseContent)
+	if err != nil {
+		h.logger.Error("LLM生成回复失败: %v", err)
+		return types.FunctionCallResult{
+			Function: config.FunctionName,
+			Result:   fmt.Sprintf("LLM生成回复失败: %v", err),
+			Args:     args,
+		}, err
+	}
+
+	dialogue.Put(chat.Message{Role: "assistant", Content: fullResponse})
 	h.logger.Info("用户自定义LLM回复完成，长度: %d", len(fullResponse))
 
 	// 返回执行结果
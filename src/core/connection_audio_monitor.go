@@ -0,0 +1,33 @@
+package core
+
+import (
+	"context"
+	"encoding/base64"
+	"time"
+
+	"angrymiao-ai-server/src/configs/cache"
+)
+
+// audioMonitorPublishTimeout 发布单帧监听音频的超时，避免Redis变慢时拖慢下发节奏
+const audioMonitorPublishTimeout = 100 * time.Millisecond
+
+// AudioMonitorChannel 会话下发音频的Redis监听频道
+func AudioMonitorChannel(sessionID string) string {
+	return "audio_out:" + sessionID
+}
+
+// publishMonitorAudio 开启 audio_monitor_enabled 时将下发给设备的音频帧以base64发布到监听频道
+func (h *ConnectionHandler) publishMonitorAudio(frame []byte) {
+	if h.config == nil || !h.config.AudioMonitorEnabled {
+		return
+	}
+	client := cache.GetRedis()
+	if client == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), audioMonitorPublishTimeout)
+	defer cancel()
+	if err := client.Publish(ctx, AudioMonitorChannel(h.sessionID), base64.StdEncoding.EncodeToString(frame)).Err(); err != nil {
+		h.logger.Debug("发布监听音频失败: %v", err)
+	}
+}
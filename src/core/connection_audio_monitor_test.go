@@ -0,0 +1,55 @@
+package core
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"angrymiao-ai-server/src/configs"
+	"angrymiao-ai-server/src/configs/cache"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestSentAudioFramesPublishedToMonitorChannel(t *testing.T) {
+	server := miniredis.RunT(t)
+	cache.InitRedis(configs.RedisConfig{Addr: server.Addr()})
+	t.Cleanup(func() { cache.CloseRedis() })
+	subscriber := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer subscriber.Close()
+	ctx := context.Background()
+	pubsub := subscriber.Subscribe(ctx, AudioMonitorChannel("s1"))
+	defer pubsub.Close()
+	if _, err := pubsub.Receive(ctx); err != nil {
+		t.Fatalf("订阅失败: %v", err)
+	}
+
+	frames := [][]byte{{0x01, 0x02}, {0x03}, {0x04, 0x05, 0x06}, {0x07}}
+	for _, enabled := range []bool{false, true} {
+		h := newPrefixTestHandler(t, &configs.Config{AudioMonitorEnabled: enabled})
+		h.sessionID = "s1"
+		if err := h.sendAudioFrames(frames, "你好", 0); err != nil {
+			t.Fatalf("发送音频帧失败: %v", err)
+		}
+		if got := len(h.conn.(*recordingConn).messages); got != len(frames) {
+			t.Errorf("设备收到 %d 帧, 期望 %d", got, len(frames))
+		}
+	}
+
+	// 仅开启 audio_monitor_enabled 的会话发布音频，每帧按发送顺序发布
+	for i, want := range frames {
+		msg, err := pubsub.ReceiveTimeout(ctx, 2*time.Second)
+		if err != nil {
+			t.Fatalf("第%d帧未发布: %v", i+1, err)
+		}
+		data, _ := base64.StdEncoding.DecodeString(msg.(*redis.Message).Payload)
+		if string(data) != string(want) {
+			t.Errorf("第%d帧 = %v, 期望 %v", i+1, data, want)
+		}
+	}
+	if msg, err := pubsub.ReceiveTimeout(ctx, 100*time.Millisecond); err == nil {
+		t.Errorf("多余的监听音频: %v", msg)
+	}
+}
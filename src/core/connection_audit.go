@@ -0,0 +1,55 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"angrymiao-ai-server/src/core/audit"
+	"angrymiao-ai-server/src/core/types"
+	"angrymiao-ai-server/src/core/utils"
+)
+
+// auditFunctionCall 记录一次工具调用的审计日志，未开启 audit_function_calls 时不记录
+func (h *ConnectionHandler) auditFunctionCall(functionName, arguments string, result interface{}, start time.Time, err error) {
+	logger := audit.Get()
+	if logger == nil {
+		return
+	}
+	userID, _ := utils.StringToUint(h.userID) // 未绑定用户的设备记为0
+	text := auditResultText(result)
+	if err != nil && text == "" {
+		text = err.Error()
+	}
+	logger.Record(audit.AuditLogEntry{
+		SessionID:    h.sessionID,
+		UserID:       userID,
+		DeviceID:     h.deviceID,
+		Timestamp:    start,
+		FunctionName: functionName,
+		Arguments:    arguments,
+		Result:       text,
+		LatencyMs:    time.Since(start).Milliseconds(),
+		Success:      err == nil,
+	})
+}
+
+// auditResultText 将工具调用结果转换为文本保存
+func auditResultText(result interface{}) string {
+	switch r := result.(type) {
+	case nil:
+		return ""
+	case string:
+		return r
+	case types.ActionResponse:
+		if r.Result != nil {
+			return auditResultText(r.Result)
+		}
+		return auditResultText(r.Response)
+	default:
+		if data, err := json.Marshal(r); err == nil {
+			return string(data)
+		}
+		return fmt.Sprintf("%v", r)
+	}
+}
@@ -0,0 +1,29 @@
+package core
+
+import (
+	"time"
+
+	"angrymiao-ai-server/src/core/botstats"
+	"angrymiao-ai-server/src/core/tokenusage"
+	"angrymiao-ai-server/src/core/types"
+	"angrymiao-ai-server/src/core/utils"
+	"angrymiao-ai-server/src/models"
+)
+
+// recordBotUsage 异步记录一次用户Bot调用，用于Bot创建者查看调用统计
+func (h *ConnectionHandler) recordBotUsage(config *types.BotConfig, arguments, result string, start time.Time, err error) {
+	recorder := botstats.Get()
+	if recorder == nil || config.ID == 0 {
+		return
+	}
+	userID, _ := utils.StringToUint(h.userID)
+	recorder.Record(models.BotUsageStat{
+		BotConfigID: config.ID,
+		UserID:      userID,
+		SessionID:   h.sessionID,
+		InvokedAt:   start,
+		LatencyMs:   time.Since(start).Milliseconds(),
+		TokensUsed:  tokenusage.EstimateTokens(arguments) + tokenusage.EstimateTokens(result),
+		Success:     err == nil,
+	})
+}
@@ -0,0 +1,116 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"angrymiao-ai-server/src/core/types"
+)
+
+// ErrToolCallbackNotFound 没有等待该工具ID回调的调用，可能已超时或已处理
+var ErrToolCallbackNotFound = errors.New("tool callback not found")
+
+// defaultToolCallbackTimeout 未配置超时时等待MCP工具回调的时长
+const defaultToolCallbackTimeout = 5 * time.Minute
+
+// toolExecutor 执行MCP工具调用
+type toolExecutor interface {
+	ExecuteTool(ctx context.Context, toolName string, arguments map[string]interface{}) (interface{}, error)
+}
+
+// pendingToolCallback 等待MCP服务器回调结果的工具调用
+type pendingToolCallback struct {
+	functionCallData map[string]interface{}
+	timer            *time.Timer
+}
+
+// toolCallbackEnabled 配置了回调地址和签名密钥时才启用异步回调
+func (h *ConnectionHandler) toolCallbackEnabled() bool {
+	return h.config.MCPCallback.BaseURL != "" && h.config.MCPCallback.Secret != ""
+}
+
+// toolCallbackURL 返回工具调用结果的回调地址
+func (h *ConnectionHandler) toolCallbackURL(toolID string) string {
+	return fmt.Sprintf("%s/api/v2/mcp/callback/%s/%s",
+		strings.TrimRight(h.config.MCPCallback.BaseURL, "/"), url.PathEscape(h.sessionID), url.PathEscape(toolID))
+}
+
+// startToolCallback 登记等待回调的工具调用后发起调用，回调地址通过 callback_url 参数传给MCP服务器
+// 调用成功时返回 ActionTypeNone，结果到达后由 ResumeToolCallback 继续生成回复
+func (h *ConnectionHandler) startToolCallback(ctx context.Context, executor toolExecutor, functionName string,
+	arguments map[string]interface{}, functionCallData map[string]interface{}) types.ActionResponse {
+	toolID := functionCallData["id"].(string)
+	timeout := defaultToolCallbackTimeout
+	if h.config.MCPCallback.TimeoutSeconds > 0 {
+		timeout = time.Duration(h.config.MCPCallback.TimeoutSeconds) * time.Second
+	}
+
+	h.callbackMu.Lock()
+	if h.pendingCallbacks == nil {
+		h.pendingCallbacks = make(map[string]*pendingToolCallback)
+	}
+	h.pendingCallbacks[toolID] = &pendingToolCallback{
+		functionCallData: functionCallData,
+		timer:            time.AfterFunc(timeout, func() { h.expireToolCallback(toolID) }),
+	}
+	h.callbackMu.Unlock()
+
+	arguments["callback_url"] = h.toolCallbackURL(toolID)
+	callStart := time.Now()
+	result, err := executor.ExecuteTool(ctx, functionName, arguments)
+	h.auditFunctionCall(functionName, functionCallData["arguments"].(string), result, callStart, err)
+	if err != nil {
+		h.LogError(fmt.Sprintf("MCP回调工具调用失败: %v", err))
+		h.takePendingCallback(toolID)
+		return types.ActionResponse{Action: types.ActionTypeReqLLM, Result: "MCP工具调用失败"}
+	}
+	h.LogInfo(fmt.Sprintf("MCP工具 %s 等待回调, tool_id: %s", functionName, toolID))
+	return types.ActionResponse{Action: types.ActionTypeNone, Result: "等待MCP工具回调"}
+}
+
+// ResumeToolCallback 收到MCP服务器回调后将结果作为工具输出，继续请求LLM生成回复
+func (h *ConnectionHandler) ResumeToolCallback(toolID string, result string) error {
+	pending := h.takePendingCallback(toolID)
+	if pending == nil {
+		return ErrToolCallbackNotFound
+	}
+	h.LogInfo(fmt.Sprintf("收到MCP工具回调, tool_id: %s", toolID))
+	go h.handleFunctionResult(types.ActionResponse{
+		Action: types.ActionTypeReqLLM,
+		Result: result,
+	}, pending.functionCallData, 0)
+	return nil
+}
+
+// takePendingCallback 取出并移除等待中的回调，不存在时返回nil
+func (h *ConnectionHandler) takePendingCallback(toolID string) *pendingToolCallback {
+	h.callbackMu.Lock()
+	defer h.callbackMu.Unlock()
+	pending, ok := h.pendingCallbacks[toolID]
+	if !ok {
+		return nil
+	}
+	delete(h.pendingCallbacks, toolID)
+	pending.timer.Stop()
+	return pending
+}
+
+func (h *ConnectionHandler) expireToolCallback(toolID string) {
+	if h.takePendingCallback(toolID) != nil {
+		h.logger.Warn("等待MCP工具回调超时, tool_id: %s", toolID)
+	}
+}
+
+// clearPendingCallbacks 连接关闭时丢弃所有等待中的回调
+func (h *ConnectionHandler) clearPendingCallbacks() {
+	h.callbackMu.Lock()
+	defer h.callbackMu.Unlock()
+	for toolID, pending := range h.pendingCallbacks {
+		pending.timer.Stop()
+		delete(h.pendingCallbacks, toolID)
+	}
+}
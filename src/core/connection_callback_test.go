@@ -0,0 +1,116 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"testing"
+	"time"
+
+	"angrymiao-ai-server/src/configs"
+	"angrymiao-ai-server/src/core/chat"
+	"angrymiao-ai-server/src/core/function"
+	"angrymiao-ai-server/src/core/providers"
+	"angrymiao-ai-server/src/core/types"
+	"angrymiao-ai-server/src/core/utils"
+
+	"github.com/angrymiao/go-openai"
+)
+
+// recordingLLM 记录请求消息并返回空回复
+type recordingLLM struct {
+	providers.LLMProvider
+	requests chan []providers.Message
+}
+
+func (m *recordingLLM) ResponseWithFunctions(ctx context.Context, sessionID string, messages []providers.Message, tools []openai.Tool) (<-chan types.Response, error) {
+	m.requests <- messages
+	ch := make(chan types.Response)
+	close(ch)
+	return ch, nil
+}
+
+func (m *recordingLLM) Capabilities() map[string]bool {
+	return map[string]bool{types.CapabilityTools: true}
+}
+
+// webhookMCPServer 模拟异步MCP服务器：立即返回受理结果，稍后将结果POST到 callback_url
+type webhookMCPServer struct {
+	result string
+}
+
+func (s *webhookMCPServer) ExecuteTool(ctx context.Context, toolName string, arguments map[string]interface{}) (interface{}, error) {
+	callbackURL, _ := arguments["callback_url"].(string)
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		body, _ := json.Marshal(map[string]string{"result": s.result})
+		resp, err := http.Post(callbackURL, "application/json", bytes.NewReader(body))
+		if err == nil {
+			resp.Body.Close()
+		}
+	}()
+	return "accepted", nil
+}
+
+func TestToolCallbackAsyncFlow(t *testing.T) {
+	logger, err := utils.NewLogger(&utils.LogCfg{LogLevel: "ERROR", LogDir: t.TempDir(), LogFile: "test.log"})
+	if err != nil {
+		t.Fatalf("创建日志失败: %v", err)
+	}
+	llm := &recordingLLM{requests: make(chan []providers.Message, 1)}
+	h := &ConnectionHandler{
+		logger:           logger,
+		sessionID:        "session-1",
+		dialogueManager:  chat.NewDialogueManager(logger, nil),
+		functionRegister: function.NewFunctionRegistry(),
+	}
+	h.providers.llm = llm
+
+	// 回调接收方，按路径中的工具ID恢复会话
+	callbackErrs := make(chan error, 2)
+	callbackSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Result string `json:"result"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		if path.Dir(r.URL.Path) != "/api/v2/mcp/callback/session-1" {
+			t.Errorf("回调地址 = %s", r.URL.Path)
+		}
+		callbackErrs <- h.ResumeToolCallback(path.Base(r.URL.Path), req.Result)
+	}))
+	defer callbackSrv.Close()
+	h.config = &configs.Config{MCPCallback: configs.MCPCallbackConfig{BaseURL: callbackSrv.URL + "/", Secret: "secret"}}
+
+	functionCallData := map[string]interface{}{"id": "call-1", "name": "mcp_weather", "arguments": `{"city":"深圳"}`}
+	arguments := map[string]interface{}{"city": "深圳"}
+	result := h.startToolCallback(context.Background(), &webhookMCPServer{result: "深圳明天晴"}, "mcp_weather", arguments, functionCallData)
+	if result.Action != types.ActionTypeNone {
+		t.Fatalf("等待回调时应返回 ActionTypeNone, got %v", result.Action)
+	}
+
+	select {
+	case err := <-callbackErrs:
+		if err != nil {
+			t.Fatalf("ResumeToolCallback: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("未收到回调")
+	}
+	select {
+	case messages := <-llm.requests:
+		last := messages[len(messages)-1]
+		if last.Role != "tool" || last.Content != "深圳明天晴" || last.ToolCallID != "call-1" {
+			t.Errorf("回调结果应作为工具输出请求LLM: %+v", last)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("回调后未继续请求LLM")
+	}
+
+	if err := h.ResumeToolCallback("call-1", "重复回调"); !errors.Is(err, ErrToolCallbackNotFound) {
+		t.Errorf("重复回调应返回 ErrToolCallbackNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,118 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"angrymiao-ai-server/src/configs"
+	"angrymiao-ai-server/src/core/chat"
+	"angrymiao-ai-server/src/core/function"
+	"angrymiao-ai-server/src/core/providers"
+	"angrymiao-ai-server/src/core/types"
+	"angrymiao-ai-server/src/core/utils"
+
+	"github.com/angrymiao/go-openai"
+)
+
+// capabilityLLM 返回指定能力，并记录请求中携带的工具
+type capabilityLLM struct {
+	providers.LLMProvider
+	capabilities map[string]bool
+	tools        chan []openai.Tool
+}
+
+func (m *capabilityLLM) Capabilities() map[string]bool { return m.capabilities }
+
+func (m *capabilityLLM) ResponseWithFunctions(ctx context.Context, sessionID string, messages []providers.Message, tools []openai.Tool) (<-chan types.Response, error) {
+	m.tools <- tools
+	ch := make(chan types.Response)
+	close(ch)
+	return ch, nil
+}
+
+type capabilityTTS struct {
+	providers.TTSProvider
+	capabilities map[string]bool
+}
+
+func (p *capabilityTTS) Capabilities() map[string]bool { return p.capabilities }
+
+type capabilityASR struct {
+	providers.ASRProvider
+	capabilities map[string]bool
+}
+
+func (p *capabilityASR) Capabilities() map[string]bool { return p.capabilities }
+
+func newCapabilityTestHandler(t *testing.T, llmCapabilities map[string]bool) (*ConnectionHandler, *recordingConn, *capabilityLLM) {
+	t.Helper()
+	logger, err := utils.NewLogger(&utils.LogCfg{LogLevel: "ERROR", LogDir: t.TempDir(), LogFile: "test.log"})
+	if err != nil {
+		t.Fatalf("创建日志失败: %v", err)
+	}
+	conn := &recordingConn{}
+	h := &ConnectionHandler{
+		logger:           logger,
+		conn:             conn,
+		config:           &configs.Config{},
+		dialogueManager:  chat.NewDialogueManager(logger, nil),
+		functionRegister: function.NewFunctionRegistry(),
+	}
+	llm := &capabilityLLM{capabilities: llmCapabilities, tools: make(chan []openai.Tool, 1)}
+	h.providers.llm = llm
+	h.providers.tts = &capabilityTTS{capabilities: map[string]bool{types.CapabilityStreaming: false, types.CapabilitySSML: true}}
+	h.providers.asr = &capabilityASR{capabilities: map[string]bool{types.CapabilityStreaming: true}}
+	return h, conn, llm
+}
+
+func TestHelloMessageIncludesMergedCapabilities(t *testing.T) {
+	h, conn, _ := newCapabilityTestHandler(t, map[string]bool{types.CapabilityStreaming: true, types.CapabilityTools: false})
+
+	if err := h.sendHelloMessage(); err != nil {
+		t.Fatalf("sendHelloMessage: %v", err)
+	}
+
+	var hello struct {
+		ServerCapabilities map[string]bool `json:"server_capabilities"`
+	}
+	if err := json.Unmarshal(conn.messages[0], &hello); err != nil {
+		t.Fatalf("解析hello消息失败: %v", err)
+	}
+	want := map[string]bool{
+		types.CapabilityStreaming: true,
+		types.CapabilityTools:     false,
+		types.CapabilityVision:    false,
+		types.CapabilitySSML:      true,
+	}
+	if len(hello.ServerCapabilities) != len(want) {
+		t.Fatalf("server_capabilities = %v, 期望 %v", hello.ServerCapabilities, want)
+	}
+	for name, supported := range want {
+		if got, ok := hello.ServerCapabilities[name]; !ok || got != supported {
+			t.Errorf("%s = %v, 期望 %v", name, got, supported)
+		}
+	}
+}
+
+func TestGenResponseSkipsToolsWhenUnsupported(t *testing.T) {
+	tool := openai.Tool{Type: openai.ToolTypeFunction, Function: &openai.FunctionDefinition{Name: "get_weather"}}
+	for _, tc := range []struct {
+		name      string
+		supported bool
+		wantTools int
+	}{
+		{"支持工具", true, 1},
+		{"不支持工具", false, 0},
+	} {
+		h, _, llm := newCapabilityTestHandler(t, map[string]bool{types.CapabilityTools: tc.supported})
+		h.functionRegister.RegisterFunction("get_weather", tool)
+
+		if err := h.genResponseByLLM(context.Background(), nil, 1); err != nil {
+			t.Fatalf("%s: genResponseByLLM: %v", tc.name, err)
+		}
+		if got := len(<-llm.tools); got != tc.wantTools {
+			t.Errorf("%s: 请求携带 %d 个工具, 期望 %d", tc.name, got, tc.wantTools)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"angrymiao-ai-server/src/core/types"
+)
+
+const (
+	defaultCompositePromptMaxBots = 5
+	// 每个Bot描述追加到系统提示词时保留的最大字符数
+	compositePromptDescriptionLimit = 200
+	compositePromptHeader           = "You have access to the following specialized assistants: "
+)
+
+// buildCompositePrompt 按优先级降序拼接已启用的LLM类Bot描述，最多 maxBots 个，没有可用Bot时返回空字符串
+func buildCompositePrompt(configs []*types.BotConfig, maxBots int) string {
+	if maxBots <= 0 {
+		maxBots = defaultCompositePromptMaxBots
+	}
+	bots := make([]*types.BotConfig, 0, len(configs))
+	for _, config := range configs {
+		if config == nil || !config.IsActive || config.BotType != "llm" || config.FunctionName == "" {
+			continue
+		}
+		bots = append(bots, config)
+	}
+	if len(bots) == 0 {
+		return ""
+	}
+	sort.SliceStable(bots, func(i, j int) bool { return bots[i].Priority > bots[j].Priority })
+	if len(bots) > maxBots {
+		bots = bots[:maxBots]
+	}
+
+	entries := make([]string, 0, len(bots))
+	for _, bot := range bots {
+		description := []rune(bot.Description)
+		if len(description) > compositePromptDescriptionLimit {
+			description = description[:compositePromptDescriptionLimit]
+		}
+		entries = append(entries, fmt.Sprintf("[%s: %s]", bot.FunctionName, string(description)))
+	}
+	return compositePromptHeader + strings.Join(entries, ", ")
+}
+
+// applyCompositeSystemPrompt 将Bot好友描述追加到系统提示词，调用方需持有 userConfigsMu
+// 重新加载时先移除上一次追加的内容，避免重复追加
+func (h *ConnectionHandler) applyCompositeSystemPrompt(configs []*types.BotConfig) {
+	if h.dialogueManager == nil {
+		return
+	}
+	base := h.dialogueManager.SystemMessage()
+	if h.compositePrompt != "" {
+		base = strings.TrimSuffix(strings.TrimSuffix(base, h.compositePrompt), "\n\n")
+	}
+	h.compositePrompt = ""
+	if h.config.CompositeSystemPrompt {
+		h.compositePrompt = buildCompositePrompt(configs, h.config.CompositePromptMaxBots)
+	}
+
+	prompt := base
+	if h.compositePrompt != "" {
+		prompt = strings.TrimSpace(base + "\n\n" + h.compositePrompt)
+		h.logger.Debug("系统提示词追加专业助手说明: %s", h.compositePrompt)
+	}
+	h.dialogueManager.SetSystemMessage(prompt)
+}
@@ -0,0 +1,53 @@
+package core
+
+import (
+	"strings"
+	"testing"
+
+	"angrymiao-ai-server/src/configs"
+	"angrymiao-ai-server/src/core/types"
+)
+
+func TestBuildCompositePromptOrdersByPriorityAndTruncates(t *testing.T) {
+	long := strings.Repeat("长", 250)
+	configs := []*types.BotConfig{
+		{FunctionName: "weather", Description: "查询天气", BotType: "llm", IsActive: true, Priority: 1},
+		{FunctionName: "translator", Description: long, BotType: "llm", IsActive: true, Priority: 10},
+		{FunctionName: "music", Description: "播放音乐", BotType: "llm", IsActive: true, Priority: 5},
+		{FunctionName: "webhook", Description: "非LLM类Bot", BotType: "http", IsActive: true, Priority: 20},
+		{FunctionName: "disabled", Description: "已停用", BotType: "llm", IsActive: false, Priority: 30},
+	}
+
+	want := "You have access to the following specialized assistants: [translator: " + strings.Repeat("长", 200) +
+		"], [music: 播放音乐], [weather: 查询天气]"
+	if got := buildCompositePrompt(configs, 5); got != want {
+		t.Errorf("组合提示词 = %q\n期望 %q", got, want)
+	}
+	if got := buildCompositePrompt(configs, 2); strings.Contains(got, "weather") || !strings.Contains(got, "[music: 播放音乐]") {
+		t.Errorf("限制2个Bot时的组合提示词 = %q", got)
+	}
+	if got := buildCompositePrompt(configs[3:], 5); got != "" {
+		t.Errorf("没有可用Bot时应返回空字符串, got %q", got)
+	}
+}
+
+func TestApplyCompositeSystemPromptReplacesPreviousSection(t *testing.T) {
+	h := newPrefixTestHandler(t, &configs.Config{CompositeSystemPrompt: true})
+	h.dialogueManager.SetSystemMessage("你是一个语音助手")
+
+	h.applyCompositeSystemPrompt([]*types.BotConfig{
+		{FunctionName: "music", Description: "播放音乐", BotType: "llm", IsActive: true},
+	})
+	h.applyCompositeSystemPrompt([]*types.BotConfig{
+		{FunctionName: "weather", Description: "查询天气", BotType: "llm", IsActive: true},
+	})
+	want := "你是一个语音助手\n\nYou have access to the following specialized assistants: [weather: 查询天气]"
+	if got := h.dialogueManager.SystemMessage(); got != want {
+		t.Errorf("重新加载后的系统提示词 = %q, 期望 %q", got, want)
+	}
+
+	h.applyCompositeSystemPrompt(nil)
+	if got := h.dialogueManager.SystemMessage(); got != "你是一个语音助手" {
+		t.Errorf("没有Bot好友时系统提示词 = %q", got)
+	}
+}
@@ -1,12 +1,16 @@
 package core
 
 import (
+	"context"
 	"fmt"
+	"reflect"
+	"strings"
 
 	"angrymiao-ai-server/src/core/providers/asr"
 	"angrymiao-ai-server/src/core/providers/llm"
 	"angrymiao-ai-server/src/core/providers/tts"
 	providersvad "angrymiao-ai-server/src/core/providers/vad"
+	"angrymiao-ai-server/src/core/types"
 )
 
 // ConfigurableASRProvider ASR 可配置接口
@@ -19,9 +23,19 @@ type ConfigurableLLMProvider interface {
 	UpdateConfig(userConfig *llm.Config) error
 }
 
-// ConfigurableTTSProvider TTS 可配置接口
+// ConfigurableTTSProvider TTS 可配置接口，只更新音色相关配置
 type ConfigurableTTSProvider interface {
-	UpdateConfig(userConfig *tts.Config) error
+	UpdateVoiceConfig(userConfig *tts.Config) error
+}
+
+// llmConfigGetter 可读取当前配置的LLM，用于记录配置变化
+type llmConfigGetter interface {
+	Config() *llm.Config
+}
+
+// ttsConfigGetter 可读取当前配置的TTS，用于记录配置变化
+type ttsConfigGetter interface {
+	Config() *tts.Config
 }
 
 // ApplyUserASRConfig 应用用户级 ASR 配置
@@ -58,11 +72,20 @@ func (h *ConnectionHandler) ApplyUserLLMConfig(userConfig *llm.Config) error {
 
 	// 类型断言检查是否支持配置更新
 	if configurable, ok := h.providers.llm.(ConfigurableLLMProvider); ok {
+		before := llm.Config{}
+		getter, hasConfig := h.providers.llm.(llmConfigGetter)
+		if hasConfig && getter.Config() != nil {
+			before = *getter.Config()
+		}
 		if err := configurable.UpdateConfig(userConfig); err != nil {
 			h.logger.Error(fmt.Sprintf("应用用户LLM配置失败: %v", err))
 			return fmt.Errorf("应用用户LLM配置失败: %v", err)
 		}
-		h.logger.Info("成功应用用户LLM配置")
+		after := *userConfig
+		if hasConfig && getter.Config() != nil {
+			after = *getter.Config()
+		}
+		h.logger.Info("成功应用用户LLM配置: %s", strings.Join(configDelta(before, after), ", "))
 		return nil
 	}
 
@@ -81,11 +104,20 @@ func (h *ConnectionHandler) ApplyUserTTSConfig(userConfig *tts.Config) error {
 
 	// 类型断言检查是否支持配置更新
 	if configurable, ok := h.providers.tts.(ConfigurableTTSProvider); ok {
-		if err := configurable.UpdateConfig(userConfig); err != nil {
+		before := tts.Config{}
+		getter, hasConfig := h.providers.tts.(ttsConfigGetter)
+		if hasConfig && getter.Config() != nil {
+			before = *getter.Config()
+		}
+		if err := configurable.UpdateVoiceConfig(userConfig); err != nil {
 			h.logger.Error(fmt.Sprintf("应用用户TTS配置失败: %v", err))
 			return fmt.Errorf("应用用户TTS配置失败: %v", err)
 		}
-		h.logger.Info("成功应用用户TTS配置")
+		after := *userConfig
+		if hasConfig && getter.Config() != nil {
+			after = *getter.Config()
+		}
+		h.logger.Info("成功应用用户TTS配置: %s", strings.Join(configDelta(before, after), ", "))
 		return nil
 	}
 
@@ -93,6 +125,192 @@ func (h *ConnectionHandler) ApplyUserTTSConfig(userConfig *tts.Config) error {
 	return nil
 }
 
+// snapshotProviderConfigs 记录刚借出的LLM与TTS提供者的配置
+func (h *ConnectionHandler) snapshotProviderConfigs() {
+	h.initialLLMConfig, h.initialTTSConfig = nil, nil
+	if getter, ok := h.providers.llm.(llmConfigGetter); ok && getter.Config() != nil {
+		config := *getter.Config()
+		h.initialLLMConfig = &config
+	}
+	if getter, ok := h.providers.tts.(ttsConfigGetter); ok && getter.Config() != nil {
+		config := *getter.Config()
+		h.initialTTSConfig = &config
+	}
+}
+
+// restoreProviderConfigs 将LLM与TTS提供者的配置恢复为借出时的快照，在提供者归还资源池前调用
+func (h *ConnectionHandler) restoreProviderConfigs() {
+	if h.initialLLMConfig != nil {
+		if getter, ok := h.providers.llm.(llmConfigGetter); ok && getter.Config() != nil {
+			*getter.Config() = *h.initialLLMConfig
+		}
+	}
+	if h.providers.tts == nil {
+		return
+	}
+	if getter, ok := h.providers.tts.(ttsConfigGetter); ok && getter.Config() != nil && h.initialTTSConfig != nil {
+		*getter.Config() = *h.initialTTSConfig
+		return
+	}
+	h.providers.tts.SetVoice(h.initailVoice) // 恢复初始语音
+}
+
+// configDelta 列出两份同类型配置中发生变化的字段，密钥类字段不输出内容
+func configDelta(before, after interface{}) []string {
+	bv, av := reflect.ValueOf(before), reflect.ValueOf(after)
+	if bv.Type() != av.Type() || bv.Kind() != reflect.Struct {
+		return nil
+	}
+	var changes []string
+	for i := 0; i < bv.NumField(); i++ {
+		field := bv.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		oldValue, newValue := bv.Field(i).Interface(), av.Field(i).Interface()
+		if reflect.DeepEqual(oldValue, newValue) {
+			continue
+		}
+		switch field.Name {
+		case "APIKey", "Token":
+			changes = append(changes, field.Name+": 已更新")
+		default:
+			changes = append(changes, fmt.Sprintf("%s: %v -> %v", field.Name, oldValue, newValue))
+		}
+	}
+	if len(changes) == 0 {
+		return []string{"无变化"}
+	}
+	return changes
+}
+
+// applyBotProviderConfig 用户只有一个启用的Bot好友且类型为 llm 或 tts 时，将其模型配置应用到本连接的provider
+func (h *ConnectionHandler) applyBotProviderConfig(configs []*types.BotConfig) {
+	active := singleActiveBot(configs)
+	if active == nil {
+		return
+	}
+
+	var err error
+	switch active.BotType {
+	case "llm":
+		err = h.ApplyUserLLMConfig(h.botLLMConfig(active))
+		if err == nil && active.TTSVoice != "" {
+			err = h.ApplyUserTTSConfig(&tts.Config{Voice: active.TTSVoice})
+		}
+	case "tts":
+		// 用户为Bot设置了复刻音色时优先使用
+		voice := active.ModelName
+		if active.TTSVoice != "" {
+			voice = active.TTSVoice
+		}
+		err = h.ApplyUserTTSConfig(&tts.Config{Voice: voice, Token: active.APIKey})
+	default:
+		return
+	}
+	if err != nil {
+		h.logger.Warn("应用Bot %s 的模型配置失败: %v", active.FunctionName, err)
+	}
+}
+
+// loadDeviceGroup 设备属于设备组时，使用设备组的系统提示词
+// 设备组的主人格Bot在加载Bot配置时生效，见 applyDevicePersona
+func (h *ConnectionHandler) loadDeviceGroup() {
+	h.deviceGroup = nil
+	if h.userConfigService == nil || h.deviceID == "" {
+		return
+	}
+	group, err := h.userConfigService.GetDeviceGroup(context.Background(), h.userID, h.deviceID)
+	if err != nil {
+		h.logger.Warn("查询设备 %s 所属设备组失败: %v", h.deviceID, err)
+		return
+	}
+	if group == nil {
+		return
+	}
+	h.deviceGroup = group
+	if group.SystemPrompt != "" && h.dialogueManager != nil {
+		h.dialogueManager.SetSystemMessage(group.SystemPrompt)
+	}
+	h.logger.Info("设备 %s 属于设备组 %s (ID: %d)", h.deviceID, group.Name, group.ID)
+}
+
+// applyDevicePersona 设备绑定了主人格Bot时，使用其描述作为系统提示词、其模型作为对话LLM
+// 其他Bot好友仍注册为工具；主人格优先于唯一启用Bot的模型配置
+// 设备组设置了主人格Bot时优先于设备自身的绑定，设备组的系统提示词优先于主人格的描述
+// 模型配置只写入本连接借出的LLM实例，连接释放提供者前由 restoreProviderConfigs 恢复
+func (h *ConnectionHandler) applyDevicePersona(configs []*types.BotConfig) {
+	if h.deviceID == "" {
+		return
+	}
+	group := h.deviceGroup
+	var botID uint
+	if group != nil && group.BotConfigID != 0 {
+		botID = group.BotConfigID
+	} else {
+		var err error
+		botID, err = h.userConfigService.GetDevicePersonaID(context.Background(), h.userID, h.deviceID)
+		if err != nil {
+			h.logger.Warn("查询设备 %s 的主人格绑定失败: %v", h.deviceID, err)
+			return
+		}
+	}
+	if botID == 0 {
+		return
+	}
+	var persona *types.BotConfig
+	for _, config := range configs {
+		if config.ID == botID {
+			persona = config
+			break
+		}
+	}
+	if persona == nil {
+		h.logger.Warn("设备 %s 绑定的主人格Bot %d 已不在好友列表中", h.deviceID, botID)
+		return
+	}
+
+	if persona.Description != "" && h.dialogueManager != nil && (group == nil || group.SystemPrompt == "") {
+		h.dialogueManager.SetSystemMessage(persona.Description)
+	}
+	if err := h.ApplyUserLLMConfig(h.botLLMConfig(persona)); err != nil {
+		h.logger.Warn("应用主人格Bot %s 的模型配置失败: %v", persona.FunctionName, err)
+		return
+	}
+	h.logger.Info("设备 %s 使用主人格Bot: %s", h.deviceID, persona.FunctionName)
+}
+
+// botLLMConfig 以当前LLM配置为基础，覆盖Bot配置中设置的模型参数
+// 用户未配置 API Key 时使用系统中同类型LLM的 API Key
+func (h *ConnectionHandler) botLLMConfig(bot *types.BotConfig) *llm.Config {
+	config := &llm.Config{}
+	if getter, ok := h.providers.llm.(llmConfigGetter); ok && getter.Config() != nil {
+		*config = *getter.Config()
+	}
+	config.Name = fmt.Sprintf("user_%s_bot_%d", h.userID, bot.ID)
+	if bot.LLMType != "" {
+		config.Type = bot.LLMType
+	}
+	if bot.ModelName != "" {
+		config.ModelName = bot.ModelName
+	}
+	if bot.BaseURL != "" {
+		config.BaseURL = bot.BaseURL
+	}
+	if bot.Temperature != 0 {
+		config.Temperature = float64(bot.Temperature)
+	}
+	if bot.MaxTokens != 0 {
+		config.MaxTokens = bot.MaxTokens
+	}
+	if bot.APIKey != "" {
+		config.APIKey = bot.APIKey
+	} else if systemConfig, ok := h.config.LLM[bot.LLMType]; ok && systemConfig.APIKey != "" {
+		config.APIKey = systemConfig.APIKey
+	}
+	return config
+}
+
 // ApplyUserVADConfig 应用用户级 VAD 配置
 func (h *ConnectionHandler) ApplyUserVADConfig(userConfig *providersvad.Config) error {
 	if userConfig == nil {
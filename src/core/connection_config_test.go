@@ -0,0 +1,406 @@
+package core
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"angrymiao-ai-server/src/configs"
+	"angrymiao-ai-server/src/core/botconfig"
+	"angrymiao-ai-server/src/core/chat"
+	"angrymiao-ai-server/src/core/function"
+	"angrymiao-ai-server/src/core/pool"
+	"angrymiao-ai-server/src/core/providers"
+	"angrymiao-ai-server/src/core/providers/llm"
+	"angrymiao-ai-server/src/core/providers/tts"
+	"angrymiao-ai-server/src/core/types"
+	"angrymiao-ai-server/src/core/utils"
+	"angrymiao-ai-server/src/models"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// configurableLLM 支持动态更新配置的LLM
+type configurableLLM struct {
+	providers.LLMProvider
+	config *llm.Config
+}
+
+func (p *configurableLLM) Config() *llm.Config { return p.config }
+
+func (p *configurableLLM) UpdateConfig(userConfig *llm.Config) error {
+	p.config.SetUserConfig(userConfig)
+	return nil
+}
+
+// configurableTTS 使用TTS基础实现更新音色配置
+type configurableTTS struct {
+	providers.TTSProvider
+	base *tts.BaseProvider
+}
+
+func (p *configurableTTS) Config() *tts.Config { return p.base.Config() }
+
+func (p *configurableTTS) UpdateVoiceConfig(userConfig *tts.Config) error {
+	return p.base.UpdateVoiceConfig(userConfig)
+}
+
+func newConfigTestHandler(t *testing.T, service *mockBotConfigService) *ConnectionHandler {
+	t.Helper()
+	logger, err := utils.NewLogger(&utils.LogCfg{LogLevel: "ERROR", LogDir: t.TempDir(), LogFile: "test.log"})
+	if err != nil {
+		t.Fatalf("创建日志失败: %v", err)
+	}
+	h := &ConnectionHandler{
+		logger: logger,
+		config: &configs.Config{LLM: map[string]configs.LLMConfig{
+			"qwen": {APIKey: "system-key"},
+		}},
+		functionRegister:  function.NewFunctionRegistry(),
+		userConfigService: service,
+		userID:            "42",
+	}
+	h.providers.llm = &configurableLLM{config: &llm.Config{Type: "openai", ModelName: "gpt-4o", TopP: 0.9}}
+	h.providers.tts = &configurableTTS{base: tts.NewBaseProvider(&tts.Config{Type: "edge", OutputDir: "tmp/", Voice: "zh-CN-XiaoxiaoNeural"}, true)}
+	return h
+}
+
+func TestLoadUserAIConfigurationsAppliesSingleLLMBot(t *testing.T) {
+	service := &mockBotConfigService{}
+	service.set(&types.BotConfig{ID: 3, FunctionName: "bot_chat", BotType: "llm", LLMType: "qwen", ModelName: "qwen-max", Temperature: 0.5, IsActive: true})
+	h := newConfigTestHandler(t, service)
+
+	h.loadUserAIConfigurations()
+
+	got := h.providers.llm.(*configurableLLM).config
+	if got.ModelName != "qwen-max" || got.Type != "qwen" || got.Temperature != 0.5 {
+		t.Errorf("LLM配置未应用Bot设置: %+v", got)
+	}
+	if got.APIKey != "system-key" {
+		t.Errorf("未配置用户Key时应使用系统Key, APIKey = %q", got.APIKey)
+	}
+	if got.TopP != 0.9 {
+		t.Errorf("Bot未设置的字段应保留, TopP = %v", got.TopP)
+	}
+}
+
+func TestLoadUserAIConfigurationsAppliesSingleTTSBot(t *testing.T) {
+	service := &mockBotConfigService{}
+	service.set(
+		&types.BotConfig{FunctionName: "bot_voice", BotType: "tts", ModelName: "zh-CN-YunxiNeural", IsActive: true},
+		&types.BotConfig{FunctionName: "bot_paused", BotType: "llm", ModelName: "qwen-max"}, // 未启用
+	)
+	h := newConfigTestHandler(t, service)
+
+	h.loadUserAIConfigurations()
+
+	got := h.providers.tts.(*configurableTTS).Config()
+	if got.Voice != "zh-CN-YunxiNeural" {
+		t.Errorf("音色 = %q, 期望应用Bot的音色", got.Voice)
+	}
+	if got.OutputDir != "tmp/" || got.Type != "edge" {
+		t.Errorf("更新音色不应覆盖服务端配置: %+v", got)
+	}
+	if m := h.providers.llm.(*configurableLLM).config.ModelName; m != "gpt-4o" {
+		t.Errorf("未启用的Bot不应应用, ModelName = %q", m)
+	}
+}
+
+func TestLoadUserAIConfigurationsSkipsMultipleActiveBots(t *testing.T) {
+	service := &mockBotConfigService{}
+	service.set(
+		&types.BotConfig{FunctionName: "bot_a", BotType: "llm", ModelName: "qwen-max", IsActive: true},
+		&types.BotConfig{FunctionName: "bot_b", BotType: "tts", ModelName: "zh-CN-YunxiNeural", IsActive: true},
+	)
+	h := newConfigTestHandler(t, service)
+
+	h.loadUserAIConfigurations()
+
+	if m := h.providers.llm.(*configurableLLM).config.ModelName; m != "gpt-4o" {
+		t.Errorf("多个启用的Bot时不应应用, ModelName = %q", m)
+	}
+	if v := h.providers.tts.(*configurableTTS).Config().Voice; v != "zh-CN-XiaoxiaoNeural" {
+		t.Errorf("多个启用的Bot时不应应用, Voice = %q", v)
+	}
+}
+
+func TestApplyUserConfigSkipsUnsupportedProviders(t *testing.T) {
+	h := newConfigTestHandler(t, &mockBotConfigService{})
+	h.providers.llm = &recordingLLM{}
+	h.providers.tts = &voiceTTS{}
+
+	if err := h.ApplyUserLLMConfig(&llm.Config{ModelName: "qwen-max"}); err != nil {
+		t.Errorf("不支持配置更新的LLM应跳过, got %v", err)
+	}
+	if err := h.ApplyUserTTSConfig(&tts.Config{Voice: "zh-CN-YunxiNeural"}); err != nil {
+		t.Errorf("不支持配置更新的TTS应跳过, got %v", err)
+	}
+}
+
+func TestConfigDeltaOnlyChangedFields(t *testing.T) {
+	before := llm.Config{ModelName: "gpt-4o", APIKey: "a", TopP: 0.9}
+	after := llm.Config{ModelName: "qwen-max", APIKey: "b", TopP: 0.9}
+	got := configDelta(before, after)
+	if len(got) != 2 || got[0] != "ModelName: gpt-4o -> qwen-max" || got[1] != "APIKey: 已更新" {
+		t.Errorf("configDelta = %v", got)
+	}
+}
+
+func TestLoadUserAIConfigurationsAppliesDevicePersona(t *testing.T) {
+	service := &mockBotConfigService{personaID: 5}
+	service.set(
+		&types.BotConfig{ID: 4, FunctionName: "bot_weather", LLMType: "qwen", ModelName: "qwen-turbo", IsActive: true},
+		&types.BotConfig{ID: 5, FunctionName: "bot_teacher", Description: "你是一位耐心的英语老师", LLMType: "qwen", ModelName: "qwen-max", IsActive: true},
+	)
+	h := newConfigTestHandler(t, service)
+	h.deviceID = "device-1"
+	h.dialogueManager = chat.NewDialogueManager(h.logger, nil)
+	h.dialogueManager.SetSystemMessage("默认提示词")
+
+	h.loadUserAIConfigurations()
+
+	if prompt := h.dialogueManager.GetLLMDialogue()[0].Content; prompt != "你是一位耐心的英语老师" {
+		t.Errorf("系统提示词 = %q, 期望使用主人格Bot的描述", prompt)
+	}
+	if m := h.providers.llm.(*configurableLLM).config.ModelName; m != "qwen-max" {
+		t.Errorf("ModelName = %q, 期望使用主人格Bot的模型", m)
+	}
+	if h.findUserConfig("bot_weather") == nil {
+		t.Error("其他Bot好友应仍可作为工具使用")
+	}
+}
+
+func TestLoadUserAIConfigurationsSkipsPersonaForOtherDevice(t *testing.T) {
+	service := &mockBotConfigService{}
+	service.set(&types.BotConfig{ID: 5, FunctionName: "bot_teacher", Description: "你是一位耐心的英语老师", ModelName: "qwen-max"})
+	h := newConfigTestHandler(t, service)
+	h.deviceID = "device-2"
+	h.dialogueManager = chat.NewDialogueManager(h.logger, nil)
+	h.dialogueManager.SetSystemMessage("默认提示词")
+
+	h.loadUserAIConfigurations()
+
+	if prompt := h.dialogueManager.GetLLMDialogue()[0].Content; prompt != "默认提示词" {
+		t.Errorf("未绑定主人格的设备不应修改系统提示词, got %q", prompt)
+	}
+}
+
+func TestDeviceGroupSharesSystemPrompt(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "groups.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("打开测试数据库失败: %v", err)
+	}
+	if err := db.AutoMigrate(&models.DeviceGroup{}, &models.DeviceGroupMember{}); err != nil {
+		t.Fatalf("迁移失败: %v", err)
+	}
+	group := &models.DeviceGroup{Name: "客厅", UserID: 42, SystemPrompt: "你是客厅里的家庭助手"}
+	if err := db.Create(group).Error; err != nil {
+		t.Fatalf("创建设备组失败: %v", err)
+	}
+	for _, deviceID := range []string{"device-1", "device-2"} {
+		if err := db.Create(&models.DeviceGroupMember{GroupID: group.ID, DeviceID: deviceID}).Error; err != nil {
+			t.Fatalf("添加设备组成员失败: %v", err)
+		}
+	}
+
+	connect := func(deviceID string) string {
+		t.Helper()
+		h := newConfigTestHandler(t, &mockBotConfigService{})
+		h.userConfigService = botconfig.NewService(db, h.logger)
+		h.config.DefaultPrompt = "默认提示词"
+		h.deviceID = deviceID
+		h.loadUserDialogueManager()
+		h.loadUserAIConfigurations()
+		return h.dialogueManager.GetLLMDialogue()[0].Content
+	}
+	for _, deviceID := range []string{"device-1", "device-2"} {
+		if prompt := connect(deviceID); prompt != "你是客厅里的家庭助手" {
+			t.Errorf("设备 %s 的系统提示词 = %q, 期望使用设备组的提示词", deviceID, prompt)
+		}
+	}
+
+	// 移出设备组后，下次连接恢复默认提示词
+	if err := db.Where("device_id = ?", "device-2").Delete(&models.DeviceGroupMember{}).Error; err != nil {
+		t.Fatalf("移除设备组成员失败: %v", err)
+	}
+	if prompt := connect("device-2"); prompt != "默认提示词" {
+		t.Errorf("移出设备组后系统提示词 = %q, 期望恢复默认提示词", prompt)
+	}
+	if prompt := connect("device-1"); prompt != "你是客厅里的家庭助手" {
+		t.Errorf("组内其他设备的系统提示词 = %q", prompt)
+	}
+}
+
+func TestDeviceGroupPersonaOverridesDeviceBinding(t *testing.T) {
+	service := &mockBotConfigService{
+		personaID: 4,
+		group:     &models.DeviceGroup{ID: 1, Name: "儿童房", SystemPrompt: "你是儿童房里的故事大王", BotConfigID: 5},
+	}
+	service.set(
+		&types.BotConfig{ID: 4, FunctionName: "bot_weather", Description: "你是天气助手", LLMType: "qwen", ModelName: "qwen-turbo", IsActive: true},
+		&types.BotConfig{ID: 5, FunctionName: "bot_teacher", Description: "你是一位耐心的英语老师", LLMType: "qwen", ModelName: "qwen-max", IsActive: true},
+	)
+	h := newConfigTestHandler(t, service)
+	h.deviceID = "device-1"
+	h.loadUserDialogueManager()
+	h.loadUserAIConfigurations()
+
+	if prompt := h.dialogueManager.GetLLMDialogue()[0].Content; prompt != "你是儿童房里的故事大王" {
+		t.Errorf("系统提示词 = %q, 期望设备组的提示词优先于主人格描述", prompt)
+	}
+	if m := h.providers.llm.(*configurableLLM).config.ModelName; m != "qwen-max" {
+		t.Errorf("ModelName = %q, 期望使用设备组主人格Bot的模型", m)
+	}
+}
+
+// pooledConfigLLM 使用LLM基础实现保存配置，配置由资源池工厂创建
+type pooledConfigLLM struct {
+	providers.LLMProvider
+	base *llm.BaseProvider
+}
+
+func (p *pooledConfigLLM) Config() *llm.Config { return p.base.Config() }
+func (p *pooledConfigLLM) Initialize() error   { return nil }
+func (p *pooledConfigLLM) Cleanup() error      { return nil }
+
+func (p *pooledConfigLLM) UpdateConfig(userConfig *llm.Config) error {
+	return p.base.UpdateConfig(userConfig)
+}
+
+// pooledConfigTTS 使用TTS基础实现保存配置，配置由资源池工厂创建
+type pooledConfigTTS struct {
+	providers.TTSProvider
+	base *tts.BaseProvider
+}
+
+func (p *pooledConfigTTS) Config() *tts.Config         { return p.base.Config() }
+func (p *pooledConfigTTS) Initialize() error           { return nil }
+func (p *pooledConfigTTS) Cleanup() error              { return nil }
+func (p *pooledConfigTTS) SetVoice(voice string) error { return nil }
+
+func (p *pooledConfigTTS) UpdateVoiceConfig(userConfig *tts.Config) error {
+	return p.base.UpdateVoiceConfig(userConfig)
+}
+
+func init() {
+	llm.Register("pool_config_test", func(config *llm.Config) (llm.Provider, error) {
+		return &pooledConfigLLM{base: llm.NewBaseProvider(config)}, nil
+	})
+	tts.Register("pool_config_test", func(config *tts.Config, deleteFile bool) (tts.Provider, error) {
+		return &pooledConfigTTS{base: tts.NewBaseProvider(config, deleteFile)}, nil
+	})
+}
+
+// newSharedPoolHandlers 创建一个资源池，并从中为每个用户借出提供者创建连接
+func newSharedPoolHandlers(t *testing.T, userIDs ...string) (*pool.PoolManager, []*ConnectionHandler) {
+	t.Helper()
+	logger, err := utils.NewLogger(&utils.LogCfg{LogLevel: "ERROR", LogDir: t.TempDir(), LogFile: "test.log"})
+	if err != nil {
+		t.Fatalf("创建日志失败: %v", err)
+	}
+	config := &configs.Config{
+		SelectedModule: map[string]string{"LLM": "TestLLM", "TTS": "TestTTS"},
+		PoolConfig:     configs.PoolConfig{PoolMinSize: 1, PoolMaxSize: len(userIDs)},
+		McpPoolConfig:  configs.McpPoolConfig{PoolMaxSize: len(userIDs) + 1},
+		LLM:            map[string]configs.LLMConfig{"TestLLM": {Type: "pool_config_test", ModelName: "gpt-4o", APIKey: "system-key"}},
+		TTS:            map[string]configs.TTSConfig{"TestTTS": {Type: "pool_config_test", Voice: "default-voice", Token: "system-token", OutputDir: t.TempDir()}},
+	}
+	pm, err := pool.NewPoolManager(config, logger)
+	if err != nil {
+		t.Fatalf("创建资源池失败: %v", err)
+	}
+	t.Cleanup(pm.Close)
+
+	handlers := make([]*ConnectionHandler, len(userIDs))
+	for i, userID := range userIDs {
+		set, err := pm.GetProviderSet()
+		if err != nil {
+			t.Fatalf("获取提供者失败: %v", err)
+		}
+		h := &ConnectionHandler{
+			logger:           logger,
+			config:           config,
+			functionRegister: function.NewFunctionRegistry(),
+			userID:           userID,
+			stopChan:         make(chan struct{}),
+		}
+		h.useProviderSet(set)
+		handlers[i] = h
+	}
+	return pm, handlers
+}
+
+func TestPooledProvidersKeepPerConnectionConfig(t *testing.T) {
+	pm, handlers := newSharedPoolHandlers(t, "1", "2")
+	for i, h := range handlers {
+		h.applyBotProviderConfig([]*types.BotConfig{{ID: uint(i + 1), FunctionName: "bot_chat", BotType: "llm", ModelName: fmt.Sprintf("model-%d", i+1), IsActive: true}})
+		h.applyBotProviderConfig([]*types.BotConfig{{ID: uint(i + 10), FunctionName: "bot_voice", BotType: "tts", ModelName: fmt.Sprintf("voice-%d", i+1), APIKey: fmt.Sprintf("token-%d", i+1), IsActive: true}})
+	}
+	for i, h := range handlers {
+		llmConfig := h.providers.llm.(llmConfigGetter).Config()
+		ttsConfig := h.providers.tts.(ttsConfigGetter).Config()
+		if want := fmt.Sprintf("model-%d", i+1); llmConfig.ModelName != want {
+			t.Errorf("连接%d的 ModelName = %q, 期望 %q", i, llmConfig.ModelName, want)
+		}
+		if want := fmt.Sprintf("token-%d", i+1); ttsConfig.Token != want {
+			t.Errorf("连接%d的 TTS Token = %q, 期望 %q", i, ttsConfig.Token, want)
+		}
+	}
+
+	// 连接关闭后归还的提供者恢复为系统配置，下一个连接不会拿到上一个用户的配置
+	handlers[0].Close()
+	if err := pm.ReturnProviderSet(handlers[0].ProviderSet()); err != nil {
+		t.Fatalf("归还提供者失败: %v", err)
+	}
+	set, err := pm.GetProviderSet()
+	if err != nil {
+		t.Fatalf("获取提供者失败: %v", err)
+	}
+	if set.LLM != handlers[0].providers.llm {
+		t.Fatal("应借出刚归还的LLM实例")
+	}
+	if got := set.LLM.(llmConfigGetter).Config(); got.ModelName != "gpt-4o" || got.APIKey != "system-key" {
+		t.Errorf("归还后的LLM配置 = %+v, 期望系统配置", got)
+	}
+	if got := set.TTS.(ttsConfigGetter).Config(); got.Token != "system-token" || got.Voice != "default-voice" {
+		t.Errorf("归还后的TTS配置 = %+v, 期望系统配置", got)
+	}
+	if got := handlers[1].providers.llm.(llmConfigGetter).Config().ModelName; got != "model-2" {
+		t.Errorf("其他连接的 ModelName = %q, 期望 model-2", got)
+	}
+}
+
+func TestDevicePersonaConfigStaysOnConnection(t *testing.T) {
+	pm, handlers := newSharedPoolHandlers(t, "1", "2")
+	service := &mockBotConfigService{personaID: 5}
+	service.set(
+		&types.BotConfig{ID: 4, FunctionName: "bot_weather", LLMType: "qwen", ModelName: "qwen-turbo", IsActive: true},
+		&types.BotConfig{ID: 5, FunctionName: "bot_teacher", Description: "你是一位耐心的英语老师", LLMType: "qwen", ModelName: "qwen-max", APIKey: "persona-key", IsActive: true},
+	)
+	h := handlers[0]
+	h.userConfigService = service
+	h.deviceID = "device-1"
+	h.dialogueManager = chat.NewDialogueManager(h.logger, nil)
+
+	h.loadUserAIConfigurations()
+
+	if got := h.providers.llm.(llmConfigGetter).Config(); got.ModelName != "qwen-max" || got.APIKey != "persona-key" {
+		t.Errorf("主人格连接的LLM配置 = %+v", got)
+	}
+	if got := handlers[1].providers.llm.(llmConfigGetter).Config(); got.ModelName != "gpt-4o" || got.APIKey != "system-key" {
+		t.Errorf("其他连接的LLM配置被主人格修改: %+v", got)
+	}
+
+	h.Close()
+	if err := pm.ReturnProviderSet(h.ProviderSet()); err != nil {
+		t.Fatalf("归还提供者失败: %v", err)
+	}
+	set, err := pm.GetProviderSet()
+	if err != nil {
+		t.Fatalf("获取提供者失败: %v", err)
+	}
+	if got := set.LLM.(llmConfigGetter).Config(); got.ModelName != "gpt-4o" || got.APIKey != "system-key" {
+		t.Errorf("归还后的LLM配置 = %+v, 期望系统配置", got)
+	}
+}
@@ -0,0 +1,115 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// defaultASRConfirmTimeout hybrid 拾音模式下等待客户端确认的默认超时
+const defaultASRConfirmTimeout = 3 * time.Second
+
+// asrConfirmTimeout 等待客户端确认识别结果的超时时间
+func (h *ConnectionHandler) asrConfirmTimeout() time.Duration {
+	if h.config != nil && h.config.ASRConfirmTimeoutMs > 0 {
+		return time.Duration(h.config.ASRConfirmTimeoutMs) * time.Millisecond
+	}
+	return defaultASRConfirmTimeout
+}
+
+// requestASRConfirm 缓存识别结果并请求客户端确认，超时未确认时丢弃
+// 已有等待确认的结果时以新的结果为准
+func (h *ConnectionHandler) requestASRConfirm(text string) {
+	h.confirmMu.Lock()
+	if h.confirmTimer != nil {
+		h.confirmTimer.Stop()
+	}
+	h.confirmSeq++
+	seq := h.confirmSeq
+	h.pendingConfirmText = text
+	h.confirmTimer = time.AfterFunc(h.asrConfirmTimeout(), func() {
+		h.expireASRConfirm(seq)
+	})
+	h.confirmMu.Unlock()
+
+	if err := h.sendASRConfirmMessage(text); err != nil {
+		h.LogError(fmt.Sprintf("发送识别确认消息失败: %v", err))
+	}
+}
+
+// confirmASRText 客户端确认识别结果，返回等待确认的文本
+func (h *ConnectionHandler) confirmASRText() (string, bool) {
+	h.confirmMu.Lock()
+	defer h.confirmMu.Unlock()
+	text := h.pendingConfirmText
+	h.clearASRConfirmLocked()
+	return text, text != ""
+}
+
+// rejectASRText 客户端拒绝识别结果，丢弃后继续识别
+func (h *ConnectionHandler) rejectASRText() {
+	h.confirmMu.Lock()
+	text := h.pendingConfirmText
+	h.clearASRConfirmLocked()
+	h.confirmMu.Unlock()
+
+	if text != "" {
+		h.LogInfo(fmt.Sprintf("客户端拒绝识别结果: %s", text))
+		h.resumeASRAfterConfirm()
+	}
+}
+
+// expireASRConfirm 确认超时，丢弃识别结果；seq 不一致说明已重新请求确认或已处理
+func (h *ConnectionHandler) expireASRConfirm(seq uint64) {
+	h.confirmMu.Lock()
+	if seq != h.confirmSeq || h.pendingConfirmText == "" {
+		h.confirmMu.Unlock()
+		return
+	}
+	text := h.pendingConfirmText
+	h.clearASRConfirmLocked()
+	h.confirmMu.Unlock()
+
+	h.LogInfo(fmt.Sprintf("等待客户端确认超时，丢弃识别结果: %s", text))
+	h.resumeASRAfterConfirm()
+}
+
+// clearASRConfirm 清除等待确认的识别结果
+func (h *ConnectionHandler) clearASRConfirm() {
+	h.confirmMu.Lock()
+	defer h.confirmMu.Unlock()
+	h.clearASRConfirmLocked()
+}
+
+// clearASRConfirmLocked 调用方需持有 confirmMu
+func (h *ConnectionHandler) clearASRConfirmLocked() {
+	if h.confirmTimer != nil {
+		h.confirmTimer.Stop()
+		h.confirmTimer = nil
+	}
+	h.confirmSeq++
+	h.pendingConfirmText = ""
+}
+
+// resumeASRAfterConfirm 丢弃识别结果后重置ASR，准备下一次识别
+func (h *ConnectionHandler) resumeASRAfterConfirm() {
+	if h.providers.asr == nil {
+		return
+	}
+	if err := h.providers.asr.Reset(); err != nil {
+		h.LogError(fmt.Sprintf("重置ASR状态失败: %v", err))
+	}
+}
+
+// sendASRConfirmMessage 发送待确认的识别结果
+func (h *ConnectionHandler) sendASRConfirmMessage(text string) error {
+	jsonData, err := json.Marshal(map[string]interface{}{
+		"type":       "asr_confirm",
+		"text":       text,
+		"session_id": h.sessionID,
+	})
+	if err != nil {
+		return fmt.Errorf("序列化识别确认消息失败: %v", err)
+	}
+	return h.conn.WriteMessage(1, jsonData)
+}
@@ -0,0 +1,121 @@
+package core
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"angrymiao-ai-server/src/configs"
+	"angrymiao-ai-server/src/core/providers"
+	"angrymiao-ai-server/src/core/utils"
+)
+
+// recordingConn 记录下发的消息
+type recordingConn struct {
+	Connection
+	mu       sync.Mutex
+	messages [][]byte
+}
+
+func (c *recordingConn) WriteMessage(messageType int, data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.messages = append(c.messages, data)
+	return nil
+}
+
+// resetCountingASR 记录ASR重置次数
+type resetCountingASR struct {
+	providers.ASRProvider
+	mu     sync.Mutex
+	resets int
+}
+
+func (a *resetCountingASR) Reset() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.resets++
+	return nil
+}
+
+func (a *resetCountingASR) resetCount() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.resets
+}
+
+func newConfirmTestHandler(t *testing.T, timeoutMs int) (*ConnectionHandler, *recordingConn, *resetCountingASR) {
+	t.Helper()
+	logger, err := utils.NewLogger(&utils.LogCfg{LogLevel: "ERROR", LogDir: t.TempDir(), LogFile: "test.log"})
+	if err != nil {
+		t.Fatalf("创建日志失败: %v", err)
+	}
+	conn := &recordingConn{}
+	asr := &resetCountingASR{}
+	h := &ConnectionHandler{
+		logger:           logger,
+		config:           &configs.Config{ASRConfirmTimeoutMs: timeoutMs},
+		conn:             conn,
+		clientListenMode: "hybrid",
+	}
+	h.providers.asr = asr
+	return h, conn, asr
+}
+
+func TestASRConfirm(t *testing.T) {
+	h, conn, asr := newConfirmTestHandler(t, 3000)
+
+	h.requestASRConfirm("打开客厅的灯")
+	if len(conn.messages) != 1 {
+		t.Fatalf("下发消息数 = %d, 期望 1", len(conn.messages))
+	}
+	var msg map[string]string
+	if err := json.Unmarshal(conn.messages[0], &msg); err != nil {
+		t.Fatalf("解析确认消息失败: %v", err)
+	}
+	if msg["type"] != "asr_confirm" || msg["text"] != "打开客厅的灯" {
+		t.Errorf("确认消息 = %s", conn.messages[0])
+	}
+
+	text, ok := h.confirmASRText()
+	if !ok || text != "打开客厅的灯" {
+		t.Errorf("确认结果 = %q, %v", text, ok)
+	}
+	// 确认后缓存已清空，重复确认无效
+	if _, ok := h.confirmASRText(); ok {
+		t.Errorf("重复确认应返回false")
+	}
+	if asr.resetCount() != 0 {
+		t.Errorf("确认后不应重置ASR")
+	}
+}
+
+func TestASRConfirmReject(t *testing.T) {
+	h, _, asr := newConfirmTestHandler(t, 3000)
+
+	h.requestASRConfirm("打开客厅的灯")
+	h.rejectASRText()
+	if _, ok := h.confirmASRText(); ok {
+		t.Errorf("拒绝后缓存应被清空")
+	}
+	if asr.resetCount() != 1 {
+		t.Errorf("拒绝后ASR重置次数 = %d, 期望 1", asr.resetCount())
+	}
+}
+
+func TestASRConfirmTimeout(t *testing.T) {
+	h, _, asr := newConfirmTestHandler(t, 20)
+
+	h.requestASRConfirm("打开客厅的灯")
+	deadline := time.Now().Add(2 * time.Second)
+	for asr.resetCount() == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if asr.resetCount() != 1 {
+		t.Fatalf("超时后ASR重置次数 = %d, 期望 1", asr.resetCount())
+	}
+	if _, ok := h.confirmASRText(); ok {
+		t.Errorf("超时后缓存应被清空")
+	}
+}
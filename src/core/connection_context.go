@@ -0,0 +1,95 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"angrymiao-ai-server/src/core/botconfig"
+)
+
+// subscribeContextUpdates 订阅用户对话上下文变更（如导入外部对话记录）与对话历史删除，收到通知后告知客户端
+func (h *ConnectionHandler) subscribeContextUpdates() {
+	notifier, ok := botconfig.GetUpdateNotifier().(botconfig.ContextNotifier)
+	if !ok || h.userID == "" {
+		return
+	}
+
+	ctx, cancelCtx := context.WithTimeout(context.Background(), userConfigSubscribeTimeout)
+	defer cancelCtx()
+	updates, cancel, err := notifier.SubscribeContextUpdates(ctx, h.userID)
+	if err != nil {
+		h.logger.Warn("订阅用户 %s 的对话上下文变更失败: %v", h.userID, err)
+		return
+	}
+	h.unsubscribeContext = cancel
+
+	// 未订阅成功时 cleared 为nil，不会收到删除通知
+	var cleared <-chan struct{}
+	if clearNotifier, ok := notifier.(botconfig.ContextClearNotifier); ok {
+		var cancelCleared func()
+		if cleared, cancelCleared, err = clearNotifier.SubscribeContextCleared(ctx, h.userID); err != nil {
+			h.logger.Warn("订阅用户 %s 的对话历史删除失败: %v", h.userID, err)
+		} else {
+			h.unsubscribeContext = func() {
+				cancel()
+				cancelCleared()
+			}
+		}
+	}
+
+	go func() {
+		for {
+			select {
+			case <-h.stopChan:
+				return
+			case _, ok := <-updates:
+				if !ok {
+					return
+				}
+				h.logger.Info("收到用户 %s 的对话上下文变更通知", h.userID)
+				if err := h.sendContextUpdatedMessage(); err != nil {
+					h.LogError(fmt.Sprintf("发送上下文变更消息失败: %v", err))
+				}
+			case _, ok := <-cleared:
+				if !ok {
+					return
+				}
+				h.logger.Info("收到用户 %s 的对话历史删除通知", h.userID)
+				h.resetDialogueContext()
+				if err := h.sendContextMessage("context_cleared"); err != nil {
+					h.LogError(fmt.Sprintf("发送对话历史删除消息失败: %v", err))
+				}
+			}
+		}
+	}()
+}
+
+// resetDialogueContext 对话历史已在存储中删除，清空主对话与各Bot对话在内存中的上下文
+func (h *ConnectionHandler) resetDialogueContext() {
+	if h.dialogueManager != nil {
+		h.dialogueManager.ResetContext()
+	}
+	h.namespacedMu.Lock()
+	defer h.namespacedMu.Unlock()
+	for _, dm := range h.namespacedDialogues {
+		dm.ResetContext()
+	}
+}
+
+// sendContextUpdatedMessage 通知客户端对话历史已变更
+func (h *ConnectionHandler) sendContextUpdatedMessage() error {
+	return h.sendContextMessage("context_updated")
+}
+
+// sendContextMessage 发送对话上下文相关的通知消息
+func (h *ConnectionHandler) sendContextMessage(messageType string) error {
+	jsonData, err := json.Marshal(map[string]interface{}{
+		"type":       messageType,
+		"session_id": h.sessionID,
+	})
+	if err != nil {
+		return fmt.Errorf("序列化%s消息失败: %v", messageType, err)
+	}
+	return h.conn.WriteMessage(1, jsonData)
+}
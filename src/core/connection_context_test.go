@@ -0,0 +1,72 @@
+package core
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"angrymiao-ai-server/src/configs"
+	"angrymiao-ai-server/src/core/botconfig"
+	"angrymiao-ai-server/src/core/chat"
+)
+
+// contextNotifier 模拟对话上下文变更与历史删除的发布订阅
+type contextNotifier struct {
+	mockNotifier
+	cleared chan struct{}
+}
+
+func (n *contextNotifier) NotifyContextUpdate(ctx context.Context, userID string) error { return nil }
+
+func (n *contextNotifier) SubscribeContextUpdates(ctx context.Context, userID string) (<-chan struct{}, func(), error) {
+	return make(chan struct{}), func() {}, nil
+}
+
+func (n *contextNotifier) NotifyContextCleared(ctx context.Context, userID string) error {
+	n.cleared <- struct{}{}
+	return nil
+}
+
+func (n *contextNotifier) SubscribeContextCleared(ctx context.Context, userID string) (<-chan struct{}, func(), error) {
+	return n.cleared, func() {}, nil
+}
+
+func TestContextClearedResetsDialogue(t *testing.T) {
+	notifier := &contextNotifier{cleared: make(chan struct{}, 1)}
+	botconfig.SetUpdateNotifier(notifier)
+	defer botconfig.SetUpdateNotifier(nil)
+
+	h := newPrefixTestHandler(t, &configs.Config{})
+	h.userID = "42"
+	h.stopChan = make(chan struct{})
+	defer close(h.stopChan)
+	h.dialogueManager.SetSystemMessage("你是助手")
+	h.dialogueManager.Put(chat.Message{Role: "user", Content: "你好"})
+	h.GetNamespacedDialogue("weather").Put(chat.Message{Role: "user", Content: "明天下雨吗"})
+
+	h.subscribeContextUpdates()
+	notifier.NotifyContextCleared(context.Background(), "42")
+
+	conn := h.conn.(*recordingConn)
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		conn.mu.Lock()
+		sent := len(conn.messages) > 0 && strings.Contains(string(conn.messages[0]), `"type":"context_cleared"`)
+		conn.mu.Unlock()
+		if sent {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("未向客户端发送 context_cleared")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if dialogue := h.dialogueManager.GetLLMDialogue(); len(dialogue) != 1 || dialogue[0].Role != "system" {
+		t.Errorf("清空后主对话 = %+v, 期望只保留系统消息", dialogue)
+	}
+	if n := h.GetNamespacedDialogue("weather").Length(); n != 0 {
+		t.Errorf("清空后Bot对话剩余 %d 条", n)
+	}
+}
@@ -0,0 +1,68 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+const (
+	// defaultDedupWindowMs 默认的重复消息判定窗口
+	defaultDedupWindowMs = 500
+	// dedupHistorySize 参与去重比较的最近消息数
+	dedupHistorySize = 5
+)
+
+// dupEntry 最近收到的一条文本消息
+type dupEntry struct {
+	text string
+	at   time.Time
+}
+
+// textDedup 最近消息的环形缓冲，仅由文本消息处理协程访问
+type textDedup struct {
+	entries [dedupHistorySize]dupEntry
+	cursor  int
+}
+
+// isDuplicate 判断消息是否与窗口内的某条消息完全相同，不重复时记录该消息
+// 重复消息不记录，窗口从第一次收到时开始计算
+func (d *textDedup) isDuplicate(text string, now time.Time, window time.Duration) bool {
+	for _, e := range d.entries {
+		if !e.at.IsZero() && e.text == text && now.Sub(e.at) < window {
+			return true
+		}
+	}
+	d.entries[d.cursor] = dupEntry{text: text, at: now}
+	d.cursor = (d.cursor + 1) % dedupHistorySize
+	return false
+}
+
+func (h *ConnectionHandler) dedupWindow() time.Duration {
+	ms := h.config.DedupWindowMs
+	if ms <= 0 {
+		ms = defaultDedupWindowMs
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+// dropDuplicateText 客户端短时间内重复发送（双击、重试）的相同消息直接丢弃，并通知客户端
+func (h *ConnectionHandler) dropDuplicateText(text string) bool {
+	if !h.textDedup.isDuplicate(text, time.Now(), h.dedupWindow()) {
+		return false
+	}
+	h.LogInfo(fmt.Sprintf("丢弃重复的文本消息: %s", text))
+	jsonData, err := json.Marshal(map[string]interface{}{
+		"type":       "info",
+		"code":       "DUPLICATE_MESSAGE",
+		"session_id": h.sessionID,
+	})
+	if err != nil {
+		h.LogError(fmt.Sprintf("序列化重复消息通知失败: %v", err))
+		return true
+	}
+	if err := h.conn.WriteMessage(1, jsonData); err != nil {
+		h.LogError(fmt.Sprintf("发送重复消息通知失败: %v", err))
+	}
+	return true
+}
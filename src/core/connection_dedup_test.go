@@ -0,0 +1,72 @@
+package core
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"angrymiao-ai-server/src/configs"
+	"angrymiao-ai-server/src/core/utils"
+)
+
+func TestTextDedupWindow(t *testing.T) {
+	var d textDedup
+	window := 500 * time.Millisecond
+	start := time.Now()
+	msg := `{"type":"listen","state":"detect","text":"你好"}`
+
+	if d.isDuplicate(msg, start, window) {
+		t.Fatal("首条消息不应判定为重复")
+	}
+	if !d.isDuplicate(msg, start.Add(200*time.Millisecond), window) {
+		t.Error("窗口内的相同消息应判定为重复")
+	}
+	if d.isDuplicate(`{"type":"listen","state":"detect","text":"再见"}`, start.Add(300*time.Millisecond), window) {
+		t.Error("不同的消息不应判定为重复")
+	}
+	if d.isDuplicate(msg, start.Add(600*time.Millisecond), window) {
+		t.Error("窗口外的相同消息应放行")
+	}
+
+	// 只比较最近5条消息
+	now := start.Add(time.Second)
+	d.isDuplicate("a", now, window)
+	for _, text := range []string{"b", "c", "d", "e", "f"} {
+		if d.isDuplicate(text, now, window) {
+			t.Fatalf("不同的消息 %q 不应判定为重复", text)
+		}
+	}
+	if d.isDuplicate("a", now, window) {
+		t.Error("移出环形缓冲的消息不应判定为重复")
+	}
+}
+
+func TestDropDuplicateTextNotifiesClient(t *testing.T) {
+	logger, err := utils.NewLogger(&utils.LogCfg{LogLevel: "ERROR", LogDir: t.TempDir(), LogFile: "test.log"})
+	if err != nil {
+		t.Fatalf("创建日志失败: %v", err)
+	}
+	conn := &recordingConn{}
+	h := &ConnectionHandler{
+		logger:    logger,
+		config:    &configs.Config{DedupWindowMs: 500},
+		conn:      conn,
+		sessionID: "s1",
+	}
+
+	msg := `{"type":"listen","state":"detect","text":"你好"}`
+	if h.dropDuplicateText(msg) {
+		t.Fatal("首条消息不应被丢弃")
+	}
+	if !h.dropDuplicateText(msg) {
+		t.Fatal("双击重复发送的消息应被丢弃")
+	}
+	if len(conn.messages) != 1 {
+		t.Fatalf("下发消息数 = %d, 期望 1", len(conn.messages))
+	}
+	var info map[string]interface{}
+	json.Unmarshal(conn.messages[0], &info)
+	if info["type"] != "info" || info["code"] != "DUPLICATE_MESSAGE" {
+		t.Errorf("重复消息通知 = %v", info)
+	}
+}
@@ -0,0 +1,97 @@
+package core
+
+import (
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"angrymiao-ai-server/src/core/chat"
+)
+
+// botDialogueMaxMessages Bot好友对话发送给LLM的最近消息数（不含系统提示词）
+const botDialogueMaxMessages = 10
+
+// defaultDialogFileDir file 存储未配置目录时的默认目录
+const defaultDialogFileDir = "data/dialogues"
+
+// newDialogueBackend 根据配置创建对话存储后端，未配置或初始化失败时返回 NullBackend（仅内存）
+func (h *ConnectionHandler) newDialogueBackend(key string) chat.MemoryBackend {
+	switch strings.ToLower(h.config.DialogStorage) {
+	case "postgres", "sqlite":
+		return chat.NewPostgresBackend(chat.NewPostgresMemory(key))
+	case "redis":
+		if h.config.RedisCache.Addr == "" {
+			h.logger.Warn("Redis未配置，回退到内存模式")
+			return chat.NewNullBackend()
+		}
+		mem, err := chat.NewRedisMemory(h.config.RedisCache, h.logger, key)
+		if err != nil {
+			h.logger.Warn("初始化Redis记忆失败: %v，使用内存模式", err)
+			return chat.NewNullBackend()
+		}
+		return chat.NewRedisBackend(mem)
+	case "file":
+		dir := h.config.DialogFileDir
+		if dir == "" {
+			dir = defaultDialogFileDir
+		}
+		backend, err := chat.NewFileBackend(filepath.Join(dir, url.PathEscape(key)+".jsonl"))
+		if err != nil {
+			h.logger.Warn("初始化文件记忆失败: %v，使用内存模式", err)
+			return chat.NewNullBackend()
+		}
+		return backend
+	default:
+		h.logger.Warn("未选择对话存储模式")
+		return chat.NewNullBackend()
+	}
+}
+
+// closeDialogueManager 关闭主对话管理器的存储后端
+func (h *ConnectionHandler) closeDialogueManager() {
+	if h.dialogueManager == nil {
+		return
+	}
+	if err := h.dialogueManager.Close(); err != nil {
+		h.logger.Warn("关闭对话存储失败: %v", err)
+	}
+}
+
+// GetNamespacedDialogue 获取或创建会话内指定Bot的对话管理器
+// 主对话管理器不受影响，持久化时使用 "userID:botName" 作为存储键；用户ID为空时仅保存在内存
+func (h *ConnectionHandler) GetNamespacedDialogue(botName string) *chat.DialogueManager {
+	h.namespacedMu.Lock()
+	defer h.namespacedMu.Unlock()
+
+	if dm, ok := h.namespacedDialogues[botName]; ok {
+		return dm
+	}
+
+	var backend chat.MemoryBackend
+	if h.userID != "" {
+		backend = h.newDialogueBackend(chat.DialogueNamespace(h.userID, botName))
+	}
+	dm := chat.NewDialogueManagerWithBackend(h.logger, backend)
+	if h.namespacedDialogues == nil {
+		h.namespacedDialogues = make(map[string]*chat.DialogueManager)
+	}
+	h.namespacedDialogues[botName] = dm
+	h.logger.Debug("创建Bot对话管理器: %s:%s", h.sessionID, botName)
+	return dm
+}
+
+// flushNamespacedDialogues 连接关闭时释放所有Bot对话管理器
+// 消息在 Put 时已逐条写入存储，这里只关闭存储后端并清理内存中的对话
+func (h *ConnectionHandler) flushNamespacedDialogues() {
+	h.namespacedMu.Lock()
+	defer h.namespacedMu.Unlock()
+	if len(h.namespacedDialogues) > 0 {
+		h.logger.Debug("释放 %d 个Bot对话管理器", len(h.namespacedDialogues))
+	}
+	for botName, dm := range h.namespacedDialogues {
+		if err := dm.Close(); err != nil {
+			h.logger.Warn("关闭Bot对话存储失败: %s, %v", botName, err)
+		}
+	}
+	h.namespacedDialogues = nil
+}
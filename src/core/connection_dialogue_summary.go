@@ -0,0 +1,53 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"angrymiao-ai-server/src/core/chat"
+)
+
+const (
+	// dialogueSummaryTimeout 后台生成对话历史摘要的超时时间
+	dialogueSummaryTimeout = 15 * time.Second
+	// dialogueSummaryKeepLast 生成摘要时原样保留的最近消息数
+	dialogueSummaryKeepLast = 6
+)
+
+const dialogueSummaryPrompt = "请将以下对话压缩为一段简洁的摘要，保留用户的需求、偏好以及已确认的关键信息，只输出摘要：\n%s"
+
+// maybeSummarizeDialogue 对话历史超过token上限时在后台将较早的对话压缩为摘要，不阻塞本轮回复
+func (h *ConnectionHandler) maybeSummarizeDialogue() {
+	if h.dialogueManager == nil || h.providers.llm == nil || !h.dialogueManager.NeedsSummary() {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), dialogueSummaryTimeout)
+		defer cancel()
+		if err := h.dialogueManager.SummarizeOlderMessages(ctx, dialogueSummaryKeepLast); err != nil {
+			h.LogError(fmt.Sprintf("压缩对话历史失败: %v", err))
+		}
+	}()
+}
+
+// summarizeDialogueMessages 调用LLM为较早的对话生成摘要，已有的摘要会一并合并
+func (h *ConnectionHandler) summarizeDialogueMessages(ctx context.Context, messages []chat.Message) (string, error) {
+	var builder strings.Builder
+	for _, msg := range messages {
+		if msg.Content == "" {
+			continue
+		}
+		if chat.IsSummaryMessage(msg) {
+			fmt.Fprintf(&builder, "summary: %s\n", strings.TrimPrefix(msg.Content, chat.SummaryPrefix))
+			continue
+		}
+		fmt.Fprintf(&builder, "%s: %s\n", msg.Role, msg.Content)
+	}
+	transcript := strings.TrimSpace(builder.String())
+	if transcript == "" {
+		return "", fmt.Errorf("没有可压缩的对话内容")
+	}
+	return h.completeLLMPrompt(ctx, fmt.Sprintf(dialogueSummaryPrompt, transcript))
+}
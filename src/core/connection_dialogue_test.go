@@ -0,0 +1,55 @@
+package core
+
+import (
+	"testing"
+
+	"angrymiao-ai-server/src/configs"
+	"angrymiao-ai-server/src/core/chat"
+	"angrymiao-ai-server/src/core/utils"
+)
+
+func TestNamespacedDialogueIsolation(t *testing.T) {
+	logger, err := utils.NewLogger(&utils.LogCfg{LogLevel: "ERROR", LogDir: t.TempDir(), LogFile: "test.log"})
+	if err != nil {
+		t.Fatalf("创建日志失败: %v", err)
+	}
+	h := &ConnectionHandler{
+		config:          &configs.Config{},
+		logger:          logger,
+		sessionID:       "session-1",
+		userID:          "42",
+		dialogueManager: chat.NewDialogueManager(logger, nil),
+	}
+
+	weather := h.GetNamespacedDialogue("weather")
+	translator := h.GetNamespacedDialogue("translator")
+	if weather == translator || h.GetNamespacedDialogue("weather") != weather {
+		t.Fatal("同一Bot应复用对话管理器，不同Bot应互相独立")
+	}
+
+	weather.Put(chat.Message{Role: "user", Content: "今天天气"})
+	weather.Put(chat.Message{Role: "assistant", Content: "晴"})
+	translator.Put(chat.Message{Role: "user", Content: "hello"})
+
+	if got := weather.GetLLMDialogue(); len(got) != 2 || got[0].Content != "今天天气" {
+		t.Errorf("weather 对话 = %+v", got)
+	}
+	if got := translator.GetLLMDialogue(); len(got) != 1 || got[0].Content != "hello" {
+		t.Errorf("translator 对话 = %+v", got)
+	}
+	if h.dialogueManager.Length() != 0 {
+		t.Errorf("主对话不应包含Bot消息: %+v", h.dialogueManager.GetLLMDialogue())
+	}
+
+	h.flushNamespacedDialogues()
+	if h.GetNamespacedDialogue("weather").Length() != 0 {
+		t.Error("关闭后应释放Bot对话管理器")
+	}
+
+	if got := chat.DialogueNamespace("42", "weather"); got != "42:weather" {
+		t.Errorf("DialogueNamespace = %q", got)
+	}
+	if got := chat.DialogueNamespace("42", ""); got != "42" {
+		t.Errorf("主会话存储键 = %q", got)
+	}
+}
@@ -0,0 +1,43 @@
+package core
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"angrymiao-ai-server/src/core/utils"
+)
+
+// initEchoCanceller 开启 echo_cancellation_enabled 时按客户端音频参数创建回声消除器
+// 仅支持单声道上行音频
+func (h *ConnectionHandler) initEchoCanceller() {
+	if !h.config.EchoCancellationEnabled {
+		return
+	}
+	if h.clientAudioChannels > 1 {
+		h.LogInfo(fmt.Sprintf("客户端音频为%d声道，不启用回声消除", h.clientAudioChannels))
+		return
+	}
+	h.echoCanceller = utils.NewEchoCanceller(h.clientAudioSampleRate)
+	h.LogInfo(fmt.Sprintf("回声消除已启用, sample_rate=%d", h.clientAudioSampleRate))
+}
+
+// addEchoReference 记录即将播放的TTS音频，作为回声消除的参考信号
+func (h *ConnectionHandler) addEchoReference(pcm []byte, sampleRate int) {
+	if h.echoCanceller == nil {
+		return
+	}
+	h.echoCanceller.AddReference(pcm, sampleRate)
+}
+
+// cancelEcho 服务端播放期间对客户端上行PCM做回声消除，在送入VAD与ASR之前调用
+// 服务端停止播放时丢弃剩余的参考信号
+func (h *ConnectionHandler) cancelEcho(pcm []byte) []byte {
+	if h.echoCanceller == nil {
+		return pcm
+	}
+	if atomic.LoadInt32(&h.serverVoiceStop) == 1 {
+		h.echoCanceller.Reset()
+		return pcm
+	}
+	return h.echoCanceller.Process(pcm)
+}
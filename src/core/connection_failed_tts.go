@@ -0,0 +1,88 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"angrymiao-ai-server/src/core/utils"
+)
+
+// maxFailedTTSEntries 每个会话保留的失败TTS分段数量
+const maxFailedTTSEntries = 20
+
+// ErrFailedTTSNotFound 指定索引的失败TTS分段不存在
+var ErrFailedTTSNotFound = errors.New("失败的TTS分段不存在")
+
+// FailedTTSEntry 合成失败的TTS分段
+type FailedTTSEntry struct {
+	Text      string    `json:"text"`
+	TextIndex int       `json:"text_index"`
+	Round     int       `json:"round"`
+	Error     string    `json:"error"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// recordFailedTTS 记录合成失败的分段，超过上限时丢弃最早的记录
+func (h *ConnectionHandler) recordFailedTTS(text string, textIndex int, round int, err error) {
+	h.failedTTSMu.Lock()
+	defer h.failedTTSMu.Unlock()
+	h.failedTTSLog = append(h.failedTTSLog, FailedTTSEntry{
+		Text:      text,
+		TextIndex: textIndex,
+		Round:     round,
+		Error:     err.Error(),
+		Timestamp: time.Now(),
+	})
+	if n := len(h.failedTTSLog); n > maxFailedTTSEntries {
+		h.failedTTSLog = append([]FailedTTSEntry(nil), h.failedTTSLog[n-maxFailedTTSEntries:]...)
+	}
+}
+
+// FailedTTS 返回本会话最近合成失败的TTS分段，按失败时间排序
+func (h *ConnectionHandler) FailedTTS() []FailedTTSEntry {
+	h.failedTTSMu.Lock()
+	defer h.failedTTSMu.Unlock()
+	return append([]FailedTTSEntry{}, h.failedTTSLog...)
+}
+
+// takeFailedTTS 取出指定索引最近一次失败的分段
+func (h *ConnectionHandler) takeFailedTTS(textIndex int) (FailedTTSEntry, bool) {
+	h.failedTTSMu.Lock()
+	defer h.failedTTSMu.Unlock()
+	for i := len(h.failedTTSLog) - 1; i >= 0; i-- {
+		if h.failedTTSLog[i].TextIndex == textIndex {
+			entry := h.failedTTSLog[i]
+			h.failedTTSLog = append(h.failedTTSLog[:i], h.failedTTSLog[i+1:]...)
+			return entry, true
+		}
+	}
+	return FailedTTSEntry{}, false
+}
+
+// RetryFailedTTS 重新合成指定索引的失败分段，成功后加入音频发送队列
+// 原轮次通常已结束，音频按当前轮次发送，避免被当作过期音频丢弃
+// 再次失败时会重新记录到失败列表
+func (h *ConnectionHandler) RetryFailedTTS(textIndex int) error {
+	entry, ok := h.takeFailedTTS(textIndex)
+	if !ok {
+		return ErrFailedTTSNotFound
+	}
+	h.LogInfo(fmt.Sprintf("重试失败的TTS分段: %s, 索引: %d, 原轮次: %d", entry.Text, entry.TextIndex, entry.Round))
+
+	round := h.GetTalkRound()
+	filepath := h.synthesizeTTS(entry.Text, entry.TextIndex, round)
+	if filepath == "" {
+		return fmt.Errorf("重新合成TTS失败, 索引: %d", textIndex)
+	}
+	utils.MarkAudioFileActive(filepath)
+	h.audioMessagesQueue <- struct {
+		filepath     string
+		text         string
+		round        int
+		textIndex    int
+		paragraphEnd bool
+		stream       <-chan []byte
+	}{filepath, entry.Text, round, entry.TextIndex, false, nil}
+	return nil
+}
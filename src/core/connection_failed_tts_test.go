@@ -0,0 +1,115 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"angrymiao-ai-server/src/configs"
+	"angrymiao-ai-server/src/core/providers"
+	"angrymiao-ai-server/src/core/utils"
+)
+
+// flakyTTS 前 failures 次合成失败，之后返回固定文件路径
+type flakyTTS struct {
+	providers.TTSProvider
+	failures int
+	calls    int
+}
+
+func (p *flakyTTS) ToTTS(text string) (string, error) {
+	p.calls++
+	if p.calls <= p.failures {
+		return "", errors.New("tts服务不可用")
+	}
+	return "tmp/" + text + ".wav", nil
+}
+
+func newFailedTTSTestHandler(t *testing.T, failures int) *ConnectionHandler {
+	t.Helper()
+	logger, err := utils.NewLogger(&utils.LogCfg{LogLevel: "ERROR", LogDir: t.TempDir(), LogFile: "test.log"})
+	if err != nil {
+		t.Fatalf("创建日志失败: %v", err)
+	}
+	h := &ConnectionHandler{
+		logger: logger,
+		config: &configs.Config{},
+		audioMessagesQueue: make(chan struct {
+			filepath     string
+			text         string
+			round        int
+			textIndex    int
+			paragraphEnd bool
+			stream       <-chan []byte
+		}, 10),
+	}
+	h.providers.tts = &flakyTTS{failures: failures}
+	return h
+}
+
+func TestProcessTTSTaskRecordsFailure(t *testing.T) {
+	h := newFailedTTSTestHandler(t, 1)
+
+	h.processTTSTask("你好", 2, 3, false)
+
+	entries := h.FailedTTS()
+	if len(entries) != 1 {
+		t.Fatalf("失败记录数 = %d, 期望 1", len(entries))
+	}
+	got := entries[0]
+	if got.Text != "你好" || got.TextIndex != 2 || got.Round != 3 || got.Error != "tts服务不可用" || got.Timestamp.IsZero() {
+		t.Errorf("失败记录 = %+v", got)
+	}
+	// 失败的分段仍以空路径入队，保证本轮能正常结束
+	if task := <-h.audioMessagesQueue; task.filepath != "" {
+		t.Errorf("失败分段入队路径 = %q, 期望为空", task.filepath)
+	}
+}
+
+func TestFailedTTSKeepsLatestEntries(t *testing.T) {
+	h := newFailedTTSTestHandler(t, 0)
+	for i := 0; i < maxFailedTTSEntries+5; i++ {
+		h.recordFailedTTS(fmt.Sprintf("分段%d", i), i, 1, errors.New("失败"))
+	}
+	entries := h.FailedTTS()
+	if len(entries) != maxFailedTTSEntries || entries[0].TextIndex != 5 || entries[len(entries)-1].TextIndex != maxFailedTTSEntries+4 {
+		t.Errorf("应只保留最近 %d 条, got %d 条, 首条索引 %d", maxFailedTTSEntries, len(entries), entries[0].TextIndex)
+	}
+}
+
+func TestRetryFailedTTSQueuesAudio(t *testing.T) {
+	h := newFailedTTSTestHandler(t, 1)
+	h.processTTSTask("你好", 2, 1, false)
+	<-h.audioMessagesQueue
+	h.talkRound = 2
+
+	if err := h.RetryFailedTTS(2); err != nil {
+		t.Fatalf("重试失败: %v", err)
+	}
+	task := <-h.audioMessagesQueue
+	if task.filepath != "tmp/你好.wav" || task.textIndex != 2 || task.round != 2 {
+		t.Errorf("重试入队任务 = %+v, 期望按当前轮次发送", task)
+	}
+	if n := len(h.FailedTTS()); n != 0 {
+		t.Errorf("重试成功后应移除失败记录, 剩余 %d 条", n)
+	}
+	if err := h.RetryFailedTTS(2); !errors.Is(err, ErrFailedTTSNotFound) {
+		t.Errorf("再次重试应返回 ErrFailedTTSNotFound, got %v", err)
+	}
+}
+
+func TestRetryFailedTTSFailsAgain(t *testing.T) {
+	h := newFailedTTSTestHandler(t, 2)
+	h.processTTSTask("你好", 1, 1, false)
+	<-h.audioMessagesQueue
+
+	if err := h.RetryFailedTTS(1); err == nil {
+		t.Fatal("再次合成失败时应返回错误")
+	}
+	if len(h.audioMessagesQueue) != 0 {
+		t.Error("合成失败时不应入队")
+	}
+	if n := len(h.FailedTTS()); n != 1 {
+		t.Errorf("再次失败应保留一条失败记录, got %d", n)
+	}
+}
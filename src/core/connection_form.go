@@ -0,0 +1,161 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"angrymiao-ai-server/src/configs/database"
+	"angrymiao-ai-server/src/models"
+)
+
+const (
+	formRepromptPrefix = "回答格式不正确，"
+	formCompletePrompt = "好的，信息已全部记录"
+	// 识别结果末尾的标点不作为回答内容
+	formAnswerTrimChars = "。，,.!！?？ "
+)
+
+// FormField 表单中的一个字段，Validation 为校验回答的正则表达式，为空时不校验
+type FormField struct {
+	Name       string `json:"name"`
+	Prompt     string `json:"prompt"`
+	Validation string `json:"validation,omitempty"`
+}
+
+// FormSession 对话式表单，依次播报字段提示并把识别结果作为当前字段的回答
+type FormSession struct {
+	Fields       []FormField
+	Collected    map[string]string
+	CurrentField int
+
+	patterns []*regexp.Regexp // 与 Fields 一一对应，未配置校验时为nil
+}
+
+// NewFormSession 校验字段定义并编译校验规则
+func NewFormSession(fields []FormField) (*FormSession, error) {
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("表单字段为空")
+	}
+	patterns := make([]*regexp.Regexp, len(fields))
+	for i, field := range fields {
+		if field.Name == "" || field.Prompt == "" {
+			return nil, fmt.Errorf("第%d个表单字段缺少 name 或 prompt", i+1)
+		}
+		if field.Validation == "" {
+			continue
+		}
+		pattern, err := regexp.Compile(field.Validation)
+		if err != nil {
+			return nil, fmt.Errorf("表单字段 %s 的校验规则无效: %v", field.Name, err)
+		}
+		patterns[i] = pattern
+	}
+	return &FormSession{
+		Fields:    fields,
+		Collected: make(map[string]string, len(fields)),
+		patterns:  patterns,
+	}, nil
+}
+
+// handleFormStartMessage 处理 form_start 消息，开始填写表单并播报第一个字段的提示
+// 已有正在填写的表单时以新的表单为准
+func (h *ConnectionHandler) handleFormStartMessage(msgMap map[string]interface{}) error {
+	raw, err := json.Marshal(msgMap["fields"])
+	if err != nil {
+		return fmt.Errorf("表单字段格式错误: %v", err)
+	}
+	var fields []FormField
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return fmt.Errorf("表单字段格式错误: %v", err)
+	}
+	session, err := NewFormSession(fields)
+	if err != nil {
+		return err
+	}
+
+	h.formMu.Lock()
+	h.formSession = session
+	h.formMu.Unlock()
+	h.LogInfo(fmt.Sprintf("开始填写表单，共%d个字段", len(fields)))
+	return h.SystemSpeak(fields[0].Prompt)
+}
+
+// handleFormAnswer 将识别结果作为当前字段的回答，没有正在填写的表单时返回false
+// 校验失败时重新播报当前字段的提示，全部字段填写完成后下发 form_complete 并保存提交记录
+func (h *ConnectionHandler) handleFormAnswer(text string) bool {
+	h.formMu.Lock()
+	session := h.formSession
+	if session == nil {
+		h.formMu.Unlock()
+		return false
+	}
+	answer := strings.TrimRight(strings.TrimSpace(text), formAnswerTrimChars)
+	field := session.Fields[session.CurrentField]
+	if pattern := session.patterns[session.CurrentField]; pattern != nil && !pattern.MatchString(answer) {
+		h.formMu.Unlock()
+		h.LogInfo(fmt.Sprintf("表单字段 %s 的回答未通过校验: %s", field.Name, answer))
+		h.sendSTTMessage(text)
+		h.SystemSpeak(formRepromptPrefix + field.Prompt)
+		return true
+	}
+	session.Collected[field.Name] = answer
+	session.CurrentField++
+	completed := session.CurrentField >= len(session.Fields)
+	if completed {
+		h.formSession = nil
+	}
+	h.formMu.Unlock()
+
+	h.sendSTTMessage(text)
+	if !completed {
+		h.SystemSpeak(session.Fields[session.CurrentField].Prompt)
+		return true
+	}
+	h.LogInfo(fmt.Sprintf("表单填写完成，共%d个字段", len(session.Collected)))
+	if err := h.sendFormCompleteMessage(session.Collected); err != nil {
+		h.LogError(fmt.Sprintf("发送表单完成消息失败: %v", err))
+	}
+	h.saveFormSubmission(session.Collected)
+	h.SystemSpeak(formCompletePrompt)
+	return true
+}
+
+// abortFormSession 客户端中止时放弃正在填写的表单
+func (h *ConnectionHandler) abortFormSession() {
+	h.formMu.Lock()
+	session := h.formSession
+	h.formSession = nil
+	h.formMu.Unlock()
+	if session != nil {
+		h.LogInfo(fmt.Sprintf("放弃填写表单，已填写%d个字段", len(session.Collected)))
+	}
+}
+
+func (h *ConnectionHandler) sendFormCompleteMessage(data map[string]string) error {
+	jsonData, err := json.Marshal(map[string]interface{}{
+		"type": "form_complete",
+		"data": data,
+	})
+	if err != nil {
+		return fmt.Errorf("序列化表单完成消息失败: %v", err)
+	}
+	return h.conn.WriteMessage(1, jsonData)
+}
+
+// saveFormSubmission 保存表单提交记录，未初始化数据库时跳过
+func (h *ConnectionHandler) saveFormSubmission(data map[string]string) {
+	if database.DB == nil {
+		return
+	}
+	raw, err := json.Marshal(data)
+	if err != nil {
+		h.LogError(fmt.Sprintf("序列化表单提交记录失败: %v", err))
+		return
+	}
+	record := models.FormSubmission{UserID: h.userID, DeviceID: h.deviceID, SessionID: h.sessionID, Data: raw}
+	if err := database.DB.Create(&record).Error; err != nil {
+		h.LogError(fmt.Sprintf("保存表单提交记录失败: %v", err))
+	}
+}
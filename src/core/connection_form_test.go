@@ -0,0 +1,92 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"angrymiao-ai-server/src/configs"
+	"angrymiao-ai-server/src/configs/database"
+	"angrymiao-ai-server/src/models"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestFormSessionRepromptsOnValidationFailure(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "form.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("打开测试数据库失败: %v", err)
+	}
+	if err := db.AutoMigrate(&models.FormSubmission{}); err != nil {
+		t.Fatalf("迁移失败: %v", err)
+	}
+	original := database.DB
+	database.DB = db
+	t.Cleanup(func() { database.DB = original })
+
+	h := newPrefixTestHandler(t, &configs.Config{})
+	h.userID = "42"
+	conn := h.conn.(*recordingConn)
+
+	var start map[string]interface{}
+	json.Unmarshal([]byte(`{"type":"form_start","fields":[
+		{"name":"name","prompt":"请问您的姓名"},
+		{"name":"phone","prompt":"请说出您的手机号","validation":"^1\\d{10}$"},
+		{"name":"city","prompt":"您在哪个城市"}]}`), &start)
+	if err := h.handleFormStartMessage(start); err != nil {
+		t.Fatalf("开始表单失败: %v", err)
+	}
+	for _, answer := range []string{"张三", "一二三", "13800138000。", "上海"} {
+		if err := h.handleChatMessage(context.Background(), answer); err != nil {
+			t.Fatalf("回答 %q 失败: %v", answer, err)
+		}
+	}
+
+	want := []string{"请问您的姓名", "请说出您的手机号", "回答格式不正确，请说出您的手机号", "您在哪个城市", "好的，信息已全部记录"}
+	if got := queuedSegments(h); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("播报的提示 = %v, 期望 %v", got, want)
+	}
+
+	var complete struct {
+		Type string            `json:"type"`
+		Data map[string]string `json:"data"`
+	}
+	for _, message := range conn.messages {
+		if strings.Contains(string(message), `"form_complete"`) {
+			json.Unmarshal(message, &complete)
+		}
+	}
+	if complete.Data["name"] != "张三" || complete.Data["phone"] != "13800138000" || complete.Data["city"] != "上海" {
+		t.Errorf("form_complete = %+v", complete)
+	}
+
+	var rows []models.FormSubmission
+	db.Where("user_id = ?", "42").Find(&rows)
+	if len(rows) != 1 || !strings.Contains(string(rows[0].Data), `"phone":"13800138000"`) {
+		t.Errorf("表单提交记录 = %+v", rows)
+	}
+	if h.formSession != nil {
+		t.Error("填写完成后应结束表单")
+	}
+}
+
+func TestFormSessionAbort(t *testing.T) {
+	h := newPrefixTestHandler(t, &configs.Config{})
+	var start map[string]interface{}
+	json.Unmarshal([]byte(`{"type":"form_start","fields":[{"name":"name","prompt":"请问您的姓名"}]}`), &start)
+	if err := h.handleFormStartMessage(start); err != nil {
+		t.Fatalf("开始表单失败: %v", err)
+	}
+	h.abortFormSession()
+	if h.handleFormAnswer("张三") {
+		t.Error("中止后识别结果不应再作为表单回答")
+	}
+
+	start["fields"] = []interface{}{map[string]interface{}{"name": "phone", "prompt": "手机号", "validation": "("}}
+	if err := h.handleFormStartMessage(start); err == nil {
+		t.Error("无效的校验规则应返回错误")
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"angrymiao-ai-server/src/httpsvr/vision"
 	"context"
 	"encoding/json"
+	"sync/atomic"
 )
 
 func (h *ConnectionHandler) initMCPResultHandlers() {
@@ -55,7 +56,7 @@ func (h *ConnectionHandler) mcp_handler_play_music(args interface{}) {
 			h.SystemSpeak("没有找到名为" + songName + "的歌曲")
 		} else {
 			//h.SystemSpeak("这就为您播放音乐: " + songName)
-			h.sendAudioMessage(path, name, h.tts_last_text_index, h.talkRound)
+			h.sendAudioMessage(path, name, int(atomic.LoadInt32(&h.tts_last_text_index)), h.GetTalkRound())
 		}
 	} else {
 		h.logger.Error("mcp_handler_play_music: args is not a string")
@@ -123,7 +124,7 @@ func (h *ConnectionHandler) mcp_handler_take_photo(args interface{}) {
 
 	if !visionResponse.Success {
 		h.logger.Error("拍照失败: %s", visionResponse.Message)
-		h.genResponseByLLM(context.Background(), h.dialogueManager.GetLLMDialogue(), h.talkRound)
+		h.genResponseByLLM(context.Background(), h.dialogueManager.GetLLMDialogue(), h.GetTalkRound())
 
 	}
 
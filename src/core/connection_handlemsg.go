@@ -1,9 +1,11 @@
 package core
 
 import (
+	"angrymiao-ai-server/src/configs"
 	"angrymiao-ai-server/src/configs/database"
 	"angrymiao-ai-server/src/core/chat"
 	"angrymiao-ai-server/src/core/image"
+	"angrymiao-ai-server/src/core/mcp"
 	"angrymiao-ai-server/src/core/media"
 	"angrymiao-ai-server/src/core/providers"
 	"angrymiao-ai-server/src/core/utils"
@@ -13,6 +15,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
+	"sync/atomic"
 	"time"
 )
 
@@ -20,43 +23,51 @@ import (
 func (h *ConnectionHandler) handleMessage(messageType int, message []byte) error {
 	switch messageType {
 	case 1: // 文本消息
-		// 优先尝试解析为 JSON，若为 MCP 消息则投递到独立队列，避免文本处理协程阻塞
+		// 优先尝试解析为 JSON，若为 MCP 消息则投递到独立队列，避免文本处理协程阻塞；心跳 pong 直接处理
 		var msgJSON interface{}
 		if err := json.Unmarshal(message, &msgJSON); err == nil {
 			if msgMap, ok := msgJSON.(map[string]interface{}); ok {
-				if msgType, ok := msgMap["type"].(string); ok && msgType == "mcp" {
+				switch msgMap["type"] {
+				case "mcp":
 					h.mcpMessageQueue <- msgMap
 					return nil
+				case "pong":
+					// 心跳回复只更新时间，不进入文本处理队列
+					h.handlePongMessage()
+					return nil
 				}
 			}
 		}
 		h.clientTextQueue <- string(message)
 		return nil
 	case 2: // 二进制消息（音频数据）
+		receivedAt := time.Now().UnixNano() // 服务端收到帧的时间，用于延迟归因
 		actualAudioData := message
 		if h.clientAudioFormat == "pcm" {
 			// 直接将PCM数据放入队列
-			h.clientAudioQueue <- actualAudioData
+			h.enqueueClientAudio(actualAudioData, receivedAt)
 		} else if h.clientAudioFormat == "opus" {
 			// 检查是否初始化了opus解码器
 			if h.opusDecoder != nil {
 				// 解码opus数据为PCM
 				decodedData, err := h.opusDecoder.Decode(actualAudioData)
 				if err != nil {
+					h.codecStats.RecordDecodeError()
 					h.logger.Error(fmt.Sprintf("解码Opus音频失败: %v", err))
 					// 即使解码失败，也尝试将原始数据传递给ASR处理
-					h.clientAudioQueue <- actualAudioData
+					h.enqueueClientAudio(actualAudioData, receivedAt)
 				} else {
 					// 解码成功，将PCM数据放入队列
+					h.codecStats.RecordFrame(len(actualAudioData), len(decodedData))
 					h.logger.Debug(fmt.Sprintf("Opus解码成功: %d bytes -> %d bytes", len(actualAudioData), len(decodedData)))
 					if len(decodedData) > 0 {
-						h.clientAudioQueue <- decodedData
+						h.enqueueClientAudio(decodedData, receivedAt)
 						h.LogInfo(fmt.Sprintf("✓ Opus解码后的PCM数据已放入队列: size=%d", len(decodedData)))
 					}
 				}
 			} else {
 				// 没有解码器，直接传递原始数据
-				h.clientAudioQueue <- actualAudioData
+				h.enqueueClientAudio(actualAudioData, receivedAt)
 				h.LogInfo(fmt.Sprintf("✓ 原始音频数据已放入队列（无解码器）: size=%d", len(actualAudioData)))
 			}
 		}
@@ -96,7 +107,10 @@ func (h *ConnectionHandler) processClientTextMessage(ctx context.Context, text s
 	case "hello":
 		return h.handleHelloMessage(msgMap)
 	case "abort":
+		h.abortFormSession()
 		return h.clientAbortChat()
+	case "form_start":
+		return h.handleFormStartMessage(msgMap)
 	case "listen":
 		return h.handleListenMessage(msgMap)
 	case "chat":
@@ -244,8 +258,9 @@ func (h *ConnectionHandler) handleHelloMessage(msgMap map[string]interface{}) er
 		if frameDuration, ok := audioParams["frame_duration"].(float64); ok {
 			h.clientAudioFrameDuration = int(frameDuration)
 		}
-		h.LogInfo(fmt.Sprintf("客户端音频参数: format=%s, sample_rate=%d, channels=%d, frame_duration=%d",
-			h.clientAudioFormat, h.clientAudioSampleRate, h.clientAudioChannels, h.clientAudioFrameDuration))
+		h.clientStreamingAudio, _ = audioParams["streaming"].(bool)
+		h.LogInfo(fmt.Sprintf("客户端音频参数: format=%s, sample_rate=%d, channels=%d, frame_duration=%d, streaming=%t",
+			h.clientAudioFormat, h.clientAudioSampleRate, h.clientAudioChannels, h.clientAudioFrameDuration, h.clientStreamingAudio))
 	}
 
 	// 处理客户端提供的UDP地址信息（用于NAT穿透）
@@ -261,7 +276,33 @@ func (h *ConnectionHandler) handleHelloMessage(msgMap map[string]interface{}) er
 		}
 	}
 
+	// 客户端指定的默认对话语言
+	if lang, ok := msgMap["preferred_language"].(string); ok && lang != "" {
+		h.setPreferredLanguage(lang)
+	}
+
+	// 客户端指定的会话级MCP服务，首次调用工具时再建立连接
+	if mcpURL, ok := msgMap["mcp_server_url"].(string); ok && mcpURL != "" {
+		mcpToken, _ := msgMap["mcp_server_token"].(string)
+		sessionMCP, err := mcp.NewSessionManager(h.logger, mcpURL, mcpToken, h.config.SessionMCP)
+		if err != nil {
+			h.LogError(fmt.Sprintf("[MCP] [会话] 拒绝客户端指定的MCP服务: %v", err))
+			h.closeSessionMCP()
+		} else {
+			h.setSessionMCP(sessionMCP)
+			h.LogInfo(fmt.Sprintf("[MCP] [会话] 使用客户端指定的MCP服务: %s", mcpURL))
+		}
+	}
+
+	// 客户端请求实时音量电平，用于设备UI显示音量条
+	if streaming, _ := msgMap["streaming_vad_level"].(bool); streaming && h.enableVAD {
+		h.startVADLevelMeter()
+	} else {
+		h.stopVADLevelMeter()
+	}
+
 	h.sendHelloMessage()
+	h.deliverPendingOTA()
 	h.closeOpusDecoder()
 	// 初始化opus解码器
 	opusDecoder, err := utils.NewOpusDecoder(&utils.OpusDecoderConfig{
@@ -274,6 +315,7 @@ func (h *ConnectionHandler) handleHelloMessage(msgMap map[string]interface{}) er
 		h.opusDecoder = opusDecoder
 		h.LogInfo("Opus解码器初始化成功")
 	}
+	h.initEchoCanceller()
 
 	// 在 hello 消息处理时就设置 ASR listener，避免依赖 listen 消息
 	// 这样即使客户端不发送 listen 消息，ASR 也能正常工作
@@ -358,8 +400,19 @@ func (h *ConnectionHandler) handleListenMessage(msgMap map[string]interface{}) e
 			h.clientAbortChat()
 		}
 		h.client_asr_text = ""
+		h.clearASRConfirm()
+	case "confirm":
+		text, ok := h.confirmASRText()
+		if !ok {
+			h.logger.Warn("没有等待确认的识别结果，忽略confirm消息")
+			return nil
+		}
+		return h.handleChatMessage(context.Background(), text)
+	case "reject":
+		h.rejectASRText()
 	case "stop":
 		// 重置ASR状态，停止语音识别
+		h.flushLanguageDetection()
 		h.providers.asr.SendLastAudio([]byte{}) // 发送空数据标记结束
 		h.LogInfo("客户端停止语音识别")
 		// if h.providers.asr != nil {
@@ -371,7 +424,10 @@ func (h *ConnectionHandler) handleListenMessage(msgMap map[string]interface{}) e
 	case "detect":
 		text, hasText := msgMap["text"].(string)
 
-		if hasText && text != "" {
+		if imageURL, _ := msgMap["image_url"].(string); imageURL != "" {
+			// 携带图片URL，优先使用VLLLM处理
+			return h.handleDetectImageURL(context.Background(), text, imageURL)
+		} else if hasText && text != "" {
 			// 只有文本，使用普通LLM处理
 			h.LogInfo(fmt.Sprintf("检测到纯文本消息，使用LLM处理 %v", map[string]interface{}{
 				"text": text,
@@ -389,8 +445,7 @@ func (h *ConnectionHandler) handleListenMessage(msgMap map[string]interface{}) e
 // handleImageMessage 处理图片消息
 func (h *ConnectionHandler) handleImageMessage(ctx context.Context, msgMap map[string]interface{}) error {
 	// 增加对话轮次
-	h.talkRound++
-	currentRound := h.talkRound
+	currentRound := int(atomic.AddInt32(&h.talkRound, 1))
 	h.LogInfo(fmt.Sprintf("开始新的图片对话轮次: %d", currentRound))
 
 	// 检查是否有VLLLM Provider
@@ -421,12 +476,40 @@ func (h *ConnectionHandler) handleImageMessage(ctx context.Context, msgMap map[s
 	if format, ok := imageDataMap["format"].(string); ok {
 		imageData.Format = format
 	}
+	if rawID, ok := imageDataMap["media_id"]; ok && imageData.URL == "" && imageData.Data == "" {
+		mediaID, valid := parseMediaID(rawID)
+		if !valid {
+			return fmt.Errorf("无效的media_id: %v", rawID)
+		}
+		mediaImage, err := h.mediaImageData(ctx, mediaID)
+		if err != nil {
+			return err
+		}
+		imageData = mediaImage
+	}
 
 	// 验证图片数据
 	if imageData.URL == "" && imageData.Data == "" {
 		return fmt.Errorf("图片数据为空")
 	}
 
+	return h.chatWithImage(ctx, imageData, text, currentRound)
+}
+
+// chatWithImage 预处理图片后发送识别结果，并使用VLLLM生成回复
+func (h *ConnectionHandler) chatWithImage(ctx context.Context, imageData image.ImageData, text string, currentRound int) error {
+	// 缩放、转换格式并压缩后再提交
+	var security *configs.SecurityConfig
+	if cfg := h.providers.vlllm.GetConfig(); cfg != nil {
+		security = &cfg.Security
+	}
+	opts := image.PreprocessOptionsFromConfig(security)
+	processed, err := image.PreprocessImageData(imageData, opts, h.logger)
+	if err != nil {
+		return fmt.Errorf("图片预处理失败: %v", err)
+	}
+	imageData = processed
+
 	h.LogInfo(fmt.Sprintf("收到图片消息 %v", map[string]interface{}{
 		"text":        text,
 		"has_url":     imageData.URL != "",
@@ -436,7 +519,7 @@ func (h *ConnectionHandler) handleImageMessage(ctx context.Context, msgMap map[s
 	}))
 
 	// 立即发送STT消息
-	err := h.sendSTTMessage(text)
+	err = h.sendSTTMessage(text)
 	if err != nil {
 		h.logger.Error(fmt.Sprintf("发送STT消息失败: %v", err))
 		return fmt.Errorf("发送STT消息失败: %v", err)
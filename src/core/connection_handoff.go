@@ -0,0 +1,216 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"angrymiao-ai-server/src/core/chat"
+	"angrymiao-ai-server/src/core/providers"
+	"angrymiao-ai-server/src/core/providers/llm"
+	"angrymiao-ai-server/src/core/types"
+)
+
+// createHandoffLLM 创建接管对话的Bot使用的LLM，测试中可替换
+var createHandoffLLM = func(config *llm.Config) (providers.LLMProvider, error) {
+	return llm.Create(config.Type, config)
+}
+
+// conversationContext 一层对话上下文：对话历史与生成回复的LLM
+type conversationContext struct {
+	bot      string // 接管对话的Bot函数名，主对话为空
+	dialogue *chat.DialogueManager
+	llm      providers.LLMProvider
+}
+
+// ConversationRouter 会话内多Bot对话的上下文栈
+// 转交时将当前上下文压栈，返回时弹出恢复；栈中保存的是被暂停的上下文
+type ConversationRouter struct {
+	mu    sync.Mutex
+	stack []conversationContext
+}
+
+// NewConversationRouter 创建对话路由
+func NewConversationRouter() *ConversationRouter {
+	return &ConversationRouter{}
+}
+
+// Push 暂停当前上下文
+func (r *ConversationRouter) Push(c conversationContext) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stack = append(r.stack, c)
+}
+
+// Pop 取出最近暂停的上下文，栈为空时返回false
+func (r *ConversationRouter) Pop() (conversationContext, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.stack) == 0 {
+		return conversationContext{}, false
+	}
+	c := r.stack[len(r.stack)-1]
+	r.stack = r.stack[:len(r.stack)-1]
+	return c, true
+}
+
+// Depth 当前转交层数，0 表示处于主对话
+func (r *ConversationRouter) Depth() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.stack)
+}
+
+// parseHandoffAction 识别工具返回的转交指令，其他结果返回false
+func parseHandoffAction(result interface{}) (types.ActionResponse, bool) {
+	var data []byte
+	switch v := result.(type) {
+	case string:
+		data = []byte(v)
+	case map[string]interface{}:
+		data, _ = json.Marshal(v)
+	default:
+		return types.ActionResponse{}, false
+	}
+	var req types.HandoffRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		return types.ActionResponse{}, false
+	}
+	switch req.Type {
+	case types.HandoffTypeStart:
+		if req.TargetBot == "" {
+			return types.ActionResponse{}, false
+		}
+		return types.ActionResponse{Action: types.ActionTypeHandoff, Result: req}, true
+	case types.HandoffTypeReturn:
+		return types.ActionResponse{Action: types.ActionTypeHandoffReturn, Result: req}, true
+	}
+	return types.ActionResponse{}, false
+}
+
+// toolActionResult 将工具结果转换为动作，转交指令之外的结果交给LLM生成回复
+func toolActionResult(result interface{}) types.ActionResponse {
+	if action, ok := parseHandoffAction(result); ok {
+		return action
+	}
+	return types.ActionResponse{Action: types.ActionTypeReqLLM, Result: result}
+}
+
+// lastUserMessage 返回对话中最后一条用户消息
+func lastUserMessage(dialogue *chat.DialogueManager) string {
+	messages := dialogue.GetLLMDialogue()
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "user" {
+			return messages[i].Content
+		}
+	}
+	return ""
+}
+
+// lastAssistantReply 返回对话中最后一条助手文本回复
+func lastAssistantReply(dialogue *chat.DialogueManager) string {
+	messages := dialogue.GetLLMDialogue()
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "assistant" && messages[i].Content != "" {
+			return messages[i].Content
+		}
+	}
+	return ""
+}
+
+// startHandoff 暂停当前对话，将用户的消息转交给目标Bot回复
+// 之后的用户消息都由目标Bot处理，直到其工具返回 handoff_return
+func (h *ConnectionHandler) startHandoff(req types.HandoffRequest, functionCallData map[string]interface{}) {
+	bot := h.findUserConfig(req.TargetBot)
+	if bot == nil {
+		h.LogError(fmt.Sprintf("转交目标Bot不存在: %s", req.TargetBot))
+		h.addToolCallMessage(fmt.Sprintf("转交失败，Bot %s 不存在", req.TargetBot), functionCallData)
+		h.genResponseByLLM(context.Background(), h.dialogueManager.GetLLMDialogue(), h.GetTalkRound())
+		return
+	}
+	provider, err := createHandoffLLM(h.botLLMConfig(bot))
+	if err != nil {
+		h.LogError(fmt.Sprintf("创建转交Bot的LLM失败: %v", err))
+		h.addToolCallMessage(fmt.Sprintf("转交失败: %v", err), functionCallData)
+		h.genResponseByLLM(context.Background(), h.dialogueManager.GetLLMDialogue(), h.GetTalkRound())
+		return
+	}
+	provider.SetIdentityFlag("session", h.sessionID)
+
+	userMessage := lastUserMessage(h.dialogueManager)
+	h.addToolCallMessage(fmt.Sprintf("已转交给 %s", req.TargetBot), functionCallData)
+
+	dialogue := h.GetNamespacedDialogue(req.TargetBot)
+	systemPrompt := bot.Description
+	if req.Context != "" {
+		systemPrompt = fmt.Sprintf("%s\n以下是转交给你的对话背景: %s", systemPrompt, req.Context)
+	}
+	dialogue.SetSystemMessage(systemPrompt)
+
+	if h.conversationRouter == nil {
+		h.conversationRouter = NewConversationRouter()
+	}
+	h.conversationRouter.Push(conversationContext{dialogue: h.dialogueManager, llm: h.providers.llm})
+	h.dialogueManager = dialogue
+	h.providers.llm = provider
+	h.LogInfo(fmt.Sprintf("对话转交给Bot: %s, 当前层数: %d", req.TargetBot, h.conversationRouter.Depth()))
+
+	h.routeUserMessage(userMessage)
+}
+
+// returnHandoff 结束当前Bot的接管，恢复上一层对话并由其回复用户的消息
+func (h *ConnectionHandler) returnHandoff(functionCallData map[string]interface{}) {
+	if h.conversationRouter == nil || h.conversationRouter.Depth() == 0 {
+		h.LogError("当前不在转交对话中，忽略 handoff_return")
+		h.addToolCallMessage("当前不在转交对话中", functionCallData)
+		h.genResponseByLLM(context.Background(), h.dialogueManager.GetLLMDialogue(), h.GetTalkRound())
+		return
+	}
+	h.addToolCallMessage("已返回原对话", functionCallData)
+	userMessage := lastUserMessage(h.dialogueManager)
+	reply := lastAssistantReply(h.dialogueManager)
+
+	h.restoreConversation()
+	if reply != "" {
+		// 让原对话知道接管期间的最后回复
+		h.dialogueManager.Put(chat.Message{Role: "assistant", Content: reply})
+	}
+	h.LogInfo(fmt.Sprintf("转交对话结束，返回上一层，当前层数: %d", h.conversationRouter.Depth()))
+
+	h.routeUserMessage(userMessage)
+}
+
+// restoreConversation 弹出上一层对话上下文并释放当前Bot的LLM
+func (h *ConnectionHandler) restoreConversation() bool {
+	parent, ok := h.conversationRouter.Pop()
+	if !ok {
+		return false
+	}
+	if err := h.providers.llm.Cleanup(); err != nil {
+		h.logger.Warn("清理转交Bot的LLM资源失败: %v", err)
+	}
+	h.dialogueManager = parent.dialogue
+	h.providers.llm = parent.llm
+	return true
+}
+
+// closeHandoffs 连接关闭时恢复主对话，保证资源池中的LLM被正常归还
+func (h *ConnectionHandler) closeHandoffs() {
+	if h.conversationRouter == nil {
+		return
+	}
+	for h.restoreConversation() {
+	}
+}
+
+// routeUserMessage 将用户的消息交给当前对话的LLM回复
+func (h *ConnectionHandler) routeUserMessage(text string) {
+	if text == "" {
+		return
+	}
+	h.dialogueManager.Put(chat.Message{Role: "user", Content: text})
+	if err := h.genResponseByLLM(context.Background(), h.dialogueManager.GetLLMDialogue(), h.GetTalkRound()); err != nil {
+		h.LogError(fmt.Sprintf("转交后生成回复失败: %v", err))
+	}
+}
@@ -0,0 +1,159 @@
+package core
+
+import (
+	"context"
+	"testing"
+
+	"angrymiao-ai-server/src/configs"
+	"angrymiao-ai-server/src/core/chat"
+	"angrymiao-ai-server/src/core/providers"
+	"angrymiao-ai-server/src/core/providers/llm"
+	"angrymiao-ai-server/src/core/types"
+
+	"github.com/angrymiao/go-openai"
+)
+
+// handoffLLM 以固定前缀回复最后一条用户消息，并记录是否已被清理
+type handoffLLM struct {
+	providers.LLMProvider
+	name    string
+	cleaned bool
+}
+
+func (m *handoffLLM) ResponseWithFunctions(ctx context.Context, sessionID string, messages []providers.Message, tools []openai.Tool) (<-chan types.Response, error) {
+	ch := make(chan types.Response, 1)
+	ch <- types.Response{Content: m.name + "：" + messages[len(messages)-1].Content}
+	close(ch)
+	return ch, nil
+}
+
+func (m *handoffLLM) Capabilities() map[string]bool       { return map[string]bool{} }
+func (m *handoffLLM) SetIdentityFlag(idType, flag string) {}
+func (m *handoffLLM) Cleanup() error                      { m.cleaned = true; return nil }
+
+func newHandoffTestHandler(t *testing.T) (*ConnectionHandler, map[uint]*handoffLLM) {
+	t.Helper()
+	h := newPrefixTestHandler(t, &configs.Config{})
+	h.providers.llm = &handoffLLM{name: "主助手"}
+	h.userConfigs = []*types.BotConfig{
+		{ID: 1, FunctionName: "translator", Description: "你是翻译", IsActive: true},
+		{ID: 2, FunctionName: "poet", Description: "你是诗人", IsActive: true},
+	}
+	created := make(map[uint]*handoffLLM)
+	original := createHandoffLLM
+	createHandoffLLM = func(config *llm.Config) (providers.LLMProvider, error) {
+		var id uint
+		for _, bot := range h.userConfigs {
+			if config.Name == h.botLLMConfig(bot).Name {
+				id = bot.ID
+			}
+		}
+		created[id] = &handoffLLM{name: map[uint]string{1: "翻译", 2: "诗人"}[id]}
+		return created[id], nil
+	}
+	t.Cleanup(func() { createHandoffLLM = original })
+	return h, created
+}
+
+func handoffCall(id string) map[string]interface{} {
+	return map[string]interface{}{"id": id, "name": "orchestrator", "arguments": "{}"}
+}
+
+func TestParseHandoffAction(t *testing.T) {
+	action, ok := parseHandoffAction(`{"type":"handoff","target_bot":"translator","context":"用户要翻译菜单"}`)
+	req, _ := action.Result.(types.HandoffRequest)
+	if !ok || action.Action != types.ActionTypeHandoff || req.TargetBot != "translator" || req.Context != "用户要翻译菜单" {
+		t.Errorf("解析转交指令 = %+v, %v", action, ok)
+	}
+	if action, ok := parseHandoffAction(map[string]interface{}{"type": "handoff_return"}); !ok || action.Action != types.ActionTypeHandoffReturn {
+		t.Errorf("解析返回指令 = %+v, %v", action, ok)
+	}
+	for _, result := range []interface{}{"今天晴", `{"type":"handoff"}`, `{"type":"other"}`, 42} {
+		if action := toolActionResult(result); action.Action != types.ActionTypeReqLLM {
+			t.Errorf("%v 应交给LLM生成回复, got %v", result, action.Action)
+		}
+	}
+}
+
+func TestHandoffChainAndReturn(t *testing.T) {
+	h, created := newHandoffTestHandler(t)
+	mainDialogue := h.dialogueManager
+	mainLLM := h.providers.llm
+	h.dialogueManager.Put(chat.Message{Role: "user", Content: "帮我翻译你好"})
+
+	// 主对话转交给翻译Bot，当前用户消息由翻译Bot回复
+	h.handleFunctionResult(toolActionResult(`{"type":"handoff","target_bot":"translator","context":"中译英"}`), handoffCall("call-1"), 0)
+	if h.conversationRouter.Depth() != 1 || h.dialogueManager != h.GetNamespacedDialogue("translator") || h.providers.llm != created[1] {
+		t.Fatalf("转交后应切换到翻译Bot的对话上下文")
+	}
+	if reply := assistantHistory(h); reply != "翻译：帮我翻译你好" {
+		t.Errorf("翻译Bot回复 = %q", reply)
+	}
+	if system := h.dialogueManager.GetLLMDialogue()[0].Content; system != "你是翻译\n以下是转交给你的对话背景: 中译英" {
+		t.Errorf("翻译Bot系统提示词 = %q", system)
+	}
+
+	// 翻译Bot继续转交给诗人Bot
+	h.dialogueManager.Put(chat.Message{Role: "user", Content: "写首诗"})
+	h.handleFunctionResult(toolActionResult(`{"type":"handoff","target_bot":"poet"}`), handoffCall("call-2"), 0)
+	if h.conversationRouter.Depth() != 2 || h.providers.llm != created[2] {
+		t.Fatalf("第二次转交后应切换到诗人Bot")
+	}
+	if reply := assistantHistory(h); reply != "诗人：写首诗" {
+		t.Errorf("诗人Bot回复 = %q", reply)
+	}
+
+	// 诗人Bot返回翻译Bot
+	h.dialogueManager.Put(chat.Message{Role: "user", Content: "继续翻译"})
+	h.handleFunctionResult(toolActionResult(`{"type":"handoff_return"}`), handoffCall("call-3"), 0)
+	if h.conversationRouter.Depth() != 1 || h.providers.llm != created[1] || !created[2].cleaned {
+		t.Fatalf("返回后应恢复翻译Bot并清理诗人Bot的LLM")
+	}
+	if reply := assistantHistory(h); reply != "翻译：继续翻译" {
+		t.Errorf("返回后翻译Bot回复 = %q", reply)
+	}
+
+	// 翻译Bot返回主对话
+	h.dialogueManager.Put(chat.Message{Role: "user", Content: "谢谢"})
+	h.handleFunctionResult(toolActionResult(`{"type":"handoff_return"}`), handoffCall("call-4"), 0)
+	if h.conversationRouter.Depth() != 0 || h.dialogueManager != mainDialogue || h.providers.llm != mainLLM || !created[1].cleaned {
+		t.Fatalf("全部返回后应恢复主对话")
+	}
+	if reply := assistantHistory(h); reply != "主助手：谢谢" {
+		t.Errorf("主对话回复 = %q", reply)
+	}
+	// 主对话中保留了转交的工具调用与接管期间的最后回复
+	var sawHandoff, sawReply bool
+	for _, msg := range mainDialogue.GetLLMDialogue() {
+		sawHandoff = sawHandoff || (msg.Role == "tool" && msg.Content == "已转交给 translator")
+		sawReply = sawReply || (msg.Role == "assistant" && msg.Content == "翻译：继续翻译")
+	}
+	if !sawHandoff || !sawReply {
+		t.Errorf("主对话历史缺少转交记录: %+v", mainDialogue.GetLLMDialogue())
+	}
+}
+
+func TestHandoffUnknownBotStaysInCurrentDialogue(t *testing.T) {
+	h, _ := newHandoffTestHandler(t)
+	mainLLM := h.providers.llm
+	h.dialogueManager.Put(chat.Message{Role: "user", Content: "你好"})
+
+	h.handleFunctionResult(toolActionResult(`{"type":"handoff","target_bot":"unknown"}`), handoffCall("call-1"), 0)
+
+	if h.conversationRouter != nil || h.providers.llm != mainLLM {
+		t.Error("目标Bot不存在时不应切换对话")
+	}
+}
+
+func TestCloseRestoresMainLLM(t *testing.T) {
+	h, created := newHandoffTestHandler(t)
+	mainLLM := h.providers.llm
+	h.dialogueManager.Put(chat.Message{Role: "user", Content: "你好"})
+	h.handleFunctionResult(toolActionResult(`{"type":"handoff","target_bot":"translator"}`), handoffCall("call-1"), 0)
+
+	h.closeHandoffs()
+
+	if h.providers.llm != mainLLM || !created[1].cleaned {
+		t.Error("关闭连接时应恢复主对话LLM并清理转交Bot的LLM")
+	}
+}
@@ -0,0 +1,81 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// defaultHeartbeatTimeoutSeconds 未配置 heartbeat_timeout_seconds 时等待 pong 的秒数
+const defaultHeartbeatTimeoutSeconds = 10
+
+// heartbeatUnit 心跳配置的时间单位，测试中可缩短
+var heartbeatUnit = time.Second
+
+// heartbeatTimings 返回心跳间隔与等待 pong 的超时，间隔为0表示不发送心跳
+func (h *ConnectionHandler) heartbeatTimings() (time.Duration, time.Duration) {
+	if h.config == nil || h.config.Server.HeartbeatIntervalSeconds <= 0 {
+		return 0, 0
+	}
+	timeout := h.config.Server.HeartbeatTimeoutSeconds
+	if timeout <= 0 {
+		timeout = defaultHeartbeatTimeoutSeconds
+	}
+	return time.Duration(h.config.Server.HeartbeatIntervalSeconds) * heartbeatUnit, time.Duration(timeout) * heartbeatUnit
+}
+
+// startHeartbeatCoroutine 定期下发 {"type":"ping","ts":毫秒时间戳}，超时未收到 pong 时关闭会话
+// 与WebSocket协议层的ping不同，应用层心跳对所有传输层生效，可发现客户端静默断开的连接
+func (h *ConnectionHandler) startHeartbeatCoroutine() {
+	interval, timeout := h.heartbeatTimings()
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-h.stopChan:
+			return
+		case <-ticker.C:
+		}
+
+		sentAt := time.Now()
+		if err := h.sendPingMessage(sentAt); err != nil {
+			h.LogError(fmt.Sprintf("发送心跳ping失败: %v", err))
+		}
+
+		select {
+		case <-h.stopChan:
+			return
+		case <-time.After(timeout):
+		}
+		if atomic.LoadInt64(&h.lastPongAt) < sentAt.UnixNano() {
+			h.LogError(fmt.Sprintf("客户端 %v 内未回复心跳pong，关闭会话", timeout))
+			h.Close()
+			// 关闭底层连接，使阻塞在 ReadMessage 的主消息循环退出
+			if h.conn != nil {
+				h.conn.Close()
+			}
+			return
+		}
+	}
+}
+
+// handlePongMessage 记录客户端回复 pong 的时间
+func (h *ConnectionHandler) handlePongMessage() {
+	atomic.StoreInt64(&h.lastPongAt, time.Now().UnixNano())
+}
+
+// sendPingMessage 下发应用层心跳
+func (h *ConnectionHandler) sendPingMessage(now time.Time) error {
+	jsonData, err := json.Marshal(map[string]interface{}{
+		"type": "ping",
+		"ts":   now.UnixMilli(),
+	})
+	if err != nil {
+		return fmt.Errorf("序列化心跳消息失败: %v", err)
+	}
+	return h.conn.WriteMessage(1, jsonData)
+}
@@ -0,0 +1,143 @@
+package core
+
+import (
+	"encoding/json"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"angrymiao-ai-server/src/configs"
+)
+
+// closingConn 记录底层连接是否被关闭
+type closingConn struct {
+	recordingConn
+	closed int32
+}
+
+func (c *closingConn) Close() error {
+	atomic.StoreInt32(&c.closed, 1)
+	return nil
+}
+
+func newHeartbeatTestHandler(t *testing.T) (*ConnectionHandler, *closingConn) {
+	t.Helper()
+	orig := heartbeatUnit
+	heartbeatUnit = 10 * time.Millisecond
+	t.Cleanup(func() { heartbeatUnit = orig })
+
+	config := &configs.Config{}
+	config.Server.HeartbeatIntervalSeconds = 2 // 20ms
+	config.Server.HeartbeatTimeoutSeconds = 3  // 30ms
+	h := newPrefixTestHandler(t, config)
+	conn := &closingConn{}
+	h.conn = conn
+	h.stopChan = make(chan struct{})
+	h.clientTextQueue = make(chan string, 10)
+	return h, conn
+}
+
+func pingMessages(conn *closingConn) []map[string]interface{} {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	var pings []map[string]interface{}
+	for _, message := range conn.messages {
+		if !strings.Contains(string(message), `"ping"`) {
+			continue
+		}
+		var ping map[string]interface{}
+		json.Unmarshal(message, &ping)
+		pings = append(pings, ping)
+	}
+	return pings
+}
+
+func TestHeartbeatClosesSilentConnection(t *testing.T) {
+	h, conn := newHeartbeatTestHandler(t)
+
+	done := make(chan struct{})
+	go func() {
+		h.startHeartbeatCoroutine()
+		close(done)
+	}()
+
+	// 客户端静默断开，不回复pong：间隔20ms + 超时30ms 后应关闭会话
+	select {
+	case <-done:
+	case <-time.After(500 * time.Millisecond):
+		t.Fatal("未收到pong时心跳协程应在超时后退出")
+	}
+	select {
+	case <-h.stopChan:
+	default:
+		t.Error("心跳超时后应关闭会话")
+	}
+	if atomic.LoadInt32(&conn.closed) != 1 {
+		t.Error("心跳超时后应关闭底层连接")
+	}
+	pings := pingMessages(conn)
+	if len(pings) != 1 || pings[0]["type"] != "ping" || pings[0]["ts"] == nil {
+		t.Errorf("ping消息 = %v", pings)
+	}
+}
+
+func TestHeartbeatKeepsRespondingConnection(t *testing.T) {
+	h, conn := newHeartbeatTestHandler(t)
+
+	done := make(chan struct{})
+	go func() {
+		h.startHeartbeatCoroutine()
+		close(done)
+	}()
+
+	// 客户端持续回复pong，pong 不进入文本处理队列
+	deadline := time.After(200 * time.Millisecond)
+	replied := 0
+loop:
+	for {
+		select {
+		case <-done:
+			t.Fatal("客户端回复pong时不应关闭会话")
+		case <-deadline:
+			break loop
+		case <-time.After(5 * time.Millisecond):
+			if n := len(pingMessages(conn)); n > replied {
+				replied = n
+				if err := h.handleMessage(1, []byte(`{"type":"pong"}`)); err != nil {
+					t.Fatalf("处理pong失败: %v", err)
+				}
+			}
+		}
+	}
+	if replied < 2 {
+		t.Errorf("200ms 内只发送了 %d 次ping", replied)
+	}
+	if len(h.clientTextQueue) != 0 {
+		t.Errorf("pong 不应进入文本处理队列, 队列长度 = %d", len(h.clientTextQueue))
+	}
+
+	close(h.stopChan)
+	select {
+	case <-done:
+	case <-time.After(200 * time.Millisecond):
+		t.Error("会话关闭后心跳协程应退出")
+	}
+	if atomic.LoadInt32(&conn.closed) != 0 {
+		t.Error("正常回复pong的连接不应被关闭")
+	}
+}
+
+func TestHeartbeatDisabledByDefault(t *testing.T) {
+	h := newPrefixTestHandler(t, &configs.Config{})
+	done := make(chan struct{})
+	go func() {
+		h.startHeartbeatCoroutine()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(100 * time.Millisecond):
+		t.Error("未配置心跳间隔时应直接返回")
+	}
+}
@@ -0,0 +1,106 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"angrymiao-ai-server/src/configs"
+	"angrymiao-ai-server/src/configs/database"
+	"angrymiao-ai-server/src/core/image"
+	"angrymiao-ai-server/src/core/media"
+	"angrymiao-ai-server/src/models"
+)
+
+// mediaImagePresignTTL 引用已上传图片时预签名地址的有效期，只需覆盖一次VLLLM调用
+const mediaImagePresignTTL = 60 * time.Second
+
+// findMediaUpload 查询媒体上传记录，测试中可替换
+var findMediaUpload = func(id uint) (*models.MediaUpload, error) {
+	var record models.MediaUpload
+	if err := database.GetDB().First(&record, id).Error; err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// presignMediaURL 为媒体文件生成限时GET地址，测试中可替换
+var presignMediaURL = func(ctx context.Context, config *configs.OSSConfig, record *models.MediaUpload) (string, error) {
+	presign, err := media.NewOSSPresignService(config, nil, "")
+	if err != nil {
+		return "", err
+	}
+	if presign == nil {
+		return "", fmt.Errorf("未配置OSS访问密钥")
+	}
+	presigned, err := presign.Presign(ctx, record.ID, strings.TrimLeft(record.Path, "/"), mediaImagePresignTTL)
+	if err != nil {
+		return "", err
+	}
+	return presigned.URL, nil
+}
+
+// parseMediaID 解析 image_data.media_id，JSON数字或数字字符串均可
+func parseMediaID(value interface{}) (uint, bool) {
+	switch v := value.(type) {
+	case float64:
+		if v > 0 && v == float64(uint(v)) {
+			return uint(v), true
+		}
+	case string:
+		if id, err := strconv.ParseUint(v, 10, 32); err == nil && id > 0 {
+			return uint(id), true
+		}
+	}
+	return 0, false
+}
+
+// mediaImageData 根据 media_id 引用的用户图片生成预签名地址，设备无需重新上传图片数据
+func (h *ConnectionHandler) mediaImageData(ctx context.Context, mediaID uint) (image.ImageData, error) {
+	record, err := findMediaUpload(mediaID)
+	if err != nil {
+		return image.ImageData{}, fmt.Errorf("媒体文件不存在: %d", mediaID)
+	}
+	if strconv.FormatUint(uint64(record.UserID), 10) != h.userID {
+		return image.ImageData{}, fmt.Errorf("无权使用媒体文件: %d", mediaID)
+	}
+	if record.FileType != "image" {
+		if err := h.sendUnsupportedMediaTypeMessage(mediaID, record.FileType); err != nil {
+			h.LogError(fmt.Sprintf("发送媒体类型错误消息失败: %v", err))
+		}
+		return image.ImageData{}, fmt.Errorf("媒体文件 %d 不是图片: %s", mediaID, record.FileType)
+	}
+
+	var ossConfig configs.OSSConfig
+	if h.config != nil {
+		ossConfig = h.config.OSS
+	}
+	url, err := presignMediaURL(ctx, &ossConfig, record)
+	if err != nil {
+		return image.ImageData{}, fmt.Errorf("生成媒体预签名地址失败: %v", err)
+	}
+	format := strings.TrimPrefix(record.MimeType, "image/")
+	if format == "" || format == record.MimeType {
+		format = strings.TrimPrefix(path.Ext(record.Path), ".")
+	}
+	return image.ImageData{URL: url, Format: format}, nil
+}
+
+// sendUnsupportedMediaTypeMessage 通知客户端 media_id 引用的不是图片
+func (h *ConnectionHandler) sendUnsupportedMediaTypeMessage(mediaID uint, fileType string) error {
+	jsonData, err := json.Marshal(map[string]interface{}{
+		"type":       "error",
+		"code":       "UNSUPPORTED_MEDIA_TYPE",
+		"message":    fmt.Sprintf("不支持的媒体类型: %s", fileType),
+		"media_id":   mediaID,
+		"session_id": h.sessionID,
+	})
+	if err != nil {
+		return fmt.Errorf("序列化媒体类型错误消息失败: %v", err)
+	}
+	return h.conn.WriteMessage(1, jsonData)
+}
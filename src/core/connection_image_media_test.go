@@ -0,0 +1,102 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"angrymiao-ai-server/src/configs"
+	"angrymiao-ai-server/src/core/chat"
+	coreimage "angrymiao-ai-server/src/core/image"
+	"angrymiao-ai-server/src/core/providers"
+	"angrymiao-ai-server/src/core/providers/vlllm"
+	"angrymiao-ai-server/src/core/utils"
+	"angrymiao-ai-server/src/models"
+)
+
+func TestImageMessageWithMediaID(t *testing.T) {
+	logger, err := utils.NewLogger(&utils.LogCfg{LogLevel: "ERROR", LogDir: t.TempDir(), LogFile: "test.log"})
+	if err != nil {
+		t.Fatalf("创建日志失败: %v", err)
+	}
+
+	uploads := map[uint]*models.MediaUpload{
+		1: {ID: 1, UserID: 42, FileType: "image", Path: "/images/a.png", MimeType: "image/png"},
+		2: {ID: 2, UserID: 7, FileType: "image", Path: "/images/b.png", MimeType: "image/png"},
+		3: {ID: 3, UserID: 42, FileType: "audio", Path: "/audio/c.mp3", MimeType: "audio/mpeg"},
+	}
+	origFind, origPresign, origVLLM := findMediaUpload, presignMediaURL, vlllmResponseWithImage
+	defer func() { findMediaUpload, presignMediaURL, vlllmResponseWithImage = origFind, origPresign, origVLLM }()
+	findMediaUpload = func(id uint) (*models.MediaUpload, error) {
+		if record, ok := uploads[id]; ok {
+			return record, nil
+		}
+		return nil, fmt.Errorf("record not found")
+	}
+	presignMediaURL = func(ctx context.Context, config *configs.OSSConfig, record *models.MediaUpload) (string, error) {
+		return "https://oss.example.com" + record.Path + "?Expires=60", nil
+	}
+	var got []coreimage.ImageData
+	vlllmResponseWithImage = func(p *vlllm.Provider, ctx context.Context, sessionID string, messages []providers.Message, imageData coreimage.ImageData, text string) (<-chan string, error) {
+		got = append(got, imageData)
+		out := make(chan string, 1)
+		out <- "这是一张白色图片。"
+		close(out)
+		return out, nil
+	}
+
+	conn := &recordingConn{}
+	h := &ConnectionHandler{
+		logger:          logger,
+		config:          &configs.Config{},
+		conn:            conn,
+		userID:          "42",
+		dialogueManager: chat.NewDialogueManager(logger, nil),
+		ttsQueue: make(chan struct {
+			text         string
+			round        int
+			textIndex    int
+			paragraphEnd bool
+		}, 10),
+	}
+	h.providers.vlllm = &vlllm.Provider{}
+
+	imageMessage := func(mediaID interface{}) map[string]interface{} {
+		return map[string]interface{}{"type": "image", "text": "这是什么", "image_data": map[string]interface{}{"media_id": mediaID}}
+	}
+
+	if err := h.handleImageMessage(context.Background(), imageMessage(float64(1))); err != nil {
+		t.Fatalf("处理media_id图片消息失败: %v", err)
+	}
+	if len(got) != 1 || got[0].URL != "https://oss.example.com/images/a.png?Expires=60" || got[0].Format != "png" {
+		t.Fatalf("VLLLM收到的图片数据 = %+v", got)
+	}
+
+	// 其他用户的图片不能引用
+	if err := h.handleImageMessage(context.Background(), imageMessage(float64(2))); err == nil {
+		t.Error("引用其他用户的媒体文件应失败")
+	}
+	if err := h.handleImageMessage(context.Background(), imageMessage(float64(9))); err == nil {
+		t.Error("引用不存在的媒体文件应失败")
+	}
+
+	// 非图片媒体返回 UNSUPPORTED_MEDIA_TYPE
+	if err := h.handleImageMessage(context.Background(), imageMessage("3")); err == nil {
+		t.Error("引用音频文件应失败")
+	}
+	if len(got) != 1 {
+		t.Errorf("失败的请求不应调用VLLLM, 调用次数 = %d", len(got))
+	}
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	found := false
+	for _, message := range conn.messages {
+		if strings.Contains(string(message), `"UNSUPPORTED_MEDIA_TYPE"`) && strings.Contains(string(message), `"type":"error"`) {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("应向客户端发送 UNSUPPORTED_MEDIA_TYPE 错误消息")
+	}
+}
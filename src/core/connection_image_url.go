@@ -0,0 +1,130 @@
+package core
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"time"
+
+	"angrymiao-ai-server/src/core/image"
+	"angrymiao-ai-server/src/core/providers"
+	"angrymiao-ai-server/src/core/providers/vlllm"
+)
+
+const (
+	// imageURLMaxBytes detect 消息图片URL允许下载的最大字节数
+	imageURLMaxBytes = 5 * 1024 * 1024
+	// imageURLTimeout 下载图片URL的超时时间
+	imageURLTimeout = 5 * time.Second
+	// imageURLCacheTTL 已下载图片在会话内的缓存时间
+	imageURLCacheTTL = 5 * time.Minute
+)
+
+// imageURLCacheEntry 会话内缓存的图片内容
+type imageURLCacheEntry struct {
+	data      []byte
+	expiresAt time.Time
+}
+
+var imageURLClient = &http.Client{Timeout: imageURLTimeout}
+
+// fetchImageURL 下载图片URL，测试中可替换
+var fetchImageURL = func(ctx context.Context, rawURL string) ([]byte, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("无效的图片URL: %s", rawURL)
+	}
+	ctx, cancel := context.WithTimeout(ctx, imageURLTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := imageURLClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("下载图片失败: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("下载图片失败，HTTP状态码: %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, imageURLMaxBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("读取图片失败: %v", err)
+	}
+	if len(data) > imageURLMaxBytes {
+		return nil, fmt.Errorf("图片超过%dMB限制", imageURLMaxBytes/1024/1024)
+	}
+	return data, nil
+}
+
+// vlllmResponseWithImage 调用VLLLM生成图片回复，测试中可替换
+var vlllmResponseWithImage = func(p *vlllm.Provider, ctx context.Context, sessionID string, messages []providers.Message, imageData image.ImageData, text string) (<-chan string, error) {
+	return p.ResponseWithImage(ctx, sessionID, messages, imageData, text)
+}
+
+// loadImageURL 获取图片内容，同一会话5分钟内复用已下载的内容
+func (h *ConnectionHandler) loadImageURL(ctx context.Context, rawURL string) ([]byte, error) {
+	now := time.Now()
+	h.imageURLMu.Lock()
+	for key, entry := range h.imageURLCache {
+		if now.After(entry.expiresAt) {
+			delete(h.imageURLCache, key)
+		}
+	}
+	if entry, ok := h.imageURLCache[rawURL]; ok {
+		h.imageURLMu.Unlock()
+		return entry.data, nil
+	}
+	h.imageURLMu.Unlock()
+
+	data, err := fetchImageURL(ctx, rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	h.imageURLMu.Lock()
+	if h.imageURLCache == nil {
+		h.imageURLCache = make(map[string]*imageURLCacheEntry)
+	}
+	h.imageURLCache[rawURL] = &imageURLCacheEntry{data: data, expiresAt: now.Add(imageURLCacheTTL)}
+	h.imageURLMu.Unlock()
+	return data, nil
+}
+
+// handleDetectImageURL 处理携带 image_url 的 detect 消息
+// 配置了VLLLM时下载图片并交给VLLLM处理，否则只使用文本调用普通LLM
+func (h *ConnectionHandler) handleDetectImageURL(ctx context.Context, text string, imageURL string) error {
+	if h.providers.vlllm == nil {
+		h.logger.Warn("未配置VLLLM服务，忽略detect消息中的图片，仅处理文本")
+		if text == "" {
+			return fmt.Errorf("未配置VLLLM服务，无法处理图片")
+		}
+		return h.handleChatMessage(ctx, text)
+	}
+	if text == "" {
+		text = "请描述这张图片" // 默认提示
+	}
+
+	data, err := h.loadImageURL(ctx, imageURL)
+	if err != nil {
+		return err
+	}
+	format, err := image.DetectFormat(data)
+	if err != nil {
+		return err
+	}
+	imageData := image.ImageData{
+		URL:    imageURL,
+		Data:   base64.StdEncoding.EncodeToString(data),
+		Format: format,
+	}
+
+	currentRound := int(atomic.AddInt32(&h.talkRound, 1))
+	h.LogInfo(fmt.Sprintf("开始新的图片对话轮次: %d", currentRound))
+	return h.chatWithImage(ctx, imageData, text, currentRound)
+}
@@ -0,0 +1,109 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"angrymiao-ai-server/src/configs"
+	"angrymiao-ai-server/src/core/chat"
+	coreimage "angrymiao-ai-server/src/core/image"
+	"angrymiao-ai-server/src/core/providers"
+	"angrymiao-ai-server/src/core/providers/vlllm"
+	"angrymiao-ai-server/src/core/utils"
+)
+
+func pngBytes(t *testing.T) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	for i := range img.Pix {
+		img.Pix[i] = 0xff
+	}
+	img.Set(0, 0, color.RGBA{R: 0xff, A: 0xff})
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("编码PNG失败: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestDetectImageURLUsesVLLLMAndCachesDownload(t *testing.T) {
+	logger, err := utils.NewLogger(&utils.LogCfg{LogLevel: "ERROR", LogDir: t.TempDir(), LogFile: "test.log"})
+	if err != nil {
+		t.Fatalf("创建日志失败: %v", err)
+	}
+	data := pngBytes(t)
+
+	downloads := 0
+	origFetch, origVLLM := fetchImageURL, vlllmResponseWithImage
+	defer func() { fetchImageURL, vlllmResponseWithImage = origFetch, origVLLM }()
+	fetchImageURL = func(ctx context.Context, rawURL string) ([]byte, error) {
+		downloads++
+		return data, nil
+	}
+	var got []coreimage.ImageData
+	vlllmResponseWithImage = func(p *vlllm.Provider, ctx context.Context, sessionID string, messages []providers.Message, imageData coreimage.ImageData, text string) (<-chan string, error) {
+		got = append(got, imageData)
+		out := make(chan string, 1)
+		out <- "这是一张白色图片。"
+		close(out)
+		return out, nil
+	}
+
+	h := &ConnectionHandler{
+		logger:          logger,
+		config:          &configs.Config{},
+		conn:            &recordingConn{},
+		dialogueManager: chat.NewDialogueManager(logger, nil),
+		ttsQueue: make(chan struct {
+			text         string
+			round        int
+			textIndex    int
+			paragraphEnd bool
+		}, 10),
+	}
+	h.providers.vlllm = &vlllm.Provider{}
+
+	msg := map[string]interface{}{"type": "listen", "state": "detect", "text": "这是什么", "image_url": "https://example.com/a.png"}
+	for i := 0; i < 2; i++ {
+		if err := h.handleListenMessage(msg); err != nil {
+			t.Fatalf("处理detect消息失败: %v", err)
+		}
+	}
+
+	if downloads != 1 {
+		t.Errorf("同一会话内同一URL下载了%d次，期望1次", downloads)
+	}
+	if len(got) != 2 {
+		t.Fatalf("VLLLM调用次数 = %d, 期望 2", len(got))
+	}
+	if got[0].URL != "https://example.com/a.png" || got[0].Format != "png" {
+		t.Errorf("图片数据 URL=%q Format=%q", got[0].URL, got[0].Format)
+	}
+	if _, err := base64.StdEncoding.DecodeString(got[0].Data); err != nil || got[0].Data == "" {
+		t.Errorf("图片数据应为base64编码: %v", err)
+	}
+	if len(h.ttsQueue) != 2 {
+		t.Errorf("TTS队列长度 = %d, 期望 2", len(h.ttsQueue))
+	}
+}
+
+func TestFetchImageURLLimitsSize(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Repeat("x", imageURLMaxBytes+1)))
+	}))
+	defer server.Close()
+
+	if _, err := fetchImageURL(context.Background(), server.URL); err == nil {
+		t.Error("超过5MB的图片应下载失败")
+	}
+	if _, err := fetchImageURL(context.Background(), "file:///etc/passwd"); err == nil {
+		t.Error("非http(s)地址应被拒绝")
+	}
+}
@@ -0,0 +1,66 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"angrymiao-ai-server/src/core/chat"
+	"angrymiao-ai-server/src/core/providers"
+)
+
+// importanceLLMTimeout 一轮对话重要性评分的超时时间
+const importanceLLMTimeout = 10 * time.Second
+
+const importanceLLMPrompt = "Rate the importance of remembering this exchange on a scale 0-1: %s. Reply with just the number."
+
+// scoreLastExchange 异步为最近一轮对话评分，存储不支持评分时跳过
+// 需在添加助手回复后立即调用，以便在下一轮开始前确定要评分的消息
+func (h *ConnectionHandler) scoreLastExchange() {
+	if h.dialogueManager == nil || h.providers.llm == nil {
+		return
+	}
+	exchange, ok := h.dialogueManager.LastExchange()
+	if !ok {
+		return
+	}
+	dm := h.dialogueManager
+	go func() {
+		score, err := h.rateImportanceByLLM(exchange.Text)
+		if err != nil {
+			h.logger.Warn("对话重要性评分失败: %v", err)
+			return
+		}
+		if err := dm.SetImportance(exchange, score); err != nil {
+			h.logger.Warn("保存对话重要性评分失败: %v", err)
+		}
+	}()
+}
+
+// rateImportanceByLLM 调用一次LLM为对话评分
+func (h *ConnectionHandler) rateImportanceByLLM(exchange string) (float32, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), importanceLLMTimeout)
+	defer cancel()
+
+	messages := []providers.Message{
+		{Role: "user", Content: fmt.Sprintf(importanceLLMPrompt, exchange)},
+	}
+	responses, err := h.providers.llm.Response(ctx, h.sessionID, messages)
+	if err != nil {
+		return 0, err
+	}
+
+	var builder strings.Builder
+	for {
+		select {
+		case chunk, ok := <-responses:
+			if !ok {
+				return chat.ParseImportanceScore(builder.String())
+			}
+			builder.WriteString(chunk)
+		case <-ctx.Done():
+			return 0, fmt.Errorf("对话重要性评分超时: %v", ctx.Err())
+		}
+	}
+}
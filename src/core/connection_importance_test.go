@@ -0,0 +1,113 @@
+package core
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"angrymiao-ai-server/src/configs"
+	"angrymiao-ai-server/src/configs/database"
+	"angrymiao-ai-server/src/core/chat"
+	"angrymiao-ai-server/src/core/providers"
+	"angrymiao-ai-server/src/core/utils"
+	"angrymiao-ai-server/src/models"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// importanceLLM 提到生日的对话评为重要
+type importanceLLM struct {
+	providers.LLMProvider
+}
+
+func (m *importanceLLM) Response(ctx context.Context, sessionID string, messages []providers.Message) (<-chan string, error) {
+	score := "0.1"
+	if strings.Contains(messages[0].Content, "生日") {
+		score = "0.9"
+	}
+	ch := make(chan string, 1)
+	ch <- score
+	close(ch)
+	return ch, nil
+}
+
+func TestImportanceScoringKeepsImportantEarlyMessages(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "memory.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("打开测试数据库失败: %v", err)
+	}
+	if err := db.AutoMigrate(&models.DialogueMessage{}, &models.BotConfig{}); err != nil {
+		t.Fatalf("迁移失败: %v", err)
+	}
+	origDB := database.DB
+	database.DB = db
+	defer func() { database.DB = origDB }()
+
+	logger, err := utils.NewLogger(&utils.LogCfg{LogLevel: "ERROR", LogDir: t.TempDir(), LogFile: "test.log"})
+	if err != nil {
+		t.Fatalf("创建日志失败: %v", err)
+	}
+	memory := chat.NewPostgresMemory("u1")
+	h := &ConnectionHandler{
+		logger:          logger,
+		config:          &configs.Config{},
+		dialogueManager: chat.NewDialogueManager(logger, memory),
+	}
+	h.providers.llm = &importanceLLM{}
+
+	turns := [][2]string{
+		{"我的生日是五月一日", "好的，我记住了"},
+		{"今天天气怎么样", "晴天"},
+		{"讲个笑话", "从前有座山"},
+		{"现在几点", "下午三点"},
+		{"再见", "再见"},
+	}
+	for _, turn := range turns {
+		h.dialogueManager.Put(chat.Message{Role: "user", Content: turn[0]})
+		h.dialogueManager.Put(chat.Message{Role: "assistant", Content: turn[1]})
+		h.scoreLastExchange()
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		var scored int64
+		db.Model(&models.DialogueMessage{}).Where("importance_score > 0").Count(&scored)
+		if scored == int64(2*len(turns)) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("评分未完成，已评分 %d 条", scored)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	msgs, err := memory.QueryMessagesLimit(4)
+	if err != nil {
+		t.Fatalf("查询消息失败: %v", err)
+	}
+	var got []string
+	for _, m := range msgs {
+		got = append(got, m.Content)
+	}
+	want := []string{"我的生日是五月一日", "好的，我记住了", "再见", "再见"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("QueryMessagesLimit(4) = %v, 期望 最近2条 + 评分最高的2条 %v", got, want)
+	}
+
+	// 未评分时按最近消息补足
+	db.Model(&models.DialogueMessage{}).Where("1 = 1").Update("importance_score", 0)
+	msgs, err = memory.QueryMessagesLimit(3)
+	if err != nil {
+		t.Fatalf("查询消息失败: %v", err)
+	}
+	got = got[:0]
+	for _, m := range msgs {
+		got = append(got, m.Content)
+	}
+	if want := []string{"下午三点", "再见", "再见"}; strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("未评分时 QueryMessagesLimit(3) = %v, 期望 %v", got, want)
+	}
+}
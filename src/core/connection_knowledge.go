@@ -0,0 +1,65 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"angrymiao-ai-server/src/core/providers"
+	"angrymiao-ai-server/src/core/utils"
+)
+
+const (
+	// knowledgeSearchTimeout 知识库检索超时，超时后不注入上下文
+	knowledgeSearchTimeout = time.Second
+	// knowledgeSearchTopK 注入上下文的检索结果条数
+	knowledgeSearchTopK = 3
+)
+
+// injectKnowledgeContext 以最后一条用户消息的关键词检索知识库，将结果作为系统消息插入到开头的系统提示词之后
+// 最后一条消息不是用户消息（如工具调用结果）、检索失败或无结果时原样返回
+func (h *ConnectionHandler) injectKnowledgeContext(ctx context.Context, messages []providers.Message) []providers.Message {
+	if h.knowledgeBase == nil || len(messages) == 0 {
+		return messages
+	}
+	last := messages[len(messages)-1]
+	if last.Role != "user" || strings.TrimSpace(last.Content) == "" {
+		return messages
+	}
+
+	query := strings.Join(utils.ExtractKeywords(last.Content), " ")
+	if query == "" {
+		query = last.Content
+	}
+
+	searchCtx, cancel := context.WithTimeout(ctx, knowledgeSearchTimeout)
+	defer cancel()
+	startTime := time.Now()
+	results, err := h.knowledgeBase.Search(searchCtx, query, knowledgeSearchTopK)
+	latency := time.Since(startTime)
+	if err != nil {
+		h.LogError(fmt.Sprintf("知识库检索失败，耗时: %v, 不注入上下文: %v", latency, err))
+		return messages
+	}
+	h.LogInfo(fmt.Sprintf("知识库检索完成，耗时: %v, 关键词: %s, 结果数: %d", latency, query, len(results)))
+	if len(results) == 0 {
+		return messages
+	}
+	if len(results) > knowledgeSearchTopK {
+		results = results[:knowledgeSearchTopK]
+	}
+
+	contextMsg := providers.Message{
+		Role:    "system",
+		Content: "Relevant context:\n" + strings.Join(results, "\n"),
+	}
+	insertAt := 0
+	for insertAt < len(messages)-1 && messages[insertAt].Role == "system" {
+		insertAt++
+	}
+	injected := make([]providers.Message, 0, len(messages)+1)
+	injected = append(injected, messages[:insertAt]...)
+	injected = append(injected, contextMsg)
+	return append(injected, messages[insertAt:]...)
+}
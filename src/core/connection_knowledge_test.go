@@ -0,0 +1,96 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"angrymiao-ai-server/src/core/knowledge"
+	"angrymiao-ai-server/src/core/providers"
+	"angrymiao-ai-server/src/core/utils"
+)
+
+func TestInjectKnowledgeContext(t *testing.T) {
+	var gotQuery string
+	var gotTopK int
+	var gotAuth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Query string `json:"query"`
+			TopK  int    `json:"top_k"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		gotQuery, gotTopK, gotAuth = req.Query, req.TopK, r.Header.Get("Authorization")
+		w.Write([]byte(`{"results":[{"content":"火星大气主要是二氧化碳"},{"content":"火星表面气压很低"},{"content":"火星有两颗卫星"},{"content":"多余的结果"}]}`))
+	}))
+	defer srv.Close()
+
+	logger, err := utils.NewLogger(&utils.LogCfg{LogLevel: "ERROR", LogDir: t.TempDir(), LogFile: "test.log"})
+	if err != nil {
+		t.Fatalf("创建日志失败: %v", err)
+	}
+	h := &ConnectionHandler{logger: logger, knowledgeBase: knowledge.NewHTTPClient(srv.URL, "test-key")}
+
+	messages := []providers.Message{
+		{Role: "system", Content: "你是一个助手"},
+		{Role: "user", Content: "请问火星的大气成分是什么？"},
+	}
+	injected := h.injectKnowledgeContext(context.Background(), messages)
+
+	if gotQuery == "" || strings.Contains(gotQuery, "请问") || gotTopK != 3 || gotAuth != "Bearer test-key" {
+		t.Errorf("检索请求 query=%q top_k=%d auth=%q", gotQuery, gotTopK, gotAuth)
+	}
+	if len(injected) != 3 || injected[0].Content != "你是一个助手" || injected[2].Role != "user" {
+		t.Fatalf("注入后的消息 = %+v", injected)
+	}
+	want := "Relevant context:\n火星大气主要是二氧化碳\n火星表面气压很低\n火星有两颗卫星"
+	if injected[1].Role != "system" || injected[1].Content != want {
+		t.Errorf("注入的上下文 = %+v", injected[1])
+	}
+	if len(messages) != 2 {
+		t.Errorf("原消息列表被修改: %+v", messages)
+	}
+
+	// 最后一条不是用户消息时不检索
+	gotQuery = ""
+	toolMessages := append(injected, providers.Message{Role: "tool", Content: "ok"})
+	if got := h.injectKnowledgeContext(context.Background(), toolMessages); len(got) != len(toolMessages) || gotQuery != "" {
+		t.Errorf("工具结果后不应注入上下文")
+	}
+}
+
+func TestInjectKnowledgeContextFailure(t *testing.T) {
+	logger, err := utils.NewLogger(&utils.LogCfg{LogLevel: "ERROR", LogDir: t.TempDir(), LogFile: "test.log"})
+	if err != nil {
+		t.Fatalf("创建日志失败: %v", err)
+	}
+	messages := []providers.Message{{Role: "user", Content: "火星"}}
+
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(3 * time.Second):
+		case <-r.Context().Done():
+		}
+	}))
+	defer slow.Close()
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "boom", http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+
+	for name, url := range map[string]string{"超时": slow.URL, "错误": failing.URL} {
+		h := &ConnectionHandler{logger: logger, knowledgeBase: knowledge.NewHTTPClient(url, "")}
+		start := time.Now()
+		got := h.injectKnowledgeContext(context.Background(), messages)
+		if len(got) != 1 {
+			t.Errorf("%s: 检索失败时不应注入上下文: %+v", name, got)
+		}
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Errorf("%s: 检索耗时 %v, 应在1秒左右超时", name, elapsed)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultLanguageDetectionBufferMs 默认用于语种识别的开头音频时长
+	defaultLanguageDetectionBufferMs = 2000
+	// languageDetectionInterval 一次语种识别后不再识别的时长
+	languageDetectionInterval = 60 * time.Second
+	// languageDetectionTimeout 单次语种识别的超时时间
+	languageDetectionTimeout = 3 * time.Second
+)
+
+// addASRAudio 将音频送入ASR；需要识别语种时先缓存开头的音频，识别完成后一并送入
+func (h *ConnectionHandler) addASRAudio(data []byte) error {
+	h.audioLatency.RecordASRStart(time.Now().UnixNano())
+	if h.languageDetector == nil {
+		return h.providers.asr.AddAudio(data)
+	}
+
+	h.langDetectMu.Lock()
+	if time.Now().Before(h.nextLanguageDetectAt) {
+		h.langDetectMu.Unlock()
+		return h.providers.asr.AddAudio(data)
+	}
+	h.langDetectBuffer = append(h.langDetectBuffer, data...)
+	if len(h.langDetectBuffer) < h.languageDetectionBufferBytes() {
+		h.langDetectMu.Unlock()
+		return nil
+	}
+	buffered := h.detectLanguageLocked()
+	h.langDetectMu.Unlock()
+	return h.providers.asr.AddAudio(buffered)
+}
+
+// flushLanguageDetection 音频结束时不足识别时长的缓存也进行识别并送入ASR
+func (h *ConnectionHandler) flushLanguageDetection() {
+	if h.languageDetector == nil {
+		return
+	}
+	h.langDetectMu.Lock()
+	if len(h.langDetectBuffer) == 0 {
+		h.langDetectMu.Unlock()
+		return
+	}
+	buffered := h.detectLanguageLocked()
+	h.langDetectMu.Unlock()
+	if err := h.providers.asr.AddAudio(buffered); err != nil {
+		h.LogError(fmt.Sprintf("处理音频数据失败: %v", err))
+	}
+}
+
+// languageDetectionBufferBytes 识别语种需要缓存的PCM字节数
+func (h *ConnectionHandler) languageDetectionBufferBytes() int {
+	ms := h.config.LanguageDetectionBufferMs
+	if ms <= 0 {
+		ms = defaultLanguageDetectionBufferMs
+	}
+	return h.languageDetectionSampleRate() * 2 * ms / 1000
+}
+
+func (h *ConnectionHandler) languageDetectionSampleRate() int {
+	if h.clientAudioSampleRate > 0 {
+		return h.clientAudioSampleRate
+	}
+	return 16000
+}
+
+// detectLanguageLocked 识别缓存音频的语种并按结果切换语言，返回并清空缓存
+// 调用方需持有 langDetectMu
+func (h *ConnectionHandler) detectLanguageLocked() []byte {
+	buffered := h.langDetectBuffer
+	h.langDetectBuffer = nil
+	h.nextLanguageDetectAt = time.Now().Add(languageDetectionInterval)
+
+	ctx, cancel := context.WithTimeout(context.Background(), languageDetectionTimeout)
+	defer cancel()
+	tag, err := h.languageDetector.Detect(ctx, buffered, h.languageDetectionSampleRate())
+	if err != nil {
+		h.LogError(fmt.Sprintf("语种识别失败: %v", err))
+		return buffered
+	}
+	h.LogInfo(fmt.Sprintf("识别到语种: %s", tag))
+	h.sendLanguageDetectedMessage(tag)
+
+	lang := h.detectedLanguageKey(tag)
+	h.languageMu.Lock()
+	current := h.activeLanguage
+	h.languageMu.Unlock()
+	if lang != current {
+		h.setPreferredLanguage(lang)
+	}
+	return buffered
+}
+
+// detectedLanguageKey 将BCP-47语言标签转换为 language_voices 中的语言，
+// 未配置完整标签（如 en-us）时使用主语言（如 en）
+func (h *ConnectionHandler) detectedLanguageKey(tag string) string {
+	lang := strings.ToLower(tag)
+	if _, ok := h.config.LanguageVoices[lang]; ok {
+		return lang
+	}
+	primary, _, _ := strings.Cut(lang, "-")
+	return primary
+}
+
+// sendLanguageDetectedMessage 通知客户端识别到的语种
+func (h *ConnectionHandler) sendLanguageDetectedMessage(tag string) {
+	data, err := json.Marshal(map[string]interface{}{
+		"type":       "language_detected",
+		"language":   tag,
+		"session_id": h.sessionID,
+	})
+	if err != nil {
+		h.LogError(fmt.Sprintf("序列化语种识别消息失败: %v", err))
+		return
+	}
+	if err := h.conn.WriteMessage(1, data); err != nil {
+		h.LogError(fmt.Sprintf("发送语种识别消息失败: %v", err))
+	}
+}
@@ -0,0 +1,144 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"angrymiao-ai-server/src/configs"
+	"angrymiao-ai-server/src/core/providers"
+	"angrymiao-ai-server/src/core/utils"
+)
+
+// fixedDetector 返回固定语种并统计调用次数
+type fixedDetector struct {
+	tag   string
+	calls int
+	bytes int
+}
+
+func (d *fixedDetector) Detect(ctx context.Context, pcm []byte, sampleRate int) (string, error) {
+	d.calls++
+	d.bytes = len(pcm)
+	return d.tag, nil
+}
+
+// languageASR 记录送入的音频与切换的识别语言
+type languageASR struct {
+	providers.ASRProvider
+	mu        sync.Mutex
+	audio     int
+	languages []string
+}
+
+func (a *languageASR) AddAudio(data []byte) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.audio += len(data)
+	return nil
+}
+
+func (a *languageASR) SetLanguage(lang string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.languages = append(a.languages, lang)
+	return nil
+}
+
+func newLangDetectTestHandler(t *testing.T, tag string) (*ConnectionHandler, *fixedDetector, *languageASR, *voiceTTS, *recordingConn) {
+	t.Helper()
+	logger, err := utils.NewLogger(&utils.LogCfg{LogLevel: "ERROR", LogDir: t.TempDir(), LogFile: "test.log"})
+	if err != nil {
+		t.Fatalf("创建日志失败: %v", err)
+	}
+	detector := &fixedDetector{tag: tag}
+	asr := &languageASR{}
+	tts := &voiceTTS{}
+	conn := &recordingConn{}
+	h := &ConnectionHandler{
+		logger: logger,
+		config: &configs.Config{
+			LanguageDetectionBufferMs: 1000,
+			LanguageVoices:            map[string]string{"en": "en-voice", "zh": "zh-voice"},
+		},
+		conn:             conn,
+		sessionID:        "s1",
+		languageDetector: detector,
+	}
+	h.providers.asr = asr
+	h.providers.tts = tts
+	return h, detector, asr, tts, conn
+}
+
+func detectedLanguages(t *testing.T, conn *recordingConn) []string {
+	t.Helper()
+	var languages []string
+	for _, data := range conn.messages {
+		var msg map[string]interface{}
+		if err := json.Unmarshal(data, &msg); err != nil {
+			t.Fatalf("下发的消息不是JSON: %v", err)
+		}
+		if msg["type"] == "language_detected" {
+			languages = append(languages, msg["language"].(string))
+		}
+	}
+	return languages
+}
+
+func TestLanguageDetectionSwitchesLanguage(t *testing.T) {
+	h, detector, asr, tts, conn := newLangDetectTestHandler(t, "en-US")
+	frame := make([]byte, 16000) // 16kHz 16位PCM 500ms
+
+	h.addASRAudio(frame)
+	if asr.audio != 0 || detector.calls != 0 {
+		t.Fatalf("不足识别时长时应缓存音频: asr=%d calls=%d", asr.audio, detector.calls)
+	}
+	h.addASRAudio(frame)
+	if detector.calls != 1 || detector.bytes != 2*len(frame) {
+		t.Fatalf("应使用开头1秒音频识别语种: calls=%d bytes=%d", detector.calls, detector.bytes)
+	}
+	if asr.audio != 2*len(frame) {
+		t.Errorf("识别后应将缓存的音频送入ASR, 送入 %d 字节", asr.audio)
+	}
+	if len(asr.languages) != 1 || asr.languages[0] != "en" {
+		t.Errorf("ASR识别语言切换 = %v, 期望 [en]", asr.languages)
+	}
+	if tts.voice != "en-voice" {
+		t.Errorf("TTS音色 = %q, 期望 en-voice", tts.voice)
+	}
+	if got := detectedLanguages(t, conn); len(got) != 1 || got[0] != "en-US" {
+		t.Errorf("language_detected 消息 = %v", got)
+	}
+
+	// 60秒内不再识别，音频直接送入ASR
+	h.addASRAudio(frame)
+	h.addASRAudio(frame)
+	if detector.calls != 1 {
+		t.Errorf("60秒内不应再次识别语种, calls=%d", detector.calls)
+	}
+	if asr.audio != 4*len(frame) {
+		t.Errorf("送入ASR %d 字节, 期望 %d", asr.audio, 4*len(frame))
+	}
+}
+
+func TestLanguageDetectionSameLanguage(t *testing.T) {
+	h, detector, asr, tts, conn := newLangDetectTestHandler(t, "en-US")
+	h.setPreferredLanguage("en")
+	tts.voice = ""
+	asr.languages = nil
+
+	// 语音不足识别时长即结束，用已有音频识别
+	h.addASRAudio(make([]byte, 8000))
+	h.flushLanguageDetection()
+
+	if detector.calls != 1 || asr.audio != 8000 {
+		t.Fatalf("语音结束时应识别并送入缓存音频: calls=%d asr=%d", detector.calls, asr.audio)
+	}
+	if len(asr.languages) != 0 || tts.voice != "" {
+		t.Errorf("语种未变化时不应切换: languages=%v voice=%q", asr.languages, tts.voice)
+	}
+	if got := detectedLanguages(t, conn); len(got) != 1 || got[0] != "en-US" {
+		t.Errorf("language_detected 消息 = %v", got)
+	}
+}
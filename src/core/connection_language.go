@@ -0,0 +1,155 @@
+package core
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"angrymiao-ai-server/src/core/utils"
+)
+
+// reLanguageTag LLM回复中的语言标记，如 [LANG:en]、[LANG:zh]
+var reLanguageTag = regexp.MustCompile(`\[LANG:([A-Za-z]{2,3}(?:-[A-Za-z]{2,4})?)\]`)
+
+const languageTagPrefix = "[LANG:"
+
+// extractLanguageTag 移除文本中的语言标记，返回去除标记后的文本与最后一个标记的语言
+func extractLanguageTag(text string) (string, string) {
+	matches := reLanguageTag.FindAllStringSubmatch(text, -1)
+	if len(matches) == 0 {
+		return text, ""
+	}
+	lang := strings.ToLower(matches[len(matches)-1][1])
+	return reLanguageTag.ReplaceAllString(text, ""), lang
+}
+
+// inlineTags 分段时不能拆开的LLM回复标记：语言标记与语速音调标记
+var inlineTags = []struct {
+	re     *regexp.Regexp
+	prefix string
+}{
+	{reLanguageTag, languageTagPrefix},
+	{reSpeechMarker, speechMarkerPrefix},
+}
+
+// partialLanguageTagIndex 文本末尾尚未接收完整的语言或语速音调标记的起始位置，没有时返回-1
+func partialLanguageTagIndex(text string) int {
+	idx := strings.LastIndex(text, "[")
+	if idx < 0 || strings.Contains(text[idx:], "]") {
+		return -1
+	}
+	tail := text[idx:]
+	for _, tag := range inlineTags {
+		if strings.HasPrefix(tail, tag.prefix) || strings.HasPrefix(tag.prefix, tail) {
+			return idx
+		}
+	}
+	return -1
+}
+
+// splitLLMSegment 按标点分段，保证分割点不落在语言标记或语速音调标记内部
+// 分割点位于完整标记内时延后到标记末尾，位于未接收完整的标记之后时提前到标记开始
+func splitLLMSegment(text string) (string, int) {
+	segment, n := utils.SplitAtLastPunctuation(text)
+	if n == 0 {
+		return segment, n
+	}
+	for _, tag := range inlineTags {
+		for _, loc := range tag.re.FindAllStringIndex(text, -1) {
+			if loc[0] < n && n < loc[1] {
+				return text[:loc[1]], loc[1]
+			}
+		}
+	}
+	if idx := partialLanguageTagIndex(text); idx >= 0 && n > idx {
+		return text[:idx], idx
+	}
+	return segment, n
+}
+
+// applyLanguageTag 处理分段中的语言标记：切换语言并返回去除标记后的分段
+func (h *ConnectionHandler) applyLanguageTag(segment string) string {
+	segment, lang := extractLanguageTag(segment)
+	if lang != "" {
+		h.switchLanguage(lang)
+	}
+	return strings.TrimSpace(segment)
+}
+
+// currentVoice 获取TTS当前音色
+func (h *ConnectionHandler) currentVoice() string {
+	if getter, ok := h.providers.tts.(configGetter); ok {
+		return getter.Config().Voice
+	}
+	return ""
+}
+
+// switchLanguage 按LLM回复中的语言标记切换本轮的TTS音色与ASR识别语言
+func (h *ConnectionHandler) switchLanguage(lang string) {
+	h.languageMu.Lock()
+	defer h.languageMu.Unlock()
+	h.setLanguageLocked(lang)
+}
+
+// setPreferredLanguage 设置客户端hello中指定的默认语言，并立即生效
+func (h *ConnectionHandler) setPreferredLanguage(lang string) {
+	h.languageMu.Lock()
+	defer h.languageMu.Unlock()
+	h.preferredLanguage = strings.ToLower(lang)
+	h.setLanguageLocked(h.preferredLanguage)
+}
+
+// resetRoundLanguage 清除本轮的语言切换，恢复为默认语言
+func (h *ConnectionHandler) resetRoundLanguage() {
+	h.languageMu.Lock()
+	defer h.languageMu.Unlock()
+	h.setLanguageLocked(h.preferredLanguage)
+}
+
+// setLanguageLocked 切换TTS音色与ASR识别语言，lang 为空表示恢复切换前的音色与配置默认语言
+// 调用方需持有 languageMu
+func (h *ConnectionHandler) setLanguageLocked(lang string) {
+	if lang == h.activeLanguage {
+		return
+	}
+
+	var voice string
+	if lang == "" {
+		voice = h.languageDefaultVoice
+	} else {
+		v, ok := h.config.LanguageVoices[lang]
+		if !ok {
+			h.LogInfo(fmt.Sprintf("未配置语言 %s 对应的音色，忽略语言切换", lang))
+			return
+		}
+		voice = v
+		if h.activeLanguage == "" {
+			// 记录切换前的音色，恢复默认语言时使用
+			h.languageDefaultVoice = h.currentVoice()
+		}
+	}
+
+	if voice != "" && h.providers.tts != nil {
+		if err := h.providers.tts.SetVoice(voice); err != nil {
+			h.LogError(fmt.Sprintf("切换语言音色失败: %v", err))
+		}
+	}
+	if h.providers.asr != nil {
+		if err := h.providers.asr.SetLanguage(lang); err != nil {
+			h.LogError(fmt.Sprintf("切换ASR识别语言失败: %v", err))
+		}
+	}
+	h.LogInfo(fmt.Sprintf("切换对话语言: %q -> %q, 音色: %s", h.activeLanguage, lang, voice))
+	h.activeLanguage = lang
+}
+
+// restorePunctuation 为ASR最终结果恢复标点，按当前对话语言选择规则
+func (h *ConnectionHandler) restorePunctuation(text string) string {
+	if h.config == nil || !h.config.PunctuationRestoreEnabled {
+		return text
+	}
+	h.languageMu.Lock()
+	lang := h.activeLanguage
+	h.languageMu.Unlock()
+	return utils.RestorePunctuation(text, lang)
+}
@@ -0,0 +1,98 @@
+package core
+
+import (
+	"strings"
+	"testing"
+
+	"angrymiao-ai-server/src/configs"
+	"angrymiao-ai-server/src/core/providers"
+	"angrymiao-ai-server/src/core/providers/tts"
+	"angrymiao-ai-server/src/core/utils"
+)
+
+// languageMockTTS 记录音色切换
+type languageMockTTS struct {
+	providers.TTSProvider
+	config *tts.Config
+}
+
+func (m *languageMockTTS) Config() *tts.Config { return m.config }
+
+func (m *languageMockTTS) SetVoice(voice string) error {
+	m.config.Voice = voice
+	return nil
+}
+
+// languageMockASR 记录识别语言切换
+type languageMockASR struct {
+	providers.ASRProvider
+	languages []string
+}
+
+func (m *languageMockASR) SetLanguage(lang string) error {
+	m.languages = append(m.languages, lang)
+	return nil
+}
+
+func TestExtractLanguageTag(t *testing.T) {
+	text, lang := extractLanguageTag("[LANG:EN]Hello there.")
+	if text != "Hello there." || lang != "en" {
+		t.Errorf("extractLanguageTag = %q, %q", text, lang)
+	}
+	if text, lang := extractLanguageTag("你好。"); text != "你好。" || lang != "" {
+		t.Errorf("无标记时 = %q, %q", text, lang)
+	}
+}
+
+func TestSplitLLMSegmentKeepsLanguageTag(t *testing.T) {
+	prefix := strings.Repeat("a", 60)
+
+	// 冒号在长文本中会被当作分句标点，分割点不能落在完整标记内部
+	segment, n := splitLLMSegment(prefix + "[LANG:en]hello")
+	if segment != prefix+"[LANG:en]" || n != len(segment) {
+		t.Errorf("完整标记分段 = %q, %d", segment, n)
+	}
+
+	// 标记尚未接收完整时在标记前分段
+	segment, n = splitLLMSegment(prefix + "[LANG:")
+	if segment != prefix || n != len(prefix) {
+		t.Errorf("不完整标记分段 = %q, %d", segment, n)
+	}
+}
+
+func TestLanguageSwitchRestoredAfterRound(t *testing.T) {
+	logger, err := utils.NewLogger(&utils.LogCfg{LogLevel: "ERROR", LogDir: t.TempDir(), LogFile: "test.log"})
+	if err != nil {
+		t.Fatalf("创建日志失败: %v", err)
+	}
+
+	mockTTS := &languageMockTTS{config: &tts.Config{Voice: "zh-CN-XiaoxiaoNeural"}}
+	mockASR := &languageMockASR{}
+	h := &ConnectionHandler{
+		logger: logger,
+		config: &configs.Config{LanguageVoices: map[string]string{"en": "en-US-AriaNeural"}},
+	}
+	h.providers.tts = mockTTS
+	h.providers.asr = mockASR
+
+	if segment := h.applyLanguageTag("[LANG:en] Sure, let's switch."); segment != "Sure, let's switch." {
+		t.Errorf("去除标记后分段 = %q", segment)
+	}
+	if mockTTS.config.Voice != "en-US-AriaNeural" {
+		t.Errorf("切换后音色 = %s", mockTTS.config.Voice)
+	}
+
+	// 未配置音色的语言不切换
+	h.switchLanguage("fr")
+	if mockTTS.config.Voice != "en-US-AriaNeural" {
+		t.Errorf("未配置语言不应切换音色, 实际 %s", mockTTS.config.Voice)
+	}
+
+	h.resetRoundLanguage()
+	if mockTTS.config.Voice != "zh-CN-XiaoxiaoNeural" {
+		t.Errorf("本轮结束后音色 = %s, 期望恢复默认音色", mockTTS.config.Voice)
+	}
+	if got := strings.Join(mockASR.languages, ","); got != "en," {
+		t.Errorf("ASR语言切换记录 = %q, 期望 \"en,\"", got)
+	}
+}
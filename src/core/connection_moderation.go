@@ -0,0 +1,58 @@
+package core
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"angrymiao-ai-server/src/core/moderation"
+	"angrymiao-ai-server/src/core/providers"
+)
+
+const (
+	moderationModeLLM = "llm"
+
+	// moderationLLMTimeout LLM审核单个分段的超时时间，超时后放行
+	moderationLLMTimeout = 2 * time.Second
+
+	defaultModerationFallback = "抱歉，这个问题我暂时无法回答。"
+)
+
+// newModerator 按 moderation_mode 创建内容审核，未开启审核时返回nil
+func (h *ConnectionHandler) newModerator() moderation.Moderator {
+	if h.config == nil || !h.config.ModerationEnabled {
+		return nil
+	}
+	if h.config.ModerationMode == moderationModeLLM {
+		return moderation.NewLLMBasedModerator(func() providers.LLMProvider { return h.providers.llm }, h.sessionID)
+	}
+	return moderation.NewRuleBasedModerator(h.config.ModerationBlocklist)
+}
+
+// moderateSegment TTS前审核LLM回复分段，未通过时替换为兜底回复
+// 同一轮次只播报一次兜底回复，之后未通过的分段返回空字符串，由调用方跳过；审核出错时放行
+func (h *ConnectionHandler) moderateSegment(ctx context.Context, segment string, round int) string {
+	if h.moderator == nil || strings.TrimSpace(segment) == "" {
+		return segment
+	}
+	ctx, cancel := context.WithTimeout(ctx, moderationLLMTimeout)
+	defer cancel()
+	safe, category, confidence, err := h.moderator.CheckText(ctx, segment)
+	if err != nil {
+		h.logger.Warn("内容审核失败，放行分段: %v", err)
+		return segment
+	}
+	if safe {
+		return segment
+	}
+
+	h.logger.Warn("内容审核未通过: category=%s, confidence=%.2f, round=%d, text=%s", category, confidence, round, segment)
+	if atomic.SwapInt32(&h.moderationFallbackRound, int32(round)) == int32(round) {
+		return ""
+	}
+	if h.config.ModerationFallbackMessage != "" {
+		return h.config.ModerationFallbackMessage
+	}
+	return defaultModerationFallback
+}
@@ -0,0 +1,66 @@
+package core
+
+import (
+	"context"
+	"testing"
+
+	"angrymiao-ai-server/src/configs"
+	"angrymiao-ai-server/src/core/utils"
+)
+
+func TestModerateSegmentReplacesBlockedText(t *testing.T) {
+	logger, err := utils.NewLogger(&utils.LogCfg{LogLevel: "ERROR", LogDir: t.TempDir(), LogFile: "test.log"})
+	if err != nil {
+		t.Fatalf("创建日志失败: %v", err)
+	}
+	h := &ConnectionHandler{
+		logger: logger,
+		config: &configs.Config{
+			ModerationEnabled:         true,
+			ModerationMode:            "rule",
+			ModerationFallbackMessage: "换个话题吧。",
+			ModerationBlocklist:       map[string][]string{"violence": {"炸弹"}},
+		},
+	}
+	h.moderator = h.newModerator()
+	ctx := context.Background()
+
+	if got := h.moderateSegment(ctx, "今天天气很好。", 1); got != "今天天气很好。" {
+		t.Errorf("安全分段 = %q", got)
+	}
+	if got := h.moderateSegment(ctx, "炸弹的做法是。", 1); got != "换个话题吧。" {
+		t.Errorf("未通过的分段 = %q, 期望兜底回复", got)
+	}
+	// 同一轮次只播报一次兜底回复
+	if got := h.moderateSegment(ctx, "再说说炸弹。", 1); got != "" {
+		t.Errorf("同一轮再次未通过 = %q, 期望跳过", got)
+	}
+	if got := h.moderateSegment(ctx, "炸弹。", 2); got != "换个话题吧。" {
+		t.Errorf("新一轮未通过 = %q, 期望兜底回复", got)
+	}
+
+	// 未开启审核时不创建审核
+	h.config.ModerationEnabled = false
+	if h.newModerator() != nil {
+		t.Error("未开启审核时不应创建审核")
+	}
+}
+
+func TestModeratedReplyStoredInHistory(t *testing.T) {
+	h := newPrefixTestHandler(t, &configs.Config{
+		ModerationEnabled:         true,
+		ModerationMode:            "rule",
+		ModerationFallbackMessage: "换个话题吧。",
+		ModerationBlocklist:       map[string][]string{"violence": {"炸弹"}},
+	})
+	h.moderator = h.newModerator()
+	h.providers.llm = &streamingLLM{chunks: []string{"今天天气很好。", "炸弹的做法是。", "再说说炸弹"}}
+
+	if err := h.genResponseByLLM(context.Background(), nil, 1); err != nil {
+		t.Fatalf("genResponseByLLM: %v", err)
+	}
+
+	if got := assistantHistory(h); got != "今天天气很好。换个话题吧。" {
+		t.Errorf("对话历史 = %q, 期望审核后的回复", got)
+	}
+}
@@ -0,0 +1,55 @@
+package core
+
+import (
+	"fmt"
+
+	"angrymiao-ai-server/src/core/pool"
+)
+
+// upgradeProviderSet 本轮对话结束后检查资源池是否已热升级，已升级时从新资源池借出提供者，原集合归还到借出它的旧资源池
+// MCP与VAD与连接状态绑定，沿用原实例；转交对话期间当前LLM不来自资源池，等转交结束后的轮次再迁移
+func (h *ConnectionHandler) upgradeProviderSet() {
+	if h.poolSource == nil || h.providerSet == nil {
+		return
+	}
+	latest := h.poolSource()
+	if latest == nil || latest.Generation() <= h.providerSet.Generation() {
+		return
+	}
+	if h.conversationRouter != nil && h.conversationRouter.Depth() > 0 {
+		return
+	}
+
+	var set *pool.ProviderSet
+	var err error
+	if h.config.UsePrivateConfig && h.userID != "" {
+		set, err = latest.GetProviderSetForUser(h.userID)
+	} else {
+		set, err = latest.GetProviderSet()
+	}
+	if err != nil {
+		h.LogError(fmt.Sprintf("从新资源池获取提供者失败，继续使用原提供者: %v", err))
+		return
+	}
+
+	old := h.providerSet
+	h.releaseASR()
+	h.restoreProviderConfigs()
+	set.MCP, old.MCP = old.MCP, set.MCP
+	set.VAD, old.VAD = old.VAD, set.VAD
+	h.useProviderSet(set)
+	h.poolManager = latest
+	if h.providers.asr != nil {
+		h.providers.asr.SetListener(h)
+	}
+	// 用户Bot的模型与音色配置重新应用到新的提供者
+	h.userConfigsMu.RLock()
+	h.applyBotProviderConfig(h.userConfigs)
+	h.userConfigsMu.RUnlock()
+
+	if err := old.ReleaseBack(); err != nil {
+		h.LogError(fmt.Sprintf("归还旧资源池的提供者失败: %v", err))
+	}
+	migrated := latest.RecordMigration()
+	h.LogInfo(fmt.Sprintf("连接已从第%d代迁移到第%d代资源池，累计迁移 %d 个连接", old.Generation(), latest.Generation(), migrated))
+}
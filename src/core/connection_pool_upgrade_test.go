@@ -0,0 +1,156 @@
+package core
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"angrymiao-ai-server/src/configs"
+	"angrymiao-ai-server/src/core/pool"
+	"angrymiao-ai-server/src/core/providers"
+	"angrymiao-ai-server/src/core/providers/llm"
+	"angrymiao-ai-server/src/core/types"
+	"angrymiao-ai-server/src/core/utils"
+)
+
+// versionedLLM 以模型名作为版本号，按配置的延迟初始化
+type versionedLLM struct {
+	providers.LLMProvider
+	version string
+	delay   time.Duration
+}
+
+func (p *versionedLLM) Initialize() error {
+	time.Sleep(p.delay)
+	return nil
+}
+
+func (p *versionedLLM) Cleanup() error { return nil }
+
+func (p *versionedLLM) Response(ctx context.Context, sessionID string, messages []types.Message) (<-chan string, error) {
+	ch := make(chan string, 1)
+	ch <- p.version
+	close(ch)
+	return ch, nil
+}
+
+func init() {
+	llm.Register("upgrade_test", func(config *llm.Config) (llm.Provider, error) {
+		delay := time.Duration(0)
+		if config.ModelName == "v2" {
+			delay = 50 * time.Millisecond
+		}
+		return &versionedLLM{version: config.ModelName, delay: delay}, nil
+	})
+}
+
+func newUpgradeTestConfig(version string) *configs.Config {
+	return &configs.Config{
+		SelectedModule: map[string]string{"LLM": "TestLLM"},
+		PoolConfig:     configs.PoolConfig{PoolMinSize: 1, PoolMaxSize: 3},
+		McpPoolConfig:  configs.McpPoolConfig{PoolMaxSize: 3},
+		LLM:            map[string]configs.LLMConfig{"TestLLM": {Type: "upgrade_test", ModelName: version}},
+	}
+}
+
+func replyVersion(t *testing.T, h *ConnectionHandler) string {
+	t.Helper()
+	ch, err := h.providers.llm.Response(context.Background(), h.sessionID, nil)
+	if err != nil {
+		t.Fatalf("LLM回复失败: %v", err)
+	}
+	return <-ch
+}
+
+func TestProvidersMigrateAfterTalkRoundOnPoolUpgrade(t *testing.T) {
+	logger, err := utils.NewLogger(&utils.LogCfg{LogLevel: "ERROR", LogDir: t.TempDir(), LogFile: "test.log"})
+	if err != nil {
+		t.Fatalf("创建日志失败: %v", err)
+	}
+	oldPool, err := pool.NewPoolManager(newUpgradeTestConfig("v1"), logger)
+	if err != nil {
+		t.Fatalf("创建资源池失败: %v", err)
+	}
+	t.Cleanup(oldPool.Close)
+
+	var current atomic.Pointer[pool.PoolManager]
+	current.Store(oldPool)
+	handlers := make([]*ConnectionHandler, 3)
+	for i := range handlers {
+		set, err := oldPool.GetProviderSet()
+		if err != nil {
+			t.Fatalf("获取提供者失败: %v", err)
+		}
+		h := &ConnectionHandler{logger: logger, config: newUpgradeTestConfig("v1")}
+		h.useProviderSet(set)
+		h.SetPoolManager(oldPool)
+		h.SetPoolSource(current.Load)
+		handlers[i] = h
+	}
+
+	// 新资源池初始化期间，活跃会话仍使用旧提供者完成对话
+	reloaded := make(chan *pool.PoolManager)
+	go func() {
+		pm, err := pool.NewPoolManager(newUpgradeTestConfig("v2"), logger)
+		if err != nil {
+			t.Errorf("创建新资源池失败: %v", err)
+		}
+		current.Store(pm)
+		reloaded <- pm
+	}()
+	for _, h := range handlers {
+		if got := replyVersion(t, h); got != "v1" {
+			t.Errorf("升级期间的回复 = %s, 期望 v1", got)
+		}
+		h.upgradeProviderSet()
+	}
+	newPool := <-reloaded
+	if newPool == nil {
+		t.FailNow()
+	}
+	t.Cleanup(newPool.Close)
+	if newPool.Generation() <= oldPool.Generation() {
+		t.Fatalf("新资源池代数 %d 应大于旧资源池 %d", newPool.Generation(), oldPool.Generation())
+	}
+
+	// 本轮结束后各会话换用新资源池，旧提供者归还到旧资源池
+	var wg sync.WaitGroup
+	for _, h := range handlers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			h.upgradeProviderSet()
+		}()
+	}
+	wg.Wait()
+	for i, h := range handlers {
+		if got := replyVersion(t, h); got != "v2" {
+			t.Errorf("会话%d迁移后的回复 = %s, 期望 v2", i, got)
+		}
+		if h.ProviderSet().Generation() != newPool.Generation() {
+			t.Errorf("会话%d的提供者代数 = %d", i, h.ProviderSet().Generation())
+		}
+	}
+	if got := newPool.Migrated(); got != 3 {
+		t.Errorf("迁移连接数 = %d, 期望 3", got)
+	}
+	if stats := oldPool.ProviderPoolStats()["llm"]; stats.InUse != 0 {
+		t.Errorf("旧资源池仍借出 %d 个LLM", stats.InUse)
+	}
+	if stats := newPool.ProviderPoolStats()["llm"]; stats.InUse != 3 {
+		t.Errorf("新资源池借出 %d 个LLM, 期望 3", stats.InUse)
+	}
+
+	// 已迁移的会话不会重复迁移，断开时归还到新资源池
+	for _, h := range handlers {
+		h.upgradeProviderSet()
+		if err := h.ProviderSet().ReleaseBack(); err != nil {
+			t.Errorf("归还提供者失败: %v", err)
+		}
+	}
+	if newPool.Migrated() != 3 || newPool.ProviderPoolStats()["llm"].InUse != 0 {
+		t.Errorf("归还后新资源池 = %+v, 迁移数 %d", newPool.ProviderPoolStats()["llm"], newPool.Migrated())
+	}
+}
@@ -0,0 +1,94 @@
+package core
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"angrymiao-ai-server/src/core/types"
+	"angrymiao-ai-server/src/core/utils"
+)
+
+// singleActiveBot 返回唯一启用的Bot好友配置，没有或有多个启用时返回nil
+func singleActiveBot(configs []*types.BotConfig) *types.BotConfig {
+	var active *types.BotConfig
+	for _, config := range configs {
+		if !config.IsActive {
+			continue
+		}
+		if active != nil {
+			return nil
+		}
+		active = config
+	}
+	return active
+}
+
+// responsePrefix 返回回复前缀，唯一启用的Bot好友配置了前缀时优先使用
+func (h *ConnectionHandler) responsePrefix() string {
+	h.userConfigsMu.RLock()
+	active := singleActiveBot(h.userConfigs)
+	h.userConfigsMu.RUnlock()
+	if active != nil && active.ResponsePrefix != "" {
+		return active.ResponsePrefix
+	}
+	return h.config.ResponsePrefix
+}
+
+// applyResponsePrefix 在本轮第一个分段前加上回复前缀，每轮只加一次
+// 前缀只进入TTS，不写入对话历史；prefix_tts_only 时前缀单独合成为第 textIndex 个分段，
+// 返回的分段索引顺延一位
+func (h *ConnectionHandler) applyResponsePrefix(segment string, textIndex int, round int) (string, int) {
+	if textIndex != 1 {
+		return segment, textIndex
+	}
+	prefix := h.responsePrefix()
+	if prefix == "" || int(atomic.SwapInt32(&h.prefixedRound, int32(round))) == round {
+		return segment, textIndex
+	}
+	if !h.config.PrefixTTSOnly {
+		return prefix + segment, textIndex
+	}
+
+	// 先登记后续分段，避免前缀播放完成时被当作本轮最后一段
+	atomic.StoreInt32(&h.tts_last_text_index, int32(textIndex+1))
+	h.LogInfo(fmt.Sprintf("播报回复前缀: %s, round: %d", prefix, round))
+	if h.config.PrefixVoice == "" {
+		if err := h.speakSegment(prefix, textIndex, round, false); err != nil {
+			h.LogError(fmt.Sprintf("播报回复前缀失败: %v", err))
+		}
+		return segment, textIndex + 1
+	}
+	h.speakPrefixWithVoice(prefix, h.config.PrefixVoice, textIndex, round)
+	return segment, textIndex + 1
+}
+
+// speakPrefixWithVoice 切换到指定音色同步合成前缀或欢迎语，合成后恢复会话音色并加入发送队列
+// 前缀位于本轮开头、欢迎语在对话开始前播报，此时没有其他分段在合成
+func (h *ConnectionHandler) speakPrefixWithVoice(prefix string, voice string, textIndex int, round int) {
+	original := ""
+	if getter, ok := h.providers.tts.(ttsConfigGetter); ok && getter.Config() != nil {
+		original = getter.Config().Voice
+	}
+	filepath := ""
+	if original == "" {
+		h.logger.Warn("无法获取会话当前音色，回复前缀使用当前音色合成")
+		filepath = h.synthesizeTTS(prefix, textIndex, round)
+	} else if err := h.providers.tts.SetVoice(voice); err != nil {
+		h.LogError(fmt.Sprintf("切换回复前缀音色失败: %v", err))
+		filepath = h.synthesizeTTS(prefix, textIndex, round)
+	} else {
+		filepath = h.synthesizeTTS(prefix, textIndex, round)
+		if err := h.providers.tts.SetVoice(original); err != nil {
+			h.LogError(fmt.Sprintf("恢复会话音色失败: %v", err))
+		}
+	}
+	utils.MarkAudioFileActive(filepath)
+	h.audioMessagesQueue <- struct {
+		filepath     string
+		text         string
+		round        int
+		textIndex    int
+		paragraphEnd bool
+		stream       <-chan []byte
+	}{filepath, prefix, round, textIndex, false, nil}
+}
@@ -0,0 +1,131 @@
+package core
+
+import (
+	"context"
+	"testing"
+
+	"angrymiao-ai-server/src/configs"
+	"angrymiao-ai-server/src/core/chat"
+	"angrymiao-ai-server/src/core/function"
+	"angrymiao-ai-server/src/core/providers"
+	"angrymiao-ai-server/src/core/types"
+	"angrymiao-ai-server/src/core/utils"
+
+	"github.com/angrymiao/go-openai"
+)
+
+// streamingLLM 按顺序流式返回固定的回复片段
+type streamingLLM struct {
+	providers.LLMProvider
+	chunks []string
+}
+
+func (m *streamingLLM) ResponseWithFunctions(ctx context.Context, sessionID string, messages []providers.Message, tools []openai.Tool) (<-chan types.Response, error) {
+	ch := make(chan types.Response, len(m.chunks))
+	for _, chunk := range m.chunks {
+		ch <- types.Response{Content: chunk}
+	}
+	close(ch)
+	return ch, nil
+}
+
+func (m *streamingLLM) Capabilities() map[string]bool {
+	return map[string]bool{types.CapabilityStreaming: true, types.CapabilityTools: true}
+}
+
+func newPrefixTestHandler(t *testing.T, config *configs.Config) *ConnectionHandler {
+	t.Helper()
+	logger, err := utils.NewLogger(&utils.LogCfg{LogLevel: "ERROR", LogDir: t.TempDir(), LogFile: "test.log"})
+	if err != nil {
+		t.Fatalf("创建日志失败: %v", err)
+	}
+	h := &ConnectionHandler{
+		logger:           logger,
+		conn:             &recordingConn{},
+		config:           config,
+		dialogueManager:  chat.NewDialogueManager(logger, nil),
+		functionRegister: function.NewFunctionRegistry(),
+		ttsQueue: make(chan struct {
+			text         string
+			round        int
+			textIndex    int
+			paragraphEnd bool
+		}, 10),
+	}
+	h.providers.llm = &streamingLLM{chunks: []string{"今天天气", "很好。", "适合出门"}}
+	return h
+}
+
+// queuedSegments 取出已加入TTS队列的分段文本
+func queuedSegments(h *ConnectionHandler) []string {
+	var texts []string
+	for len(h.ttsQueue) > 0 {
+		texts = append(texts, (<-h.ttsQueue).text)
+	}
+	return texts
+}
+
+func assistantHistory(h *ConnectionHandler) string {
+	dialogue := h.dialogueManager.GetLLMDialogue()
+	return dialogue[len(dialogue)-1].Content
+}
+
+func TestResponsePrefixSpokenButNotStored(t *testing.T) {
+	h := newPrefixTestHandler(t, &configs.Config{ResponsePrefix: "小爱："})
+
+	if err := h.genResponseByLLM(context.Background(), nil, 1); err != nil {
+		t.Fatalf("genResponseByLLM: %v", err)
+	}
+
+	got := queuedSegments(h)
+	if len(got) != 2 || got[0] != "小爱：今天天气很好。" || got[1] != "适合出门" {
+		t.Errorf("TTS分段 = %q, 期望仅第一段带前缀", got)
+	}
+	if content := assistantHistory(h); content != "今天天气很好。适合出门" {
+		t.Errorf("对话历史 = %q, 不应包含前缀", content)
+	}
+}
+
+func TestResponsePrefixOncePerRound(t *testing.T) {
+	h := newPrefixTestHandler(t, &configs.Config{ResponsePrefix: "小爱："})
+
+	h.genResponseByLLM(context.Background(), nil, 1)
+	h.genResponseByLLM(context.Background(), nil, 1) // 工具调用后同一轮继续回复
+	h.genResponseByLLM(context.Background(), nil, 2)
+
+	got := queuedSegments(h)
+	if len(got) != 6 || got[0] != "小爱：今天天气很好。" || got[2] != "今天天气很好。" || got[4] != "小爱：今天天气很好。" {
+		t.Errorf("TTS分段 = %q, 前缀应每轮只加一次", got)
+	}
+}
+
+func TestResponsePrefixTTSOnlySeparateSegment(t *testing.T) {
+	h := newPrefixTestHandler(t, &configs.Config{ResponsePrefix: "小爱：", PrefixTTSOnly: true})
+
+	h.genResponseByLLM(context.Background(), nil, 1)
+
+	var indexes []int
+	var texts []string
+	for len(h.ttsQueue) > 0 {
+		task := <-h.ttsQueue
+		indexes = append(indexes, task.textIndex)
+		texts = append(texts, task.text)
+	}
+	if len(texts) != 3 || texts[0] != "小爱：" || texts[1] != "今天天气很好。" || indexes[1] != 2 || indexes[2] != 3 {
+		t.Errorf("TTS分段 = %q, 索引 = %v, 期望前缀单独作为第1段", texts, indexes)
+	}
+	if content := assistantHistory(h); content != "今天天气很好。适合出门" {
+		t.Errorf("对话历史 = %q, 不应包含前缀", content)
+	}
+}
+
+func TestResponsePrefixBotOverride(t *testing.T) {
+	h := newPrefixTestHandler(t, &configs.Config{ResponsePrefix: "小爱："})
+	h.userConfigs = []*types.BotConfig{{FunctionName: "bot_weather", ResponsePrefix: "天气君：", IsActive: true}}
+
+	h.genResponseByLLM(context.Background(), nil, 1)
+
+	if got := queuedSegments(h); len(got) == 0 || got[0] != "天气君：今天天气很好。" {
+		t.Errorf("TTS分段 = %q, 期望使用Bot配置的前缀", got)
+	}
+}
@@ -0,0 +1,33 @@
+package core
+
+import (
+	"fmt"
+)
+
+// switchToUserProviders 开启 use_private_config 且用户选择了与全局不同的提供者时，换用用户私有资源池中的提供者
+// MCP与VAD保持不变，原提供者集合中的其余提供者归还到资源池
+func (h *ConnectionHandler) switchToUserProviders() {
+	if !h.config.UsePrivateConfig || h.userID == "" || h.poolManager == nil {
+		return
+	}
+	if !h.poolManager.UsesPrivateProviders(h.userID) {
+		return
+	}
+	set, err := h.poolManager.GetProviderSetForUser(h.userID)
+	if err != nil {
+		h.LogError(fmt.Sprintf("获取用户私有提供者失败，继续使用全局提供者: %v", err))
+		return
+	}
+
+	old := h.providerSet
+	if old != nil {
+		h.restoreProviderConfigs()
+		set.MCP, old.MCP = old.MCP, set.MCP
+		set.VAD, old.VAD = old.VAD, set.VAD
+		if err := old.ReleaseBack(); err != nil {
+			h.LogError(fmt.Sprintf("归还全局提供者失败: %v", err))
+		}
+	}
+	h.useProviderSet(set)
+	h.LogInfo(fmt.Sprintf("用户%s使用私有提供者配置", h.userID))
+}
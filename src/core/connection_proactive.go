@@ -0,0 +1,88 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"angrymiao-ai-server/src/core/chat"
+)
+
+// ErrProactiveRateLimited 会话在限制时间内已有主动消息
+var ErrProactiveRateLimited = errors.New("proactive message rate limited")
+
+// proactiveInterval 每个会话两次主动消息的最小间隔
+const proactiveInterval = 60 * time.Second
+
+// proactiveMessage 服务端主动下发的语音消息
+type proactiveMessage struct {
+	text  string
+	voice string
+}
+
+// roundBusy 正在处理对话或仍有语音待播放时为true
+func (h *ConnectionHandler) roundBusy() bool {
+	return atomic.LoadInt32(&h.activeChats) > 0 || atomic.LoadInt32(&h.tts_last_text_index) != -1
+}
+
+// ProactiveSpeak 服务端主动向会话播报一段文本，voice 非空时先切换音色
+// 会话空闲时立即播报；正在对话时返回 queued=true，待本轮结束后播报
+// 每个会话 60 秒内只接受一条主动消息，超出返回 ErrProactiveRateLimited
+func (h *ConnectionHandler) ProactiveSpeak(text string, voice string) (bool, error) {
+	if text == "" {
+		return false, errors.New("主动消息文本为空")
+	}
+
+	h.proactiveMu.Lock()
+	defer h.proactiveMu.Unlock()
+	if h.pendingProactiveMessage != nil ||
+		(!h.lastProactiveAt.IsZero() && time.Since(h.lastProactiveAt) < proactiveInterval) {
+		return false, ErrProactiveRateLimited
+	}
+
+	msg := &proactiveMessage{text: text, voice: voice}
+	if h.roundBusy() {
+		h.pendingProactiveMessage = msg
+		h.lastProactiveAt = time.Now()
+		h.LogInfo(fmt.Sprintf("会话正在对话，主动消息排队等待本轮结束: %s", text))
+		return true, nil
+	}
+	if err := h.speakProactive(msg); err != nil {
+		return false, err
+	}
+	h.lastProactiveAt = time.Now()
+	return false, nil
+}
+
+// deliverPendingProactive 本轮结束后播报排队的主动消息
+func (h *ConnectionHandler) deliverPendingProactive() {
+	h.proactiveMu.Lock()
+	defer h.proactiveMu.Unlock()
+	if h.pendingProactiveMessage == nil || h.roundBusy() {
+		return
+	}
+	msg := h.pendingProactiveMessage
+	h.pendingProactiveMessage = nil
+	if err := h.speakProactive(msg); err != nil {
+		h.LogError(fmt.Sprintf("播报排队的主动消息失败: %v", err))
+	}
+}
+
+// speakProactive 通知客户端开始播放并合成主动消息，消息记入对话历史
+func (h *ConnectionHandler) speakProactive(msg *proactiveMessage) error {
+	if msg.voice != "" {
+		if err := h.providers.tts.SetVoice(msg.voice); err != nil {
+			return fmt.Errorf("切换音色失败: %w", err)
+		}
+	}
+	h.LogInfo(fmt.Sprintf("服务端主动播报: %s", msg.text))
+	if err := h.sendTTSMessage("start", "", 0); err != nil {
+		return fmt.Errorf("发送TTS开始状态失败: %w", err)
+	}
+	if h.dialogueManager != nil {
+		h.dialogueManager.Put(chat.Message{Role: "assistant", Content: msg.text})
+	}
+	atomic.StoreInt32(&h.serverVoiceStop, 0)
+	return h.SystemSpeak(msg.text)
+}
@@ -0,0 +1,126 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"angrymiao-ai-server/src/configs"
+	"angrymiao-ai-server/src/core/chat"
+	"angrymiao-ai-server/src/core/providers"
+	"angrymiao-ai-server/src/core/utils"
+)
+
+// listeningASR 忽略监听计时重置
+type listeningASR struct {
+	resetCountingASR
+}
+
+func (a *listeningASR) ResetStartListenTime() {}
+
+// voiceTTS 记录切换的音色
+type voiceTTS struct {
+	providers.TTSProvider
+	voice string
+}
+
+func (p *voiceTTS) SetVoice(voice string) error {
+	p.voice = voice
+	return nil
+}
+
+func TestProactiveSpeakQueuedUntilRoundEnds(t *testing.T) {
+	logger, err := utils.NewLogger(&utils.LogCfg{LogLevel: "ERROR", LogDir: t.TempDir(), LogFile: "test.log"})
+	if err != nil {
+		t.Fatalf("创建日志失败: %v", err)
+	}
+	conn := &recordingConn{}
+	tts := &voiceTTS{}
+	h := &ConnectionHandler{
+		logger:          logger,
+		config:          &configs.Config{},
+		conn:            conn,
+		sessionID:       "s1",
+		dialogueManager: chat.NewDialogueManager(logger, nil),
+		ttsQueue: make(chan struct {
+			text         string
+			round        int
+			textIndex    int
+			paragraphEnd bool
+		}, 10),
+	}
+	h.providers.asr = &listeningASR{}
+	h.providers.tts = tts
+
+	// 本轮回复的最后一段（索引2）仍在播放
+	h.tts_last_text_index = 2
+	queued, err := h.ProactiveSpeak("早上好！", "xiaoxiao")
+	if err != nil || !queued {
+		t.Fatalf("会话忙时应排队: queued=%v err=%v", queued, err)
+	}
+	if len(h.ttsQueue) != 0 || tts.voice != "" {
+		t.Fatalf("本轮结束前不应播报主动消息")
+	}
+	if _, err := h.ProactiveSpeak("再说一次", ""); !errors.Is(err, ErrProactiveRateLimited) {
+		t.Errorf("60秒内第二条主动消息应被限制, got %v", err)
+	}
+
+	// 最后一段音频发送完成，本轮结束
+	h.sendAudioMessage("", "最后一句", 2, h.GetTalkRound())
+
+	if tts.voice != "xiaoxiao" {
+		t.Errorf("播报前应切换音色, voice = %q", tts.voice)
+	}
+	select {
+	case task := <-h.ttsQueue:
+		if task.text != "早上好" {
+			t.Errorf("合成文本 = %q", task.text)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("本轮结束后未播报排队的主动消息")
+	}
+	var states []string
+	for _, data := range conn.messages {
+		var msg map[string]interface{}
+		json.Unmarshal(data, &msg)
+		states = append(states, msg["state"].(string))
+	}
+	if len(states) != 2 || states[0] != "stop" || states[1] != "start" {
+		t.Errorf("下发的TTS状态 = %v, 期望本轮 stop 后主动消息 start", states)
+	}
+	if h.pendingProactiveMessage != nil {
+		t.Error("播报后应清除排队的主动消息")
+	}
+}
+
+func TestProactiveSpeakIdle(t *testing.T) {
+	logger, err := utils.NewLogger(&utils.LogCfg{LogLevel: "ERROR", LogDir: t.TempDir(), LogFile: "test.log"})
+	if err != nil {
+		t.Fatalf("创建日志失败: %v", err)
+	}
+	h := &ConnectionHandler{
+		logger:              logger,
+		config:              &configs.Config{},
+		conn:                &recordingConn{},
+		tts_last_text_index: -1,
+		ttsQueue: make(chan struct {
+			text         string
+			round        int
+			textIndex    int
+			paragraphEnd bool
+		}, 10),
+	}
+	h.providers.tts = &voiceTTS{}
+
+	queued, err := h.ProactiveSpeak("早上好！", "")
+	if err != nil || queued {
+		t.Fatalf("空闲会话应立即播报: queued=%v err=%v", queued, err)
+	}
+	if len(h.ttsQueue) != 1 {
+		t.Errorf("TTS队列长度 = %d, 期望 1", len(h.ttsQueue))
+	}
+	if _, err := h.ProactiveSpeak("早上好！", ""); !errors.Is(err, ErrProactiveRateLimited) {
+		t.Errorf("60秒内第二条主动消息应被限制, got %v", err)
+	}
+}
@@ -0,0 +1,51 @@
+package core
+
+import (
+	"regexp"
+
+	"angrymiao-ai-server/src/core/utils"
+)
+
+// compileExitPatterns 编译退出命令正则，无效的正则记录警告后跳过
+func compileExitPatterns(patterns []string, logger *utils.Logger) []*regexp.Regexp {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			logger.Warn("退出命令正则无效，已忽略: %s, %v", pattern, err)
+			continue
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled
+}
+
+// matchQuitIntent 判断移除标点后的文本是否为退出意图
+// CMD_exit 完全匹配或相似度达到 quit_intent_confidence_threshold，或匹配任一 CMD_exit_patterns 正则
+func (h *ConnectionHandler) matchQuitIntent(text string) bool {
+	cleaned := utils.RemoveAllPunctuation(text) // 移除标点符号，确保匹配准确
+	if cleaned == "" {
+		return false
+	}
+
+	threshold := h.config.QuitIntentConfidenceThreshold
+	for _, cmd := range h.config.CMDExit {
+		if cleaned == cmd {
+			return true
+		}
+		if threshold > 0 && cmd != "" {
+			if score := utils.EditSimilarity(cleaned, cmd); score >= threshold {
+				h.logger.Debug("退出命令模糊匹配: %s ~ %s, 相似度: %.2f", cleaned, cmd, score)
+				return true
+			}
+		}
+	}
+
+	for _, re := range h.exitPatterns {
+		if re.MatchString(cleaned) {
+			h.logger.Debug("退出命令正则匹配: %s ~ %s", cleaned, re.String())
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,53 @@
+package core
+
+import (
+	"testing"
+
+	"angrymiao-ai-server/src/configs"
+	"angrymiao-ai-server/src/core/utils"
+)
+
+func TestMatchQuitIntent(t *testing.T) {
+	logger, err := utils.NewLogger(&utils.LogCfg{LogLevel: "ERROR", LogDir: t.TempDir(), LogFile: "test.log"})
+	if err != nil {
+		t.Fatalf("创建日志失败: %v", err)
+	}
+	config := &configs.Config{
+		CMDExit:                       []string{"退出", "关闭连接"},
+		CMDExitPatterns:               []string{"^再见.*", "^拜拜", "([无效"},
+		QuitIntentConfidenceThreshold: 0.7,
+	}
+	h := &ConnectionHandler{config: config, logger: logger}
+	h.exitPatterns = compileExitPatterns(config.CMDExitPatterns, logger)
+	if len(h.exitPatterns) != 2 {
+		t.Fatalf("编译的正则数量 = %d, 无效正则应被忽略", len(h.exitPatterns))
+	}
+
+	tests := []struct {
+		name string
+		text string
+		want bool
+	}{
+		{"完全匹配", "退出。", true},
+		{"正则匹配", "再见啦，明天聊！", true},
+		{"正则前缀", "拜拜", true},
+		{"正则不匹配中间位置", "好的拜拜", false},
+		{"模糊匹配", "关闭链接", true},
+		{"部分匹配低于阈值", "我不想退出游戏", false},
+		{"无关文本", "今天天气怎么样", false},
+		{"只有标点", "。。", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := h.matchQuitIntent(tt.text); got != tt.want {
+				t.Errorf("matchQuitIntent(%q) = %v, 期望 %v", tt.text, got, tt.want)
+			}
+		})
+	}
+
+	// 阈值为0时仅完全匹配
+	config.QuitIntentConfidenceThreshold = 0
+	if h.matchQuitIntent("关闭链接") {
+		t.Error("未配置阈值时不应模糊匹配")
+	}
+}
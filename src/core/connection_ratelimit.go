@@ -0,0 +1,55 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"angrymiao-ai-server/src/core/ratelimit"
+)
+
+// rateLimitKey 限流计数对象，已绑定用户时按用户计数，否则按设备计数
+func (h *ConnectionHandler) rateLimitKey() string {
+	if h.userID != "" {
+		return h.userID
+	}
+	return "device:" + h.deviceID
+}
+
+// allowChatRequest 判断本次聊天请求是否超出限流，超出时通知客户端需等待的秒数
+// 未开启限流或限流器异常时放行
+func (h *ConnectionHandler) allowChatRequest(ctx context.Context) bool {
+	limiter := ratelimit.Get()
+	if limiter == nil {
+		return true
+	}
+	result, err := limiter.Allow(ctx, h.rateLimitKey())
+	if err != nil {
+		h.LogError(fmt.Sprintf("限流判断失败，放行本次请求: %v", err))
+		return true
+	}
+	if result.Allowed {
+		return true
+	}
+
+	h.LogInfo(fmt.Sprintf("请求过于频繁，本窗口内第%d次请求被拒绝", result.Count))
+	if err := h.sendRateLimitMessage(result.RetryAfterSeconds()); err != nil {
+		h.LogError(fmt.Sprintf("发送限流消息失败: %v", err))
+	}
+	return false
+}
+
+// sendRateLimitMessage 发送限流消息，retry_after 与HTTP的 Retry-After 响应头含义一致
+func (h *ConnectionHandler) sendRateLimitMessage(retryAfter int) error {
+	jsonData, err := json.Marshal(map[string]interface{}{
+		"type":        "error",
+		"code":        "rate_limited",
+		"message":     "请求过于频繁，请稍后再试",
+		"retry_after": retryAfter,
+		"session_id":  h.sessionID,
+	})
+	if err != nil {
+		return fmt.Errorf("序列化限流消息失败: %v", err)
+	}
+	return h.conn.WriteMessage(1, jsonData)
+}
@@ -1,9 +1,14 @@
 package core
 
 import (
+	"angrymiao-ai-server/src/configs/cache"
+	"angrymiao-ai-server/src/core/types"
 	"angrymiao-ai-server/src/core/utils"
+	"angrymiao-ai-server/src/httpsvr/device"
+	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"sync/atomic"
 	"time"
 )
@@ -59,13 +64,87 @@ func (h *ConnectionHandler) sendHelloMessage() error {
 			hello["transport"] = "mqtt"
 		}
 	}
+	// 客户端据此决定是否使用工具调用等能力
+	hello["server_capabilities"] = h.serverCapabilities()
+
+	// 已协商端到端加密时下发服务端公钥，hello响应本身以明文发送
+	e2eProvider, _ := h.conn.(E2EInfoProvider)
+	e2eEnabled := false
+	if e2eProvider != nil {
+		var curve, cipher, publicKey string
+		if e2eEnabled, curve, cipher, publicKey = e2eProvider.GetE2EInfo(); e2eEnabled {
+			hello["e2e"] = map[string]interface{}{
+				"key_exchange": "ecdh",
+				"curve":        curve,
+				"cipher":       cipher,
+				"public_key":   publicKey,
+			}
+		}
+	}
 
 	data, err := json.Marshal(hello)
 	if err != nil {
 		return fmt.Errorf("序列化欢迎消息失败: %v", err)
 	}
 
-	return h.conn.WriteMessage(1, data)
+	if err := h.conn.WriteMessage(1, data); err != nil {
+		return err
+	}
+	if e2eEnabled {
+		e2eProvider.ActivateE2E()
+		h.LogInfo("已启用端到端加密")
+	}
+	return nil
+}
+
+// serverCapabilities 汇总当前LLM、TTS、ASR提供者的能力，任一提供者支持即为true
+func (h *ConnectionHandler) serverCapabilities() map[string]bool {
+	capabilities := map[string]bool{
+		types.CapabilityStreaming: false,
+		types.CapabilityTools:     false,
+		types.CapabilityVision:    false,
+		types.CapabilitySSML:      false,
+	}
+	var sources []map[string]bool
+	if h.providers.llm != nil {
+		sources = append(sources, h.providers.llm.Capabilities())
+	}
+	if h.providers.tts != nil {
+		sources = append(sources, h.providers.tts.Capabilities())
+	}
+	if h.providers.asr != nil {
+		sources = append(sources, h.providers.asr.Capabilities())
+	}
+	for _, source := range sources {
+		for name, supported := range source {
+			capabilities[name] = capabilities[name] || supported
+		}
+	}
+	return capabilities
+}
+
+// deliverPendingOTA 设备上线后推送离线期间保存的OTA消息，发送失败时重新保存
+func (h *ConnectionHandler) deliverPendingOTA() {
+	if h.deviceID == "" {
+		return
+	}
+	queue := device.NewRedisOTAQueue(cache.GetRedis())
+	data, err := queue.Pop(context.Background(), h.deviceID)
+	if err != nil {
+		h.LogError(fmt.Sprintf("读取离线OTA消息失败: %v", err))
+		return
+	}
+	if data == nil {
+		return
+	}
+	if err := h.conn.WriteMessage(1, data); err != nil {
+		h.LogError(fmt.Sprintf("推送离线OTA消息失败: %v", err))
+		if err := queue.Enqueue(context.Background(), h.deviceID, data); err != nil {
+			h.LogError(fmt.Sprintf("重新保存离线OTA消息失败: %v", err))
+		}
+		return
+	}
+	h.LogInfo("已推送离线期间保存的OTA消息")
 }
 
 func (h *ConnectionHandler) sendTTSMessage(state string, text string, textIndex int) error {
@@ -89,9 +168,15 @@ func (h *ConnectionHandler) sendTTSMessage(state string, text string, textIndex
 }
 
 func (h *ConnectionHandler) sendSTTMessage(text string) error {
+	// 语音识别的文本附带最近一次识别结果的置信度，文本输入固定为1
+	confidence := float32(1)
+	if last := h.asrConfidence.Swap(nil); last != nil {
+		confidence = *last
+	}
 	sttMsg := map[string]interface{}{
 		"type":       "stt",
 		"text":       text,
+		"confidence": confidence,
 		"session_id": h.sessionID,
 	}
 	jsonData, err := json.Marshal(sttMsg)
@@ -105,15 +190,19 @@ func (h *ConnectionHandler) sendSTTMessage(text string) error {
 	return nil
 }
 
-// sendEmotionMessage 发送情绪消息
-func (h *ConnectionHandler) sendEmotionMessage(emotion string) error {
-	data := map[string]interface{}{
-		"type":       "llm",
+// newEmotionMessage 构造情绪消息
+func newEmotionMessage(emotion string, sessionID string) map[string]interface{} {
+	return map[string]interface{}{
+		"type":       "emotion",
 		"text":       utils.GetEmotionEmoji(emotion),
 		"emotion":    emotion,
-		"session_id": h.sessionID,
+		"session_id": sessionID,
 	}
-	jsonData, err := json.Marshal(data)
+}
+
+// sendEmotionMessage 发送情绪消息
+func (h *ConnectionHandler) sendEmotionMessage(emotion string) error {
+	jsonData, err := json.Marshal(newEmotionMessage(emotion, h.sessionID))
 	if err != nil {
 		return fmt.Errorf("序列化情绪消息失败: %v", err)
 	}
@@ -126,20 +215,9 @@ func (h *ConnectionHandler) sendAudioMessage(filepath string, text string, textI
 		// 音频发送完成后，根据配置决定是否删除文件
 		h.deleteAudioFileIfNeeded(filepath, "音频发送完成")
 
-		h.LogInfo(fmt.Sprintf("TTS音频发送任务结束(%t): %s, 索引: %d/%d", bFinishSuccess, text, textIndex, h.tts_last_text_index))
-		h.providers.asr.ResetStartListenTime()
-		if textIndex == h.tts_last_text_index {
-			if round != h.talkRound {
-				h.LogInfo("sendTTSMessage stop: 跳过结束状态发送，轮次已变化")
-			} else {
-				h.sendTTSMessage("stop", "", textIndex)
-				if h.closeAfterChat {
-					h.Close()
-				} else {
-					h.clearSpeakStatus()
-				}
-			}
-		}
+		lastTextIndex := int(atomic.LoadInt32(&h.tts_last_text_index))
+		h.LogInfo(fmt.Sprintf("TTS音频发送任务结束(%t): %s, 索引: %d/%d", bFinishSuccess, text, textIndex, lastTextIndex))
+		h.finishAudioSegment(textIndex, lastTextIndex, round)
 	}()
 
 	if len(filepath) == 0 {
@@ -147,9 +225,9 @@ func (h *ConnectionHandler) sendAudioMessage(filepath string, text string, textI
 	}
 
 	// 检查轮次
-	if round != h.talkRound {
+	if round != h.GetTalkRound() {
 		h.LogInfo(fmt.Sprintf("sendAudioMessage: 跳过过期轮次的音频: 任务轮次=%d, 当前轮次=%d, 文本=%s",
-			round, h.talkRound, text))
+			round, h.GetTalkRound(), text))
 		// 即使跳过，也要根据配置删除音频文件
 		h.deleteAudioFileIfNeeded(filepath, "跳过过期轮次")
 		return
@@ -174,8 +252,16 @@ func (h *ConnectionHandler) sendAudioMessage(filepath string, text string, textI
 			h.LogError(fmt.Sprintf("音频转PCM失败: %v", err))
 			return
 		}
+		for _, frame := range audioData {
+			h.addEchoReference(frame, 16000) // AudioToPCMData 输出16kHz单声道PCM
+		}
 	} else if h.serverAudioFormat == "opus" {
-		audioData, duration, err = utils.AudioToOpusData(filepath)
+		if strings.HasSuffix(filepath, ".wav") {
+			// PCM输出使用会话编码器逐帧编码
+			audioData, duration, err = h.encodeWavToOpus(filepath)
+		} else {
+			audioData, duration, err = utils.AudioToOpusData(filepath)
+		}
 		if err != nil {
 			h.LogError(fmt.Sprintf("音频转Opus失败: %v", err))
 			return
@@ -194,8 +280,9 @@ func (h *ConnectionHandler) sendAudioMessage(filepath string, text string, textI
 		fmt.Println("回复首句耗时:", spentTime, text, round)
 		h.logger.Debug("回复首句耗时 %s 第一句话【%s】, round: %d", spentTime, text, round)
 	}
-	fmt.Println("TTS发送", h.serverAudioFormat, text, "(索引:", textIndex, h.tts_last_text_index, "时长:", duration, "帧数:", len(audioData), ")")
-	h.logger.Debug("TTS发送(%s): \"%s\" (索引:%d/%d，时长:%f，帧数:%d)", h.serverAudioFormat, text, textIndex, h.tts_last_text_index, duration, len(audioData))
+	lastTextIndex := atomic.LoadInt32(&h.tts_last_text_index)
+	fmt.Println("TTS发送", h.serverAudioFormat, text, "(索引:", textIndex, lastTextIndex, "时长:", duration, "帧数:", len(audioData), ")")
+	h.logger.Debug("TTS发送(%s): \"%s\" (索引:%d/%d，时长:%f，帧数:%d)", h.serverAudioFormat, text, textIndex, lastTextIndex, duration, len(audioData))
 
 	// 分时发送音频数据
 	if err := h.sendAudioFrames(audioData, text, round); err != nil {
@@ -212,6 +299,99 @@ func (h *ConnectionHandler) sendAudioMessage(filepath string, text string, textI
 	bFinishSuccess = true
 }
 
+// finishAudioSegment 单个分段的音频发送结束，最后一个分段发送结束时下发 tts stop 并结束本轮
+func (h *ConnectionHandler) finishAudioSegment(textIndex int, lastTextIndex int, round int) {
+	h.providers.asr.ResetStartListenTime()
+	if textIndex != lastTextIndex {
+		return
+	}
+	if round != h.GetTalkRound() {
+		h.LogInfo("sendTTSMessage stop: 跳过结束状态发送，轮次已变化")
+		return
+	}
+	h.sendTTSMessage("stop", "", textIndex)
+	// 本轮结束，清除语言切换与语速音调
+	h.resetRoundLanguage()
+	h.resetSpeechParams()
+	if h.closeAfterChat {
+		h.closeWithSummary()
+	} else {
+		h.upgradeProviderSet()
+		h.clearSpeakStatus()
+		h.deliverPendingProactive()
+	}
+}
+
+// sessionOpusEncoder 获取会话的Opus编码器，首次使用时按服务端音频参数创建
+func (h *ConnectionHandler) sessionOpusEncoder() (*utils.OpusEncoder, error) {
+	h.opusEncoderMu.Lock()
+	defer h.opusEncoderMu.Unlock()
+	if h.opusEncoder == nil {
+		encoder, err := utils.NewOpusEncoder(h.serverAudioSampleRate, h.serverAudioChannels, 0)
+		if err != nil {
+			return nil, err
+		}
+		h.opusEncoder = encoder
+	}
+	return h.opusEncoder, nil
+}
+
+// encodeWavToOpus 读取WAV文件中的PCM数据，按服务端帧长逐帧编码为Opus，返回数据帧与时长(秒)
+func (h *ConnectionHandler) encodeWavToOpus(filepath string) ([][]byte, float64, error) {
+	pcmData, err := utils.ReadPCMDataFromWavFile(filepath)
+	if err != nil {
+		return nil, 0, err
+	}
+	encoder, err := h.sessionOpusEncoder()
+	if err != nil {
+		return nil, 0, err
+	}
+	frames, err := encoder.EncodePCM(pcmData, h.serverAudioFrameDuration)
+	if err != nil {
+		return nil, 0, err
+	}
+	if h.serverAudioChannels == 1 {
+		h.addEchoReference(pcmData, h.serverAudioSampleRate)
+	}
+	duration := float64(len(pcmData)) / float64(h.serverAudioSampleRate*2*h.serverAudioChannels)
+	return frames, duration, nil
+}
+
+// sendInterSegmentSilence 在TTS分段之间插入静音帧，时长按句尾标点调整
+func (h *ConnectionHandler) sendInterSegmentSilence(text string, paragraphEnd bool, round int) {
+	if h.config.TTSInterSegmentSilenceMs <= 0 {
+		return
+	}
+	silenceMs := utils.InterSegmentSilenceMs(text, paragraphEnd, h.config.TTSInterSegmentSilenceMs)
+	pcmData := utils.GenerateSilencePCM(silenceMs, h.serverAudioSampleRate)
+	if len(pcmData) == 0 {
+		return
+	}
+
+	var frames [][]byte
+	if h.serverAudioFormat == "pcm" {
+		frameBytes := h.serverAudioSampleRate * 2 * h.serverAudioFrameDuration / 1000
+		for offset := 0; offset < len(pcmData); offset += frameBytes {
+			end := offset + frameBytes
+			if end > len(pcmData) {
+				end = len(pcmData)
+			}
+			frames = append(frames, pcmData[offset:end])
+		}
+	} else {
+		var err error
+		frames, err = utils.PCMSlicesToOpusData([][]byte{pcmData}, h.serverAudioSampleRate, h.serverAudioChannels, 0)
+		if err != nil {
+			h.LogError(fmt.Sprintf("静音数据编码Opus失败: %v", err))
+			return
+		}
+	}
+
+	if err := h.sendAudioFrames(frames, "[静音]", round); err != nil {
+		h.LogError(fmt.Sprintf("发送段间静音失败: %v", err))
+	}
+}
+
 // sendAudioFrames 分时发送音频帧，避免撑爆客户端缓冲区
 func (h *ConnectionHandler) sendAudioFrames(audioData [][]byte, text string, round int) error {
 	if len(audioData) == 0 {
@@ -231,7 +411,7 @@ func (h *ConnectionHandler) sendAudioFrames(audioData [][]byte, text string, rou
 	// 发送预缓冲帧
 	for i := 0; i < preBufferFrames; i++ {
 		// 检查是否被打断
-		if atomic.LoadInt32(&h.serverVoiceStop) == 1 || round != h.talkRound {
+		if atomic.LoadInt32(&h.serverVoiceStop) == 1 || round != h.GetTalkRound() {
 			h.LogInfo(fmt.Sprintf("音频发送被中断(预缓冲阶段): 帧=%d/%d, 文本=%s", i+1, preBufferFrames, text))
 			return nil
 		}
@@ -239,6 +419,11 @@ func (h *ConnectionHandler) sendAudioFrames(audioData [][]byte, text string, rou
 		if err := h.conn.WriteMessage(2, audioData[i]); err != nil {
 			return fmt.Errorf("发送预缓冲音频帧失败: %v", err)
 		}
+		h.publishMonitorAudio(audioData[i])
+		if i == 0 {
+			h.audioQuality.RecordTTSAudioSent()
+			h.audioLatency.RecordAudioSent(time.Now().UnixNano())
+		}
 		playPosition += h.serverAudioFrameDuration
 	}
 
@@ -246,7 +431,7 @@ func (h *ConnectionHandler) sendAudioFrames(audioData [][]byte, text string, rou
 	remainingFrames := audioData[preBufferFrames:]
 	for i, chunk := range remainingFrames {
 		// 检查是否被打断或轮次变化
-		if atomic.LoadInt32(&h.serverVoiceStop) == 1 || round != h.talkRound {
+		if atomic.LoadInt32(&h.serverVoiceStop) == 1 || round != h.GetTalkRound() {
 			h.LogInfo(fmt.Sprintf("音频发送被中断: 帧=%d/%d, 文本=%s", i+preBufferFrames+1, len(audioData), text))
 			return nil
 		}
@@ -274,7 +459,7 @@ func (h *ConnectionHandler) sendAudioFrames(audioData [][]byte, text string, rou
 				select {
 				case <-ticker.C:
 					// 检查中断条件
-					if atomic.LoadInt32(&h.serverVoiceStop) == 1 || round != h.talkRound {
+					if atomic.LoadInt32(&h.serverVoiceStop) == 1 || round != h.GetTalkRound() {
 						h.LogInfo(fmt.Sprintf("音频发送在延迟中被中断: 帧=%d/%d, 文本=%s", i+preBufferFrames+1, len(audioData), text))
 						return nil
 					}
@@ -288,6 +473,7 @@ func (h *ConnectionHandler) sendAudioFrames(audioData [][]byte, text string, rou
 		if err := h.conn.WriteMessage(2, chunk); err != nil {
 			return fmt.Errorf("发送音频帧失败: %v", err)
 		}
+		h.publishMonitorAudio(chunk)
 
 		playPosition += h.serverAudioFrameDuration
 	}
@@ -0,0 +1,78 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"angrymiao-ai-server/src/core/providers"
+	"angrymiao-ai-server/src/core/utils"
+)
+
+const (
+	sentimentModelLLM = "llm"
+
+	// sentimentLLMTimeout LLM情感标注的超时时间，超时后回退为规则分析
+	sentimentLLMTimeout = time.Second
+
+	sentimentLLMPrompt = "Classify the sentiment of the following assistant reply. " +
+		"Answer with exactly one word from: positive, neutral, negative, excited, sad."
+)
+
+// analyzeResponseSentiment 按配置的 sentiment_model 分析回复的情感
+func (h *ConnectionHandler) analyzeResponseSentiment(text string) string {
+	if h.config != nil && h.config.SentimentModel == sentimentModelLLM && h.providers.llm != nil {
+		label, err := h.labelSentimentByLLM(text)
+		if err == nil {
+			return label
+		}
+		h.logger.Warn("LLM情感分析失败，使用规则分析: %v", err)
+	}
+	return utils.AnalyzeSentiment(text)
+}
+
+// labelSentimentByLLM 调用一次LLM标注情感，仅读取首个有效标签
+func (h *ConnectionHandler) labelSentimentByLLM(text string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), sentimentLLMTimeout)
+	defer cancel()
+
+	messages := []providers.Message{
+		{Role: "system", Content: sentimentLLMPrompt},
+		{Role: "user", Content: text},
+	}
+	responses, err := h.providers.llm.Response(ctx, h.sessionID, messages)
+	if err != nil {
+		return "", err
+	}
+
+	var builder strings.Builder
+	for {
+		select {
+		case chunk, ok := <-responses:
+			if !ok {
+				if label := utils.NormalizeSentiment(builder.String()); label != "" {
+					return label, nil
+				}
+				return "", fmt.Errorf("无法识别的情感标签: %q", builder.String())
+			}
+			builder.WriteString(chunk)
+			if label := utils.NormalizeSentiment(builder.String()); label != "" {
+				return label, nil
+			}
+		case <-ctx.Done():
+			return "", fmt.Errorf("LLM情感分析超时: %v", ctx.Err())
+		}
+	}
+}
+
+// sendResponseEmotion 分析完整回复的情感并发送情绪消息
+func (h *ConnectionHandler) sendResponseEmotion(text string) {
+	if strings.TrimSpace(text) == "" {
+		return
+	}
+	emotion := h.analyzeResponseSentiment(text)
+	if err := h.sendEmotionMessage(emotion); err != nil {
+		h.LogError(fmt.Sprintf("发送情绪消息失败: %v", err))
+	}
+}
@@ -0,0 +1,24 @@
+package core
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestEmotionMessageSerialization(t *testing.T) {
+	data, err := json.Marshal(newEmotionMessage("excited", "session-1"))
+	if err != nil {
+		t.Fatalf("序列化情绪消息失败: %v", err)
+	}
+
+	var msg map[string]string
+	if err := json.Unmarshal(data, &msg); err != nil {
+		t.Fatalf("解析情绪消息失败: %v", err)
+	}
+	if msg["type"] != "emotion" || msg["emotion"] != "excited" || msg["session_id"] != "session-1" {
+		t.Errorf("情绪消息 = %s", data)
+	}
+	if msg["text"] != "🤩" {
+		t.Errorf("情绪表情 = %q, 期望 🤩", msg["text"])
+	}
+}
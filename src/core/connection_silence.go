@@ -0,0 +1,62 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+const (
+	silenceActionPrompt = "prompt" // 只让LLM回复，不断开连接
+	silenceActionNoop   = "noop"   // 不处理
+
+	defaultMaxSilenceCount = 2
+	defaultSilencePrompt   = "长时间未检测到用户说话，请礼貌地结束对话"
+	silenceReminder        = "Are you still there?"
+)
+
+// OnSilenceThreshold ASR连续静音时回调，返回需要交给LLM的文本，返回false时本次识别结果不触发对话
+// 未达到 max_silence_count 时只向客户端发送提醒，达到后执行 silence_action
+func (h *ConnectionHandler) OnSilenceThreshold(count int) (string, bool) {
+	cfg := h.config.ASRSilence
+	maxCount := cfg.MaxSilenceCount
+	if maxCount <= 0 {
+		maxCount = defaultMaxSilenceCount
+	}
+	if count < maxCount {
+		h.LogInfo(fmt.Sprintf("检测到第%d次静音，提醒用户", count))
+		h.sendSilenceReminder()
+		return "", false
+	}
+
+	prompt := cfg.SilencePrompt
+	if prompt == "" {
+		prompt = defaultSilencePrompt
+	}
+	switch cfg.SilenceAction {
+	case silenceActionNoop:
+		h.LogInfo(fmt.Sprintf("检测到连续%d次静音，不处理", count))
+		return "", false
+	case silenceActionPrompt:
+		h.LogInfo(fmt.Sprintf("检测到连续%d次静音，提示LLM回复", count))
+		return prompt, true
+	default: // close，让LLM礼貌结束对话，回复后断开连接
+		h.LogInfo(fmt.Sprintf("检测到连续%d次静音，结束对话", count))
+		h.closeAfterChat = true
+		return prompt, true
+	}
+}
+
+// sendSilenceReminder 向客户端发送静音提醒，不触发LLM
+func (h *ConnectionHandler) sendSilenceReminder() {
+	jsonData, err := json.Marshal(map[string]interface{}{
+		"type":    "info",
+		"message": silenceReminder,
+	})
+	if err != nil {
+		h.LogError(fmt.Sprintf("序列化静音提醒失败: %v", err))
+		return
+	}
+	if err := h.conn.WriteMessage(1, jsonData); err != nil {
+		h.LogError(fmt.Sprintf("发送静音提醒失败: %v", err))
+	}
+}
@@ -0,0 +1,115 @@
+package core
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"angrymiao-ai-server/src/configs"
+	"angrymiao-ai-server/src/core/providers"
+)
+
+// silenceASR 返回固定的连续静音计数
+type silenceASR struct {
+	providers.ASRProvider
+	count int
+}
+
+func (a *silenceASR) GetSilenceCount() int { return a.count }
+
+func TestOnAsrResultSilenceThresholds(t *testing.T) {
+	const silenceText = "[SILENCE_TIMEOUT] 用户有一段时间没说话了，请礼貌提醒用户"
+	cases := []struct {
+		name      string
+		cfg       configs.ASRSilenceConfig
+		count     int
+		wantType  string // 下发消息的类型，为空表示不下发
+		wantText  string // 交给对话的文本
+		wantClose bool
+	}{
+		{"未静音时原样处理", configs.ASRSilenceConfig{}, 0, "asr_confirm", "打开客厅的灯", false},
+		{"首次静音只提醒", configs.ASRSilenceConfig{}, 1, "info", "", false},
+		{"默认结束对话", configs.ASRSilenceConfig{}, 2, "asr_confirm", defaultSilencePrompt, true},
+		{"close", configs.ASRSilenceConfig{SilenceAction: "close", SilencePrompt: "请说再见"}, 2, "asr_confirm", "请说再见", true},
+		{"prompt", configs.ASRSilenceConfig{SilenceAction: "prompt", SilencePrompt: "请提醒用户"}, 2, "asr_confirm", "请提醒用户", false},
+		{"noop", configs.ASRSilenceConfig{SilenceAction: "noop"}, 2, "", "", false},
+		{"prompt 未达阈值只提醒", configs.ASRSilenceConfig{MaxSilenceCount: 3, SilenceAction: "prompt"}, 2, "info", "", false},
+		{"超过阈值仍执行动作", configs.ASRSilenceConfig{SilenceAction: "prompt", SilencePrompt: "请提醒用户"}, 3, "asr_confirm", "请提醒用户", false},
+		{"阈值为1时不提醒", configs.ASRSilenceConfig{MaxSilenceCount: 1}, 1, "asr_confirm", defaultSilencePrompt, true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			h, conn, _ := newConfirmTestHandler(t, 60000)
+			h.config.ASRSilence = tc.cfg
+			h.providers.asr = &silenceASR{count: tc.count}
+			text := silenceText
+			if tc.count == 0 {
+				text = "打开客厅的灯"
+			}
+
+			finished := h.OnAsrResult(text, true, 1)
+			if finished != (tc.wantText != "") {
+				t.Errorf("OnAsrResult = %v", finished)
+			}
+			if h.closeAfterChat != tc.wantClose {
+				t.Errorf("closeAfterChat = %v, 期望 %v", h.closeAfterChat, tc.wantClose)
+			}
+			if tc.wantType == "" {
+				if len(conn.messages) != 0 {
+					t.Errorf("不应下发消息, got %s", conn.messages[0])
+				}
+				return
+			}
+			if len(conn.messages) != 1 {
+				t.Fatalf("下发消息数 = %d, 期望 1", len(conn.messages))
+			}
+			var msg map[string]string
+			if err := json.Unmarshal(conn.messages[0], &msg); err != nil {
+				t.Fatalf("解析消息失败: %v", err)
+			}
+			if msg["type"] != tc.wantType {
+				t.Errorf("消息 = %s, 期望类型 %s", conn.messages[0], tc.wantType)
+			}
+			if tc.wantType == "info" && msg["message"] != silenceReminder {
+				t.Errorf("静音提醒 = %s", conn.messages[0])
+			}
+			if tc.wantType == "asr_confirm" && msg["text"] != tc.wantText {
+				t.Errorf("交给对话的文本 = %q, 期望 %q", msg["text"], tc.wantText)
+			}
+		})
+	}
+}
+
+func TestOnAsrResultDropsLowConfidence(t *testing.T) {
+	h, conn, _ := newConfirmTestHandler(t, 60000)
+	h.config.ASR.MinConfidence = 0.6
+	h.providers.asr = &silenceASR{}
+	h.audioQuality = NewAudioQualityTracker("s1")
+
+	if h.OnAsrResult("打开客厅的灯", true, 0.3) {
+		t.Error("低置信度的识别结果应被丢弃")
+	}
+	if len(conn.messages) != 0 {
+		t.Fatalf("丢弃的识别结果不应下发消息, got %s", conn.messages[0])
+	}
+	if !h.OnAsrResult("打开客厅的灯", true, 0.9) || len(conn.messages) != 1 {
+		t.Fatalf("置信度达标的识别结果应交给对话, 下发消息数 = %d", len(conn.messages))
+	}
+	if q := h.audioQuality.Snapshot(); q.ConfidenceSamples != 1 || math.Abs(q.ASRConfidence-0.9) > 1e-6 {
+		t.Errorf("应只统计通过过滤的识别置信度, got %.2f (%d 次)", q.ASRConfidence, q.ConfidenceSamples)
+	}
+
+	// stt 消息附带识别结果的置信度，文本输入固定为1
+	for _, want := range []float64{0.9, 1} {
+		if err := h.sendSTTMessage("打开客厅的灯"); err != nil {
+			t.Fatalf("发送STT消息失败: %v", err)
+		}
+		var msg map[string]interface{}
+		if err := json.Unmarshal(conn.messages[len(conn.messages)-1], &msg); err != nil {
+			t.Fatalf("解析消息失败: %v", err)
+		}
+		if got, _ := msg["confidence"].(float64); got != want {
+			t.Errorf("stt 消息置信度 = %v, 期望 %v", msg["confidence"], want)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package core
+
+import "angrymiao-ai-server/src/core/utils"
+
+// speculateIntents 预先计算退出意图与唤醒词快速回复的匹配结果，不触发关闭连接或播报
+func (h *ConnectionHandler) speculateIntents(text string) SpeculativeResult {
+	return SpeculativeResult{
+		Quit:       h.matchQuitIntent(text),
+		QuickReply: h.matchQuickReply(text),
+	}
+}
+
+// matchQuickReply 判断文本是否为需要快速回复的唤醒词
+func (h *ConnectionHandler) matchQuickReply(text string) bool {
+	return h.config.QuickReply && utils.IsWakeUpWord(text)
+}
+
+// confirmSpeculation 用最终识别结果确认流式识别中的预计算
+func (h *ConnectionHandler) confirmSpeculation(text string) {
+	if _, hit := h.speculativeASR.OnFinal(text); hit {
+		hits, misses, rate := h.speculativeASR.HitRate()
+		h.logger.Debug("流式识别预计算命中: %s，命中率 %.2f (%d/%d)", text, rate, hits, hits+misses)
+	}
+}
@@ -0,0 +1,63 @@
+package core
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"angrymiao-ai-server/src/core/utils"
+)
+
+// conversationStarter 返回连接建立后播报的欢迎语，唯一启用的Bot好友配置了欢迎语时优先使用
+func (h *ConnectionHandler) conversationStarter() string {
+	h.userConfigsMu.RLock()
+	active := singleActiveBot(h.userConfigs)
+	h.userConfigsMu.RUnlock()
+	if active != nil && active.WelcomeMessage != "" {
+		return active.WelcomeMessage
+	}
+	return h.config.ConversationStarter
+}
+
+// scheduleConversationStarter 延迟 conversation_starter_delay_ms 后主动播报欢迎语
+// 用户在此之前已开始对话时不再播报
+func (h *ConnectionHandler) scheduleConversationStarter() {
+	if h.conversationStarter() == "" {
+		return
+	}
+	delay := time.Duration(h.config.ConversationStarterDelayMs) * time.Millisecond
+	go func() {
+		select {
+		case <-h.stopChan:
+			return
+		case <-time.After(delay):
+		}
+		if h.GetTalkRound() != 0 {
+			return
+		}
+		h.speakConversationStarter(h.conversationStarter())
+	}()
+}
+
+// speakConversationStarter 使用初始音色播报欢迎语，不增加对话轮次
+func (h *ConnectionHandler) speakConversationStarter(text string) {
+	if text == "" {
+		return
+	}
+	h.LogInfo(fmt.Sprintf("播报欢迎语: %s", text))
+	if h.initailVoice == "" || h.currentVoice() == h.initailVoice {
+		if err := h.SystemSpeak(text); err != nil {
+			h.LogError(fmt.Sprintf("播报欢迎语失败: %v", err))
+		}
+		return
+	}
+
+	// 当前音色已被用户配置或语言切换修改，逐段切换到初始音色同步合成
+	round := h.GetTalkRound()
+	index := int(atomic.LoadInt32(&h.tts_last_text_index))
+	for _, item := range utils.SplitByPunctuation(text) {
+		index++
+		atomic.StoreInt32(&h.tts_last_text_index, int32(index))
+		h.speakPrefixWithVoice(item, h.initailVoice, index, round)
+	}
+}
@@ -0,0 +1,108 @@
+package core
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"angrymiao-ai-server/src/configs"
+	"angrymiao-ai-server/src/core/providers"
+	"angrymiao-ai-server/src/core/providers/tts"
+	"angrymiao-ai-server/src/core/types"
+)
+
+// voiceRecordingTTS 记录每次合成时使用的音色
+type voiceRecordingTTS struct {
+	providers.TTSProvider
+	config *tts.Config
+	voices []string
+}
+
+func (m *voiceRecordingTTS) Config() *tts.Config { return m.config }
+
+func (m *voiceRecordingTTS) SetVoice(voice string) error {
+	m.config.Voice = voice
+	return nil
+}
+
+func (m *voiceRecordingTTS) ToTTS(text string) (string, error) {
+	m.voices = append(m.voices, m.config.Voice)
+	return "", nil
+}
+
+func newStarterTestHandler(t *testing.T, voice string) (*ConnectionHandler, *voiceRecordingTTS) {
+	t.Helper()
+	h := newPrefixTestHandler(t, &configs.Config{ConversationStarter: "您好！有什么可以帮您？", ConversationStarterDelayMs: 10})
+	h.stopChan = make(chan struct{})
+	h.audioMessagesQueue = make(chan struct {
+		filepath     string
+		text         string
+		round        int
+		textIndex    int
+		paragraphEnd bool
+		stream       <-chan []byte
+	}, 10)
+	h.initailVoice = "zh-CN-XiaoxiaoNeural"
+	mockTTS := &voiceRecordingTTS{config: &tts.Config{Voice: voice}}
+	h.providers.tts = mockTTS
+	t.Cleanup(func() { close(h.stopChan) })
+	return h, mockTTS
+}
+
+func waitQueued(t *testing.T, h *ConnectionHandler) []string {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for len(h.ttsQueue) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("欢迎语未加入TTS队列")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	return queuedSegments(h)
+}
+
+func TestConversationStarterOnConnect(t *testing.T) {
+	h, _ := newStarterTestHandler(t, "zh-CN-XiaoxiaoNeural")
+	h.scheduleConversationStarter()
+	if got := waitQueued(t, h); len(got) != 2 || got[0] != "您好" || got[1] != "有什么可以帮您" {
+		t.Errorf("播报的欢迎语 = %v", got)
+	}
+	if h.GetTalkRound() != 0 {
+		t.Errorf("欢迎语不应增加对话轮次, 实际 %d", h.GetTalkRound())
+	}
+
+	// 唯一启用的Bot好友配置了欢迎语时优先使用
+	h.userConfigs = []*types.BotConfig{{FunctionName: "weather", IsActive: true, WelcomeMessage: "我是天气助手。"}}
+	h.scheduleConversationStarter()
+	if got := waitQueued(t, h); len(got) != 1 || got[0] != "我是天气助手" {
+		t.Errorf("Bot欢迎语 = %v", got)
+	}
+}
+
+func TestConversationStarterSkippedAfterUserSpeaks(t *testing.T) {
+	h, _ := newStarterTestHandler(t, "zh-CN-XiaoxiaoNeural")
+	h.scheduleConversationStarter()
+	atomic.StoreInt32(&h.talkRound, 1)
+	time.Sleep(50 * time.Millisecond)
+	if got := queuedSegments(h); len(got) != 0 {
+		t.Errorf("用户已开始对话时仍播报了欢迎语: %v", got)
+	}
+}
+
+func TestConversationStarterUsesInitialVoice(t *testing.T) {
+	h, mockTTS := newStarterTestHandler(t, "en-US-AriaNeural")
+	h.speakConversationStarter("您好！有什么可以帮您？")
+
+	if len(h.audioMessagesQueue) != 2 {
+		t.Fatalf("加入发送队列的分段数 = %d, 期望 2", len(h.audioMessagesQueue))
+	}
+	for _, voice := range mockTTS.voices {
+		if voice != "zh-CN-XiaoxiaoNeural" {
+			t.Errorf("欢迎语合成音色 = %s, 期望初始音色", voice)
+		}
+	}
+	if mockTTS.config.Voice != "en-US-AriaNeural" {
+		t.Errorf("播报后音色 = %s, 期望恢复会话音色", mockTTS.config.Voice)
+	}
+}
@@ -0,0 +1,84 @@
+package core
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// SessionProviderInfo 会话正在使用的提供者，Name 为配置中选用的名称，Type 为实现类型
+type SessionProviderInfo struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// SessionQueueDepths 会话各处理队列中等待的任务数
+type SessionQueueDepths struct {
+	ClientAudioQueue   int `json:"client_audio_queue"`
+	ClientTextQueue    int `json:"client_text_queue"`
+	TTSQueue           int `json:"tts_queue"`
+	AudioMessagesQueue int `json:"audio_messages_queue"`
+}
+
+// SessionStateSnapshot 会话当前状态，用于排查卡住的会话
+// 各字段分别读取，不保证彼此之间是同一时刻的值
+type SessionStateSnapshot struct {
+	SessionID        string                         `json:"session_id"`
+	TalkRound        int                            `json:"talk_round"`
+	ClientListenMode string                         `json:"client_listen_mode"`
+	EnableVAD        bool                           `json:"enable_vad"`
+	ServerVoiceStop  bool                           `json:"server_voice_stop"`
+	CloseAfterChat   bool                           `json:"close_after_chat"`
+	Providers        map[string]SessionProviderInfo `json:"providers"`
+	QueueDepths      SessionQueueDepths             `json:"queue_depths"`
+	DialogueTurns    int                            `json:"dialogue_turns"` // 对话历史中的消息数，包含系统提示词
+	ActiveTools      []string                       `json:"active_tools"`
+	LastActiveTime   time.Time                      `json:"last_active_time"`
+}
+
+// SessionState 获取会话状态快照，只读取不修改会话
+func (h *ConnectionHandler) SessionState() SessionStateSnapshot {
+	state := SessionStateSnapshot{
+		SessionID:        h.sessionID,
+		TalkRound:        h.GetTalkRound(),
+		ClientListenMode: h.clientListenMode,
+		EnableVAD:        h.enableVAD,
+		ServerVoiceStop:  atomic.LoadInt32(&h.serverVoiceStop) == 1,
+		CloseAfterChat:   h.closeAfterChat,
+		Providers:        make(map[string]SessionProviderInfo),
+		QueueDepths: SessionQueueDepths{
+			ClientAudioQueue:   len(h.clientAudioQueue),
+			ClientTextQueue:    len(h.clientTextQueue),
+			TTSQueue:           len(h.ttsQueue),
+			AudioMessagesQueue: len(h.audioMessagesQueue),
+		},
+		ActiveTools: []string{},
+	}
+
+	active := map[string]interface{}{
+		"ASR": h.providers.asr,
+		"LLM": h.providers.llm,
+		"TTS": h.providers.tts,
+	}
+	for kind, provider := range active {
+		if provider == nil {
+			continue
+		}
+		info := SessionProviderInfo{Type: fmt.Sprintf("%T", provider)}
+		if h.config != nil {
+			info.Name = h.config.SelectedModule[kind]
+		}
+		state.Providers[kind] = info
+	}
+
+	if h.dialogueManager != nil {
+		state.DialogueTurns = h.dialogueManager.Length()
+	}
+	if h.functionRegister != nil {
+		state.ActiveTools = h.functionRegister.ListFunctions()
+	}
+	if h.conn != nil {
+		state.LastActiveTime = h.conn.GetLastActiveTime()
+	}
+	return state
+}
@@ -0,0 +1,59 @@
+package core
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"angrymiao-ai-server/src/configs"
+	"angrymiao-ai-server/src/core/chat"
+)
+
+// activeConn 提供固定的最后活跃时间
+type activeConn struct {
+	recordingConn
+	lastActive time.Time
+}
+
+func (c *activeConn) GetLastActiveTime() time.Time { return c.lastActive }
+
+func TestSessionStateSnapshot(t *testing.T) {
+	h := newPrefixTestHandler(t, &configs.Config{SelectedModule: map[string]string{"LLM": "ChatGLMLLM"}})
+	lastActive := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	h.conn = &activeConn{lastActive: lastActive}
+	h.sessionID = "s1"
+	h.clientListenMode = "manual"
+	h.enableVAD = true
+	atomic.StoreInt32(&h.serverVoiceStop, 1)
+	atomic.StoreInt32(&h.talkRound, 4)
+	h.clientAudioQueue = make(chan clientAudioFrame, 10)
+	h.clientTextQueue = make(chan string, 10)
+	h.clientAudioQueue <- clientAudioFrame{}
+	h.clientTextQueue <- "你好"
+	h.clientTextQueue <- "在吗"
+	h.ttsQueue <- struct {
+		text         string
+		round        int
+		textIndex    int
+		paragraphEnd bool
+	}{text: "你好"}
+	h.dialogueManager.Put(chat.Message{Role: "user", Content: "你好"})
+
+	state := h.SessionState()
+	if state.SessionID != "s1" || state.TalkRound != 4 || state.ClientListenMode != "manual" || !state.EnableVAD || !state.ServerVoiceStop || state.CloseAfterChat {
+		t.Errorf("会话状态 = %+v", state)
+	}
+	want := SessionQueueDepths{ClientAudioQueue: 1, ClientTextQueue: 2, TTSQueue: 1}
+	if state.QueueDepths != want {
+		t.Errorf("队列长度 = %+v, 期望 %+v", state.QueueDepths, want)
+	}
+	if state.DialogueTurns != 1 || state.ActiveTools == nil || !state.LastActiveTime.Equal(lastActive) {
+		t.Errorf("对话与活跃时间 = %+v", state)
+	}
+	if llm, ok := state.Providers["LLM"]; !ok || llm.Name != "ChatGLMLLM" || llm.Type != "*core.streamingLLM" {
+		t.Errorf("提供者 = %+v", state.Providers)
+	}
+	if _, ok := state.Providers["TTS"]; ok {
+		t.Error("未设置的TTS不应出现在提供者中")
+	}
+}
@@ -0,0 +1,118 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"angrymiao-ai-server/src/configs/database"
+	"angrymiao-ai-server/src/core/chat"
+	"angrymiao-ai-server/src/core/providers"
+	"angrymiao-ai-server/src/models"
+)
+
+// sessionSummaryTimeout 生成会话摘要的超时时间
+const sessionSummaryTimeout = 10 * time.Second
+
+const sessionSummaryPrompt = "请用一两句话简要总结以下对话的主要内容，只输出摘要：\n%s"
+
+// closeWithSummary 对话结束后关闭连接
+// 开启 generate_session_summary_on_close 时先在后台生成会话摘要，保存并下发给客户端后再关闭
+func (h *ConnectionHandler) closeWithSummary() {
+	if !h.config.GenerateSessionSummaryOnClose {
+		h.Close()
+		return
+	}
+	go func() {
+		defer h.Close()
+		summary, err := h.generateSessionSummary()
+		if err != nil {
+			h.LogError(fmt.Sprintf("生成会话摘要失败: %v", err))
+			return
+		}
+		if summary == "" {
+			return
+		}
+		h.saveSessionSummary(summary)
+		if err := h.sendSessionSummaryMessage(summary); err != nil {
+			h.LogError(fmt.Sprintf("发送会话摘要失败: %v", err))
+		}
+	}()
+}
+
+// sessionTranscript 拼接本次会话中用户与助手的文本对话
+func (h *ConnectionHandler) sessionTranscript() string {
+	if h.dialogueManager == nil {
+		return ""
+	}
+	var builder strings.Builder
+	for _, msg := range h.dialogueManager.GetLLMDialogue() {
+		if (msg.Role != "user" && msg.Role != "assistant") || msg.Content == "" {
+			continue
+		}
+		fmt.Fprintf(&builder, "%s: %s\n", msg.Role, msg.Content)
+	}
+	return strings.TrimSpace(builder.String())
+}
+
+// generateSessionSummary 调用一次LLM生成会话摘要，没有对话内容时返回空
+// 会话上下文即将随连接关闭而取消，使用独立的超时上下文
+func (h *ConnectionHandler) generateSessionSummary() (string, error) {
+	transcript := h.sessionTranscript()
+	if transcript == "" || h.providers.llm == nil {
+		return "", nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), sessionSummaryTimeout)
+	defer cancel()
+	return h.completeLLMPrompt(ctx, fmt.Sprintf(sessionSummaryPrompt, transcript))
+}
+
+// completeLLMPrompt 以单条用户消息调用一次LLM，返回完整回复文本
+func (h *ConnectionHandler) completeLLMPrompt(ctx context.Context, prompt string) (string, error) {
+	messages := []providers.Message{
+		{Role: "user", Content: prompt},
+	}
+	responses, err := h.providers.llm.Response(ctx, h.sessionID, messages)
+	if err != nil {
+		return "", err
+	}
+
+	var builder strings.Builder
+	for {
+		select {
+		case chunk, ok := <-responses:
+			if !ok {
+				return strings.TrimSpace(builder.String()), nil
+			}
+			builder.WriteString(chunk)
+		case <-ctx.Done():
+			return "", fmt.Errorf("等待LLM回复超时: %v", ctx.Err())
+		}
+	}
+}
+
+// saveSessionSummary 将会话摘要保存为 role=summary 的对话记录，未绑定用户或未初始化数据库时跳过
+func (h *ConnectionHandler) saveSessionSummary(summary string) {
+	if h.userID == "" || database.DB == nil {
+		return
+	}
+	record := models.DialogueMessage{UserID: h.userID, Role: chat.RoleSummary, Content: summary}
+	if err := database.DB.Create(&record).Error; err != nil {
+		h.LogError(fmt.Sprintf("保存会话摘要失败: %v", err))
+	}
+}
+
+// sendSessionSummaryMessage 下发会话摘要，供客户端在连接关闭前展示
+func (h *ConnectionHandler) sendSessionSummaryMessage(summary string) error {
+	jsonData, err := json.Marshal(map[string]interface{}{
+		"type":       "session_summary",
+		"summary":    summary,
+		"session_id": h.sessionID,
+	})
+	if err != nil {
+		return fmt.Errorf("序列化会话摘要消息失败: %v", err)
+	}
+	return h.conn.WriteMessage(1, jsonData)
+}
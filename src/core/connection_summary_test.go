@@ -0,0 +1,156 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"angrymiao-ai-server/src/configs"
+	"angrymiao-ai-server/src/configs/database"
+	"angrymiao-ai-server/src/core/chat"
+	"angrymiao-ai-server/src/core/providers"
+	"angrymiao-ai-server/src/core/utils"
+	"angrymiao-ai-server/src/models"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// summaryLLM 返回固定摘要并记录收到的提示词
+type summaryLLM struct {
+	providers.LLMProvider
+	prompt string
+}
+
+func (m *summaryLLM) Response(ctx context.Context, sessionID string, messages []providers.Message) (<-chan string, error) {
+	m.prompt = messages[0].Content
+	ch := make(chan string, 2)
+	ch <- "用户询问了天气，"
+	ch <- "助手建议带伞。"
+	close(ch)
+	return ch, nil
+}
+
+// closeAwareConn 记录每条消息下发时连接是否已关闭
+type closeAwareConn struct {
+	recordingConn
+	stopChan      chan struct{}
+	writtenClosed []bool
+}
+
+func (c *closeAwareConn) WriteMessage(messageType int, data []byte) error {
+	closed := false
+	select {
+	case <-c.stopChan:
+		closed = true
+	default:
+	}
+	c.mu.Lock()
+	c.writtenClosed = append(c.writtenClosed, closed)
+	c.mu.Unlock()
+	return c.recordingConn.WriteMessage(messageType, data)
+}
+
+func newSummaryTestHandler(t *testing.T, enabled bool) (*ConnectionHandler, *closeAwareConn, *summaryLLM) {
+	t.Helper()
+	logger, err := utils.NewLogger(&utils.LogCfg{LogLevel: "ERROR", LogDir: t.TempDir(), LogFile: "test.log"})
+	if err != nil {
+		t.Fatalf("创建日志失败: %v", err)
+	}
+	stopChan := make(chan struct{})
+	conn := &closeAwareConn{stopChan: stopChan}
+	h := &ConnectionHandler{
+		logger:          logger,
+		conn:            conn,
+		config:          &configs.Config{GenerateSessionSummaryOnClose: enabled},
+		stopChan:        stopChan,
+		dialogueManager: chat.NewDialogueManager(logger, nil),
+	}
+	h.dialogueManager.Put(chat.Message{Role: "user", Content: "明天会下雨吗"})
+	h.dialogueManager.Put(chat.Message{Role: "assistant", Content: "明天有雨，记得带伞"})
+	llm := &summaryLLM{}
+	h.providers.llm = llm
+	return h, conn, llm
+}
+
+func waitClosed(t *testing.T, h *ConnectionHandler) {
+	t.Helper()
+	select {
+	case <-h.stopChan:
+	case <-time.After(time.Second):
+		t.Fatal("连接未关闭")
+	}
+}
+
+func TestCloseWithSummarySendsSummaryBeforeClose(t *testing.T) {
+	h, conn, llm := newSummaryTestHandler(t, true)
+
+	h.closeWithSummary()
+	waitClosed(t, h)
+
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	if len(conn.messages) != 1 {
+		t.Fatalf("下发消息数 = %d, 期望 1", len(conn.messages))
+	}
+	var msg struct {
+		Type    string `json:"type"`
+		Summary string `json:"summary"`
+	}
+	if err := json.Unmarshal(conn.messages[0], &msg); err != nil {
+		t.Fatalf("解析摘要消息失败: %v", err)
+	}
+	if msg.Type != "session_summary" || msg.Summary != "用户询问了天气，助手建议带伞。" {
+		t.Errorf("摘要消息 = %+v", msg)
+	}
+	if conn.writtenClosed[0] {
+		t.Error("摘要消息应在连接关闭前下发")
+	}
+	if !strings.Contains(llm.prompt, "user: 明天会下雨吗") || !strings.Contains(llm.prompt, "assistant: 明天有雨，记得带伞") {
+		t.Errorf("摘要提示词缺少对话内容: %q", llm.prompt)
+	}
+}
+
+func TestCloseWithSummaryDisabled(t *testing.T) {
+	h, conn, llm := newSummaryTestHandler(t, false)
+
+	h.closeWithSummary()
+	waitClosed(t, h)
+
+	if len(conn.messages) != 0 || llm.prompt != "" {
+		t.Error("未开启时应直接关闭连接，不生成摘要")
+	}
+}
+
+func TestSessionSummaryStoredAndExcludedFromHistory(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "summary.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("打开测试数据库失败: %v", err)
+	}
+	if err := db.AutoMigrate(&models.DialogueMessage{}); err != nil {
+		t.Fatalf("迁移失败: %v", err)
+	}
+	original := database.DB
+	database.DB = db
+	t.Cleanup(func() { database.DB = original })
+
+	h, _, _ := newSummaryTestHandler(t, true)
+	h.userID = "42"
+	h.closeWithSummary()
+	waitClosed(t, h)
+
+	var rows []models.DialogueMessage
+	db.Where("user_id = ? AND role = ?", "42", chat.RoleSummary).Find(&rows)
+	if len(rows) != 1 || rows[0].Content != "用户询问了天气，助手建议带伞。" {
+		t.Fatalf("摘要记录 = %+v", rows)
+	}
+	// 摘要不作为对话历史发送给LLM
+	db.Create(&models.DialogueMessage{UserID: "42", Role: "user", Content: "你好"})
+	messages, err := chat.NewPostgresMemory("42").QueryMessagesLimit(0)
+	if err != nil || len(messages) != 1 || messages[0].Role != "user" {
+		t.Errorf("加载的对话历史 = %+v, %v", messages, err)
+	}
+}
@@ -0,0 +1,74 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"angrymiao-ai-server/src/core/providers"
+	"angrymiao-ai-server/src/core/tokenusage"
+)
+
+// estimateRequestTokens 估算本次LLM请求的输入token数
+func estimateRequestTokens(messages []providers.Message) int64 {
+	var tokens int64
+	for _, msg := range messages {
+		tokens += tokenusage.EstimateTokens(msg.Content)
+	}
+	return tokens
+}
+
+// allowLLMRequest 判断本次LLM请求是否超出用户的每日token预算
+// 超出时通知客户端、播报提示并在本轮结束后关闭连接；未统计用量或查询失败时放行
+func (h *ConnectionHandler) allowLLMRequest(ctx context.Context, requestTokens int64, round int) bool {
+	tracker := tokenusage.Get()
+	if tracker == nil || h.userID == "" {
+		return true
+	}
+	status, err := tracker.Status(ctx, h.userID)
+	if err != nil {
+		h.LogError(fmt.Sprintf("查询token用量失败，放行本次请求: %v", err))
+		return true
+	}
+	if !status.Exceeds(requestTokens) {
+		return true
+	}
+
+	h.LogInfo(fmt.Sprintf("今日token预算已用完: 已用%d, 本次预计%d, 预算%d", status.Used, requestTokens, status.Budget))
+	if err := h.sendTokenBudgetMessage(status.ResetAt); err != nil {
+		h.LogError(fmt.Sprintf("发送token预算消息失败: %v", err))
+	}
+	h.closeAfterChat = true
+	atomic.StoreInt32(&h.tts_last_text_index, 1)
+	h.SpeakAndPlay("今天的对话额度已经用完了，明天再来找我聊天吧", 1, round)
+	return false
+}
+
+// recordTokenUsage 累加本次LLM请求的输入与输出token用量
+func (h *ConnectionHandler) recordTokenUsage(ctx context.Context, requestTokens int64, response string) {
+	tracker := tokenusage.Get()
+	if tracker == nil || h.userID == "" {
+		return
+	}
+	tokens := requestTokens + tokenusage.EstimateTokens(response)
+	if _, err := tracker.Add(ctx, h.userID, tokens); err != nil {
+		h.LogError(fmt.Sprintf("记录token用量失败: %v", err))
+	}
+}
+
+// sendTokenBudgetMessage 发送每日token预算用完的错误消息，reset_at 为预算重置时间
+func (h *ConnectionHandler) sendTokenBudgetMessage(resetAt time.Time) error {
+	jsonData, err := json.Marshal(map[string]interface{}{
+		"type":       "error",
+		"code":       "TOKEN_BUDGET_EXCEEDED",
+		"message":    "今日对话额度已用完",
+		"reset_at":   resetAt.Format(time.RFC3339),
+		"session_id": h.sessionID,
+	})
+	if err != nil {
+		return fmt.Errorf("序列化token预算消息失败: %v", err)
+	}
+	return h.conn.WriteMessage(1, jsonData)
+}
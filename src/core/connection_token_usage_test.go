@@ -0,0 +1,73 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"angrymiao-ai-server/src/configs"
+	"angrymiao-ai-server/src/core/providers"
+	"angrymiao-ai-server/src/core/tokenusage"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func setTestTokenTracker(t *testing.T, budget int64) *tokenusage.Tracker {
+	t.Helper()
+	server := miniredis.RunT(t)
+	tracker := tokenusage.NewTracker(redis.NewClient(&redis.Options{Addr: server.Addr()}), nil, budget)
+	tokenusage.SetDefault(tracker)
+	t.Cleanup(func() { tokenusage.SetDefault(nil) })
+	return tracker
+}
+
+func TestGenResponseRecordsTokenUsage(t *testing.T) {
+	tracker := setTestTokenTracker(t, 1000)
+	h := newPrefixTestHandler(t, &configs.Config{})
+	h.userID = "42"
+
+	if err := h.genResponseByLLM(context.Background(), nil, 1); err != nil {
+		t.Fatalf("genResponseByLLM: %v", err)
+	}
+
+	// 回复"今天天气很好。适合出门"共11个字符，约3个token
+	status, _ := tracker.Status(context.Background(), "42")
+	if status.Used != 3 {
+		t.Errorf("记录的token用量 = %d, 期望 3", status.Used)
+	}
+}
+
+func TestGenResponseRejectedWhenOverBudget(t *testing.T) {
+	tracker := setTestTokenTracker(t, 10)
+	tracker.Add(context.Background(), "42", 10)
+	h := newPrefixTestHandler(t, &configs.Config{})
+	h.userID = "42"
+	conn := h.conn.(*recordingConn)
+
+	messages := []providers.Message{{Role: "user", Content: "明天会下雨吗"}}
+	if err := h.genResponseByLLM(context.Background(), messages, 1); err == nil {
+		t.Fatal("超出预算时应返回错误")
+	}
+
+	if got := queuedSegments(h); len(got) != 1 || got[0] == "今天天气" {
+		t.Errorf("TTS分段 = %q, 超出预算时不应调用LLM，只播报提示", got)
+	}
+	if !h.closeAfterChat {
+		t.Error("超出预算后应在本轮结束时关闭连接")
+	}
+	var msg struct {
+		Type    string `json:"type"`
+		Code    string `json:"code"`
+		ResetAt string `json:"reset_at"`
+	}
+	if len(conn.messages) == 0 || json.Unmarshal(conn.messages[0], &msg) != nil {
+		t.Fatalf("未发送预算错误消息: %q", conn.messages)
+	}
+	if msg.Type != "error" || msg.Code != "TOKEN_BUDGET_EXCEEDED" || msg.ResetAt == "" {
+		t.Errorf("预算错误消息 = %+v", msg)
+	}
+	if status, _ := tracker.Status(context.Background(), "42"); status.Used != 10 {
+		t.Errorf("被拒绝的请求不应计入用量, got %d", status.Used)
+	}
+}
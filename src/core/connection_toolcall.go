@@ -0,0 +1,59 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"slices"
+)
+
+const (
+	defaultMaxToolCallDepth = 5
+	toolCallAbortMessage    = "抱歉，工具调用过于复杂，请简化您的请求"
+)
+
+type toolContinuationKey struct{}
+
+// withToolContinuation 标记本次LLM请求由 functionName 的调用结果触发
+func withToolContinuation(ctx context.Context, functionName string) context.Context {
+	return context.WithValue(ctx, toolContinuationKey{}, functionName)
+}
+
+// toolContinuationFrom 返回触发本次LLM请求的函数名，非工具调用后的请求返回false
+func toolContinuationFrom(ctx context.Context) (string, bool) {
+	functionName, ok := ctx.Value(toolContinuationKey{}).(string)
+	return functionName, ok
+}
+
+func (h *ConnectionHandler) maxToolCallDepth() int {
+	if h.config.MaxToolCallDepth > 0 {
+		return h.config.MaxToolCallDepth
+	}
+	return defaultMaxToolCallDepth
+}
+
+// enterToolCall 进入一层工具调用后的LLM请求
+// 同一函数在调用链中重复出现视为循环，立即中止；嵌套深度超过 max_tool_call_depth 时中止
+func (h *ConnectionHandler) enterToolCall(functionName string) error {
+	h.toolCallMu.Lock()
+	defer h.toolCallMu.Unlock()
+	if slices.Contains(h.toolCallChain, functionName) {
+		return fmt.Errorf("检测到循环工具调用: %v -> %s", h.toolCallChain, functionName)
+	}
+	if maxDepth := h.maxToolCallDepth(); h.toolCallDepth >= maxDepth {
+		return fmt.Errorf("工具调用深度超过上限 %d: %v -> %s", maxDepth, h.toolCallChain, functionName)
+	}
+	h.toolCallDepth++
+	h.toolCallChain = append(h.toolCallChain, functionName)
+	return nil
+}
+
+// exitToolCall 退出一层工具调用后的LLM请求
+func (h *ConnectionHandler) exitToolCall() {
+	h.toolCallMu.Lock()
+	defer h.toolCallMu.Unlock()
+	if h.toolCallDepth == 0 {
+		return
+	}
+	h.toolCallDepth--
+	h.toolCallChain = h.toolCallChain[:len(h.toolCallChain)-1]
+}
@@ -0,0 +1,94 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"angrymiao-ai-server/src/configs"
+	"angrymiao-ai-server/src/core/mcp"
+	"angrymiao-ai-server/src/core/providers"
+	"angrymiao-ai-server/src/core/types"
+
+	"github.com/angrymiao/go-openai"
+)
+
+// toolCallingLLM 每次请求都返回一个工具调用，name 返回第n次请求调用的函数名
+type toolCallingLLM struct {
+	providers.LLMProvider
+	calls atomic.Int32
+	name  func(n int) string
+}
+
+func (m *toolCallingLLM) ResponseWithFunctions(ctx context.Context, sessionID string, messages []providers.Message, tools []openai.Tool) (<-chan types.Response, error) {
+	n := int(m.calls.Add(1))
+	ch := make(chan types.Response, 1)
+	ch <- types.Response{ToolCalls: []types.ToolCall{{
+		ID:       fmt.Sprintf("call-%d", n),
+		Function: types.FunctionCall{Name: m.name(n), Arguments: "{}"},
+	}}}
+	close(ch)
+	return ch, nil
+}
+
+func (m *toolCallingLLM) Capabilities() map[string]bool {
+	return map[string]bool{types.CapabilityStreaming: true, types.CapabilityTools: true}
+}
+
+// newToolLoopTestHandler 工具均为Bot函数，LLM类型无效时以错误信息作为结果继续请求LLM
+func newToolLoopTestHandler(t *testing.T, maxDepth int, name func(n int) string) (*ConnectionHandler, *toolCallingLLM) {
+	t.Helper()
+	h := newPrefixTestHandler(t, &configs.Config{MaxToolCallDepth: maxDepth})
+	h.mcpManager = &mcp.Manager{}
+	for i := 1; i <= 10; i++ {
+		h.userConfigs = append(h.userConfigs, &types.BotConfig{FunctionName: fmt.Sprintf("tool_%d", i), LLMType: "not_exist", ModelName: "m", IsActive: true})
+	}
+	llm := &toolCallingLLM{name: name}
+	h.providers.llm = llm
+	return h, llm
+}
+
+func TestToolCallDepthLimit(t *testing.T) {
+	h, llm := newToolLoopTestHandler(t, 0, func(n int) string { return fmt.Sprintf("tool_%d", n) })
+
+	if err := h.genResponseByLLM(context.Background(), nil, 1); err != nil {
+		t.Fatalf("genResponseByLLM: %v", err)
+	}
+	// 首次请求与5次工具调用后的请求，第6次工具调用后中止
+	if got := llm.calls.Load(); got != 6 {
+		t.Errorf("LLM请求次数 = %d, 期望 6", got)
+	}
+	if got := strings.Join(queuedSegments(h), ""); got != toolCallAbortMessage {
+		t.Errorf("播报内容 = %q, 期望 %q", got, toolCallAbortMessage)
+	}
+	if h.toolCallDepth != 0 || len(h.toolCallChain) != 0 {
+		t.Errorf("返回后调用深度 = %d, 调用链 = %v", h.toolCallDepth, h.toolCallChain)
+	}
+}
+
+func TestToolCallDepthConfigurable(t *testing.T) {
+	h, llm := newToolLoopTestHandler(t, 2, func(n int) string { return fmt.Sprintf("tool_%d", n) })
+
+	h.genResponseByLLM(context.Background(), nil, 1)
+	if got := llm.calls.Load(); got != 3 {
+		t.Errorf("max_tool_call_depth=2 时LLM请求次数 = %d, 期望 3", got)
+	}
+}
+
+func TestToolCallCycleAbortsImmediately(t *testing.T) {
+	h, llm := newToolLoopTestHandler(t, 0, func(n int) string { return "tool_1" })
+
+	h.genResponseByLLM(context.Background(), nil, 1)
+	// 第二次调用 tool_1 时已在调用链中
+	if got := llm.calls.Load(); got != 2 {
+		t.Errorf("LLM请求次数 = %d, 期望 2", got)
+	}
+	if got := strings.Join(queuedSegments(h), ""); got != toolCallAbortMessage {
+		t.Errorf("播报内容 = %q, 期望 %q", got, toolCallAbortMessage)
+	}
+	if h.toolCallDepth != 0 || len(h.toolCallChain) != 0 {
+		t.Errorf("返回后调用深度 = %d, 调用链 = %v", h.toolCallDepth, h.toolCallChain)
+	}
+}
@@ -0,0 +1,68 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"angrymiao-ai-server/src/configs"
+	"angrymiao-ai-server/src/core/providers"
+	"angrymiao-ai-server/src/core/utils"
+)
+
+// countingTTS 统计合成次数并生成临时音频文件
+type countingTTS struct {
+	providers.TTSProvider
+	dir   string
+	texts []string
+}
+
+func (p *countingTTS) ToTTS(text string) (string, error) {
+	p.texts = append(p.texts, text)
+	path := filepath.Join(p.dir, "tts_output.mp3")
+	return path, os.WriteFile(path, []byte("audio:"+text), 0o644)
+}
+
+func TestShortTextUsesCachedAudio(t *testing.T) {
+	logger, err := utils.NewLogger(&utils.LogCfg{LogLevel: "ERROR", LogDir: t.TempDir(), LogFile: "test.log"})
+	if err != nil {
+		t.Fatalf("创建日志失败: %v", err)
+	}
+	tts := &countingTTS{dir: t.TempDir()}
+	cache := utils.NewQuickReplyCache("doubao", "voice1")
+	cache.CacheDir = filepath.Join(t.TempDir(), "wake_replay")
+	h := &ConnectionHandler{
+		logger:          logger,
+		config:          &configs.Config{ShortTextCacheThresholdChars: 5},
+		quickReplyCache: cache,
+	}
+	h.providers.tts = tts
+
+	if got := h.synthesizeTTS("嗯", 1, 1); got != filepath.Join(tts.dir, "tts_output.mp3") {
+		t.Fatalf("首次合成应调用TTS, 返回 %q", got)
+	}
+	cached := h.synthesizeTTS("嗯😊", 1, 2) // 去除表情后命中缓存
+	if len(tts.texts) != 1 {
+		t.Fatalf("再次合成\"嗯\"不应调用TTS, 调用 %v", tts.texts)
+	}
+	if cached != cache.FindShortTextAudio("嗯") || !cache.IsCachedFile(cached) {
+		t.Errorf("应返回短文本缓存音频, 返回 %q", cached)
+	}
+	if data, _ := os.ReadFile(cached); string(data) != "audio:嗯" {
+		t.Errorf("缓存音频内容 = %q", data)
+	}
+
+	// 超过阈值的文本每次都调用TTS且不缓存
+	h.synthesizeTTS("今天天气很好", 1, 3)
+	h.synthesizeTTS("今天天气很好", 1, 4)
+	if len(tts.texts) != 3 || cache.FindShortTextAudio("今天天气很好") != "" {
+		t.Errorf("长文本合成 = %v", tts.texts)
+	}
+
+	// 阈值为0时不使用短文本缓存
+	h.config.ShortTextCacheThresholdChars = 0
+	h.synthesizeTTS("嗯", 1, 5)
+	if len(tts.texts) != 4 {
+		t.Errorf("关闭短文本缓存后应调用TTS, 调用 %v", tts.texts)
+	}
+}
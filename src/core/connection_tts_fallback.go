@@ -0,0 +1,125 @@
+package core
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"angrymiao-ai-server/src/configs"
+	"angrymiao-ai-server/src/core/pool"
+	"angrymiao-ai-server/src/core/providers"
+	"angrymiao-ai-server/src/core/utils"
+)
+
+// 切换备用TTS前的随机等待区间，避免多个连接同时涌向备用TTS
+const (
+	ttsFallbackMinJitter = 100 * time.Millisecond
+	ttsFallbackMaxJitter = 500 * time.Millisecond
+)
+
+// ttsFallbackJitter 切换备用TTS前的等待时长，测试中可替换
+var ttsFallbackJitter = func() time.Duration {
+	return ttsFallbackMinJitter + time.Duration(rand.Int63n(int64(ttsFallbackMaxJitter-ttsFallbackMinJitter)))
+}
+
+// newFallbackTTS 按TTS配置名称创建备用TTS，测试中可替换
+var newFallbackTTS = func(name string, config *configs.Config, logger *utils.Logger) (providers.TTSProvider, error) {
+	factory := pool.NewTTSFactory(name, config, logger)
+	if factory == nil {
+		return nil, fmt.Errorf("找不到TTS配置: %s", name)
+	}
+	resource, err := factory.Create()
+	if err != nil {
+		return nil, err
+	}
+	provider, ok := resource.(providers.TTSProvider)
+	if !ok {
+		return nil, fmt.Errorf("TTS配置 %s 创建的不是TTS提供者", name)
+	}
+	return provider, nil
+}
+
+// isTTSCapacityError 主TTS是否因容量、配额不足或限流(HTTP 429)失败
+func isTTSCapacityError(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := strings.ToLower(err.Error())
+	for _, keyword := range []string{"capacity", "quota", "429", "too many requests"} {
+		if strings.Contains(msg, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// toTTSWithFallback 使用主TTS合成，容量不足时依次尝试 tts_fallback 中的备用TTS
+// 备用TTS也失败时返回主TTS的错误
+func (h *ConnectionHandler) toTTSWithFallback(text string, round int) (string, error) {
+	filepath, err := h.providers.tts.ToTTS(text)
+	if err == nil || !isTTSCapacityError(err) || len(h.config.TTSFallback) == 0 {
+		return filepath, err
+	}
+
+	h.LogInfo(fmt.Sprintf("主TTS容量不足，尝试备用TTS: %v", err))
+	time.Sleep(ttsFallbackJitter())
+	for _, name := range h.config.TTSFallback {
+		provider, createErr := h.fallbackTTSProvider(name)
+		if createErr != nil {
+			h.LogError(fmt.Sprintf("创建备用TTS %s 失败: %v", name, createErr))
+			continue
+		}
+		fallbackPath, fallbackErr := provider.ToTTS(text)
+		if fallbackErr != nil {
+			h.LogError(fmt.Sprintf("备用TTS %s 合成失败: %v", name, fallbackErr))
+			continue
+		}
+		count := h.addTTSFallbackCount(round)
+		h.LogInfo(fmt.Sprintf("使用备用TTS %s 合成成功, round: %d, 本轮第 %d 个备用分段", name, round, count))
+		return fallbackPath, nil
+	}
+	return "", err
+}
+
+// fallbackTTSProvider 获取备用TTS，首次使用时创建并在连接关闭时清理
+func (h *ConnectionHandler) fallbackTTSProvider(name string) (providers.TTSProvider, error) {
+	h.ttsFallbackMu.Lock()
+	defer h.ttsFallbackMu.Unlock()
+	if provider, ok := h.ttsFallbackProviders[name]; ok {
+		return provider, nil
+	}
+	provider, err := newFallbackTTS(name, h.config, h.logger)
+	if err != nil {
+		return nil, err
+	}
+	if h.ttsFallbackProviders == nil {
+		h.ttsFallbackProviders = make(map[string]providers.TTSProvider)
+	}
+	h.ttsFallbackProviders[name] = provider
+	return provider, nil
+}
+
+// addTTSFallbackCount 累加本轮使用备用TTS的分段数，轮次变化时重新计数
+func (h *ConnectionHandler) addTTSFallbackCount(round int) int {
+	h.ttsFallbackMu.Lock()
+	defer h.ttsFallbackMu.Unlock()
+	if h.ttsFallbackRound != round {
+		h.ttsFallbackRound = round
+		h.ttsRoundFallbackCount = 0
+	}
+	h.ttsRoundFallbackCount++
+	return h.ttsRoundFallbackCount
+}
+
+// closeFallbackTTS 清理已创建的备用TTS
+func (h *ConnectionHandler) closeFallbackTTS() {
+	h.ttsFallbackMu.Lock()
+	defer h.ttsFallbackMu.Unlock()
+	for name, provider := range h.ttsFallbackProviders {
+		if err := provider.Cleanup(); err != nil {
+			h.LogError(fmt.Sprintf("清理备用TTS %s 失败: %v", name, err))
+		}
+	}
+	h.ttsFallbackProviders = nil
+}
@@ -0,0 +1,115 @@
+package core
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"angrymiao-ai-server/src/configs"
+	"angrymiao-ai-server/src/core/providers"
+	"angrymiao-ai-server/src/core/utils"
+)
+
+// scriptedTTS 按设定的错误返回，记录收到的文本
+type scriptedTTS struct {
+	providers.TTSProvider
+	err     error
+	texts   []string
+	cleaned bool
+}
+
+func (p *scriptedTTS) ToTTS(text string) (string, error) {
+	p.texts = append(p.texts, text)
+	if p.err != nil {
+		return "", p.err
+	}
+	return "tmp/fallback_" + text + ".wav", nil
+}
+
+func (p *scriptedTTS) Cleanup() error {
+	p.cleaned = true
+	return nil
+}
+
+func TestIsTTSCapacityError(t *testing.T) {
+	for _, msg := range []string{"server capacity exceeded", "Quota exhausted", "HTTP 429", "429 Too Many Requests"} {
+		if !isTTSCapacityError(errors.New(msg)) {
+			t.Errorf("%q 应识别为容量错误", msg)
+		}
+	}
+	if isTTSCapacityError(errors.New("连接超时")) || isTTSCapacityError(nil) {
+		t.Error("其他错误不应识别为容量错误")
+	}
+}
+
+func TestTTSFallbackOnCapacityError(t *testing.T) {
+	originalJitter, originalFactory := ttsFallbackJitter, newFallbackTTS
+	t.Cleanup(func() { ttsFallbackJitter, newFallbackTTS = originalJitter, originalFactory })
+	ttsFallbackJitter = func() time.Duration { return 0 }
+	secondary := map[string]*scriptedTTS{
+		"BrokenTTS": {err: errors.New("quota exceeded")},
+		"EdgeTTS":   {},
+	}
+	created := 0
+	newFallbackTTS = func(name string, config *configs.Config, logger *utils.Logger) (providers.TTSProvider, error) {
+		created++
+		provider, ok := secondary[name]
+		if !ok {
+			return nil, errors.New("找不到TTS配置")
+		}
+		return provider, nil
+	}
+
+	h := newFailedTTSTestHandler(t, 0)
+	h.config.TTSFallback = []string{"MissingTTS", "BrokenTTS", "EdgeTTS"}
+	primary := &scriptedTTS{err: errors.New("tts request failed: 429 too many requests")}
+	h.providers.tts = primary
+
+	h.processTTSTask("你好", 1, 3, false)
+	h.processTTSTask("再见", 2, 3, false)
+	if task := <-h.audioMessagesQueue; task.filepath != "tmp/fallback_你好.wav" {
+		t.Errorf("备用TTS合成路径 = %q", task.filepath)
+	}
+	<-h.audioMessagesQueue
+	if got := secondary["EdgeTTS"].texts; len(got) != 2 || got[0] != "你好" {
+		t.Errorf("备用TTS收到的文本 = %v", got)
+	}
+	if h.ttsRoundFallbackCount != 2 || len(h.FailedTTS()) != 0 {
+		t.Errorf("本轮备用分段数 = %d, 失败记录 %d", h.ttsRoundFallbackCount, len(h.FailedTTS()))
+	}
+	// 已创建的备用TTS被复用，创建失败的配置每次重试
+	if created != 4 {
+		t.Errorf("创建备用TTS次数 = %d, 期望 4", created)
+	}
+
+	// 新的一轮重新计数
+	h.processTTSTask("新一轮", 1, 4, false)
+	<-h.audioMessagesQueue
+	if h.ttsRoundFallbackCount != 1 {
+		t.Errorf("新一轮备用分段数 = %d, 期望 1", h.ttsRoundFallbackCount)
+	}
+
+	// 备用TTS也失败时走原有的失败流程
+	secondary["EdgeTTS"].err = errors.New("capacity")
+	h.processTTSTask("失败", 2, 4, false)
+	if task := <-h.audioMessagesQueue; task.filepath != "" {
+		t.Errorf("全部失败时入队路径 = %q, 期望为空", task.filepath)
+	}
+	if entries := h.FailedTTS(); len(entries) != 1 || entries[0].Error != primary.err.Error() {
+		t.Errorf("失败记录 = %+v, 期望记录主TTS的错误", entries)
+	}
+
+	// 非容量错误不尝试备用TTS
+	calls := len(secondary["EdgeTTS"].texts)
+	primary.err = errors.New("连接超时")
+	h.processTTSTask("超时", 3, 4, false)
+	<-h.audioMessagesQueue
+	if len(secondary["EdgeTTS"].texts) != calls {
+		t.Error("非容量错误不应使用备用TTS")
+	}
+
+	h.closeFallbackTTS()
+	if !secondary["EdgeTTS"].cleaned || !secondary["BrokenTTS"].cleaned {
+		t.Error("关闭时应清理备用TTS")
+	}
+}
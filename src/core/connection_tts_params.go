@@ -0,0 +1,96 @@
+package core
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"angrymiao-ai-server/src/core/providers"
+)
+
+// reSpeechMarker LLM回复中调整语速与音调的标记，如 [TTS_SPEED:1.5]、[TTS_PITCH:+2]
+var reSpeechMarker = regexp.MustCompile(`\[TTS_(SPEED|PITCH):\s*([+-]?\d+(?:\.\d+)?)\]`)
+
+const speechMarkerPrefix = "[TTS_"
+
+const (
+	defaultTTSSpeed float32 = 1
+	defaultTTSPitch float32 = 0
+)
+
+// speechMarkers 文本中的语速与音调标记，未出现的项为 nil
+type speechMarkers struct {
+	speed *float32
+	pitch *float32
+}
+
+// extractSpeechMarkers 移除文本中的语速与音调标记，同一项出现多次时以最后一个为准
+func extractSpeechMarkers(text string) (string, speechMarkers) {
+	var markers speechMarkers
+	matches := reSpeechMarker.FindAllStringSubmatch(text, -1)
+	if len(matches) == 0 {
+		return text, markers
+	}
+	for _, match := range matches {
+		value, err := strconv.ParseFloat(match[2], 32)
+		if err != nil {
+			continue
+		}
+		v := float32(value)
+		if match[1] == "SPEED" {
+			markers.speed = &v
+		} else {
+			markers.pitch = &v
+		}
+	}
+	return reSpeechMarker.ReplaceAllString(text, ""), markers
+}
+
+// applySpeechMarkers 处理分段中的语速与音调标记：设置TTS参数并返回去除标记后的分段
+func (h *ConnectionHandler) applySpeechMarkers(segment string) string {
+	segment, markers := extractSpeechMarkers(segment)
+	if markers.speed == nil && markers.pitch == nil {
+		return segment
+	}
+
+	h.ttsParamsMu.Lock()
+	defer h.ttsParamsMu.Unlock()
+	speed, pitch := h.currentTTSSpeed, h.currentTTSPitch
+	if markers.speed != nil {
+		speed = *markers.speed
+	}
+	if markers.pitch != nil {
+		pitch = *markers.pitch
+	}
+	if h.setSpeechParamsLocked(speed, pitch) {
+		h.LogInfo(fmt.Sprintf("调整TTS参数: 语速 %.2f, 音调 %+.1f", speed, pitch))
+	}
+	return strings.TrimSpace(segment)
+}
+
+// resetSpeechParams 恢复默认语速与音调
+func (h *ConnectionHandler) resetSpeechParams() {
+	h.ttsParamsMu.Lock()
+	defer h.ttsParamsMu.Unlock()
+	if h.currentTTSSpeed == defaultTTSSpeed && h.currentTTSPitch == defaultTTSPitch {
+		return
+	}
+	h.setSpeechParamsLocked(defaultTTSSpeed, defaultTTSPitch)
+}
+
+// setSpeechParamsLocked 将语速与音调传给TTS提供者，提供者不支持或参数无效时返回 false
+// 调用方需持有 ttsParamsMu
+func (h *ConnectionHandler) setSpeechParamsLocked(speed, pitch float32) bool {
+	setter, ok := h.providers.tts.(providers.SpeechParamsSetter)
+	if !ok {
+		h.LogInfo("当前TTS不支持调整语速与音调，忽略标记")
+		return false
+	}
+	if err := setter.SetSpeechParams(speed, pitch); err != nil {
+		h.LogError(fmt.Sprintf("设置TTS语速与音调失败: %v", err))
+		return false
+	}
+	h.currentTTSSpeed, h.currentTTSPitch = speed, pitch
+	return true
+}
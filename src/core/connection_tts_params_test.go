@@ -0,0 +1,82 @@
+package core
+
+import (
+	"testing"
+
+	"angrymiao-ai-server/src/configs"
+	"angrymiao-ai-server/src/core/providers"
+	"angrymiao-ai-server/src/core/providers/tts"
+)
+
+// speechParamsMockTTS 记录传入的语速与音调
+type speechParamsMockTTS struct {
+	providers.TTSProvider
+	tts.SpeechParams
+	calls int
+}
+
+func (m *speechParamsMockTTS) SetSpeechParams(speed, pitch float32) error {
+	m.calls++
+	return m.SpeechParams.SetSpeechParams(speed, pitch)
+}
+
+func TestExtractSpeechMarkers(t *testing.T) {
+	text, markers := extractSpeechMarkers("[TTS_SPEED:1.5]好的，[TTS_PITCH:+2]我说快一点。[TTS_SPEED: 0.8]")
+	if text != "好的，我说快一点。" {
+		t.Errorf("去除标记后文本 = %q", text)
+	}
+	if markers.speed == nil || *markers.speed != 0.8 || markers.pitch == nil || *markers.pitch != 2 {
+		t.Errorf("解析结果 = %+v, 期望语速取最后一个标记 0.8、音调 2", markers)
+	}
+	if _, markers := extractSpeechMarkers("[TTS_PITCH:-3]"); markers.speed != nil || markers.pitch == nil || *markers.pitch != -3 {
+		t.Errorf("负音调解析结果 = %+v", markers)
+	}
+	if text, markers := extractSpeechMarkers("[TTS_SPEED:fast]你好"); text != "[TTS_SPEED:fast]你好" || markers.speed != nil {
+		t.Errorf("无效标记应原样保留: %q, %+v", text, markers)
+	}
+
+	// 分割点不落在标记内部
+	if segment, n := splitLLMSegment("好的。[TTS_SPEED:1.5]我"); segment != "好的。" || n != len(segment) {
+		t.Errorf("splitLLMSegment = %q, %d", segment, n)
+	}
+	if segment, _ := splitLLMSegment("好的。[TTS_SP"); segment != "好的。" {
+		t.Errorf("未接收完整的标记不应被拆开: %q", segment)
+	}
+}
+
+func TestSpeechMarkersPassedToTTS(t *testing.T) {
+	h := newPrefixTestHandler(t, &configs.Config{})
+	h.currentTTSSpeed = defaultTTSSpeed
+	mockTTS := &speechParamsMockTTS{}
+	h.providers.tts = mockTTS
+
+	if segment := h.applySpeechMarkers("[TTS_SPEED:1.5] 我们快一点"); segment != "我们快一点" {
+		t.Errorf("去除标记后分段 = %q", segment)
+	}
+	h.applySpeechMarkers("[TTS_PITCH:+2]音调高一点")
+	if speed, pitch := mockTTS.SpeechParams.SpeechParams(); speed != 1.5 || pitch != 2 {
+		t.Errorf("TTS参数 = %v, %v, 期望 1.5, 2", speed, pitch)
+	}
+
+	// 超出范围的参数不生效
+	h.applySpeechMarkers("[TTS_SPEED:5]")
+	if h.currentTTSSpeed != 1.5 {
+		t.Errorf("超出范围的语速不应生效, 实际 %v", h.currentTTSSpeed)
+	}
+
+	h.resetSpeechParams()
+	if speed, pitch := mockTTS.SpeechParams.SpeechParams(); speed != 1 || pitch != 0 || h.currentTTSSpeed != 1 || h.currentTTSPitch != 0 {
+		t.Errorf("重置后TTS参数 = %v, %v", speed, pitch)
+	}
+	calls := mockTTS.calls
+	h.resetSpeechParams()
+	if mockTTS.calls != calls {
+		t.Error("已是默认参数时不应重复设置")
+	}
+
+	// 不支持调整的TTS忽略标记
+	h.providers.tts = &languageMockTTS{config: &tts.Config{}}
+	if segment := h.applySpeechMarkers("[TTS_SPEED:1.2]你好"); segment != "你好" || h.currentTTSSpeed != 1 {
+		t.Errorf("不支持时分段 = %q, 语速 %v", segment, h.currentTTSSpeed)
+	}
+}
@@ -0,0 +1,159 @@
+package core
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"angrymiao-ai-server/src/core/providers"
+	"angrymiao-ai-server/src/core/types"
+	"angrymiao-ai-server/src/core/utils"
+)
+
+// canStreamTTS 客户端声明支持流式音频、下发Opus且TTS提供者支持流式合成
+// 满足时TTS任务不经过预取缓冲区，边合成边发送
+func (h *ConnectionHandler) canStreamTTS() bool {
+	if !h.clientStreamingAudio || h.serverAudioFormat != "opus" || h.providers.tts == nil {
+		return false
+	}
+	if _, ok := h.providers.tts.(providers.StreamingTTSProvider); !ok {
+		return false
+	}
+	return h.providers.tts.Capabilities()[types.CapabilityStreaming]
+}
+
+// openTTSStream 满足 canStreamTTS 时开始流式合成，返回Opus帧通道
+// 不满足条件或流式合成失败时返回nil，由调用方回退到生成音频文件
+// 命中快速回复或短文本缓存的分段仍使用文件，以便复用缓存音频
+func (h *ConnectionHandler) openTTSStream(text string, textIndex int, round int) <-chan []byte {
+	if !h.canStreamTTS() {
+		return nil
+	}
+	streamer := h.providers.tts.(providers.StreamingTTSProvider)
+	text = utils.RemoveAllEmoji(text)
+	if text == "" || utils.IsQuickReplyHit(text, h.config.QuickReplyWords) || h.isShortTextCacheable(text) {
+		return nil
+	}
+	if atomic.LoadInt32(&h.serverVoiceStop) == 1 || round != h.GetTalkRound() {
+		return nil
+	}
+
+	h.audioLatency.RecordTTSStart(time.Now().UnixNano())
+	stream, err := streamer.ToTTSStream(text)
+	if err != nil {
+		h.LogError(fmt.Sprintf("流式TTS合成失败，回退到音频文件: text(%s) %v", text, err))
+		return nil
+	}
+	h.logger.Debug("开始流式TTS合成: text(%s), index(%d)", text, textIndex)
+	return stream
+}
+
+// streamAudioMessage 按播放速度转发流式TTS输出的Opus帧，直到通道关闭
+// 被打断、轮次变化或连接关闭时停止发送，剩余的帧在后台丢弃
+func (h *ConnectionHandler) streamAudioMessage(stream <-chan []byte, text string, textIndex int, round int) {
+	bFinishSuccess := false
+	frames := 0
+	defer func() {
+		go drainAudioStream(stream)
+		lastTextIndex := int(atomic.LoadInt32(&h.tts_last_text_index))
+		h.LogInfo(fmt.Sprintf("TTS流式音频发送任务结束(%t): %s, 索引: %d/%d, 帧数: %d", bFinishSuccess, text, textIndex, lastTextIndex, frames))
+		h.finishAudioSegment(textIndex, lastTextIndex, round)
+	}()
+
+	if round != h.GetTalkRound() {
+		h.LogInfo(fmt.Sprintf("streamAudioMessage: 跳过过期轮次的音频: 任务轮次=%d, 当前轮次=%d, 文本=%s",
+			round, h.GetTalkRound(), text))
+		return
+	}
+	if atomic.LoadInt32(&h.serverVoiceStop) == 1 {
+		h.LogInfo(fmt.Sprintf("streamAudioMessage 服务端语音停止, 不再发送音频数据：%s", text))
+		return
+	}
+
+	if err := h.sendTTSMessage("sentence_start", text, textIndex); err != nil {
+		h.LogError(fmt.Sprintf("发送TTS开始状态失败: %v", err))
+		return
+	}
+
+	// 与 sendAudioFrames 相同，先发送预缓冲帧，之后按播放位置控制发送速度
+	const preBufferFrames = 3
+	preBufferTime := time.Duration(h.serverAudioFrameDuration*preBufferFrames) * time.Millisecond
+	var startTime time.Time
+	playPosition := 0
+	for {
+		var frame []byte
+		var ok bool
+		select {
+		case frame, ok = <-stream:
+		case <-h.stopChan:
+			return
+		}
+		if !ok {
+			break
+		}
+		if atomic.LoadInt32(&h.serverVoiceStop) == 1 || round != h.GetTalkRound() {
+			h.LogInfo(fmt.Sprintf("流式音频发送被中断: 帧=%d, 文本=%s", frames+1, text))
+			return
+		}
+		if frames == 0 {
+			startTime = time.Now()
+		} else if frames >= preBufferFrames {
+			expectedTime := startTime.Add(time.Duration(playPosition)*time.Millisecond - preBufferTime)
+			if !h.waitUntil(expectedTime, round) {
+				h.LogInfo(fmt.Sprintf("流式音频发送在延迟中被中断: 帧=%d, 文本=%s", frames+1, text))
+				return
+			}
+		}
+
+		if err := h.conn.WriteMessage(2, frame); err != nil {
+			h.LogError(fmt.Sprintf("发送流式音频帧失败: %v", err))
+			return
+		}
+		h.publishMonitorAudio(frame)
+		if frames == 0 {
+			h.audioQuality.RecordTTSAudioSent()
+			h.audioLatency.RecordAudioSent(time.Now().UnixNano())
+		}
+		frames++
+		playPosition += h.serverAudioFrameDuration
+	}
+
+	if err := h.sendTTSMessage("sentence_end", text, textIndex); err != nil {
+		h.LogError(fmt.Sprintf("发送TTS结束状态失败: %v", err))
+		return
+	}
+	bFinishSuccess = true
+}
+
+// waitUntil 等待到指定时间，期间被打断、轮次变化或连接关闭时返回false
+func (h *ConnectionHandler) waitUntil(deadline time.Time, round int) bool {
+	delay := time.Until(deadline)
+	if delay <= 0 {
+		return true
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-timer.C:
+			return true
+		case <-ticker.C:
+			if atomic.LoadInt32(&h.serverVoiceStop) == 1 || round != h.GetTalkRound() {
+				return false
+			}
+		case <-h.stopChan:
+			return false
+		}
+	}
+}
+
+// drainAudioStream 丢弃流式TTS通道中剩余的帧，使提供者的合成协程能够退出
+func drainAudioStream(stream <-chan []byte) {
+	if stream == nil {
+		return
+	}
+	for range stream {
+	}
+}
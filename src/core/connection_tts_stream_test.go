@@ -0,0 +1,167 @@
+package core
+
+import (
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"angrymiao-ai-server/src/configs"
+	"angrymiao-ai-server/src/core/providers/tts"
+	"angrymiao-ai-server/src/core/types"
+)
+
+// streamingTTS 流式输出固定的Opus帧，ToTTS 记录回退到文件合成的次数
+type streamingTTS struct {
+	voiceRecordingTTS
+	frames [][]byte
+}
+
+func (m *streamingTTS) Capabilities() map[string]bool {
+	return map[string]bool{types.CapabilityStreaming: true}
+}
+
+func (m *streamingTTS) ToTTSStream(text string) (<-chan []byte, error) {
+	out := make(chan []byte, len(m.frames))
+	for _, frame := range m.frames {
+		out <- frame
+	}
+	close(out)
+	return out, nil
+}
+
+func newStreamTestHandler(t *testing.T) (*ConnectionHandler, *streamingTTS) {
+	t.Helper()
+	h := newPrefixTestHandler(t, &configs.Config{})
+	h.stopChan = make(chan struct{})
+	t.Cleanup(func() { close(h.stopChan) })
+	h.audioMessagesQueue = make(chan struct {
+		filepath     string
+		text         string
+		round        int
+		textIndex    int
+		paragraphEnd bool
+		stream       <-chan []byte
+	}, 10)
+	h.providers.asr = &listeningASR{}
+	h.serverAudioFormat = "opus"
+	h.serverAudioFrameDuration = 1
+	h.clientStreamingAudio = true
+	mockTTS := &streamingTTS{
+		voiceRecordingTTS: voiceRecordingTTS{config: &tts.Config{}},
+		frames:            [][]byte{{0xf1}, {0xf2}, {0xf3}, {0xf4}, {0xf5}},
+	}
+	h.providers.tts = mockTTS
+	return h, mockTTS
+}
+
+func TestStreamAudioMessageForwardsFrames(t *testing.T) {
+	h, mockTTS := newStreamTestHandler(t)
+	h.tts_last_text_index = 1
+	h.processTTSTask("今天天气很好", 1, 0, false)
+
+	task := <-h.audioMessagesQueue
+	if task.stream == nil || task.filepath != "" {
+		t.Fatalf("流式合成的任务 = %+v", task)
+	}
+	if len(mockTTS.voices) != 0 {
+		t.Errorf("流式合成不应生成音频文件")
+	}
+	h.streamAudioMessage(task.stream, task.text, task.textIndex, task.round)
+
+	conn := h.conn.(*recordingConn)
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	if len(conn.messages) != 8 {
+		t.Fatalf("发送的消息数 = %d, 期望 8", len(conn.messages))
+	}
+	if !strings.Contains(string(conn.messages[0]), `"sentence_start"`) {
+		t.Errorf("首条消息 = %s", conn.messages[0])
+	}
+	for i, frame := range mockTTS.frames {
+		if got := conn.messages[i+1]; len(got) != 1 || got[0] != frame[0] {
+			t.Errorf("第%d帧 = %v, 期望 %v", i+1, got, frame)
+		}
+	}
+	if !strings.Contains(string(conn.messages[6]), `"sentence_end"`) || !strings.Contains(string(conn.messages[7]), `"stop"`) {
+		t.Errorf("结束消息 = %s, %s", conn.messages[6], conn.messages[7])
+	}
+}
+
+func TestStreamAudioMessageStopsOnServerVoiceStop(t *testing.T) {
+	h, _ := newStreamTestHandler(t)
+	h.tts_last_text_index = 2
+	h.processTTSTask("今天天气很好", 1, 0, false)
+	task := <-h.audioMessagesQueue
+	atomic.StoreInt32(&h.serverVoiceStop, 1)
+	h.streamAudioMessage(task.stream, task.text, task.textIndex, task.round)
+
+	conn := h.conn.(*recordingConn)
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	if len(conn.messages) != 0 {
+		t.Errorf("服务端语音停止后不应发送音频, 发送了 %d 条消息", len(conn.messages))
+	}
+}
+
+func TestProcessTTSTaskFallsBackToFile(t *testing.T) {
+	h, mockTTS := newStreamTestHandler(t)
+	h.clientStreamingAudio = false
+	h.processTTSTask("今天天气很好", 1, 0, false)
+
+	if task := <-h.audioMessagesQueue; task.stream != nil {
+		t.Error("客户端未声明 streaming 时应使用音频文件")
+	}
+	if len(mockTTS.voices) != 1 {
+		t.Errorf("回退到文件合成的次数 = %d, 期望 1", len(mockTTS.voices))
+	}
+}
+
+func TestTTSQueueStreamsWithPrefetchEnabled(t *testing.T) {
+	h, mockTTS := newStreamTestHandler(t)
+	h.ttsPrefetch = NewPreFetchBuffer(2, h.synthesizeTTS)
+	go h.processTTSQueueCoroutine()
+
+	h.ttsQueue <- struct {
+		text         string
+		round        int
+		textIndex    int
+		paragraphEnd bool
+	}{"今天天气很好", 0, 1, false}
+
+	select {
+	case task := <-h.audioMessagesQueue:
+		if task.stream == nil {
+			t.Error("支持流式合成时不应经过预取生成音频文件")
+		}
+		drainAudioStream(task.stream)
+	case <-time.After(time.Second):
+		t.Fatal("等待TTS任务超时")
+	}
+	if len(mockTTS.voices) != 0 {
+		t.Errorf("流式合成不应调用 ToTTS, 调用了 %d 次", len(mockTTS.voices))
+	}
+}
+
+func TestTTSQueuePrefetchesWithoutStreaming(t *testing.T) {
+	h, _ := newStreamTestHandler(t)
+	h.clientStreamingAudio = false
+	h.ttsPrefetch = NewPreFetchBuffer(2, h.synthesizeTTS)
+	go h.processTTSQueueCoroutine()
+
+	h.ttsQueue <- struct {
+		text         string
+		round        int
+		textIndex    int
+		paragraphEnd bool
+	}{"今天天气很好", 0, 1, false}
+
+	select {
+	case task := <-h.audioMessagesQueue:
+		if task.stream != nil {
+			t.Error("客户端未声明 streaming 时应经过预取生成音频文件")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("等待TTS任务超时")
+	}
+}
@@ -0,0 +1,169 @@
+package core
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"angrymiao-ai-server/src/core/botconfig"
+	"angrymiao-ai-server/src/core/function"
+	"angrymiao-ai-server/src/core/types"
+	"angrymiao-ai-server/src/core/utils"
+	"angrymiao-ai-server/src/models"
+
+	"github.com/angrymiao/go-openai"
+)
+
+// mockNotifier 模拟Redis发布订阅
+type mockNotifier struct {
+	mu   sync.Mutex
+	subs map[string][]chan struct{}
+}
+
+func (n *mockNotifier) NotifyUpdate(ctx context.Context, userID string) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for _, ch := range n.subs[botconfig.UserConfigUpdateChannel(userID)] {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+func (n *mockNotifier) SubscribeUpdates(ctx context.Context, userID string) (<-chan struct{}, func(), error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	ch := make(chan struct{}, 1)
+	channel := botconfig.UserConfigUpdateChannel(userID)
+	n.subs[channel] = append(n.subs[channel], ch)
+	return ch, func() {}, nil
+}
+
+// mockBotConfigService 返回可修改的Bot配置列表
+type mockBotConfigService struct {
+	mu        sync.Mutex
+	configs   []*types.BotConfig
+	personaID uint                // 设备绑定的主人格Bot配置ID
+	group     *models.DeviceGroup // 设备所属的设备组
+}
+
+func (s *mockBotConfigService) set(configs ...*types.BotConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.configs = configs
+}
+
+func (s *mockBotConfigService) GetUserConfigs(ctx context.Context, userID string) ([]*types.BotConfig, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.configs, nil
+}
+
+func (s *mockBotConfigService) GetActiveConfigs(ctx context.Context, userID string) ([]*types.BotConfig, error) {
+	return s.GetUserConfigs(ctx, userID)
+}
+
+func (s *mockBotConfigService) GetBotFriendConfig(ctx context.Context, userID uint, botConfigID uint) (*types.BotConfig, error) {
+	return nil, nil
+}
+
+func (s *mockBotConfigService) GetDevicePersonaID(ctx context.Context, userID string, deviceID string) (uint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.personaID, nil
+}
+
+func (s *mockBotConfigService) GetDeviceGroup(ctx context.Context, userID string, deviceID string) (*models.DeviceGroup, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.group, nil
+}
+
+func waitForFunctions(t *testing.T, registry *function.FunctionRegistry, expected []string) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if reflect.DeepEqual(registry.RegisteredFunctions(), expected) {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("已注册函数 = %v, 期望 %v", registry.RegisteredFunctions(), expected)
+}
+
+func TestUserConfigHotUpdate(t *testing.T) {
+	logger, err := utils.NewLogger(&utils.LogCfg{LogLevel: "ERROR", LogDir: t.TempDir(), LogFile: "test.log"})
+	if err != nil {
+		t.Fatalf("创建日志失败: %v", err)
+	}
+
+	notifier := &mockNotifier{subs: make(map[string][]chan struct{})}
+	botconfig.SetUpdateNotifier(notifier)
+	defer botconfig.SetUpdateNotifier(nil)
+
+	service := &mockBotConfigService{}
+	service.set(&types.BotConfig{FunctionName: "bot_weather", Description: "天气"})
+
+	h := &ConnectionHandler{
+		logger:            logger,
+		stopChan:          make(chan struct{}),
+		functionRegister:  function.NewFunctionRegistry(),
+		userConfigService: service,
+		userID:            "42",
+	}
+	defer close(h.stopChan)
+
+	h.loadUserAIConfigurations()
+	h.subscribeUserConfigUpdates()
+	waitForFunctions(t, h.functionRegister, []string{"bot_weather"})
+
+	// 添加好友
+	service.set(
+		&types.BotConfig{FunctionName: "bot_weather", Description: "天气"},
+		&types.BotConfig{FunctionName: "bot_music", Description: "音乐"},
+	)
+	notifier.NotifyUpdate(context.Background(), "42")
+	waitForFunctions(t, h.functionRegister, []string{"bot_music", "bot_weather"})
+
+	// 删除好友
+	service.set(&types.BotConfig{FunctionName: "bot_music", Description: "音乐"})
+	notifier.NotifyUpdate(context.Background(), "42")
+	waitForFunctions(t, h.functionRegister, []string{"bot_music"})
+
+	if h.findUserConfig("bot_weather") != nil {
+		t.Errorf("已删除的Bot配置仍在缓存中")
+	}
+}
+
+func TestUserConfigReloadKeepsForeignFunctions(t *testing.T) {
+	logger, err := utils.NewLogger(&utils.LogCfg{LogLevel: "ERROR", LogDir: t.TempDir(), LogFile: "test.log"})
+	if err != nil {
+		t.Fatalf("创建日志失败: %v", err)
+	}
+
+	service := &mockBotConfigService{}
+	service.set(
+		&types.BotConfig{FunctionName: "bot_weather", Description: "天气"},
+		&types.BotConfig{FunctionName: "play_music", Description: "与MCP工具重名"},
+	)
+	h := &ConnectionHandler{
+		logger:            logger,
+		functionRegister:  function.NewFunctionRegistry(),
+		userConfigService: service,
+		userID:            "42",
+	}
+	// 模拟已由MCP注册的同名工具
+	h.functionRegister.RegisterFunction("play_music", openai.Tool{Type: openai.ToolTypeFunction, Function: &openai.FunctionDefinition{Name: "play_music"}})
+
+	h.loadUserAIConfigurations()
+	waitForFunctions(t, h.functionRegister, []string{"bot_weather", "play_music"})
+
+	// 重新加载不应注销本连接未注册成功的同名MCP工具
+	service.set()
+	h.loadUserAIConfigurations()
+	waitForFunctions(t, h.functionRegister, []string{"play_music"})
+}
@@ -0,0 +1,89 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+)
+
+// vadLevelInterval 下发音量电平的间隔，期间只发送最近一帧的电平
+const vadLevelInterval = 200 * time.Millisecond
+
+// pcmDBFS 计算16位小端PCM帧的电平(dBFS)，RMS按满幅归一化，满幅正弦波约为 -3dBFS，静音趋近 -180dBFS
+func pcmDBFS(pcm []byte) float64 {
+	rms := CalculateRMS(pcm) / 32768
+	return 20 * math.Log10(rms+1e-9)
+}
+
+// startVADLevelMeter 客户端hello中开启 streaming_vad_level 时，定时下发最近一帧的音量电平
+// 重复收到hello时替换已有的定时器
+func (h *ConnectionHandler) startVADLevelMeter() {
+	h.stopVADLevelMeter()
+	stop := make(chan struct{})
+	h.vadLevelMu.Lock()
+	h.vadLevelStop = stop
+	h.vadLevelFresh = false
+	h.vadLevelMu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(vadLevelInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-h.stopChan:
+				return
+			case <-stop:
+				return
+			case <-ticker.C:
+				h.emitVADLevel()
+			}
+		}
+	}()
+}
+
+func (h *ConnectionHandler) stopVADLevelMeter() {
+	h.vadLevelMu.Lock()
+	defer h.vadLevelMu.Unlock()
+	if h.vadLevelStop != nil {
+		close(h.vadLevelStop)
+		h.vadLevelStop = nil
+	}
+}
+
+// recordVADLevel 记录VAD检测帧的电平与语音状态，未开启电平下发时跳过
+func (h *ConnectionHandler) recordVADLevel(pcm []byte, voice bool) {
+	h.vadLevelMu.Lock()
+	defer h.vadLevelMu.Unlock()
+	if h.vadLevelStop == nil {
+		return
+	}
+	h.vadLevelDB = pcmDBFS(pcm)
+	h.vadLevelVoice = voice
+	h.vadLevelFresh = true
+}
+
+// emitVADLevel 下发上次发送后最新一帧的电平，期间没有新的音频时不发送
+func (h *ConnectionHandler) emitVADLevel() {
+	h.vadLevelMu.Lock()
+	if !h.vadLevelFresh {
+		h.vadLevelMu.Unlock()
+		return
+	}
+	level, voice := h.vadLevelDB, h.vadLevelVoice
+	h.vadLevelFresh = false
+	h.vadLevelMu.Unlock()
+
+	jsonData, err := json.Marshal(map[string]interface{}{
+		"type":  "vad_level",
+		"db_fs": math.Round(level*10) / 10,
+		"voice": voice,
+	})
+	if err != nil {
+		h.LogError(fmt.Sprintf("序列化音量电平消息失败: %v", err))
+		return
+	}
+	if err := h.conn.WriteMessage(1, jsonData); err != nil {
+		h.LogError(fmt.Sprintf("发送音量电平消息失败: %v", err))
+	}
+}
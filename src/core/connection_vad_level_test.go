@@ -0,0 +1,72 @@
+package core
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"angrymiao-ai-server/src/configs"
+)
+
+func TestPCMDBFS(t *testing.T) {
+	// 满幅正弦波 RMS 为 1/√2，约 -3.01dBFS
+	if got := pcmDBFS(sinePCM(32767, 1000, 16000, 320)); math.Abs(got-(-3.01)) > 0.05 {
+		t.Errorf("满幅正弦波 dBFS = %.2f, 期望 -3.01", got)
+	}
+	if got := pcmDBFS(sinePCM(32767/10.0, 1000, 16000, 320)); math.Abs(got-(-23.01)) > 0.05 {
+		t.Errorf("-20dB 正弦波 dBFS = %.2f, 期望 -23.01", got)
+	}
+	if got := pcmDBFS(make([]byte, 640)); got > -170 {
+		t.Errorf("静音 dBFS = %.2f", got)
+	}
+}
+
+func vadLevelMessages(conn *recordingConn) []map[string]interface{} {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	var levels []map[string]interface{}
+	for _, message := range conn.messages {
+		if !strings.Contains(string(message), `"vad_level"`) {
+			continue
+		}
+		var level map[string]interface{}
+		json.Unmarshal(message, &level)
+		levels = append(levels, level)
+	}
+	return levels
+}
+
+func TestVADLevelEmittedPeriodically(t *testing.T) {
+	h := newPrefixTestHandler(t, &configs.Config{})
+	conn := h.conn.(*recordingConn)
+
+	// 未开启时不记录电平
+	h.recordVADLevel(sinePCM(32767, 1000, 16000, 320), true)
+	h.startVADLevelMeter()
+	defer h.stopVADLevelMeter()
+
+	// 450ms 内送入45帧，只按200ms周期下发
+	frame := sinePCM(32767, 1000, 16000, 160)
+	for i := 0; i < 45; i++ {
+		h.recordVADLevel(frame, true)
+		time.Sleep(10 * time.Millisecond)
+	}
+	h.stopVADLevelMeter()
+
+	levels := vadLevelMessages(conn)
+	if len(levels) < 1 || len(levels) > 3 {
+		t.Fatalf("下发了 %d 条音量电平消息，期望按周期下发2条左右", len(levels))
+	}
+	if levels[0]["db_fs"] != -3.0 || levels[0]["voice"] != true {
+		t.Errorf("音量电平消息 = %v", levels[0])
+	}
+
+	// 没有新的音频帧时不再下发
+	h.startVADLevelMeter()
+	time.Sleep(3 * vadLevelInterval)
+	if got := len(vadLevelMessages(conn)); got != len(levels) {
+		t.Errorf("没有音频帧时下发了 %d 条新消息", got-len(levels))
+	}
+}
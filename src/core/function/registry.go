@@ -1,13 +1,20 @@
 package function
 
 import (
+	"errors"
 	"fmt"
+	"sort"
 	"strings"
+	"sync"
 
 	"github.com/angrymiao/go-openai"
 )
 
+// ErrNotFound 函数未注册
+var ErrNotFound = errors.New("function not found")
+
 type FunctionRegistry struct {
+	mu        sync.RWMutex
 	functions map[string]openai.Tool
 }
 
@@ -18,6 +25,8 @@ func NewFunctionRegistry() *FunctionRegistry {
 }
 
 func (fr *FunctionRegistry) RegisterFunction(name string, function openai.Tool) error {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
 	if _, exists := fr.functions[name]; exists {
 		return fmt.Errorf("function already registered: %s", name)
 	}
@@ -26,13 +35,17 @@ func (fr *FunctionRegistry) RegisterFunction(name string, function openai.Tool)
 }
 
 func (fr *FunctionRegistry) GetFunction(name string) (openai.Tool, error) {
+	fr.mu.RLock()
+	defer fr.mu.RUnlock()
 	if function, exists := fr.functions[name]; exists {
 		return function, nil
 	}
-	return openai.Tool{}, fmt.Errorf("function not found: %s", name)
+	return openai.Tool{}, fmt.Errorf("%w: %s", ErrNotFound, name)
 }
 
 func (fr *FunctionRegistry) GetAllFunctions() []openai.Tool {
+	fr.mu.RLock()
+	defer fr.mu.RUnlock()
 	functions := make([]openai.Tool, 0, len(fr.functions))
 	for _, function := range fr.functions {
 		functions = append(functions, function)
@@ -44,6 +57,8 @@ func (fr *FunctionRegistry) GetFunctionByFilter(filter []string) []openai.Tool {
 	if len(filter) == 0 {
 		return fr.GetAllFunctions()
 	}
+	fr.mu.RLock()
+	defer fr.mu.RUnlock()
 	functions := make([]openai.Tool, 0)
 	for name, function := range fr.functions {
 		// 返回self和local开头的函数
@@ -62,6 +77,8 @@ func (fr *FunctionRegistry) GetFunctionByFilter(filter []string) []openai.Tool {
 }
 
 func (fr *FunctionRegistry) UnregisterAllFunctions() error {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
 	// Unregister all functions
 	for name := range fr.functions {
 		delete(fr.functions, name)
@@ -69,17 +86,48 @@ func (fr *FunctionRegistry) UnregisterAllFunctions() error {
 	return nil
 }
 
+// UnregisterFunction 注销指定函数，未注册时返回 ErrNotFound
 func (fr *FunctionRegistry) UnregisterFunction(name string) error {
-	// Unregister a specific function
-	if _, exists := fr.functions[name]; exists {
-		delete(fr.functions, name)
-	} else {
-		return fmt.Errorf("function not found: %s", name)
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	if _, exists := fr.functions[name]; !exists {
+		return fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	delete(fr.functions, name)
+	return nil
+}
+
+// ReplaceFunction 替换已注册的函数定义，未注册时返回 ErrNotFound
+func (fr *FunctionRegistry) ReplaceFunction(name string, function openai.Tool) error {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	if _, exists := fr.functions[name]; !exists {
+		return fmt.Errorf("%w: %s", ErrNotFound, name)
 	}
+	fr.functions[name] = function
 	return nil
 }
 
 func (fr *FunctionRegistry) FunctionExists(name string) bool {
+	fr.mu.RLock()
+	defer fr.mu.RUnlock()
 	_, exists := fr.functions[name]
 	return exists
 }
+
+// RegisteredFunctions 返回已注册的函数名称（按名称排序），同 ListFunctions
+func (fr *FunctionRegistry) RegisteredFunctions() []string {
+	return fr.ListFunctions()
+}
+
+// ListFunctions 返回已注册的函数名称（按名称排序）
+func (fr *FunctionRegistry) ListFunctions() []string {
+	fr.mu.RLock()
+	defer fr.mu.RUnlock()
+	names := make([]string, 0, len(fr.functions))
+	for name := range fr.functions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
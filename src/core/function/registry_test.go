@@ -0,0 +1,67 @@
+package function
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/angrymiao/go-openai"
+)
+
+func newTool(name, description string) openai.Tool {
+	return openai.Tool{
+		Type:     openai.ToolTypeFunction,
+		Function: &openai.FunctionDefinition{Name: name, Description: description},
+	}
+}
+
+func TestFunctionRegistryReplaceAndUnregister(t *testing.T) {
+	fr := NewFunctionRegistry()
+
+	if err := fr.UnregisterFunction("weather"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("注销未注册函数应返回 ErrNotFound, got %v", err)
+	}
+	if err := fr.ReplaceFunction("weather", newTool("weather", "v2")); !errors.Is(err, ErrNotFound) {
+		t.Errorf("替换未注册函数应返回 ErrNotFound, got %v", err)
+	}
+
+	fr.RegisterFunction("weather", newTool("weather", "v1"))
+	fr.RegisterFunction("music", newTool("music", "v1"))
+	if err := fr.ReplaceFunction("weather", newTool("weather", "v2")); err != nil {
+		t.Fatalf("替换函数失败: %v", err)
+	}
+	if tool, _ := fr.GetFunction("weather"); tool.Function.Description != "v2" {
+		t.Errorf("替换后的函数定义 = %q", tool.Function.Description)
+	}
+	if got := fr.ListFunctions(); len(got) != 2 || got[0] != "music" || got[1] != "weather" {
+		t.Errorf("ListFunctions = %v", got)
+	}
+
+	if err := fr.UnregisterFunction("music"); err != nil {
+		t.Fatalf("注销函数失败: %v", err)
+	}
+	if fr.FunctionExists("music") {
+		t.Error("注销后函数仍存在")
+	}
+}
+
+func TestFunctionRegistryConcurrentAccess(t *testing.T) {
+	fr := NewFunctionRegistry()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			name := fmt.Sprintf("tool_%d", i%4)
+			for j := 0; j < 200; j++ {
+				fr.RegisterFunction(name, newTool(name, "v1"))
+				fr.ReplaceFunction(name, newTool(name, "v2"))
+				fr.ListFunctions()
+				fr.GetAllFunctions()
+				fr.UnregisterFunction(name)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,78 @@
+package governor
+
+import (
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Governor 限制所有传输层（WebSocket、MQTT）的连接总数
+type Governor struct {
+	maxConnections int // 小于等于0时不限制
+	current        atomic.Int64
+}
+
+// New 创建连接总数限制器，maxConnections 小于等于0时只计数不限制
+func New(maxConnections int) *Governor {
+	return &Governor{maxConnections: maxConnections}
+}
+
+// Acquire 占用一个连接名额，已达上限时返回 false；成功后连接结束时需调用 Release
+func (g *Governor) Acquire() bool {
+	for {
+		current := g.current.Load()
+		if g.maxConnections > 0 && current >= int64(g.maxConnections) {
+			return false
+		}
+		if g.current.CompareAndSwap(current, current+1) {
+			return true
+		}
+	}
+}
+
+// Release 释放一个连接名额
+func (g *Governor) Release() {
+	for {
+		current := g.current.Load()
+		if current <= 0 {
+			return
+		}
+		if g.current.CompareAndSwap(current, current-1) {
+			return
+		}
+	}
+}
+
+// CurrentCount 当前连接数
+func (g *Governor) CurrentCount() int64 {
+	return g.current.Load()
+}
+
+// MaxConnections 连接数上限，小于等于0表示不限制
+func (g *Governor) MaxConnections() int {
+	return g.maxConnections
+}
+
+var defaultGovernor atomic.Pointer[Governor]
+
+func init() {
+	defaultGovernor.Store(New(0))
+}
+
+// Default 获取各传输层共用的连接限制器
+func Default() *Governor { return defaultGovernor.Load() }
+
+// SetDefault 设置各传输层共用的连接限制器，需在传输层启动前调用
+func SetDefault(g *Governor) { defaultGovernor.Store(g) }
+
+var activeConnections = prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+	Name: "server_active_connections_total",
+	Help: "所有传输层的活跃连接总数",
+}, func() float64 {
+	return float64(Default().CurrentCount())
+})
+
+// RegisterMetrics 将连接数指标注册到 Prometheus
+func RegisterMetrics(registerer prometheus.Registerer) error {
+	return registerer.Register(activeConnections)
+}
@@ -0,0 +1,56 @@
+package governor
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestGovernorRejectsOverLimit(t *testing.T) {
+	g := New(500)
+	var wg sync.WaitGroup
+	for i := 0; i < 500; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if !g.Acquire() {
+				t.Error("未达上限时应允许连接")
+			}
+		}()
+	}
+	wg.Wait()
+	if g.Acquire() {
+		t.Fatal("第501个连接应被拒绝")
+	}
+	if got := g.CurrentCount(); got != 500 {
+		t.Errorf("当前连接数 = %d, 期望 500", got)
+	}
+
+	// 连接关闭后计数减少，可以重新接入
+	g.Release()
+	if got := g.CurrentCount(); got != 499 {
+		t.Errorf("关闭后连接数 = %d, 期望 499", got)
+	}
+	if !g.Acquire() {
+		t.Error("有空闲名额时应允许连接")
+	}
+}
+
+func TestGovernorUnlimited(t *testing.T) {
+	g := New(0)
+	for i := 0; i < 1000; i++ {
+		if !g.Acquire() {
+			t.Fatalf("不限制时第%d个连接被拒绝", i+1)
+		}
+	}
+	g.Release()
+	if got := g.CurrentCount(); got != 999 {
+		t.Errorf("当前连接数 = %d, 期望 999", got)
+	}
+
+	// 多余的 Release 不会使计数变为负数
+	empty := New(1)
+	empty.Release()
+	if got := empty.CurrentCount(); got != 0 {
+		t.Errorf("空限制器释放后连接数 = %d", got)
+	}
+}
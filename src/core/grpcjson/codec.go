@@ -0,0 +1,22 @@
+// Package grpcjson 提供gRPC消息的JSON编解码，供不依赖proto生成代码的gRPC服务与客户端共用
+package grpcjson
+
+import (
+	"encoding/json"
+
+	"google.golang.org/grpc/encoding"
+)
+
+// Name 编解码名称，即 content-subtype，对应 content-type 为 application/grpc+json
+const Name = "json"
+
+// Codec gRPC消息的JSON编解码，字段名即JSON键名，[]byte 字段编码为base64字符串
+type Codec struct{}
+
+func (Codec) Name() string                               { return Name }
+func (Codec) Marshal(v interface{}) ([]byte, error)      { return json.Marshal(v) }
+func (Codec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+
+func init() {
+	encoding.RegisterCodec(Codec{})
+}
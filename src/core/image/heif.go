@@ -0,0 +1,40 @@
+package image
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"strings"
+
+	"github.com/gen2brain/avif"
+	"github.com/gen2brain/heic"
+)
+
+// TranscodeToJPEG 将HEIC/AVIF图片解码后编码为JPEG，透明区域填充为白色
+// 解码器基于WebAssembly运行，不依赖CGo
+func TranscodeToJPEG(data []byte, format string, quality int) ([]byte, error) {
+	if quality <= 0 || quality > 100 {
+		quality = defaultJPEGQuality
+	}
+
+	var src image.Image
+	var err error
+	switch strings.ToLower(format) {
+	case "heic", "heif":
+		src, err = heic.Decode(bytes.NewReader(data))
+	case "avif":
+		src, err = avif.Decode(bytes.NewReader(data))
+	default:
+		return nil, fmt.Errorf("不支持转码的图片格式: %s", format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("解码%s图片失败: %v", format, err)
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, flattenAlpha(src), &jpeg.Options{Quality: quality}); err != nil {
+		return nil, fmt.Errorf("%s转换为JPEG失败: %v", format, err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,209 @@
+package image
+
+import (
+	"angrymiao-ai-server/src/configs"
+	"angrymiao-ai-server/src/core/utils"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"strings"
+	"time"
+
+	_ "golang.org/x/image/bmp" // 注册BMP解码器
+	"golang.org/x/image/draw"
+)
+
+const (
+	// 预处理默认缩放的最长边
+	defaultMaxDimension = 1920
+	// 预处理默认JPEG质量
+	defaultJPEGQuality = 85
+	// 缩放、转换等中间步骤使用的JPEG质量，最终质量由 NormalizeJPEGQuality 决定
+	intermediateJPEGQuality = 95
+)
+
+// PreprocessOptions 提交VLLLM前的图片预处理参数
+type PreprocessOptions struct {
+	MaxDimension int   // 最长边超过该值时等比缩放
+	JPEGQuality  int   // JPEG压缩质量(1-100)
+	MaxPixels    int64 // 解码前校验像素数，防止超大图片耗尽内存，0表示不限制
+}
+
+// PreprocessOptionsFromConfig 根据图片安全配置生成预处理参数
+// 未配置时使用默认值，且缩放后的尺寸不超过 max_width、max_height
+func PreprocessOptionsFromConfig(cfg *configs.SecurityConfig) PreprocessOptions {
+	opts := PreprocessOptions{MaxDimension: defaultMaxDimension, JPEGQuality: defaultJPEGQuality}
+	if cfg == nil {
+		return opts
+	}
+	opts.MaxPixels = cfg.MaxPixels
+	if cfg.MaxDimension > 0 {
+		opts.MaxDimension = cfg.MaxDimension
+	}
+	for _, limit := range []int{cfg.MaxWidth, cfg.MaxHeight} {
+		if limit > 0 && limit < opts.MaxDimension {
+			opts.MaxDimension = limit
+		}
+	}
+	if cfg.JPEGQuality > 0 && cfg.JPEGQuality <= 100 {
+		opts.JPEGQuality = cfg.JPEGQuality
+	}
+	return opts
+}
+
+// ResizeToMaxDimension 最长边超过 maxDim 时等比缩放，PNG保持PNG，其他格式输出JPEG；无需缩放时返回原数据
+func ResizeToMaxDimension(data []byte, maxDim int) ([]byte, error) {
+	src, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("解码图片失败: %v", err)
+	}
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if maxDim <= 0 || (width <= maxDim && height <= maxDim) {
+		return data, nil
+	}
+
+	if width >= height {
+		height = max(1, height*maxDim/width)
+		width = maxDim
+	} else {
+		width = max(1, width*maxDim/height)
+		height = maxDim
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, bounds, draw.Over, nil)
+
+	var buf bytes.Buffer
+	if format == "png" {
+		err = png.Encode(&buf, dst)
+	} else {
+		err = jpeg.Encode(&buf, flattenAlpha(dst), &jpeg.Options{Quality: intermediateJPEGQuality})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("编码缩放后的图片失败: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// ConvertToJPEG 将图片转换为JPEG，透明区域填充为白色；format 为 jpeg/jpg 时返回原数据
+func ConvertToJPEG(data []byte, format string) ([]byte, error) {
+	if isJPEGFormat(format) {
+		return data, nil
+	}
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("解码%s图片失败: %v", format, err)
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, flattenAlpha(src), &jpeg.Options{Quality: intermediateJPEGQuality}); err != nil {
+		return nil, fmt.Errorf("转换为JPEG失败: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// NormalizeJPEGQuality 按指定质量重新压缩JPEG，压缩后反而变大时（原图质量已更低）返回原数据
+func NormalizeJPEGQuality(data []byte, quality int) ([]byte, error) {
+	src, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("解码图片失败: %v", err)
+	}
+	if format != "jpeg" {
+		return nil, fmt.Errorf("不是JPEG图片: %s", format)
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, src, &jpeg.Options{Quality: quality}); err != nil {
+		return nil, fmt.Errorf("压缩JPEG失败: %v", err)
+	}
+	if buf.Len() >= len(data) {
+		return data, nil
+	}
+	return buf.Bytes(), nil
+}
+
+// Preprocess 依次执行缩放、格式转换（JPEG/PNG以外转为JPEG）、JPEG质量压缩，返回处理后的数据与实际格式
+func Preprocess(data []byte, opts PreprocessOptions, logger *utils.Logger) ([]byte, string, error) {
+	originalSize := len(data)
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("识别图片格式失败: %v", err)
+	}
+	if pixels := int64(config.Width) * int64(config.Height); opts.MaxPixels > 0 && pixels > opts.MaxPixels {
+		return nil, "", fmt.Errorf("图片像素过多: %d，最大允许: %d", pixels, opts.MaxPixels)
+	}
+
+	start := time.Now()
+	if data, err = ResizeToMaxDimension(data, opts.MaxDimension); err != nil {
+		return nil, "", err
+	}
+	format, err := DetectFormat(data)
+	if err != nil {
+		return nil, "", err
+	}
+	logger.Debug("图片预处理[缩放] 耗时: %v, 大小: %d -> %d", time.Since(start), originalSize, len(data))
+
+	if format != "jpeg" && format != "png" {
+		start = time.Now()
+		size := len(data)
+		if data, err = ConvertToJPEG(data, format); err != nil {
+			return nil, "", err
+		}
+		logger.Debug("图片预处理[%s转JPEG] 耗时: %v, 大小: %d -> %d", format, time.Since(start), size, len(data))
+		format = "jpeg"
+	}
+
+	if format == "jpeg" {
+		start = time.Now()
+		size := len(data)
+		if data, err = NormalizeJPEGQuality(data, opts.JPEGQuality); err != nil {
+			return nil, "", err
+		}
+		logger.Debug("图片预处理[JPEG质量%d] 耗时: %v, 大小: %d -> %d", opts.JPEGQuality, time.Since(start), size, len(data))
+	}
+
+	logger.Info("图片预处理完成，格式: %s, 大小: %d -> %d", format, originalSize, len(data))
+	return data, format, nil
+}
+
+// PreprocessImageData 预处理base64图片数据，URL图片由VLLLM服务自行下载，不做处理
+func PreprocessImageData(imageData ImageData, opts PreprocessOptions, logger *utils.Logger) (ImageData, error) {
+	if imageData.Data == "" {
+		return imageData, nil
+	}
+	data, err := base64.StdEncoding.DecodeString(imageData.Data)
+	if err != nil {
+		return imageData, fmt.Errorf("base64解码失败: %v", err)
+	}
+	data, format, err := Preprocess(data, opts, logger)
+	if err != nil {
+		return imageData, err
+	}
+	imageData.Data = base64.StdEncoding.EncodeToString(data)
+	imageData.Format = format
+	return imageData, nil
+}
+
+// DetectFormat 根据图片内容识别格式
+func DetectFormat(data []byte) (string, error) {
+	_, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("识别图片格式失败: %v", err)
+	}
+	return format, nil
+}
+
+func isJPEGFormat(format string) bool {
+	format = strings.ToLower(format)
+	return format == "jpeg" || format == "jpg"
+}
+
+// flattenAlpha 将透明区域填充为白色，JPEG不支持透明通道
+func flattenAlpha(src image.Image) image.Image {
+	dst := image.NewRGBA(src.Bounds())
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(dst, dst.Bounds(), src, src.Bounds().Min, draw.Over)
+	return dst
+}
@@ -0,0 +1,126 @@
+package image
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"angrymiao-ai-server/src/configs"
+	"angrymiao-ai-server/src/core/utils"
+)
+
+// gradientImage 生成渐变图片，避免纯色图片压缩后过小
+func gradientImage(width, height int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: uint8(x + y), A: 255})
+		}
+	}
+	return img
+}
+
+func encode(t *testing.T, format string, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var err error
+	switch format {
+	case "png":
+		err = png.Encode(&buf, img)
+	case "gif":
+		err = gif.Encode(&buf, img, nil)
+	default:
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 100})
+	}
+	if err != nil {
+		t.Fatalf("编码%s测试图片失败: %v", format, err)
+	}
+	return buf.Bytes()
+}
+
+func decodeConfig(t *testing.T, data []byte) image.Config {
+	t.Helper()
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("解码预处理结果失败: %v", err)
+	}
+	return config
+}
+
+func TestPreprocessResizesPNG(t *testing.T) {
+	logger, err := utils.NewLogger(&utils.LogCfg{LogLevel: "ERROR", LogDir: t.TempDir(), LogFile: "test.log"})
+	if err != nil {
+		t.Fatalf("创建日志失败: %v", err)
+	}
+
+	data := encode(t, "png", gradientImage(2400, 600))
+	output, format, err := Preprocess(data, PreprocessOptions{MaxDimension: 1920, JPEGQuality: 85}, logger)
+	if err != nil {
+		t.Fatalf("预处理失败: %v", err)
+	}
+	if format != "png" || !bytes.HasPrefix(output, imageSignatures["png"]) {
+		t.Errorf("缩放后格式 = %s, 期望保持png", format)
+	}
+	if config := decodeConfig(t, output); config.Width != 1920 || config.Height != 480 {
+		t.Errorf("缩放后尺寸 = %dx%d, 期望 1920x480", config.Width, config.Height)
+	}
+}
+
+func TestPreprocessConvertsGIFToJPEG(t *testing.T) {
+	logger, err := utils.NewLogger(&utils.LogCfg{LogLevel: "ERROR", LogDir: t.TempDir(), LogFile: "test.log"})
+	if err != nil {
+		t.Fatalf("创建日志失败: %v", err)
+	}
+
+	data := encode(t, "gif", gradientImage(200, 100))
+	output, format, err := Preprocess(data, PreprocessOptions{MaxDimension: 1920, JPEGQuality: 85}, logger)
+	if err != nil {
+		t.Fatalf("预处理失败: %v", err)
+	}
+	if format != "jpeg" || !bytes.HasPrefix(output, imageSignatures["jpeg"]) {
+		t.Errorf("GIF预处理后格式 = %s, 期望 jpeg", format)
+	}
+	if config := decodeConfig(t, output); config.Width != 200 || config.Height != 100 {
+		t.Errorf("未超出限制的图片不应缩放, 实际 %dx%d", config.Width, config.Height)
+	}
+
+	// 像素数超出限制时拒绝处理
+	if _, _, err := Preprocess(data, PreprocessOptions{MaxDimension: 1920, MaxPixels: 100}, logger); err == nil {
+		t.Errorf("像素数超出限制时应返回错误")
+	}
+}
+
+func TestNormalizeJPEGQuality(t *testing.T) {
+	data := encode(t, "jpeg", gradientImage(400, 300))
+	output, err := NormalizeJPEGQuality(data, 85)
+	if err != nil {
+		t.Fatalf("压缩JPEG失败: %v", err)
+	}
+	if len(output) >= len(data) {
+		t.Errorf("质量85压缩后大小 = %d, 应小于原图 %d", len(output), len(data))
+	}
+
+	// 已压缩过的图片不会因重新编码变大
+	again, err := NormalizeJPEGQuality(output, 95)
+	if err != nil {
+		t.Fatalf("重新压缩JPEG失败: %v", err)
+	}
+	if len(again) > len(output) {
+		t.Errorf("重新压缩后大小 = %d, 不应大于 %d", len(again), len(output))
+	}
+
+	if _, err := NormalizeJPEGQuality(encode(t, "png", gradientImage(10, 10)), 85); err == nil {
+		t.Errorf("非JPEG图片应返回错误")
+	}
+}
+
+func TestPreprocessOptionsFromConfig(t *testing.T) {
+	opts := PreprocessOptionsFromConfig(&configs.SecurityConfig{MaxWidth: 1024, MaxHeight: 4096})
+	if opts.MaxDimension != 1024 || opts.JPEGQuality != 85 {
+		t.Errorf("预处理参数 = %+v, 期望最长边1024、质量85", opts)
+	}
+}
@@ -0,0 +1,91 @@
+package knowledge
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// KnowledgeBaseClient 知识库检索客户端
+type KnowledgeBaseClient interface {
+	// Search 检索与 query 相关的内容，按相关度从高到低最多返回 topK 条
+	Search(ctx context.Context, query string, topK int) ([]string, error)
+}
+
+// HTTPClient 通过HTTP接口访问外部向量检索服务
+// 请求: POST {"query": "...", "top_k": 3}
+// 响应: {"results": [{"content": "...", "score": 0.9}]}
+type HTTPClient struct {
+	url        string
+	apiKey     string
+	httpClient *http.Client
+}
+
+type searchRequest struct {
+	Query string `json:"query"`
+	TopK  int    `json:"top_k"`
+}
+
+type searchResponse struct {
+	Results []struct {
+		Content string  `json:"content"`
+		Score   float64 `json:"score"`
+	} `json:"results"`
+}
+
+// NewHTTPClient 创建HTTP知识库客户端，超时由调用方通过 ctx 控制
+func NewHTTPClient(url, apiKey string) *HTTPClient {
+	return &HTTPClient{
+		url:        url,
+		apiKey:     apiKey,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Search 检索相关内容
+func (c *HTTPClient) Search(ctx context.Context, query string, topK int) ([]string, error) {
+	body, err := json.Marshal(searchRequest{Query: query, TopK: topK})
+	if err != nil {
+		return nil, fmt.Errorf("序列化检索请求失败: %v", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("请求知识库失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("知识库返回状态码 %d: %s", resp.StatusCode, data)
+	}
+
+	var result searchResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("解析检索结果失败: %v", err)
+	}
+
+	contents := make([]string, 0, len(result.Results))
+	for _, item := range result.Results {
+		if item.Content == "" {
+			continue
+		}
+		contents = append(contents, item.Content)
+		if topK > 0 && len(contents) >= topK {
+			break
+		}
+	}
+	return contents, nil
+}
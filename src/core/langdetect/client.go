@@ -0,0 +1,86 @@
+package langdetect
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Detector 语种识别提供者
+type Detector interface {
+	// Detect 识别16位单声道PCM音频的语种，返回BCP-47语言标签，如 en-US、zh-CN
+	Detect(ctx context.Context, pcm []byte, sampleRate int) (string, error)
+}
+
+// HTTPDetector 通过HTTP接口调用外部语种识别服务
+// 请求: POST {"audio": "<base64 PCM>", "format": "pcm", "sample_rate": 16000}
+// 响应: {"language": "en-US"}
+type HTTPDetector struct {
+	url        string
+	apiKey     string
+	httpClient *http.Client
+}
+
+type detectRequest struct {
+	Audio      string `json:"audio"`
+	Format     string `json:"format"`
+	SampleRate int    `json:"sample_rate"`
+}
+
+type detectResponse struct {
+	Language string `json:"language"`
+}
+
+// NewHTTPDetector 创建HTTP语种识别客户端，超时由调用方通过 ctx 控制
+func NewHTTPDetector(url, apiKey string) *HTTPDetector {
+	return &HTTPDetector{
+		url:        url,
+		apiKey:     apiKey,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Detect 识别音频语种
+func (d *HTTPDetector) Detect(ctx context.Context, pcm []byte, sampleRate int) (string, error) {
+	body, err := json.Marshal(detectRequest{
+		Audio:      base64.StdEncoding.EncodeToString(pcm),
+		Format:     "pcm",
+		SampleRate: sampleRate,
+	})
+	if err != nil {
+		return "", fmt.Errorf("序列化语种识别请求失败: %v", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.url, bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("创建请求失败: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if d.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+d.apiKey)
+	}
+
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("请求语种识别服务失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("语种识别服务返回状态码 %d: %s", resp.StatusCode, data)
+	}
+
+	var result detectResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("解析语种识别结果失败: %v", err)
+	}
+	if result.Language == "" {
+		return "", fmt.Errorf("语种识别结果为空")
+	}
+	return result.Language, nil
+}
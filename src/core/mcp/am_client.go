@@ -162,6 +162,21 @@ func (c *AMMCPClient) HasTool(name string) bool {
 	return false
 }
 
+// CallbackType 返回工具的回调方式（支持sanitized名称），同步返回结果的工具为空
+func (c *AMMCPClient) CallbackType(name string) string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if original, exists := c.toolNameMap[name]; exists {
+		name = original
+	}
+	for _, tool := range c.tools {
+		if tool.Name == name {
+			return tool.CallbackType
+		}
+	}
+	return ""
+}
+
 func sanitizeToolName(name string) string {
 	return strings.ReplaceAll(name, ".", "_")
 }
@@ -525,10 +540,12 @@ func (c *AMMCPClient) HandleMCPMessage(msgMap map[string]interface{}) error {
 						}
 					}
 
+					callbackType, _ := toolMap["callback_type"].(string)
 					newTool := Tool{
-						Name:        name,
-						Description: desc,
-						InputSchema: inputSchema,
+						Name:         name,
+						Description:  desc,
+						InputSchema:  inputSchema,
+						CallbackType: callbackType,
 					}
 
 					c.tools = append(c.tools, newTool)
@@ -5,6 +5,7 @@ import (
 	"angrymiao-ai-server/src/core/utils"
 	"context"
 	"fmt"
+	"net/http"
 	"sync"
 	"time"
 
@@ -27,6 +28,8 @@ type Config struct {
 	Env           []string          `yaml:"env,omitempty"`     // 环境变量
 	URL           string            `yaml:"url,omitempty"`     // SSE连接URL
 	Headers       map[string]string `yaml:"headers,omitempty"` // 连接头
+	HTTPClient    *http.Client      `yaml:"-"`                 // SSE连接使用的HTTP客户端，为空时使用默认客户端
+	CallbackTools []string          `yaml:"callback_tools"`    // 结果通过webhook异步回调的工具
 }
 
 // Client 封装MCP客户端功能
@@ -63,6 +66,9 @@ func NewClient(config *Config, logger *utils.Logger) (*Client, error) {
 			if len(config.Headers) > 0 {
 				options = append(options, transport.WithHeaders(config.Headers))
 			}
+			if config.HTTPClient != nil {
+				options = append(options, transport.WithHTTPClient(config.HTTPClient))
+			}
 			sseClient, err := mcpclient.NewSSEMCPClient(
 				config.URL,
 				options...,
@@ -105,9 +111,17 @@ func NewClient(config *Config, logger *utils.Logger) (*Client, error) {
 
 // Start 启动MCP客户端并监听资源更新
 func (c *Client) Start(ctx context.Context) error {
-	if c.useStdioClient {
+	cli := c.activeClient()
+	if cli != nil {
 		// c.logger.Info("Starting MCP stdio client with command: %s", c.config.Command)
 
+		// SSE客户端需要先建立事件流，stdio客户端创建时已启动
+		if !c.useStdioClient {
+			if err := cli.Start(ctx); err != nil {
+				return fmt.Errorf("failed to start SSE MCP client: %w", err)
+			}
+		}
+
 		// 创建初始化请求
 		initRequest := mcp.InitializeRequest{}
 		initRequest.Params.ProtocolVersion = mcp.LATEST_PROTOCOL_VERSION
@@ -121,9 +135,9 @@ func (c *Client) Start(ctx context.Context) error {
 		defer cancel()
 
 		// 初始化客户端
-		initResult, err := c.stdioClient.Initialize(initCtx, initRequest)
+		initResult, err := cli.Initialize(initCtx, initRequest)
 		if err != nil {
-			return fmt.Errorf("failed to initialize stdio MCP client: %w", err)
+			return fmt.Errorf("failed to initialize MCP client: %w", err)
 		}
 		c.name = initResult.ServerInfo.Name
 		c.logger.Info("Initialized server: %s %s with conmmand: %s",
@@ -145,12 +159,20 @@ func (c *Client) Start(ctx context.Context) error {
 	return nil
 }
 
+// activeClient 返回当前使用的底层MCP客户端
+func (c *Client) activeClient() *mcpclient.Client {
+	if c.useStdioClient {
+		return c.stdioClient
+	}
+	return c.client
+}
+
 // fetchTools 获取可用的工具列表
 func (c *Client) fetchTools(ctx context.Context) error {
-	if c.useStdioClient {
+	if cli := c.activeClient(); cli != nil {
 		// 使用协议方式获取工具列表
 		toolsRequest := mcp.ListToolsRequest{}
-		tools, err := c.stdioClient.ListTools(ctx, toolsRequest)
+		tools, err := cli.ListTools(ctx, toolsRequest)
 		if err != nil {
 			return fmt.Errorf("failed to list tools: %w", err)
 		}
@@ -168,6 +190,14 @@ func (c *Client) fetchTools(ctx context.Context) error {
 			if required == nil {
 				required = make([]string, 0)
 			}
+			// mcp-go 不保留工具定义中的扩展字段，异步回调工具由配置声明
+			callbackType := ""
+			for _, name := range c.config.CallbackTools {
+				if name == tool.Name {
+					callbackType = CallbackTypeWebhook
+					break
+				}
+			}
 			c.tools = append(c.tools, Tool{
 				Name:        tool.Name,
 				Description: tool.Description,
@@ -176,6 +206,7 @@ func (c *Client) fetchTools(ctx context.Context) error {
 					Properties: tool.InputSchema.Properties,
 					Required:   required,
 				},
+				CallbackType: callbackType,
 			})
 			toolNames += fmt.Sprintf("%s, ", tool.Name)
 			// log.Printf("Added tool: %s - %s %v; %v; %v", tool.Name, tool.Description, tool.InputSchema, tool.RawInputSchema, tool.Annotations)
@@ -189,6 +220,14 @@ func (c *Client) fetchTools(ctx context.Context) error {
 	}
 }
 
+// RefreshTools 重新从MCP服务器获取工具列表，失败时保留原有列表
+func (c *Client) RefreshTools(ctx context.Context) error {
+	if c.activeClient() == nil {
+		return fmt.Errorf("MCP客户端 %s 未连接", c.name)
+	}
+	return c.fetchTools(ctx)
+}
+
 // Stop 停止MCP客户端
 func (c *Client) Stop() {
 	if c.useStdioClient {
@@ -225,6 +264,21 @@ func (c *Client) HasTool(name string) bool {
 	return false
 }
 
+// CallbackType 返回工具的回调方式，同步返回结果的工具为空
+func (c *Client) CallbackType(name string) string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if len(name) > 4 && name[:4] == "mcp_" {
+		name = name[4:]
+	}
+	for _, tool := range c.tools {
+		if tool.Name == name {
+			return tool.CallbackType
+		}
+	}
+	return ""
+}
+
 // GetAvailableTools 获取所有可用工具
 func (c *Client) GetAvailableTools() []openai.Tool {
 	c.mu.RLock()
@@ -265,12 +319,12 @@ func (c *Client) CallTool(
 		return nil, fmt.Errorf("tool %s not found", name)
 	}
 
-	if c.useStdioClient {
+	if cli := c.activeClient(); cli != nil {
 		callRequest := mcp.CallToolRequest{}
 		callRequest.Params.Name = name
 		callRequest.Params.Arguments = args
 
-		result, err := c.stdioClient.CallTool(ctx, callRequest)
+		result, err := cli.CallTool(ctx, callRequest)
 		if err != nil {
 			return nil, fmt.Errorf("failed to call tool %s: %w", name, err)
 		}
@@ -17,6 +17,8 @@ type Tool struct {
 	Name        string          `json:"name"`
 	Description string          `json:"description"`
 	InputSchema ToolInputSchema `json:"inputSchema"`
+	// CallbackType 为 webhook 时工具结果由MCP服务器异步回调返回
+	CallbackType string `json:"callback_type,omitempty"`
 }
 
 // MCPClient 定义MCP客户端接口
@@ -43,5 +45,13 @@ type MCPClient interface {
 	ResetConnection() error
 }
 
+// CallbackTypeWebhook 工具结果由MCP服务器POST到回调地址
+const CallbackTypeWebhook = "webhook"
+
+// callbackTyper 可声明工具回调方式的MCP客户端
+type callbackTyper interface {
+	CallbackType(name string) string
+}
+
 // 确保Client实现了MCPClient接口
 var _ MCPClient = (*Client)(nil)
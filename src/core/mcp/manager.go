@@ -36,6 +36,13 @@ type Manager struct {
 	mu               sync.RWMutex
 
 	AutoReturnToPool bool // 是否自动归还到资源池
+
+	replacedTools map[string]go_openai.Tool // 会话级MCP工具覆盖的同名工具，关闭时恢复
+
+	// 定期刷新外部MCP服务器的工具列表
+	refreshMu   sync.Mutex // 同一时刻只允许一个刷新在执行
+	refreshStop chan struct{}
+	serverTools map[string]*serverToolState // 按服务器名记录上一次同步到函数注册表的工具
 }
 
 // NewManagerForPool 创建用于资源池的MCP管理器
@@ -173,6 +180,7 @@ func (m *Manager) BindConnection(
 	m.clients["xiaozhi"] = m.AMMCPClient
 	// 重新注册工具（只注册尚未注册的）
 	m.registerAllToolsIfNeeded()
+	m.startToolRefreshLocked()
 	return nil
 }
 
@@ -196,6 +204,7 @@ func (m *Manager) registerAllToolsIfNeeded() {
 	for name, client := range m.clients {
 		if name != "am" && client.IsReady() {
 			tools := client.GetAvailableTools()
+			m.recordServerToolsLocked(name, tools)
 			for _, tool := range tools {
 				toolName := tool.Function.Name
 				m.funcHandler.RegisterFunction(toolName, tool)
@@ -228,6 +237,7 @@ func (m *Manager) Reset() error {
 	m.funcHandler = nil
 	m.bRegisteredAMMCP = false
 	m.tools = make([]string, 0)
+	m.stopToolRefreshLocked()
 
 	// 对am客户端进行连接重置而不是完全销毁
 	if m.AMMCPClient != nil {
@@ -367,6 +377,15 @@ func convertConfig(cfg map[string]interface{}) (*Config, error) {
 		}
 	}
 
+	// 结果通过webhook异步回调的工具
+	if tools, ok := cfg["callback_tools"].([]interface{}); ok {
+		for _, tool := range tools {
+			if name, ok := tool.(string); ok {
+				config.CallbackTools = append(config.CallbackTools, name)
+			}
+		}
+	}
+
 	return config, nil
 }
 
@@ -408,6 +427,23 @@ func (m *Manager) IsMCPTool(toolName string) bool {
 	return false
 }
 
+// ToolCallbackType 返回工具的回调方式，为 CallbackTypeWebhook 时结果由MCP服务器异步回调
+func (m *Manager) ToolCallbackType(toolName string) string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, client := range m.clients {
+		if !client.HasTool(toolName) {
+			continue
+		}
+		if typer, ok := client.(callbackTyper); ok {
+			return typer.CallbackType(toolName)
+		}
+		return ""
+	}
+	return ""
+}
+
 // ExecuteTool 执行工具调用
 func (m *Manager) ExecuteTool(
 	ctx context.Context,
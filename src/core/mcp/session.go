@@ -0,0 +1,223 @@
+package mcp
+
+import (
+	"angrymiao-ai-server/src/configs"
+	"angrymiao-ai-server/src/core/types"
+	"angrymiao-ai-server/src/core/utils"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/angrymiao/go-openai"
+)
+
+const sessionClientName = "session"
+
+// 会话级MCP服务默认允许的协议
+var defaultSessionSchemes = []string{"wss", "https"}
+
+// allowPrivateSessionAddr 是否允许连接内网地址，仅测试使用
+var allowPrivateSessionAddr = false
+
+// 运营商级NAT地址段 100.64.0.0/10
+var cgnatNet = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// NewSessionManager 创建会话级MCP管理器，仅连接客户端在hello消息中指定的MCP服务
+// 服务地址必须通过 session_mcp 配置的协议与主机白名单校验；管理器创建时不建立连接，首次使用时由 EnsureSessionTools 初始化
+func NewSessionManager(lg *utils.Logger, serverURL string, token string, cfg configs.SessionMCPConfig) (*Manager, error) {
+	if serverURL == "" {
+		return nil, fmt.Errorf("会话MCP服务地址为空")
+	}
+	if err := ValidateSessionServerURL(serverURL, cfg); err != nil {
+		return nil, err
+	}
+
+	headers := make(map[string]string)
+	if token != "" {
+		if !strings.HasPrefix(token, "Bearer ") {
+			token = "Bearer " + token
+		}
+		headers["Authorization"] = token
+	}
+
+	client, err := NewClient(&Config{
+		Enabled:    true,
+		Command:    "sse",
+		URL:        sessionHTTPURL(serverURL),
+		Headers:    headers,
+		HTTPClient: newSessionHTTPClient(),
+	}, lg)
+	if err != nil {
+		return nil, fmt.Errorf("创建会话MCP客户端失败: %v", err)
+	}
+
+	return &Manager{
+		logger:        lg,
+		clients:       map[string]MCPClient{sessionClientName: client},
+		tools:         make([]string, 0),
+		replacedTools: make(map[string]openai.Tool),
+	}, nil
+}
+
+// ValidateSessionServerURL 校验会话MCP服务地址：功能需开启，协议与主机需在白名单内，且不能是内网地址
+func ValidateSessionServerURL(serverURL string, cfg configs.SessionMCPConfig) error {
+	if !cfg.Enabled {
+		return fmt.Errorf("会话MCP服务未开启")
+	}
+	u, err := url.Parse(serverURL)
+	if err != nil || u.Hostname() == "" {
+		return fmt.Errorf("会话MCP服务地址无效: %s", serverURL)
+	}
+
+	schemes := cfg.AllowedSchemes
+	if len(schemes) == 0 {
+		schemes = defaultSessionSchemes
+	}
+	if !containsFold(schemes, u.Scheme) {
+		return fmt.Errorf("会话MCP服务协议不允许: %s", u.Scheme)
+	}
+
+	host := strings.ToLower(u.Hostname())
+	if !sessionHostAllowed(host, cfg.AllowedHosts) {
+		return fmt.Errorf("会话MCP服务主机不在白名单中: %s", host)
+	}
+	if ip := net.ParseIP(host); ip != nil && isBlockedSessionIP(ip) {
+		return fmt.Errorf("会话MCP服务地址不允许为内网地址: %s", host)
+	}
+	return nil
+}
+
+// sessionHostAllowed 主机名精确匹配，"*.example.com" 匹配其子域名
+func sessionHostAllowed(host string, allowedHosts []string) bool {
+	for _, allowed := range allowedHosts {
+		allowed = strings.ToLower(strings.TrimSpace(allowed))
+		if allowed == "" {
+			continue
+		}
+		if strings.HasPrefix(allowed, "*.") {
+			if strings.HasSuffix(host, allowed[1:]) {
+				return true
+			}
+			continue
+		}
+		if host == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(list []string, value string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// isBlockedSessionIP 回环、内网、链路本地（含云厂商元数据地址）等地址不允许连接
+func isBlockedSessionIP(ip net.IP) bool {
+	if allowPrivateSessionAddr {
+		return false
+	}
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || cgnatNet.Contains(ip)
+}
+
+// newSessionHTTPClient 创建会话MCP使用的HTTP客户端，建立连接时再次校验实际IP，防止域名解析到内网地址
+func newSessionHTTPClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network, address string, c syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || isBlockedSessionIP(ip) {
+				return fmt.Errorf("会话MCP服务地址不允许为内网地址: %s", host)
+			}
+			return nil
+		},
+	}
+	httpTransport := http.DefaultTransport.(*http.Transport).Clone()
+	httpTransport.Proxy = nil
+	httpTransport.DialContext = dialer.DialContext
+	return &http.Client{Transport: httpTransport}
+}
+
+// sessionHTTPURL 将ws/wss地址转换为SSE所需的http/https地址
+func sessionHTTPURL(serverURL string) string {
+	switch {
+	case strings.HasPrefix(serverURL, "wss://"):
+		return "https://" + strings.TrimPrefix(serverURL, "wss://")
+	case strings.HasPrefix(serverURL, "ws://"):
+		return "http://" + strings.TrimPrefix(serverURL, "ws://")
+	}
+	return serverURL
+}
+
+// EnsureSessionTools 按需连接会话MCP服务，并将其工具注册到函数注册表
+// 与已有工具同名时，会话工具覆盖原有注册，原工具在 ReleaseSessionTools 时恢复
+func (m *Manager) EnsureSessionTools(ctx context.Context, fh types.FunctionRegistryInterface) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.isInitialized {
+		return nil
+	}
+
+	// 只尝试初始化一次，失败后不再重复连接
+	m.isInitialized = true
+
+	client, ok := m.clients[sessionClientName]
+	if !ok {
+		return fmt.Errorf("会话MCP客户端不存在")
+	}
+	if err := client.Start(ctx); err != nil {
+		return fmt.Errorf("启动会话MCP客户端失败: %v", err)
+	}
+
+	m.funcHandler = fh
+	for _, tool := range client.GetAvailableTools() {
+		toolName := tool.Function.Name
+		if replaced, err := fh.GetFunction(toolName); err == nil {
+			if _, saved := m.replacedTools[toolName]; !saved {
+				m.replacedTools[toolName] = replaced
+			}
+			fh.UnregisterFunction(toolName)
+			m.logger.Info("会话MCP工具覆盖同名工具: %s", toolName)
+		}
+		if err := fh.RegisterFunction(toolName, tool); err != nil {
+			m.logger.Error("注册会话MCP工具失败: %s, 错误: %v", toolName, err)
+			continue
+		}
+		if !m.isToolRegistered(toolName) {
+			m.tools = append(m.tools, toolName)
+		}
+	}
+	return nil
+}
+
+// ReleaseSessionTools 注销会话工具，并恢复被覆盖的同名工具
+func (m *Manager) ReleaseSessionTools(fh types.FunctionRegistryInterface) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, name := range m.tools {
+		fh.UnregisterFunction(name)
+		if replaced, ok := m.replacedTools[name]; ok {
+			if err := fh.RegisterFunction(name, replaced); err != nil {
+				m.logger.Error("恢复被覆盖的工具失败: %s, 错误: %v", name, err)
+			}
+		}
+	}
+	m.tools = m.tools[:0]
+	m.replacedTools = make(map[string]openai.Tool)
+}
@@ -0,0 +1,121 @@
+package mcp
+
+import (
+	"angrymiao-ai-server/src/configs"
+	"angrymiao-ai-server/src/core/function"
+	"angrymiao-ai-server/src/core/utils"
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/angrymiao/go-openai"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+func TestSessionManagerWithMockServer(t *testing.T) {
+	authHeader := make(chan string, 1)
+	mcpServer := server.NewMCPServer("mock-mcp", "1.0.0")
+	mcpServer.AddTool(mcp.NewTool("get_weather", mcp.WithDescription("查询天气")),
+		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			return mcp.NewToolResultText("晴"), nil
+		})
+	testServer := server.NewTestServer(mcpServer, server.WithSSEContextFunc(
+		func(ctx context.Context, r *http.Request) context.Context {
+			select {
+			case authHeader <- r.Header.Get("Authorization"):
+			default:
+			}
+			return ctx
+		}))
+	defer testServer.Close()
+
+	logger, err := utils.NewLogger(&utils.LogCfg{LogLevel: "ERROR", LogDir: t.TempDir(), LogFile: "test.log"})
+	if err != nil {
+		t.Fatalf("创建日志失败: %v", err)
+	}
+
+	// 测试服务监听在回环地址
+	allowPrivateSessionAddr = true
+	defer func() { allowPrivateSessionAddr = false }()
+
+	serverURL := strings.Replace(testServer.URL, "http://", "ws://", 1) + "/sse"
+	cfg := configs.SessionMCPConfig{Enabled: true, AllowedHosts: []string{"127.0.0.1"}, AllowedSchemes: []string{"ws"}}
+	mgr, err := NewSessionManager(logger, serverURL, "secret", cfg)
+	if err != nil {
+		t.Fatalf("创建会话MCP管理器失败: %v", err)
+	}
+	defer mgr.CleanupAll(context.Background())
+
+	// 注册同名工具，验证会话工具覆盖
+	registry := function.NewFunctionRegistry()
+	registry.RegisterFunction("mcp_get_weather", openai.Tool{
+		Type:     "function",
+		Function: &openai.FunctionDefinition{Name: "mcp_get_weather", Description: "旧工具"},
+	})
+
+	if err := mgr.EnsureSessionTools(context.Background(), registry); err != nil {
+		t.Fatalf("初始化会话MCP失败: %v", err)
+	}
+
+	if got := <-authHeader; got != "Bearer secret" {
+		t.Errorf("Authorization = %q, 期望 %q", got, "Bearer secret")
+	}
+
+	tool, err := registry.GetFunction("mcp_get_weather")
+	if err != nil {
+		t.Fatalf("会话工具未注册: %v", err)
+	}
+	if tool.Function.Description != "查询天气" {
+		t.Errorf("同名工具未被会话工具覆盖, 描述 = %q", tool.Function.Description)
+	}
+	if !mgr.IsMCPTool("mcp_get_weather") {
+		t.Errorf("会话管理器未记录工具 mcp_get_weather")
+	}
+
+	result, err := mgr.ExecuteTool(context.Background(), "mcp_get_weather", map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("调用会话工具失败: %v", err)
+	}
+	if result != "晴" {
+		t.Errorf("工具结果 = %v, 期望 晴", result)
+	}
+
+	// 关闭会话后恢复被覆盖的工具
+	mgr.ReleaseSessionTools(registry)
+	tool, err = registry.GetFunction("mcp_get_weather")
+	if err != nil {
+		t.Fatalf("被覆盖的工具未恢复: %v", err)
+	}
+	if tool.Function.Description != "旧工具" {
+		t.Errorf("恢复的工具描述 = %q, 期望 %q", tool.Function.Description, "旧工具")
+	}
+}
+
+func TestValidateSessionServerURL(t *testing.T) {
+	cfg := configs.SessionMCPConfig{Enabled: true, AllowedHosts: []string{"mcp.example.com", "*.trusted.com", "127.0.0.1", "169.254.169.254"}}
+	tests := []struct {
+		name    string
+		url     string
+		cfg     configs.SessionMCPConfig
+		wantErr bool
+	}{
+		{name: "白名单主机", url: "wss://mcp.example.com/sse", cfg: cfg},
+		{name: "白名单子域名", url: "https://a.trusted.com/sse", cfg: cfg},
+		{name: "未开启", url: "wss://mcp.example.com/sse", cfg: configs.SessionMCPConfig{AllowedHosts: cfg.AllowedHosts}, wantErr: true},
+		{name: "不在白名单", url: "wss://evil.com/sse", cfg: cfg, wantErr: true},
+		{name: "默认不允许明文协议", url: "ws://mcp.example.com/sse", cfg: cfg, wantErr: true},
+		{name: "回环地址", url: "wss://127.0.0.1/sse", cfg: cfg, wantErr: true},
+		{name: "元数据地址", url: "https://169.254.169.254/latest", cfg: cfg, wantErr: true},
+		{name: "地址无效", url: "://bad", cfg: cfg, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateSessionServerURL(tt.url, tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateSessionServerURL(%q) 错误 = %v, 期望错误 %v", tt.url, err, tt.wantErr)
+			}
+		})
+	}
+}
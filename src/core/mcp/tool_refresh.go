@@ -0,0 +1,198 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	go_openai "github.com/angrymiao/go-openai"
+)
+
+const (
+	// 刷新失败时重试间隔的最大倍数
+	maxToolRefreshBackoff = 4
+	toolRefreshTimeout    = 30 * time.Second
+)
+
+// toolRefresher 支持重新获取工具列表的MCP客户端
+type toolRefresher interface {
+	RefreshTools(ctx context.Context) error
+}
+
+// serverToolState 单个MCP服务器上一次同步到函数注册表的工具
+type serverToolState struct {
+	tools     map[string]go_openai.Tool
+	failures  int // 连续刷新失败次数
+	skipTicks int // 失败退避时需要跳过的刷新周期数
+}
+
+// toolRefreshInterval 返回工具列表的刷新间隔，<=0 时不刷新
+func (m *Manager) toolRefreshInterval() time.Duration {
+	if m.systemCfg == nil || m.systemCfg.MCPToolRefreshIntervalMinutes <= 0 {
+		return 0
+	}
+	return time.Duration(m.systemCfg.MCPToolRefreshIntervalMinutes) * time.Minute
+}
+
+// startToolRefreshLocked 绑定连接后启动周期刷新，调用方需持有 m.mu
+func (m *Manager) startToolRefreshLocked() {
+	interval := m.toolRefreshInterval()
+	if interval <= 0 || m.refreshStop != nil {
+		return
+	}
+	stop := make(chan struct{})
+	m.refreshStop = stop
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				ctx, cancel := context.WithTimeout(context.Background(), toolRefreshTimeout)
+				m.RefreshTools(ctx)
+				cancel()
+			}
+		}
+	}()
+}
+
+// stopToolRefreshLocked 停止周期刷新并清空同步记录，调用方需持有 m.mu
+func (m *Manager) stopToolRefreshLocked() {
+	if m.refreshStop != nil {
+		close(m.refreshStop)
+		m.refreshStop = nil
+	}
+	m.serverTools = nil
+}
+
+// recordServerToolsLocked 记录服务器已注册的工具，作为下次刷新的比较基准，调用方需持有 m.mu
+func (m *Manager) recordServerToolsLocked(name string, tools []go_openai.Tool) {
+	if m.serverTools == nil {
+		m.serverTools = make(map[string]*serverToolState)
+	}
+	state := m.serverTools[name]
+	if state == nil {
+		state = &serverToolState{}
+		m.serverTools[name] = state
+	}
+	state.tools = make(map[string]go_openai.Tool, len(tools))
+	for _, tool := range tools {
+		state.tools[tool.Function.Name] = tool
+	}
+}
+
+// RefreshTools 重新获取各外部MCP服务器的工具列表，新增、变更与移除的工具同步到函数注册表
+// 同一时刻只执行一次刷新；服务器刷新失败时保留原有工具，并按指数退避推迟下次重试，最多为刷新间隔的4倍
+func (m *Manager) RefreshTools(ctx context.Context) {
+	m.refreshMu.Lock()
+	defer m.refreshMu.Unlock()
+
+	m.mu.Lock()
+	due := make(map[string]MCPClient)
+	for name, client := range m.clients {
+		if _, ok := client.(toolRefresher); !ok || name == "am" || !client.IsReady() {
+			continue
+		}
+		if state := m.serverTools[name]; state != nil && state.skipTicks > 0 {
+			state.skipTicks--
+			continue
+		}
+		due[name] = client
+	}
+	m.mu.Unlock()
+
+	for name, client := range due {
+		// 请求MCP服务器期间不持有锁，避免阻塞工具调用
+		err := client.(toolRefresher).RefreshTools(ctx)
+
+		m.mu.Lock()
+		if m.serverTools[name] == nil {
+			m.recordServerToolsLocked(name, nil)
+		}
+		state := m.serverTools[name]
+		if err != nil {
+			state.failures++
+			backoff := maxToolRefreshBackoff
+			if state.failures < 2 {
+				backoff = 1 << state.failures
+			}
+			state.skipTicks = backoff - 1
+			m.mu.Unlock()
+			m.logger.Warn("刷新MCP服务器 %s 的工具列表失败，保留原有工具，%d个周期后重试: %v", name, backoff, err)
+			continue
+		}
+		state.failures, state.skipTicks = 0, 0
+		added, removed, changed := m.syncServerToolsLocked(name, client.GetAvailableTools())
+		m.mu.Unlock()
+
+		if added+removed+changed > 0 {
+			m.logger.Info("MCP服务器 %s 的工具列表已刷新: 新增%d个, 移除%d个, 变更%d个", name, added, removed, changed)
+		} else {
+			m.logger.Debug("MCP服务器 %s 的工具列表无变化", name)
+		}
+	}
+}
+
+// syncServerToolsLocked 将服务器最新的工具列表与上次同步的结果比较并更新函数注册表，调用方需持有 m.mu
+func (m *Manager) syncServerToolsLocked(name string, tools []go_openai.Tool) (added, removed, changed int) {
+	previous := m.serverTools[name].tools
+	current := make(map[string]go_openai.Tool, len(tools))
+	for _, tool := range tools {
+		toolName := tool.Function.Name
+		current[toolName] = tool
+		old, exists := previous[toolName]
+		switch {
+		case !exists:
+			added++
+			if m.funcHandler != nil {
+				if err := m.funcHandler.RegisterFunction(toolName, tool); err != nil {
+					m.funcHandler.ReplaceFunction(toolName, tool)
+				}
+			}
+			if !m.isToolRegistered(toolName) {
+				m.tools = append(m.tools, toolName)
+			}
+		case !sameTool(old, tool):
+			changed++
+			if m.funcHandler != nil {
+				if err := m.funcHandler.ReplaceFunction(toolName, tool); err != nil {
+					m.logger.Warn("更新MCP工具 %s 失败: %v", toolName, err)
+				}
+			}
+		}
+	}
+	for toolName := range previous {
+		if _, exists := current[toolName]; exists {
+			continue
+		}
+		removed++
+		if m.funcHandler != nil {
+			if err := m.funcHandler.UnregisterFunction(toolName); err != nil {
+				m.logger.Debug("注销MCP工具 %s: %v", toolName, err)
+			}
+		}
+		for i, registered := range m.tools {
+			if registered == toolName {
+				m.tools = append(m.tools[:i], m.tools[i+1:]...)
+				break
+			}
+		}
+	}
+	m.serverTools[name].tools = current
+	return added, removed, changed
+}
+
+// sameTool 比较两个工具定义是否一致
+func sameTool(a, b go_openai.Tool) bool {
+	left, err := json.Marshal(a)
+	if err != nil {
+		return false
+	}
+	right, err := json.Marshal(b)
+	if err != nil {
+		return false
+	}
+	return string(left) == string(right)
+}
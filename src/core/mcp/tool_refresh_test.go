@@ -0,0 +1,143 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+
+	"angrymiao-ai-server/src/configs"
+	"angrymiao-ai-server/src/core/function"
+	"angrymiao-ai-server/src/core/utils"
+
+	go_openai "github.com/angrymiao/go-openai"
+)
+
+// refreshableClient 模拟外部MCP服务器，RefreshTools 时返回 next 中的工具列表
+type refreshableClient struct {
+	mu        sync.Mutex
+	tools     []go_openai.Tool
+	next      []go_openai.Tool
+	err       error
+	refreshes int
+}
+
+func (c *refreshableClient) Start(ctx context.Context) error { return nil }
+func (c *refreshableClient) Stop()                           {}
+func (c *refreshableClient) IsReady() bool                   { return true }
+func (c *refreshableClient) ResetConnection() error          { return nil }
+
+func (c *refreshableClient) HasTool(name string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, tool := range c.tools {
+		if tool.Function.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *refreshableClient) GetAvailableTools() []go_openai.Tool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]go_openai.Tool(nil), c.tools...)
+}
+
+func (c *refreshableClient) CallTool(ctx context.Context, name string, args map[string]interface{}) (interface{}, error) {
+	return nil, nil
+}
+
+func (c *refreshableClient) RefreshTools(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.refreshes++
+	if c.err != nil {
+		return c.err
+	}
+	if c.next != nil {
+		c.tools = c.next
+	}
+	return nil
+}
+
+func mcpTool(name, description string) go_openai.Tool {
+	return go_openai.Tool{
+		Type:     go_openai.ToolTypeFunction,
+		Function: &go_openai.FunctionDefinition{Name: name, Description: description},
+	}
+}
+
+func newRefreshTestManager(t *testing.T, clients map[string]MCPClient) (*Manager, *function.FunctionRegistry) {
+	t.Helper()
+	logger, err := utils.NewLogger(&utils.LogCfg{LogLevel: "ERROR", LogDir: t.TempDir(), LogFile: "test.log"})
+	if err != nil {
+		t.Fatalf("创建日志失败: %v", err)
+	}
+	registry := function.NewFunctionRegistry()
+	m := &Manager{
+		logger:      logger,
+		funcHandler: registry,
+		clients:     clients,
+		systemCfg:   &configs.Config{MCPToolRefreshIntervalMinutes: 30},
+	}
+	m.registerAllToolsIfNeeded()
+	return m, registry
+}
+
+func TestRefreshToolsSyncsFunctionRegistry(t *testing.T) {
+	server1 := &refreshableClient{tools: []go_openai.Tool{mcpTool("search", "搜索")}}
+	server2 := &refreshableClient{tools: []go_openai.Tool{mcpTool("calendar", "日程"), mcpTool("notes", "笔记")}}
+	m, registry := newRefreshTestManager(t, map[string]MCPClient{"server1": server1, "server2": server2})
+
+	// T+30min：server1 新增工具，server2 变更并移除各一个工具
+	server1.next = []go_openai.Tool{mcpTool("search", "搜索"), mcpTool("translate", "翻译")}
+	server2.next = []go_openai.Tool{mcpTool("calendar", "日程v2")}
+	m.RefreshTools(context.Background())
+
+	names := registry.ListFunctions()
+	sort.Strings(names)
+	if want := []string{"calendar", "search", "translate"}; len(names) != len(want) || names[0] != want[0] || names[1] != want[1] || names[2] != want[2] {
+		t.Errorf("刷新后注册的函数 = %v, 期望 %v", names, want)
+	}
+	if tool, _ := registry.GetFunction("calendar"); tool.Function.Description != "日程v2" {
+		t.Errorf("变更的工具未更新: %+v", tool.Function)
+	}
+	if !m.IsMCPTool("translate") || m.IsMCPTool("notes") {
+		t.Errorf("MCP工具列表 = %v", m.GetAllToolsNames())
+	}
+}
+
+func TestRefreshToolsBacksOffOnFailure(t *testing.T) {
+	server := &refreshableClient{tools: []go_openai.Tool{mcpTool("search", "搜索")}, err: errors.New("连接超时")}
+	m, registry := newRefreshTestManager(t, map[string]MCPClient{"server1": server})
+
+	// 连续失败后重试间隔依次为2倍、4倍，之后保持4倍
+	var attempted []int
+	for tick := 1; tick <= 12; tick++ {
+		before := server.refreshes
+		m.RefreshTools(context.Background())
+		if server.refreshes > before {
+			attempted = append(attempted, tick)
+		}
+	}
+	if want := []int{1, 3, 7, 11}; len(attempted) != len(want) || attempted[1] != 3 || attempted[2] != 7 || attempted[3] != 11 {
+		t.Errorf("发起刷新的周期 = %v, 期望 %v", attempted, want)
+	}
+	if !registry.FunctionExists("search") {
+		t.Error("刷新失败时应保留原有工具")
+	}
+
+	// 恢复后每个周期都刷新
+	server.err = nil
+	for tick := 0; tick < 4; tick++ {
+		m.RefreshTools(context.Background())
+	}
+	before := server.refreshes
+	m.RefreshTools(context.Background())
+	m.RefreshTools(context.Background())
+	if server.refreshes-before != 2 {
+		t.Errorf("恢复后2个周期刷新了 %d 次", server.refreshes-before)
+	}
+}
@@ -0,0 +1,102 @@
+package media
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"angrymiao-ai-server/src/configs"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// DefaultPresignTTL 未配置 presigned_url_ttl_seconds 时预签名地址的有效期
+	DefaultPresignTTL = time.Hour
+	// MaxPresignTTL 预签名地址允许的最长有效期
+	MaxPresignTTL = 24 * time.Hour
+)
+
+// URLSigner 为对象存储中的文件生成限时GET地址
+type URLSigner interface {
+	PresignGet(key string, ttl time.Duration) (string, error)
+}
+
+// PresignedURL 预签名地址及其过期时间
+type PresignedURL struct {
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// PresignService 生成私有媒体的预签名地址，并缓存在Redis中
+// 缓存时间为有效期的一半，命中缓存时返回的地址至少还有一半有效期
+type PresignService struct {
+	signer    URLSigner
+	cache     *redis.Client // 为nil时不缓存
+	keyPrefix string
+	now       func() time.Time
+}
+
+// NewPresignService 创建预签名服务，service 为Redis键的服务前缀，为空时为 ai
+func NewPresignService(signer URLSigner, cache *redis.Client, service string) *PresignService {
+	if service == "" {
+		service = "ai"
+	}
+	return &PresignService{
+		signer:    signer,
+		cache:     cache,
+		keyPrefix: service + ":media_presign:",
+		now:       time.Now,
+	}
+}
+
+// NewOSSPresignService 使用OSS配置创建预签名服务，未配置 access_key_id 时返回nil
+func NewOSSPresignService(config *configs.OSSConfig, cache *redis.Client, service string) (*PresignService, error) {
+	if config.AccessKeyID == "" {
+		return nil, nil
+	}
+	backend, err := NewOSSBackend(config)
+	if err != nil {
+		return nil, err
+	}
+	return NewPresignService(backend, cache, service), nil
+}
+
+// PresignTTL 返回 presigned_url_ttl_seconds 配置的有效期，不超过 MaxPresignTTL
+func PresignTTL(config *configs.OSSConfig) time.Duration {
+	if config.PresignedURLTTLSeconds <= 0 {
+		return DefaultPresignTTL
+	}
+	return min(time.Duration(config.PresignedURLTTLSeconds)*time.Second, MaxPresignTTL)
+}
+
+func (p *PresignService) cacheKey(mediaID uint, ttl time.Duration) string {
+	return fmt.Sprintf("%s%d:%d", p.keyPrefix, mediaID, int64(ttl/time.Second))
+}
+
+// Presign 获取媒体文件的预签名地址，优先使用缓存；缓存读写失败时不影响生成
+func (p *PresignService) Presign(ctx context.Context, mediaID uint, key string, ttl time.Duration) (PresignedURL, error) {
+	cacheKey := p.cacheKey(mediaID, ttl)
+	if p.cache != nil {
+		if cached, err := p.cache.Get(ctx, cacheKey).Bytes(); err == nil {
+			var presigned PresignedURL
+			if json.Unmarshal(cached, &presigned) == nil && presigned.ExpiresAt.After(p.now()) {
+				return presigned, nil
+			}
+		}
+	}
+
+	expiresAt := p.now().Add(ttl)
+	signed, err := p.signer.PresignGet(key, ttl)
+	if err != nil {
+		return PresignedURL{}, err
+	}
+	presigned := PresignedURL{URL: signed, ExpiresAt: expiresAt}
+	if p.cache != nil {
+		if data, err := json.Marshal(presigned); err == nil {
+			_ = p.cache.Set(ctx, cacheKey, data, ttl/2).Err()
+		}
+	}
+	return presigned, nil
+}
@@ -0,0 +1,99 @@
+package media
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"angrymiao-ai-server/src/configs"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// countingSigner 记录预签名调用
+type countingSigner struct {
+	calls []string
+}
+
+func (s *countingSigner) PresignGet(key string, ttl time.Duration) (string, error) {
+	s.calls = append(s.calls, key)
+	return fmt.Sprintf("https://bucket.oss/%s?Expires=%d&n=%d", key, int64(ttl/time.Second), len(s.calls)), nil
+}
+
+func TestPresignServiceCachesURLWithExpiry(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+	signer := &countingSigner{}
+	p := NewPresignService(signer, client, "")
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	p.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	first, err := p.Presign(ctx, 7, "u1/image/a.jpg", time.Hour)
+	if err != nil {
+		t.Fatalf("生成预签名地址失败: %v", err)
+	}
+	if first.URL != "https://bucket.oss/u1/image/a.jpg?Expires=3600&n=1" || !first.ExpiresAt.Equal(now.Add(time.Hour)) {
+		t.Errorf("预签名地址 = %+v", first)
+	}
+	if ttl := mr.TTL("ai:media_presign:7:3600"); ttl != 30*time.Minute {
+		t.Errorf("缓存有效期 = %v, 期望 30m", ttl)
+	}
+
+	// 命中缓存时不重复签名
+	cached, err := p.Presign(ctx, 7, "u1/image/a.jpg", time.Hour)
+	if err != nil || cached != first || len(signer.calls) != 1 {
+		t.Errorf("缓存结果 = %+v, %v, 签名次数 %d", cached, err, len(signer.calls))
+	}
+
+	// 不同有效期分别缓存
+	if longer, _ := p.Presign(ctx, 7, "u1/image/a.jpg", 2*time.Hour); longer.URL == first.URL || len(signer.calls) != 2 {
+		t.Errorf("ttl=7200 的预签名地址 = %+v, 签名次数 %d", longer, len(signer.calls))
+	}
+
+	// 缓存过期后重新签名
+	mr.FastForward(31 * time.Minute)
+	if renewed, _ := p.Presign(ctx, 7, "u1/image/a.jpg", time.Hour); renewed.URL == first.URL || len(signer.calls) != 3 {
+		t.Errorf("缓存过期后的预签名地址 = %+v, 签名次数 %d", renewed, len(signer.calls))
+	}
+}
+
+func TestOSSBackendPresignGet(t *testing.T) {
+	presign, err := NewOSSPresignService(&configs.OSSConfig{
+		Endpoint:        "oss-cn-shenzhen.aliyuncs.com",
+		Bucket:          "media",
+		AccessKeyID:     "id",
+		AccessKeySecret: "secret",
+	}, nil, "")
+	if err != nil || presign == nil {
+		t.Fatalf("创建OSS预签名服务失败: %v", err)
+	}
+	got, err := presign.Presign(context.Background(), 1, "u1/image/a.jpg", time.Hour)
+	if err != nil {
+		t.Fatalf("生成预签名地址失败: %v", err)
+	}
+	parsed, err := url.Parse(got.URL)
+	if err != nil || !strings.HasSuffix(parsed.Path, "/u1/image/a.jpg") {
+		t.Fatalf("预签名地址 = %s", got.URL)
+	}
+	if q := parsed.Query(); q.Get("OSSAccessKeyId") != "id" || q.Get("Signature") == "" || q.Get("Expires") == "" {
+		t.Errorf("预签名参数 = %v", q)
+	}
+
+	if presign, err := NewOSSPresignService(&configs.OSSConfig{}, nil, ""); presign != nil || err != nil {
+		t.Errorf("未配置 access_key_id 时 = %v, %v, 期望不启用", presign, err)
+	}
+}
+
+func TestPresignTTL(t *testing.T) {
+	for seconds, want := range map[int64]time.Duration{0: time.Hour, 600: 10 * time.Minute, 200000: MaxPresignTTL} {
+		if got := PresignTTL(&configs.OSSConfig{PresignedURLTTLSeconds: seconds}); got != want {
+			t.Errorf("PresignTTL(%d) = %v, 期望 %v", seconds, got, want)
+		}
+	}
+}
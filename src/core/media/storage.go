@@ -0,0 +1,168 @@
+package media
+
+import (
+	"angrymiao-ai-server/src/configs"
+	"angrymiao-ai-server/src/core/utils"
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// ObjectStorageBackend 媒体文件的对象存储后端
+type ObjectStorageBackend interface {
+	// Upload 上传数据到指定路径，返回文件访问URL
+	Upload(ctx context.Context, key string, data []byte) (url string, err error)
+	// Delete 删除指定路径的文件
+	Delete(ctx context.Context, key string) error
+}
+
+// NewObjectStorageBackend 根据 storage_backend 配置创建存储后端，未配置时使用OSS
+func NewObjectStorageBackend(config *configs.OSSConfig) (ObjectStorageBackend, error) {
+	switch strings.ToLower(config.StorageBackend) {
+	case "", "oss":
+		return NewOSSBackend(config)
+	case "s3":
+		return NewS3Backend(config)
+	default:
+		return nil, fmt.Errorf("不支持的存储后端: %s", config.StorageBackend)
+	}
+}
+
+// OSSBackend 阿里云OSS存储后端
+type OSSBackend struct {
+	uploader *utils.OSSUploader
+}
+
+// NewOSSBackend 创建OSS存储后端
+func NewOSSBackend(config *configs.OSSConfig) (*OSSBackend, error) {
+	if config.AccessKeyID == "" || config.AccessKeySecret == "" {
+		return nil, fmt.Errorf("OSS配置不完整")
+	}
+
+	uploader, err := utils.NewOSSUploader(&utils.OSSConfig{
+		Region:          extractRegion(config.Endpoint),
+		Endpoint:        config.Endpoint,
+		Bucket:          config.Bucket,
+		AccessKeyID:     config.AccessKeyID,
+		AccessKeySecret: config.AccessKeySecret,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("创建OSS上传器失败: %v", err)
+	}
+	return &OSSBackend{uploader: uploader}, nil
+}
+
+func (b *OSSBackend) Upload(ctx context.Context, key string, data []byte) (string, error) {
+	return b.uploader.UploadData(ctx, key, data)
+}
+
+func (b *OSSBackend) Delete(ctx context.Context, key string) error {
+	return b.uploader.DeleteObject(ctx, key)
+}
+
+// PresignGet 实现 URLSigner
+func (b *OSSBackend) PresignGet(key string, ttl time.Duration) (string, error) {
+	return b.uploader.SignGetURL(key, ttl)
+}
+
+// extractRegion 从endpoint提取region
+func extractRegion(endpoint string) string {
+	region := "cn-shenzhen" // 默认区域
+	if strings.Contains(endpoint, "oss-") {
+		parts := strings.Split(endpoint, "oss-")
+		if len(parts) > 1 {
+			regionPart := strings.Split(parts[1], ".")[0]
+			region = regionPart
+		}
+	}
+	return region
+}
+
+const (
+	// defaultS3Region 未配置 s3_region 时使用的区域，MinIO 默认也使用该区域
+	defaultS3Region = "us-east-1"
+	// defaultS3PresignExpiration 未配置 expiration 时预签名URL的有效期
+	defaultS3PresignExpiration = time.Hour
+)
+
+// S3Backend S3兼容存储后端，配置 s3_endpoint 时可对接MinIO等服务
+// 上传后返回预签名的GET地址，有效期取 expiration 配置
+type S3Backend struct {
+	client  *s3.Client
+	presign *s3.PresignClient
+	bucket  string
+	expires time.Duration
+}
+
+// NewS3Backend 创建S3存储后端
+func NewS3Backend(config *configs.OSSConfig) (*S3Backend, error) {
+	if config.S3Bucket == "" || config.S3AccessKey == "" || config.S3SecretKey == "" {
+		return nil, fmt.Errorf("S3配置不完整")
+	}
+
+	region := config.S3Region
+	if region == "" {
+		region = defaultS3Region
+	}
+	client := s3.New(s3.Options{
+		Region:      region,
+		Credentials: credentials.NewStaticCredentialsProvider(config.S3AccessKey, config.S3SecretKey, ""),
+	}, func(o *s3.Options) {
+		if config.S3Endpoint != "" {
+			// 自建的S3兼容服务通常不支持虚拟主机风格的bucket域名
+			o.BaseEndpoint = aws.String(config.S3Endpoint)
+			o.UsePathStyle = true
+		}
+	})
+
+	expires := defaultS3PresignExpiration
+	if config.Expiration > 0 {
+		expires = time.Duration(config.Expiration) * time.Second
+	}
+	return &S3Backend{
+		client:  client,
+		presign: s3.NewPresignClient(client),
+		bucket:  config.S3Bucket,
+		expires: expires,
+	}, nil
+}
+
+func (b *S3Backend) Upload(ctx context.Context, key string, data []byte) (string, error) {
+	_, err := b.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:        aws.String(b.bucket),
+		Key:           aws.String(key),
+		Body:          bytes.NewReader(data),
+		ContentLength: aws.Int64(int64(len(data))),
+		ContentType:   aws.String(http.DetectContentType(data)),
+	})
+	if err != nil {
+		return "", fmt.Errorf("上传到S3失败: %v", err)
+	}
+
+	req, err := b.presign.PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(b.bucket),
+		Key:    aws.String(key),
+	}, s3.WithPresignExpires(b.expires))
+	if err != nil {
+		return "", fmt.Errorf("生成S3预签名地址失败: %v", err)
+	}
+	return req.URL, nil
+}
+
+func (b *S3Backend) Delete(ctx context.Context, key string) error {
+	_, err := b.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(b.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return fmt.Errorf("删除S3文件失败: %v", err)
+	}
+	return nil
+}
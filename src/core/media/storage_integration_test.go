@@ -0,0 +1,75 @@
+//go:build integration
+
+package media
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"testing"
+
+	"angrymiao-ai-server/src/configs"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// TestS3BackendMinIO 需要本地运行MinIO：
+//
+//	docker run -d -p 9000:9000 minio/minio server /data
+//	MINIO_ENDPOINT=http://127.0.0.1:9000 go test -tags integration ./src/core/media/
+func TestS3BackendMinIO(t *testing.T) {
+	endpoint := os.Getenv("MINIO_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("未设置 MINIO_ENDPOINT")
+	}
+	accessKey, secretKey := os.Getenv("MINIO_ACCESS_KEY"), os.Getenv("MINIO_SECRET_KEY")
+	if accessKey == "" {
+		accessKey, secretKey = "minioadmin", "minioadmin"
+	}
+
+	backend, err := NewS3Backend(&configs.OSSConfig{
+		S3Bucket:    "media-integration",
+		S3Endpoint:  endpoint,
+		S3AccessKey: accessKey,
+		S3SecretKey: secretKey,
+		Expiration:  300,
+	})
+	if err != nil {
+		t.Fatalf("创建S3存储后端失败: %v", err)
+	}
+	ctx := context.Background()
+	if _, err := backend.client.CreateBucket(ctx, &s3.CreateBucketInput{Bucket: aws.String(backend.bucket)}); err != nil {
+		t.Logf("创建bucket: %v", err)
+	}
+
+	data := []byte("\x89PNG\r\n\x1a\n integration")
+	key := "test/integration.png"
+	url, err := backend.Upload(ctx, key, data)
+	if err != nil {
+		t.Fatalf("上传失败: %v", err)
+	}
+
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("访问预签名地址失败: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != string(data) {
+		t.Fatalf("预签名地址返回 %d %q", resp.StatusCode, body)
+	}
+
+	if err := backend.Delete(ctx, key); err != nil {
+		t.Fatalf("删除失败: %v", err)
+	}
+	resp, err = http.Get(url)
+	if err != nil {
+		t.Fatalf("访问预签名地址失败: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("删除后预签名地址状态码 = %d, 期望 404", resp.StatusCode)
+	}
+}
@@ -0,0 +1,33 @@
+package media
+
+import (
+	"testing"
+
+	"angrymiao-ai-server/src/configs"
+)
+
+func TestNewObjectStorageBackendSelectsByConfig(t *testing.T) {
+	backend, err := NewObjectStorageBackend(&configs.OSSConfig{
+		StorageBackend: "s3",
+		S3Bucket:       "media",
+		S3Endpoint:     "http://127.0.0.1:9000",
+		S3AccessKey:    "minioadmin",
+		S3SecretKey:    "minioadmin",
+	})
+	if err != nil {
+		t.Fatalf("创建S3存储后端失败: %v", err)
+	}
+	if _, ok := backend.(*S3Backend); !ok {
+		t.Errorf("storage_backend=s3 时后端类型 = %T", backend)
+	}
+
+	if _, err := NewObjectStorageBackend(&configs.OSSConfig{StorageBackend: "s3"}); err == nil {
+		t.Error("S3配置不完整时应返回错误")
+	}
+	if _, err := NewObjectStorageBackend(&configs.OSSConfig{}); err == nil {
+		t.Error("未配置OSS密钥时应返回错误")
+	}
+	if _, err := NewObjectStorageBackend(&configs.OSSConfig{StorageBackend: "ftp"}); err == nil {
+		t.Error("不支持的存储后端应返回错误")
+	}
+}
@@ -3,6 +3,7 @@ package media
 import (
 	"angrymiao-ai-server/src/configs"
 	"angrymiao-ai-server/src/core/utils"
+	"context"
 	"fmt"
 	"strings"
 	"time"
@@ -33,7 +34,7 @@ type UploadRequest struct {
 // UploadResult 上传结果
 type UploadResult struct {
 	URL      string // 文件访问URL
-	Path     string // OSS路径
+	Path     string // 对象存储路径
 	FileType string // 文件类型
 	Suffix   string // 文件后缀
 	Size     int64  // 文件大小
@@ -70,13 +71,17 @@ func (u *Uploader) Upload(req *UploadRequest) (*UploadResult, error) {
 
 	u.logger.Info("文件已保存到本地: %s", localPath)
 
-	// 上传到OSS
-	fileURL, err := u.uploadToOSS(localPath, pathInfo.FullPath)
+	// 上传到对象存储
+	backend, err := NewObjectStorageBackend(&u.config.OSS)
 	if err != nil {
-		return nil, fmt.Errorf("上传到OSS失败: %v", err)
+		return nil, fmt.Errorf("创建存储后端失败: %v", err)
+	}
+	fileURL, err := backend.Upload(context.Background(), pathInfo.FullPath, fileData)
+	if err != nil {
+		return nil, fmt.Errorf("上传到对象存储失败: %v", err)
 	}
 
-	u.logger.Info("文件已上传到OSS: %s", fileURL)
+	u.logger.Info("文件已上传到对象存储: %s", fileURL)
 
 	return &UploadResult{
 		URL:      fileURL,
@@ -133,42 +138,3 @@ func (u *Uploader) saveToLocal(localPath string, data []byte) error {
 
 	return utils.WriteFile(localPath, data)
 }
-
-// uploadToOSS 上传文件到OSS
-func (u *Uploader) uploadToOSS(localPath, ossPath string) (string, error) {
-	ossConfig := u.config.OSS
-	if ossConfig.AccessKeyID == "" || ossConfig.AccessKeySecret == "" {
-		return "", fmt.Errorf("OSS配置不完整")
-	}
-
-	// 从endpoint提取region
-	region := u.extractRegion(ossConfig.Endpoint)
-
-	// 创建OSS上传器
-	uploader, err := utils.NewOSSUploader(&utils.OSSConfig{
-		Region:          region,
-		Endpoint:        ossConfig.Endpoint,
-		Bucket:          ossConfig.Bucket,
-		AccessKeyID:     ossConfig.AccessKeyID,
-		AccessKeySecret: ossConfig.AccessKeySecret,
-	})
-	if err != nil {
-		return "", fmt.Errorf("创建OSS上传器失败: %v", err)
-	}
-
-	// 上传文件
-	return uploader.UploadFile(localPath, ossPath)
-}
-
-// extractRegion 从endpoint提取region
-func (u *Uploader) extractRegion(endpoint string) string {
-	region := "cn-shenzhen" // 默认区域
-	if strings.Contains(endpoint, "oss-") {
-		parts := strings.Split(endpoint, "oss-")
-		if len(parts) > 1 {
-			regionPart := strings.Split(parts[1], ".")[0]
-			region = regionPart
-		}
-	}
-	return region
-}
@@ -1,11 +1,14 @@
 package middleware
 
 import (
+	"crypto/subtle"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"angrymiao-ai-server/src/core/auth"
 	am_token "angrymiao-ai-server/src/core/auth/am_token"
+	"angrymiao-ai-server/src/core/ratelimit"
 	"angrymiao-ai-server/src/core/utils"
 
 	"github.com/gin-gonic/gin"
@@ -44,7 +47,7 @@ func AmTokenJWTUserAuth() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if !strings.HasPrefix(authHeader, "Bearer ") {
-			c.JSON(http.StatusUnauthorized, gin.H{"code": http.StatusUnauthorized, "message": "无效的认证token或token已过期"})
+			c.JSON(http.StatusUnauthorized, errorBody(c, http.StatusUnauthorized, "无效的认证token或token已过期"))
 			c.Abort()
 			return
 		}
@@ -53,7 +56,7 @@ func AmTokenJWTUserAuth() gin.HandlerFunc {
 
 		claims, err := am_token.ParseToken(token)
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"code": http.StatusUnauthorized, "message": "token验证失败: " + err.Error()})
+			c.JSON(http.StatusUnauthorized, errorBody(c, http.StatusUnauthorized, "token验证失败: "+err.Error()))
 			c.Abort()
 			return
 		}
@@ -69,7 +72,7 @@ func DeviceTokenAuth(authToken *auth.AuthToken, logger *utils.Logger) gin.Handle
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if !strings.HasPrefix(authHeader, "Bearer ") {
-			c.JSON(http.StatusUnauthorized, gin.H{"code": http.StatusUnauthorized, "message": "无效的认证token或token已过期"})
+			c.JSON(http.StatusUnauthorized, errorBody(c, http.StatusUnauthorized, "无效的认证token或token已过期"))
 			c.Abort()
 			return
 		}
@@ -80,7 +83,7 @@ func DeviceTokenAuth(authToken *auth.AuthToken, logger *utils.Logger) gin.Handle
 			if logger != nil {
 				logger.Warn("JWTDeviceAuth 验证失败: %v", err)
 			}
-			c.JSON(http.StatusUnauthorized, gin.H{"code": http.StatusUnauthorized, "message": "无效的认证token或token已过期"})
+			c.JSON(http.StatusUnauthorized, errorBody(c, http.StatusUnauthorized, "无效的认证token或token已过期"))
 			c.Abort()
 			return
 		}
@@ -90,7 +93,7 @@ func DeviceTokenAuth(authToken *auth.AuthToken, logger *utils.Logger) gin.Handle
 			if logger != nil {
 				logger.Warn("设备ID与token不匹配: 请求=%s, token=%s", requestDeviceID, deviceID)
 			}
-			c.JSON(http.StatusUnauthorized, gin.H{"code": http.StatusUnauthorized, "message": "设备ID与token不匹配"})
+			c.JSON(http.StatusUnauthorized, errorBody(c, http.StatusUnauthorized, "设备ID与token不匹配"))
 			c.Abort()
 			return
 		}
@@ -101,3 +104,49 @@ func DeviceTokenAuth(authToken *auth.AuthToken, logger *utils.Logger) gin.Handle
 		c.Next()
 	}
 }
+
+// AdminTokenAuth 使用配置的管理员令牌校验，令牌未配置时拒绝所有请求
+func AdminTokenAuth(adminToken string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if adminToken == "" {
+			c.JSON(http.StatusForbidden, errorBody(c, http.StatusForbidden, "管理接口未启用"))
+			c.Abort()
+			return
+		}
+
+		authHeader := c.GetHeader("Authorization")
+		if !strings.HasPrefix(authHeader, "Bearer ") ||
+			subtle.ConstantTimeCompare([]byte(authHeader[7:]), []byte(adminToken)) != 1 {
+			c.JSON(http.StatusUnauthorized, errorBody(c, http.StatusUnauthorized, "无效的管理员令牌"))
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// UserRateLimit 按用户限流，需放在 AmTokenJWTUserAuth 之后；未开启限流时直接放行
+// 超出限制时返回429并通过 Retry-After 告知需等待的秒数，限流器异常时放行
+func UserRateLimit() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limiter := ratelimit.Get()
+		if limiter == nil {
+			c.Next()
+			return
+		}
+
+		result, err := limiter.Allow(c.Request.Context(), strconv.FormatUint(uint64(c.GetUint("user_id")), 10))
+		if err != nil || result.Allowed {
+			c.Next()
+			return
+		}
+
+		retryAfter := result.RetryAfterSeconds()
+		c.Header("Retry-After", strconv.Itoa(retryAfter))
+		body := errorBody(c, http.StatusTooManyRequests, "请求过于频繁，请稍后再试")
+		body["retry_after"] = retryAfter
+		c.JSON(http.StatusTooManyRequests, body)
+		c.Abort()
+	}
+}
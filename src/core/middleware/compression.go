@@ -0,0 +1,208 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/klauspost/compress/zstd"
+)
+
+// DefaultCompressionMinSize 响应体达到该大小(字节)才压缩
+const DefaultCompressionMinSize = 1024
+
+const (
+	encodingGzip = "gzip"
+	encodingZstd = "zstd"
+)
+
+// Compression 返回响应压缩中间件，响应体不小于1KB时压缩
+// 客户端 Accept-Encoding 包含 zstd 时使用 zstd，否则使用 gzip；level 为 gzip 压缩级别
+func Compression(level int) gin.HandlerFunc {
+	return CompressionWithMinSize(level, DefaultCompressionMinSize)
+}
+
+// CompressionWithMinSize 同 Compression，minSize<=0 时使用默认值
+// WebSocket 升级请求和 SSE 流式响应不压缩
+func CompressionWithMinSize(level int, minSize int) gin.HandlerFunc {
+	if minSize <= 0 {
+		minSize = DefaultCompressionMinSize
+	}
+	if level < gzip.HuffmanOnly || level > gzip.BestCompression {
+		level = gzip.DefaultCompression
+	}
+	zstdLevel := zstd.SpeedDefault
+	if level > 0 {
+		zstdLevel = zstd.EncoderLevelFromZstd(level)
+	}
+
+	gzipPool := sync.Pool{New: func() interface{} {
+		w, _ := gzip.NewWriterLevel(io.Discard, level)
+		return w
+	}}
+	zstdPool := sync.Pool{New: func() interface{} {
+		w, _ := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstdLevel), zstd.WithEncoderConcurrency(1))
+		return w
+	}}
+
+	return func(c *gin.Context) {
+		if !compressible(c.Request) {
+			c.Next()
+			return
+		}
+		encoding := negotiateEncoding(c.GetHeader("Accept-Encoding"))
+		if encoding == "" {
+			c.Next()
+			return
+		}
+		c.Header("Vary", "Accept-Encoding")
+
+		cw := &compressWriter{ResponseWriter: c.Writer, encoding: encoding, minSize: minSize}
+		switch encoding {
+		case encodingZstd:
+			cw.newEncoder = func(w io.Writer) io.WriteCloser {
+				enc := zstdPool.Get().(*zstd.Encoder)
+				enc.Reset(w)
+				return enc
+			}
+			cw.release = func(enc io.WriteCloser) { zstdPool.Put(enc) }
+		default:
+			cw.newEncoder = func(w io.Writer) io.WriteCloser {
+				enc := gzipPool.Get().(*gzip.Writer)
+				enc.Reset(w)
+				return enc
+			}
+			cw.release = func(enc io.WriteCloser) { gzipPool.Put(enc) }
+		}
+
+		c.Writer = cw
+		defer func() {
+			cw.finish()
+			c.Writer = cw.ResponseWriter
+		}()
+		c.Next()
+	}
+}
+
+// compressible WebSocket 升级与 SSE 请求不压缩
+func compressible(r *http.Request) bool {
+	if r.Method == http.MethodHead {
+		return false
+	}
+	if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") ||
+		strings.Contains(strings.ToLower(r.Header.Get("Connection")), "upgrade") {
+		return false
+	}
+	return !strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+}
+
+// negotiateEncoding 根据 Accept-Encoding 选择压缩算法，优先 zstd，q=0 视为不接受
+func negotiateEncoding(acceptEncoding string) string {
+	accepted := map[string]bool{}
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if v, err := strconv.ParseFloat(q, 64); err == nil && v == 0 {
+				continue
+			}
+		}
+		accepted[strings.ToLower(strings.TrimSpace(name))] = true
+	}
+	switch {
+	case accepted[encodingZstd]:
+		return encodingZstd
+	case accepted[encodingGzip]:
+		return encodingGzip
+	}
+	return ""
+}
+
+// compressWriter 先缓存响应体，达到 minSize 后开始压缩；结束时不足 minSize 则原样写出
+type compressWriter struct {
+	gin.ResponseWriter
+	encoding   string
+	minSize    int
+	newEncoder func(w io.Writer) io.WriteCloser
+	release    func(enc io.WriteCloser)
+
+	buf         bytes.Buffer
+	enc         io.WriteCloser
+	passthrough bool // 不压缩，直接写出
+}
+
+func (w *compressWriter) Write(p []byte) (int, error) {
+	if w.passthrough {
+		return w.ResponseWriter.Write(p)
+	}
+	if w.enc != nil {
+		return w.enc.Write(p)
+	}
+	if w.buf.Len() == 0 && !w.shouldCompress() {
+		w.passthrough = true
+		return w.ResponseWriter.Write(p)
+	}
+	w.buf.Write(p)
+	if w.buf.Len() >= w.minSize {
+		if err := w.startCompression(); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// shouldCompress 已自行编码或流式的响应不压缩
+func (w *compressWriter) shouldCompress() bool {
+	header := w.Header()
+	if header.Get("Content-Encoding") != "" {
+		return false
+	}
+	return !strings.HasPrefix(header.Get("Content-Type"), "text/event-stream")
+}
+
+func (w *compressWriter) startCompression() error {
+	header := w.Header()
+	header.Set("Content-Encoding", w.encoding)
+	header.Del("Content-Length")
+	w.enc = w.newEncoder(w.ResponseWriter)
+	_, err := w.enc.Write(w.buf.Bytes())
+	w.buf.Reset()
+	return err
+}
+
+// Flush 流式写出时不再等待 minSize，已缓存的内容原样写出
+func (w *compressWriter) Flush() {
+	if w.enc != nil {
+		if f, ok := w.enc.(interface{ Flush() error }); ok {
+			f.Flush()
+		}
+	} else if !w.passthrough {
+		w.passthrough = true
+		if w.buf.Len() > 0 {
+			w.ResponseWriter.Write(w.buf.Bytes())
+			w.buf.Reset()
+		}
+	}
+	w.ResponseWriter.Flush()
+}
+
+func (w *compressWriter) finish() {
+	if w.enc != nil {
+		w.enc.Close()
+		w.release(w.enc)
+		w.enc = nil
+		return
+	}
+	if w.buf.Len() > 0 {
+		w.ResponseWriter.Write(w.buf.Bytes())
+		w.buf.Reset()
+	}
+}
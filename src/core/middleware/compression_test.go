@@ -0,0 +1,143 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/klauspost/compress/zstd"
+)
+
+// chatHistoryBody 模拟50条消息的聊天历史响应
+func chatHistoryBody() gin.H {
+	messages := make([]gin.H, 50)
+	for i := range messages {
+		role := "user"
+		if i%2 == 1 {
+			role = "assistant"
+		}
+		messages[i] = gin.H{"role": role, "content": fmt.Sprintf("第%d条消息：今天深圳天气晴，最高气温30度，适合户外活动。", i+1), "bot_id": 0, "bot_name": "AM official"}
+	}
+	return gin.H{"success": true, "messages": messages, "total": 50, "page": 1, "page_size": 50}
+}
+
+func newCompressionEngine() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(Compression(gzip.DefaultCompression))
+	engine.GET("/history", func(c *gin.Context) { c.JSON(http.StatusOK, chatHistoryBody()) })
+	engine.GET("/small", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"success": true}) })
+	return engine
+}
+
+func request(engine *gin.Engine, path string, header map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	return w
+}
+
+func TestCompressionBodiesMatch(t *testing.T) {
+	engine := newCompressionEngine()
+	plain := request(engine, "/history", nil)
+	if plain.Header().Get("Content-Encoding") != "" {
+		t.Fatalf("未声明 Accept-Encoding 时不应压缩")
+	}
+	if plain.Body.Len() < DefaultCompressionMinSize {
+		t.Fatalf("测试响应应大于1KB, 实际 %d 字节", plain.Body.Len())
+	}
+
+	tests := []struct {
+		acceptEncoding string
+		want           string
+		decode         func(io.Reader) ([]byte, error)
+	}{
+		{"gzip, deflate", "gzip", func(r io.Reader) ([]byte, error) {
+			zr, err := gzip.NewReader(r)
+			if err != nil {
+				return nil, err
+			}
+			return io.ReadAll(zr)
+		}},
+		{"gzip, zstd", "zstd", func(r io.Reader) ([]byte, error) {
+			zr, err := zstd.NewReader(r)
+			if err != nil {
+				return nil, err
+			}
+			defer zr.Close()
+			return io.ReadAll(zr)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			w := request(engine, "/history", map[string]string{"Accept-Encoding": tt.acceptEncoding})
+			if got := w.Header().Get("Content-Encoding"); got != tt.want {
+				t.Fatalf("Content-Encoding = %q, 期望 %q", got, tt.want)
+			}
+			if w.Body.Len() >= plain.Body.Len() {
+				t.Errorf("压缩后 %d 字节不小于原始 %d 字节", w.Body.Len(), plain.Body.Len())
+			}
+			body, err := tt.decode(w.Body)
+			if err != nil {
+				t.Fatalf("解压失败: %v", err)
+			}
+			if !bytes.Equal(body, plain.Body.Bytes()) {
+				t.Errorf("解压后内容与未压缩响应不一致")
+			}
+			var decoded map[string]interface{}
+			if err := json.Unmarshal(body, &decoded); err != nil || decoded["total"] != float64(50) {
+				t.Errorf("解压后不是合法的响应JSON: %v", err)
+			}
+		})
+	}
+}
+
+func TestCompressionSkips(t *testing.T) {
+	engine := newCompressionEngine()
+	tests := []struct {
+		name   string
+		path   string
+		header map[string]string
+	}{
+		{"小于1KB", "/small", map[string]string{"Accept-Encoding": "gzip"}},
+		{"WebSocket升级", "/history", map[string]string{"Accept-Encoding": "gzip", "Connection": "Upgrade", "Upgrade": "websocket"}},
+		{"SSE", "/history", map[string]string{"Accept-Encoding": "gzip", "Accept": "text/event-stream"}},
+		{"q=0", "/history", map[string]string{"Accept-Encoding": "gzip;q=0"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := request(engine, tt.path, tt.header)
+			if got := w.Header().Get("Content-Encoding"); got != "" {
+				t.Errorf("Content-Encoding = %q, 期望不压缩", got)
+			}
+			if !json.Valid(w.Body.Bytes()) {
+				t.Errorf("未压缩的响应体应为原始JSON")
+			}
+		})
+	}
+}
+
+func BenchmarkCompressionChatHistory(b *testing.B) {
+	engine := newCompressionEngine()
+	for _, encoding := range []string{"identity", "gzip", "zstd"} {
+		b.Run(encoding, func(b *testing.B) {
+			req := httptest.NewRequest(http.MethodGet, "/history", nil)
+			req.Header.Set("Accept-Encoding", encoding)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				w := httptest.NewRecorder()
+				engine.ServeHTTP(w, req)
+				b.SetBytes(int64(w.Body.Len()))
+			}
+		})
+	}
+}
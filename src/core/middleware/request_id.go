@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"angrymiao-ai-server/src/core/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// maxRequestIDLength 客户端传入的请求关联ID最大长度，超出时重新生成
+const maxRequestIDLength = 128
+
+// RequestID 为每个请求分配关联ID：沿用客户端传入的 X-Request-ID，没有时生成UUID
+// 关联ID写入gin上下文与请求的 context，并通过响应头 X-Request-ID 返回
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(utils.RequestIDHeader)
+		if requestID == "" || len(requestID) > maxRequestIDLength {
+			requestID = uuid.New().String()
+		}
+
+		c.Set(utils.RequestIDKey, requestID)
+		c.Request = c.Request.WithContext(utils.WithRequestID(c.Request.Context(), requestID))
+		c.Header(utils.RequestIDHeader, requestID)
+		c.Next()
+	}
+}
+
+// errorBody 中间件直接返回的错误响应，附带请求关联ID
+func errorBody(c *gin.Context, code int, message string) gin.H {
+	body := gin.H{"code": code, "message": message}
+	if requestID := utils.GetRequestID(c); requestID != "" {
+		body[utils.RequestIDKey] = requestID
+	}
+	return body
+}
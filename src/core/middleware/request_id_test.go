@@ -0,0 +1,110 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"angrymiao-ai-server/src/core/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newRequestIDEngine 处理请求时调用上游服务并记录日志，上游失败时返回错误响应
+func newRequestIDEngine(t *testing.T, upstreamURL string) (*gin.Engine, string) {
+	t.Helper()
+	logDir := t.TempDir()
+	logger, err := utils.NewLogger(&utils.LogCfg{LogLevel: "INFO", LogDir: logDir, LogFile: "test.log"})
+	if err != nil {
+		t.Fatalf("创建日志失败: %v", err)
+	}
+	t.Cleanup(func() { logger.Close() })
+
+	client := &http.Client{Transport: utils.NewRequestIDTransport(nil)}
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(RequestID())
+	engine.GET("/chat", func(c *gin.Context) {
+		req, _ := http.NewRequestWithContext(c.Request.Context(), http.MethodGet, upstreamURL, nil)
+		resp, err := client.Do(req)
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				err = errors.New(resp.Status)
+			}
+		}
+		if err != nil {
+			utils.RequestLogger(c, logger).Error("调用上游服务失败: %v", err)
+			utils.ErrorWithDetail(c, http.StatusBadGateway, "上游服务异常", err)
+			return
+		}
+		utils.Success(c, gin.H{"reply": "ok"})
+	})
+	return engine, filepath.Join(logDir, "test.log")
+}
+
+func TestRequestIDCorrelatesLogsAndResponses(t *testing.T) {
+	var upstreamIDs []string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamIDs = append(upstreamIDs, r.Header.Get(utils.RequestIDHeader))
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer upstream.Close()
+	engine, logPath := newRequestIDEngine(t, upstream.URL)
+
+	w := request(engine, "/chat", nil)
+	requestID := w.Header().Get(utils.RequestIDHeader)
+	if len(requestID) != 36 {
+		t.Fatalf("响应头 X-Request-ID = %q, 期望生成UUID", requestID)
+	}
+	var body utils.UnifiedResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("解析响应失败: %v", err)
+	}
+	if w.Code != http.StatusBadGateway || body.RequestID != requestID {
+		t.Errorf("错误响应 = %d %+v, 期望携带 request_id %s", w.Code, body, requestID)
+	}
+	if len(upstreamIDs) != 1 || upstreamIDs[0] != requestID {
+		t.Errorf("上游收到的 X-Request-ID = %v", upstreamIDs)
+	}
+
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatalf("读取日志失败: %v", err)
+	}
+	found := false
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var entry map[string]interface{}
+		if json.Unmarshal([]byte(line), &entry) == nil && strings.HasPrefix(entry["msg"].(string), "调用上游服务失败") {
+			found = entry[utils.RequestIDKey] == requestID
+		}
+	}
+	if !found {
+		t.Errorf("日志中未找到 request_id %s: %s", requestID, data)
+	}
+
+	// 沿用客户端传入的关联ID
+	w = request(engine, "/chat", map[string]string{utils.RequestIDHeader: "client-request-1"})
+	if got := w.Header().Get(utils.RequestIDHeader); got != "client-request-1" {
+		t.Errorf("响应头 X-Request-ID = %q, 期望沿用客户端传入的ID", got)
+	}
+	if !strings.Contains(w.Body.String(), `"request_id":"client-request-1"`) || upstreamIDs[1] != "client-request-1" {
+		t.Errorf("响应 = %s, 上游收到 %v", w.Body.String(), upstreamIDs)
+	}
+}
+
+func TestRequestIDOmittedFromSuccessResponse(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer upstream.Close()
+	engine, _ := newRequestIDEngine(t, upstream.URL)
+
+	w := request(engine, "/chat", nil)
+	if w.Code != http.StatusOK || w.Header().Get(utils.RequestIDHeader) == "" || strings.Contains(w.Body.String(), "request_id") {
+		t.Errorf("成功响应 = %d %s, 关联ID只通过响应头返回", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,132 @@
+package moderation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"angrymiao-ai-server/src/core/providers"
+)
+
+const (
+	// CategoryBlocklist 命中未分类的黑名单关键词
+	CategoryBlocklist = "blocklist"
+
+	llmModerationPrompt = "You are a content moderator for a voice assistant. " +
+		"Classify whether the following assistant reply is safe to speak to the user. " +
+		`Answer with JSON only: {"safe": true|false, "category": "<violence|sexual|hate|self_harm|illegal|other|none>", "confidence": <0-1>}`
+)
+
+// Moderator 内容审核
+type Moderator interface {
+	// CheckText 检查文本是否可以播报，不安全时返回违规类别与置信度
+	CheckText(ctx context.Context, text string) (safe bool, category string, confidence float32, err error)
+}
+
+// RuleBasedModerator 按关键词黑名单审核，文本包含任一关键词（不区分大小写）即不安全
+type RuleBasedModerator struct {
+	rules []keywordRule
+}
+
+type keywordRule struct {
+	keyword  string
+	category string
+}
+
+// NewRuleBasedModerator 创建关键词审核，blocklist 的 key 为违规类别，key 为空时类别为 blocklist
+func NewRuleBasedModerator(blocklist map[string][]string) *RuleBasedModerator {
+	categories := make([]string, 0, len(blocklist))
+	for category := range blocklist {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+
+	m := &RuleBasedModerator{}
+	for _, category := range categories {
+		keywords := blocklist[category]
+		if category == "" {
+			category = CategoryBlocklist
+		}
+		for _, keyword := range keywords {
+			if keyword = strings.ToLower(strings.TrimSpace(keyword)); keyword != "" {
+				m.rules = append(m.rules, keywordRule{keyword: keyword, category: category})
+			}
+		}
+	}
+	return m
+}
+
+// CheckText 检查文本是否包含黑名单关键词
+func (m *RuleBasedModerator) CheckText(ctx context.Context, text string) (bool, string, float32, error) {
+	lower := strings.ToLower(text)
+	for _, rule := range m.rules {
+		if strings.Contains(lower, rule.keyword) {
+			return false, rule.category, 1, nil
+		}
+	}
+	return true, "", 0, nil
+}
+
+// LLMBasedModerator 调用LLM对文本分类
+type LLMBasedModerator struct {
+	llm       func() providers.LLMProvider
+	sessionID string
+}
+
+type llmVerdict struct {
+	Safe       *bool   `json:"safe"`
+	Category   string  `json:"category"`
+	Confidence float32 `json:"confidence"`
+}
+
+// NewLLMBasedModerator 创建LLM审核，llm 返回审核时使用的提供者，会话切换模型后仍使用最新的提供者
+func NewLLMBasedModerator(llm func() providers.LLMProvider, sessionID string) *LLMBasedModerator {
+	return &LLMBasedModerator{llm: llm, sessionID: sessionID}
+}
+
+// CheckText 请求LLM分类并解析返回的JSON，超时由调用方通过 ctx 控制
+func (m *LLMBasedModerator) CheckText(ctx context.Context, text string) (bool, string, float32, error) {
+	llm := m.llm()
+	if llm == nil {
+		return true, "", 0, fmt.Errorf("LLM提供者未初始化")
+	}
+	messages := []providers.Message{
+		{Role: "system", Content: llmModerationPrompt},
+		{Role: "user", Content: text},
+	}
+	responses, err := llm.Response(ctx, m.sessionID, messages)
+	if err != nil {
+		return true, "", 0, err
+	}
+
+	var builder strings.Builder
+	for {
+		select {
+		case chunk, ok := <-responses:
+			if !ok {
+				return parseVerdict(builder.String())
+			}
+			builder.WriteString(chunk)
+		case <-ctx.Done():
+			return true, "", 0, fmt.Errorf("LLM内容审核超时: %v", ctx.Err())
+		}
+	}
+}
+
+// parseVerdict 解析LLM回复中的审核结果，回复中可能带有JSON以外的说明文字
+func parseVerdict(reply string) (bool, string, float32, error) {
+	start, end := strings.Index(reply, "{"), strings.LastIndex(reply, "}")
+	if start == -1 || end < start {
+		return true, "", 0, fmt.Errorf("无法解析的审核结果: %q", reply)
+	}
+	var verdict llmVerdict
+	if err := json.Unmarshal([]byte(reply[start:end+1]), &verdict); err != nil || verdict.Safe == nil {
+		return true, "", 0, fmt.Errorf("无法解析的审核结果: %q", reply)
+	}
+	if *verdict.Safe {
+		return true, "", verdict.Confidence, nil
+	}
+	return false, verdict.Category, verdict.Confidence, nil
+}
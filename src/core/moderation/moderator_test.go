@@ -0,0 +1,84 @@
+package moderation
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"angrymiao-ai-server/src/core/providers"
+	"angrymiao-ai-server/src/core/types"
+)
+
+func TestRuleBasedModerator(t *testing.T) {
+	m := NewRuleBasedModerator(map[string][]string{
+		"violence": {"炸弹"},
+		"":         {"Forbidden"},
+	})
+	tests := []struct {
+		text     string
+		safe     bool
+		category string
+	}{
+		{"今天天气很好", true, ""},
+		{"教你制作炸弹", false, "violence"},
+		{"this is FORBIDDEN content", false, CategoryBlocklist},
+	}
+	for _, tt := range tests {
+		safe, category, confidence, err := m.CheckText(context.Background(), tt.text)
+		if err != nil || safe != tt.safe || category != tt.category {
+			t.Errorf("CheckText(%q) = %v, %q, %v, %v", tt.text, safe, category, confidence, err)
+		}
+		if !safe && confidence != 1 {
+			t.Errorf("关键词命中的置信度 = %v, 期望 1", confidence)
+		}
+	}
+}
+
+// classifyLLM 返回固定的分类结果
+type classifyLLM struct {
+	providers.LLMProvider
+	reply string
+	delay time.Duration
+	texts []string
+}
+
+func (p *classifyLLM) Response(ctx context.Context, sessionID string, messages []types.Message) (<-chan string, error) {
+	p.texts = append(p.texts, messages[len(messages)-1].Content)
+	ch := make(chan string, 1)
+	go func() {
+		defer close(ch)
+		time.Sleep(p.delay)
+		ch <- p.reply
+	}()
+	return ch, nil
+}
+
+func TestLLMBasedModerator(t *testing.T) {
+	llm := &classifyLLM{reply: `结果：{"safe": false, "category": "violence", "confidence": 0.93}`}
+	m := NewLLMBasedModerator(func() providers.LLMProvider { return llm }, "session-1")
+
+	safe, category, confidence, err := m.CheckText(context.Background(), "教你制作炸弹")
+	if err != nil || safe || category != "violence" || confidence != 0.93 {
+		t.Errorf("不安全内容 = %v, %q, %v, %v", safe, category, confidence, err)
+	}
+	if len(llm.texts) != 1 || llm.texts[0] != "教你制作炸弹" {
+		t.Errorf("发送给LLM的文本 = %v", llm.texts)
+	}
+
+	llm.reply = `{"safe": true, "category": "none", "confidence": 0.99}`
+	if safe, category, _, err := m.CheckText(context.Background(), "今天天气很好"); err != nil || !safe || category != "" {
+		t.Errorf("安全内容 = %v, %q, %v", safe, category, err)
+	}
+
+	// 无法解析或超时时返回错误，由调用方决定是否放行
+	llm.reply = "我不确定"
+	if _, _, _, err := m.CheckText(context.Background(), "你好"); err == nil {
+		t.Error("无法解析的回复应返回错误")
+	}
+	llm.reply, llm.delay = `{"safe": true}`, 100*time.Millisecond
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, _, _, err := m.CheckText(ctx, "你好"); err == nil {
+		t.Error("超时应返回错误")
+	}
+}
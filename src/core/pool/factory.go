@@ -58,13 +58,50 @@ func (f *ProviderFactory) createProvider() (interface{}, error) {
 		asrType, _ := params["type"].(string)
 		return asr.Create(asrType, cfg, delete_audio, f.logger)
 	case "llm":
-		cfg := f.config.(*llm.Config)
-		return llm.Create(cfg.Type, cfg)
+		// 每个实例使用独立的配置副本，连接应用用户配置时不影响池中其他实例
+		cfg := *f.config.(*llm.Config)
+		if dir := providers.ReplayDir(); dir != "" {
+			replay, err := providers.NewReplayLLM(dir)
+			if err != nil {
+				return nil, err
+			}
+			return replay, nil
+		}
+		provider, err := llm.Create(cfg.Type, &cfg)
+		if err != nil {
+			return nil, err
+		}
+		if dir := providers.RecordDir(); dir != "" {
+			recording, err := providers.NewRecordingLLM(provider, dir)
+			if err != nil {
+				return nil, err
+			}
+			return recording, nil
+		}
+		return provider, nil
 	case "tts":
-		cfg := f.config.(*tts.Config)
+		cfg := *f.config.(*tts.Config)
 		params := f.params
 		delete_audio, _ := params["delete_audio"].(bool)
-		return tts.Create(cfg.Type, cfg, delete_audio)
+		if dir := providers.ReplayDir(); dir != "" {
+			replay, err := providers.NewReplayTTS(dir)
+			if err != nil {
+				return nil, err
+			}
+			return replay, nil
+		}
+		provider, err := tts.Create(cfg.Type, &cfg, delete_audio)
+		if err != nil {
+			return nil, err
+		}
+		if dir := providers.RecordDir(); dir != "" {
+			recording, err := providers.NewRecordingTTS(provider, dir)
+			if err != nil {
+				return nil, err
+			}
+			return recording, nil
+		}
+		return provider, nil
 	case "vlllm":
 		cfg := f.config.(*configs.VLLMConfig)
 		return vlllm.Create(cfg.Type, cfg, f.logger)
@@ -82,7 +119,7 @@ func (f *ProviderFactory) createProvider() (interface{}, error) {
 
 // 创建各类型工厂的便利函数
 func NewASRFactory(asrType string, config *configs.Config, logger *utils.Logger) ResourceFactory {
-	if asrCfg, ok := config.ASR[asrType]; ok {
+	if asrCfg, ok := config.ASR.Providers[asrType]; ok {
 		return &ProviderFactory{
 			providerType: "asr",
 			config: &asr.Config{
@@ -176,11 +213,12 @@ func NewVADFactory(vadType string, config *configs.Config, logger *utils.Logger)
 		return &ProviderFactory{
 			providerType: "vad",
 			config: &vad.Config{
-				Name:           vadType,
-				Type:           vadType,
-				Aggressiveness: vadCfg.Aggressiveness,
-				FrameDuration:  vadCfg.FrameDuration,
-				Params:         nil,
+				Name:            vadType,
+				Type:            vadType,
+				Aggressiveness:  vadCfg.Aggressiveness,
+				FrameDuration:   vadCfg.FrameDuration,
+				EnergyThreshold: vadCfg.EnergyThreshold,
+				Params:          nil,
 			},
 			logger: logger,
 		}
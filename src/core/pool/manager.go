@@ -9,18 +9,36 @@ import (
 	"angrymiao-ai-server/src/core/utils"
 	"context"
 	"fmt"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
-// PoolManager 资源池管理器
+// providerAcquireTimeout 资源池达到最大容量时等待归还的最长时间
+const providerAcquireTimeout = 3 * time.Second
+
+// poolGeneration 资源池管理器的代数计数，每创建一个管理器加一
+var poolGeneration atomic.Uint64
+
+// PoolManager 资源池管理器，每种提供者使用独立的资源池
 type PoolManager struct {
-	asrPool   *ResourcePool
-	llmPool   *ResourcePool
-	ttsPool   *ResourcePool
-	vlllmPool *ResourcePool
-	mcpPool   *ResourcePool
-	vadPool   *ResourcePool
+	asrPool   *ProviderPool[providers.ASRProvider]
+	sharedASR *providers.MultiSessionASRProvider // ASR配置 can_share 时所有会话共享的ASR
+	llmPool   *ProviderPool[providers.LLMProvider]
+	ttsPool   *ProviderPool[providers.TTSProvider]
+	vlllmPool *ProviderPool[*vlllm.Provider]
+	mcpPool   *ProviderPool[*mcp.Manager]
+	vadPool   *ProviderPool[providersvad.Provider]
 	logger    *utils.Logger
+
+	// 开启 use_private_config 时按用户保存的私有资源池，未开启时为nil
+	config           *configs.Config
+	userPools        *UserProviderPool
+	userConfigLoader UserConfigLoader
+
+	// 热升级相关：generation 标识管理器的代数，migrated 统计从旧资源池迁移过来的连接数
+	generation uint64
+	migrated   atomic.Int64
 }
 
 // ProviderSet 提供者集合
@@ -31,12 +49,56 @@ type ProviderSet struct {
 	VLLLM *vlllm.Provider
 	MCP   *mcp.Manager
 	VAD   providersvad.Provider
+
+	// SharedASR 非空时ASR由多个会话共享，ASR 字段为空，会话通过 SharedASR.Session 获取自己的ASR
+	SharedASR *providers.MultiSessionASRProvider
+
+	userSlot *userPoolSlot // 非空时部分提供者来自用户的私有资源池，归还时放回对应的池
+
+	manager    *PoolManager // 借出该集合的资源池管理器
+	generation uint64       // 借出时管理器的代数
+}
+
+// Generation 借出该集合的资源池管理器的代数
+func (s *ProviderSet) Generation() uint64 {
+	return s.generation
+}
+
+// ReleaseBack 将集合归还到借出它的资源池管理器，热升级后旧连接的提供者仍归还到旧资源池
+func (s *ProviderSet) ReleaseBack() error {
+	if s.manager == nil {
+		return fmt.Errorf("提供者集合没有来源资源池，无法归还")
+	}
+	return s.manager.ReturnProviderSet(s)
+}
+
+// asrCanShare ASR配置了 can_share: true 时所有会话共享一个实例
+func asrCanShare(cfg configs.ASRConfig) bool {
+	canShare, _ := cfg["can_share"].(bool)
+	return canShare
+}
+
+// providerPoolConfig 按提供者类型覆盖最小、最大数量，未单独配置时使用全局配置
+func providerPoolConfig(base PoolConfig, minSize, maxSize int) PoolConfig {
+	if minSize > 0 {
+		base.MinSize = minSize
+	}
+	if maxSize > 0 {
+		base.MaxSize = maxSize
+	}
+	return base
 }
 
 // NewPoolManager 创建资源池管理器
 func NewPoolManager(config *configs.Config, logger *utils.Logger) (*PoolManager, error) {
 	pm := &PoolManager{
-		logger: logger,
+		logger:     logger,
+		config:     config,
+		generation: poolGeneration.Add(1),
+	}
+	if config.UsePrivateConfig {
+		pm.userPools = NewUserProviderPool(maxUserPools)
+		pm.userConfigLoader = loadUserProviderConfig
 	}
 
 	// 执行连通性检查
@@ -52,8 +114,9 @@ func NewPoolManager(config *configs.Config, logger *utils.Logger) (*PoolManager,
 		MinSize:       config.PoolConfig.PoolMinSize,
 		MaxSize:       config.PoolConfig.PoolMaxSize,
 		RefillSize:    config.PoolConfig.PoolRefillSize,
-		CheckInterval: 30 * time.Second,
+		CheckInterval: time.Duration(interval) * time.Second,
 	}
+	sizes := config.PoolConfig
 
 	// 检查配置是否包含所需的模块
 	selectedModule := config.SelectedModule
@@ -64,13 +127,25 @@ func NewPoolManager(config *configs.Config, logger *utils.Logger) (*PoolManager,
 		if asrFactory == nil {
 			return nil, fmt.Errorf("创建ASR工厂失败: 找不到配置 %s", asrType)
 		}
-		asrPool, err := NewResourcePool("asrPool", asrFactory, poolConfig, logger)
-		if err != nil {
-			return nil, fmt.Errorf("初始化ASR资源池失败: %v", err)
+		if asrCanShare(config.ASR.Providers[asrType]) {
+			resource, err := asrFactory.Create()
+			if err != nil {
+				return nil, fmt.Errorf("初始化共享ASR失败: %v", err)
+			}
+			asrProvider, ok := resource.(providers.ASRProvider)
+			if !ok {
+				return nil, fmt.Errorf("初始化共享ASR失败: %T 不是ASR提供者", resource)
+			}
+			pm.sharedASR = providers.NewMultiSessionASRProvider(asrProvider)
+			logger.Info("共享ASR初始化成功，类型: %s", asrType)
+		} else {
+			asrPool, err := NewProviderPool[providers.ASRProvider]("asrPool", asrFactory, providerPoolConfig(poolConfig, sizes.ASRMin, sizes.ASRMax), logger)
+			if err != nil {
+				return nil, fmt.Errorf("初始化ASR资源池失败: %v", err)
+			}
+			pm.asrPool = asrPool
+			logger.Info("ASR资源池初始化成功，类型: %s, 数量：%d", asrType, asrPool.Stats().Total)
 		}
-		pm.asrPool = asrPool
-		_, cnt := asrPool.GetStats()
-		logger.Info("ASR资源池初始化成功，类型: %s, 数量：%d", asrType, cnt)
 	}
 
 	// 初始化LLM池
@@ -79,13 +154,12 @@ func NewPoolManager(config *configs.Config, logger *utils.Logger) (*PoolManager,
 		if llmFactory == nil {
 			return nil, fmt.Errorf("创建LLM工厂失败: 找不到配置 %s", llmType)
 		}
-		llmPool, err := NewResourcePool("llmPool", llmFactory, poolConfig, logger)
+		llmPool, err := NewProviderPool[providers.LLMProvider]("llmPool", llmFactory, providerPoolConfig(poolConfig, sizes.LLMMin, sizes.LLMMax), logger)
 		if err != nil {
 			return nil, fmt.Errorf("初始化LLM资源池失败: %v", err)
 		}
 		pm.llmPool = llmPool
-		_, cnt := llmPool.GetStats()
-		logger.Info("LLM资源池初始化成功，类型: %s, 数量：%d", llmType, cnt)
+		logger.Info("LLM资源池初始化成功，类型: %s, 数量：%d", llmType, llmPool.Stats().Total)
 	}
 
 	// 初始化TTS池
@@ -94,13 +168,12 @@ func NewPoolManager(config *configs.Config, logger *utils.Logger) (*PoolManager,
 		if ttsFactory == nil {
 			return nil, fmt.Errorf("创建TTS工厂失败: 找不到配置 %s", ttsType)
 		}
-		ttsPool, err := NewResourcePool("ttsPool", ttsFactory, poolConfig, logger)
+		ttsPool, err := NewProviderPool[providers.TTSProvider]("ttsPool", ttsFactory, providerPoolConfig(poolConfig, sizes.TTSMin, sizes.TTSMax), logger)
 		if err != nil {
 			return nil, fmt.Errorf("初始化TTS资源池失败: %v", err)
 		}
 		pm.ttsPool = ttsPool
-		_, cnt := ttsPool.GetStats()
-		logger.Info("TTS资源池初始化成功，类型: %s, 数量：%d", ttsType, cnt)
+		logger.Info("TTS资源池初始化成功，类型: %s, 数量：%d", ttsType, ttsPool.Stats().Total)
 	}
 
 	// 初始化VLLLM池（可选）
@@ -109,7 +182,7 @@ func NewPoolManager(config *configs.Config, logger *utils.Logger) (*PoolManager,
 		if vlllmFactory == nil {
 			logger.Warn("创建VLLLM工厂失败: 找不到配置 %s", vlllmType)
 		} else {
-			vlllmPool, err := NewResourcePool("vllmPool", vlllmFactory, poolConfig, logger)
+			vlllmPool, err := NewProviderPool[*vlllm.Provider]("vllmPool", vlllmFactory, providerPoolConfig(poolConfig, sizes.VLLLMMin, sizes.VLLLMMax), logger)
 			if err != nil {
 				logger.Warn("初始化VLLLM资源池失败（将继续使用普通LLM）: %v", err)
 			} else {
@@ -117,8 +190,7 @@ func NewPoolManager(config *configs.Config, logger *utils.Logger) (*PoolManager,
 			}
 		}
 		if pm.vlllmPool != nil {
-			_, cnt := pm.vlllmPool.GetStats()
-			logger.Info("VLLLM资源池初始化成功，类型: %s, 数量：%d", vlllmType, cnt)
+			logger.Info("VLLLM资源池初始化成功，类型: %s, 数量：%d", vlllmType, pm.vlllmPool.Stats().Total)
 		} else {
 			logger.Warn("VLLLM资源池未初始化，将使用普通LLM")
 		}
@@ -130,7 +202,7 @@ func NewPoolManager(config *configs.Config, logger *utils.Logger) (*PoolManager,
 		if vadFactory == nil {
 			logger.Warn("创建VAD工厂失败: 找不到配置 %s", vadType)
 		} else {
-			vadPool, err := NewResourcePool("vadPool", vadFactory, poolConfig, logger)
+			vadPool, err := NewProviderPool[providersvad.Provider]("vadPool", vadFactory, providerPoolConfig(poolConfig, sizes.VADMin, sizes.VADMax), logger)
 			if err != nil {
 				logger.Warn("初始化VAD资源池失败: %v", err)
 			} else {
@@ -138,8 +210,7 @@ func NewPoolManager(config *configs.Config, logger *utils.Logger) (*PoolManager,
 			}
 		}
 		if pm.vadPool != nil {
-			_, cnt := pm.vadPool.GetStats()
-			logger.Info("VAD资源池初始化成功，类型: %s, 数量：%d", vadType, cnt)
+			logger.Info("VAD资源池初始化成功，类型: %s, 数量：%d", vadType, pm.vadPool.Stats().Total)
 		} else {
 			logger.Warn("VAD资源池未初始化")
 		}
@@ -156,13 +227,12 @@ func NewPoolManager(config *configs.Config, logger *utils.Logger) (*PoolManager,
 	logger.Info("开始初始化MCP资源池，请等待...")
 	mcpFactory := NewMCPFactory(config, logger)
 	if mcpFactory != nil {
-		mcpPool, err := NewResourcePool("mcpPool", mcpFactory, poolConfig, logger)
+		mcpPool, err := NewProviderPool[*mcp.Manager]("mcpPool", mcpFactory, poolConfig, logger)
 		if err != nil {
 			return nil, fmt.Errorf("初始化MCP资源池失败: %v", err)
 		}
 		pm.mcpPool = mcpPool
-		_, cnt := mcpPool.GetStats()
-		logger.Info("MCP资源池初始化成功，数量：%d", cnt)
+		logger.Info("MCP资源池初始化成功，数量：%d", mcpPool.Stats().Total)
 	} else {
 		logger.Warn("创建MCP工厂失败，MCP功能将不可用")
 	}
@@ -170,61 +240,113 @@ func NewPoolManager(config *configs.Config, logger *utils.Logger) (*PoolManager,
 	return pm, nil
 }
 
-// GetProviderSet 获取一套提供者
+// acquireProvider 在协程中从资源池借出提供者，pool 为nil时跳过
+func acquireProvider[T any](ctx context.Context, wg *sync.WaitGroup, pool *ProviderPool[T], dst *T, errp *error) {
+	if pool == nil {
+		return
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		*dst, *errp = pool.Get(ctx)
+	}()
+}
+
+// GetProviderSet 并发地从各资源池获取一套提供者
+// ASR、LLM、TTS 任一获取失败时归还已获取的提供者并返回错误，VLLLM、MCP、VAD 获取失败时留空
 func (pm *PoolManager) GetProviderSet() (*ProviderSet, error) {
-	set := &ProviderSet{}
+	return pm.getProviderSet(nil)
+}
 
-	if pm.asrPool != nil {
-		asr, err := pm.asrPool.Get()
-		if err != nil {
-			return nil, fmt.Errorf("获取ASR提供者失败: %v", err)
-		}
-		set.ASR = asr.(providers.ASRProvider)
+// setPools 返回提供者集合中各提供者所属的资源池，用户私有资源池中存在的优先
+func (pm *PoolManager) setPools(slot *userPoolSlot) (
+	*ProviderPool[providers.ASRProvider],
+	*ProviderPool[providers.LLMProvider],
+	*ProviderPool[providers.TTSProvider],
+	*ProviderPool[*vlllm.Provider],
+) {
+	asrPool, llmPool, ttsPool, vlllmPool := pm.asrPool, pm.llmPool, pm.ttsPool, pm.vlllmPool
+	if slot == nil {
+		return asrPool, llmPool, ttsPool, vlllmPool
 	}
-
-	if pm.llmPool != nil {
-		llm, err := pm.llmPool.Get()
-		if err != nil {
-			return nil, fmt.Errorf("获取LLM提供者失败: %v", err)
-		}
-		set.LLM = llm.(providers.LLMProvider)
+	if slot.asrPool != nil {
+		asrPool = slot.asrPool
 	}
-
-	if pm.ttsPool != nil {
-		tts, err := pm.ttsPool.Get()
-		if err != nil {
-			return nil, fmt.Errorf("获取TTS提供者失败: %v", err)
-		}
-		set.TTS = tts.(providers.TTSProvider)
+	if slot.llmPool != nil {
+		llmPool = slot.llmPool
 	}
-
-	if pm.vlllmPool != nil {
-		vlllmProvider, err := pm.vlllmPool.Get()
-		if err == nil {
-			// 直接转换，因为我们知道这是从 vlllm 工厂创建的
-			set.VLLLM = vlllmProvider.(*vlllm.Provider)
-		}
+	if slot.ttsPool != nil {
+		ttsPool = slot.ttsPool
 	}
-
-	if pm.mcpPool != nil {
-		mcpManager, err := pm.mcpPool.Get()
-		if err == nil {
-			// 直接转换，因为我们知道这是从 mcp 工厂创建的
-			set.MCP = mcpManager.(*mcp.Manager)
-			set.MCP.AutoReturnToPool = true
-		}
+	if slot.vlllmPool != nil {
+		vlllmPool = slot.vlllmPool
 	}
+	return asrPool, llmPool, ttsPool, vlllmPool
+}
 
-	if pm.vadPool != nil {
-		vadp, err := pm.vadPool.Get()
-		if err == nil {
-			set.VAD = vadp.(providersvad.Provider)
+// getProviderSet 获取一套提供者，slot 非空时其中的提供者从用户的私有资源池获取
+func (pm *PoolManager) getProviderSet(slot *userPoolSlot) (*ProviderSet, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), providerAcquireTimeout)
+	defer cancel()
+
+	asrPool, llmPool, ttsPool, vlllmPool := pm.setPools(slot)
+	set := &ProviderSet{SharedASR: pm.sharedASR, userSlot: slot, manager: pm, generation: pm.generation}
+	if slot != nil && slot.asrPool != nil {
+		set.SharedASR = nil
+	}
+	var asrErr, llmErr, ttsErr, vlllmErr, mcpErr, vadErr error
+	var wg sync.WaitGroup
+	acquireProvider(ctx, &wg, asrPool, &set.ASR, &asrErr)
+	acquireProvider(ctx, &wg, llmPool, &set.LLM, &llmErr)
+	acquireProvider(ctx, &wg, ttsPool, &set.TTS, &ttsErr)
+	acquireProvider(ctx, &wg, vlllmPool, &set.VLLLM, &vlllmErr)
+	acquireProvider(ctx, &wg, pm.mcpPool, &set.MCP, &mcpErr)
+	acquireProvider(ctx, &wg, pm.vadPool, &set.VAD, &vadErr)
+	wg.Wait()
+
+	if set.MCP != nil {
+		set.MCP.AutoReturnToPool = true
+	}
+	for _, optional := range []struct {
+		name string
+		err  error
+	}{{"VLLLM", vlllmErr}, {"MCP", mcpErr}, {"VAD", vadErr}} {
+		if optional.err != nil {
+			pm.logger.Warn("获取%s提供者失败: %v", optional.name, optional.err)
 		}
 	}
 
+	var err error
+	switch {
+	case asrErr != nil:
+		err = fmt.Errorf("获取ASR提供者失败: %v", asrErr)
+	case llmErr != nil:
+		err = fmt.Errorf("获取LLM提供者失败: %v", llmErr)
+	case ttsErr != nil:
+		err = fmt.Errorf("获取TTS提供者失败: %v", ttsErr)
+	}
+	if err != nil {
+		pm.ReturnProviderSet(set)
+		return nil, err
+	}
 	return set, nil
 }
 
+// Generation 资源池管理器的代数，热升级后新管理器的代数更大
+func (pm *PoolManager) Generation() uint64 {
+	return pm.generation
+}
+
+// RecordMigration 记录一个连接从旧资源池迁移到本管理器，返回累计迁移的连接数
+func (pm *PoolManager) RecordMigration() int64 {
+	return pm.migrated.Add(1)
+}
+
+// Migrated 累计迁移到本管理器的连接数
+func (pm *PoolManager) Migrated() int64 {
+	return pm.migrated.Load()
+}
+
 func (pm *PoolManager) ReturnMcpManager(m *mcp.Manager) error {
 	if pm.mcpPool != nil && m != nil {
 		// 重置资源状态
@@ -244,6 +366,11 @@ func (pm *PoolManager) Close() {
 	if pm.asrPool != nil {
 		pm.asrPool.Close()
 	}
+	if pm.sharedASR != nil {
+		if err := pm.sharedASR.Cleanup(); err != nil {
+			pm.logger.Warn("释放共享ASR失败: %v", err)
+		}
+	}
 	if pm.llmPool != nil {
 		pm.llmPool.Close()
 	}
@@ -256,6 +383,12 @@ func (pm *PoolManager) Close() {
 	if pm.mcpPool != nil {
 		pm.mcpPool.Close()
 	}
+	if pm.vadPool != nil {
+		pm.vadPool.Close()
+	}
+	if pm.userPools != nil {
+		pm.userPools.Close()
+	}
 }
 
 // ReturnProviderSet 归还提供者集合到池中
@@ -265,15 +398,16 @@ func (pm *PoolManager) ReturnProviderSet(set *ProviderSet) error {
 	}
 
 	var errs []error
+	asrPool, llmPool, ttsPool, vlllmPool := pm.setPools(set.userSlot)
 
 	// 归还ASR提供者
-	if set.ASR != nil && pm.asrPool != nil {
+	if set.ASR != nil && asrPool != nil {
 		// 重置资源状态
-		if err := pm.asrPool.Reset(set.ASR); err != nil {
+		if err := asrPool.Reset(set.ASR); err != nil {
 			pm.logger.Warn("重置ASR资源状态失败: %v", err)
 		}
 		// 归还到池中
-		if err := pm.asrPool.Put(set.ASR); err != nil {
+		if err := asrPool.Put(set.ASR); err != nil {
 			errs = append(errs, fmt.Errorf("归还ASR提供者失败: %v", err))
 			pm.logger.Error("归还ASR提供者失败: %v", err)
 		} else {
@@ -282,11 +416,11 @@ func (pm *PoolManager) ReturnProviderSet(set *ProviderSet) error {
 	}
 
 	// 归还LLM提供者
-	if set.LLM != nil && pm.llmPool != nil {
-		if err := pm.llmPool.Reset(set.LLM); err != nil {
+	if set.LLM != nil && llmPool != nil {
+		if err := llmPool.Reset(set.LLM); err != nil {
 			pm.logger.Warn("重置LLM资源状态失败: %v", err)
 		}
-		if err := pm.llmPool.Put(set.LLM); err != nil {
+		if err := llmPool.Put(set.LLM); err != nil {
 			errs = append(errs, fmt.Errorf("归还LLM提供者失败: %v", err))
 			pm.logger.Error("归还LLM提供者失败: %v", err)
 		} else {
@@ -295,11 +429,11 @@ func (pm *PoolManager) ReturnProviderSet(set *ProviderSet) error {
 	}
 
 	// 归还TTS提供者
-	if set.TTS != nil && pm.ttsPool != nil {
-		if err := pm.ttsPool.Reset(set.TTS); err != nil {
+	if set.TTS != nil && ttsPool != nil {
+		if err := ttsPool.Reset(set.TTS); err != nil {
 			pm.logger.Warn("重置TTS资源状态失败: %v", err)
 		}
-		if err := pm.ttsPool.Put(set.TTS); err != nil {
+		if err := ttsPool.Put(set.TTS); err != nil {
 			errs = append(errs, fmt.Errorf("归还TTS提供者失败: %v", err))
 			pm.logger.Error("归还TTS提供者失败: %v", err)
 		} else {
@@ -308,11 +442,11 @@ func (pm *PoolManager) ReturnProviderSet(set *ProviderSet) error {
 	}
 
 	// 归还VLLLM提供者
-	if set.VLLLM != nil && pm.vlllmPool != nil {
-		if err := pm.vlllmPool.Reset(set.VLLLM); err != nil {
+	if set.VLLLM != nil && vlllmPool != nil {
+		if err := vlllmPool.Reset(set.VLLLM); err != nil {
 			pm.logger.Warn("重置VLLLM资源状态失败: %v", err)
 		}
-		if err := pm.vlllmPool.Put(set.VLLLM); err != nil {
+		if err := vlllmPool.Put(set.VLLLM); err != nil {
 			errs = append(errs, fmt.Errorf("归还VLLLM提供者失败: %v", err))
 			pm.logger.Error("归还VLLLM提供者失败: %v", err)
 		} else {
@@ -333,6 +467,19 @@ func (pm *PoolManager) ReturnProviderSet(set *ProviderSet) error {
 		}
 	}
 
+	// 归还VAD提供者
+	if set.VAD != nil && pm.vadPool != nil {
+		if err := pm.vadPool.Reset(set.VAD); err != nil {
+			pm.logger.Warn("重置VAD资源状态失败: %v", err)
+		}
+		if err := pm.vadPool.Put(set.VAD); err != nil {
+			errs = append(errs, fmt.Errorf("归还VAD提供者失败: %v", err))
+			pm.logger.Error("归还VAD提供者失败: %v", err)
+		} else {
+			pm.logger.Debug("VAD提供者已成功归还到池中")
+		}
+	}
+
 	if len(errs) > 0 {
 		return fmt.Errorf("归还过程中发生多个错误: %v", errs)
 	}
@@ -341,35 +488,36 @@ func (pm *PoolManager) ReturnProviderSet(set *ProviderSet) error {
 	return nil
 }
 
-// GetStats 获取所有池的统计信息
-func (pm *PoolManager) GetStats() map[string]map[string]int {
-	stats := make(map[string]map[string]int)
-
+// ProviderPoolStats 获取各提供者池的当前数量、借出次数与等待时间，供监控使用
+func (pm *PoolManager) ProviderPoolStats() map[string]PoolStats {
+	stats := make(map[string]PoolStats)
 	if pm.asrPool != nil {
-		available, total := pm.asrPool.GetStats()
-		stats["asr"] = map[string]int{"available": available, "total": total}
+		stats["asr"] = pm.asrPool.Stats()
 	}
-
 	if pm.llmPool != nil {
-		available, total := pm.llmPool.GetStats()
-		stats["llm"] = map[string]int{"available": available, "total": total}
+		stats["llm"] = pm.llmPool.Stats()
 	}
-
 	if pm.ttsPool != nil {
-		available, total := pm.ttsPool.GetStats()
-		stats["tts"] = map[string]int{"available": available, "total": total}
+		stats["tts"] = pm.ttsPool.Stats()
 	}
-
 	if pm.vlllmPool != nil {
-		available, total := pm.vlllmPool.GetStats()
-		stats["vlllm"] = map[string]int{"available": available, "total": total}
+		stats["vlllm"] = pm.vlllmPool.Stats()
 	}
-
 	if pm.mcpPool != nil {
-		available, total := pm.mcpPool.GetStats()
-		stats["mcp"] = map[string]int{"available": available, "total": total}
+		stats["mcp"] = pm.mcpPool.Stats()
+	}
+	if pm.vadPool != nil {
+		stats["vad"] = pm.vadPool.Stats()
 	}
+	return stats
+}
 
+// GetStats 获取所有池的统计信息
+func (pm *PoolManager) GetStats() map[string]map[string]int {
+	stats := make(map[string]map[string]int)
+	for name, s := range pm.ProviderPoolStats() {
+		stats[name] = map[string]int{"available": s.Available, "total": s.Total}
+	}
 	return stats
 }
 
@@ -403,26 +551,14 @@ func (pm *PoolManager) performConnectivityCheck(
 // GetDetailedStats 获取所有池的详细统计信息
 func (pm *PoolManager) GetDetailedStats() map[string]map[string]int {
 	stats := make(map[string]map[string]int)
-
-	if pm.asrPool != nil {
-		stats["asr"] = pm.asrPool.GetDetailedStats()
-	}
-
-	if pm.llmPool != nil {
-		stats["llm"] = pm.llmPool.GetDetailedStats()
-	}
-
-	if pm.ttsPool != nil {
-		stats["tts"] = pm.ttsPool.GetDetailedStats()
-	}
-
-	if pm.vlllmPool != nil {
-		stats["vlllm"] = pm.vlllmPool.GetDetailedStats()
-	}
-
-	if pm.mcpPool != nil {
-		stats["mcp"] = pm.mcpPool.GetDetailedStats()
+	for name, s := range pm.ProviderPoolStats() {
+		stats[name] = map[string]int{
+			"available": s.Available,
+			"total":     s.Total,
+			"max":       s.Max,
+			"min":       s.Min,
+			"in_use":    s.InUse,
+		}
 	}
-
 	return stats
 }
@@ -0,0 +1,271 @@
+package pool
+
+import (
+	"angrymiao-ai-server/src/core/utils"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// PoolStats 单个提供者池的统计信息
+type PoolStats struct {
+	Available int     `json:"available"`   // 池中空闲数量
+	Total     int     `json:"total"`       // 已创建且未销毁的数量（空闲+借出）
+	InUse     int     `json:"in_use"`      // 借出数量
+	Min       int     `json:"min"`         // 保持的最少空闲数量
+	Max       int     `json:"max"`         // 最大数量
+	Borrows   int64   `json:"borrows"`     // 累计借出次数
+	AvgWaitMs float64 `json:"avg_wait_ms"` // 平均借出等待时间（包含即时创建的耗时）
+	MaxWaitMs float64 `json:"max_wait_ms"` // 最长借出等待时间
+}
+
+// ProviderPool 单一类型提供者的资源池
+// 借出后由后台协程将空闲数量补充到 minSize，已创建总数不超过 maxSize
+type ProviderPool[T any] struct {
+	name    string
+	factory ResourceFactory
+	items   chan T
+	minSize int
+	maxSize int
+	refill  chan struct{}
+	logger  *utils.Logger
+	ctx     context.Context
+	cancel  context.CancelFunc
+
+	mu        sync.Mutex
+	total     int  // 已创建且未销毁的数量，包含正在创建的
+	closed    bool // 关闭后不再接收资源
+	borrows   int64
+	waitTotal time.Duration
+	waitMax   time.Duration
+}
+
+// NewProviderPool 创建提供者池，预创建 MinSize 个提供者并启动补充协程，MaxSize 小于 MinSize 时按 MinSize 处理
+func NewProviderPool[T any](
+	name string,
+	factory ResourceFactory,
+	config PoolConfig,
+	logger *utils.Logger,
+) (*ProviderPool[T], error) {
+	minSize := max(config.MinSize, 0)
+	maxSize := max(config.MaxSize, minSize)
+	checkInterval := config.CheckInterval
+	if checkInterval <= 0 {
+		checkInterval = 30 * time.Second
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &ProviderPool[T]{
+		name:    name,
+		factory: factory,
+		items:   make(chan T, maxSize),
+		minSize: minSize,
+		maxSize: maxSize,
+		refill:  make(chan struct{}, 1),
+		logger:  logger,
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+
+	// 预创建最小数量的提供者
+	for i := 0; i < minSize; i++ {
+		item, err := p.create()
+		if err != nil {
+			p.Close()
+			return nil, err
+		}
+		p.mu.Lock()
+		p.total++
+		p.items <- item
+		p.mu.Unlock()
+	}
+
+	go p.maintain(checkInterval)
+	return p, nil
+}
+
+// Get 借出一个提供者：优先取空闲的，没有空闲且未达到上限时立即创建，否则等待归还直到 ctx 结束
+func (p *ProviderPool[T]) Get(ctx context.Context) (T, error) {
+	var zero T
+	start := time.Now()
+
+	select {
+	case item, ok := <-p.items:
+		if !ok {
+			return zero, fmt.Errorf("%s 资源池已关闭", p.name)
+		}
+		p.borrowed(start)
+		return item, nil
+	default:
+	}
+
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return zero, fmt.Errorf("%s 资源池已关闭", p.name)
+	}
+	if p.total < p.maxSize {
+		p.total++
+		p.mu.Unlock()
+		p.logger.Info("%s 资源池中没有可用资源，创建新资源", p.name)
+		item, err := p.create()
+		if err != nil {
+			p.mu.Lock()
+			p.total--
+			p.mu.Unlock()
+			return zero, err
+		}
+		p.borrowed(start)
+		return item, nil
+	}
+	p.mu.Unlock()
+
+	select {
+	case item, ok := <-p.items:
+		if !ok {
+			return zero, fmt.Errorf("%s 资源池已关闭", p.name)
+		}
+		p.borrowed(start)
+		return item, nil
+	case <-ctx.Done():
+		return zero, fmt.Errorf("%s 资源池已达到最大容量 %d，等待可用资源超时: %v", p.name, p.maxSize, ctx.Err())
+	}
+}
+
+// Put 归还提供者，池已关闭或已满时直接销毁
+func (p *ProviderPool[T]) Put(item T) error {
+	p.mu.Lock()
+	if !p.closed {
+		select {
+		case p.items <- item:
+			p.mu.Unlock()
+			return nil
+		default:
+		}
+	}
+	p.total--
+	p.mu.Unlock()
+
+	p.logger.Info("[Put] %s 资源池已满或已关闭，销毁归还的资源", p.name)
+	return p.factory.Destroy(item)
+}
+
+// Reset 重置提供者状态（在归还前调用）
+func (p *ProviderPool[T]) Reset(item T) error {
+	if resetter, ok := any(item).(interface{ Reset() error }); ok {
+		return resetter.Reset()
+	}
+	return nil
+}
+
+// Close 关闭资源池并销毁空闲的提供者，可重复调用；借出的提供者归还时销毁
+func (p *ProviderPool[T]) Close() {
+	p.cancel()
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.closed = true
+	close(p.items)
+	p.mu.Unlock()
+
+	for item := range p.items {
+		p.logger.Info("[Close] %s 资源池关闭，销毁资源", p.name)
+		p.factory.Destroy(item)
+		p.mu.Lock()
+		p.total--
+		p.mu.Unlock()
+	}
+}
+
+// Stats 获取资源池统计信息
+func (p *ProviderPool[T]) Stats() PoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stats := PoolStats{
+		Available: len(p.items),
+		Total:     p.total,
+		InUse:     p.total - len(p.items),
+		Min:       p.minSize,
+		Max:       p.maxSize,
+		Borrows:   p.borrows,
+		MaxWaitMs: float64(p.waitMax) / float64(time.Millisecond),
+	}
+	if p.borrows > 0 {
+		stats.AvgWaitMs = float64(p.waitTotal) / float64(p.borrows) / float64(time.Millisecond)
+	}
+	return stats
+}
+
+// borrowed 记录借出统计并通知补充协程
+func (p *ProviderPool[T]) borrowed(start time.Time) {
+	wait := time.Since(start)
+	p.mu.Lock()
+	p.borrows++
+	p.waitTotal += wait
+	p.waitMax = max(p.waitMax, wait)
+	p.mu.Unlock()
+
+	select {
+	case p.refill <- struct{}{}:
+	default:
+	}
+}
+
+// maintain 借出后或定期将空闲数量补充到 minSize
+func (p *ProviderPool[T]) maintain(checkInterval time.Duration) {
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-p.refill:
+		case <-ticker.C:
+		}
+		p.fill()
+	}
+}
+
+// fill 创建提供者直到空闲数量达到 minSize 或总数达到 maxSize，创建失败时等待下次补充
+func (p *ProviderPool[T]) fill() {
+	for {
+		p.mu.Lock()
+		if p.closed || len(p.items) >= p.minSize || p.total >= p.maxSize {
+			p.mu.Unlock()
+			return
+		}
+		p.total++
+		p.mu.Unlock()
+
+		item, err := p.create()
+		if err != nil {
+			p.logger.Error("%s 创建资源失败: %v", p.name, err)
+			p.mu.Lock()
+			p.total--
+			p.mu.Unlock()
+			return
+		}
+		if err := p.Put(item); err != nil {
+			p.logger.Warn("%s 销毁资源失败: %v", p.name, err)
+		}
+	}
+}
+
+// create 通过工厂创建提供者并转换为池的类型
+func (p *ProviderPool[T]) create() (T, error) {
+	var zero T
+	resource, err := p.factory.Create()
+	if err != nil {
+		return zero, err
+	}
+	item, ok := resource.(T)
+	if !ok {
+		p.factory.Destroy(resource)
+		return zero, fmt.Errorf("%s 工厂创建的资源类型 %T 不匹配", p.name, resource)
+	}
+	return item, nil
+}
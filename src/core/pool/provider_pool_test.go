@@ -0,0 +1,187 @@
+package pool
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"angrymiao-ai-server/src/configs"
+	"angrymiao-ai-server/src/core/providers"
+	"angrymiao-ai-server/src/core/utils"
+)
+
+// mockTTS 只用于满足 providers.TTSProvider 类型
+type mockTTS struct {
+	providers.TTSProvider
+	id int
+}
+
+type mockLLM struct {
+	providers.LLMProvider
+}
+
+// typedFactory 创建指定类型的资源并记录数量
+type typedFactory struct {
+	mu        sync.Mutex
+	created   int
+	destroyed int
+	newItem   func(id int) interface{}
+}
+
+func (f *typedFactory) Create() (interface{}, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.created++
+	return f.newItem(f.created), nil
+}
+
+func (f *typedFactory) Destroy(resource interface{}) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.destroyed++
+	return nil
+}
+
+func (f *typedFactory) counts() (created, destroyed int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.created, f.destroyed
+}
+
+func newTestLogger(t *testing.T) *utils.Logger {
+	logger, err := utils.NewLogger(&utils.LogCfg{LogLevel: "ERROR", LogDir: t.TempDir(), LogFile: "test.log"})
+	if err != nil {
+		t.Fatalf("创建日志失败: %v", err)
+	}
+	return logger
+}
+
+func waitForStats(t *testing.T, p *ProviderPool[providers.TTSProvider], cond func(PoolStats) bool) PoolStats {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		stats := p.Stats()
+		if cond(stats) {
+			return stats
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("等待资源池状态超时: %+v", stats)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestProviderPoolTTSMinAndRefill(t *testing.T) {
+	cfg := configs.PoolConfig{PoolMinSize: 1, PoolMaxSize: 10, TTSMin: 3}
+	poolConfig := providerPoolConfig(PoolConfig{MinSize: cfg.PoolMinSize, MaxSize: cfg.PoolMaxSize, CheckInterval: time.Hour}, cfg.TTSMin, cfg.TTSMax)
+	factory := &typedFactory{newItem: func(id int) interface{} { return &mockTTS{id: id} }}
+	p, err := NewProviderPool[providers.TTSProvider]("ttsPool", factory, poolConfig, newTestLogger(t))
+	if err != nil {
+		t.Fatalf("创建资源池失败: %v", err)
+	}
+	defer p.Close()
+
+	// tts_min 覆盖全局的 pool_min_size
+	if created, _ := factory.counts(); created != 3 {
+		t.Fatalf("预创建数量 = %d, 期望 3", created)
+	}
+	if stats := p.Stats(); stats.Available != 3 || stats.Min != 3 || stats.Max != 10 {
+		t.Errorf("初始状态不符合预期: %+v", stats)
+	}
+
+	tts, err := p.Get(context.Background())
+	if err != nil {
+		t.Fatalf("获取TTS失败: %v", err)
+	}
+
+	// 借出后后台协程补充空闲数量
+	stats := waitForStats(t, p, func(s PoolStats) bool { return s.Available == 3 })
+	if stats.Total != 4 || stats.InUse != 1 || stats.Borrows != 1 {
+		t.Errorf("补充后的状态不符合预期: %+v", stats)
+	}
+
+	if err := p.Put(tts); err != nil {
+		t.Fatalf("归还TTS失败: %v", err)
+	}
+	if stats := p.Stats(); stats.Available != 4 || stats.InUse != 0 {
+		t.Errorf("归还后的状态不符合预期: %+v", stats)
+	}
+
+	p.Close()
+	if created, destroyed := factory.counts(); created != destroyed {
+		t.Errorf("关闭后销毁数量 = %d, 创建数量 = %d", destroyed, created)
+	}
+}
+
+func TestProviderPoolWaitsForReturnAtMax(t *testing.T) {
+	factory := &typedFactory{newItem: func(id int) interface{} { return &mockTTS{id: id} }}
+	p, err := NewProviderPool[providers.TTSProvider]("ttsPool", factory, PoolConfig{MinSize: 1, MaxSize: 1, CheckInterval: time.Hour}, newTestLogger(t))
+	if err != nil {
+		t.Fatalf("创建资源池失败: %v", err)
+	}
+	defer p.Close()
+
+	first, err := p.Get(context.Background())
+	if err != nil {
+		t.Fatalf("获取TTS失败: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	if _, err := p.Get(ctx); err == nil {
+		t.Fatalf("达到最大容量时应等待超时")
+	}
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		p.Put(first)
+	}()
+	second, err := p.Get(context.Background())
+	if err != nil {
+		t.Fatalf("等待归还后获取失败: %v", err)
+	}
+	if second != first {
+		t.Errorf("应获取到归还的提供者")
+	}
+	if stats := p.Stats(); stats.Borrows != 2 || stats.MaxWaitMs < 10 {
+		t.Errorf("等待统计不符合预期: %+v", stats)
+	}
+}
+
+func TestGetProviderSetReturnsOnFailure(t *testing.T) {
+	logger := newTestLogger(t)
+	ttsFactory := &typedFactory{newItem: func(id int) interface{} { return &mockTTS{id: id} }}
+	ttsPool, err := NewProviderPool[providers.TTSProvider]("ttsPool", ttsFactory, PoolConfig{MinSize: 1, MaxSize: 1, CheckInterval: time.Hour}, logger)
+	if err != nil {
+		t.Fatalf("创建TTS资源池失败: %v", err)
+	}
+	defer ttsPool.Close()
+	llmFactory := &typedFactory{newItem: func(int) interface{} { return &mockLLM{} }}
+	llmPool, err := NewProviderPool[providers.LLMProvider]("llmPool", llmFactory, PoolConfig{MinSize: 0, MaxSize: 1, CheckInterval: time.Hour}, logger)
+	if err != nil {
+		t.Fatalf("创建LLM资源池失败: %v", err)
+	}
+	defer llmPool.Close()
+
+	pm := &PoolManager{ttsPool: ttsPool, llmPool: llmPool, logger: logger}
+	set, err := pm.GetProviderSet()
+	if err != nil {
+		t.Fatalf("获取提供者集合失败: %v", err)
+	}
+	if set.TTS == nil || set.LLM == nil {
+		t.Fatalf("提供者集合不完整: %+v", set)
+	}
+	if err := pm.ReturnProviderSet(set); err != nil {
+		t.Fatalf("归还提供者集合失败: %v", err)
+	}
+
+	// LLM 获取失败时，已获取的TTS归还到池中
+	llmPool.Close()
+	if _, err := pm.GetProviderSet(); err == nil {
+		t.Fatalf("LLM获取失败时应返回错误")
+	}
+	if stats := ttsPool.Stats(); stats.Available != 1 || stats.InUse != 0 {
+		t.Errorf("TTS应归还到池中: %+v", stats)
+	}
+}
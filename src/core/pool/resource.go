@@ -31,6 +31,7 @@ type ResourcePool struct {
 	minSize     int
 	maxSize     int
 	currentSize int
+	closed      bool // 关闭后不再接收资源，由 mutex 保护
 	mutex       sync.RWMutex
 	logger      *utils.Logger
 	ctx         context.Context
@@ -80,7 +81,10 @@ func NewResourcePool(
 // Get 获取资源
 func (p *ResourcePool) Get() (interface{}, error) {
 	select {
-	case resource := <-p.pool:
+	case resource, ok := <-p.pool:
+		if !ok {
+			return nil, fmt.Errorf("%s 资源池已关闭", p.poolName)
+		}
 		p.mutex.Lock()
 		p.currentSize--
 		p.mutex.Unlock()
@@ -149,24 +153,43 @@ func (p *ResourcePool) refillPool(refillSize int) {
 				continue
 			}
 
-			select {
-			case p.pool <- resource:
-				p.mutex.Lock()
-				p.currentSize++
-				p.mutex.Unlock()
-			default:
-				// 池满了，销毁资源
-				p.logger.Warn("%s 资源池已满，销毁新创建的资源", p.poolName)
+			if !p.offer(resource) {
+				// 池满了或已关闭，销毁资源
+				p.logger.Warn("%s 资源池已满或已关闭，销毁新创建的资源", p.poolName)
 				p.factory.Destroy(resource)
 			}
 		}
 	}
 }
 
-// Close 关闭资源池
+// offer 尝试将资源放入池中，池已满或已关闭时返回false
+// 持有 mutex 期间发送，保证不会向已关闭的通道发送
+func (p *ResourcePool) offer(resource interface{}) bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.closed {
+		return false
+	}
+	select {
+	case p.pool <- resource:
+		p.currentSize++
+		return true
+	default:
+		return false
+	}
+}
+
+// Close 关闭资源池，可重复调用；关闭后归还的资源直接销毁
 func (p *ResourcePool) Close() {
 	p.cancel()
+	p.mutex.Lock()
+	if p.closed {
+		p.mutex.Unlock()
+		return
+	}
+	p.closed = true
 	close(p.pool)
+	p.mutex.Unlock()
 
 	// 销毁剩余资源
 	for resource := range p.pool {
@@ -181,33 +204,12 @@ func (p *ResourcePool) Put(resource interface{}) error {
 		return fmt.Errorf("%s 不能将nil资源归还到池中", p.poolName)
 	}
 
-	// 检查池是否已关闭
-	select {
-	case <-p.ctx.Done():
-		p.logger.Warn("[Put] %s 资源池已关闭，销毁归还的资源", p.poolName)
-		return p.factory.Destroy(resource)
-	default:
-	}
-
-	// 设置归还超时
-	timeout := time.NewTimer(5 * time.Second)
-	defer timeout.Stop()
-
-	select {
-	case p.pool <- resource:
-		p.mutex.Lock()
-		p.currentSize++
-		p.mutex.Unlock()
-		return nil
-	case <-timeout.C:
-		// 超时后销毁资源而不是阻塞
-		p.logger.Warn("[Put] %s 资源归还超时，销毁资源", p.poolName)
-		return p.factory.Destroy(resource)
-	default:
-		// 池已满，销毁多余的资源
-		p.logger.Info("[Put] %s 资源池已满，销毁归还的资源", p.poolName)
+	// 资源池配置热更新后旧池会被关闭，仍在使用的资源归还时直接销毁
+	if !p.offer(resource) {
+		p.logger.Info("[Put] %s 资源池已满或已关闭，销毁归还的资源", p.poolName)
 		return p.factory.Destroy(resource)
 	}
+	return nil
 }
 
 // Reset 重置资源状态（在归还前调用）
@@ -0,0 +1,54 @@
+package pool
+
+import (
+	"testing"
+	"time"
+
+	"angrymiao-ai-server/src/core/utils"
+)
+
+type countingFactory struct {
+	created   int
+	destroyed int
+}
+
+func (f *countingFactory) Create() (interface{}, error) {
+	f.created++
+	return f.created, nil
+}
+
+func (f *countingFactory) Destroy(resource interface{}) error {
+	f.destroyed++
+	return nil
+}
+
+func TestResourcePoolPutAfterClose(t *testing.T) {
+	logger, err := utils.NewLogger(&utils.LogCfg{LogLevel: "ERROR", LogDir: t.TempDir(), LogFile: "test.log"})
+	if err != nil {
+		t.Fatalf("创建日志失败: %v", err)
+	}
+	factory := &countingFactory{}
+	p, err := NewResourcePool("test", factory, PoolConfig{MinSize: 1, MaxSize: 2, RefillSize: 1, CheckInterval: time.Hour}, logger)
+	if err != nil {
+		t.Fatalf("创建资源池失败: %v", err)
+	}
+
+	// 连接持有的资源在资源池关闭后归还，应被销毁而不是向已关闭的通道发送
+	resource, err := p.Get()
+	if err != nil {
+		t.Fatalf("获取资源失败: %v", err)
+	}
+	p.Close()
+	if err := p.Put(resource); err != nil {
+		t.Fatalf("归还资源失败: %v", err)
+	}
+	p.refillPool(2)
+	p.Close()
+
+	if factory.destroyed != factory.created {
+		t.Errorf("销毁数量 = %d, 创建数量 = %d", factory.destroyed, factory.created)
+	}
+	if _, err := p.Get(); err == nil {
+		t.Errorf("关闭后获取资源应返回错误")
+	}
+}
@@ -0,0 +1,79 @@
+package pool
+
+import (
+	"fmt"
+	"sync"
+
+	"angrymiao-ai-server/src/configs"
+	"angrymiao-ai-server/src/core/utils"
+)
+
+// TenantConfigStore 读取租户生效的配置
+type TenantConfigStore interface {
+	ForTenant(tenantID string) (*configs.Config, error)
+}
+
+// tenantPool 单个租户的配置与资源池管理器
+type tenantPool struct {
+	config  *configs.Config
+	manager *PoolManager
+}
+
+// TenantPools 按租户ID保存资源池管理器，租户之间不共享提供者实例
+type TenantPools struct {
+	mu      sync.Mutex
+	store   TenantConfigStore
+	logger  *utils.Logger
+	tenants map[string]*tenantPool
+	closed  bool
+}
+
+// NewTenantPools 创建租户资源池集合，租户的资源池在首次使用时按 store 中的配置创建
+func NewTenantPools(store TenantConfigStore, logger *utils.Logger) *TenantPools {
+	return &TenantPools{store: store, logger: logger, tenants: make(map[string]*tenantPool)}
+}
+
+// Get 获取租户的配置与资源池管理器，不存在时创建
+// 创建过程在锁内进行，同一租户的并发连接只会创建一个资源池管理器
+func (p *TenantPools) Get(tenantID string) (*configs.Config, *PoolManager, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return nil, nil, fmt.Errorf("租户资源池已关闭")
+	}
+	if tp, ok := p.tenants[tenantID]; ok {
+		return tp.config, tp.manager, nil
+	}
+
+	config, err := p.store.ForTenant(tenantID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("读取租户%s的配置失败: %v", tenantID, err)
+	}
+	manager, err := NewPoolManager(config, p.logger)
+	if err != nil {
+		return nil, nil, fmt.Errorf("创建租户%s的资源池失败: %v", tenantID, err)
+	}
+	p.tenants[tenantID] = &tenantPool{config: config, manager: manager}
+	p.logger.Info("为租户%s创建资源池，LLM=%s", tenantID, config.SelectedModule["LLM"])
+	return config, manager, nil
+}
+
+// Len 已创建资源池的租户数量
+func (p *TenantPools) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.tenants)
+}
+
+// Close 关闭所有租户的资源池，已建立连接断开时归还的资源会被直接销毁
+func (p *TenantPools) Close() {
+	p.mu.Lock()
+	tenants := p.tenants
+	p.tenants = make(map[string]*tenantPool)
+	p.closed = true
+	p.mu.Unlock()
+
+	for _, tp := range tenants {
+		tp.manager.Close()
+	}
+}
@@ -0,0 +1,90 @@
+package pool
+
+import (
+	"testing"
+
+	"angrymiao-ai-server/src/configs"
+	"angrymiao-ai-server/src/core/providers"
+	"angrymiao-ai-server/src/core/providers/llm"
+)
+
+// tenantLLM 记录创建它的模型名
+type tenantLLM struct {
+	providers.LLMProvider
+	model string
+}
+
+func (p *tenantLLM) Initialize() error { return nil }
+func (p *tenantLLM) Cleanup() error    { return nil }
+
+func init() {
+	llm.Register("tenant_test", func(config *llm.Config) (llm.Provider, error) {
+		return &tenantLLM{model: config.ModelName}, nil
+	})
+}
+
+func newTenantTestConfig() *configs.Config {
+	return &configs.Config{
+		SelectedModule: map[string]string{"LLM": "QwenLLM"},
+		PoolConfig:     configs.PoolConfig{PoolMinSize: 1, PoolMaxSize: 2},
+		McpPoolConfig:  configs.McpPoolConfig{PoolMaxSize: 1},
+		LLM: map[string]configs.LLMConfig{
+			"QwenLLM": {Type: "tenant_test", ModelName: "qwen"},
+		},
+		Tenants: map[string]configs.TenantProviderConfig{
+			"tenant_b": {
+				SelectedModule: map[string]string{"LLM": "GlmLLM"},
+				LLM:            map[string]configs.LLMConfig{"GlmLLM": {Type: "tenant_test", ModelName: "glm"}},
+			},
+		},
+	}
+}
+
+func TestTenantPoolsIsolateTenantProviders(t *testing.T) {
+	global := newTenantTestConfig()
+	pools := NewTenantPools(global, newTestLogger(t))
+	t.Cleanup(pools.Close)
+
+	cfgA, pmA, err := pools.Get("tenant_a")
+	if err != nil {
+		t.Fatalf("获取租户A资源池失败: %v", err)
+	}
+	cfgB, pmB, err := pools.Get("tenant_b")
+	if err != nil {
+		t.Fatalf("获取租户B资源池失败: %v", err)
+	}
+	if pmA == pmB {
+		t.Fatal("不同租户不应共享资源池管理器")
+	}
+	if cfgA.SelectedModule["LLM"] != "QwenLLM" || cfgB.SelectedModule["LLM"] != "GlmLLM" {
+		t.Errorf("租户LLM配置 = %s/%s", cfgA.SelectedModule["LLM"], cfgB.SelectedModule["LLM"])
+	}
+	if _, ok := global.LLM["GlmLLM"]; ok || global.SelectedModule["LLM"] != "QwenLLM" {
+		t.Errorf("合并租户配置不应修改全局配置: %+v", global.SelectedModule)
+	}
+
+	setA, err := pmA.GetProviderSet()
+	if err != nil {
+		t.Fatalf("获取租户A提供者失败: %v", err)
+	}
+	setB, err := pmB.GetProviderSet()
+	if err != nil {
+		t.Fatalf("获取租户B提供者失败: %v", err)
+	}
+	if got := setA.LLM.(*tenantLLM).model; got != "qwen" {
+		t.Errorf("租户A的LLM = %s, 期望 qwen", got)
+	}
+	if got := setB.LLM.(*tenantLLM).model; got != "glm" {
+		t.Errorf("租户B的LLM = %s, 期望 glm", got)
+	}
+	pmA.ReturnProviderSet(setA)
+	pmB.ReturnProviderSet(setB)
+
+	if _, again, _ := pools.Get("tenant_a"); again != pmA || pools.Len() != 2 {
+		t.Errorf("同一租户应复用资源池管理器, 租户数 = %d", pools.Len())
+	}
+	pools.Close()
+	if _, _, err := pools.Get("tenant_a"); err == nil {
+		t.Error("关闭后获取租户资源池应返回错误")
+	}
+}
@@ -0,0 +1,333 @@
+package pool
+
+import (
+	"angrymiao-ai-server/src/configs"
+	"angrymiao-ai-server/src/configs/cache"
+	"angrymiao-ai-server/src/configs/database"
+	"angrymiao-ai-server/src/core/providers"
+	"angrymiao-ai-server/src/core/providers/vlllm"
+	"angrymiao-ai-server/src/core/utils"
+	"angrymiao-ai-server/src/models"
+	"container/list"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// maxUserPools 最多保留的用户私有资源池数量，超出时淘汰最久未使用的
+	maxUserPools = 100
+	// 用户私有资源池的最小、最大数量
+	userPoolMinSize = 1
+	userPoolMaxSize = 3
+	// userConfigCacheTTL 用户提供者配置在Redis中的缓存时间
+	userConfigCacheTTL = 5 * time.Minute
+	// userConfigLoadTimeout 读取用户提供者配置的超时时间
+	userConfigLoadTimeout = 2 * time.Second
+)
+
+// UserProviderConfig 用户选择的提供者配置名，字段为空表示使用全局配置
+type UserProviderConfig struct {
+	ASR   string `json:"asr,omitempty"`
+	LLM   string `json:"llm,omitempty"`
+	TTS   string `json:"tts,omitempty"`
+	VLLLM string `json:"vlllm,omitempty"`
+}
+
+// IsEmpty 是否没有任何覆盖
+func (c UserProviderConfig) IsEmpty() bool {
+	return c == UserProviderConfig{}
+}
+
+// UserConfigLoader 读取用户的提供者配置
+type UserConfigLoader func(ctx context.Context, userID string) (UserProviderConfig, error)
+
+// userProviderConfigKey 用户提供者配置的缓存key
+func userProviderConfigKey(userID string) string {
+	return fmt.Sprintf("user_provider_config:%s", userID)
+}
+
+// loadUserProviderConfig 默认的用户配置读取：优先读取Redis缓存，未命中时查询 user_settings 并写入缓存
+func loadUserProviderConfig(ctx context.Context, userID string) (UserProviderConfig, error) {
+	var cfg UserProviderConfig
+	client := cache.GetRedis()
+	key := userProviderConfigKey(userID)
+	if client != nil {
+		if data, err := client.Get(ctx, key).Bytes(); err == nil && json.Unmarshal(data, &cfg) == nil {
+			return cfg, nil
+		}
+	}
+	if database.DB == nil {
+		return cfg, nil
+	}
+
+	var settings []models.UserSetting
+	if err := database.DB.WithContext(ctx).Where("user_id = ?", userID).Limit(1).Find(&settings).Error; err != nil {
+		return cfg, fmt.Errorf("查询用户设置失败: %v", err)
+	}
+	if len(settings) > 0 {
+		cfg = UserProviderConfig{
+			ASR:   settings[0].SelectedASR,
+			LLM:   settings[0].SelectedLLM,
+			TTS:   settings[0].SelectedTTS,
+			VLLLM: settings[0].SelectedVLLLM,
+		}
+	}
+	if client != nil {
+		if data, err := json.Marshal(cfg); err == nil {
+			client.Set(ctx, key, data, userConfigCacheTTL)
+		}
+	}
+	return cfg, nil
+}
+
+// newUserFactory 创建用户私有资源池使用的工厂，测试中可替换
+var newUserFactory = func(kind, name string, config *configs.Config, logger *utils.Logger) ResourceFactory {
+	switch kind {
+	case "asr":
+		return NewASRFactory(name, config, logger)
+	case "llm":
+		return NewLLMFactory(name, config, logger)
+	case "tts":
+		return NewTTSFactory(name, config, logger)
+	case "vlllm":
+		return NewVLLLMFactory(name, config, logger)
+	}
+	return nil
+}
+
+// userPoolSlot 单个用户的私有资源池，只包含与全局配置不同的提供者，其余提供者仍从全局资源池获取
+type userPoolSlot struct {
+	userID    string
+	config    UserProviderConfig // 生效的覆盖配置
+	asrPool   *ProviderPool[providers.ASRProvider]
+	llmPool   *ProviderPool[providers.LLMProvider]
+	ttsPool   *ProviderPool[providers.TTSProvider]
+	vlllmPool *ProviderPool[*vlllm.Provider]
+}
+
+// close 关闭用户的资源池，借出的提供者归还时销毁
+func (s *userPoolSlot) close() {
+	if s.asrPool != nil {
+		s.asrPool.Close()
+	}
+	if s.llmPool != nil {
+		s.llmPool.Close()
+	}
+	if s.ttsPool != nil {
+		s.ttsPool.Close()
+	}
+	if s.vlllmPool != nil {
+		s.vlllmPool.Close()
+	}
+}
+
+// newUserPool 为单个提供者创建私有资源池，工厂不存在时返回错误
+func newUserPool[T any](kind, name, userID string, config *configs.Config, logger *utils.Logger) (*ProviderPool[T], error) {
+	factory := newUserFactory(kind, name, config, logger)
+	if factory == nil {
+		return nil, fmt.Errorf("创建%s工厂失败: 找不到配置 %s", kind, name)
+	}
+	poolConfig := PoolConfig{MinSize: userPoolMinSize, MaxSize: userPoolMaxSize, CheckInterval: time.Minute}
+	return NewProviderPool[T](fmt.Sprintf("%sPool[%s]", kind, userID), factory, poolConfig, logger)
+}
+
+// newUserPoolSlot 按覆盖配置创建用户的私有资源池
+func newUserPoolSlot(userID string, cfg UserProviderConfig, config *configs.Config, logger *utils.Logger) (*userPoolSlot, error) {
+	slot := &userPoolSlot{userID: userID, config: cfg}
+	var err error
+	if cfg.ASR != "" {
+		slot.asrPool, err = newUserPool[providers.ASRProvider]("asr", cfg.ASR, userID, config, logger)
+	}
+	if err == nil && cfg.LLM != "" {
+		slot.llmPool, err = newUserPool[providers.LLMProvider]("llm", cfg.LLM, userID, config, logger)
+	}
+	if err == nil && cfg.TTS != "" {
+		slot.ttsPool, err = newUserPool[providers.TTSProvider]("tts", cfg.TTS, userID, config, logger)
+	}
+	if err == nil && cfg.VLLLM != "" {
+		slot.vlllmPool, err = newUserPool[*vlllm.Provider]("vlllm", cfg.VLLLM, userID, config, logger)
+	}
+	if err != nil {
+		slot.close()
+		return nil, err
+	}
+	return slot, nil
+}
+
+// UserProviderPool 按用户ID保存私有资源池，超出容量时淘汰最久未使用的
+type UserProviderPool struct {
+	mu    sync.Mutex
+	max   int
+	order *list.List               // 元素为 *userPoolSlot，前端为最近使用
+	slots map[string]*list.Element // userID -> order 中的元素
+}
+
+// NewUserProviderPool 创建用户私有资源池集合，maxSize 为最多保留的用户数
+func NewUserProviderPool(maxSize int) *UserProviderPool {
+	if maxSize <= 0 {
+		maxSize = maxUserPools
+	}
+	return &UserProviderPool{max: maxSize, order: list.New(), slots: make(map[string]*list.Element)}
+}
+
+// get 返回与覆盖配置一致的用户资源池并标记为最近使用，不存在或配置已变化时返回nil
+func (p *UserProviderPool) get(userID string, cfg UserProviderConfig) *userPoolSlot {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	elem, ok := p.slots[userID]
+	if !ok {
+		return nil
+	}
+	slot := elem.Value.(*userPoolSlot)
+	if slot.config != cfg {
+		return nil
+	}
+	p.order.MoveToFront(elem)
+	return slot
+}
+
+// add 保存用户资源池，返回实际生效的资源池
+// 并发创建了相同配置的资源池时保留已有的；替换旧配置与淘汰的资源池在锁外关闭
+func (p *UserProviderPool) add(slot *userPoolSlot) *userPoolSlot {
+	var removed []*userPoolSlot
+	p.mu.Lock()
+	if elem, ok := p.slots[slot.userID]; ok {
+		existing := elem.Value.(*userPoolSlot)
+		if existing.config == slot.config {
+			p.order.MoveToFront(elem)
+			p.mu.Unlock()
+			slot.close()
+			return existing
+		}
+		p.order.Remove(elem)
+		removed = append(removed, existing)
+	}
+	p.slots[slot.userID] = p.order.PushFront(slot)
+	for p.order.Len() > p.max {
+		oldest := p.order.Back()
+		evicted := p.order.Remove(oldest).(*userPoolSlot)
+		delete(p.slots, evicted.userID)
+		removed = append(removed, evicted)
+	}
+	p.mu.Unlock()
+
+	for _, s := range removed {
+		s.close()
+	}
+	return slot
+}
+
+// remove 移除并关闭用户的资源池，用户恢复使用全局配置时调用
+func (p *UserProviderPool) remove(userID string) {
+	p.mu.Lock()
+	elem, ok := p.slots[userID]
+	if ok {
+		p.order.Remove(elem)
+		delete(p.slots, userID)
+	}
+	p.mu.Unlock()
+	if ok {
+		elem.Value.(*userPoolSlot).close()
+	}
+}
+
+// Len 当前保留的用户资源池数量
+func (p *UserProviderPool) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.order.Len()
+}
+
+// Close 关闭所有用户资源池
+func (p *UserProviderPool) Close() {
+	p.mu.Lock()
+	slots := make([]*userPoolSlot, 0, p.order.Len())
+	for elem := p.order.Front(); elem != nil; elem = elem.Next() {
+		slots = append(slots, elem.Value.(*userPoolSlot))
+	}
+	p.order.Init()
+	p.slots = make(map[string]*list.Element)
+	p.mu.Unlock()
+
+	for _, s := range slots {
+		s.close()
+	}
+}
+
+// userOverrides 读取用户的提供者配置，只保留与全局配置不同且存在对应配置的项
+func (pm *PoolManager) userOverrides(userID string) UserProviderConfig {
+	var overrides UserProviderConfig
+	if pm.userPools == nil || pm.config == nil || userID == "" {
+		return overrides
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), userConfigLoadTimeout)
+	defer cancel()
+	cfg, err := pm.userConfigLoader(ctx, userID)
+	if err != nil {
+		pm.logger.Warn("读取用户%s的提供者配置失败，使用全局配置: %v", userID, err)
+		return overrides
+	}
+
+	selected := pm.config.SelectedModule
+	if _, ok := pm.config.ASR.Providers[cfg.ASR]; ok && cfg.ASR != selected["ASR"] {
+		overrides.ASR = cfg.ASR
+	}
+	if _, ok := pm.config.LLM[cfg.LLM]; ok && cfg.LLM != selected["LLM"] {
+		overrides.LLM = cfg.LLM
+	}
+	if _, ok := pm.config.TTS[cfg.TTS]; ok && cfg.TTS != selected["TTS"] {
+		overrides.TTS = cfg.TTS
+	}
+	if _, ok := pm.config.VLLLM[cfg.VLLLM]; ok && cfg.VLLLM != selected["VLLLM"] {
+		overrides.VLLLM = cfg.VLLLM
+	}
+	return overrides
+}
+
+// UsesPrivateProviders 开启 use_private_config 时用户是否选择了与全局配置不同的提供者
+func (pm *PoolManager) UsesPrivateProviders(userID string) bool {
+	return !pm.userOverrides(userID).IsEmpty()
+}
+
+// userSlot 获取用户的私有资源池，用户使用全局配置时返回nil
+func (pm *PoolManager) userSlot(userID string) (*userPoolSlot, error) {
+	overrides := pm.userOverrides(userID)
+	if overrides.IsEmpty() {
+		if pm.userPools != nil && userID != "" {
+			pm.userPools.remove(userID)
+		}
+		return nil, nil
+	}
+	if slot := pm.userPools.get(userID, overrides); slot != nil {
+		return slot, nil
+	}
+	slot, err := newUserPoolSlot(userID, overrides, pm.config, pm.logger)
+	if err != nil {
+		return nil, err
+	}
+	pm.logger.Info("为用户%s创建私有资源池: %+v", userID, overrides)
+	return pm.userPools.add(slot), nil
+}
+
+// GetProviderSetForUser 获取用户的一套提供者
+// 开启 use_private_config 且用户选择了与全局不同的提供者时，这些提供者从用户的私有资源池获取，其余从全局资源池获取；
+// 否则与 GetProviderSet 相同
+func (pm *PoolManager) GetProviderSetForUser(userID string) (*ProviderSet, error) {
+	slot, err := pm.userSlot(userID)
+	if err != nil {
+		pm.logger.Warn("创建用户%s的私有资源池失败，使用全局资源池: %v", userID, err)
+		slot = nil
+	}
+	return pm.getProviderSet(slot)
+}
+
+// UserPoolCount 当前保留的用户私有资源池数量
+func (pm *PoolManager) UserPoolCount() int {
+	if pm.userPools == nil {
+		return 0
+	}
+	return pm.userPools.Len()
+}
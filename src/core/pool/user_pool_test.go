@@ -0,0 +1,161 @@
+package pool
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"angrymiao-ai-server/src/configs"
+	"angrymiao-ai-server/src/core/providers"
+	"angrymiao-ai-server/src/core/utils"
+)
+
+// namedLLM 记录创建它的LLM配置名
+type namedLLM struct {
+	providers.LLMProvider
+	config string
+}
+
+// newPrivateConfigManager 创建全局LLM为 global 的资源池管理器，用户配置来自 settings
+func newPrivateConfigManager(t *testing.T, settings map[string]UserProviderConfig, maxUserPools int) *PoolManager {
+	t.Helper()
+	logger := newTestLogger(t)
+	original := newUserFactory
+	newUserFactory = func(kind, name string, config *configs.Config, logger *utils.Logger) ResourceFactory {
+		return &typedFactory{newItem: func(int) interface{} { return &namedLLM{config: name} }}
+	}
+	t.Cleanup(func() { newUserFactory = original })
+
+	globalFactory := &typedFactory{newItem: func(int) interface{} { return &namedLLM{config: "global"} }}
+	llmPool, err := NewProviderPool[providers.LLMProvider]("llmPool", globalFactory, PoolConfig{MinSize: 1, MaxSize: 5, CheckInterval: time.Hour}, logger)
+	if err != nil {
+		t.Fatalf("创建LLM资源池失败: %v", err)
+	}
+
+	pm := &PoolManager{
+		llmPool: llmPool,
+		logger:  logger,
+		config: &configs.Config{
+			SelectedModule: map[string]string{"LLM": "global"},
+			LLM:            map[string]configs.LLMConfig{"global": {}, "openai": {}, "ollama": {}},
+		},
+		userPools: NewUserProviderPool(maxUserPools),
+		userConfigLoader: func(ctx context.Context, userID string) (UserProviderConfig, error) {
+			return settings[userID], nil
+		},
+	}
+	t.Cleanup(pm.Close)
+	return pm
+}
+
+func llmConfigName(t *testing.T, set *ProviderSet) string {
+	t.Helper()
+	llm, ok := set.LLM.(*namedLLM)
+	if !ok {
+		t.Fatalf("LLM类型 = %T", set.LLM)
+	}
+	return llm.config
+}
+
+func TestGetProviderSetForUserIsolatesUserLLMConfig(t *testing.T) {
+	pm := newPrivateConfigManager(t, map[string]UserProviderConfig{
+		"1": {LLM: "openai"},
+		"2": {LLM: "ollama"},
+		"3": {LLM: "global"},  // 与全局配置相同
+		"4": {LLM: "unknown"}, // 不存在的配置
+	}, maxUserPools)
+
+	first, err := pm.GetProviderSetForUser("1")
+	if err != nil {
+		t.Fatalf("获取用户1的提供者失败: %v", err)
+	}
+	second, err := pm.GetProviderSetForUser("2")
+	if err != nil {
+		t.Fatalf("获取用户2的提供者失败: %v", err)
+	}
+	if got := llmConfigName(t, first); got != "openai" {
+		t.Errorf("用户1的LLM = %s, 期望 openai", got)
+	}
+	if got := llmConfigName(t, second); got != "ollama" {
+		t.Errorf("用户2的LLM = %s, 期望 ollama", got)
+	}
+	for _, userID := range []string{"3", "4", "5"} {
+		if pm.UsesPrivateProviders(userID) {
+			t.Errorf("用户%s应使用全局配置", userID)
+		}
+		set, err := pm.GetProviderSetForUser(userID)
+		if err != nil {
+			t.Fatalf("获取用户%s的提供者失败: %v", userID, err)
+		}
+		if got := llmConfigName(t, set); got != "global" {
+			t.Errorf("用户%s的LLM = %s, 期望 global", userID, got)
+		}
+		pm.ReturnProviderSet(set)
+	}
+	if count := pm.UserPoolCount(); count != 2 {
+		t.Errorf("用户资源池数量 = %d, 期望 2", count)
+	}
+
+	// 归还后回到各自的私有资源池，不进入全局资源池
+	globalBefore := pm.llmPool.Stats().Available
+	if err := pm.ReturnProviderSet(first); err != nil {
+		t.Fatalf("归还用户1的提供者失败: %v", err)
+	}
+	if got := pm.llmPool.Stats().Available; got != globalBefore {
+		t.Errorf("用户私有的LLM不应归还到全局资源池: %d -> %d", globalBefore, got)
+	}
+	slot := pm.userPools.get("1", UserProviderConfig{LLM: "openai"})
+	if slot == nil {
+		t.Fatalf("用户1的私有资源池不存在")
+	}
+	if stats := slot.llmPool.Stats(); stats.InUse != 0 || stats.Max != userPoolMaxSize || stats.Min != userPoolMinSize {
+		t.Errorf("用户1的私有资源池状态不符合预期: %+v", stats)
+	}
+
+	again, err := pm.GetProviderSetForUser("1")
+	if err != nil {
+		t.Fatalf("再次获取用户1的提供者失败: %v", err)
+	}
+	if got := llmConfigName(t, again); got != "openai" || slot.llmPool.Stats().Borrows != 2 {
+		t.Errorf("用户1应复用私有资源池, LLM = %s, 状态: %+v", got, slot.llmPool.Stats())
+	}
+	pm.ReturnProviderSet(again)
+	pm.ReturnProviderSet(second)
+}
+
+func TestUserProviderPoolEvictsLeastRecentlyUsed(t *testing.T) {
+	pm := newPrivateConfigManager(t, map[string]UserProviderConfig{
+		"1": {LLM: "openai"},
+		"2": {LLM: "ollama"},
+		"3": {LLM: "openai"},
+	}, 2)
+
+	for _, userID := range []string{"1", "2"} {
+		set, err := pm.GetProviderSetForUser(userID)
+		if err != nil {
+			t.Fatalf("获取用户%s的提供者失败: %v", userID, err)
+		}
+		pm.ReturnProviderSet(set)
+	}
+	evicted := pm.userPools.get("2", UserProviderConfig{LLM: "ollama"})
+	// 访问用户1后，用户2成为最久未使用的
+	if pm.userPools.get("1", UserProviderConfig{LLM: "openai"}) == nil {
+		t.Fatalf("用户1的私有资源池不存在")
+	}
+
+	set, err := pm.GetProviderSetForUser("3")
+	if err != nil {
+		t.Fatalf("获取用户3的提供者失败: %v", err)
+	}
+	defer pm.ReturnProviderSet(set)
+
+	if count := pm.UserPoolCount(); count != 2 {
+		t.Errorf("用户资源池数量 = %d, 期望 2", count)
+	}
+	if pm.userPools.get("2", UserProviderConfig{LLM: "ollama"}) != nil {
+		t.Errorf("用户2的私有资源池应被淘汰")
+	}
+	if stats := evicted.llmPool.Stats(); stats.Total != 0 {
+		t.Errorf("淘汰的资源池应关闭并销毁资源: %+v", stats)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"angrymiao-ai-server/src/core/utils"
 	"bytes"
 	"fmt"
+	"sync"
 	"time"
 )
 
@@ -47,6 +48,9 @@ type BaseProvider struct {
 
 	UserPreferences map[string]interface{}
 
+	langMu   sync.RWMutex
+	language string // 对话中指定的识别语言，为空时使用配置默认值
+
 	listener providers.AsrEventListener
 }
 
@@ -91,6 +95,21 @@ func (p *BaseProvider) SetUserPreferences(preferences map[string]interface{}) er
 	return nil
 }
 
+// SetLanguage 设置期望识别的语言，下次建立识别会话时生效
+func (p *BaseProvider) SetLanguage(lang string) error {
+	p.langMu.Lock()
+	defer p.langMu.Unlock()
+	p.language = lang
+	return nil
+}
+
+// Language 获取对话中指定的识别语言，未指定时返回空字符串
+func (p *BaseProvider) Language() string {
+	p.langMu.RLock()
+	defer p.langMu.RUnlock()
+	return p.language
+}
+
 // Config 获取配置
 func (p *BaseProvider) Config() *Config {
 	return p.config
@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"angrymiao-ai-server/src/core/providers/asr"
+	"angrymiao-ai-server/src/core/types"
 	"angrymiao-ai-server/src/core/utils"
 
 	"github.com/gorilla/websocket"
@@ -87,6 +88,14 @@ func NewProvider(config *asr.Config, deleteFile bool, logger *utils.Logger) (*Pr
 	return provider, nil
 }
 
+// requestLanguage 对话中指定了语言时优先使用，否则使用配置的语言
+func (p *Provider) requestLanguage() string {
+	if lang := p.Language(); lang != "" {
+		return lang
+	}
+	return p.language
+}
+
 // Transcribe implements the asr.Provider interface transcription method
 func (p *Provider) Transcribe(ctx context.Context, audioData []byte) (string, error) {
 	if p.isStreaming {
@@ -176,7 +185,7 @@ func (p *Provider) StartStreaming(ctx context.Context) error {
 
 	// Add query parameters
 	queryParams := fmt.Sprintf("?language=%s&sample_rate=%v&encoding=%v",
-		p.language, 16000, "linear16")
+		p.requestLanguage(), 16000, "linear16")
 
 	headers := http.Header{
 		"Authorization": []string{"token " + p.apiKey},
@@ -287,13 +296,17 @@ func (p *Provider) ReadMessage() {
 								p.connMutex.Unlock()
 
 								if listener := p.BaseProvider.GetListener(); listener != nil {
+									confidence := float32(1)
 									if p.result == "" && p.SilenceTime() > 30*time.Second {
 										p.BaseProvider.SilenceCount += 1
 										p.result = "U r not listen to me!"
 									} else if p.result != "" {
 										p.BaseProvider.SilenceCount = 0
+										if value, ok := firstAlt["confidence"].(float64); ok {
+											confidence = float32(value)
+										}
 									}
-									if finished := listener.OnAsrResult(p.result, true); finished {
+									if finished := listener.OnAsrResult(p.result, true, confidence); finished {
 										return
 									}
 								}
@@ -427,3 +440,10 @@ func init() {
 		return NewProvider(config, deleteFile, logger)
 	})
 }
+
+// Capabilities Deepgram ASR通过WebSocket流式识别
+func (p *Provider) Capabilities() map[string]bool {
+	return map[string]bool{
+		types.CapabilityStreaming: true,
+	}
+}
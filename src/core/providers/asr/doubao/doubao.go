@@ -15,6 +15,7 @@ import (
 	"time"
 
 	"angrymiao-ai-server/src/core/providers/asr"
+	"angrymiao-ai-server/src/core/types"
 	"angrymiao-ai-server/src/core/utils"
 
 	"github.com/gorilla/websocket"
@@ -187,6 +188,17 @@ func (p *Provider) generateHeader(messageType uint8, flags uint8, serializationM
 }
 
 // constructRequest 构造请求数据
+// doubaoLanguage 将语言代码转换为豆包ASR的语言参数，默认中文
+func doubaoLanguage(lang string) string {
+	switch lang {
+	case "", "zh":
+		return "zh-CN"
+	case "en":
+		return "en-US"
+	}
+	return lang
+}
+
 func (p *Provider) constructRequest() map[string]interface{} {
 	return map[string]interface{}{
 		"user": map[string]interface{}{
@@ -198,7 +210,7 @@ func (p *Provider) constructRequest() map[string]interface{} {
 			"rate":     16000,
 			"bits":     16,
 			"channel":  1,
-			"language": "zh-CN", // Added language as per doc example
+			"language": doubaoLanguage(p.Language()),
 		},
 		"request": map[string]interface{}{
 			"model_name":      p.modelName,
@@ -614,7 +626,7 @@ func (p *Provider) ReadMessage() {
 						continue
 					}
 					p.logger.Info("调用OnAsrResult: text=%s, isLastPackage=%v", text, isLastPackage)
-					if finished := listener.OnAsrResult(text, isLastPackage); finished {
+					if finished := listener.OnAsrResult(text, isLastPackage, 1); finished {
 						return
 					}
 				} else {
@@ -771,3 +783,10 @@ func init() {
 		return NewProvider(config, deleteFile, logger)
 	})
 }
+
+// Capabilities 豆包ASR通过WebSocket流式识别
+func (p *Provider) Capabilities() map[string]bool {
+	return map[string]bool{
+		types.CapabilityStreaming: true,
+	}
+}
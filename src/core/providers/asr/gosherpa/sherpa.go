@@ -2,6 +2,7 @@ package gosherpa
 
 import (
 	"angrymiao-ai-server/src/core/providers/asr"
+	"angrymiao-ai-server/src/core/types"
 	"angrymiao-ai-server/src/core/utils"
 	"context"
 	"time"
@@ -39,7 +40,7 @@ func NewProvider(config *asr.Config, deleteFile bool, logger *utils.Logger) (*Pr
 			messageType, p, _ := conn.ReadMessage()
 			if messageType == websocket.TextMessage {
 				if listener := provider.GetListener(); listener != nil {
-					if finished := listener.OnAsrResult(string(p), true); finished {
+					if finished := listener.OnAsrResult(string(p), true, 1); finished {
 					}
 				}
 			}
@@ -75,3 +76,10 @@ func init() {
 		return NewProvider(config, deleteFile, logger)
 	})
 }
+
+// Capabilities Sherpa ASR通过WebSocket流式识别
+func (p *Provider) Capabilities() map[string]bool {
+	return map[string]bool{
+		types.CapabilityStreaming: true,
+	}
+}
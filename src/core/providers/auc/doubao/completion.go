@@ -0,0 +1,89 @@
+package doubao
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"angrymiao-ai-server/src/configs/database"
+	"angrymiao-ai-server/src/models"
+
+	"gorm.io/gorm"
+)
+
+// 豆包AUC任务状态码
+const (
+	codeSuccess    = 1000 // 识别成功
+	codeProcessing = 2000 // 处理中
+	codeQueued     = 2001 // 排队中
+)
+
+// ErrTaskNotFound 识别结果对应的 AudioTask 记录不存在
+var ErrTaskNotFound = errors.New("识别任务不存在")
+
+// TaskResult 识别任务结果，回调与查询接口的 resp 字段结构相同
+type TaskResult struct {
+	ID         string      `json:"id"`
+	Code       int         `json:"code"`
+	Message    string      `json:"message,omitempty"`
+	Text       string      `json:"text,omitempty"`
+	Utterances []Utterance `json:"utterances,omitempty"`
+}
+
+type Utterance struct {
+	Text      string            `json:"text"`
+	StartTime int               `json:"start_time"`
+	EndTime   int               `json:"end_time"`
+	Words     []Word            `json:"words,omitempty"`
+	Additions map[string]string `json:"additions,omitempty"`
+}
+
+type Word struct {
+	Text      string `json:"text"`
+	StartTime int    `json:"start_time"`
+	EndTime   int    `json:"end_time"`
+}
+
+// completionHook 识别成功、保存任务前补充任务信息（说话人分组、摘要等），由应用层注册
+var completionHook func(task *models.AudioTask, result *TaskResult)
+
+// SetCompletionHook 注册识别成功后的任务补充处理，回调与轮询两种模式共用
+func SetCompletionHook(hook func(task *models.AudioTask, result *TaskResult)) {
+	completionHook = hook
+}
+
+// HandleCallback 处理豆包AUC回调的识别结果并更新 AudioTask 记录
+func HandleCallback(result *TaskResult) error {
+	return handleCompletion(result.ID, result)
+}
+
+// handleCompletion 按识别结果更新 AudioTask 记录，code=1000 表示成功，其余视为失败
+func handleCompletion(taskID string, result *TaskResult) error {
+	db := database.GetDB()
+	var audioTask models.AudioTask
+	if err := db.Where("auc_task_id = ?", taskID).First(&audioTask).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrTaskNotFound
+		}
+		return fmt.Errorf("查找AudioTask失败: %w", err)
+	}
+
+	if result.Code == codeSuccess {
+		audioTask.Status = models.AudioTaskStatusCompleted
+		audioTask.Text = result.Text
+		// 保存完整的识别结果到 JSON 字段（包含 utterances、words、speaker 等详细信息）
+		if resultJSON, err := json.Marshal(result); err == nil {
+			audioTask.ResultJSON = resultJSON
+		}
+		if completionHook != nil {
+			completionHook(&audioTask, result)
+		}
+	} else {
+		audioTask.Status = models.AudioTaskStatusFailed
+	}
+
+	if err := db.Save(&audioTask).Error; err != nil {
+		return fmt.Errorf("更新AudioTask失败: %w", err)
+	}
+	return nil
+}
@@ -6,9 +6,17 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"time"
+)
+
+// 未配置 callback_url 时轮询任务结果的默认间隔与最长时间
+const (
+	defaultPollingIntervalSeconds    = 5
+	defaultPollingMaxDurationMinutes = 30
 )
 
 // Ensure Provider implements auc.Provider interface
@@ -23,6 +31,9 @@ type Provider struct {
 	serviceURL  string
 	callbackURL string
 	logger      *utils.Logger
+
+	pollingInterval    time.Duration // callbackURL 为空时轮询任务结果的间隔
+	pollingMaxDuration time.Duration // 轮询的最长时间，超时后任务标记为失败
 }
 
 // SubmitRequest 提交任务请求结构
@@ -61,6 +72,11 @@ type SubmitResponse struct {
 	} `json:"resp"`
 }
 
+// queryResponse 查询任务响应结构
+type queryResponse struct {
+	Resp TaskResult `json:"resp"`
+}
+
 // QueryRequest 查询任务请求结构
 type QueryRequest struct {
 	Appid   string `json:"appid"`
@@ -89,10 +105,8 @@ func NewProvider(config *auc.Config, logger *utils.Logger) (*Provider, error) {
 		return nil, fmt.Errorf("缺少cluster配置")
 	}
 
-	callbackURL, ok := config.Data["callback_url"].(string)
-	if !ok {
-		return nil, fmt.Errorf("缺少callback_url配置")
-	}
+	// callback_url 为空时提交任务后轮询识别结果
+	callbackURL, _ := config.Data["callback_url"].(string)
 
 	requestURL, ok := config.Data["request_url"].(string)
 	if !ok {
@@ -107,6 +121,9 @@ func NewProvider(config *auc.Config, logger *utils.Logger) (*Provider, error) {
 		serviceURL:   requestURL,
 		callbackURL:  callbackURL,
 		logger:       logger,
+
+		pollingInterval:    time.Duration(intOption(config.Data, "polling_interval_seconds", defaultPollingIntervalSeconds)) * time.Second,
+		pollingMaxDuration: time.Duration(intOption(config.Data, "polling_max_duration_minutes", defaultPollingMaxDurationMinutes)) * time.Minute,
 	}
 
 	return provider, nil
@@ -128,7 +145,7 @@ func (p *Provider) SubmitTask(ctx context.Context, audioURL string, userID strin
 			URL:    audioURL,
 		},
 		Additions: map[string]string{
-			"with_speaker_info": "False",
+			"with_speaker_info": "True",
 		},
 		Request: RequestInfo{
 			Callback: p.callbackURL,
@@ -172,11 +189,69 @@ func (p *Provider) SubmitTask(ctx context.Context, audioURL string, userID strin
 	}
 
 	p.logger.Info("AUC task submitted successfully, task ID: %s", submitResp.Resp.ID)
+	if p.callbackURL == "" {
+		go p.pollTask(submitResp.Resp.ID)
+	}
 	return submitResp.Resp.ID, nil
 }
 
+// pollTask 未配置回调地址时定时查询任务结果，完成后更新 AudioTask 记录
+// AudioTask 记录在任务提交后才创建，查询到结果时记录尚不存在则下次继续
+func (p *Provider) pollTask(taskID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), p.pollingMaxDuration)
+	defer cancel()
+	ticker := time.NewTicker(p.pollingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			p.logger.Warn("AUC任务轮询超时, TaskID: %s", taskID)
+			timeout := &TaskResult{ID: taskID, Message: "轮询识别结果超时"}
+			if err := handleCompletion(taskID, timeout); err != nil {
+				p.logger.Error("AUC任务标记失败出错: %v, TaskID: %s", err, taskID)
+			}
+			return
+		case <-ticker.C:
+		}
+
+		result, err := p.queryTaskResult(ctx, taskID)
+		if err != nil {
+			p.logger.Warn("AUC任务查询失败: %v, TaskID: %s", err, taskID)
+			continue
+		}
+		if result.Code == codeProcessing || result.Code == codeQueued {
+			continue
+		}
+		if err := handleCompletion(taskID, result); err != nil {
+			if errors.Is(err, ErrTaskNotFound) {
+				continue
+			}
+			p.logger.Error("AUC任务结果保存失败: %v, TaskID: %s", err, taskID)
+			return
+		}
+		if result.Code == codeSuccess {
+			p.logger.Info("AUC任务完成(轮询), TaskID: %s, Utterances: %d", taskID, len(result.Utterances))
+		} else {
+			p.logger.Error("AUC任务失败(轮询), TaskID: %s, Code: %d, Message: %s", taskID, result.Code, result.Message)
+		}
+		return
+	}
+}
+
 // QueryTask 查询任务状态
 func (p *Provider) QueryTask(ctx context.Context, taskID string) (*auc.QueryResponse, error) {
+	result, err := p.queryTaskResult(ctx, taskID)
+	if err != nil {
+		return nil, err
+	}
+	queryResp := &auc.QueryResponse{Code: result.Code, Message: result.Message}
+	queryResp.Result.Text = result.Text
+	return queryResp, nil
+}
+
+// queryTaskResult 调用查询接口获取完整的识别结果
+func (p *Provider) queryTaskResult(ctx context.Context, taskID string) (*TaskResult, error) {
 	queryReq := QueryRequest{
 		Appid:   p.appID,
 		Token:   p.accessToken,
@@ -213,12 +288,27 @@ func (p *Provider) QueryTask(ctx context.Context, taskID string) (*auc.QueryResp
 
 	p.logger.Debug("AUC query response: %s", string(body))
 
-	var queryResp auc.QueryResponse
+	var queryResp queryResponse
 	if err := json.Unmarshal(body, &queryResp); err != nil {
 		return nil, fmt.Errorf("unmarshal query response failed: %w", err)
 	}
 
-	return &queryResp, nil
+	return &queryResp.Resp, nil
+}
+
+// intOption 读取整数配置，YAML解析为int、JSON解析为float64，缺省或非正数时返回默认值
+func intOption(data map[string]interface{}, key string, defaultValue int) int {
+	switch v := data[key].(type) {
+	case int:
+		if v > 0 {
+			return v
+		}
+	case float64:
+		if v > 0 {
+			return int(v)
+		}
+	}
+	return defaultValue
 }
 
 // Initialize 实现Provider接口的Initialize方法
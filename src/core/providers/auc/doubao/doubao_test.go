@@ -0,0 +1,139 @@
+package doubao
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"angrymiao-ai-server/src/configs/database"
+	"angrymiao-ai-server/src/core/providers/auc"
+	"angrymiao-ai-server/src/core/utils"
+	"angrymiao-ai-server/src/models"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// newQueryServer 模拟豆包AUC接口，查询接口按 states 依次返回任务状态码，之后保持最后一个状态
+func newQueryServer(t *testing.T, states ...int) (*httptest.Server, *int32) {
+	t.Helper()
+	var queries int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/submit":
+			w.Write([]byte(`{"resp":{"id":"task-1","code":1000}}`))
+		case "/query":
+			n := int(atomic.AddInt32(&queries, 1))
+			code := states[min(n, len(states))-1]
+			result := TaskResult{ID: "task-1", Code: code}
+			if code == codeSuccess {
+				result.Text = "下午三点开会"
+				result.Utterances = []Utterance{{Text: "下午三点开会", EndTime: 1500, Additions: map[string]string{"speaker": "1"}}}
+			}
+			json.NewEncoder(w).Encode(queryResponse{Resp: result})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, &queries
+}
+
+// newPollingProvider 创建未配置回调地址的提供者，并准备 AudioTask 测试库
+func newPollingProvider(t *testing.T, serviceURL string) *Provider {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "auc.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("打开测试数据库失败: %v", err)
+	}
+	if err := db.AutoMigrate(&models.AudioTask{}); err != nil {
+		t.Fatalf("迁移失败: %v", err)
+	}
+	prev := database.DB
+	database.DB = db
+	t.Cleanup(func() { database.DB = prev })
+
+	logger, err := utils.NewLogger(&utils.LogCfg{LogLevel: "ERROR", LogDir: t.TempDir(), LogFile: "test.log"})
+	if err != nil {
+		t.Fatalf("创建日志失败: %v", err)
+	}
+	p, err := NewProvider(&auc.Config{Data: map[string]interface{}{
+		"appid":                        "app",
+		"access_token":                 "token",
+		"cluster":                      "cluster",
+		"request_url":                  serviceURL,
+		"polling_interval_seconds":     5,
+		"polling_max_duration_minutes": 30,
+	}}, logger)
+	if err != nil {
+		t.Fatalf("创建提供者失败: %v", err)
+	}
+	if p.pollingInterval != 5*time.Second || p.pollingMaxDuration != 30*time.Minute {
+		t.Fatalf("轮询配置 = %v/%v", p.pollingInterval, p.pollingMaxDuration)
+	}
+	p.pollingInterval = 10 * time.Millisecond
+	return p
+}
+
+// submitAndWait 提交任务并创建 AudioTask 记录，等待轮询更新任务状态
+func submitAndWait(t *testing.T, p *Provider) models.AudioTask {
+	t.Helper()
+	taskID, err := p.SubmitTask(context.Background(), "https://example.com/a.wav", "7")
+	if err != nil {
+		t.Fatalf("提交任务失败: %v", err)
+	}
+	if err := database.DB.Create(&models.AudioTask{UserID: 7, AucTaskID: taskID, Status: models.AudioTaskStatusProcessing}).Error; err != nil {
+		t.Fatalf("创建任务记录失败: %v", err)
+	}
+	var task models.AudioTask
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		database.DB.Where("auc_task_id = ?", taskID).First(&task)
+		if task.Status != models.AudioTaskStatusProcessing {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return task
+}
+
+func TestPollingUpdatesTaskOnCompletion(t *testing.T) {
+	server, queries := newQueryServer(t, codeProcessing, codeProcessing, codeSuccess)
+	p := newPollingProvider(t, server.URL)
+	var hooked atomic.Value
+	SetCompletionHook(func(task *models.AudioTask, result *TaskResult) {
+		hooked.Store(len(result.Utterances))
+		task.Summary = "会议时间"
+	})
+	t.Cleanup(func() { SetCompletionHook(nil) })
+
+	task := submitAndWait(t, p)
+	if task.Status != models.AudioTaskStatusCompleted || task.Text != "下午三点开会" || task.Summary != "会议时间" {
+		t.Fatalf("任务 = %+v, 期望轮询完成后更新识别结果", task)
+	}
+	if got := atomic.LoadInt32(queries); got != 3 {
+		t.Errorf("查询次数 = %d, 期望 3", got)
+	}
+	if hooked.Load() != 1 {
+		t.Errorf("完成回调收到的发言数 = %v, 期望 1", hooked.Load())
+	}
+	var saved TaskResult
+	if err := json.Unmarshal(task.ResultJSON, &saved); err != nil || len(saved.Utterances) != 1 {
+		t.Errorf("保存的识别结果 = %s, err = %v", task.ResultJSON, err)
+	}
+}
+
+func TestPollingMarksTaskFailedOnTimeout(t *testing.T) {
+	server, _ := newQueryServer(t, codeQueued, codeProcessing)
+	p := newPollingProvider(t, server.URL)
+	p.pollingMaxDuration = 100 * time.Millisecond
+
+	if task := submitAndWait(t, p); task.Status != models.AudioTaskStatusFailed {
+		t.Errorf("轮询超时后任务状态 = %s, 期望 failed", task.Status)
+	}
+}
@@ -3,8 +3,12 @@ package providers
 import (
 	"angrymiao-ai-server/src/core/types"
 	"context"
+	"errors"
 )
 
+// ErrVoiceCloneNotSupported TTS提供者不支持声音复刻
+var ErrVoiceCloneNotSupported = errors.New("tts provider does not support voice cloning")
+
 // Provider 所有提供者的基础接口
 type Provider interface {
 	Initialize() error
@@ -18,7 +22,8 @@ type ConfigurableProvider interface {
 }
 
 type AsrEventListener interface {
-	OnAsrResult(result string, isFinalResult bool) bool
+	// confidence 为识别结果的置信度(0~1)，不提供置信度的ASR固定传1
+	OnAsrResult(result string, isFinalResult bool, confidence float32) bool
 }
 
 // ASRProvider 语音识别提供者接口
@@ -51,6 +56,12 @@ type ASRProvider interface {
 	ResetStartListenTime()
 
 	EnableSilenceDetection(bEnable bool)
+
+	// 设置期望识别的语言（如 en、zh），空字符串表示恢复配置中的默认语言
+	SetLanguage(lang string) error
+
+	// 支持的能力，键为 types.Capability* 常量
+	Capabilities() map[string]bool
 }
 
 // TTSProvider 语音合成提供者接口
@@ -58,9 +69,31 @@ type TTSProvider interface {
 	Provider
 
 	// 合成音频并返回文件路径
+	// 开启TTS预取时会被并发调用（不超过 tts_prefetch_count 个）：doubao、deepgram、edge 每次调用独立建立连接，
+	// gosherpa 共享连接，内部加锁串行处理
 	ToTTS(text string) (string, error)
 
 	SetVoice(voice string) error
+
+	// 使用录音复刻用户音色，返回可用于 SetVoice 与用户TTS配置的音色ID
+	// 不支持时返回 ErrVoiceCloneNotSupported
+	CloneVoice(ctx context.Context, audioData []byte, name, language string) (string, error)
+
+	// 支持的能力，键为 types.Capability* 常量
+	Capabilities() map[string]bool
+}
+
+// StreamingTTSProvider 支持流式合成的TTS提供者，可选实现，需同时在 Capabilities 中声明 supports_streaming
+type StreamingTTSProvider interface {
+	// 合成音频并按顺序输出Opus帧，每个元素为一帧，采样率与帧长与服务端音频参数一致（16kHz单声道，60ms），合成结束或出错时关闭通道
+	// 调用方停止读取后会继续消费通道直到关闭，提供者不需要处理取消
+	ToTTSStream(text string) (<-chan []byte, error)
+}
+
+// SpeechParamsSetter 支持调整语速与音调的TTS提供者，可选实现
+type SpeechParamsSetter interface {
+	// speed 为语速倍率，1 为正常语速；pitch 为音调偏移的半音数，0 为不调整
+	SetSpeechParams(speed, pitch float32) error
 }
 
 // LLMProvider 大语言模型提供者接口
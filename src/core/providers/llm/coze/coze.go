@@ -215,3 +215,12 @@ func (p *Provider) ResponseWithFunctions(ctx context.Context, sessionID string,
 
 	return responseChan, nil
 }
+
+// Capabilities Coze流式输出，工具通过提示词描述并从回复中解析调用
+func (p *Provider) Capabilities() map[string]bool {
+	return map[string]bool{
+		types.CapabilityStreaming: true,
+		types.CapabilityTools:     true,
+		types.CapabilityVision:    false,
+	}
+}
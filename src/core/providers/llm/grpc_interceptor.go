@@ -0,0 +1,142 @@
+package llm
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// timeoutStreamInterceptor 为整个流式调用设置超时，流结束或出错时释放上下文
+func timeoutStreamInterceptor(timeout time.Duration) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		if timeout <= 0 {
+			return streamer(ctx, desc, cc, method, opts...)
+		}
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		stream, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		return &cancelOnDoneStream{ClientStream: stream, cancel: cancel}, nil
+	}
+}
+
+// cancelOnDoneStream 在 RecvMsg 返回错误（包括 io.EOF）后释放超时上下文
+type cancelOnDoneStream struct {
+	grpc.ClientStream
+	cancel context.CancelFunc
+}
+
+func (s *cancelOnDoneStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err != nil {
+		s.cancel()
+	}
+	return err
+}
+
+// retryStreamInterceptor 服务端流式调用在收到第一条消息前因服务不可用失败时，重新发起调用
+// 客户端流式调用无法重放请求，不做重试
+func retryStreamInterceptor(maxAttempts int, backoff time.Duration) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		if maxAttempts <= 1 || desc.ClientStreams {
+			return streamer(ctx, desc, cc, method, opts...)
+		}
+		s := &retryStream{
+			ctx:         ctx,
+			newStream:   func() (grpc.ClientStream, error) { return streamer(ctx, desc, cc, method, opts...) },
+			maxAttempts: maxAttempts,
+			backoff:     backoff,
+		}
+		var err error
+		for s.attempts = 1; ; s.attempts++ {
+			if s.ClientStream, err = s.newStream(); err == nil || !s.retry(err) {
+				break
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	}
+}
+
+// retryStream 记录发送的请求，重试时在新的流上重放
+type retryStream struct {
+	grpc.ClientStream
+	ctx         context.Context
+	newStream   func() (grpc.ClientStream, error)
+	maxAttempts int
+	backoff     time.Duration
+	attempts    int
+	request     interface{}
+	sendClosed  bool
+	received    bool
+}
+
+func (s *retryStream) SendMsg(m interface{}) error {
+	s.request = m
+	return s.ClientStream.SendMsg(m)
+}
+
+func (s *retryStream) CloseSend() error {
+	s.sendClosed = true
+	return s.ClientStream.CloseSend()
+}
+
+func (s *retryStream) RecvMsg(m interface{}) error {
+	for {
+		err := s.ClientStream.RecvMsg(m)
+		if err == nil {
+			s.received = true
+			return nil
+		}
+		if s.received {
+			return err
+		}
+		for s.retry(err) {
+			s.attempts++
+			if err = s.replay(); err == nil {
+				break
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// retry 判断是否还可以重试，可以时等待退避时间
+func (s *retryStream) retry(err error) bool {
+	if status.Code(err) != codes.Unavailable || s.attempts >= s.maxAttempts {
+		return false
+	}
+	select {
+	case <-s.ctx.Done():
+		return false
+	case <-time.After(s.backoff * time.Duration(s.attempts)):
+		return true
+	}
+}
+
+// replay 新建流并重放已发送的请求
+func (s *retryStream) replay() error {
+	stream, err := s.newStream()
+	if err != nil {
+		return err
+	}
+	s.ClientStream = stream
+	if s.request != nil {
+		if err := stream.SendMsg(s.request); err != nil {
+			return err
+		}
+	}
+	if s.sendClosed {
+		return stream.CloseSend()
+	}
+	return nil
+}
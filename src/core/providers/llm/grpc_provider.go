@@ -0,0 +1,297 @@
+package llm
+
+import (
+	"angrymiao-ai-server/src/core/grpcjson"
+	"angrymiao-ai-server/src/core/types"
+	"angrymiao-ai-server/src/core/utils"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/angrymiao/go-openai"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// grpcStreamChatMethod 外部LLM服务的流式对话方法，服务定义：
+	//   service LLMService { rpc StreamChat(ChatRequest) returns (stream ChatChunk); }
+	// 消息使用JSON编码（content-subtype: json），字段见 GRPCChatRequest、GRPCChatChunk
+	grpcStreamChatMethod = "/llm.LLMService/StreamChat"
+	// 默认的整个流式调用超时时间
+	defaultGRPCTimeout = 60 * time.Second
+	// 服务不可用时最多尝试的次数与退避间隔
+	grpcMaxAttempts  = 3
+	grpcRetryBackoff = 200 * time.Millisecond
+)
+
+// GRPCChatMessage gRPC对话请求中的消息
+type GRPCChatMessage struct {
+	Role       string           `json:"role"`
+	Content    string           `json:"content"`
+	ToolCallID string           `json:"tool_call_id,omitempty"`
+	ToolCalls  []types.ToolCall `json:"tool_calls,omitempty"`
+}
+
+// GRPCChatRequest StreamChat 的请求
+type GRPCChatRequest struct {
+	Model       string            `json:"model"`
+	SessionID   string            `json:"session_id,omitempty"`
+	Messages    []GRPCChatMessage `json:"messages"`
+	Tools       []openai.Tool     `json:"tools,omitempty"`
+	Temperature float64           `json:"temperature,omitempty"`
+	MaxTokens   int               `json:"max_tokens,omitempty"`
+	TopP        float64           `json:"top_p,omitempty"`
+}
+
+// GRPCChatChunk StreamChat 流式返回的分片
+type GRPCChatChunk struct {
+	Content          string           `json:"content,omitempty"`
+	ReasoningContent string           `json:"reasoning_content,omitempty"`
+	ToolCalls        []types.ToolCall `json:"tool_calls,omitempty"`
+	FinishReason     string           `json:"finish_reason,omitempty"`
+	Error            string           `json:"error,omitempty"`
+}
+
+// GRPCProvider 通过gRPC流式接口调用外部LLM服务
+type GRPCProvider struct {
+	*BaseProvider
+	conn        *grpc.ClientConn
+	timeout     time.Duration
+	dialOptions []grpc.DialOption // 额外的连接参数，测试中用于替换拨号方式
+
+	identityMu sync.RWMutex
+	identity   map[string]string // SetIdentityFlag 设置的身份标识，作为gRPC metadata发送
+}
+
+// 注册提供者
+func init() {
+	Register("grpc", NewGRPCProvider)
+}
+
+// NewGRPCProvider 创建gRPC LLM提供者
+func NewGRPCProvider(config *Config) (Provider, error) {
+	return &GRPCProvider{
+		BaseProvider: NewBaseProvider(config),
+		timeout:      defaultGRPCTimeout,
+		identity:     make(map[string]string),
+	}, nil
+}
+
+// extraString 读取字符串类型的额外配置
+func (p *GRPCProvider) extraString(key string) string {
+	value, _ := p.Config().Extra[key].(string)
+	return value
+}
+
+// Initialize 按 grpc_endpoint 建立连接，配置了CA证书时使用TLS，同时配置了客户端证书时使用双向TLS
+func (p *GRPCProvider) Initialize() error {
+	endpoint := p.extraString("grpc_endpoint")
+	if endpoint == "" {
+		return fmt.Errorf("缺少gRPC LLM服务地址配置 grpc_endpoint")
+	}
+	switch seconds := p.Config().Extra["grpc_timeout_seconds"].(type) {
+	case int:
+		p.timeout = time.Duration(seconds) * time.Second
+	case float64:
+		p.timeout = time.Duration(seconds * float64(time.Second))
+	}
+
+	creds, err := p.transportCredentials()
+	if err != nil {
+		return err
+	}
+	opts := append([]grpc.DialOption{
+		grpc.WithTransportCredentials(creds),
+		grpc.WithDefaultCallOptions(grpc.ForceCodec(grpcjson.Codec{})),
+		grpc.WithChainStreamInterceptor(
+			timeoutStreamInterceptor(p.timeout),
+			retryStreamInterceptor(grpcMaxAttempts, grpcRetryBackoff),
+		),
+	}, p.dialOptions...)
+	conn, err := grpc.NewClient(endpoint, opts...)
+	if err != nil {
+		return fmt.Errorf("连接gRPC LLM服务失败: %v", err)
+	}
+	p.conn = conn
+	return nil
+}
+
+// transportCredentials 根据 grpc_ca_file、grpc_client_cert、grpc_client_key 生成传输凭证，均未配置时不加密
+func (p *GRPCProvider) transportCredentials() (credentials.TransportCredentials, error) {
+	caFile := p.extraString("grpc_ca_file")
+	certFile := p.extraString("grpc_client_cert")
+	keyFile := p.extraString("grpc_client_key")
+	if caFile == "" && certFile == "" && keyFile == "" {
+		return insecure.NewCredentials(), nil
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caFile != "" {
+		caPEM, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("读取gRPC CA证书失败: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("解析gRPC CA证书失败: %s", caFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("加载gRPC客户端证书失败: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return credentials.NewTLS(tlsConfig), nil
+}
+
+// Cleanup 关闭gRPC连接
+func (p *GRPCProvider) Cleanup() error {
+	if p.conn == nil {
+		return nil
+	}
+	err := p.conn.Close()
+	p.conn = nil
+	return err
+}
+
+// SetIdentityFlag 设置身份标识，之后的请求以 x-identity-{idType} 作为gRPC metadata发送
+func (p *GRPCProvider) SetIdentityFlag(idType string, flag string) {
+	p.identityMu.Lock()
+	defer p.identityMu.Unlock()
+	p.identity[fmt.Sprintf("x-identity-%s", strings.ToLower(idType))] = flag
+}
+
+// Capabilities gRPC服务支持流式输出与工具调用
+func (p *GRPCProvider) Capabilities() map[string]bool {
+	return map[string]bool{
+		types.CapabilityStreaming: true,
+		types.CapabilityTools:     true,
+		types.CapabilityVision:    false,
+	}
+}
+
+// outgoingContext 将身份标识与请求关联ID附加到请求的metadata
+func (p *GRPCProvider) outgoingContext(ctx context.Context) context.Context {
+	p.identityMu.RLock()
+	md := metadata.New(p.identity)
+	p.identityMu.RUnlock()
+	if requestID := utils.RequestIDFromContext(ctx); requestID != "" {
+		md.Set("x-request-id", requestID)
+	}
+	if md.Len() == 0 {
+		return ctx
+	}
+	return metadata.NewOutgoingContext(ctx, md)
+}
+
+// buildRequest 将对话消息转换为gRPC请求
+func (p *GRPCProvider) buildRequest(sessionID string, messages []types.Message, tools []openai.Tool) *GRPCChatRequest {
+	config := p.Config()
+	req := &GRPCChatRequest{
+		Model:       config.ModelName,
+		SessionID:   sessionID,
+		Messages:    make([]GRPCChatMessage, len(messages)),
+		Tools:       tools,
+		Temperature: config.Temperature,
+		MaxTokens:   config.MaxTokens,
+		TopP:        config.TopP,
+	}
+	for i, msg := range messages {
+		req.Messages[i] = GRPCChatMessage{
+			Role:       msg.Role,
+			Content:    msg.Content,
+			ToolCallID: msg.ToolCallID,
+			ToolCalls:  msg.ToolCalls,
+		}
+	}
+	return req
+}
+
+// streamChat 调用 StreamChat 并依次处理返回的分片，流正常结束时返回nil
+func (p *GRPCProvider) streamChat(ctx context.Context, req *GRPCChatRequest, onChunk func(*GRPCChatChunk)) error {
+	if p.conn == nil {
+		return fmt.Errorf("gRPC连接未初始化")
+	}
+	stream, err := p.conn.NewStream(p.outgoingContext(ctx), &grpc.StreamDesc{ServerStreams: true}, grpcStreamChatMethod)
+	if err != nil {
+		return err
+	}
+	if err := stream.SendMsg(req); err != nil {
+		return err
+	}
+	if err := stream.CloseSend(); err != nil {
+		return err
+	}
+	for {
+		chunk := new(GRPCChatChunk)
+		if err := stream.RecvMsg(chunk); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if chunk.Error != "" {
+			return fmt.Errorf("%s", chunk.Error)
+		}
+		onChunk(chunk)
+	}
+}
+
+// Response types.LLMProvider接口实现
+func (p *GRPCProvider) Response(ctx context.Context, sessionID string, messages []types.Message) (<-chan string, error) {
+	responseChan := make(chan string, 10)
+
+	go func() {
+		defer close(responseChan)
+		err := p.streamChat(ctx, p.buildRequest(sessionID, messages, nil), func(chunk *GRPCChatChunk) {
+			if chunk.Content != "" {
+				responseChan <- chunk.Content
+			}
+		})
+		if err != nil {
+			responseChan <- fmt.Sprintf("【gRPC LLM服务响应异常: %v】", err)
+		}
+	}()
+
+	return responseChan, nil
+}
+
+// ResponseWithFunctions types.LLMProvider接口实现
+func (p *GRPCProvider) ResponseWithFunctions(ctx context.Context, sessionID string, messages []types.Message, tools []openai.Tool) (<-chan types.Response, error) {
+	responseChan := make(chan types.Response, 10)
+
+	go func() {
+		defer close(responseChan)
+		err := p.streamChat(ctx, p.buildRequest(sessionID, messages, tools), func(chunk *GRPCChatChunk) {
+			if chunk.Content == "" && chunk.ReasoningContent == "" && len(chunk.ToolCalls) == 0 && chunk.FinishReason == "" {
+				return
+			}
+			responseChan <- types.Response{
+				Content:       chunk.Content,
+				ReasonContent: chunk.ReasoningContent,
+				ToolCalls:     chunk.ToolCalls,
+				StopReason:    chunk.FinishReason,
+			}
+		})
+		if err != nil {
+			responseChan <- types.Response{
+				Content: fmt.Sprintf("【gRPC LLM服务响应异常: %v】", err),
+				Error:   err.Error(),
+			}
+		}
+	}()
+
+	return responseChan, nil
+}
@@ -0,0 +1,284 @@
+package llm
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"angrymiao-ai-server/src/core/grpcjson"
+	"angrymiao-ai-server/src/core/types"
+	"angrymiao-ai-server/src/core/utils"
+
+	"github.com/angrymiao/go-openai"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// mockChatServer 将最后一条消息按字拆分为流式分片返回，前 failFirst 次调用返回服务不可用
+type mockChatServer struct {
+	mu        sync.Mutex
+	calls     int
+	failFirst int
+	requests  []GRPCChatRequest
+	metadata  []metadata.MD
+}
+
+func (s *mockChatServer) streamChat(_ interface{}, stream grpc.ServerStream) error {
+	var req GRPCChatRequest
+	if err := stream.RecvMsg(&req); err != nil {
+		return err
+	}
+	md, _ := metadata.FromIncomingContext(stream.Context())
+	s.mu.Lock()
+	s.calls++
+	fail := s.calls <= s.failFirst
+	s.requests = append(s.requests, req)
+	s.metadata = append(s.metadata, md)
+	s.mu.Unlock()
+	if fail {
+		return status.Error(codes.Unavailable, "服务暂不可用")
+	}
+
+	for _, r := range req.Messages[len(req.Messages)-1].Content {
+		if err := stream.SendMsg(&GRPCChatChunk{Content: string(r)}); err != nil {
+			return err
+		}
+	}
+	if len(req.Tools) > 0 {
+		call := types.ToolCall{ID: "call-1", Type: "function", Function: types.FunctionCall{Name: req.Tools[0].Function.Name, Arguments: "{}"}}
+		if err := stream.SendMsg(&GRPCChatChunk{ToolCalls: []types.ToolCall{call}}); err != nil {
+			return err
+		}
+	}
+	return stream.SendMsg(&GRPCChatChunk{FinishReason: "stop"})
+}
+
+// startMockChatServer 在内存连接上启动gRPC服务，返回服务实现与拨号参数
+func startMockChatServer(t *testing.T, opts ...grpc.ServerOption) (*mockChatServer, grpc.DialOption) {
+	t.Helper()
+	listener := bufconn.Listen(1024 * 1024)
+	mock := &mockChatServer{}
+	server := grpc.NewServer(append(opts, grpc.ForceServerCodec(grpcjson.Codec{}))...)
+	server.RegisterService(&grpc.ServiceDesc{
+		ServiceName: "llm.LLMService",
+		HandlerType: (*interface{})(nil),
+		Streams: []grpc.StreamDesc{{
+			StreamName:    "StreamChat",
+			Handler:       mock.streamChat,
+			ServerStreams: true,
+		}},
+	}, struct{}{})
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	dialer := grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+		return listener.DialContext(ctx)
+	})
+	return mock, dialer
+}
+
+// newTestGRPCProvider 创建连接到内存gRPC服务的提供者
+func newTestGRPCProvider(t *testing.T, dialer grpc.DialOption, extra map[string]interface{}) *GRPCProvider {
+	t.Helper()
+	extra["grpc_endpoint"] = "passthrough:///localhost"
+	provider, err := NewGRPCProvider(&Config{Name: "grpc", Type: "grpc", ModelName: "test-model", Extra: extra})
+	if err != nil {
+		t.Fatalf("创建gRPC提供者失败: %v", err)
+	}
+	p := provider.(*GRPCProvider)
+	p.dialOptions = []grpc.DialOption{dialer}
+	if err := p.Initialize(); err != nil {
+		t.Fatalf("初始化gRPC提供者失败: %v", err)
+	}
+	t.Cleanup(func() { p.Cleanup() })
+	return p
+}
+
+func collectResponses(t *testing.T, ch <-chan types.Response) (string, []types.ToolCall, string) {
+	t.Helper()
+	var content strings.Builder
+	var toolCalls []types.ToolCall
+	var errMsg string
+	for resp := range ch {
+		content.WriteString(resp.Content)
+		toolCalls = append(toolCalls, resp.ToolCalls...)
+		if resp.Error != "" {
+			errMsg = resp.Error
+		}
+	}
+	return content.String(), toolCalls, errMsg
+}
+
+func TestGRPCProviderAssemblesStreamAndSendsIdentity(t *testing.T) {
+	mock, dialer := startMockChatServer(t)
+	p := newTestGRPCProvider(t, dialer, map[string]interface{}{"grpc_timeout_seconds": 5})
+	p.SetIdentityFlag("session", "session-1")
+
+	ch, err := p.Response(utils.WithRequestID(context.Background(), "req-1"), "session-1", []types.Message{{Role: "system", Content: "你是助手"}, {Role: "user", Content: "你好呀"}})
+	if err != nil {
+		t.Fatalf("调用gRPC LLM失败: %v", err)
+	}
+	var content strings.Builder
+	for part := range ch {
+		content.WriteString(part)
+	}
+	if content.String() != "你好呀" {
+		t.Errorf("流式回复拼接结果 = %q, 期望 你好呀", content.String())
+	}
+
+	mock.mu.Lock()
+	req, md := mock.requests[0], mock.metadata[0]
+	mock.mu.Unlock()
+	if req.Model != "test-model" || req.SessionID != "session-1" || len(req.Messages) != 2 || req.Messages[0].Role != "system" {
+		t.Errorf("请求内容不符合预期: %+v", req)
+	}
+	if got := md.Get("x-identity-session"); len(got) != 1 || got[0] != "session-1" {
+		t.Errorf("身份标识metadata = %v", got)
+	}
+	if got := md.Get("x-request-id"); len(got) != 1 || got[0] != "req-1" {
+		t.Errorf("请求关联ID metadata = %v", got)
+	}
+}
+
+func TestGRPCProviderRetriesUnavailable(t *testing.T) {
+	mock, dialer := startMockChatServer(t)
+	mock.failFirst = 2
+	p := newTestGRPCProvider(t, dialer, map[string]interface{}{})
+
+	tools := []types.Message{{Role: "user", Content: "开灯"}}
+	ch, err := p.ResponseWithFunctions(context.Background(), "", tools, []openai.Tool{{Type: openai.ToolTypeFunction, Function: &openai.FunctionDefinition{Name: "turn_on"}}})
+	if err != nil {
+		t.Fatalf("调用gRPC LLM失败: %v", err)
+	}
+	content, toolCalls, errMsg := collectResponses(t, ch)
+	if errMsg != "" || content != "开灯" {
+		t.Fatalf("重试后回复 = %q, 错误: %s", content, errMsg)
+	}
+	if len(toolCalls) != 1 || toolCalls[0].Function.Name != "turn_on" {
+		t.Errorf("工具调用 = %+v", toolCalls)
+	}
+	if mock.calls != 3 {
+		t.Errorf("调用次数 = %d, 期望 3", mock.calls)
+	}
+
+	// 超过最大尝试次数后返回错误
+	mock.calls, mock.failFirst = 0, grpcMaxAttempts
+	ch, _ = p.ResponseWithFunctions(context.Background(), "", tools, nil)
+	if _, _, errMsg := collectResponses(t, ch); !strings.Contains(errMsg, "服务暂不可用") {
+		t.Errorf("错误信息 = %q", errMsg)
+	}
+}
+
+func TestGRPCProviderMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca, caKey := newTestCA(t)
+	caFile := writePEM(t, dir, "ca.pem", "CERTIFICATE", ca.Raw)
+	serverCert := newTestCert(t, ca, caKey, "localhost", x509.ExtKeyUsageServerAuth)
+	clientCert := newTestCert(t, ca, caKey, "client", x509.ExtKeyUsageClientAuth)
+	certFile := writePEM(t, dir, "client.pem", "CERTIFICATE", clientCert.Certificate[0])
+	keyDER, err := x509.MarshalPKCS8PrivateKey(clientCert.PrivateKey)
+	if err != nil {
+		t.Fatalf("序列化客户端私钥失败: %v", err)
+	}
+	keyFile := writePEM(t, dir, "client-key.pem", "PRIVATE KEY", keyDER)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	_, dialer := startMockChatServer(t, grpc.Creds(credentials.NewTLS(&tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientCAs:    roots,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	})))
+	messages := []types.Message{{Role: "user", Content: "加密"}}
+
+	p := newTestGRPCProvider(t, dialer, map[string]interface{}{
+		"grpc_ca_file":     caFile,
+		"grpc_client_cert": certFile,
+		"grpc_client_key":  keyFile,
+	})
+	ch, _ := p.ResponseWithFunctions(context.Background(), "", messages, nil)
+	if content, _, errMsg := collectResponses(t, ch); errMsg != "" || content != "加密" {
+		t.Fatalf("双向TLS调用结果 = %q, 错误: %s", content, errMsg)
+	}
+
+	// 未提供客户端证书时握手失败
+	noCert := newTestGRPCProvider(t, dialer, map[string]interface{}{"grpc_ca_file": caFile, "grpc_timeout_seconds": 2})
+	ch, _ = noCert.ResponseWithFunctions(context.Background(), "", messages, nil)
+	if _, _, errMsg := collectResponses(t, ch); errMsg == "" {
+		t.Error("未提供客户端证书时应握手失败")
+	}
+}
+
+func newTestCA(t *testing.T) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("生成CA私钥失败: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("生成CA证书失败: %v", err)
+	}
+	ca, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("解析CA证书失败: %v", err)
+	}
+	return ca, key
+}
+
+func newTestCert(t *testing.T, ca *x509.Certificate, caKey *ecdsa.PrivateKey, name string, usage x509.ExtKeyUsage) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("生成私钥失败: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("签发证书失败: %v", err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func writePEM(t *testing.T, dir, name, blockType string, der []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600); err != nil {
+		t.Fatalf("写入%s失败: %v", name, err)
+	}
+	return path
+}
@@ -3,8 +3,10 @@ package ollama
 import (
 	"angrymiao-ai-server/src/core/providers/llm"
 	"angrymiao-ai-server/src/core/types"
+	"angrymiao-ai-server/src/core/utils"
 	"context"
 	"fmt"
+	"net/http"
 	"strings"
 
 	"github.com/angrymiao/go-openai"
@@ -60,6 +62,8 @@ func (p *Provider) Initialize() error {
 	clientConfig := openai.DefaultConfig("ollama")
 	clientConfig.BaseURL = baseURL
 
+	// 转发当前对话的请求关联ID
+	clientConfig.HTTPClient = &http.Client{Transport: utils.NewRequestIDTransport(nil)}
 	p.client = openai.NewClientWithConfig(clientConfig)
 	return nil
 }
@@ -300,3 +304,12 @@ func (p *Provider) handleThinkTagsWithBuffer(buffer string, isActive bool) (stri
 
 	return buffer, isActive
 }
+
+// Capabilities Ollama通过OpenAI兼容接口流式输出，支持原生工具调用
+func (p *Provider) Capabilities() map[string]bool {
+	return map[string]bool{
+		types.CapabilityStreaming: true,
+		types.CapabilityTools:     true,
+		types.CapabilityVision:    false,
+	}
+}
@@ -3,8 +3,10 @@ package openai
 import (
 	"angrymiao-ai-server/src/core/providers/llm"
 	"angrymiao-ai-server/src/core/types"
+	"angrymiao-ai-server/src/core/utils"
 	"context"
 	"fmt"
+	"net/http"
 
 	"github.com/angrymiao/go-openai"
 )
@@ -47,6 +49,8 @@ func (p *Provider) Initialize() error {
 		clientConfig.BaseURL = config.BaseURL
 	}
 
+	// 转发当前对话的请求关联ID
+	clientConfig.HTTPClient = &http.Client{Transport: utils.NewRequestIDTransport(nil)}
 	p.client = openai.NewClientWithConfig(clientConfig)
 	return nil
 }
@@ -233,3 +237,12 @@ func handleThinkTags(content string, isActive bool) (string, bool) {
 
 	return content, isActive
 }
+
+// Capabilities OpenAI兼容接口支持流式输出与原生工具调用
+func (p *Provider) Capabilities() map[string]bool {
+	return map[string]bool{
+		types.CapabilityStreaming: true,
+		types.CapabilityTools:     true,
+		types.CapabilityVision:    false,
+	}
+}
@@ -0,0 +1,301 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+/*
+* 多会话共享ASR。ASR 配置 can_share: true 时，资源池只创建一个ASR实例，
+* 所有会话通过 SharedASRSession 使用该实例。
+* 同一时刻只有一个会话持有识别权：会话首次送入音频时获取，识别结束（最终结果或监听器要求停止）、
+* Reset 或 CloseConnection 时释放；其他会话送入音频时等待释放。
+* 识别事件按持有识别权的会话ID分发到各自的监听器。
+ */
+
+// SharedASRWaitTimeout 等待其他会话释放共享ASR的最长时间
+const SharedASRWaitTimeout = 10 * time.Second
+
+// ErrSharedASRBusy 等待共享ASR超时
+var ErrSharedASRBusy = errors.New("shared asr busy")
+
+// MultiSessionASRProvider 多个会话共享的ASR实例，按会话ID分发识别事件
+type MultiSessionASRProvider struct {
+	inner ASRProvider
+
+	turn chan struct{} // 识别权，容量为1
+
+	mu        sync.Mutex
+	owner     string // 当前持有识别权的会话ID
+	listeners map[string]AsrEventListener
+}
+
+// NewMultiSessionASRProvider 包装一个ASR实例供多个会话共享
+func NewMultiSessionASRProvider(inner ASRProvider) *MultiSessionASRProvider {
+	m := &MultiSessionASRProvider{
+		inner:     inner,
+		turn:      make(chan struct{}, 1),
+		listeners: make(map[string]AsrEventListener),
+	}
+	inner.SetListener(m)
+	return m
+}
+
+// Inner 获取被共享的ASR实例
+func (m *MultiSessionASRProvider) Inner() ASRProvider {
+	return m.inner
+}
+
+// SetListener 设置会话的识别事件监听器
+func (m *MultiSessionASRProvider) SetListener(sessionID string, listener AsrEventListener) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.listeners[sessionID] = listener
+}
+
+// RemoveListener 移除会话的监听器，会话持有识别权时一并释放
+func (m *MultiSessionASRProvider) RemoveListener(sessionID string) {
+	m.mu.Lock()
+	delete(m.listeners, sessionID)
+	m.mu.Unlock()
+	m.release(sessionID)
+}
+
+// ListenerCount 当前注册的会话数
+func (m *MultiSessionASRProvider) ListenerCount() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.listeners)
+}
+
+// Owner 当前持有识别权的会话ID，为空表示空闲
+func (m *MultiSessionASRProvider) Owner() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.owner
+}
+
+// OnAsrResult 将识别结果分发给持有识别权的会话
+// 最终结果或监听器要求停止识别时释放识别权
+func (m *MultiSessionASRProvider) OnAsrResult(result string, isFinalResult bool, confidence float32) bool {
+	m.mu.Lock()
+	sessionID := m.owner
+	listener := m.listeners[sessionID]
+	m.mu.Unlock()
+	if listener == nil {
+		return false
+	}
+	stop := listener.OnAsrResult(result, isFinalResult, confidence)
+	if stop || isFinalResult {
+		m.release(sessionID)
+	}
+	return stop
+}
+
+// Session 获取会话使用的ASR
+func (m *MultiSessionASRProvider) Session(sessionID string) *SharedASRSession {
+	return &SharedASRSession{mux: m, sessionID: sessionID}
+}
+
+// Initialize 共享实例由资源池创建时已初始化
+func (m *MultiSessionASRProvider) Initialize() error {
+	return nil
+}
+
+// Cleanup 释放共享的ASR实例
+func (m *MultiSessionASRProvider) Cleanup() error {
+	return m.inner.Cleanup()
+}
+
+func (m *MultiSessionASRProvider) isOwner(sessionID string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.owner == sessionID
+}
+
+// acquire 获取识别权，已持有时直接返回；新获取时返回 true
+func (m *MultiSessionASRProvider) acquire(ctx context.Context, sessionID string) (bool, error) {
+	if m.isOwner(sessionID) {
+		return false, nil
+	}
+	timer := time.NewTimer(SharedASRWaitTimeout)
+	defer timer.Stop()
+	select {
+	case m.turn <- struct{}{}:
+	case <-timer.C:
+		return false, fmt.Errorf("%w: 等待%v后仍被会话 %s 占用", ErrSharedASRBusy, SharedASRWaitTimeout, m.Owner())
+	case <-ctx.Done():
+		return false, ctx.Err()
+	}
+	m.mu.Lock()
+	m.owner = sessionID
+	m.mu.Unlock()
+	return true, nil
+}
+
+// release 会话持有识别权时释放
+func (m *MultiSessionASRProvider) release(sessionID string) {
+	m.mu.Lock()
+	if sessionID == "" || m.owner != sessionID {
+		m.mu.Unlock()
+		return
+	}
+	m.owner = ""
+	m.mu.Unlock()
+	<-m.turn
+}
+
+// SharedASRSession 单个会话对共享ASR的视图，实现 ASRProvider 接口
+// 语言与用户偏好按会话保存，获取识别权时应用到共享实例
+type SharedASRSession struct {
+	mux       *MultiSessionASRProvider
+	sessionID string
+
+	mu          sync.Mutex
+	language    string
+	preferences map[string]interface{}
+}
+
+// SessionID 获取会话ID
+func (s *SharedASRSession) SessionID() string {
+	return s.sessionID
+}
+
+func (s *SharedASRSession) Initialize() error {
+	return nil
+}
+
+// Cleanup 会话结束时移除监听器，共享实例由资源池关闭时释放
+func (s *SharedASRSession) Cleanup() error {
+	s.mux.RemoveListener(s.sessionID)
+	return nil
+}
+
+// begin 获取识别权，新获取时复位共享实例并应用本会话的设置
+func (s *SharedASRSession) begin(ctx context.Context) error {
+	acquired, err := s.mux.acquire(ctx, s.sessionID)
+	if err != nil || !acquired {
+		return err
+	}
+	inner := s.mux.inner
+	if err := inner.Reset(); err != nil {
+		s.mux.release(s.sessionID)
+		return fmt.Errorf("复位共享ASR失败: %v", err)
+	}
+	inner.ResetStartListenTime()
+	s.mu.Lock()
+	language, preferences := s.language, s.preferences
+	s.mu.Unlock()
+	if err := inner.SetLanguage(language); err != nil {
+		s.mux.release(s.sessionID)
+		return err
+	}
+	if preferences != nil {
+		if err := inner.SetUserPreferences(preferences); err != nil {
+			s.mux.release(s.sessionID)
+			return err
+		}
+	}
+	return nil
+}
+
+// Transcribe 直接识别音频，期间独占共享实例
+func (s *SharedASRSession) Transcribe(ctx context.Context, audioData []byte) (string, error) {
+	owned := s.mux.isOwner(s.sessionID)
+	if err := s.begin(ctx); err != nil {
+		return "", err
+	}
+	if !owned {
+		defer s.mux.release(s.sessionID)
+	}
+	return s.mux.inner.Transcribe(ctx, audioData)
+}
+
+func (s *SharedASRSession) AddAudio(data []byte) error {
+	if err := s.begin(context.Background()); err != nil {
+		return err
+	}
+	return s.mux.inner.AddAudio(data)
+}
+
+func (s *SharedASRSession) SendLastAudio(data []byte) error {
+	if !s.mux.isOwner(s.sessionID) {
+		return nil // 本会话没有进行中的识别
+	}
+	return s.mux.inner.SendLastAudio(data)
+}
+
+func (s *SharedASRSession) SetListener(listener AsrEventListener) {
+	s.mux.SetListener(s.sessionID, listener)
+}
+
+func (s *SharedASRSession) SetUserPreferences(preferences map[string]interface{}) error {
+	s.mu.Lock()
+	s.preferences = preferences
+	s.mu.Unlock()
+	if s.mux.isOwner(s.sessionID) {
+		return s.mux.inner.SetUserPreferences(preferences)
+	}
+	return nil
+}
+
+// Reset 复位本会话的识别并释放识别权
+func (s *SharedASRSession) Reset() error {
+	if !s.mux.isOwner(s.sessionID) {
+		return nil
+	}
+	defer s.mux.release(s.sessionID)
+	return s.mux.inner.Reset()
+}
+
+// CloseConnection 断开本会话的识别连接并释放识别权
+func (s *SharedASRSession) CloseConnection() error {
+	if !s.mux.isOwner(s.sessionID) {
+		return nil
+	}
+	defer s.mux.release(s.sessionID)
+	return s.mux.inner.CloseConnection()
+}
+
+func (s *SharedASRSession) GetSilenceCount() int {
+	if !s.mux.isOwner(s.sessionID) {
+		return 0
+	}
+	return s.mux.inner.GetSilenceCount()
+}
+
+func (s *SharedASRSession) ResetSilenceCount() {
+	if s.mux.isOwner(s.sessionID) {
+		s.mux.inner.ResetSilenceCount()
+	}
+}
+
+func (s *SharedASRSession) ResetStartListenTime() {
+	if s.mux.isOwner(s.sessionID) {
+		s.mux.inner.ResetStartListenTime()
+	}
+}
+
+func (s *SharedASRSession) EnableSilenceDetection(bEnable bool) {
+	if s.mux.isOwner(s.sessionID) {
+		s.mux.inner.EnableSilenceDetection(bEnable)
+	}
+}
+
+func (s *SharedASRSession) SetLanguage(lang string) error {
+	s.mu.Lock()
+	s.language = lang
+	s.mu.Unlock()
+	if s.mux.isOwner(s.sessionID) {
+		return s.mux.inner.SetLanguage(lang)
+	}
+	return nil
+}
+
+// Capabilities 返回共享实例的能力
+func (s *SharedASRSession) Capabilities() map[string]bool {
+	return s.mux.inner.Capabilities()
+}
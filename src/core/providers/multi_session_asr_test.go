@@ -0,0 +1,180 @@
+package providers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// streamASR 累积音频，SendLastAudio 时把累积内容作为最终结果回调
+type streamASR struct {
+	ASRProvider
+	mu       sync.Mutex
+	listener AsrEventListener
+	buf      []byte
+	language string
+}
+
+func (a *streamASR) SetListener(listener AsrEventListener) { a.listener = listener }
+func (a *streamASR) ResetStartListenTime()                 {}
+
+func (a *streamASR) AddAudio(data []byte) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.buf = append(a.buf, data...)
+	return nil
+}
+
+func (a *streamASR) SendLastAudio(data []byte) error {
+	a.mu.Lock()
+	result := a.language + ":" + string(append(a.buf, data...))
+	a.mu.Unlock()
+	a.listener.OnAsrResult(result, true, 1)
+	return nil
+}
+
+func (a *streamASR) Reset() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.buf = nil
+	return nil
+}
+
+func (a *streamASR) SetLanguage(lang string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.language = lang
+	return nil
+}
+
+// Transcribe 模拟识别耗时的计算
+func (a *streamASR) Transcribe(ctx context.Context, audioData []byte) (string, error) {
+	sum := sha256.Sum256(audioData)
+	for i := 0; i < 200; i++ {
+		sum = sha256.Sum256(sum[:])
+	}
+	return hex.EncodeToString(sum[:4]), nil
+}
+
+type resultListener struct {
+	results chan string
+}
+
+func (l *resultListener) OnAsrResult(result string, isFinalResult bool, confidence float32) bool {
+	l.results <- result
+	return isFinalResult
+}
+
+func TestMultiSessionASRDemultiplexesBySession(t *testing.T) {
+	mux := NewMultiSessionASRProvider(&streamASR{})
+	a, b := mux.Session("a"), mux.Session("b")
+	la, lb := &resultListener{results: make(chan string, 1)}, &resultListener{results: make(chan string, 1)}
+	a.SetListener(la)
+	b.SetListener(lb)
+	b.SetLanguage("en")
+
+	if err := a.AddAudio([]byte("你好")); err != nil {
+		t.Fatalf("会话a送入音频失败: %v", err)
+	}
+	// 会话a识别期间，会话b等待识别权
+	bDone := make(chan error, 1)
+	go func() {
+		if err := b.AddAudio([]byte("hello")); err != nil {
+			bDone <- err
+			return
+		}
+		bDone <- b.SendLastAudio(nil)
+	}()
+	select {
+	case err := <-bDone:
+		t.Fatalf("会话a识别结束前会话b不应获得识别权: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if mux.Owner() != "a" {
+		t.Fatalf("识别权持有者 = %q, 期望 a", mux.Owner())
+	}
+	if err := a.SendLastAudio(nil); err != nil {
+		t.Fatalf("会话a结束识别失败: %v", err)
+	}
+	if got := <-la.results; got != ":你好" {
+		t.Errorf("会话a识别结果 = %q", got)
+	}
+
+	if err := <-bDone; err != nil {
+		t.Fatalf("会话b识别失败: %v", err)
+	}
+	if got := <-lb.results; got != "en:hello" {
+		t.Errorf("会话b识别结果 = %q, 期望不含会话a的音频且使用会话b的语言", got)
+	}
+	if len(la.results) != 0 {
+		t.Error("会话b的结果不应分发给会话a")
+	}
+	if mux.Owner() != "" {
+		t.Errorf("最终结果后应释放识别权, owner = %q", mux.Owner())
+	}
+
+	a.Cleanup()
+	b.Cleanup()
+	if n := mux.ListenerCount(); n != 0 {
+		t.Errorf("会话结束后监听器数量 = %d, 期望 0", n)
+	}
+}
+
+func TestMultiSessionASRCleanupReleasesTurn(t *testing.T) {
+	mux := NewMultiSessionASRProvider(&streamASR{})
+	a, b := mux.Session("a"), mux.Session("b")
+	a.AddAudio([]byte("x"))
+	a.Cleanup() // 会话在识别中断开
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := b.Transcribe(ctx, []byte("y")); err != nil {
+		t.Fatalf("会话a断开后会话b应能使用共享ASR: %v", err)
+	}
+	if mux.Owner() != "" {
+		t.Errorf("Transcribe 结束后应释放识别权, owner = %q", mux.Owner())
+	}
+}
+
+// BenchmarkSharedASR 10个并发会话共享一个ASR与各自独立ASR的对比
+func BenchmarkSharedASR(b *testing.B) {
+	const sessions = 10
+	audio := make([]byte, 3200)
+
+	run := func(b *testing.B, asrs []ASRProvider) {
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			var wg sync.WaitGroup
+			for _, asr := range asrs {
+				wg.Add(1)
+				go func(asr ASRProvider) {
+					defer wg.Done()
+					if _, err := asr.Transcribe(context.Background(), audio); err != nil {
+						b.Error(err)
+					}
+				}(asr)
+			}
+			wg.Wait()
+		}
+	}
+
+	b.Run("shared", func(b *testing.B) {
+		mux := NewMultiSessionASRProvider(&streamASR{})
+		asrs := make([]ASRProvider, sessions)
+		for i := range asrs {
+			asrs[i] = mux.Session(fmt.Sprintf("s%d", i))
+		}
+		run(b, asrs)
+	})
+	b.Run("independent", func(b *testing.B) {
+		asrs := make([]ASRProvider, sessions)
+		for i := range asrs {
+			asrs[i] = &streamASR{}
+		}
+		run(b, asrs)
+	})
+}
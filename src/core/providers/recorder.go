@@ -0,0 +1,384 @@
+package providers
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"angrymiao-ai-server/src/core/types"
+
+	"github.com/angrymiao/go-openai"
+)
+
+/*
+* 提供者请求/响应录制与回放，用于集成测试。
+* 设置 DAYN_RECORD_DIR 环境变量后，LLM 与 TTS 的每次调用会以 NDJSON 追加写入录制目录；
+* 以 --replay-dir 启动时，直接从录制文件按请求哈希返回响应，不调用真实提供者。
+* 包装后的提供者只暴露 LLMProvider / TTSProvider 接口的方法，具体实现的扩展接口不可用。
+ */
+
+// RecordDirEnv 录制目录环境变量
+const RecordDirEnv = "DAYN_RECORD_DIR"
+
+const (
+	// RecordKindLLM LLM 录制：(messages, tools) -> 响应流
+	RecordKindLLM = "llm"
+	// RecordKindTTS TTS 录制：(text) -> 音频文件路径
+	RecordKindTTS = "tts"
+)
+
+// ErrRecordingNotFound 回放时找不到与请求匹配的录制记录
+var ErrRecordingNotFound = errors.New("recording not found")
+
+var replayDir string
+
+// SetReplayDir 设置回放目录，非空时由资源池创建回放提供者
+func SetReplayDir(dir string) {
+	replayDir = dir
+}
+
+// ReplayDir 获取回放目录
+func ReplayDir() string {
+	return replayDir
+}
+
+// RecordDir 获取录制目录，未设置 DAYN_RECORD_DIR 时为空
+func RecordDir() string {
+	return os.Getenv(RecordDirEnv)
+}
+
+// RecordingPath 返回指定类型的录制文件路径
+func RecordingPath(dir, kind string) string {
+	return filepath.Join(dir, kind+".ndjson")
+}
+
+// RecordEntry 录制文件中的一行
+type RecordEntry struct {
+	Key       string          `json:"key"`
+	Request   json.RawMessage `json:"request"`
+	Response  json.RawMessage `json:"response"`
+	Timestamp time.Time       `json:"timestamp"`
+}
+
+// RecordKey 计算请求的回放查找键
+func RecordKey(request interface{}) (string, error) {
+	data, err := json.Marshal(request)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// recordFile 同一录制文件由多个提供者实例共享，写入时加锁
+type recordFile struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+var (
+	recordFilesMu sync.Mutex
+	recordFiles   = map[string]*recordFile{}
+)
+
+func openRecordFile(path string) (*recordFile, error) {
+	recordFilesMu.Lock()
+	defer recordFilesMu.Unlock()
+	if f, ok := recordFiles[path]; ok {
+		return f, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("创建录制目录失败: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("打开录制文件失败: %w", err)
+	}
+	f := &recordFile{file: file}
+	recordFiles[path] = f
+	return f, nil
+}
+
+// RecordingProvider 包装提供者，将每次请求/响应写入 NDJSON 录制文件
+type RecordingProvider[T any] struct {
+	Inner T
+	out   *recordFile
+}
+
+// NewRecordingProvider 创建录制包装，kind 决定录制文件名
+func NewRecordingProvider[T any](inner T, dir, kind string) (*RecordingProvider[T], error) {
+	out, err := openRecordFile(RecordingPath(dir, kind))
+	if err != nil {
+		return nil, err
+	}
+	return &RecordingProvider[T]{Inner: inner, out: out}, nil
+}
+
+// Record 写入一条请求/响应记录
+func (r *RecordingProvider[T]) Record(request, response interface{}) error {
+	key, err := RecordKey(request)
+	if err != nil {
+		return err
+	}
+	req, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	resp, err := json.Marshal(response)
+	if err != nil {
+		return err
+	}
+	line, err := json.Marshal(RecordEntry{Key: key, Request: req, Response: resp, Timestamp: time.Now()})
+	if err != nil {
+		return err
+	}
+
+	r.out.mu.Lock()
+	defer r.out.mu.Unlock()
+	_, err = r.out.file.Write(append(line, '\n'))
+	return err
+}
+
+// Replayer 从录制文件读取响应，按请求哈希查找
+// 同一请求录制了多次时按录制顺序依次返回，用完后重复返回最后一次
+type Replayer[T any] struct {
+	mu      sync.Mutex
+	entries map[string][]T
+	served  map[string]int
+}
+
+// LoadReplayer 读取录制文件
+func LoadReplayer[T any](path string) (*Replayer[T], error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("打开录制文件失败: %w", err)
+	}
+	defer file.Close()
+
+	r := &Replayer[T]{entries: map[string][]T{}, served: map[string]int{}}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry RecordEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("录制文件第%d行格式错误: %w", line, err)
+		}
+		var resp T
+		if err := json.Unmarshal(entry.Response, &resp); err != nil {
+			return nil, fmt.Errorf("录制文件第%d行响应格式错误: %w", line, err)
+		}
+		r.entries[entry.Key] = append(r.entries[entry.Key], resp)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取录制文件失败: %w", err)
+	}
+	return r, nil
+}
+
+// Lookup 返回与请求匹配的录制响应
+func (r *Replayer[T]) Lookup(request interface{}) (T, error) {
+	var zero T
+	key, err := RecordKey(request)
+	if err != nil {
+		return zero, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	responses := r.entries[key]
+	if len(responses) == 0 {
+		return zero, fmt.Errorf("%w: %s", ErrRecordingNotFound, key)
+	}
+	i := r.served[key]
+	if i >= len(responses) {
+		i = len(responses) - 1
+	}
+	r.served[key] = i + 1
+	return responses[i], nil
+}
+
+// llmRecordRequest LLM 录制的请求部分，不包含会话ID以便跨会话回放
+type llmRecordRequest struct {
+	Messages []Message     `json:"messages"`
+	Tools    []openai.Tool `json:"tools,omitempty"`
+}
+
+// RecordingLLM 录制 LLM 调用
+type RecordingLLM struct {
+	LLMProvider
+	rec *RecordingProvider[LLMProvider]
+}
+
+// NewRecordingLLM 创建 LLM 录制包装
+func NewRecordingLLM(inner LLMProvider, dir string) (*RecordingLLM, error) {
+	rec, err := NewRecordingProvider(inner, dir, RecordKindLLM)
+	if err != nil {
+		return nil, err
+	}
+	return &RecordingLLM{LLMProvider: inner, rec: rec}, nil
+}
+
+// ResponseWithFunctions 转发响应流，流结束后写入完整响应
+func (p *RecordingLLM) ResponseWithFunctions(ctx context.Context, sessionID string, messages []Message, tools []openai.Tool) (<-chan types.Response, error) {
+	request := llmRecordRequest{Messages: messages, Tools: tools}
+	in, err := p.LLMProvider.ResponseWithFunctions(ctx, sessionID, messages, tools)
+	if err != nil {
+		return nil, err
+	}
+	out := make(chan types.Response)
+	go func() {
+		defer close(out)
+		var responses []types.Response
+		for resp := range in {
+			responses = append(responses, resp)
+			out <- resp
+		}
+		p.rec.Record(request, responses)
+	}()
+	return out, nil
+}
+
+// Response 转发文本响应流，按 ResponseWithFunctions 的格式录制
+func (p *RecordingLLM) Response(ctx context.Context, sessionID string, messages []Message) (<-chan string, error) {
+	request := llmRecordRequest{Messages: messages}
+	in, err := p.LLMProvider.Response(ctx, sessionID, messages)
+	if err != nil {
+		return nil, err
+	}
+	out := make(chan string)
+	go func() {
+		defer close(out)
+		var responses []types.Response
+		for content := range in {
+			responses = append(responses, types.Response{Content: content})
+			out <- content
+		}
+		p.rec.Record(request, responses)
+	}()
+	return out, nil
+}
+
+// ReplayLLM 从录制文件回放 LLM 响应
+type ReplayLLM struct {
+	replayer  *Replayer[[]types.Response]
+	sessionID string
+}
+
+// NewReplayLLM 加载 LLM 录制文件
+func NewReplayLLM(dir string) (*ReplayLLM, error) {
+	replayer, err := LoadReplayer[[]types.Response](RecordingPath(dir, RecordKindLLM))
+	if err != nil {
+		return nil, err
+	}
+	return &ReplayLLM{replayer: replayer}, nil
+}
+
+func (p *ReplayLLM) Initialize() error { return nil }
+func (p *ReplayLLM) Cleanup() error    { return nil }
+
+func (p *ReplayLLM) GetSessionID() string { return p.sessionID }
+
+func (p *ReplayLLM) SetIdentityFlag(idType string, flag string) {
+	if idType == "session" {
+		p.sessionID = flag
+	}
+}
+
+// Capabilities 回放时仍携带工具列表，保证请求与录制时一致
+func (p *ReplayLLM) Capabilities() map[string]bool {
+	return map[string]bool{types.CapabilityStreaming: true, types.CapabilityTools: true}
+}
+
+func (p *ReplayLLM) ResponseWithFunctions(ctx context.Context, sessionID string, messages []Message, tools []openai.Tool) (<-chan types.Response, error) {
+	responses, err := p.replayer.Lookup(llmRecordRequest{Messages: messages, Tools: tools})
+	if err != nil {
+		return nil, err
+	}
+	out := make(chan types.Response, len(responses))
+	for _, resp := range responses {
+		out <- resp
+	}
+	close(out)
+	return out, nil
+}
+
+func (p *ReplayLLM) Response(ctx context.Context, sessionID string, messages []Message) (<-chan string, error) {
+	responses, err := p.replayer.Lookup(llmRecordRequest{Messages: messages})
+	if err != nil {
+		return nil, err
+	}
+	out := make(chan string, len(responses))
+	for _, resp := range responses {
+		out <- resp.Content
+	}
+	close(out)
+	return out, nil
+}
+
+// ttsRecordRequest TTS 录制的请求部分
+type ttsRecordRequest struct {
+	Text string `json:"text"`
+}
+
+// RecordingTTS 录制 TTS 调用
+type RecordingTTS struct {
+	TTSProvider
+	rec *RecordingProvider[TTSProvider]
+}
+
+// NewRecordingTTS 创建 TTS 录制包装
+func NewRecordingTTS(inner TTSProvider, dir string) (*RecordingTTS, error) {
+	rec, err := NewRecordingProvider(inner, dir, RecordKindTTS)
+	if err != nil {
+		return nil, err
+	}
+	return &RecordingTTS{TTSProvider: inner, rec: rec}, nil
+}
+
+// ToTTS 合成成功后录制文本与音频文件路径
+func (p *RecordingTTS) ToTTS(text string) (string, error) {
+	path, err := p.TTSProvider.ToTTS(text)
+	if err != nil {
+		return "", err
+	}
+	p.rec.Record(ttsRecordRequest{Text: text}, path)
+	return path, nil
+}
+
+// ReplayTTS 从录制文件回放 TTS 音频文件路径
+type ReplayTTS struct {
+	replayer *Replayer[string]
+}
+
+// NewReplayTTS 加载 TTS 录制文件
+func NewReplayTTS(dir string) (*ReplayTTS, error) {
+	replayer, err := LoadReplayer[string](RecordingPath(dir, RecordKindTTS))
+	if err != nil {
+		return nil, err
+	}
+	return &ReplayTTS{replayer: replayer}, nil
+}
+
+func (p *ReplayTTS) Initialize() error           { return nil }
+func (p *ReplayTTS) Cleanup() error              { return nil }
+func (p *ReplayTTS) SetVoice(voice string) error { return nil }
+
+func (p *ReplayTTS) Capabilities() map[string]bool { return map[string]bool{} }
+
+func (p *ReplayTTS) CloneVoice(ctx context.Context, audioData []byte, name, language string) (string, error) {
+	return "", ErrVoiceCloneNotSupported
+}
+
+func (p *ReplayTTS) ToTTS(text string) (string, error) {
+	return p.replayer.Lookup(ttsRecordRequest{Text: text})
+}
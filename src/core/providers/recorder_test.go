@@ -0,0 +1,104 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"angrymiao-ai-server/src/core/types"
+
+	"github.com/angrymiao/go-openai"
+)
+
+// scriptedLLM 按轮次返回固定回复，并统计调用次数
+type scriptedLLM struct {
+	LLMProvider
+	calls int
+}
+
+func (m *scriptedLLM) ResponseWithFunctions(ctx context.Context, sessionID string, messages []Message, tools []openai.Tool) (<-chan types.Response, error) {
+	m.calls++
+	last := messages[len(messages)-1].Content
+	ch := make(chan types.Response, 2)
+	ch <- types.Response{Content: "关于" + last + "，"}
+	ch <- types.Response{Content: fmt.Sprintf("这是第%d轮回答", m.calls), StopReason: "stop"}
+	close(ch)
+	return ch, nil
+}
+
+type scriptedTTS struct {
+	TTSProvider
+	calls int
+}
+
+func (m *scriptedTTS) ToTTS(text string) (string, error) {
+	m.calls++
+	return fmt.Sprintf("/tmp/tts/%d.wav", m.calls), nil
+}
+
+// runConversation 进行3轮对话，返回每轮的回复与音频文件路径
+func runConversation(t *testing.T, llm LLMProvider, tts TTSProvider) ([]string, []string) {
+	t.Helper()
+	var messages []Message
+	var replies, audio []string
+	for _, question := range []string{"天气", "穿衣", "出行"} {
+		messages = append(messages, Message{Role: "user", Content: question})
+		ch, err := llm.ResponseWithFunctions(context.Background(), "session-1", messages, nil)
+		if err != nil {
+			t.Fatalf("ResponseWithFunctions: %v", err)
+		}
+		var reply strings.Builder
+		for resp := range ch {
+			reply.WriteString(resp.Content)
+		}
+		messages = append(messages, Message{Role: "assistant", Content: reply.String()})
+		path, err := tts.ToTTS(reply.String())
+		if err != nil {
+			t.Fatalf("ToTTS: %v", err)
+		}
+		replies = append(replies, reply.String())
+		audio = append(audio, path)
+	}
+	return replies, audio
+}
+
+func TestRecordAndReplayConversation(t *testing.T) {
+	dir := t.TempDir()
+	llm, tts := &scriptedLLM{}, &scriptedTTS{}
+	recordingLLM, err := NewRecordingLLM(llm, dir)
+	if err != nil {
+		t.Fatalf("NewRecordingLLM: %v", err)
+	}
+	recordingTTS, err := NewRecordingTTS(tts, dir)
+	if err != nil {
+		t.Fatalf("NewRecordingTTS: %v", err)
+	}
+	recordedReplies, recordedAudio := runConversation(t, recordingLLM, recordingTTS)
+	if llm.calls != 3 || tts.calls != 3 {
+		t.Fatalf("录制时调用次数 llm=%d tts=%d, 期望各3次", llm.calls, tts.calls)
+	}
+
+	replayLLM, err := NewReplayLLM(dir)
+	if err != nil {
+		t.Fatalf("NewReplayLLM: %v", err)
+	}
+	replayTTS, err := NewReplayTTS(dir)
+	if err != nil {
+		t.Fatalf("NewReplayTTS: %v", err)
+	}
+	replies, audio := runConversation(t, replayLLM, replayTTS)
+	if !reflect.DeepEqual(replies, recordedReplies) || !reflect.DeepEqual(audio, recordedAudio) {
+		t.Errorf("回放结果不一致:\n回复 %v\n录制 %v\n音频 %v\n录制 %v", replies, recordedReplies, audio, recordedAudio)
+	}
+	if llm.calls != 3 || tts.calls != 3 {
+		t.Errorf("回放时不应调用真实提供者, llm=%d tts=%d", llm.calls, tts.calls)
+	}
+
+	_, err = replayLLM.ResponseWithFunctions(context.Background(), "session-1", []Message{{Role: "user", Content: "未录制的问题"}}, nil)
+	if !errors.Is(err, ErrRecordingNotFound) {
+		t.Errorf("未录制的请求应返回 ErrRecordingNotFound, got %v", err)
+	}
+}
@@ -2,6 +2,7 @@ package deepgram
 
 import (
 	"angrymiao-ai-server/src/core/providers/tts"
+	"angrymiao-ai-server/src/core/types"
 	"bytes"
 	"encoding/json"
 	"fmt"
@@ -159,3 +160,11 @@ func init() {
 		return NewProvider(config, deleteFile)
 	})
 }
+
+// Capabilities Deepgram TTS按整段文本合成音频文件，不支持SSML
+func (p *Provider) Capabilities() map[string]bool {
+	return map[string]bool{
+		types.CapabilityStreaming: false,
+		types.CapabilitySSML:      false,
+	}
+}
@@ -0,0 +1,100 @@
+package doubao
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// voiceCloneURL 豆包声音复刻训练接口
+const voiceCloneURL = "https://openspeech.bytedance.com/api/v1/mega_tts/audio/upload"
+
+// cloneLanguages 声音复刻接口的语种编号
+var cloneLanguages = map[string]int{
+	"zh": 0,
+	"en": 1,
+	"ja": 2,
+	"es": 3,
+	"id": 4,
+	"pt": 5,
+}
+
+type cloneAudio struct {
+	AudioBytes  string `json:"audio_bytes"`
+	AudioFormat string `json:"audio_format"`
+}
+
+type cloneRequest struct {
+	AppID     string       `json:"appid"`
+	SpeakerID string       `json:"speaker_id"`
+	Audios    []cloneAudio `json:"audios"`
+	Source    int          `json:"source"`
+	Language  int          `json:"language"`
+	ModelType int          `json:"model_type"`
+}
+
+type cloneResponse struct {
+	BaseResp struct {
+		StatusCode    int    `json:"StatusCode"`
+		StatusMessage string `json:"StatusMessage"`
+	} `json:"BaseResp"`
+	SpeakerID string `json:"speaker_id"`
+}
+
+// CloneVoice 上传WAV录音训练复刻音色，name 为在豆包控制台获取的音色ID（S_开头）
+// 训练成功后返回的音色ID可直接作为 voice_type 使用
+func (p *Provider) CloneVoice(ctx context.Context, audioData []byte, name, language string) (string, error) {
+	lang, ok := cloneLanguages[language]
+	if !ok {
+		return "", fmt.Errorf("声音复刻不支持的语种: %s", language)
+	}
+	body, err := json.Marshal(cloneRequest{
+		AppID:     p.Config().AppID,
+		SpeakerID: name,
+		Audios:    []cloneAudio{{AudioBytes: base64.StdEncoding.EncodeToString(audioData), AudioFormat: "wav"}},
+		Source:    2,
+		Language:  lang,
+		ModelType: 1,
+	})
+	if err != nil {
+		return "", fmt.Errorf("序列化声音复刻请求失败: %v", err)
+	}
+
+	url := p.cloneURL
+	if url == "" {
+		url = voiceCloneURL
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("创建声音复刻请求失败: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer;%s", p.Config().Token))
+	req.Header.Set("Resource-Id", "volc.megatts.voiceclone")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("请求声音复刻接口失败: %v", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("读取声音复刻响应失败: %v", err)
+	}
+
+	var result cloneResponse
+	if err := json.Unmarshal(data, &result); err != nil {
+		return "", fmt.Errorf("解析声音复刻响应失败: %v, HTTP %d", err, resp.StatusCode)
+	}
+	if result.BaseResp.StatusCode != 0 {
+		return "", fmt.Errorf("声音复刻失败: %d %s", result.BaseResp.StatusCode, result.BaseResp.StatusMessage)
+	}
+	if result.SpeakerID == "" {
+		result.SpeakerID = name
+	}
+	return result.SpeakerID, nil
+}
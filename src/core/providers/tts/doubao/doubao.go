@@ -14,6 +14,8 @@ import (
 	"time"
 
 	"angrymiao-ai-server/src/core/providers/tts"
+	"angrymiao-ai-server/src/core/types"
+	"angrymiao-ai-server/src/core/utils"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
@@ -43,6 +45,12 @@ var (
 // reserved data: 0x00 (1 byte)
 var defaultHeader = []byte{0x11, 0x10, 0x11, 0x00}
 
+const (
+	// 流式合成输出的音频参数，与服务端下发音频的参数一致
+	streamSampleRate      = 16000
+	streamFrameDurationMs = 60
+)
+
 type synResp struct {
 	Audio  []byte
 	IsLast bool
@@ -51,7 +59,9 @@ type synResp struct {
 // Provider 豆包 TTS 提供者
 type Provider struct {
 	*tts.BaseProvider
-	baseURL string
+	tts.SpeechParams
+	baseURL  string
+	cloneURL string // 声音复刻接口地址，为空时使用 voiceCloneURL
 }
 
 // NewProvider 创建豆包 TTS 提供者
@@ -67,14 +77,64 @@ func NewProvider(config *tts.Config, deleteFile bool) (*Provider, error) {
 
 // ToTTS 实现文本到语音的转换
 func (p *Provider) ToTTS(text string) (string, error) {
+	conn, err := p.submit(text, map[string]interface{}{"encoding": "mp3"})
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	// 创建临时文件
+	outputDir := p.Config().OutputDir
+	if outputDir == "" {
+		outputDir = "tmp"
+	}
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return "", fmt.Errorf("创建输出目录失败: %v", err)
+	}
+
+	tempFile := filepath.Join(outputDir, fmt.Sprintf("doubao_tts_%d.mp3", time.Now().UnixNano()))
+	var audioData []byte
+
+	// 接收音频数据
+	for {
+		resp, err := p.readResponse(conn)
+		if err != nil {
+			return "", err
+		}
+
+		audioData = append(audioData, resp.Audio...)
+		if resp.IsLast {
+			break
+		}
+	}
+
+	// 写入音频文件
+	if err := os.WriteFile(tempFile, audioData, 0644); err != nil {
+		return "", fmt.Errorf("写入音频文件失败: %v", err)
+	}
+
+	return tempFile, nil
+}
+
+// submit 建立WebSocket连接并发送合成请求，audioParams 覆盖 audio 中的编码参数
+func (p *Provider) submit(text string, audioParams map[string]interface{}) (*websocket.Conn, error) {
+	speed, pitch := p.SpeechParams.SpeechParams()
 	// 创建WebSocket连接
 	header := http.Header{"Authorization": []string{fmt.Sprintf("Bearer;%s", p.Config().Token)}}
 	conn, _, err := websocket.DefaultDialer.Dial(p.baseURL, header)
 	if err != nil {
-		return "", fmt.Errorf("连接WebSocket服务器失败: %v", err)
+		return nil, fmt.Errorf("连接WebSocket服务器失败: %v", err)
 	}
-	defer conn.Close()
 
+	audio := map[string]interface{}{
+		"voice_type":   p.Config().Voice,
+		"speed_ratio":  speed,
+		"volume_ratio": 1.0,
+		"pitch_ratio":  tts.PitchRatio(pitch),
+	}
+	for key, value := range audioParams {
+		audio[key] = value
+	}
 	// 准备请求参数
 	reqParams := map[string]map[string]interface{}{
 		"app": {
@@ -85,13 +145,7 @@ func (p *Provider) ToTTS(text string) (string, error) {
 		"user": {
 			"uid": "uid",
 		},
-		"audio": {
-			"voice_type":   p.Config().Voice,
-			"encoding":     "mp3",
-			"speed_ratio":  1.0,
-			"volume_ratio": 1.0,
-			"pitch_ratio":  1.0,
-		},
+		"audio": audio,
 		"request": {
 			"reqid":     uuid.New().String(),
 			"text":      text,
@@ -103,13 +157,15 @@ func (p *Provider) ToTTS(text string) (string, error) {
 	// 序列化并压缩请求参数
 	jsonData, err := json.Marshal(reqParams)
 	if err != nil {
-		return "", fmt.Errorf("序列化请求参数失败: %v", err)
+		conn.Close()
+		return nil, fmt.Errorf("序列化请求参数失败: %v", err)
 	}
 
 	var b bytes.Buffer
 	w := gzip.NewWriter(&b)
 	if _, err := w.Write(jsonData); err != nil {
-		return "", fmt.Errorf("压缩请求数据失败: %v", err)
+		conn.Close()
+		return nil, fmt.Errorf("压缩请求数据失败: %v", err)
 	}
 	w.Close()
 	compressed := b.Bytes()
@@ -124,45 +180,77 @@ func (p *Provider) ToTTS(text string) (string, error) {
 
 	// 发送请求
 	if err := conn.WriteMessage(websocket.BinaryMessage, request); err != nil {
-		return "", fmt.Errorf("发送请求失败: %v", err)
+		conn.Close()
+		return nil, fmt.Errorf("发送请求失败: %v", err)
 	}
+	return conn, nil
+}
 
-	// 创建临时文件
-	outputDir := p.Config().OutputDir
-	if outputDir == "" {
-		outputDir = "tmp"
+// ToTTSStream 以16kHz PCM流式合成，收到的音频按60ms分帧编码为Opus后依次输出（实现 providers.StreamingTTSProvider）
+// 首个响应同步读取，鉴权等服务端错误直接返回，由调用方回退到 ToTTS
+func (p *Provider) ToTTSStream(text string) (<-chan []byte, error) {
+	conn, err := p.submit(text, map[string]interface{}{"encoding": "pcm", "rate": streamSampleRate})
+	if err != nil {
+		return nil, err
 	}
-	if err := os.MkdirAll(outputDir, 0755); err != nil {
-		return "", fmt.Errorf("创建输出目录失败: %v", err)
+	first, err := p.readResponse(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	encoder, err := utils.NewOpusEncoder(streamSampleRate, 1, 0)
+	if err != nil {
+		conn.Close()
+		return nil, err
 	}
 
-	tempFile := filepath.Join(outputDir, fmt.Sprintf("doubao_tts_%d.mp3", time.Now().UnixNano()))
-	var audioData []byte
-
-	// 接收音频数据
-	for {
-		_, message, err := conn.ReadMessage()
-		if err != nil {
-			return "", fmt.Errorf("接收响应失败: %v", err)
-		}
+	frames := make(chan []byte, 16)
+	go func() {
+		defer close(frames)
+		defer encoder.Close()
+		defer conn.Close()
 
-		resp, err := p.parseResponse(message)
-		if err != nil {
-			return "", fmt.Errorf("解析响应失败: %v", err)
+		frameBytes := streamSampleRate * 2 * streamFrameDurationMs / 1000
+		var pending []byte
+		resp := first
+		for {
+			pending = append(pending, resp.Audio...)
+			if resp.IsLast && len(pending)%frameBytes != 0 {
+				// 最后一帧不足时补静音
+				pending = append(pending, make([]byte, frameBytes-len(pending)%frameBytes)...)
+			}
+			for len(pending) >= frameBytes {
+				packet, err := encoder.Encode(pending[:frameBytes])
+				if err != nil {
+					fmt.Printf("豆包流式TTS编码Opus失败: %v\n", err)
+					return
+				}
+				frames <- packet
+				pending = pending[frameBytes:]
+			}
+			if resp.IsLast {
+				return
+			}
+			if resp, err = p.readResponse(conn); err != nil {
+				fmt.Printf("豆包流式TTS接收音频失败: %v\n", err)
+				return
+			}
 		}
+	}()
+	return frames, nil
+}
 
-		audioData = append(audioData, resp.Audio...)
-		if resp.IsLast {
-			break
-		}
+// readResponse 读取并解析一条服务端响应
+func (p *Provider) readResponse(conn *websocket.Conn) (synResp, error) {
+	_, message, err := conn.ReadMessage()
+	if err != nil {
+		return synResp{}, fmt.Errorf("接收响应失败: %v", err)
 	}
-
-	// 写入音频文件
-	if err := os.WriteFile(tempFile, audioData, 0644); err != nil {
-		return "", fmt.Errorf("写入音频文件失败: %v", err)
+	resp, err := p.parseResponse(message)
+	if err != nil {
+		return synResp{}, fmt.Errorf("解析响应失败: %v", err)
 	}
-
-	return tempFile, nil
+	return resp, nil
 }
 
 // parseResponse 解析服务器响应
@@ -217,3 +305,11 @@ func init() {
 		return NewProvider(config, deleteFile)
 	})
 }
+
+// Capabilities 豆包TTS支持流式输出Opus帧（见 ToTTSStream），请求使用纯文本
+func (p *Provider) Capabilities() map[string]bool {
+	return map[string]bool{
+		types.CapabilityStreaming: true,
+		types.CapabilitySSML:      false,
+	}
+}
@@ -0,0 +1,109 @@
+package doubao
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"angrymiao-ai-server/src/core/providers/tts"
+
+	"github.com/gorilla/websocket"
+)
+
+// audioResponse 构造豆包 audio-only 响应，seq 小于0表示最后一包
+func audioResponse(seq int32, audio []byte) []byte {
+	msg := []byte{0x11, 0xb1, 0x10, 0x00}
+	if seq < 0 {
+		msg[1] = 0xb3
+	}
+	msg = binary.BigEndian.AppendUint32(msg, uint32(seq))
+	msg = binary.BigEndian.AppendUint32(msg, uint32(len(audio)))
+	return append(msg, audio...)
+}
+
+// newStreamServer 模拟豆包TTS WebSocket接口，记录请求中的 audio 参数并依次返回音频分片
+func newStreamServer(t *testing.T, chunks ...[]byte) (*httptest.Server, chan map[string]interface{}) {
+	t.Helper()
+	requests := make(chan map[string]interface{}, 1)
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		gz, err := gzip.NewReader(bytes.NewReader(message[8:]))
+		if err != nil {
+			return
+		}
+		data, _ := io.ReadAll(gz)
+		var req map[string]map[string]interface{}
+		json.Unmarshal(data, &req)
+		requests <- req["audio"]
+		for i, chunk := range chunks {
+			seq := int32(i + 1)
+			if i == len(chunks)-1 {
+				seq = -seq
+			}
+			conn.WriteMessage(websocket.BinaryMessage, audioResponse(seq, chunk))
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, requests
+}
+
+func TestToTTSStreamEmitsOpusFrames(t *testing.T) {
+	// 1920字节为16kHz单声道60ms的PCM，两个分片共100ms，输出两帧（最后一帧补静音）
+	server, requests := newStreamServer(t, make([]byte, 2000), make([]byte, 1200))
+	p, _ := NewProvider(&tts.Config{Voice: "zh_female", Token: "token"}, false)
+	p.baseURL = "ws" + strings.TrimPrefix(server.URL, "http")
+
+	frames, err := p.ToTTSStream("你好")
+	if err != nil {
+		t.Fatalf("ToTTSStream: %v", err)
+	}
+	count := 0
+	for range frames {
+		count++
+	}
+	if count != 2 {
+		t.Errorf("输出 %d 帧, 期望 2", count)
+	}
+	audio := <-requests
+	if audio["encoding"] != "pcm" || audio["rate"] != float64(streamSampleRate) || audio["voice_type"] != "zh_female" {
+		t.Errorf("流式合成的 audio 参数 = %v", audio)
+	}
+}
+
+func TestToTTSStreamReturnsServerError(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.ReadMessage()
+		msg := []byte{0x11, 0xf0, 0x10, 0x00}
+		msg = binary.BigEndian.AppendUint32(msg, 3001)
+		msg = binary.BigEndian.AppendUint32(msg, 7)
+		conn.WriteMessage(websocket.BinaryMessage, append(msg, "invalid"...))
+	}))
+	t.Cleanup(server.Close)
+	p, _ := NewProvider(&tts.Config{Token: "bad"}, false)
+	p.baseURL = "ws" + strings.TrimPrefix(server.URL, "http")
+
+	if _, err := p.ToTTSStream("你好"); err == nil || !strings.Contains(err.Error(), "3001") {
+		t.Errorf("服务端错误应在首个响应时返回, got %v", err)
+	}
+}
@@ -2,7 +2,9 @@ package edge
 
 import (
 	"angrymiao-ai-server/src/core/providers/tts"
+	"angrymiao-ai-server/src/core/types"
 	"fmt"
+	"math"
 	"os"
 	"path/filepath"
 	"time"
@@ -13,6 +15,7 @@ import (
 // Provider Edge TTS提供者实现
 type Provider struct {
 	*tts.BaseProvider
+	tts.SpeechParams
 }
 
 // NewProvider 创建Edge TTS提供者
@@ -49,6 +52,13 @@ func (p *Provider) ToTTS(text string) (string, error) {
 	connOptions := []edge_tts.CommunicateOption{
 		edge_tts.SetVoice(voice),
 	}
+	if speed, pitch := p.SpeechParams.SpeechParams(); speed != 1 || pitch != 0 {
+		// edge 的语速与音调为相对默认值的百分比
+		connOptions = append(connOptions,
+			edge_tts.SetRate(fmt.Sprintf("%+d%%", int(math.Round(float64(speed-1)*100)))),
+			edge_tts.SetPitch(fmt.Sprintf("%+d%%", int(math.Round((tts.PitchRatio(pitch)-1)*100)))),
+		)
+	}
 
 	// 创建 Communicate 实例
 	conn, err := edge_tts.NewCommunicate(text, connOptions...)
@@ -88,3 +98,11 @@ func init() {
 		return NewProvider(config, deleteFile)
 	})
 }
+
+// Capabilities Edge TTS按整段文本合成音频文件，不支持SSML
+func (p *Provider) Capabilities() map[string]bool {
+	return map[string]bool{
+		types.CapabilityStreaming: false,
+		types.CapabilitySSML:      false,
+	}
+}
@@ -2,10 +2,12 @@ package gosherpa
 
 import (
 	"angrymiao-ai-server/src/core/providers/tts"
+	"angrymiao-ai-server/src/core/types"
 	"context"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -14,7 +16,8 @@ import (
 // Provider Sherpa TTS提供者实现
 type Provider struct {
 	*tts.BaseProvider
-	conn *websocket.Conn
+	conn   *websocket.Conn
+	connMu sync.Mutex // 共享连接上的请求需串行，避免TTS预取并发读写
 }
 
 // NewProvider 创建Sherpa TTS提供者
@@ -52,8 +55,10 @@ func (p *Provider) ToTTS(text string) (string, error) {
 	// Use a unique filename
 	tempFile := filepath.Join(outputDir, fmt.Sprintf("go_sherpa_tts_%d.wav", time.Now().UnixNano()))
 
+	p.connMu.Lock()
 	p.conn.WriteMessage(websocket.TextMessage, []byte(text))
 	_, bytes, err := p.conn.ReadMessage()
+	p.connMu.Unlock()
 
 	if err != nil {
 		return "", fmt.Errorf("go-sherpa-tts 获取音频流失败: %v", err)
@@ -83,3 +88,11 @@ func init() {
 		return NewProvider(config, deleteFile)
 	})
 }
+
+// Capabilities Sherpa TTS按整段文本合成音频文件，不支持SSML
+func (p *Provider) Capabilities() map[string]bool {
+	return map[string]bool{
+		types.CapabilityStreaming: false,
+		types.CapabilitySSML:      false,
+	}
+}
@@ -0,0 +1,53 @@
+package tts
+
+import (
+	"fmt"
+	"math"
+	"sync"
+)
+
+const (
+	// 语速倍率范围
+	MinSpeechSpeed = 0.5
+	MaxSpeechSpeed = 2.0
+	// 音调偏移范围（半音）
+	MinSpeechPitch = -12
+	MaxSpeechPitch = 12
+)
+
+// SpeechParams 语速与音调设置，嵌入到支持调整语速与音调的提供者中以实现 providers.SpeechParamsSetter
+type SpeechParams struct {
+	mu    sync.RWMutex
+	speed float32
+	pitch float32
+	set   bool
+}
+
+// SetSpeechParams 设置语速倍率与音调偏移的半音数，超出范围时返回错误
+func (p *SpeechParams) SetSpeechParams(speed, pitch float32) error {
+	if speed < MinSpeechSpeed || speed > MaxSpeechSpeed {
+		return fmt.Errorf("语速 %.2f 超出范围 [%.1f, %.1f]", speed, MinSpeechSpeed, MaxSpeechSpeed)
+	}
+	if pitch < MinSpeechPitch || pitch > MaxSpeechPitch {
+		return fmt.Errorf("音调 %.2f 超出范围 [%d, %d]", pitch, MinSpeechPitch, MaxSpeechPitch)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.speed, p.pitch, p.set = speed, pitch, true
+	return nil
+}
+
+// SpeechParams 获取当前的语速倍率与音调偏移，未设置时为 1 与 0
+func (p *SpeechParams) SpeechParams() (speed, pitch float32) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if !p.set {
+		return 1, 0
+	}
+	return p.speed, p.pitch
+}
+
+// PitchRatio 将半音偏移换算为音调倍率
+func PitchRatio(pitch float32) float64 {
+	return math.Pow(2, float64(pitch)/12)
+}
@@ -4,6 +4,7 @@ import (
 	"angrymiao-ai-server/src/configs"
 	"angrymiao-ai-server/src/core/providers"
 	"angrymiao-ai-server/src/core/utils"
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -102,6 +103,11 @@ func (p *BaseProvider) SetVoice(voice string) error {
 	return nil
 }
 
+// CloneVoice 默认不支持声音复刻
+func (p *BaseProvider) CloneVoice(ctx context.Context, audioData []byte, name, language string) (string, error) {
+	return "", providers.ErrVoiceCloneNotSupported
+}
+
 // Cleanup 清理资源
 func (p *BaseProvider) Cleanup() error {
 	if p.deleteFile {
@@ -130,6 +136,33 @@ func (p *BaseProvider) UpdateConfig(userConfig *Config) error {
 	return nil
 }
 
+// UpdateVoiceConfig 按用户配置更新音色相关字段，未设置的字段保持不变
+// 与 UpdateConfig 不同，不会覆盖输出目录、提供者类型等服务端配置
+func (p *BaseProvider) UpdateVoiceConfig(userConfig *Config) error {
+	if userConfig == nil {
+		return nil
+	}
+	if userConfig.Voice != "" {
+		p.config.Voice = userConfig.Voice
+	}
+	if userConfig.Format != "" {
+		p.config.Format = userConfig.Format
+	}
+	if userConfig.SampleRate > 0 {
+		p.config.SampleRate = userConfig.SampleRate
+	}
+	if userConfig.AppID != "" {
+		p.config.AppID = userConfig.AppID
+	}
+	if userConfig.Token != "" {
+		p.config.Token = userConfig.Token
+	}
+	if userConfig.Cluster != "" {
+		p.config.Cluster = userConfig.Cluster
+	}
+	return nil
+}
+
 // Factory TTS工厂函数类型
 type Factory func(config *Config, deleteFile bool) (Provider, error)
 
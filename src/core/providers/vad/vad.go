@@ -8,9 +8,11 @@ import (
 type Config struct {
 	Name           string
 	Type           string
-	Aggressiveness int                    // 0..3
-	FrameDuration  int                    // ms
-	Params         map[string]interface{} // 保留兼容性，暂不使用
+	Aggressiveness int // 0..3
+	FrameDuration  int // ms
+	// EnergyThreshold WebRTC VAD 不可用时能量检测的RMS阈值(0~1)，<=0 使用默认值
+	EnergyThreshold float64
+	Params          map[string]interface{} // 保留兼容性，暂不使用
 }
 
 // SetUserConfig 设置用户配置（覆盖当前配置）
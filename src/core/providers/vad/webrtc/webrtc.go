@@ -5,6 +5,7 @@ import (
 	"angrymiao-ai-server/src/core/utils"
 	"encoding/binary"
 	"fmt"
+	"math"
 	"sync"
 	"time"
 
@@ -18,8 +19,20 @@ const (
 	DefaultMode = 2
 	// FrameDuration 帧持续时间 (ms)，WebRTC VAD 支持 10ms, 20ms, 30ms
 	FrameDuration = 20
+	// DefaultEnergyThreshold 能量回退检测的默认RMS阈值，基于归一化到[-1.0, 1.0]的采样
+	DefaultEnergyThreshold = 0.02
 )
 
+const (
+	// ModeWebRTC 使用 WebRTC VAD 检测
+	ModeWebRTC = "webrtc"
+	// ModeEnergyFallback WebRTC VAD 初始化失败，使用能量阈值检测
+	ModeEnergyFallback = "energy_fallback"
+)
+
+// newWebrtcVAD 创建 WebRTC VAD 实例，测试中可替换以模拟初始化失败
+var newWebrtcVAD = webrtcvad.New
+
 // Provider 实现了基于 WebRTC VAD 的语音活动检测
 // 使用 go-webrtcvad 库提供真正的 WebRTC VAD 功能
 // 输入的 pcm 需为 16 位小端单声道 PCM
@@ -34,6 +47,10 @@ type Provider struct {
 	initialized    bool           // 是否已初始化
 	lastUsed       time.Time      // 最后使用时间
 	mu             sync.RWMutex   // 读写锁保证线程安全
+
+	energyThreshold float64   // 能量回退检测的RMS阈值
+	energyFallback  bool      // WebRTC VAD 初始化失败后改用能量检测
+	fallbackWarn    sync.Once // 能量回退只告警一次
 }
 
 // New 创建新的 WebRTC VAD Provider
@@ -56,17 +73,23 @@ func New(logger *utils.Logger, cfg *vad.Config) (*Provider, error) {
 		return nil, fmt.Errorf("unsupported sample rate: %d, supported rates: 8000, 16000, 32000, 48000", sampleRate)
 	}
 
+	energyThreshold := DefaultEnergyThreshold
+	if cfg.EnergyThreshold > 0 {
+		energyThreshold = cfg.EnergyThreshold
+	}
+
 	provider := &Provider{
-		logger:        logger,
-		sampleRate:    sampleRate,
-		mode:          mode,
-		frameDuration: frameDuration,
-		lastUsed:      time.Now(),
+		logger:          logger,
+		sampleRate:      sampleRate,
+		mode:            mode,
+		frameDuration:   frameDuration,
+		energyThreshold: energyThreshold,
+		lastUsed:        time.Now(),
 	}
 
-	// 初始化 WebRTC VAD
+	// 初始化 WebRTC VAD，失败时回退到能量检测
 	if err := provider.initialize(); err != nil {
-		return nil, fmt.Errorf("failed to initialize WebRTC VAD: %w", err)
+		provider.enableEnergyFallback(err)
 	}
 
 	return provider, nil
@@ -91,7 +114,7 @@ func (p *Provider) initialize() error {
 
 	// 创建 WebRTC VAD 实例
 	var err error
-	p.webrtcVad, err = webrtcvad.New()
+	p.webrtcVad, err = newWebrtcVAD()
 	if err != nil || p.webrtcVad == nil {
 		return fmt.Errorf("failed to create WebRTC VAD instance: %w", err)
 	}
@@ -140,11 +163,15 @@ func (p *Provider) Process(pcm []byte, sampleRate int, frameMs int) (bool, error
 		return false, nil
 	}
 
-	// 检查VAD实例是否已初始化
+	// 检查VAD实例是否已初始化，初始化失败时使用能量检测
 	if !p.initialized || p.webrtcVad == nil {
+		if p.isEnergyFallback() {
+			return p.processEnergy(pcm)
+		}
 		p.logger.Error("VAD实例未初始化，尝试重新初始化")
 		if err := p.initialize(); err != nil {
-			return false, fmt.Errorf("VAD初始化失败: %w", err)
+			p.enableEnergyFallback(err)
+			return p.processEnergy(pcm)
 		}
 	}
 
@@ -251,6 +278,46 @@ func (p *Provider) Process(pcm []byte, sampleRate int, frameMs int) (bool, error
 	return isActive, nil
 }
 
+// enableEnergyFallback 切换到能量检测模式
+func (p *Provider) enableEnergyFallback(err error) {
+	p.mu.Lock()
+	p.energyFallback = true
+	p.mu.Unlock()
+	p.fallbackWarn.Do(func() {
+		p.logger.Warn("WebRTC VAD初始化失败，回退到能量检测(阈值: %.4f): %v", p.energyThreshold, err)
+	})
+}
+
+func (p *Provider) isEnergyFallback() bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.energyFallback
+}
+
+// processEnergy 计算PCM帧的RMS能量，超过阈值判定为语音
+func (p *Provider) processEnergy(pcm []byte) (bool, error) {
+	samples := BytesToFloat32(pcm)
+	if samples == nil {
+		return false, fmt.Errorf("pcm data must be 16-bit (even number of bytes), got: %d", len(pcm))
+	}
+	p.lastUsed = time.Now()
+
+	var sum float64
+	for _, sample := range samples {
+		sum += float64(sample) * float64(sample)
+	}
+	rms := math.Sqrt(sum / float64(len(samples)))
+	return rms > p.energyThreshold, nil
+}
+
+// GetMode 返回当前检测模式: "webrtc" 或 "energy_fallback"
+func (p *Provider) GetMode() string {
+	if p.isEnergyFallback() {
+		return ModeEnergyFallback
+	}
+	return ModeWebRTC
+}
+
 // isValidSampleRate 检查采样率是否被 WebRTC VAD 支持
 func isValidSampleRate(sampleRate int) bool {
 	// WebRTC VAD 支持的采样率: 8000, 16000, 32000, 48000 Hz
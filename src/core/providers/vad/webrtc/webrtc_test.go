@@ -0,0 +1,47 @@
+package webrtc
+
+import (
+	"errors"
+	"testing"
+
+	"angrymiao-ai-server/src/core/providers/vad"
+	"angrymiao-ai-server/src/core/utils"
+
+	"github.com/hackers365/go-webrtcvad"
+)
+
+func TestEnergyFallbackWhenWebRTCInitFails(t *testing.T) {
+	logger, err := utils.NewLogger(&utils.LogCfg{LogLevel: "ERROR", LogDir: t.TempDir(), LogFile: "test.log"})
+	if err != nil {
+		t.Fatalf("创建日志失败: %v", err)
+	}
+	origNew := newWebrtcVAD
+	newWebrtcVAD = func() (*webrtcvad.VAD, error) { return nil, errors.New("init failed") }
+	defer func() { newWebrtcVAD = origNew }()
+
+	p, err := New(logger, &vad.Config{Aggressiveness: 2, EnergyThreshold: 0.05})
+	if err != nil {
+		t.Fatalf("初始化失败时应回退而不是返回错误: %v", err)
+	}
+	if mode := p.GetMode(); mode != ModeEnergyFallback {
+		t.Fatalf("GetMode() = %q, 期望 %q", mode, ModeEnergyFallback)
+	}
+
+	// 20ms@16kHz 方波，幅值0.5，RMS=0.5
+	loud := make([]float32, 320)
+	for i := range loud {
+		loud[i] = 0.5
+		if i%2 == 1 {
+			loud[i] = -0.5
+		}
+	}
+	if active, err := p.Process(Float32ToBytes(loud), 16000, 20); err != nil || !active {
+		t.Errorf("高能量帧应判定为语音: active=%v err=%v", active, err)
+	}
+	if active, err := p.Process(make([]byte, 640), 16000, 20); err != nil || active {
+		t.Errorf("静音帧不应判定为语音: active=%v err=%v", active, err)
+	}
+	if _, err := p.Process(make([]byte, 641), 16000, 20); err == nil {
+		t.Error("奇数字节的PCM应返回错误")
+	}
+}
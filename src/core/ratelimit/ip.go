@@ -0,0 +1,113 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// bucketIdleTTL 超过该时长未出现的令牌桶会被清理
+	bucketIdleTTL = 10 * time.Minute
+	// bucketPruneInterval 清理空闲令牌桶的间隔
+	bucketPruneInterval = time.Minute
+)
+
+// tokenBucket 单个键的令牌桶
+type tokenBucket struct {
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time // 上次补充令牌的时间，同时作为最近出现时间
+}
+
+// TokenBucketLimiter 按键的令牌桶限流，桶容量为允许突发的次数，令牌按每秒 rate 个匀速补充
+type TokenBucketLimiter struct {
+	capacity float64
+	rate     float64 // 每秒补充的令牌数
+	buckets  sync.Map
+	now      func() time.Time
+
+	stopOnce sync.Once
+	stop     chan struct{}
+}
+
+// NewTokenBucketLimiter 创建令牌桶限流器并启动空闲令牌桶的清理协程
+func NewTokenBucketLimiter(capacity, rate float64) *TokenBucketLimiter {
+	l := &TokenBucketLimiter{
+		capacity: capacity,
+		rate:     rate,
+		now:      time.Now,
+		stop:     make(chan struct{}),
+	}
+	go l.pruneLoop()
+	return l
+}
+
+// SetClock 替换获取当前时间的函数，测试中使用
+func (l *TokenBucketLimiter) SetClock(now func() time.Time) {
+	l.now = now
+}
+
+// Allow 消耗 key 的一个令牌，令牌不足时返回拒绝结果及补充一个令牌所需的时间
+func (l *TokenBucketLimiter) Allow(key string) Result {
+	now := l.now()
+	value, _ := l.buckets.LoadOrStore(key, &tokenBucket{tokens: l.capacity, last: now})
+	bucket := value.(*tokenBucket)
+
+	bucket.mu.Lock()
+	defer bucket.mu.Unlock()
+	if elapsed := now.Sub(bucket.last); elapsed > 0 {
+		bucket.tokens = min(l.capacity, bucket.tokens+elapsed.Seconds()*l.rate)
+	}
+	bucket.last = now
+
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return Result{Allowed: true}
+	}
+	wait := time.Duration((1 - bucket.tokens) / l.rate * float64(time.Second))
+	return Result{Allowed: false, RetryAfter: wait}
+}
+
+// Close 停止清理协程
+func (l *TokenBucketLimiter) Close() {
+	l.stopOnce.Do(func() { close(l.stop) })
+}
+
+// pruneLoop 定期清理空闲的令牌桶
+func (l *TokenBucketLimiter) pruneLoop() {
+	ticker := time.NewTicker(bucketPruneInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+			l.prune()
+		}
+	}
+}
+
+// prune 删除超过 bucketIdleTTL 未出现的令牌桶
+func (l *TokenBucketLimiter) prune() {
+	cutoff := l.now().Add(-bucketIdleTTL)
+	l.buckets.Range(func(key, value interface{}) bool {
+		bucket := value.(*tokenBucket)
+		bucket.mu.Lock()
+		idle := bucket.last.Before(cutoff)
+		bucket.mu.Unlock()
+		if idle {
+			l.buckets.Delete(key)
+		}
+		return true
+	})
+}
+
+// IPRateLimiter 按客户端IP的令牌桶限流，桶容量为每分钟允许的次数
+type IPRateLimiter struct {
+	*TokenBucketLimiter
+}
+
+// NewIPRateLimiter 创建IP限流器，perMinute 为每个IP每分钟允许的次数
+func NewIPRateLimiter(perMinute int) *IPRateLimiter {
+	return &IPRateLimiter{NewTokenBucketLimiter(float64(perMinute), float64(perMinute)/60)}
+}
@@ -0,0 +1,47 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIPRateLimiter(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	limiter := NewIPRateLimiter(10)
+	defer limiter.Close()
+	limiter.now = fixedClock(&now)
+
+	for i := 1; i <= 10; i++ {
+		if result := limiter.Allow("1.2.3.4"); !result.Allowed {
+			t.Fatalf("第%d次连接应放行", i)
+		}
+	}
+	result := limiter.Allow("1.2.3.4")
+	if result.Allowed || result.RetryAfterSeconds() != 6 {
+		t.Fatalf("超出限制的连接 = %+v, 期望拒绝并在6秒后重试", result)
+	}
+	if !limiter.Allow("5.6.7.8").Allowed {
+		t.Fatal("不同IP应分别计数")
+	}
+
+	// 每6秒补充一个令牌
+	now = now.Add(6 * time.Second)
+	if !limiter.Allow("1.2.3.4").Allowed {
+		t.Fatal("补充令牌后应放行")
+	}
+	if limiter.Allow("1.2.3.4").Allowed {
+		t.Fatal("补充的令牌已用完")
+	}
+
+	// 空闲超过10分钟的令牌桶被清理
+	now = now.Add(bucketIdleTTL + time.Second)
+	limiter.prune()
+	count := 0
+	limiter.buckets.Range(func(_, _ interface{}) bool {
+		count++
+		return true
+	})
+	if count != 0 {
+		t.Errorf("清理后剩余 %d 个令牌桶", count)
+	}
+}
@@ -0,0 +1,38 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryRateLimiter 单实例内存限流器，多实例部署时各实例分别计数
+type MemoryRateLimiter struct {
+	window
+
+	mu       sync.Mutex
+	counters map[string]int
+	start    int64 // counters 所属窗口的开始时间
+}
+
+// NewMemoryRateLimiter 创建内存限流器，每个 length 窗口内最多允许 limit 次请求
+func NewMemoryRateLimiter(limit int, length time.Duration) *MemoryRateLimiter {
+	return &MemoryRateLimiter{
+		window:   newWindow(limit, length),
+		counters: make(map[string]int),
+	}
+}
+
+func (l *MemoryRateLimiter) Allow(ctx context.Context, key string) (Result, error) {
+	start, remaining := l.current()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	// 进入新窗口时丢弃上一窗口的全部计数
+	if start != l.start {
+		l.start = start
+		l.counters = make(map[string]int)
+	}
+	l.counters[key]++
+	return l.result(l.counters[key], remaining), nil
+}
@@ -0,0 +1,111 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"angrymiao-ai-server/src/configs"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	BackendMemory = "memory"
+	BackendRedis  = "redis"
+
+	defaultWindow = time.Minute
+)
+
+// Result 一次限流判断的结果
+type Result struct {
+	Allowed    bool
+	Count      int           // 当前窗口内的请求数（含本次）
+	RetryAfter time.Duration // 被限流时距离窗口结束的时间
+}
+
+// RetryAfterSeconds 向上取整的重试等待秒数，用于 Retry-After 响应头
+func (r Result) RetryAfterSeconds() int {
+	return int((r.RetryAfter + time.Second - 1) / time.Second)
+}
+
+// RateLimiter 请求限流器，内存与Redis实现可互换
+type RateLimiter interface {
+	// Allow 记录一次请求并判断 key 在当前窗口内是否超出限制
+	Allow(ctx context.Context, key string) (Result, error)
+}
+
+// window 固定时间窗口的计算
+type window struct {
+	limit  int
+	length time.Duration
+	now    func() time.Time
+}
+
+func newWindow(limit int, length time.Duration) window {
+	if length < time.Second {
+		length = defaultWindow
+	}
+	return window{limit: limit, length: length, now: time.Now}
+}
+
+// current 返回当前窗口的开始时间(秒)与剩余时长
+func (w window) current() (int64, time.Duration) {
+	now := w.now()
+	seconds := int64(w.length / time.Second)
+	start := now.Unix() / seconds * seconds
+	return start, time.Unix(start+seconds, 0).Sub(now)
+}
+
+func (w window) result(count int, remaining time.Duration) Result {
+	if count <= w.limit {
+		return Result{Allowed: true, Count: count}
+	}
+	return Result{Allowed: false, Count: count, RetryAfter: remaining}
+}
+
+// counterKey 计数key：ratelimit:{userID}:{window_start}
+func counterKey(key string, start int64) string {
+	return fmt.Sprintf("ratelimit:%s:%d", key, start)
+}
+
+// New 根据配置创建限流器，未开启时返回nil
+func New(cfg configs.RateLimitConfig, client *redis.Client) (RateLimiter, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	if cfg.Limit <= 0 {
+		return nil, fmt.Errorf("限流次数必须大于0: %d", cfg.Limit)
+	}
+	length := time.Duration(cfg.WindowSeconds) * time.Second
+	switch cfg.Backend {
+	case "", BackendMemory:
+		return NewMemoryRateLimiter(cfg.Limit, length), nil
+	case BackendRedis:
+		if client == nil {
+			return nil, fmt.Errorf("限流后端为redis，但未配置redis_cache")
+		}
+		return NewRedisRateLimiter(client, cfg.Limit, length), nil
+	}
+	return nil, fmt.Errorf("不支持的限流后端: %s", cfg.Backend)
+}
+
+var (
+	mu      sync.RWMutex
+	limiter RateLimiter
+)
+
+// SetDefault 设置全局限流器，nil 表示不限流
+func SetDefault(l RateLimiter) {
+	mu.Lock()
+	defer mu.Unlock()
+	limiter = l
+}
+
+// Get 获取全局限流器，未开启限流时返回nil
+func Get() RateLimiter {
+	mu.RLock()
+	defer mu.RUnlock()
+	return limiter
+}
@@ -0,0 +1,86 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"angrymiao-ai-server/src/configs"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// fixedClock 返回可手动推进的时间
+func fixedClock(now *time.Time) func() time.Time {
+	return func() time.Time { return *now }
+}
+
+func testRateLimiter(t *testing.T, limiter RateLimiter, now *time.Time) {
+	t.Helper()
+	ctx := context.Background()
+
+	for i := 1; i <= 2; i++ {
+		result, err := limiter.Allow(ctx, "42")
+		if err != nil {
+			t.Fatalf("第%d次请求失败: %v", i, err)
+		}
+		if !result.Allowed || result.Count != i {
+			t.Fatalf("第%d次请求 = %+v, 期望放行", i, result)
+		}
+	}
+
+	result, err := limiter.Allow(ctx, "42")
+	if err != nil {
+		t.Fatalf("第3次请求失败: %v", err)
+	}
+	if result.Allowed {
+		t.Fatalf("超出限制的请求应被拒绝")
+	}
+	if result.RetryAfter != 45*time.Second || result.RetryAfterSeconds() != 45 {
+		t.Errorf("RetryAfter = %v, 期望 45s", result.RetryAfter)
+	}
+
+	// 其他用户单独计数
+	if result, _ := limiter.Allow(ctx, "43"); !result.Allowed {
+		t.Errorf("其他用户的请求不应被限流")
+	}
+
+	// 进入下一个窗口后重新计数
+	*now = now.Add(45 * time.Second)
+	if result, _ := limiter.Allow(ctx, "42"); !result.Allowed || result.Count != 1 {
+		t.Errorf("新窗口的请求 = %+v, 期望重新计数", result)
+	}
+}
+
+func TestMemoryRateLimiter(t *testing.T) {
+	now := time.Unix(1699999995, 0)
+	limiter := NewMemoryRateLimiter(2, time.Minute)
+	limiter.now = fixedClock(&now)
+	testRateLimiter(t, limiter, &now)
+}
+
+func TestRedisRateLimiter(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	now := time.Unix(1699999995, 0)
+	limiter := NewRedisRateLimiter(client, 2, time.Minute)
+	limiter.now = fixedClock(&now)
+	testRateLimiter(t, limiter, &now)
+
+	key := counterKey("42", 1699999980)
+	if ttl := mr.TTL(key); ttl != time.Minute {
+		t.Errorf("%s 过期时间 = %v, 期望 1m", key, ttl)
+	}
+	if value, _ := mr.Get(key); value != "3" {
+		t.Errorf("%s 计数 = %s, 期望 3", key, value)
+	}
+}
+
+func TestNewRedisBackendWithoutClient(t *testing.T) {
+	if _, err := New(configs.RateLimitConfig{Enabled: true, Backend: BackendRedis, Limit: 10, WindowSeconds: 60}, nil); err == nil {
+		t.Errorf("未配置Redis时应返回错误")
+	}
+}
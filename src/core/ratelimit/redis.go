@@ -0,0 +1,39 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// incrScript 计数并在首次写入时设置过期时间，保证INCR与EXPIRE的原子性
+var incrScript = redis.NewScript(`
+local counter = redis.call('INCR', KEYS[1])
+if counter == 1 then
+	redis.call('EXPIRE', KEYS[1], ARGV[1])
+end
+return counter
+`)
+
+// RedisRateLimiter 基于Redis的限流器，多个服务实例共享同一计数
+type RedisRateLimiter struct {
+	window
+	client *redis.Client
+}
+
+// NewRedisRateLimiter 创建Redis限流器，每个 length 窗口内最多允许 limit 次请求
+func NewRedisRateLimiter(client *redis.Client, limit int, length time.Duration) *RedisRateLimiter {
+	return &RedisRateLimiter{window: newWindow(limit, length), client: client}
+}
+
+func (l *RedisRateLimiter) Allow(ctx context.Context, key string) (Result, error) {
+	start, remaining := l.current()
+	seconds := int64(l.length / time.Second)
+	count, err := incrScript.Run(ctx, l.client, []string{counterKey(key, start)}, seconds).Int()
+	if err != nil {
+		return Result{}, fmt.Errorf("Redis限流计数失败: %v", err)
+	}
+	return l.result(count, remaining), nil
+}
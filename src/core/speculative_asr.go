@@ -0,0 +1,133 @@
+package core
+
+import (
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var speculativeASRResults = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "speculative_asr_results_total",
+	Help: "流式识别预计算的命中情况，hit 为最终结果与预计算时的部分结果一致",
+}, []string{"outcome"})
+
+// RegisterSpeculativeASRMetrics 将流式识别预计算指标注册到 Prometheus
+func RegisterSpeculativeASRMetrics(registerer prometheus.Registerer) error {
+	return registerer.Register(speculativeASRResults)
+}
+
+// speculativeSuffixes 部分结果以这些疑问语气结尾时，大概率已是完整的一句话
+var speculativeSuffixes = []string{"吗", "呢", "?", "？"}
+
+// SpeculativeResult 预先计算的意图判断
+type SpeculativeResult struct {
+	Quit       bool // 命中退出意图
+	QuickReply bool // 命中唤醒词快速回复
+}
+
+type speculation struct {
+	text   string
+	result SpeculativeResult
+}
+
+// SpeculativeProcessor 流式识别过程中对疑似完整的部分结果预先计算意图，
+// 最终结果与之一致时直接使用预计算结果
+type SpeculativeProcessor struct {
+	compute func(text string) SpeculativeResult
+
+	mu        sync.Mutex
+	pending   *speculation // 最近一次预计算的部分结果
+	confirmed *speculation // 被最终结果确认的预计算
+	hits      int64
+	misses    int64
+}
+
+// NewSpeculativeProcessor 创建预计算处理器，compute 不应有副作用
+func NewSpeculativeProcessor(compute func(text string) SpeculativeResult) *SpeculativeProcessor {
+	return &SpeculativeProcessor{compute: compute}
+}
+
+// normalizeSpeculativeText 比较部分结果与最终结果时忽略空白与标点，最终结果可能已恢复标点
+func normalizeSpeculativeText(text string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) || unicode.IsPunct(r) {
+			return -1
+		}
+		return r
+	}, text)
+}
+
+// OnPartial 部分结果以疑问语气词结尾时预先计算意图，返回是否进行了预计算
+func (p *SpeculativeProcessor) OnPartial(text string) bool {
+	if p == nil {
+		return false
+	}
+	text = strings.TrimSpace(text)
+	speculative := false
+	for _, suffix := range speculativeSuffixes {
+		if strings.HasSuffix(text, suffix) {
+			speculative = true
+			break
+		}
+	}
+	if !speculative {
+		return false
+	}
+	result := p.compute(text)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.pending = &speculation{text: normalizeSpeculativeText(text), result: result}
+	return true
+}
+
+// OnFinal 用最终结果确认预计算，一致时返回预计算结果；没有预计算时不计入命中率
+func (p *SpeculativeProcessor) OnFinal(text string) (SpeculativeResult, bool) {
+	if p == nil {
+		return SpeculativeResult{}, false
+	}
+	text = normalizeSpeculativeText(text)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	pending := p.pending
+	p.pending, p.confirmed = nil, nil
+	if pending == nil {
+		return SpeculativeResult{}, false
+	}
+	if pending.text != text {
+		p.misses++
+		speculativeASRResults.WithLabelValues("miss").Inc()
+		return SpeculativeResult{}, false
+	}
+	p.hits++
+	speculativeASRResults.WithLabelValues("hit").Inc()
+	p.confirmed = pending
+	return pending.result, true
+}
+
+// Lookup 返回已被最终结果确认的预计算结果
+func (p *SpeculativeProcessor) Lookup(text string) (SpeculativeResult, bool) {
+	if p == nil {
+		return SpeculativeResult{}, false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.confirmed == nil || p.confirmed.text != normalizeSpeculativeText(text) {
+		return SpeculativeResult{}, false
+	}
+	return p.confirmed.result, true
+}
+
+// HitRate 返回命中次数、未命中次数与命中率
+func (p *SpeculativeProcessor) HitRate() (hits, misses int64, rate float64) {
+	if p == nil {
+		return 0, 0, 0
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if total := p.hits + p.misses; total > 0 {
+		rate = float64(p.hits) / float64(total)
+	}
+	return p.hits, p.misses, rate
+}
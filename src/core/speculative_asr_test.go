@@ -0,0 +1,79 @@
+package core
+
+import (
+	"testing"
+
+	"angrymiao-ai-server/src/configs"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestSpeculativeProcessorHitRate(t *testing.T) {
+	hitsBefore := testutil.ToFloat64(speculativeASRResults.WithLabelValues("hit"))
+	missesBefore := testutil.ToFloat64(speculativeASRResults.WithLabelValues("miss"))
+
+	var computed []string
+	p := NewSpeculativeProcessor(func(text string) SpeculativeResult {
+		computed = append(computed, text)
+		return SpeculativeResult{Quit: text == "可以退出了吗"}
+	})
+
+	// 不以疑问语气词结尾的部分结果不预计算，最终结果不计入命中率
+	if p.OnPartial("今天天气") {
+		t.Error("非疑问结尾的部分结果不应预计算")
+	}
+	if _, hit := p.OnFinal("今天天气"); hit {
+		t.Error("没有预计算时不应命中")
+	}
+
+	// 最终结果与部分结果一致，直接使用预计算结果
+	if !p.OnPartial("可以退出了吗") {
+		t.Fatal("以“吗”结尾的部分结果应预计算")
+	}
+	result, hit := p.OnFinal(" 可以退出了吗")
+	if !hit || !result.Quit {
+		t.Errorf("OnFinal = %+v, %v, 期望命中且为退出意图", result, hit)
+	}
+	if result, ok := p.Lookup("可以退出了吗"); !ok || !result.Quit {
+		t.Errorf("Lookup = %+v, %v", result, ok)
+	}
+
+	// 最终结果与预计算不同，不使用预计算结果
+	p.OnPartial("明天会下雨吗")
+	p.OnPartial("明天会下雨呢？")
+	if _, hit := p.OnFinal("明天会下雨呢？还是晴天"); hit {
+		t.Error("最终结果与部分结果不同时不应命中")
+	}
+	if _, ok := p.Lookup("明天会下雨呢？还是晴天"); ok {
+		t.Error("未命中时不应返回预计算结果")
+	}
+	if _, ok := p.Lookup("可以退出了吗"); ok {
+		t.Error("新的最终结果应清除上一次确认的预计算")
+	}
+
+	if hits, misses, rate := p.HitRate(); hits != 1 || misses != 1 || rate != 0.5 {
+		t.Errorf("HitRate = %d, %d, %.2f, 期望 1, 1, 0.50", hits, misses, rate)
+	}
+	if len(computed) != 3 {
+		t.Errorf("预计算次数 = %d, 期望 3", len(computed))
+	}
+	if got := testutil.ToFloat64(speculativeASRResults.WithLabelValues("hit")) - hitsBefore; got != 1 {
+		t.Errorf("hit 计数增加 %v, 期望 1", got)
+	}
+	if got := testutil.ToFloat64(speculativeASRResults.WithLabelValues("miss")) - missesBefore; got != 1 {
+		t.Errorf("miss 计数增加 %v, 期望 1", got)
+	}
+}
+
+func TestSpeculateIntentsHasNoSideEffects(t *testing.T) {
+	h := newPrefixTestHandler(t, &configs.Config{CMDExit: []string{"可以退出了吗"}})
+	h.speculativeASR = NewSpeculativeProcessor(h.speculateIntents)
+
+	h.speculativeASR.OnPartial("可以退出了吗")
+	if result, hit := h.speculativeASR.OnFinal("可以退出了吗"); !hit || !result.Quit || result.QuickReply {
+		t.Errorf("预计算结果 = %+v, %v", result, hit)
+	}
+	if msgs := h.conn.(*recordingConn).messages; len(msgs) != 0 {
+		t.Errorf("预计算不应发送消息, got %d", len(msgs))
+	}
+}
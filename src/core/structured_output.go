@@ -0,0 +1,62 @@
+package core
+
+import (
+	"fmt"
+
+	"angrymiao-ai-server/src/core/providers"
+	"angrymiao-ai-server/src/core/utils"
+)
+
+const (
+	// structuredOutputRetries 结构化输出校验失败后的最大重试次数
+	structuredOutputRetries = 2
+
+	structuredOutputPrompt      = "Respond ONLY with valid JSON matching this schema: %s"
+	structuredOutputRetryPrompt = "Your previous response was invalid JSON. Please try again."
+)
+
+// hasResponseSchema 判断Bot是否配置了结构化输出Schema
+func hasResponseSchema(schema []byte) bool {
+	return len(schema) > 0 && string(schema) != "null"
+}
+
+// generateStructuredResponse 要求LLM按Schema输出JSON，校验失败时附带纠正提示重试
+// respond 负责调用LLM并返回完整回复；返回通过校验的JSON文本
+func (h *ConnectionHandler) generateStructuredResponse(
+	respond func(messages []providers.Message) (string, error),
+	messages []providers.Message,
+	schema []byte,
+) (string, error) {
+	compiled, err := utils.CompileJSONSchema(schema)
+	if err != nil {
+		return "", err
+	}
+
+	// 在系统提示词末尾追加Schema约束，没有系统提示词时新增一条
+	messages = append([]providers.Message(nil), messages...)
+	suffix := fmt.Sprintf(structuredOutputPrompt, string(schema))
+	if len(messages) > 0 && messages[0].Role == "system" {
+		messages[0].Content += "\n" + suffix
+	} else {
+		messages = append([]providers.Message{{Role: "system", Content: suffix}}, messages...)
+	}
+
+	var lastErr error
+	for attempt := 0; attempt <= structuredOutputRetries; attempt++ {
+		response, err := respond(messages)
+		if err != nil {
+			return "", err
+		}
+		result, err := utils.ValidateJSON(compiled, response)
+		if err == nil {
+			return result, nil
+		}
+		lastErr = err
+		h.logger.Warn("LLM结构化输出校验失败(第%d次): %v", attempt+1, err)
+		messages = append(messages,
+			providers.Message{Role: "assistant", Content: response},
+			providers.Message{Role: "user", Content: structuredOutputRetryPrompt},
+		)
+	}
+	return "", fmt.Errorf("LLM结构化输出重试%d次后仍无效: %v", structuredOutputRetries, lastErr)
+}
@@ -0,0 +1,84 @@
+package core
+
+import (
+	"strings"
+	"testing"
+
+	"angrymiao-ai-server/src/core/providers"
+	"angrymiao-ai-server/src/core/utils"
+)
+
+const weatherSchema = `{
+  "type": "object",
+  "properties": {"city": {"type": "string"}, "temp": {"type": "number"}},
+  "required": ["city", "temp"]
+}`
+
+// scriptedLLM 按顺序返回预设回复，并记录每次调用的消息
+type scriptedLLM struct {
+	replies []string
+	calls   [][]providers.Message
+}
+
+func (l *scriptedLLM) respond(messages []providers.Message) (string, error) {
+	l.calls = append(l.calls, messages)
+	reply := l.replies[len(l.calls)-1]
+	return reply, nil
+}
+
+func newStructuredTestHandler(t *testing.T) *ConnectionHandler {
+	logger, err := utils.NewLogger(&utils.LogCfg{LogLevel: "ERROR", LogDir: t.TempDir(), LogFile: "test.log"})
+	if err != nil {
+		t.Fatalf("创建日志失败: %v", err)
+	}
+	return &ConnectionHandler{logger: logger}
+}
+
+func TestGenerateStructuredResponse(t *testing.T) {
+	messages := []providers.Message{
+		{Role: "system", Content: "你是天气助手"},
+		{Role: "user", Content: "北京天气"},
+	}
+
+	tests := []struct {
+		name      string
+		replies   []string
+		wantCalls int
+		wantErr   bool
+	}{
+		{"首次通过", []string{`{"city":"北京","temp":21}`}, 1, false},
+		{"重试后通过", []string{"北京今天21度", "```json\n{\"city\":\"北京\",\"temp\":21}\n```"}, 2, false},
+		{"重试耗尽", []string{"21度", `{"city":"北京"}`, `{"temp":"21"}`}, 3, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newStructuredTestHandler(t)
+			llm := &scriptedLLM{replies: tt.replies}
+			result, err := h.generateStructuredResponse(llm.respond, messages, []byte(weatherSchema))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(llm.calls) != tt.wantCalls {
+				t.Errorf("LLM调用次数 = %d, 期望 %d", len(llm.calls), tt.wantCalls)
+			}
+			if !tt.wantErr && result != `{"city":"北京","temp":21}` {
+				t.Errorf("结果 = %q", result)
+			}
+
+			if !strings.Contains(llm.calls[0][0].Content, "Respond ONLY with valid JSON matching this schema") {
+				t.Errorf("系统提示词未追加Schema约束: %q", llm.calls[0][0].Content)
+			}
+			if len(llm.calls) > 1 {
+				retry := llm.calls[1]
+				if last := retry[len(retry)-1]; last.Content != structuredOutputRetryPrompt {
+					t.Errorf("重试消息 = %q", last.Content)
+				}
+			}
+		})
+	}
+
+	// 原消息列表不应被修改
+	if messages[0].Content != "你是天气助手" {
+		t.Errorf("原系统提示词被修改: %q", messages[0].Content)
+	}
+}
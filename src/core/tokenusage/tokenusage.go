@@ -0,0 +1,125 @@
+package tokenusage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"angrymiao-ai-server/src/models"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+// usageTTL 每日用量计数的过期时间，跨天后仍可查询前一天的用量
+const usageTTL = 48 * time.Hour
+
+// EstimateTokens 按每4个字符1个token估算文本的token数，不足4个字符按1个计
+func EstimateTokens(text string) int64 {
+	return int64(utf8.RuneCountInString(text)+3) / 4
+}
+
+// usageKey 计数key：token_usage:{userID}:{YYYYMMDD}
+func usageKey(userID string, day time.Time) string {
+	return fmt.Sprintf("token_usage:%s:%s", userID, day.Format("20060102"))
+}
+
+// Status 用户当日的token用量与预算
+type Status struct {
+	Used      int64     `json:"used"`
+	Budget    int64     `json:"budget"`    // 0 表示不限制
+	Remaining int64     `json:"remaining"` // 不限制时为 -1
+	ResetAt   time.Time `json:"reset_at"`
+}
+
+// Exceeds 再使用 tokens 个token是否超出预算
+func (s Status) Exceeds(tokens int64) bool {
+	return s.Budget > 0 && s.Used+tokens > s.Budget
+}
+
+// Tracker 基于Redis按用户按天累计LLM token用量，多个服务实例共享同一计数
+type Tracker struct {
+	client        *redis.Client
+	db            *gorm.DB // 可选，用于读取用户的每日预算
+	defaultBudget int64
+	now           func() time.Time
+}
+
+// NewTracker 创建token用量统计，defaultBudget 为未单独设置预算的用户的每日预算，0表示不限制
+func NewTracker(client *redis.Client, db *gorm.DB, defaultBudget int64) *Tracker {
+	return &Tracker{client: client, db: db, defaultBudget: defaultBudget, now: time.Now}
+}
+
+// Add 累加用户当日的token用量，返回累加后的用量
+func (t *Tracker) Add(ctx context.Context, userID string, tokens int64) (int64, error) {
+	key := usageKey(userID, t.now())
+	var incr *redis.IntCmd
+	_, err := t.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		incr = pipe.IncrBy(ctx, key, tokens)
+		pipe.Expire(ctx, key, usageTTL)
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("累加token用量失败: %v", err)
+	}
+	return incr.Val(), nil
+}
+
+// Status 查询用户当日的token用量与剩余预算
+func (t *Tracker) Status(ctx context.Context, userID string) (Status, error) {
+	now := t.now()
+	used, err := t.client.Get(ctx, usageKey(userID, now)).Int64()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return Status{}, fmt.Errorf("查询token用量失败: %v", err)
+	}
+	status := Status{Used: used, Budget: t.budget(ctx, userID), Remaining: -1, ResetAt: nextDay(now)}
+	if status.Budget > 0 {
+		status.Remaining = status.Budget - used
+		if status.Remaining < 0 {
+			status.Remaining = 0
+		}
+	}
+	return status, nil
+}
+
+// budget 用户的每日预算，用户单独设置了 DailyTokenBudget 时优先使用
+func (t *Tracker) budget(ctx context.Context, userID string) int64 {
+	if t.db == nil {
+		return t.defaultBudget
+	}
+	var override int64
+	err := t.db.WithContext(ctx).Model(&models.User{}).Select("daily_token_budget").
+		Where("id = ?", userID).Scan(&override).Error
+	if err != nil || override <= 0 {
+		return t.defaultBudget
+	}
+	return override
+}
+
+// nextDay 返回次日零点，即当日用量的重置时间
+func nextDay(now time.Time) time.Time {
+	year, month, day := now.Date()
+	return time.Date(year, month, day+1, 0, 0, 0, 0, now.Location())
+}
+
+var (
+	mu      sync.RWMutex
+	tracker *Tracker
+)
+
+// SetDefault 设置全局token用量统计，nil 表示不统计
+func SetDefault(t *Tracker) {
+	mu.Lock()
+	defer mu.Unlock()
+	tracker = t
+}
+
+// Get 获取全局token用量统计，未配置Redis时返回nil
+func Get() *Tracker {
+	mu.RLock()
+	defer mu.RUnlock()
+	return tracker
+}
@@ -0,0 +1,96 @@
+package tokenusage
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"angrymiao-ai-server/src/models"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newTestTracker(t *testing.T, db *gorm.DB, defaultBudget int64) (*Tracker, *miniredis.Miniredis) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	tracker := NewTracker(redis.NewClient(&redis.Options{Addr: server.Addr()}), db, defaultBudget)
+	now := time.Date(2026, 3, 8, 21, 30, 0, 0, time.Local)
+	tracker.now = func() time.Time { return now }
+	return tracker, server
+}
+
+func TestEstimateTokens(t *testing.T) {
+	for text, want := range map[string]int64{"": 0, "你好": 1, "今天天气很好": 2, "hello world!": 3} {
+		if got := EstimateTokens(text); got != want {
+			t.Errorf("EstimateTokens(%q) = %d, 期望 %d", text, got, want)
+		}
+	}
+}
+
+func TestTrackerAddAndStatus(t *testing.T) {
+	tracker, server := newTestTracker(t, nil, 100)
+	ctx := context.Background()
+
+	if _, err := tracker.Add(ctx, "42", 30); err != nil {
+		t.Fatalf("累加用量失败: %v", err)
+	}
+	if total, _ := tracker.Add(ctx, "42", 50); total != 80 {
+		t.Errorf("累加后用量 = %d, 期望 80", total)
+	}
+	if ttl := server.TTL("token_usage:42:20260308"); ttl != usageTTL {
+		t.Errorf("计数过期时间 = %v, 期望 %v", ttl, usageTTL)
+	}
+
+	status, err := tracker.Status(ctx, "42")
+	if err != nil {
+		t.Fatalf("查询用量失败: %v", err)
+	}
+	if status.Used != 80 || status.Budget != 100 || status.Remaining != 20 {
+		t.Errorf("用量 = %+v", status)
+	}
+	if !status.ResetAt.Equal(time.Date(2026, 3, 9, 0, 0, 0, 0, time.Local)) {
+		t.Errorf("重置时间 = %v, 期望次日零点", status.ResetAt)
+	}
+	if status.Exceeds(20) || !status.Exceeds(21) {
+		t.Errorf("预算判断错误: %+v", status)
+	}
+
+	// 其他用户单独计数
+	if other, _ := tracker.Status(ctx, "43"); other.Used != 0 || other.Remaining != 100 {
+		t.Errorf("其他用户用量 = %+v", other)
+	}
+}
+
+func TestTrackerUnlimitedBudget(t *testing.T) {
+	tracker, _ := newTestTracker(t, nil, 0)
+	tracker.Add(context.Background(), "42", 1000)
+
+	status, _ := tracker.Status(context.Background(), "42")
+	if status.Remaining != -1 || status.Exceeds(1<<40) {
+		t.Errorf("未配置预算时不应限制: %+v", status)
+	}
+}
+
+func TestTrackerUserBudgetOverride(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "users.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("打开测试数据库失败: %v", err)
+	}
+	if err := db.AutoMigrate(&models.User{}); err != nil {
+		t.Fatalf("迁移失败: %v", err)
+	}
+	db.Create(&models.User{ID: 42, Username: "vip", DailyTokenBudget: 5000})
+	db.Create(&models.User{ID: 43, Username: "normal"})
+	tracker, _ := newTestTracker(t, db, 100)
+
+	if status, _ := tracker.Status(context.Background(), "42"); status.Budget != 5000 {
+		t.Errorf("单独设置预算的用户 = %d, 期望 5000", status.Budget)
+	}
+	if status, _ := tracker.Status(context.Background(), "43"); status.Budget != 100 {
+		t.Errorf("未单独设置预算的用户 = %d, 期望使用全局预算 100", status.Budget)
+	}
+}
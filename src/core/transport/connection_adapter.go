@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"sync"
 	"sync/atomic"
 
 	"angrymiao-ai-server/src/configs"
@@ -16,15 +17,13 @@ import (
 
 // ConnectionContextAdapter 连接上下文适配器，完全兼容现有的ConnectionContext逻辑
 type ConnectionContextAdapter struct {
-	handler     *core.ConnectionHandler
-	providerSet *pool.ProviderSet
-	poolManager *pool.PoolManager
-	clientID    string
-	logger      *utils.Logger
-	conn        Connection
-	ctx         context.Context
-	cancel      context.CancelFunc
-	closed      int32 // 原子操作标志，0=活跃，1=已关闭
+	handler  *core.ConnectionHandler
+	clientID string
+	logger   *utils.Logger
+	conn     Connection
+	ctx      context.Context
+	cancel   context.CancelFunc
+	closed   int32 // 原子操作标志，0=活跃，1=已关闭
 }
 
 // NewConnectionContextAdapter 创建新的连接上下文适配器
@@ -46,17 +45,16 @@ func NewConnectionContextAdapter(
 	// 注入依赖：用户配置服务与任务管理器
 	handler.SetUserConfigService(userConfigService)
 	handler.SetTaskManager(taskMgr)
+	handler.SetPoolManager(poolManager)
 
 	adapter := &ConnectionContextAdapter{
-		handler:     handler,
-		providerSet: providerSet,
-		poolManager: poolManager,
-		clientID:    clientID,
-		logger:      logger,
-		conn:        conn,
-		ctx:         connCtx,
-		cancel:      connCancel,
-		closed:      0,
+		handler:  handler,
+		clientID: clientID,
+		logger:   logger,
+		conn:     conn,
+		ctx:      connCtx,
+		cancel:   connCancel,
+		closed:   0,
 	}
 
 	// 设置TaskManager和回调
@@ -93,9 +91,13 @@ func (a *ConnectionContextAdapter) Close() {
 		a.conn.Close()
 	}
 
-	// 归还资源到池中
-	if a.providerSet != nil && a.poolManager != nil {
-		if err := a.poolManager.ReturnProviderSet(a.providerSet); err != nil {
+	// 归还资源到借出它的资源池，连接处理器可能已换用用户私有的提供者或热升级后的新资源池
+	var providerSet *pool.ProviderSet
+	if a.handler != nil {
+		providerSet = a.handler.ProviderSet()
+	}
+	if providerSet != nil {
+		if err := providerSet.ReleaseBack(); err != nil {
 			a.logger.Error("客户端 %s 归还资源失败: %v", a.clientID, err)
 		} else {
 			a.logger.Info("客户端 %s 资源已成功归还到池中", a.clientID)
@@ -108,6 +110,64 @@ func (a *ConnectionContextAdapter) GetSessionID() string {
 	return a.clientID
 }
 
+// GetTalkRound 获取当前对话轮次
+func (a *ConnectionContextAdapter) GetTalkRound() int {
+	return a.handler.GetTalkRound()
+}
+
+// AudioQuality 获取会话音频质量指标
+func (a *ConnectionContextAdapter) AudioQuality() core.AudioQualitySnapshot {
+	return a.handler.AudioQuality()
+}
+
+// AudioCodecStats 获取会话Opus解码统计
+func (a *ConnectionContextAdapter) AudioCodecStats() core.AudioCodecSnapshot {
+	return a.handler.AudioCodecStats()
+}
+
+// SessionState 获取会话状态快照
+func (a *ConnectionContextAdapter) SessionState() core.SessionStateSnapshot {
+	return a.handler.SessionState()
+}
+
+// AudioLatency 获取会话延迟归因统计
+func (a *ConnectionContextAdapter) AudioLatency() core.AudioLatencyReport {
+	return a.handler.AudioLatency()
+}
+
+// TTSQueueDepth 获取等待合成的TTS任务数
+func (a *ConnectionContextAdapter) TTSQueueDepth() int {
+	return a.handler.TTSQueueDepth()
+}
+
+// ResumeToolCallback 将MCP工具回调结果交给连接处理器
+func (a *ConnectionContextAdapter) ResumeToolCallback(toolID string, result string) error {
+	return a.handler.ResumeToolCallback(toolID, result)
+}
+
+// ProactiveSpeak 服务端主动向客户端播报文本
+func (a *ConnectionContextAdapter) ProactiveSpeak(text string, voice string) (bool, error) {
+	return a.handler.ProactiveSpeak(text, voice)
+}
+
+// FailedTTS 获取最近合成失败的TTS分段
+func (a *ConnectionContextAdapter) FailedTTS() []core.FailedTTSEntry {
+	return a.handler.FailedTTS()
+}
+
+// RetryFailedTTS 重试合成失败的TTS分段
+func (a *ConnectionContextAdapter) RetryFailedTTS(textIndex int) error {
+	return a.handler.RetryFailedTTS(textIndex)
+}
+
+// WriteMessage 直接向客户端发送消息，供服务端主动推送使用
+func (a *ConnectionContextAdapter) WriteMessage(messageType int, data []byte) error {
+	if !a.IsActive() || a.conn == nil {
+		return fmt.Errorf("客户端 %s 连接已关闭", a.clientID)
+	}
+	return a.conn.WriteMessage(messageType, data)
+}
+
 // IsActive 检查连接是否仍然活跃
 func (a *ConnectionContextAdapter) IsActive() bool {
 	return atomic.LoadInt32(&a.closed) == 0
@@ -151,11 +211,14 @@ func (a *ConnectionContextAdapter) CreateSafeCallback() func(func(*core.Connecti
 
 // DefaultConnectionHandlerFactory 默认连接处理器工厂
 type DefaultConnectionHandlerFactory struct {
+	poolMu            sync.RWMutex // 保护 config 与 poolManager，热更新时替换
 	config            *configs.Config
 	poolManager       *pool.PoolManager
 	taskMgr           *task.TaskManager
 	logger            *utils.Logger
 	userConfigService botconfig.Service
+
+	tenantPools *pool.TenantPools // 多租户部署时按租户隔离的资源池，请求头 Tenant-Id 非空时使用
 }
 
 // NewDefaultConnectionHandlerFactory 创建默认连接处理器工厂
@@ -175,13 +238,70 @@ func NewDefaultConnectionHandlerFactory(
 	}
 }
 
+// Reload 同时替换新连接使用的配置快照与资源池管理器，pm 为nil时保留原资源池
+// 返回被替换的旧管理器；已建立的连接继续持有旧配置与旧管理器，断开时资源归还到旧池
+func (f *DefaultConnectionHandlerFactory) Reload(cfg *configs.Config, pm *pool.PoolManager) *pool.PoolManager {
+	f.poolMu.Lock()
+	defer f.poolMu.Unlock()
+	f.config = cfg
+	if pm == nil {
+		return nil
+	}
+	old := f.poolManager
+	f.poolManager = pm
+	return old
+}
+
+// SetTenantPools 设置新连接使用的租户资源池，返回被替换的旧租户资源池
+func (f *DefaultConnectionHandlerFactory) SetTenantPools(tp *pool.TenantPools) *pool.TenantPools {
+	f.poolMu.Lock()
+	defer f.poolMu.Unlock()
+	old := f.tenantPools
+	f.tenantPools = tp
+	return old
+}
+
+// currentPoolManager 新连接使用的资源池管理器，热升级后已建立的连接在对话轮次结束时换用
+func (f *DefaultConnectionHandlerFactory) currentPoolManager() *pool.PoolManager {
+	f.poolMu.RLock()
+	defer f.poolMu.RUnlock()
+	return f.poolManager
+}
+
+// ProviderPoolStats 获取当前资源池的统计信息
+func (f *DefaultConnectionHandlerFactory) ProviderPoolStats() map[string]pool.PoolStats {
+	f.poolMu.RLock()
+	defer f.poolMu.RUnlock()
+	if f.poolManager == nil {
+		return map[string]pool.PoolStats{}
+	}
+	return f.poolManager.ProviderPoolStats()
+}
+
 // CreateHandler 实现ConnectionHandlerFactory接口
 func (f *DefaultConnectionHandlerFactory) CreateHandler(
 	conn Connection,
 	req *http.Request,
 ) ConnectionHandler {
+	f.poolMu.RLock()
+	config := f.config
+	poolManager := f.poolManager
+	tenantPools := f.tenantPools
+	f.poolMu.RUnlock()
+
+	// 多租户连接使用租户的配置与资源池，断开时资源归还到租户的资源池
+	tenantID := req.Header.Get("Tenant-Id")
+	if tenantID != "" && tenantPools != nil {
+		var err error
+		config, poolManager, err = tenantPools.Get(tenantID)
+		if err != nil {
+			f.logger.Error("获取租户资源池失败: %v", err)
+			return nil
+		}
+	}
+
 	// 从资源池获取提供者集合
-	providerSet, err := f.poolManager.GetProviderSet()
+	providerSet, err := poolManager.GetProviderSet()
 	if err != nil {
 		f.logger.Error(fmt.Sprintf("获取提供者集合失败: %v", err))
 		return nil
@@ -190,7 +310,7 @@ func (f *DefaultConnectionHandlerFactory) CreateHandler(
 	//检查conn是否有属性mcpManager
 	if holder, ok := conn.(MCPManagerHolder); ok {
 		if mgr := holder.GetMCPManager(); mgr != nil {
-			f.poolManager.ReturnMcpManager(providerSet.MCP)
+			poolManager.ReturnMcpManager(providerSet.MCP)
 			providerSet.MCP = mgr
 			f.logger.Info("使用已有的MCPManager创建handler")
 		} else {
@@ -203,14 +323,18 @@ func (f *DefaultConnectionHandlerFactory) CreateHandler(
 	// 创建连接上下文适配器
 	adapter := NewConnectionContextAdapter(
 		conn,
-		f.config,
+		config,
 		providerSet,
-		f.poolManager,
+		poolManager,
 		f.taskMgr,
 		f.logger,
 		req,
 		f.userConfigService,
 	)
+	// 租户连接的资源池按租户管理，不参与全局资源池的热升级
+	if tenantID == "" || tenantPools == nil {
+		adapter.handler.SetPoolSource(f.currentPoolManager)
+	}
 
 	return adapter
 }
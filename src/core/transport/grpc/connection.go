@@ -0,0 +1,122 @@
+package grpc
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// ErrClosed 连接已关闭
+var ErrClosed = fmt.Errorf("connection closed")
+
+// GrpcConnection 将 ChatStream 双向流适配为 core.Connection
+type GrpcConnection struct {
+	id         string
+	stream     grpc.ServerStream
+	sendMu     sync.Mutex // ServerStream.SendMsg 不能并发调用，也不能在 ChatStream 返回后调用
+	incoming   chan *ClientMessage
+	done       chan struct{}
+	closeOnce  sync.Once
+	closed     int32
+	lastActive int64
+}
+
+// NewGrpcConnection 创建gRPC流连接
+func NewGrpcConnection(id string, stream grpc.ServerStream) *GrpcConnection {
+	c := &GrpcConnection{
+		id:       id,
+		stream:   stream,
+		incoming: make(chan *ClientMessage, 64),
+		done:     make(chan struct{}),
+	}
+	c.touch()
+	return c
+}
+
+func (c *GrpcConnection) touch() {
+	atomic.StoreInt64(&c.lastActive, time.Now().Unix())
+}
+
+func (c *GrpcConnection) GetID() string {
+	return c.id
+}
+
+func (c *GrpcConnection) GetType() string {
+	return "grpc"
+}
+
+// WriteMessage messageType 为1时作为文本消息发送，其他作为音频帧发送
+func (c *GrpcConnection) WriteMessage(messageType int, data []byte) error {
+	if c.IsClosed() {
+		return ErrClosed
+	}
+	msg := &ServerMessage{Audio: data}
+	if messageType == 1 {
+		msg = &ServerMessage{Text: string(data)}
+	}
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+	if c.IsClosed() {
+		return ErrClosed
+	}
+	if err := c.stream.SendMsg(msg); err != nil {
+		return fmt.Errorf("发送gRPC消息失败: %v", err)
+	}
+	c.touch()
+	return nil
+}
+
+// ReadMessage 读取上行消息，文本消息返回类型1，音频帧返回类型2
+func (c *GrpcConnection) ReadMessage(stopChan <-chan struct{}) (int, []byte, error) {
+	select {
+	case <-stopChan:
+		return 0, nil, ErrClosed
+	case <-c.done:
+		return 0, nil, ErrClosed
+	case msg := <-c.incoming:
+		c.touch()
+		if msg.Audio != nil {
+			return 2, msg.Audio, nil
+		}
+		return 1, []byte(msg.Text), nil
+	}
+}
+
+// pushIncoming 由流的接收协程调用，连接关闭后丢弃
+func (c *GrpcConnection) pushIncoming(msg *ClientMessage) {
+	select {
+	case c.incoming <- msg:
+	case <-c.done:
+	}
+}
+
+// Done 连接关闭时关闭的通道，ChatStream 据此结束流
+func (c *GrpcConnection) Done() <-chan struct{} {
+	return c.done
+}
+
+func (c *GrpcConnection) Close() error {
+	c.closeOnce.Do(func() {
+		// 等待进行中的发送完成，关闭后不再调用 SendMsg
+		c.sendMu.Lock()
+		atomic.StoreInt32(&c.closed, 1)
+		c.sendMu.Unlock()
+		close(c.done)
+	})
+	return nil
+}
+
+func (c *GrpcConnection) IsClosed() bool {
+	return atomic.LoadInt32(&c.closed) == 1
+}
+
+func (c *GrpcConnection) GetLastActiveTime() time.Time {
+	return time.Unix(atomic.LoadInt64(&c.lastActive), 0)
+}
+
+func (c *GrpcConnection) IsStale(timeout time.Duration) bool {
+	return time.Since(c.GetLastActiveTime()) > timeout
+}
@@ -0,0 +1,44 @@
+package grpc
+
+import (
+	"google.golang.org/grpc"
+)
+
+// chatStreamMethod 双向流方法，服务定义见 chat.proto
+const chatStreamMethod = "/chat.ChatService/ChatStream"
+
+// ClientMessage 设备上行消息，对应 chat.proto 中的 ClientMessage
+type ClientMessage struct {
+	Text  string `json:"text,omitempty"`  // 与WebSocket文本帧相同的JSON消息
+	Audio []byte `json:"audio,omitempty"` // 与WebSocket二进制帧相同的音频帧
+}
+
+// ServerMessage 服务端下行消息，对应 chat.proto 中的 ServerMessage
+type ServerMessage struct {
+	Text  string `json:"text,omitempty"`
+	Audio []byte `json:"audio,omitempty"`
+}
+
+// chatServer chat.ChatService 的服务端实现
+type chatServer interface {
+	ChatStream(stream grpc.ServerStream) error
+}
+
+// chatServiceDesc 与 chat.proto 中的 ChatService 保持一致
+var chatServiceDesc = grpc.ServiceDesc{
+	ServiceName: "chat.ChatService",
+	HandlerType: (*chatServer)(nil),
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ChatStream",
+			Handler:       chatStreamHandler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "chat.proto",
+}
+
+func chatStreamHandler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(chatServer).ChatStream(stream)
+}
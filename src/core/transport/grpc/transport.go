@@ -0,0 +1,264 @@
+package grpc
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"angrymiao-ai-server/src/configs"
+	"angrymiao-ai-server/src/core/auth"
+	"angrymiao-ai-server/src/core/governor"
+	"angrymiao-ai-server/src/core/grpcjson"
+	"angrymiao-ai-server/src/core/transport"
+	"angrymiao-ai-server/src/core/utils"
+	"angrymiao-ai-server/src/httpsvr/device"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// GrpcTransport gRPC传输层实现，每个 ChatStream 双向流对应一个会话
+type GrpcTransport struct {
+	cfg         *configs.Config
+	logger      *utils.Logger
+	factory     transport.ConnectionHandlerFactory
+	server      *grpc.Server
+	listener    net.Listener // 非nil时使用该监听器，测试中用于替换监听方式
+	handlers    sync.Map     // connID -> transport.ConnectionHandler
+	authToken   *auth.AuthToken
+	governor    *governor.Governor     // 所有传输层共用的连接总数限制
+	rateLimiter *transport.RateLimiter // 所有传输层共用的设备上行消息限流
+}
+
+// NewGrpcTransport 创建gRPC传输层
+func NewGrpcTransport(cfg *configs.Config, logger *utils.Logger) *GrpcTransport {
+	return &GrpcTransport{
+		cfg:         cfg,
+		logger:      logger,
+		authToken:   auth.NewAuthToken(cfg.Server.Token),
+		governor:    governor.Default(),
+		rateLimiter: transport.DefaultRateLimiter(),
+	}
+}
+
+func (t *GrpcTransport) GetType() string { return "grpc" }
+
+func (t *GrpcTransport) SetConnectionHandler(factory transport.ConnectionHandlerFactory) {
+	t.factory = factory
+}
+
+// Start 启动gRPC传输层，阻塞直到服务停止
+func (t *GrpcTransport) Start(ctx context.Context) error {
+	if t.factory == nil {
+		return fmt.Errorf("connection handler factory not set")
+	}
+	opts := []grpc.ServerOption{grpc.ForceServerCodec(grpcjson.Codec{})}
+	if t.cfg.Transport.Grpc.TLS.Enabled {
+		tlsConfig, err := t.loadTLSConfig()
+		if err != nil {
+			return err
+		}
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+	t.server = grpc.NewServer(opts...)
+	t.server.RegisterService(&chatServiceDesc, t)
+
+	lis := t.listener
+	if lis == nil {
+		addr := fmt.Sprintf("%s:%d", t.cfg.Transport.Grpc.IP, t.cfg.Transport.Grpc.Port)
+		var err error
+		if lis, err = net.Listen("tcp", addr); err != nil {
+			return fmt.Errorf("gRPC传输层监听失败: %v", err)
+		}
+	}
+
+	// 监听关闭信号
+	go func() {
+		<-ctx.Done()
+		t.Stop()
+	}()
+
+	t.logger.Info("启动gRPC传输层 %s (TLS: %t)", lis.Addr(), t.cfg.Transport.Grpc.TLS.Enabled)
+	if err := t.server.Serve(lis); err != nil && err != grpc.ErrServerStopped {
+		return fmt.Errorf("gRPC传输层启动失败: %v", err)
+	}
+	return nil
+}
+
+// loadTLSConfig 加载服务端证书，配置 ca_file 时校验客户端证书
+func (t *GrpcTransport) loadTLSConfig() (*tls.Config, error) {
+	tlsCfg := t.cfg.Transport.Grpc.TLS
+	cert, err := tls.LoadX509KeyPair(tlsCfg.CertFile, tlsCfg.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("加载服务端证书失败: %v", err)
+	}
+	ls := &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	if tlsCfg.CAFile != "" {
+		pem, err := os.ReadFile(tlsCfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("读取CA文件失败: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("加载CA证书失败")
+		}
+		ls.ClientCAs = pool
+		ls.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return ls, nil
+}
+
+// Stop 关闭所有会话并停止gRPC服务
+func (t *GrpcTransport) Stop() error {
+	t.handlers.Range(func(key, value interface{}) bool {
+		if handler, ok := value.(transport.ConnectionHandler); ok {
+			handler.Close()
+		}
+		return true
+	})
+	if t.server != nil {
+		t.server.Stop()
+	}
+	return nil
+}
+
+func (t *GrpcTransport) GetActiveConnectionCount() int {
+	count := 0
+	t.handlers.Range(func(_, _ interface{}) bool { count++; return true })
+	return count
+}
+
+// ChatStream 处理一个双向流：认证、创建连接处理器，并将上行消息转交给连接
+func (t *GrpcTransport) ChatStream(stream grpc.ServerStream) error {
+	req := requestFromMetadata(stream.Context())
+	deviceID := req.Header.Get("Device-Id")
+	userID, err := t.verifyJWTAuth(req)
+	if err != nil {
+		t.logger.Warn("gRPC认证失败: %v device-id: %s", err, deviceID)
+		return status.Errorf(codes.Unauthenticated, "unauthorized: %v", err)
+	}
+	if !t.governor.Acquire() {
+		t.logger.Warn("连接数已达上限(%d)，拒绝gRPC连接: device-id: %s", t.governor.MaxConnections(), deviceID)
+		return status.Error(codes.ResourceExhausted, "too many connections")
+	}
+	defer t.governor.Release()
+
+	conn := NewGrpcConnection(fmt.Sprintf("%p", stream), stream)
+	connID := conn.GetID()
+	// 若请求未提供 Session-Id，则使用连接ID作为会话ID
+	sessionID := req.Header.Get("Session-Id")
+	if sessionID == "" {
+		sessionID = connID
+		req.Header.Set("Session-Id", sessionID)
+	}
+
+	handler := t.factory.CreateHandler(conn, req)
+	if handler == nil {
+		t.logger.Error("创建连接处理器失败")
+		return status.Error(codes.Internal, "create handler failed")
+	}
+	// 绑定用户ID到具体的 ConnectionHandler
+	if adapter, ok := handler.(*transport.ConnectionContextAdapter); ok {
+		adapter.GetConnectionHandler().SetUserID(fmt.Sprintf("%d", userID))
+	}
+
+	t.handlers.Store(connID, handler)
+	device.GetPresenceManager().SetSessionOnline(deviceID, sessionID)
+	if err := transport.GetSessionRegistry().Register(transport.SessionSummary{
+		ID:        connID,
+		SessionID: sessionID,
+		DeviceID:  deviceID,
+		UserID:    fmt.Sprintf("%d", userID),
+		Transport: t.GetType(),
+	}, handler); err != nil {
+		t.logger.Warn("登记会话失败: %s, %v", connID, err)
+	}
+	t.logger.Info("gRPC客户端 %s 连接已建立: device-id=%s, user-id=%d", connID, deviceID, userID)
+
+	handleDone := make(chan struct{})
+	go func() {
+		defer close(handleDone)
+		handler.Handle()
+	}()
+	go t.receive(stream, conn, deviceID)
+
+	select {
+	case <-conn.Done():
+	case <-handleDone:
+	case <-stream.Context().Done():
+	}
+	conn.Close()
+	handler.Close()
+	<-handleDone
+	t.handlers.Delete(connID)
+	transport.GetSessionRegistry().Unregister(connID, handler)
+	device.GetPresenceManager().SetSessionOffline(deviceID, sessionID)
+	t.logger.Info("gRPC客户端 %s 连接已关闭", connID)
+	return nil
+}
+
+// receive 读取上行消息直到流结束，被限流的消息直接丢弃
+func (t *GrpcTransport) receive(stream grpc.ServerStream, conn *GrpcConnection, deviceID string) {
+	defer conn.Close()
+	for {
+		msg := new(ClientMessage)
+		if err := stream.RecvMsg(msg); err != nil {
+			return
+		}
+		messageType, data := 1, []byte(msg.Text)
+		if msg.Audio != nil {
+			messageType, data = 2, msg.Audio
+		}
+		if result := t.rateLimiter.Allow(deviceID, messageType, data); !result.Allowed {
+			if result.Notify {
+				_ = conn.WriteMessage(1, transport.RateLimitMessage(result.RetryAfter))
+			}
+			continue
+		}
+		conn.pushIncoming(msg)
+	}
+}
+
+// verifyJWTAuth 验证metadata中的设备JWT，返回用户ID
+func (t *GrpcTransport) verifyJWTAuth(req *http.Request) (uint, error) {
+	authHeader := req.Header.Get("Authorization")
+	if !strings.HasPrefix(authHeader, "Bearer ") {
+		return 0, fmt.Errorf("缺少或无效的Authorization")
+	}
+	isValid, deviceID, userID, err := t.authToken.VerifyToken(authHeader[7:])
+	if err != nil || !isValid {
+		return 0, fmt.Errorf("JWT token验证失败: %v", err)
+	}
+	if requestDeviceID := req.Header.Get("Device-Id"); requestDeviceID != deviceID {
+		return 0, fmt.Errorf("设备ID与token不匹配: 请求=%s, token=%s", requestDeviceID, deviceID)
+	}
+	return userID, nil
+}
+
+// requestFromMetadata 将流的metadata转换为请求头，供连接处理器按WebSocket请求头读取
+func requestFromMetadata(ctx context.Context) *http.Request {
+	req := (&http.Request{Header: http.Header{}}).WithContext(ctx)
+	if p, ok := peer.FromContext(ctx); ok {
+		req.RemoteAddr = p.Addr.String()
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	for key, values := range md {
+		if len(values) > 0 && !strings.HasPrefix(key, ":") {
+			req.Header.Set(key, values[0])
+		}
+	}
+	return req
+}
+
+// 编译期校验满足 Transport 接口
+var _ transport.Transport = (*GrpcTransport)(nil)
@@ -0,0 +1,161 @@
+package grpc
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"angrymiao-ai-server/src/configs"
+	"angrymiao-ai-server/src/core/auth"
+	"angrymiao-ai-server/src/core/grpcjson"
+	"angrymiao-ai-server/src/core/transport"
+	"angrymiao-ai-server/src/core/utils"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// echoHandler 将收到的消息原样发回，文本消息转为大写
+type echoHandler struct {
+	conn    transport.Connection
+	session string
+	stop    chan struct{}
+}
+
+func (h *echoHandler) Handle() {
+	for {
+		messageType, data, err := h.conn.ReadMessage(h.stop)
+		if err != nil {
+			return
+		}
+		if messageType == 1 {
+			data = []byte(strings.ToUpper(string(data)))
+		}
+		if err := h.conn.WriteMessage(messageType, data); err != nil {
+			return
+		}
+	}
+}
+
+func (h *echoHandler) Close()               { h.conn.Close() }
+func (h *echoHandler) GetSessionID() string { return h.session }
+
+type echoFactory struct{}
+
+func (echoFactory) CreateHandler(conn transport.Connection, req *http.Request) transport.ConnectionHandler {
+	return &echoHandler{conn: conn, session: req.Header.Get("Session-Id"), stop: make(chan struct{})}
+}
+
+func startTestTransport(t *testing.T) (*GrpcTransport, *grpc.ClientConn, *configs.Config) {
+	t.Helper()
+	logger, err := utils.NewLogger(&utils.LogCfg{LogLevel: "ERROR", LogDir: t.TempDir(), LogFile: "test.log"})
+	if err != nil {
+		t.Fatalf("创建日志失败: %v", err)
+	}
+	cfg := &configs.Config{}
+	cfg.Server.Token = "test-secret"
+	lis := bufconn.Listen(1 << 20)
+	tr := NewGrpcTransport(cfg, logger)
+	tr.listener = lis
+	tr.SetConnectionHandler(echoFactory{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan struct{})
+	go func() {
+		defer close(served)
+		if err := tr.Start(ctx); err != nil {
+			t.Errorf("启动gRPC传输层失败: %v", err)
+		}
+	}()
+
+	cc, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.ForceCodec(grpcjson.Codec{})),
+	)
+	if err != nil {
+		t.Fatalf("创建gRPC客户端失败: %v", err)
+	}
+	t.Cleanup(func() {
+		cc.Close()
+		cancel()
+		<-served
+	})
+	return tr, cc, cfg
+}
+
+func openChatStream(t *testing.T, cc *grpc.ClientConn, md metadata.MD) grpc.ClientStream {
+	t.Helper()
+	ctx := metadata.NewOutgoingContext(context.Background(), md)
+	stream, err := cc.NewStream(ctx, &chatServiceDesc.Streams[0], chatStreamMethod)
+	if err != nil {
+		t.Fatalf("创建流失败: %v", err)
+	}
+	return stream
+}
+
+func TestChatStreamRejectsUnauthenticated(t *testing.T) {
+	_, cc, _ := startTestTransport(t)
+	stream := openChatStream(t, cc, metadata.Pairs("device-id", "dev1"))
+	err := stream.RecvMsg(new(ServerMessage))
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("未携带token的流应返回 Unauthenticated, got %v", err)
+	}
+}
+
+func TestChatStreamRelaysMessages(t *testing.T) {
+	tr, cc, cfg := startTestTransport(t)
+	token, err := auth.NewAuthToken(cfg.Server.Token).GenerateToken("dev1")
+	if err != nil {
+		t.Fatalf("生成token失败: %v", err)
+	}
+	stream := openChatStream(t, cc, metadata.Pairs("authorization", "Bearer "+token, "device-id", "dev1", "session-id", "s1"))
+
+	if err := stream.SendMsg(&ClientMessage{Text: `{"type":"hello"}`}); err != nil {
+		t.Fatalf("发送文本消息失败: %v", err)
+	}
+	reply := new(ServerMessage)
+	if err := stream.RecvMsg(reply); err != nil {
+		t.Fatalf("接收文本消息失败: %v", err)
+	}
+	if reply.Text != `{"TYPE":"HELLO"}` || reply.Audio != nil {
+		t.Errorf("文本回复 = %+v", reply)
+	}
+
+	if err := stream.SendMsg(&ClientMessage{Audio: []byte{1, 2, 3}}); err != nil {
+		t.Fatalf("发送音频帧失败: %v", err)
+	}
+	reply = new(ServerMessage)
+	if err := stream.RecvMsg(reply); err != nil {
+		t.Fatalf("接收音频帧失败: %v", err)
+	}
+	if string(reply.Audio) != "\x01\x02\x03" || reply.Text != "" {
+		t.Errorf("音频回复 = %+v", reply)
+	}
+	if got := tr.GetActiveConnectionCount(); got != 1 {
+		t.Errorf("活跃连接数 = %d, 期望 1", got)
+	}
+
+	// 客户端结束发送后会话关闭，流正常结束
+	if err := stream.CloseSend(); err != nil {
+		t.Fatalf("关闭发送失败: %v", err)
+	}
+	if err := stream.RecvMsg(new(ServerMessage)); err != io.EOF {
+		t.Errorf("会话结束后应返回 EOF, got %v", err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for tr.GetActiveConnectionCount() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("会话结束后仍有活跃连接")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
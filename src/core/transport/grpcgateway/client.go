@@ -3,6 +3,8 @@ package grpcgateway
 import (
 	"context"
 
+	"angrymiao-ai-server/src/core/grpcjson"
+
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
 )
@@ -18,7 +20,7 @@ func NewIMGatewayClient(ctx context.Context, addr string) (*IMGatewayClient, err
 	// 强制使用 json 编码，避免 proto 依赖
 	opts := []grpc.DialOption{
 		grpc.WithTransportCredentials(insecure.NewCredentials()),
-		grpc.WithDefaultCallOptions(grpc.ForceCodec(jsonCodec{}), grpc.CallContentSubtype("json")),
+		grpc.WithDefaultCallOptions(grpc.ForceCodec(grpcjson.Codec{}), grpc.CallContentSubtype(grpcjson.Name)),
 	}
 	cc, err := grpc.DialContext(ctx, addr, opts...)
 	if err != nil {
@@ -0,0 +1,92 @@
+package mqtt
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+)
+
+// audioSeqHeaderSize 客户端在音频二进制消息前附加的大端序号长度
+const audioSeqHeaderSize = 4
+
+// AudioNackMessage 检测到音频丢包时下发给客户端的重传请求
+type AudioNackMessage struct {
+	Type        string `json:"type"`
+	ExpectedSeq uint32 `json:"expected_seq"`
+	ReceivedSeq uint32 `json:"received_seq"`
+}
+
+// AudioLossStats 会话音频丢包统计
+type AudioLossStats struct {
+	Received uint64  `json:"received"`
+	Lost     uint64  `json:"lost"`
+	LossRate float64 `json:"loss_rate"`
+}
+
+// SetAudioNackEnabled 设置是否解析音频序号并在丢包时下发 audio_nack
+func (c *MQTTConnection) SetAudioNackEnabled(enabled bool) {
+	c.seqMu.Lock()
+	defer c.seqMu.Unlock()
+	c.audioNackEnabled = enabled
+}
+
+// AudioLossStats 返回当前会话的音频丢包统计
+func (c *MQTTConnection) AudioLossStats() AudioLossStats {
+	c.seqMu.Lock()
+	defer c.seqMu.Unlock()
+	stats := AudioLossStats{Received: c.audioReceived, Lost: c.audioLost}
+	if total := stats.Received + stats.Lost; total > 0 {
+		stats.LossRate = float64(stats.Lost) / float64(total)
+	}
+	return stats
+}
+
+// handleAudioSeq 剥离音频序号头并检测丢包，返回去掉序号后的音频数据
+// 未启用或数据不足4字节时原样返回
+func (c *MQTTConnection) handleAudioSeq(message []byte) []byte {
+	c.seqMu.Lock()
+	if !c.audioNackEnabled || len(message) < audioSeqHeaderSize {
+		c.seqMu.Unlock()
+		return message
+	}
+	seqNumber := binary.BigEndian.Uint32(message[:audioSeqHeaderSize])
+	c.audioReceived++
+
+	var nack *AudioNackMessage
+	switch gap := seqNumber - c.expectedSeq; {
+	case !c.seqStarted:
+		c.seqStarted = true
+		c.expectedSeq = seqNumber + 1
+	case gap == 0:
+		c.expectedSeq = seqNumber + 1
+	case gap < 1<<31:
+		// 序号向前跳变（含回绕），中间的包视为丢失
+		c.audioLost += uint64(gap)
+		nack = &AudioNackMessage{Type: "audio_nack", ExpectedSeq: c.expectedSeq, ReceivedSeq: seqNumber}
+		c.expectedSeq = seqNumber + 1
+	default:
+		// 迟到或重传的包，已计入丢失的从统计中扣除
+		if c.audioLost > 0 {
+			c.audioLost--
+		}
+	}
+	c.seqMu.Unlock()
+
+	if nack != nil {
+		fmt.Printf("⚠ MQTT音频丢包: connID=%s, expected=%d, received=%d\n", c.id, nack.ExpectedSeq, nack.ReceivedSeq)
+		// 在订阅回调中同步等待发布确认可能阻塞paho的消息分发，异步发送
+		go c.sendAudioNack(nack)
+	}
+	return message[audioSeqHeaderSize:]
+}
+
+// sendAudioNack 通过MQTT下发重传请求
+func (c *MQTTConnection) sendAudioNack(nack *AudioNackMessage) {
+	data, err := json.Marshal(nack)
+	if err != nil {
+		return
+	}
+	if err := c.WriteMessage(1, data); err != nil {
+		fmt.Printf("✗ 发送audio_nack失败: connID=%s, err=%v\n", c.id, err)
+	}
+}
@@ -0,0 +1,146 @@
+package mqtt
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"angrymiao-ai-server/src/core/transport"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// doneToken 立即完成的发布令牌
+type doneToken struct{ mqtt.Token }
+
+func (doneToken) Wait() bool                     { return true }
+func (doneToken) WaitTimeout(time.Duration) bool { return true }
+func (doneToken) Error() error                   { return nil }
+
+// publishRecorder 记录发布到MQTT的消息
+type publishRecorder struct {
+	mqtt.Client
+	published chan []byte
+}
+
+func (c *publishRecorder) Publish(topic string, qos byte, retained bool, payload interface{}) mqtt.Token {
+	c.published <- payload.([]byte)
+	return doneToken{}
+}
+
+func audioPacket(seq uint32, payload string) []byte {
+	packet := make([]byte, audioSeqHeaderSize, audioSeqHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(packet, seq)
+	return append(packet, payload...)
+}
+
+func newNackTestConnection() (*MQTTConnection, *publishRecorder) {
+	client := &publishRecorder{published: make(chan []byte, 10)}
+	conn := NewMQTTConnection(client, "device/session", "root/device/session/out", 1)
+	conn.SetAudioNackEnabled(true)
+	return conn, client
+}
+
+func TestPushIncomingStripsAudioSeq(t *testing.T) {
+	conn, client := newNackTestConnection()
+
+	conn.PushIncoming(2, audioPacket(7, "opus-1"))
+	conn.PushIncoming(2, audioPacket(8, "opus-2"))
+
+	for _, want := range []string{"opus-1", "opus-2"} {
+		_, data, err := conn.ReadMessage(nil)
+		if err != nil || string(data) != want {
+			t.Fatalf("读取音频 = %q, %v, 期望 %q", data, err, want)
+		}
+	}
+	select {
+	case msg := <-client.published:
+		t.Errorf("序号连续时不应发送NACK, got %s", msg)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if stats := conn.AudioLossStats(); stats.Received != 2 || stats.Lost != 0 {
+		t.Errorf("丢包统计 = %+v", stats)
+	}
+}
+
+func TestPushIncomingSendsNackOnGap(t *testing.T) {
+	conn, client := newNackTestConnection()
+
+	conn.PushIncoming(2, audioPacket(1, "a"))
+	conn.PushIncoming(2, audioPacket(4, "d"))
+
+	select {
+	case msg := <-client.published:
+		var nack AudioNackMessage
+		if err := json.Unmarshal(msg, &nack); err != nil {
+			t.Fatalf("解析NACK失败: %v", err)
+		}
+		if nack.Type != "audio_nack" || nack.ExpectedSeq != 2 || nack.ReceivedSeq != 4 {
+			t.Errorf("NACK = %+v, 期望 expected_seq=2 received_seq=4", nack)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("检测到丢包后应发送NACK")
+	}
+
+	stats := conn.AudioLossStats()
+	if stats.Received != 2 || stats.Lost != 2 || stats.LossRate != 0.5 {
+		t.Errorf("丢包统计 = %+v, 期望收到2个、丢失2个", stats)
+	}
+
+	// 重传的包正常投递并从丢失统计中扣除
+	conn.PushIncoming(2, audioPacket(2, "b"))
+	if stats := conn.AudioLossStats(); stats.Lost != 1 {
+		t.Errorf("重传后丢失数 = %d, 期望 1", stats.Lost)
+	}
+	for _, want := range []string{"a", "d", "b"} {
+		if _, data, _ := conn.ReadMessage(nil); string(data) != want {
+			t.Errorf("读取音频 = %q, 期望 %q", data, want)
+		}
+	}
+}
+
+func TestPushIncomingSeqDisabled(t *testing.T) {
+	conn, _ := newNackTestConnection()
+	conn.SetAudioNackEnabled(false)
+
+	packet := audioPacket(3, "raw")
+	conn.PushIncoming(2, packet)
+
+	if _, data, _ := conn.ReadMessage(nil); string(data) != string(packet) {
+		t.Errorf("未启用时应原样投递, got %q", data)
+	}
+}
+
+func TestAllowDeviceMessageDropsBurst(t *testing.T) {
+	conn, client := newNackTestConnection()
+	tr := &MQTTTransport{logger: newTestLogger(t), rateLimiter: transport.NewRateLimiter(2, 0)}
+
+	allowed := 0
+	for i := 0; i < 5; i++ {
+		if tr.allowDeviceMessage(conn, "", "dev1", 1, nil) {
+			allowed++
+		}
+	}
+	if allowed != 2 {
+		t.Errorf("突发5条文本放行 %d 条, 期望 2", allowed)
+	}
+	// 音频帧未限制，其他租户的同名设备独立计数
+	if !tr.allowDeviceMessage(conn, "", "dev1", 2, nil) || !tr.allowDeviceMessage(conn, "tenant_a", "dev1", 1, nil) {
+		t.Error("音频帧与其他租户设备不应被限流")
+	}
+
+	select {
+	case payload := <-client.published:
+		var msg map[string]interface{}
+		json.Unmarshal(payload, &msg)
+		if msg["type"] != "rate_limit" || msg["retry_after_ms"] == nil {
+			t.Errorf("限流消息 = %s", payload)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("被限流时应通知设备")
+	}
+	if len(client.published) != 0 {
+		t.Errorf("同一秒内只应通知一次, 额外通知 %d 次", len(client.published))
+	}
+}
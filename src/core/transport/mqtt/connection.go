@@ -1,6 +1,7 @@
 package mqtt
 
 import (
+	"encoding/base64"
 	"fmt"
 	"sync"
 	"sync/atomic"
@@ -27,6 +28,18 @@ type MQTTConnection struct {
 	udpServer  string // UDP服务器地址
 	udpPort    string // UDP服务器端口
 
+	// 音频序号与丢包统计（audio_nack_enabled 时启用）
+	seqMu            sync.Mutex
+	audioNackEnabled bool
+	seqStarted       bool
+	expectedSeq      uint32
+	audioReceived    uint64
+	audioLost        uint64
+
+	// 端到端加密（e2e_encryption_enabled 时由hello协商），激活后MQTT收发的消息均加密
+	e2e       *e2eSession
+	e2eActive atomic.Bool
+
 	incoming chan struct {
 		messageType int
 		data        []byte
@@ -80,6 +93,52 @@ func (c *MQTTConnection) GetUDPInfo() (enabled bool, server, port, key, nonce st
 	return true, c.udpServer, c.udpPort, keyHex, nonceHex
 }
 
+// SetupE2E 使用客户端公钥（base64）协商端到端加密会话密钥
+// 协商完成后需在hello响应发出后调用 ActivateE2E 才开始加密
+func (c *MQTTConnection) SetupE2E(clientPublicKey string) error {
+	peer, err := base64.StdEncoding.DecodeString(clientPublicKey)
+	if err != nil {
+		return fmt.Errorf("客户端公钥不是有效的base64")
+	}
+	session, err := newE2ESession(peer)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.e2e = session
+	return nil
+}
+
+// GetE2EInfo 实现E2EInfoProvider接口，获取hello响应中下发的协商信息
+func (c *MQTTConnection) GetE2EInfo() (enabled bool, curve, cipherName, publicKey string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.e2e == nil {
+		return false, "", "", ""
+	}
+	return true, e2eCurve, e2eCipher, base64.StdEncoding.EncodeToString(c.e2e.publicKey)
+}
+
+// ActivateE2E 开始加密后续收发的消息
+func (c *MQTTConnection) ActivateE2E() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.e2e != nil {
+		c.e2eActive.Store(true)
+	}
+}
+
+// activeE2E 返回已激活的端到端加密会话，未激活时返回nil
+func (c *MQTTConnection) activeE2E() *e2eSession {
+	if !c.e2eActive.Load() {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.e2e
+}
+
 // WriteMessage 发布数据到 outTopic
 // 如果是音频数据(messageType=2)且UDP会话活跃，优先使用UDP发送
 func (c *MQTTConnection) WriteMessage(messageType int, data []byte) error {
@@ -107,6 +166,13 @@ func (c *MQTTConnection) WriteMessage(messageType int, data []byte) error {
 	}
 
 	// 控制消息(messageType=1)或UDP不可用，使用MQTT发送
+	if session := c.activeE2E(); session != nil {
+		frame, err := sealE2EFrame(session.seal, messageType, data)
+		if err != nil {
+			return err
+		}
+		data = frame
+	}
 	token := c.client.Publish(c.outTopic, c.qos, false, data)
 	if token == nil {
 		return fmt.Errorf("写入失败")
@@ -187,7 +253,18 @@ func (c *MQTTConnection) PushIncoming(messageType int, data []byte) {
 		return
 	}
 
+	// 端到端加密激活后，消息类型以加密帧中的为准，无法解密的消息直接丢弃
+	if session := c.activeE2E(); session != nil {
+		mt, plaintext, err := openE2EFrame(session.open, data)
+		if err != nil {
+			fmt.Printf("✗ MQTT端到端加密消息解密失败，丢弃: conn=%s, err=%v\n", c.id, err)
+			return
+		}
+		messageType, data = mt, plaintext
+	}
+
 	if messageType == 2 {
+		data = c.handleAudioSeq(data)
 		handled, processed := c.handleIncomingUDPPacket(data)
 		if handled {
 			return
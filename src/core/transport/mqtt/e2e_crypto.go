@@ -0,0 +1,92 @@
+package mqtt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+)
+
+const (
+	e2eKeyExchange = "ecdh"
+	e2eCurve       = "x25519"
+	e2eCipher      = "aes-256-gcm"
+
+	// HKDF info，按方向派生不同的密钥，避免双方使用同一密钥加密
+	e2eServerToClientInfo = "angrymiao-mqtt-e2e server->client"
+	e2eClientToServerInfo = "angrymiao-mqtt-e2e client->server"
+
+	e2eNonceSize = 12
+)
+
+// e2eSession 会话级端到端加密状态
+type e2eSession struct {
+	publicKey []byte      // 服务端公钥，通过hello响应下发
+	seal      cipher.AEAD // 服务端 -> 客户端
+	open      cipher.AEAD // 客户端 -> 服务端
+}
+
+// newE2ESession 生成服务端X25519密钥对，与客户端公钥协商出会话密钥
+func newE2ESession(clientPublicKey []byte) (*e2eSession, error) {
+	curve := ecdh.X25519()
+	peer, err := curve.NewPublicKey(clientPublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("客户端公钥无效: %v", err)
+	}
+	priv, err := curve.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("生成ECDH密钥对失败: %v", err)
+	}
+	shared, err := priv.ECDH(peer)
+	if err != nil {
+		return nil, fmt.Errorf("ECDH协商失败: %v", err)
+	}
+	seal, err := deriveE2EAEAD(shared, e2eServerToClientInfo)
+	if err != nil {
+		return nil, err
+	}
+	open, err := deriveE2EAEAD(shared, e2eClientToServerInfo)
+	if err != nil {
+		return nil, err
+	}
+	return &e2eSession{publicKey: priv.PublicKey().Bytes(), seal: seal, open: open}, nil
+}
+
+// deriveE2EAEAD 由ECDH共享密钥经HKDF-SHA256派生指定方向的AES-256-GCM
+func deriveE2EAEAD(shared []byte, info string) (cipher.AEAD, error) {
+	key, err := hkdf.Key(sha256.New, shared, nil, info, 32)
+	if err != nil {
+		return nil, fmt.Errorf("派生会话密钥失败: %v", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("创建AES cipher失败: %v", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// sealE2EFrame 加密一条消息
+// 帧格式: [type(1B)][nonce(12B)][密文+tag(16B)]，type 作为附加认证数据
+func sealE2EFrame(aead cipher.AEAD, messageType int, plaintext []byte) ([]byte, error) {
+	frame := make([]byte, 1+e2eNonceSize, 1+e2eNonceSize+len(plaintext)+aead.Overhead())
+	frame[0] = byte(messageType)
+	if _, err := rand.Read(frame[1 : 1+e2eNonceSize]); err != nil {
+		return nil, fmt.Errorf("生成nonce失败: %v", err)
+	}
+	return aead.Seal(frame, frame[1:1+e2eNonceSize], plaintext, frame[:1]), nil
+}
+
+// openE2EFrame 解密一帧，返回消息类型与明文
+func openE2EFrame(aead cipher.AEAD, frame []byte) (int, []byte, error) {
+	if len(frame) < 1+e2eNonceSize+aead.Overhead() {
+		return 0, nil, fmt.Errorf("加密帧长度不足: %d", len(frame))
+	}
+	plaintext, err := aead.Open(nil, frame[1:1+e2eNonceSize], frame[1+e2eNonceSize:], frame[:1])
+	if err != nil {
+		return 0, nil, fmt.Errorf("解密失败: %v", err)
+	}
+	return int(frame[0]), plaintext, nil
+}
@@ -0,0 +1,124 @@
+package mqtt
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/base64"
+	"testing"
+	"time"
+)
+
+// e2eDevice 模拟设备端：生成X25519密钥对，收到服务端公钥后派生双向密钥
+type e2eDevice struct {
+	priv *ecdh.PrivateKey
+	seal cipher.AEAD // 客户端 -> 服务端
+	open cipher.AEAD // 服务端 -> 客户端
+}
+
+func (d *e2eDevice) derive(t *testing.T, serverPublicKey string) {
+	raw, err := base64.StdEncoding.DecodeString(serverPublicKey)
+	if err != nil {
+		t.Errorf("服务端公钥解码失败: %v", err)
+		return
+	}
+	peer, err := ecdh.X25519().NewPublicKey(raw)
+	if err != nil {
+		t.Errorf("服务端公钥无效: %v", err)
+		return
+	}
+	shared, err := d.priv.ECDH(peer)
+	if err != nil {
+		t.Errorf("ECDH协商失败: %v", err)
+		return
+	}
+	d.seal, _ = deriveE2EAEAD(shared, e2eClientToServerInfo)
+	d.open, _ = deriveE2EAEAD(shared, e2eServerToClientInfo)
+}
+
+func TestE2EHandshakeRoundTrip(t *testing.T) {
+	broker := newFakeBroker()
+	conn := NewMQTTConnection(broker, "dev1/s1", "mqtt_msg/dev1/s1/out", 0)
+	serverSecret := []byte(`{"type":"tts","text":"服务端机密回复"}`)
+	deviceSecret := []byte(`{"type":"listen","text":"设备机密输入"}`)
+
+	clientKeys := make(chan string, 1)
+	serverDone := make(chan []byte, 1)
+
+	// 服务端：收到客户端公钥后协商，发送明文hello，激活后发送加密消息并读取设备消息
+	go func() {
+		if err := conn.SetupE2E(<-clientKeys); err != nil {
+			t.Errorf("服务端协商失败: %v", err)
+			close(serverDone)
+			return
+		}
+		enabled, curve, cipherName, publicKey := conn.GetE2EInfo()
+		if !enabled || curve != "x25519" || cipherName != "aes-256-gcm" {
+			t.Errorf("协商信息 = %v, %s, %s", enabled, curve, cipherName)
+		}
+		conn.WriteMessage(1, []byte(publicKey))
+		conn.ActivateE2E()
+		conn.WriteMessage(1, serverSecret)
+		_, data, err := conn.ReadMessage(make(chan struct{}))
+		if err != nil {
+			t.Errorf("服务端读取消息失败: %v", err)
+		}
+		serverDone <- data
+	}()
+
+	// 设备端：发送公钥，根据hello中的服务端公钥派生密钥，解密服务端消息并回复密文
+	deviceDone := make(chan []byte, 1)
+	go func() {
+		device := &e2eDevice{}
+		device.priv, _ = ecdh.X25519().GenerateKey(rand.Reader)
+		clientKeys <- base64.StdEncoding.EncodeToString(device.priv.PublicKey().Bytes())
+
+		hello := <-broker.published
+		device.derive(t, string(hello.payload))
+		encrypted := <-broker.published
+		if bytes.Contains(encrypted.payload, []byte("服务端机密回复")) {
+			t.Error("激活加密后MQTT消息仍包含明文")
+		}
+		mt, plaintext, err := openE2EFrame(device.open, encrypted.payload)
+		if err != nil || mt != 1 {
+			t.Errorf("设备端解密失败: type=%d, err=%v", mt, err)
+		}
+
+		// 篡改的消息被服务端丢弃
+		frame, _ := sealE2EFrame(device.seal, 1, []byte("篡改"))
+		frame[len(frame)-1] ^= 0xff
+		conn.PushIncoming(2, frame)
+		frame, _ = sealE2EFrame(device.seal, 1, deviceSecret)
+		conn.PushIncoming(2, frame)
+		deviceDone <- plaintext
+	}()
+
+	for name, ch := range map[string]chan []byte{"设备端": deviceDone, "服务端": serverDone} {
+		select {
+		case got := <-ch:
+			want := serverSecret
+			if name == "服务端" {
+				want = deviceSecret
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("%s收到 %q, 期望 %q", name, got, want)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("%s等待超时", name)
+		}
+	}
+}
+
+func TestE2ESetupRejectsInvalidPublicKey(t *testing.T) {
+	conn := NewMQTTConnection(newFakeBroker(), "dev1/s1", "out", 0)
+	for _, key := range []string{"", "not base64!", base64.StdEncoding.EncodeToString([]byte("short"))} {
+		if err := conn.SetupE2E(key); err == nil {
+			t.Errorf("公钥 %q 应协商失败", key)
+		}
+	}
+	conn.ActivateE2E()
+	if enabled, _, _, _ := conn.GetE2EInfo(); enabled || conn.activeE2E() != nil {
+		t.Error("协商失败时不应启用端到端加密")
+	}
+}
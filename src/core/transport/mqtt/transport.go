@@ -15,6 +15,7 @@ import (
 
 	"angrymiao-ai-server/src/configs"
 	"angrymiao-ai-server/src/core/auth"
+	"angrymiao-ai-server/src/core/governor"
 	"angrymiao-ai-server/src/core/transport"
 	"angrymiao-ai-server/src/core/utils"
 	"angrymiao-ai-server/src/httpsvr/device"
@@ -22,26 +23,32 @@ import (
 	mqtt "github.com/eclipse/paho.mqtt.golang"
 )
 
+// tenantPlaceholder topic_root 中的租户ID占位符，如 mqtt_msg/{tenant}
+const tenantPlaceholder = "{tenant}"
+
 // MQTTTransport MQTT传输层实现
 type MQTTTransport struct {
 	cfg         *configs.Config
 	logger      *utils.Logger
 	factory     transport.ConnectionHandlerFactory
 	client      mqtt.Client
-	udpServer   *UDPServer      // UDP服务器（可选）
-	connections sync.Map        // key=deviceID:sessionID -> *MQTTConnection
-	handlers    sync.Map        // key=deviceID:sessionID -> transport.ConnectionHandler
-	authToken   *auth.AuthToken // JWT认证工具
+	udpServer   *UDPServer             // UDP服务器（可选）
+	connections sync.Map               // key=deviceID:sessionID -> *MQTTConnection
+	handlers    sync.Map               // key=deviceID:sessionID -> transport.ConnectionHandler
+	authToken   *auth.AuthToken        // JWT认证工具
+	governor    *governor.Governor     // 所有传输层共用的连接总数限制
+	rateLimiter *transport.RateLimiter // 所有传输层共用的设备上行消息限流
 }
 
 func NewMQTTTransport(cfg *configs.Config, logger *utils.Logger) *MQTTTransport {
-	t := &MQTTTransport{cfg: cfg, logger: logger}
+	t := &MQTTTransport{cfg: cfg, logger: logger, governor: governor.Default(), rateLimiter: transport.DefaultRateLimiter()}
 	// 使用配置中的 topic_root
 	topicRoot := cfg.Transport.Mqtt.TopicRoot
 	if topicRoot == "" {
 		topicRoot = "am_topic" // 默认值
 	}
-	t.authToken = auth.NewAuthTokenWithConfig(cfg.Server.Token, topicRoot)
+	// 多租户时Token中的ACL允许任意租户层级，租户由主题解析并校验
+	t.authToken = auth.NewAuthTokenWithConfig(cfg.Server.Token, strings.ReplaceAll(topicRoot, tenantPlaceholder, "+"))
 	return t
 }
 
@@ -120,34 +127,7 @@ func (t *MQTTTransport) Start(ctx context.Context) error {
 	opts.SetConnectionLostHandler(func(_ mqtt.Client, err error) {
 		t.logger.Warn("MQTT连接丢失: %v", err)
 	})
-	opts.SetOnConnectHandler(func(c mqtt.Client) {
-		prefix := strings.TrimSuffix(t.cfg.Transport.Mqtt.TopicRoot, "/")
-		inSuffix := strings.TrimPrefix(t.cfg.Transport.Mqtt.InSuffix, "/")
-		// 示例：ws_asr/+/+/in
-		inTopic := fmt.Sprintf("%s/+/+/%s", prefix, inSuffix)
-		t.logger.Info("MQTT已连接，订阅主题: %s", inTopic)
-		tk := c.Subscribe(inTopic, byte(t.cfg.Transport.Mqtt.Qos), t.onMessage)
-		tk.Wait()
-		if err := tk.Error(); err != nil {
-			t.logger.Error("订阅失败: %v", err)
-		}
-		// 订阅心跳与连接状态（LWT）主题：ws_asr/+/status/heartbeat 与 ws_asr/+/status/connection
-		hbTopic := fmt.Sprintf("%s/+/status/heartbeat", prefix)
-		t.logger.Info("MQTT订阅心跳主题: %s", hbTopic)
-		tk2 := c.Subscribe(hbTopic, byte(t.cfg.Transport.Mqtt.Qos), t.onHeartbeatMessage)
-		tk2.Wait()
-		if err := tk2.Error(); err != nil {
-			t.logger.Error("订阅心跳失败: %v", err)
-		}
-
-		connTopic := fmt.Sprintf("%s/+/status/connection", prefix)
-		t.logger.Info("MQTT订阅连接状态主题: %s", connTopic)
-		tk3 := c.Subscribe(connTopic, byte(t.cfg.Transport.Mqtt.Qos), t.onConnectionMessage)
-		tk3.Wait()
-		if err := tk3.Error(); err != nil {
-			t.logger.Error("订阅连接状态失败: %v", err)
-		}
-	})
+	opts.SetOnConnectHandler(t.subscribe)
 
 	client := mqtt.NewClient(opts)
 	con := client.Connect()
@@ -166,6 +146,37 @@ func (t *MQTTTransport) Start(ctx context.Context) error {
 	return nil
 }
 
+// subscribe 连接Broker后订阅入站、心跳与连接状态主题，断线重连后重新订阅
+func (t *MQTTTransport) subscribe(c mqtt.Client) {
+	// topic_root 含 {tenant} 时以通配符订阅所有租户
+	prefix := t.topicPrefix("+")
+	inSuffix := strings.TrimPrefix(t.cfg.Transport.Mqtt.InSuffix, "/")
+	// 示例：ws_asr/+/+/in，多租户时为 ws_asr/+/+/+/in
+	inTopic := fmt.Sprintf("%s/+/+/%s", prefix, inSuffix)
+	t.logger.Info("MQTT已连接，订阅主题: %s", inTopic)
+	tk := c.Subscribe(inTopic, byte(t.cfg.Transport.Mqtt.Qos), t.onMessage)
+	tk.Wait()
+	if err := tk.Error(); err != nil {
+		t.logger.Error("订阅失败: %v", err)
+	}
+	// 订阅心跳与连接状态（LWT）主题：ws_asr/+/status/heartbeat 与 ws_asr/+/status/connection
+	hbTopic := fmt.Sprintf("%s/+/status/heartbeat", prefix)
+	t.logger.Info("MQTT订阅心跳主题: %s", hbTopic)
+	tk2 := c.Subscribe(hbTopic, byte(t.cfg.Transport.Mqtt.Qos), t.onHeartbeatMessage)
+	tk2.Wait()
+	if err := tk2.Error(); err != nil {
+		t.logger.Error("订阅心跳失败: %v", err)
+	}
+
+	connTopic := fmt.Sprintf("%s/+/status/connection", prefix)
+	t.logger.Info("MQTT订阅连接状态主题: %s", connTopic)
+	tk3 := c.Subscribe(connTopic, byte(t.cfg.Transport.Mqtt.Qos), t.onConnectionMessage)
+	tk3.Wait()
+	if err := tk3.Error(); err != nil {
+		t.logger.Error("订阅连接状态失败: %v", err)
+	}
+}
+
 // Stop 停止MQTT传输层
 func (t *MQTTTransport) Stop() error {
 	if t.client != nil && t.client.IsConnected() {
@@ -198,12 +209,15 @@ func (t *MQTTTransport) Stop() error {
 
 // onMessage 处理设备入站消息
 func (t *MQTTTransport) onMessage(_ mqtt.Client, msg mqtt.Message) {
-	deviceID, sessionID, ok := t.extractIDs(msg.Topic())
+	tenantID, deviceID, sessionID, ok := t.extractIDs(msg.Topic())
 	if !ok {
 		t.logger.Warn("MQTT主题不匹配，忽略: %s", msg.Topic())
 		return
 	}
 	key := deviceID + ":" + sessionID
+	if tenantID != "" {
+		key = tenantID + "/" + key
+	}
 
 	// 检查连接是否已存在
 	_, exists := t.connections.Load(key)
@@ -219,14 +233,14 @@ func (t *MQTTTransport) onMessage(_ mqtt.Client, msg mqtt.Message) {
 		// 1. 强制要求消息必须包含headers字段
 		if err := json.Unmarshal(msg.Payload(), &wrapper); err != nil {
 			t.logger.Warn("首条消息格式错误，无法解析JSON: deviceID=%s, error=%v", deviceID, err)
-			t.sendErrorResponse(deviceID, sessionID, "首条消息格式错误，必须为有效的JSON格式")
+			t.sendErrorResponse(tenantID, deviceID, sessionID, "首条消息格式错误，必须为有效的JSON格式")
 			return
 		}
 
 		// 2. 强制要求headers字段必须存在且不为空
 		if len(wrapper.Headers) == 0 {
 			t.logger.Warn("首条消息缺少headers字段: deviceID=%s", deviceID)
-			t.sendErrorResponse(deviceID, sessionID, "连接失败：首条消息必须包含headers字段")
+			t.sendErrorResponse(tenantID, deviceID, sessionID, "连接失败：首条消息必须包含headers字段")
 			return
 		}
 
@@ -235,33 +249,39 @@ func (t *MQTTTransport) onMessage(_ mqtt.Client, msg mqtt.Message) {
 		token, ok := wrapper.Headers["Token"]
 		if !ok || token == "" {
 			t.logger.Warn("连接失败：缺少Token字段: deviceID=%s, sessionID=%s", deviceID, sessionID)
-			t.sendErrorResponse(deviceID, sessionID, "连接失败：headers中必须包含Token字段")
+			t.sendErrorResponse(tenantID, deviceID, sessionID, "连接失败：headers中必须包含Token字段")
 			return
 		}
 
 		// 4. 验证Token
 		if t.authToken == nil {
 			t.logger.Error("认证管理器未初始化")
-			t.sendErrorResponse(deviceID, sessionID, "服务器配置错误")
+			t.sendErrorResponse(tenantID, deviceID, sessionID, "服务器配置错误")
 			return
 		}
 
 		valid, tokenDevID, userID, err := t.authToken.VerifyToken(token)
 		if err != nil || !valid {
 			t.logger.Warn("Token验证失败: deviceID=%s, sessionID=%s, error=%v", deviceID, sessionID, err)
-			t.sendErrorResponse(deviceID, sessionID, "连接失败：Token验证失败，请检查Token是否有效")
+			t.sendErrorResponse(tenantID, deviceID, sessionID, "连接失败：Token验证失败，请检查Token是否有效")
 			return
 		}
 
 		if tokenDevID != deviceID {
 			t.logger.Warn("设备ID与Token不匹配: 请求deviceID=%s, token中deviceID=%s", deviceID, tokenDevID)
-			t.sendErrorResponse(deviceID, sessionID, "连接失败：设备ID与Token不匹配")
+			t.sendErrorResponse(tenantID, deviceID, sessionID, "连接失败：设备ID与Token不匹配")
 			return
 		}
 
-		t.logger.Info("MQTT连接验证成功: deviceID=%s, sessionID=%s, userID=%d", deviceID, sessionID, userID)
-		conn := t.newConnection(deviceID, sessionID)
+		t.logger.Info("MQTT连接验证成功: tenantID=%s, deviceID=%s, sessionID=%s, userID=%d", tenantID, deviceID, sessionID, userID)
+		if !t.governor.Acquire() {
+			t.logger.Warn("连接数已达上限(%d)，拒绝MQTT连接: deviceID=%s, sessionID=%s", t.governor.MaxConnections(), deviceID, sessionID)
+			t.sendErrorResponse(tenantID, deviceID, sessionID, "连接失败：服务器连接数已达上限，请稍后重试")
+			return
+		}
+		conn := t.newConnection(tenantID, deviceID, sessionID)
 		if conn == nil {
+			t.governor.Release()
 			return
 		}
 		req := &http.Request{Header: http.Header{}}
@@ -282,6 +302,24 @@ func (t *MQTTTransport) onMessage(_ mqtt.Client, msg mqtt.Message) {
 		for k, v := range wrapper.Headers {
 			req.Header.Set(k, v)
 		}
+		// 租户ID以主题为准，不允许客户端通过headers指定
+		req.Header.Del("Tenant-Id")
+		if tenantID != "" {
+			req.Header.Set("Tenant-Id", tenantID)
+		}
+
+		// 客户端请求端到端加密时协商会话密钥，公钥不转发给处理器
+		if hello, ok := wrapper.Payload.(map[string]interface{}); ok && hello["e2e_key_exchange"] == e2eKeyExchange {
+			clientPublicKey, _ := hello["e2e_public_key"].(string)
+			delete(hello, "e2e_public_key")
+			if !t.cfg.Transport.Mqtt.E2EEncryptionEnabled {
+				t.logger.Info("未启用端到端加密，忽略客户端的协商请求: deviceID=%s", deviceID)
+			} else if err := conn.SetupE2E(clientPublicKey); err != nil {
+				t.logger.Warn("端到端加密协商失败，使用明文传输: deviceID=%s, error=%v", deviceID, err)
+			} else {
+				t.logger.Info("端到端加密协商成功: deviceID=%s, sessionID=%s", deviceID, sessionID)
+			}
+		}
 
 		// 将内部 payload 作为首条实际消息
 		var payloadToPush []byte
@@ -294,12 +332,14 @@ func (t *MQTTTransport) onMessage(_ mqtt.Client, msg mqtt.Message) {
 		if t.factory == nil {
 			t.logger.Error("连接处理器工厂未设置")
 			_ = conn.Close()
+			t.governor.Release()
 			return
 		}
 		handler := t.factory.CreateHandler(conn, req)
 		if handler == nil {
 			t.logger.Error("创建连接处理器失败")
 			_ = conn.Close()
+			t.governor.Release()
 			return
 		}
 
@@ -310,14 +350,11 @@ func (t *MQTTTransport) onMessage(_ mqtt.Client, msg mqtt.Message) {
 			udpSession, err := t.udpServer.CreateSession(deviceID, sessionID)
 			if err != nil {
 				t.logger.Error("创建UDP会话失败: %v", err)
-				t.sendErrorResponse(deviceID, sessionID, fmt.Sprintf("服务器配置错误:%v", err))
+				t.sendErrorResponse(tenantID, deviceID, sessionID, fmt.Sprintf("服务器配置错误:%v", err))
 			} else {
 				// 将UDP会话和服务器信息设置到连接，并标记启用UDP
-				conn.SetUDPSession(
-					udpSession,
-					t.udpServer.externalHost,
-					fmt.Sprintf("%d", t.udpServer.externalPort),
-				)
+				host, port := t.udpServer.AdvertisedAddress()
+				conn.SetUDPSession(udpSession, host, fmt.Sprintf("%d", port))
 				t.logger.Info("创建UDP会话成功: deviceID=%s, sessionID=%s", deviceID, sessionID)
 
 				// 如果客户端在headers中提供了UDP地址信息，立即发起探测
@@ -352,13 +389,29 @@ func (t *MQTTTransport) onMessage(_ mqtt.Client, msg mqtt.Message) {
 		t.handlers.Store(key, handler)
 		// 标记会话在线
 		device.GetPresenceManager().SetSessionOnline(deviceID, sessionID)
+		connID := fmt.Sprintf("%p", conn)
+		if err := transport.GetSessionRegistry().Register(transport.SessionSummary{
+			ID:        connID,
+			SessionID: sessionID,
+			DeviceID:  deviceID,
+			UserID:    fmt.Sprintf("%d", userID),
+			Transport: t.GetType(),
+		}, handler); err != nil {
+			t.logger.Warn("登记会话失败: %s, %v", connID, err)
+		}
 		go func() {
 			defer func() {
 				t.handlers.Delete(key)
 				t.connections.Delete(key)
+				if t.cfg.Transport.Mqtt.AudioNackEnabled {
+					stats := conn.AudioLossStats()
+					t.logger.Info("MQTT会话音频丢包统计: connID=%s, received=%d, lost=%d, loss_rate=%.2f%%", conn.GetID(), stats.Received, stats.Lost, stats.LossRate*100)
+				}
 				handler.Close()
+				transport.GetSessionRegistry().Unregister(connID, handler)
 				// 标记会话离线
 				device.GetPresenceManager().SetSessionOffline(deviceID, sessionID)
+				t.governor.Release()
 			}()
 			handler.Handle()
 		}()
@@ -373,6 +426,9 @@ func (t *MQTTTransport) onMessage(_ mqtt.Client, msg mqtt.Message) {
 		if v, ok := t.connections.Load(key); ok {
 			if conn, ok := v.(*MQTTConnection); ok {
 				mt := inferMessageType(msg.Payload())
+				if !t.allowDeviceMessage(conn, tenantID, deviceID, mt, msg.Payload()) {
+					return
+				}
 				conn.PushIncoming(mt, msg.Payload())
 				// 更新会话活跃时间
 				device.GetPresenceManager().TouchSession(deviceID, sessionID)
@@ -381,6 +437,25 @@ func (t *MQTTTransport) onMessage(_ mqtt.Client, msg mqtt.Message) {
 	}
 }
 
+// allowDeviceMessage 按设备限制上行消息频率，超出时丢弃消息并通知设备，控制消息不限流
+func (t *MQTTTransport) allowDeviceMessage(conn *MQTTConnection, tenantID, deviceID string, messageType int, payload []byte) bool {
+	limitKey := deviceID
+	if tenantID != "" {
+		limitKey = tenantID + "/" + deviceID
+	}
+	result := t.rateLimiter.Allow(limitKey, messageType, payload)
+	if result.Allowed {
+		return true
+	}
+	if result.Notify {
+		t.logger.Warn("设备上行消息过于频繁，丢弃消息: deviceID=%s, messageType=%d", limitKey, messageType)
+		if err := conn.WriteMessage(1, transport.RateLimitMessage(result.RetryAfter)); err != nil {
+			t.logger.Warn("发送限流消息失败: deviceID=%s, %v", limitKey, err)
+		}
+	}
+	return false
+}
+
 // onHeartbeatMessage 处理心跳消息：主题形如 prefix/{deviceID}/status/heartbeat
 func (t *MQTTTransport) onHeartbeatMessage(_ mqtt.Client, msg mqtt.Message) {
 	deviceID := t.extractDeviceIDFromStatusTopic(msg.Topic())
@@ -454,8 +529,8 @@ func (t *MQTTTransport) extractDeviceIDFromStatusTopic(topic string) string {
 }
 
 // sendErrorResponse 发送错误响应到设备
-func (t *MQTTTransport) sendErrorResponse(deviceID, sessionID, errorMsg string) {
-	prefix := strings.TrimSuffix(t.cfg.Transport.Mqtt.TopicRoot, "/")
+func (t *MQTTTransport) sendErrorResponse(tenantID, deviceID, sessionID, errorMsg string) {
+	prefix := t.topicPrefix(tenantID)
 	outSuffix := strings.TrimPrefix(t.cfg.Transport.Mqtt.OutSuffix, "/")
 	outTopic := fmt.Sprintf("%s/%s/%s/%s", prefix, deviceID, sessionID, outSuffix)
 
@@ -481,32 +556,77 @@ func (t *MQTTTransport) sendErrorResponse(deviceID, sessionID, errorMsg string)
 }
 
 // newConnection 创建新的会话连接
-func (t *MQTTTransport) newConnection(deviceID, sessionID string) *MQTTConnection {
+func (t *MQTTTransport) newConnection(tenantID, deviceID, sessionID string) *MQTTConnection {
 	if t.client == nil || !t.client.IsConnected() {
 		t.logger.Error("MQTT客户端未连接，无法创建连接")
 		return nil
 	}
-	prefix := strings.TrimSuffix(t.cfg.Transport.Mqtt.TopicRoot, "/")
+	prefix := t.topicPrefix(tenantID)
 	outSuffix := strings.TrimPrefix(t.cfg.Transport.Mqtt.OutSuffix, "/")
 	outTopic := fmt.Sprintf("%s/%s/%s/%s", prefix, deviceID, sessionID, outSuffix)
 	connID := fmt.Sprintf("%s/%s", deviceID, sessionID)
-	return NewMQTTConnection(t.client, connID, outTopic, t.cfg.Transport.Mqtt.Qos)
+	conn := NewMQTTConnection(t.client, connID, outTopic, t.cfg.Transport.Mqtt.Qos)
+	conn.SetAudioNackEnabled(t.cfg.Transport.Mqtt.AudioNackEnabled)
+	return conn
+}
+
+// topicPrefix 返回租户的主题前缀，topic_root 不含 {tenant} 时与租户无关
+func (t *MQTTTransport) topicPrefix(tenantID string) string {
+	root := strings.TrimSuffix(t.cfg.Transport.Mqtt.TopicRoot, "/")
+	return strings.ReplaceAll(root, tenantPlaceholder, tenantID)
+}
+
+// tenantLevel 返回 topic_root 中 {tenant} 所在的主题层级与 topic_root 的层级数，不含占位符时返回-1
+func (t *MQTTTransport) tenantLevel() (int, int) {
+	rootParts := strings.Split(strings.TrimSuffix(t.cfg.Transport.Mqtt.TopicRoot, "/"), "/")
+	for i, part := range rootParts {
+		if part == tenantPlaceholder {
+			return i, len(rootParts)
+		}
+	}
+	return -1, len(rootParts)
 }
 
-// extractIDs 从主题中解析 deviceID 与 sessionID
-func (t *MQTTTransport) extractIDs(topic string) (string, string, bool) {
+// allowedTenant 租户是否在 tenant_ids 中，未配置 tenant_ids 时接受任意租户
+func (t *MQTTTransport) allowedTenant(tenantID string) bool {
+	tenantIDs := t.cfg.Transport.Mqtt.TenantIDs
+	if len(tenantIDs) == 0 {
+		return true
+	}
+	for _, id := range tenantIDs {
+		if id == tenantID {
+			return true
+		}
+	}
+	return false
+}
+
+// extractIDs 从主题中解析 tenantID、deviceID 与 sessionID，topic_root 不含 {tenant} 时 tenantID 为空
+func (t *MQTTTransport) extractIDs(topic string) (string, string, string, bool) {
 	parts := strings.Split(topic, "/")
 	if len(parts) < 3 {
-		return "", "", false
+		return "", "", "", false
 	}
 	// 末尾应为 InSuffix
 	inSuffix := strings.TrimPrefix(t.cfg.Transport.Mqtt.InSuffix, "/")
 	if parts[len(parts)-1] != inSuffix {
-		return "", "", false
+		return "", "", "", false
 	}
 	sessionID := parts[len(parts)-2]
 	deviceID := parts[len(parts)-3]
-	return deviceID, sessionID, true
+
+	tenantID := ""
+	if level, rootLevels := t.tenantLevel(); level >= 0 {
+		// 多租户主题形如 prefix/{tenant}/{deviceID}/{sessionID}/in
+		if len(parts) != rootLevels+3 {
+			return "", "", "", false
+		}
+		tenantID = parts[level]
+		if tenantID == "" || !t.allowedTenant(tenantID) {
+			return "", "", "", false
+		}
+	}
+	return tenantID, deviceID, sessionID, true
 }
 
 // inferMessageType 基于payload内容推断消息类型：文本=1，二进制=2
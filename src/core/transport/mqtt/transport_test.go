@@ -0,0 +1,276 @@
+package mqtt
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"angrymiao-ai-server/src/configs"
+	"angrymiao-ai-server/src/core/auth"
+	"angrymiao-ai-server/src/core/pool"
+	"angrymiao-ai-server/src/core/providers"
+	"angrymiao-ai-server/src/core/providers/llm"
+	"angrymiao-ai-server/src/core/transport"
+	"angrymiao-ai-server/src/core/types"
+	"angrymiao-ai-server/src/core/utils"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// fakeMessage 模拟Broker投递的消息
+type fakeMessage struct {
+	mqtt.Message
+	topic   string
+	payload []byte
+}
+
+func (m *fakeMessage) Topic() string   { return m.topic }
+func (m *fakeMessage) Payload() []byte { return m.payload }
+
+type fakeSubscription struct {
+	filter  string
+	handler mqtt.MessageHandler
+}
+
+type publishedMessage struct {
+	topic   string
+	payload []byte
+}
+
+// fakeBroker 模拟MQTT Broker：记录订阅与服务端发布的消息，按订阅的主题过滤器投递设备消息
+type fakeBroker struct {
+	mqtt.Client
+	mu        sync.Mutex
+	subs      []fakeSubscription
+	published chan publishedMessage
+}
+
+func newFakeBroker() *fakeBroker {
+	return &fakeBroker{published: make(chan publishedMessage, 16)}
+}
+
+func (b *fakeBroker) IsConnected() bool { return true }
+func (b *fakeBroker) Disconnect(uint)   {}
+
+func (b *fakeBroker) Subscribe(topic string, qos byte, callback mqtt.MessageHandler) mqtt.Token {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subs = append(b.subs, fakeSubscription{filter: topic, handler: callback})
+	return doneToken{}
+}
+
+func (b *fakeBroker) Publish(topic string, qos byte, retained bool, payload interface{}) mqtt.Token {
+	b.published <- publishedMessage{topic: topic, payload: payload.([]byte)}
+	return doneToken{}
+}
+
+// deliver 将设备发布的消息投递给匹配的订阅，返回匹配的订阅数
+func (b *fakeBroker) deliver(topic string, payload []byte) int {
+	b.mu.Lock()
+	subs := append([]fakeSubscription(nil), b.subs...)
+	b.mu.Unlock()
+	matched := 0
+	for _, sub := range subs {
+		if topicMatches(sub.filter, topic) {
+			sub.handler(b, &fakeMessage{topic: topic, payload: payload})
+			matched++
+		}
+	}
+	return matched
+}
+
+// topicMatches 判断主题是否匹配过滤器，仅支持单层通配符 +
+func topicMatches(filter, topic string) bool {
+	filterParts := strings.Split(filter, "/")
+	topicParts := strings.Split(topic, "/")
+	if len(filterParts) != len(topicParts) {
+		return false
+	}
+	for i, part := range filterParts {
+		if part != "+" && part != topicParts[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// modelLLM 以模型名作为回复的LLM
+type modelLLM struct {
+	providers.LLMProvider
+	model string
+}
+
+func (p *modelLLM) Initialize() error { return nil }
+func (p *modelLLM) Cleanup() error    { return nil }
+
+func (p *modelLLM) Response(ctx context.Context, sessionID string, messages []types.Message) (<-chan string, error) {
+	ch := make(chan string, 1)
+	ch <- p.model
+	close(ch)
+	return ch, nil
+}
+
+func init() {
+	llm.Register("mqtt_tenant_test", func(config *llm.Config) (llm.Provider, error) {
+		return &modelLLM{model: config.ModelName}, nil
+	})
+}
+
+// replyHandler 使用租户资源池中的LLM回复一条消息
+type replyHandler struct {
+	conn transport.Connection
+	pm   *pool.PoolManager
+	set  *pool.ProviderSet
+}
+
+func (h *replyHandler) Handle() {
+	ch, _ := h.set.LLM.Response(context.Background(), "", nil)
+	data, _ := json.Marshal(map[string]string{"type": "llm", "text": <-ch})
+	h.conn.WriteMessage(1, data)
+}
+
+func (h *replyHandler) Close()               { h.pm.ReturnProviderSet(h.set) }
+func (h *replyHandler) GetSessionID() string { return h.conn.GetID() }
+
+// tenantFactory 按请求头 Tenant-Id 从租户资源池获取提供者
+type tenantFactory struct {
+	pools *pool.TenantPools
+	mu    sync.Mutex
+	llms  map[string]providers.LLMProvider
+}
+
+func (f *tenantFactory) CreateHandler(conn transport.Connection, req *http.Request) transport.ConnectionHandler {
+	tenantID := req.Header.Get("Tenant-Id")
+	_, pm, err := f.pools.Get(tenantID)
+	if err != nil {
+		return nil
+	}
+	set, err := pm.GetProviderSet()
+	if err != nil {
+		return nil
+	}
+	f.mu.Lock()
+	f.llms[tenantID] = set.LLM
+	f.mu.Unlock()
+	return &replyHandler{conn: conn, pm: pm, set: set}
+}
+
+func newTenantTestConfig() *configs.Config {
+	cfg := &configs.Config{
+		SelectedModule: map[string]string{"LLM": "QwenLLM"},
+		PoolConfig:     configs.PoolConfig{PoolMinSize: 1, PoolMaxSize: 2},
+		McpPoolConfig:  configs.McpPoolConfig{PoolMaxSize: 1},
+		LLM:            map[string]configs.LLMConfig{"QwenLLM": {Type: "mqtt_tenant_test", ModelName: "qwen"}},
+		Tenants: map[string]configs.TenantProviderConfig{
+			"tenant_b": {
+				SelectedModule: map[string]string{"LLM": "GlmLLM"},
+				LLM:            map[string]configs.LLMConfig{"GlmLLM": {Type: "mqtt_tenant_test", ModelName: "glm"}},
+			},
+		},
+	}
+	cfg.Server.Token = "test-secret"
+	cfg.Transport.Mqtt.TopicRoot = "mqtt_msg/{tenant}"
+	cfg.Transport.Mqtt.InSuffix = "in"
+	cfg.Transport.Mqtt.OutSuffix = "out"
+	cfg.Transport.Mqtt.TenantIDs = []string{"tenant_a", "tenant_b"}
+	return cfg
+}
+
+func newTestLogger(t *testing.T) *utils.Logger {
+	t.Helper()
+	logger, err := utils.NewLogger(&utils.LogCfg{LogLevel: "ERROR", LogDir: t.TempDir(), LogFile: "test.log"})
+	if err != nil {
+		t.Fatalf("创建日志失败: %v", err)
+	}
+	return logger
+}
+
+func TestExtractIDsParsesTenant(t *testing.T) {
+	cfg := newTenantTestConfig()
+	tr := NewMQTTTransport(cfg, newTestLogger(t))
+	tests := []struct {
+		topic  string
+		tenant string
+		ok     bool
+	}{
+		{"mqtt_msg/tenant_a/dev1/s1/in", "tenant_a", true},
+		{"mqtt_msg/tenant_c/dev1/s1/in", "", false}, // 不在 tenant_ids 中
+		{"mqtt_msg/dev1/s1/in", "", false},          // 缺少租户层级
+		{"mqtt_msg/tenant_a/dev1/s1/out", "", false},
+	}
+	for _, tt := range tests {
+		tenantID, deviceID, sessionID, ok := tr.extractIDs(tt.topic)
+		if ok != tt.ok || tenantID != tt.tenant || (ok && (deviceID != "dev1" || sessionID != "s1")) {
+			t.Errorf("extractIDs(%q) = %q, %q, %q, %v", tt.topic, tenantID, deviceID, sessionID, ok)
+		}
+	}
+
+	// topic_root 不含占位符时与之前一致，租户ID为空
+	cfg.Transport.Mqtt.TopicRoot = "mqtt_msg"
+	if tenantID, deviceID, _, ok := tr.extractIDs("mqtt_msg/dev1/s1/in"); !ok || tenantID != "" || deviceID != "dev1" {
+		t.Errorf("单租户主题解析 = %q, %q, %v", tenantID, deviceID, ok)
+	}
+}
+
+func TestTenantsReceiveRepliesFromSeparateProviders(t *testing.T) {
+	cfg := newTenantTestConfig()
+	logger := newTestLogger(t)
+	pools := pool.NewTenantPools(cfg, logger)
+	t.Cleanup(pools.Close)
+	factory := &tenantFactory{pools: pools, llms: make(map[string]providers.LLMProvider)}
+
+	broker := newFakeBroker()
+	tr := NewMQTTTransport(cfg, logger)
+	tr.SetConnectionHandler(factory)
+	tr.client = broker
+	tr.subscribe(broker)
+	t.Cleanup(func() { tr.Stop() })
+
+	if broker.subs[0].filter != "mqtt_msg/+/+/+/in" {
+		t.Fatalf("入站订阅 = %s, 期望 mqtt_msg/+/+/+/in", broker.subs[0].filter)
+	}
+
+	token, err := auth.NewAuthTokenWithConfig(cfg.Server.Token, "mqtt_msg/+").GenerateToken("dev1")
+	if err != nil {
+		t.Fatalf("生成Token失败: %v", err)
+	}
+	// headers 中的 Tenant-Id 不生效，租户以主题为准
+	hello, _ := json.Marshal(map[string]interface{}{
+		"headers": map[string]string{"Token": token, "Tenant-Id": "tenant_b"},
+		"payload": map[string]string{"type": "hello"},
+	})
+
+	replies := map[string]string{}
+	for _, tenantID := range []string{"tenant_a", "tenant_b"} {
+		if n := broker.deliver("mqtt_msg/"+tenantID+"/dev1/s1/in", hello); n != 1 {
+			t.Fatalf("租户%s的消息匹配 %d 个订阅", tenantID, n)
+		}
+		select {
+		case msg := <-broker.published:
+			var reply struct{ Text string }
+			json.Unmarshal(msg.payload, &reply)
+			replies[msg.topic] = reply.Text
+		case <-time.After(2 * time.Second):
+			t.Fatalf("租户%s未收到回复", tenantID)
+		}
+	}
+	if replies["mqtt_msg/tenant_a/dev1/s1/out"] != "qwen" || replies["mqtt_msg/tenant_b/dev1/s1/out"] != "glm" {
+		t.Errorf("租户回复 = %v", replies)
+	}
+	factory.mu.Lock()
+	llmA, llmB := factory.llms["tenant_a"], factory.llms["tenant_b"]
+	factory.mu.Unlock()
+	if llmA == nil || llmA == llmB {
+		t.Errorf("不同租户应使用不同的LLM实例: %p, %p", llmA, llmB)
+	}
+
+	// 不在 tenant_ids 中的租户不会创建连接
+	broker.deliver("mqtt_msg/tenant_c/dev1/s1/in", hello)
+	if pools.Len() != 2 {
+		t.Errorf("租户资源池数量 = %d, 期望 2", pools.Len())
+	}
+}
@@ -0,0 +1,514 @@
+package mqtt
+
+import (
+	"angrymiao-ai-server/src/core/utils"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// STUN/TURN 协议常量（RFC 5389、RFC 5766）
+const (
+	stunMagicCookie = 0x2112A442
+	stunHeaderSize  = 20
+
+	turnMethodAllocate         = 0x003
+	turnMethodRefresh          = 0x004
+	turnMethodSend             = 0x006
+	turnMethodData             = 0x007
+	turnMethodCreatePermission = 0x008
+
+	stunClassRequest    = 0x000
+	stunClassIndication = 0x010
+	stunClassSuccess    = 0x100
+	stunClassError      = 0x110
+
+	stunAttrUsername           = 0x0006
+	stunAttrMessageIntegrity   = 0x0008
+	stunAttrErrorCode          = 0x0009
+	stunAttrLifetime           = 0x000D
+	stunAttrXORPeerAddress     = 0x0012
+	stunAttrData               = 0x0013
+	stunAttrRealm              = 0x0014
+	stunAttrNonce              = 0x0015
+	stunAttrXORRelayedAddress  = 0x0016
+	stunAttrRequestedTransport = 0x0019
+	stunAttrXORMappedAddress   = 0x0020
+
+	turnTransportUDP = 17
+
+	turnRequestTimeout = 3 * time.Second
+	// 权限有效期为5分钟，提前刷新
+	turnPermissionRefresh = 4 * time.Minute
+	turnDefaultLifetime   = 10 * time.Minute
+)
+
+// stunMessage STUN消息，method 与 class 合并为消息类型
+type stunMessage struct {
+	method uint16
+	class  uint16
+	txID   [12]byte
+	attrs  []stunAttr
+}
+
+type stunAttr struct {
+	typ   uint16
+	value []byte
+}
+
+func newStunMessage(method, class uint16) *stunMessage {
+	m := &stunMessage{method: method, class: class}
+	rand.Read(m.txID[:])
+	return m
+}
+
+func (m *stunMessage) add(typ uint16, value []byte) {
+	m.attrs = append(m.attrs, stunAttr{typ: typ, value: value})
+}
+
+func (m *stunMessage) get(typ uint16) ([]byte, bool) {
+	for _, attr := range m.attrs {
+		if attr.typ == typ {
+			return attr.value, true
+		}
+	}
+	return nil, false
+}
+
+// messageType 按RFC 5389将 method 与 class 编码为14位消息类型
+func (m *stunMessage) messageType() uint16 {
+	method := m.method
+	return (method & 0x000F) | (method&0x0070)<<1 | (method&0x0F80)<<2 | m.class
+}
+
+// encode 编码消息，key 非空时追加 MESSAGE-INTEGRITY
+func (m *stunMessage) encode(key []byte) []byte {
+	buf := make([]byte, stunHeaderSize, 256)
+	binary.BigEndian.PutUint16(buf[0:2], m.messageType())
+	binary.BigEndian.PutUint32(buf[4:8], stunMagicCookie)
+	copy(buf[8:20], m.txID[:])
+	for _, attr := range m.attrs {
+		buf = appendStunAttr(buf, attr.typ, attr.value)
+	}
+	if key != nil {
+		// 长度字段需包含 MESSAGE-INTEGRITY 属性本身（4字节头 + 20字节HMAC）
+		binary.BigEndian.PutUint16(buf[2:4], uint16(len(buf)-stunHeaderSize+24))
+		mac := hmac.New(sha1.New, key)
+		mac.Write(buf)
+		buf = appendStunAttr(buf, stunAttrMessageIntegrity, mac.Sum(nil))
+	}
+	binary.BigEndian.PutUint16(buf[2:4], uint16(len(buf)-stunHeaderSize))
+	return buf
+}
+
+func appendStunAttr(buf []byte, typ uint16, value []byte) []byte {
+	var header [4]byte
+	binary.BigEndian.PutUint16(header[0:2], typ)
+	binary.BigEndian.PutUint16(header[2:4], uint16(len(value)))
+	buf = append(buf, header[:]...)
+	buf = append(buf, value...)
+	// 属性按4字节对齐
+	for len(buf)%4 != 0 {
+		buf = append(buf, 0)
+	}
+	return buf
+}
+
+// isStunMessage 判断数据包是否为STUN消息：前两位为0且包含 magic cookie
+func isStunMessage(data []byte) bool {
+	return len(data) >= stunHeaderSize && data[0]&0xC0 == 0 &&
+		binary.BigEndian.Uint32(data[4:8]) == stunMagicCookie
+}
+
+func decodeStunMessage(data []byte) (*stunMessage, error) {
+	if !isStunMessage(data) {
+		return nil, fmt.Errorf("不是STUN消息")
+	}
+	length := int(binary.BigEndian.Uint16(data[2:4]))
+	if stunHeaderSize+length > len(data) {
+		return nil, fmt.Errorf("STUN消息长度不足: %d", len(data))
+	}
+
+	typ := binary.BigEndian.Uint16(data[0:2])
+	m := &stunMessage{
+		method: (typ & 0x000F) | (typ&0x00E0)>>1 | (typ&0x3E00)>>2,
+		class:  typ & 0x0110,
+	}
+	copy(m.txID[:], data[8:20])
+
+	body := data[stunHeaderSize : stunHeaderSize+length]
+	for len(body) >= 4 {
+		attrType := binary.BigEndian.Uint16(body[0:2])
+		attrLen := int(binary.BigEndian.Uint16(body[2:4]))
+		if 4+attrLen > len(body) {
+			return nil, fmt.Errorf("STUN属性长度无效: type=0x%04x", attrType)
+		}
+		m.add(attrType, body[4:4+attrLen])
+		padded := (attrLen + 3) &^ 3
+		if 4+padded > len(body) {
+			break
+		}
+		body = body[4+padded:]
+	}
+	return m, nil
+}
+
+// encodeXORAddress 编码 XOR-*-ADDRESS 属性
+func encodeXORAddress(addr *net.UDPAddr, txID [12]byte) []byte {
+	ip := addr.IP.To4()
+	family := byte(0x01)
+	if ip == nil {
+		ip = addr.IP.To16()
+		family = 0x02
+	}
+	value := make([]byte, 4+len(ip))
+	value[1] = family
+	binary.BigEndian.PutUint16(value[2:4], uint16(addr.Port)^uint16(stunMagicCookie>>16))
+	mask := xorMask(txID)
+	for i := range ip {
+		value[4+i] = ip[i] ^ mask[i]
+	}
+	return value
+}
+
+// decodeXORAddress 解析 XOR-*-ADDRESS 属性
+func decodeXORAddress(value []byte, txID [12]byte) (*net.UDPAddr, error) {
+	if len(value) < 8 {
+		return nil, fmt.Errorf("地址属性长度无效: %d", len(value))
+	}
+	ipLen := 4
+	if value[1] == 0x02 {
+		ipLen = 16
+	}
+	if len(value) < 4+ipLen {
+		return nil, fmt.Errorf("地址属性长度无效: %d", len(value))
+	}
+	mask := xorMask(txID)
+	ip := make(net.IP, ipLen)
+	for i := range ip {
+		ip[i] = value[4+i] ^ mask[i]
+	}
+	port := binary.BigEndian.Uint16(value[2:4]) ^ uint16(stunMagicCookie>>16)
+	return &net.UDPAddr{IP: ip, Port: int(port)}, nil
+}
+
+// xorMask IPv4 使用 magic cookie，IPv6 使用 magic cookie + 事务ID
+func xorMask(txID [12]byte) []byte {
+	mask := make([]byte, 16)
+	binary.BigEndian.PutUint32(mask[0:4], stunMagicCookie)
+	copy(mask[4:], txID[:])
+	return mask
+}
+
+// stunErrorCode 解析 ERROR-CODE 属性
+func stunErrorCode(m *stunMessage) (int, string) {
+	value, ok := m.get(stunAttrErrorCode)
+	if !ok || len(value) < 4 {
+		return 0, ""
+	}
+	return int(value[2]&0x07)*100 + int(value[3]), string(value[4:])
+}
+
+// TURNClient 最小化的TURN客户端（RFC 5766），通过中继地址与设备收发UDP数据
+// 仅支持UDP中继、长期凭证认证，以及 Allocate、Refresh、CreatePermission 请求和 Send/Data 指示
+type TURNClient struct {
+	server   *net.UDPAddr
+	username string
+	password string
+	conn     *net.UDPConn
+	logger   *utils.Logger
+
+	mu          sync.Mutex
+	realm       string
+	nonce       string
+	relayAddr   *net.UDPAddr
+	permissions map[string]time.Time // 对端IP -> 权限创建时间
+	pending     map[[12]byte]chan *stunMessage
+	onData      func(peer *net.UDPAddr, data []byte)
+
+	stopChan chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+// NewTURNClient 创建TURN客户端，使用独立的本地UDP端口与TURN服务器通信
+// onData 接收设备经中继发来的数据
+func NewTURNClient(server, username, password string, logger *utils.Logger, onData func(peer *net.UDPAddr, data []byte)) (*TURNClient, error) {
+	serverAddr, err := net.ResolveUDPAddr("udp", server)
+	if err != nil {
+		return nil, fmt.Errorf("解析TURN服务器地址失败: %v", err)
+	}
+	conn, err := net.ListenUDP("udp", nil)
+	if err != nil {
+		return nil, fmt.Errorf("创建TURN客户端连接失败: %v", err)
+	}
+	c := &TURNClient{
+		server:      serverAddr,
+		username:    username,
+		password:    password,
+		conn:        conn,
+		logger:      logger,
+		permissions: make(map[string]time.Time),
+		pending:     make(map[[12]byte]chan *stunMessage),
+		onData:      onData,
+		stopChan:    make(chan struct{}),
+	}
+	c.wg.Add(1)
+	go c.readLoop()
+	return c, nil
+}
+
+// RelayAddr 分配到的中继地址，未分配时返回nil
+func (c *TURNClient) RelayAddr() *net.UDPAddr {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.relayAddr
+}
+
+// Allocate 申请UDP中继地址，并在有效期内定时刷新
+func (c *TURNClient) Allocate() (*net.UDPAddr, error) {
+	resp, err := c.request(turnMethodAllocate, func(req *stunMessage) {
+		req.add(stunAttrRequestedTransport, []byte{turnTransportUDP, 0, 0, 0})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("TURN Allocate失败: %v", err)
+	}
+
+	value, ok := resp.get(stunAttrXORRelayedAddress)
+	if !ok {
+		return nil, fmt.Errorf("TURN Allocate响应缺少中继地址")
+	}
+	relayAddr, err := decodeXORAddress(value, resp.txID)
+	if err != nil {
+		return nil, fmt.Errorf("解析中继地址失败: %v", err)
+	}
+	lifetime := turnDefaultLifetime
+	if value, ok := resp.get(stunAttrLifetime); ok && len(value) == 4 {
+		lifetime = time.Duration(binary.BigEndian.Uint32(value)) * time.Second
+	}
+
+	c.mu.Lock()
+	c.relayAddr = relayAddr
+	c.mu.Unlock()
+
+	c.wg.Add(1)
+	go c.refreshLoop(lifetime)
+	c.logger.Info("TURN中继地址分配成功: relay=%s, lifetime=%v", relayAddr.String(), lifetime)
+	return relayAddr, nil
+}
+
+// CreatePermission 允许对端IP经中继地址收发数据
+func (c *TURNClient) CreatePermission(ip net.IP) error {
+	_, err := c.request(turnMethodCreatePermission, func(req *stunMessage) {
+		req.add(stunAttrXORPeerAddress, encodeXORAddress(&net.UDPAddr{IP: ip}, req.txID))
+	})
+	if err != nil {
+		return fmt.Errorf("TURN CreatePermission失败: %v", err)
+	}
+	c.mu.Lock()
+	c.permissions[ip.String()] = time.Now()
+	c.mu.Unlock()
+	return nil
+}
+
+// SendTo 经中继地址向对端发送数据，未创建权限或权限即将过期时先创建权限
+func (c *TURNClient) SendTo(data []byte, peer *net.UDPAddr) error {
+	c.mu.Lock()
+	created, ok := c.permissions[peer.IP.String()]
+	c.mu.Unlock()
+	if !ok || time.Since(created) > turnPermissionRefresh {
+		if err := c.CreatePermission(peer.IP); err != nil {
+			return err
+		}
+	}
+
+	msg := newStunMessage(turnMethodSend, stunClassIndication)
+	msg.add(stunAttrXORPeerAddress, encodeXORAddress(peer, msg.txID))
+	msg.add(stunAttrData, data)
+	_, err := c.conn.WriteToUDP(msg.encode(nil), c.server)
+	return err
+}
+
+// Close 释放中继地址并关闭连接
+func (c *TURNClient) Close() error {
+	var err error
+	c.stopOnce.Do(func() {
+		if c.RelayAddr() != nil {
+			// LIFETIME 为0表示释放分配，失败不影响关闭
+			_, e := c.request(turnMethodRefresh, func(req *stunMessage) {
+				req.add(stunAttrLifetime, []byte{0, 0, 0, 0})
+			})
+			if e != nil {
+				c.logger.Warn("释放TURN中继地址失败: %v", e)
+			}
+		}
+		close(c.stopChan)
+		err = c.conn.Close()
+		c.wg.Wait()
+	})
+	return err
+}
+
+// request 发送请求并等待响应，收到401或438时使用服务器下发的 realm、nonce 认证后重试一次
+// build 负责添加请求属性，每次发送都会生成新的事务ID，XOR地址需基于当前消息编码
+func (c *TURNClient) request(method uint16, build func(req *stunMessage)) (*stunMessage, error) {
+	for attempt := 0; attempt < 2; attempt++ {
+		c.mu.Lock()
+		realm, nonce := c.realm, c.nonce
+		c.mu.Unlock()
+
+		req := newStunMessage(method, stunClassRequest)
+		if build != nil {
+			build(req)
+		}
+		var key []byte
+		if realm != "" {
+			req.add(stunAttrUsername, []byte(c.username))
+			req.add(stunAttrRealm, []byte(realm))
+			req.add(stunAttrNonce, []byte(nonce))
+			key = c.integrityKey(realm)
+		}
+
+		resp, err := c.roundTrip(req, key)
+		if err != nil {
+			return nil, err
+		}
+		if resp.class == stunClassSuccess {
+			return resp, nil
+		}
+
+		code, reason := stunErrorCode(resp)
+		if (code == 401 || code == 438) && attempt == 0 {
+			realmValue, _ := resp.get(stunAttrRealm)
+			nonceValue, _ := resp.get(stunAttrNonce)
+			c.mu.Lock()
+			if len(realmValue) > 0 {
+				c.realm = string(realmValue)
+			}
+			c.nonce = string(nonceValue)
+			c.mu.Unlock()
+			continue
+		}
+		return nil, fmt.Errorf("TURN服务器返回错误: %d %s", code, reason)
+	}
+	return nil, fmt.Errorf("TURN认证失败")
+}
+
+// roundTrip 发送请求并按事务ID等待响应
+func (c *TURNClient) roundTrip(req *stunMessage, key []byte) (*stunMessage, error) {
+	ch := make(chan *stunMessage, 1)
+	c.mu.Lock()
+	c.pending[req.txID] = ch
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, req.txID)
+		c.mu.Unlock()
+	}()
+
+	if _, err := c.conn.WriteToUDP(req.encode(key), c.server); err != nil {
+		return nil, fmt.Errorf("发送TURN请求失败: %v", err)
+	}
+
+	select {
+	case resp := <-ch:
+		return resp, nil
+	case <-time.After(turnRequestTimeout):
+		return nil, fmt.Errorf("TURN请求超时")
+	case <-c.stopChan:
+		return nil, fmt.Errorf("TURN客户端已关闭")
+	}
+}
+
+// integrityKey 长期凭证的 MESSAGE-INTEGRITY 密钥：MD5(username:realm:password)
+func (c *TURNClient) integrityKey(realm string) []byte {
+	sum := md5.Sum([]byte(c.username + ":" + realm + ":" + c.password))
+	return sum[:]
+}
+
+// readLoop 接收TURN服务器的响应与 Data 指示
+func (c *TURNClient) readLoop() {
+	defer c.wg.Done()
+
+	buffer := make([]byte, 65535)
+	for {
+		n, _, err := c.conn.ReadFromUDP(buffer)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			c.logger.Warn("读取TURN数据失败: %v", err)
+			continue
+		}
+
+		msg, err := decodeStunMessage(buffer[:n])
+		if err != nil {
+			c.logger.Warn("解析TURN消息失败: %v", err)
+			continue
+		}
+
+		if msg.class == stunClassIndication && msg.method == turnMethodData {
+			c.handleDataIndication(msg)
+			continue
+		}
+
+		c.mu.Lock()
+		ch, ok := c.pending[msg.txID]
+		c.mu.Unlock()
+		if ok {
+			ch <- msg
+		}
+	}
+}
+
+// handleDataIndication 将设备经中继发来的数据交给 onData
+func (c *TURNClient) handleDataIndication(msg *stunMessage) {
+	peerValue, ok := msg.get(stunAttrXORPeerAddress)
+	data, hasData := msg.get(stunAttrData)
+	if !ok || !hasData {
+		return
+	}
+	peer, err := decodeXORAddress(peerValue, msg.txID)
+	if err != nil {
+		c.logger.Warn("解析TURN对端地址失败: %v", err)
+		return
+	}
+	if c.onData != nil {
+		// data 引用读取缓冲区，交给处理方前复制
+		c.onData(peer, append([]byte(nil), data...))
+	}
+}
+
+// refreshLoop 在分配有效期过半时刷新
+func (c *TURNClient) refreshLoop(lifetime time.Duration) {
+	defer c.wg.Done()
+
+	interval := lifetime / 2
+	if interval < time.Second {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.stopChan:
+			return
+		case <-ticker.C:
+			var value [4]byte
+			binary.BigEndian.PutUint32(value[:], uint32(lifetime/time.Second))
+			_, err := c.request(turnMethodRefresh, func(req *stunMessage) {
+				req.add(stunAttrLifetime, value[:])
+			})
+			if err != nil {
+				c.logger.Warn("刷新TURN中继地址失败: %v", err)
+			}
+		}
+	}
+}
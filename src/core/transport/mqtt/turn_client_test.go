@@ -0,0 +1,155 @@
+package mqtt
+
+import (
+	"bytes"
+	"crypto/md5"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"angrymiao-ai-server/src/core/utils"
+)
+
+// mockTURNServer 模拟TURN服务器：未认证的请求返回401，认证通过后分配固定的中继地址
+// 收到 Send 指示后以 Data 指示回复 "pong"
+type mockTURNServer struct {
+	t    *testing.T
+	conn *net.UDPConn
+	key  []byte
+
+	mu          sync.Mutex
+	permissions []string
+	sent        [][]byte
+}
+
+var mockRelayAddr = &net.UDPAddr{IP: net.IPv4(203, 0, 113, 5).To4(), Port: 49152}
+
+func newMockTURNServer(t *testing.T) *mockTURNServer {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("启动模拟TURN服务器失败: %v", err)
+	}
+	sum := md5.Sum([]byte("device:test-realm:secret"))
+	s := &mockTURNServer{t: t, conn: conn, key: sum[:]}
+	go s.serve()
+	t.Cleanup(func() { conn.Close() })
+	return s
+}
+
+func (s *mockTURNServer) serve() {
+	buffer := make([]byte, 65535)
+	for {
+		n, addr, err := s.conn.ReadFromUDP(buffer)
+		if err != nil {
+			return
+		}
+		data := append([]byte(nil), buffer[:n]...)
+		req, err := decodeStunMessage(data)
+		if err != nil {
+			s.t.Errorf("解析请求失败: %v", err)
+			continue
+		}
+
+		if req.class == stunClassIndication && req.method == turnMethodSend {
+			peerValue, _ := req.get(stunAttrXORPeerAddress)
+			payload, _ := req.get(stunAttrData)
+			peer, _ := decodeXORAddress(peerValue, req.txID)
+			s.mu.Lock()
+			s.sent = append(s.sent, payload)
+			s.mu.Unlock()
+
+			reply := newStunMessage(turnMethodData, stunClassIndication)
+			reply.add(stunAttrXORPeerAddress, encodeXORAddress(peer, reply.txID))
+			reply.add(stunAttrData, []byte("pong"))
+			s.conn.WriteToUDP(reply.encode(nil), addr)
+			continue
+		}
+
+		resp := &stunMessage{method: req.method, txID: req.txID}
+		if !s.authenticated(req, data) {
+			resp.class = stunClassError
+			resp.add(stunAttrErrorCode, []byte{0, 0, 4, 1})
+			resp.add(stunAttrRealm, []byte("test-realm"))
+			resp.add(stunAttrNonce, []byte("nonce-1"))
+			s.conn.WriteToUDP(resp.encode(nil), addr)
+			continue
+		}
+
+		resp.class = stunClassSuccess
+		switch req.method {
+		case turnMethodAllocate:
+			resp.add(stunAttrXORRelayedAddress, encodeXORAddress(mockRelayAddr, resp.txID))
+			resp.add(stunAttrLifetime, []byte{0, 0, 0x02, 0x58})
+		case turnMethodCreatePermission:
+			peerValue, _ := req.get(stunAttrXORPeerAddress)
+			peer, _ := decodeXORAddress(peerValue, req.txID)
+			s.mu.Lock()
+			s.permissions = append(s.permissions, peer.IP.String())
+			s.mu.Unlock()
+		}
+		s.conn.WriteToUDP(resp.encode(s.key), addr)
+	}
+}
+
+// authenticated 按长期凭证重新计算 MESSAGE-INTEGRITY 并与请求比对
+func (s *mockTURNServer) authenticated(req *stunMessage, raw []byte) bool {
+	if _, ok := req.get(stunAttrMessageIntegrity); !ok {
+		return false
+	}
+	expected := &stunMessage{method: req.method, class: req.class, txID: req.txID}
+	for _, attr := range req.attrs {
+		if attr.typ != stunAttrMessageIntegrity {
+			expected.add(attr.typ, attr.value)
+		}
+	}
+	return bytes.Equal(expected.encode(s.key), raw)
+}
+
+func TestTURNClientAllocateAndRelay(t *testing.T) {
+	logger, err := utils.NewLogger(&utils.LogCfg{LogLevel: "ERROR", LogDir: t.TempDir(), LogFile: "test.log"})
+	if err != nil {
+		t.Fatalf("创建日志失败: %v", err)
+	}
+	server := newMockTURNServer(t)
+
+	received := make(chan []byte, 1)
+	client, err := NewTURNClient(server.conn.LocalAddr().String(), "device", "secret", logger, func(peer *net.UDPAddr, data []byte) {
+		received <- data
+	})
+	if err != nil {
+		t.Fatalf("创建TURN客户端失败: %v", err)
+	}
+	defer client.Close()
+
+	relay, err := client.Allocate()
+	if err != nil {
+		t.Fatalf("Allocate失败: %v", err)
+	}
+	if relay.String() != mockRelayAddr.String() {
+		t.Errorf("中继地址 = %s, 期望 %s", relay, mockRelayAddr)
+	}
+
+	peer := &net.UDPAddr{IP: net.IPv4(198, 51, 100, 7), Port: 40000}
+	if err := client.SendTo([]byte("probe"), peer); err != nil {
+		t.Fatalf("经中继发送失败: %v", err)
+	}
+
+	select {
+	case data := <-received:
+		if string(data) != "pong" {
+			t.Errorf("中继回复 = %q, 期望 pong", data)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("等待中继数据超时")
+	}
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if len(server.permissions) != 1 || server.permissions[0] != "198.51.100.7" {
+		t.Errorf("创建的权限 = %v, 期望 [198.51.100.7]", server.permissions)
+	}
+	if len(server.sent) != 1 || string(server.sent[0]) != "probe" {
+		t.Errorf("中继发送的数据 = %q", server.sent)
+	}
+}
@@ -13,10 +13,14 @@ import (
 
 // UDPServer UDP服务器，负责处理UDP音频数据的接收和发送
 type UDPServer struct {
-	conn          *net.UDPConn  // UDP连接
-	listenPort    int           // UDP监听端口
-	externalHost  string        // 外部访问地址（返回给客户端）
-	externalPort  int           // 外部访问端口（返回给客户端）
+	conn          *net.UDPConn // UDP连接
+	listenPort    int          // UDP监听端口
+	externalHost  string       // 外部访问地址（返回给客户端）
+	externalPort  int          // 外部访问端口（返回给客户端）
+	turnServer    string       // TURN服务器地址，配置后经中继地址收发数据
+	turnUsername  string
+	turnPassword  string
+	turn          *TURNClient   // TURN客户端，未配置或分配失败时为nil
 	nonce2Session sync.Map      // connID -> *UDPSession
 	addr2Session  sync.Map      // remoteAddr.String() -> *UDPSession
 	logger        *utils.Logger // 日志记录器
@@ -71,6 +75,9 @@ func NewUDPServer(cfg *configs.Config, logger *utils.Logger) *UDPServer {
 		listenPort:   udpCfg.ListenPort,
 		externalHost: udpCfg.ExternalHost,
 		externalPort: udpCfg.ExternalPort,
+		turnServer:   udpCfg.TurnServer,
+		turnUsername: udpCfg.TurnUsername,
+		turnPassword: udpCfg.TurnPassword,
 		logger:       logger,
 		stopChan:     make(chan struct{}),
 	}
@@ -96,9 +103,46 @@ func (s *UDPServer) Start() error {
 	s.wg.Add(1)
 	go s.handlePackets()
 
+	if s.turnServer != "" {
+		s.startTURN()
+	}
 	return nil
 }
 
+// startTURN 申请TURN中继地址，失败时回退为直连
+func (s *UDPServer) startTURN() {
+	client, err := NewTURNClient(s.turnServer, s.turnUsername, s.turnPassword, s.logger, s.processPacket)
+	if err != nil {
+		s.logger.Error("创建TURN客户端失败，使用直连地址: %v", err)
+		return
+	}
+	if _, err := client.Allocate(); err != nil {
+		s.logger.Error("申请TURN中继地址失败，使用直连地址: %v", err)
+		client.Close()
+		return
+	}
+	s.turn = client
+}
+
+// AdvertisedAddress 返回给客户端的UDP服务地址，启用TURN时为中继地址
+func (s *UDPServer) AdvertisedAddress() (string, int) {
+	if s.turn != nil {
+		if relay := s.turn.RelayAddr(); relay != nil {
+			return relay.IP.String(), relay.Port
+		}
+	}
+	return s.externalHost, s.externalPort
+}
+
+// writeTo 向设备发送数据，启用TURN时经中继地址转发
+func (s *UDPServer) writeTo(data []byte, addr *net.UDPAddr) error {
+	if s.turn != nil {
+		return s.turn.SendTo(data, addr)
+	}
+	_, err := s.conn.WriteToUDP(data, addr)
+	return err
+}
+
 // Stop 停止UDP服务器
 func (s *UDPServer) Stop() error {
 	var stopErr error
@@ -108,6 +152,12 @@ func (s *UDPServer) Stop() error {
 		// 发送停止信号
 		close(s.stopChan)
 
+		if s.turn != nil {
+			if err := s.turn.Close(); err != nil {
+				s.logger.Warn("关闭TURN客户端失败: %v", err)
+			}
+		}
+
 		// 关闭UDP连接
 		if s.conn != nil {
 			if err := s.conn.Close(); err != nil {
@@ -177,6 +227,7 @@ func (s *UDPServer) CreateSession(deviceID, sessionID string) (*UDPSession, erro
 
 // ProbeClientAddress 主动探测客户端UDP地址（用于NAT穿透）
 // 向客户端提供的公网IP:Port发送探测包，等待客户端回复以确认真实地址
+// 启用TURN时探测包经中继地址发送，同时为客户端IP创建中继权限，客户端需回复到中继地址
 func (s *UDPServer) ProbeClientAddress(session *UDPSession, clientIP string, clientPort int) error {
 	if clientIP == "" || clientPort == 0 {
 		return fmt.Errorf("客户端地址信息不完整")
@@ -197,7 +248,7 @@ func (s *UDPServer) ProbeClientAddress(session *UDPSession, clientIP string, cli
 	// 发送探测包（带重试）
 	maxRetries := 3
 	for retry := 0; retry < maxRetries; retry++ {
-		_, err := s.conn.WriteToUDP(probeData, targetAddr)
+		err := s.writeTo(probeData, targetAddr)
 		if err == nil {
 			s.logger.Info("UDP探测包已发送: 目标=%s, 重试=%d/%d", targetAddr.String(), retry+1, maxRetries)
 			break
@@ -413,7 +464,7 @@ func (s *UDPServer) handleSend(session *UDPSession) {
 			// 发送UDP数据包（带重试）
 			maxRetries := 3
 			for retry := 0; retry < maxRetries; retry++ {
-				_, err = s.conn.WriteToUDP(encrypted, session.RemoteAddr)
+				err = s.writeTo(encrypted, session.RemoteAddr)
 				if err == nil {
 					break // 发送成功
 				}
@@ -0,0 +1,114 @@
+package transport
+
+import (
+	"encoding/json"
+	"sync/atomic"
+	"time"
+
+	"angrymiao-ai-server/src/core/ratelimit"
+)
+
+// rateLimitNotifyInterval 同一设备同类消息被限流时，通知客户端的最小间隔
+const rateLimitNotifyInterval = time.Second
+
+// exemptTextTypes 不计入文本限流的控制消息：心跳应答、MCP工具响应与打断，丢弃后会导致会话异常
+var exemptTextTypes = map[string]bool{"pong": true, "mcp": true, "abort": true}
+
+// RateLimitResult 单条消息的限流结果
+type RateLimitResult struct {
+	Allowed    bool
+	RetryAfter time.Duration // 被拒绝时补充一个令牌所需的时间
+	Notify     bool          // 被拒绝且距上次通知已超过 rateLimitNotifyInterval，需要通知客户端
+}
+
+// RateLimiter 按设备ID限制上行消息频率，文本消息与音频帧分别使用独立的令牌桶，容量为1秒内允许的条数
+// 速率小于等于0时不限制该类消息
+type RateLimiter struct {
+	text   *ratelimit.TokenBucketLimiter
+	audio  *ratelimit.TokenBucketLimiter
+	notify *ratelimit.TokenBucketLimiter // 每个设备每类消息每秒最多通知一次
+}
+
+// NewRateLimiter 创建设备消息限流器，textRPS 为每秒允许的文本消息数，audioFPS 为每秒允许的音频帧数
+func NewRateLimiter(textRPS, audioFPS float64) *RateLimiter {
+	l := &RateLimiter{}
+	if textRPS > 0 {
+		l.text = ratelimit.NewTokenBucketLimiter(max(textRPS, 1), textRPS)
+	}
+	if audioFPS > 0 {
+		l.audio = ratelimit.NewTokenBucketLimiter(max(audioFPS, 1), audioFPS)
+	}
+	if l.text != nil || l.audio != nil {
+		l.notify = ratelimit.NewTokenBucketLimiter(1, 1/rateLimitNotifyInterval.Seconds())
+	}
+	return l
+}
+
+// setClock 替换各令牌桶获取当前时间的函数，测试中使用
+func (l *RateLimiter) setClock(now func() time.Time) {
+	for _, limiter := range []*ratelimit.TokenBucketLimiter{l.text, l.audio, l.notify} {
+		if limiter != nil {
+			limiter.SetClock(now)
+		}
+	}
+}
+
+// Allow 消耗设备的一个令牌，messageType 为2时计入音频帧，其他计入文本消息
+// 限流器为nil、设备ID为空或文本消息为 exemptTextTypes 中的控制消息时放行
+func (l *RateLimiter) Allow(deviceID string, messageType int, data []byte) RateLimitResult {
+	if l == nil || deviceID == "" {
+		return RateLimitResult{Allowed: true}
+	}
+	limiter, kind := l.text, "text"
+	if messageType == 2 {
+		limiter, kind = l.audio, "audio"
+	}
+	if limiter == nil || (kind == "text" && isExemptTextMessage(data)) {
+		return RateLimitResult{Allowed: true}
+	}
+
+	result := limiter.Allow(deviceID)
+	if result.Allowed {
+		return RateLimitResult{Allowed: true}
+	}
+	return RateLimitResult{
+		RetryAfter: result.RetryAfter,
+		Notify:     l.notify.Allow(deviceID + "/" + kind).Allowed,
+	}
+}
+
+// isExemptTextMessage 判断文本消息是否为不计入限流的控制消息
+func isExemptTextMessage(data []byte) bool {
+	var msg struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return false
+	}
+	return exemptTextTypes[msg.Type]
+}
+
+// RateLimitMessage 下发给被限流设备的消息：{"type":"rate_limit","retry_after_ms":N}
+func RateLimitMessage(retryAfter time.Duration) []byte {
+	data, _ := json.Marshal(map[string]interface{}{
+		"type":           "rate_limit",
+		"retry_after_ms": max(retryAfter.Milliseconds(), 1),
+	})
+	return data
+}
+
+var defaultRateLimiter atomic.Pointer[RateLimiter]
+
+func init() {
+	defaultRateLimiter.Store(NewRateLimiter(0, 0))
+}
+
+// SetDefaultRateLimiter 设置所有传输层共用的设备消息限流器
+func SetDefaultRateLimiter(l *RateLimiter) {
+	defaultRateLimiter.Store(l)
+}
+
+// DefaultRateLimiter 获取所有传输层共用的设备消息限流器，未设置时不限制
+func DefaultRateLimiter() *RateLimiter {
+	return defaultRateLimiter.Load()
+}
@@ -0,0 +1,97 @@
+package transport
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterBurst(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := NewRateLimiter(5, 50)
+	l.setClock(func() time.Time { return now })
+
+	// 突发10条文本消息，只放行1秒的额度
+	allowed, notified := 0, 0
+	var retryAfter time.Duration
+	for i := 0; i < 10; i++ {
+		result := l.Allow("dev1", 1, nil)
+		if result.Allowed {
+			allowed++
+			continue
+		}
+		retryAfter = result.RetryAfter
+		if result.Notify {
+			notified++
+		}
+	}
+	if allowed != 5 {
+		t.Errorf("突发10条文本放行 %d 条, 期望 5", allowed)
+	}
+	if notified != 1 {
+		t.Errorf("1秒内通知 %d 次, 期望 1", notified)
+	}
+	if retryAfter != 200*time.Millisecond {
+		t.Errorf("retry_after = %v, 期望 200ms", retryAfter)
+	}
+
+	// 音频帧与其他设备使用独立的令牌桶
+	if !l.Allow("dev1", 2, nil).Allowed || !l.Allow("dev2", 1, nil).Allowed {
+		t.Error("音频帧与其他设备不应受dev1文本限流影响")
+	}
+
+	// 200ms 后补充一个令牌
+	now = now.Add(200 * time.Millisecond)
+	if !l.Allow("dev1", 1, nil).Allowed {
+		t.Error("补充令牌后应放行")
+	}
+	if l.Allow("dev1", 1, nil).Allowed {
+		t.Error("令牌用完后应再次限流")
+	}
+}
+
+func TestRateLimiterDisabled(t *testing.T) {
+	l := NewRateLimiter(0, 1)
+	for i := 0; i < 100; i++ {
+		if !l.Allow("dev1", 1, nil).Allowed {
+			t.Fatal("text_rps 为0时不应限制文本消息")
+		}
+	}
+	var nilLimiter *RateLimiter
+	if !nilLimiter.Allow("dev1", 2, nil).Allowed || !l.Allow("", 2, nil).Allowed {
+		t.Error("未设置限流器或设备ID为空时应放行")
+	}
+}
+
+func TestRateLimitMessage(t *testing.T) {
+	var msg map[string]interface{}
+	if err := json.Unmarshal(RateLimitMessage(1500*time.Millisecond), &msg); err != nil {
+		t.Fatalf("解析限流消息失败: %v", err)
+	}
+	if msg["type"] != "rate_limit" || msg["retry_after_ms"] != float64(1500) {
+		t.Errorf("限流消息 = %v", msg)
+	}
+}
+
+func TestRateLimiterExemptsControlMessages(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := NewRateLimiter(1, 0)
+	l.setClock(func() time.Time { return now })
+
+	if !l.Allow("dev1", 1, []byte(`{"type":"listen","state":"detect"}`)).Allowed {
+		t.Fatal("第一条文本消息应放行")
+	}
+	// 令牌用完后心跳应答、MCP响应与打断仍放行，且不消耗令牌
+	for _, msg := range []string{`{"type":"pong"}`, `{"type":"mcp","payload":{}}`, `{"type":"abort"}`} {
+		if !l.Allow("dev1", 1, []byte(msg)).Allowed {
+			t.Errorf("控制消息 %s 不应被限流", msg)
+		}
+	}
+	if l.Allow("dev1", 1, []byte(`{"type":"listen","state":"detect"}`)).Allowed {
+		t.Error("普通文本消息应被限流")
+	}
+	now = now.Add(time.Second)
+	if !l.Allow("dev1", 1, []byte("not json")).Allowed {
+		t.Error("补充令牌后应放行")
+	}
+}
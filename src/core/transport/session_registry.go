@@ -0,0 +1,349 @@
+package transport
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"angrymiao-ai-server/src/core"
+)
+
+// ErrSessionNotFound 会话不存在
+var ErrSessionNotFound = errors.New("会话不存在")
+
+// ErrSessionExists 连接ID已登记
+var ErrSessionExists = errors.New("连接ID已登记")
+
+// SessionSummary 活跃会话摘要
+// ID 为服务端分配的唯一连接ID；SessionID 由客户端指定，可能重复
+type SessionSummary struct {
+	ID        string    `json:"id"`
+	SessionID string    `json:"session_id"`
+	DeviceID  string    `json:"device_id"`
+	UserID    string    `json:"user_id"`
+	StartTime time.Time `json:"start_time"`
+	TalkRound int       `json:"talk_round"`
+	Transport string    `json:"transport"`
+}
+
+// talkRoundGetter 可提供当前对话轮次的处理器
+type talkRoundGetter interface {
+	GetTalkRound() int
+}
+
+// messageWriter 可直接向客户端发送消息的处理器
+type messageWriter interface {
+	WriteMessage(messageType int, data []byte) error
+}
+
+// audioQualityGetter 可提供音频质量指标的处理器
+type audioQualityGetter interface {
+	AudioQuality() core.AudioQualitySnapshot
+}
+
+// audioCodecStatsGetter 可提供音频编解码统计的处理器
+type audioCodecStatsGetter interface {
+	AudioCodecStats() core.AudioCodecSnapshot
+}
+
+// sessionStateGetter 可提供会话状态快照的处理器
+type sessionStateGetter interface {
+	SessionState() core.SessionStateSnapshot
+}
+
+// audioLatencyGetter 可提供延迟归因统计的处理器
+type audioLatencyGetter interface {
+	AudioLatency() core.AudioLatencyReport
+}
+
+// ttsQueueDepthGetter 可提供TTS队列长度的处理器
+type ttsQueueDepthGetter interface {
+	TTSQueueDepth() int
+}
+
+// toolCallbackReceiver 可接收MCP工具回调结果的处理器
+type toolCallbackReceiver interface {
+	ResumeToolCallback(toolID string, result string) error
+}
+
+// proactiveSpeaker 可由服务端主动发起语音播报的处理器
+type proactiveSpeaker interface {
+	ProactiveSpeak(text string, voice string) (bool, error)
+}
+
+// failedTTSRetrier 可查询并重试失败TTS分段的处理器
+type failedTTSRetrier interface {
+	FailedTTS() []core.FailedTTSEntry
+	RetryFailedTTS(textIndex int) error
+}
+
+type sessionEntry struct {
+	summary SessionSummary
+	handler ConnectionHandler
+	done    chan struct{}
+}
+
+// SessionRegistry 记录所有传输层的活跃会话
+type SessionRegistry struct {
+	sessions sync.Map // 连接ID -> *sessionEntry
+}
+
+var defaultSessionRegistry = &SessionRegistry{}
+
+// GetSessionRegistry 获取默认 SessionRegistry（单例）
+func GetSessionRegistry() *SessionRegistry { return defaultSessionRegistry }
+
+// Register 按连接ID登记会话，连接处理结束时需调用 Unregister
+// 连接ID需由服务端生成且唯一，重复登记时返回 ErrSessionExists
+func (r *SessionRegistry) Register(summary SessionSummary, handler ConnectionHandler) error {
+	if summary.ID == "" {
+		return fmt.Errorf("连接ID为空")
+	}
+	if summary.StartTime.IsZero() {
+		summary.StartTime = time.Now()
+	}
+	entry := &sessionEntry{
+		summary: summary,
+		handler: handler,
+		done:    make(chan struct{}),
+	}
+	if _, loaded := r.sessions.LoadOrStore(summary.ID, entry); loaded {
+		return ErrSessionExists
+	}
+	return nil
+}
+
+// Unregister 移除会话并通知等待方会话已退出
+// 仅当登记的处理器与传入处理器一致时移除
+func (r *SessionRegistry) Unregister(id string, handler ConnectionHandler) {
+	v, ok := r.sessions.Load(id)
+	if !ok {
+		return
+	}
+	entry := v.(*sessionEntry)
+	if entry.handler != handler {
+		return
+	}
+	if r.sessions.CompareAndDelete(id, entry) {
+		close(entry.done)
+	}
+}
+
+// List 返回所有活跃会话，按开始时间排序
+func (r *SessionRegistry) List() []SessionSummary {
+	list := make([]SessionSummary, 0)
+	r.sessions.Range(func(key, value interface{}) bool {
+		entry := value.(*sessionEntry)
+		summary := entry.summary
+		if getter, ok := entry.handler.(talkRoundGetter); ok {
+			summary.TalkRound = getter.GetTalkRound()
+		}
+		list = append(list, summary)
+		return true
+	})
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].StartTime.Before(list[j].StartTime)
+	})
+	return list
+}
+
+// LoadStats 统计活跃连接数、不同会话ID数以及所有会话等待合成的TTS任务总数
+func (r *SessionRegistry) LoadStats() (connections, sessions, ttsQueueDepth int) {
+	sessionIDs := make(map[string]struct{})
+	r.sessions.Range(func(key, value interface{}) bool {
+		entry := value.(*sessionEntry)
+		connections++
+		sessionIDs[entry.summary.SessionID] = struct{}{}
+		if getter, ok := entry.handler.(ttsQueueDepthGetter); ok {
+			ttsQueueDepth += getter.TTSQueueDepth()
+		}
+		return true
+	})
+	return connections, len(sessionIDs), ttsQueueDepth
+}
+
+// find 按连接ID或客户端指定的会话ID查找会话，会话ID重复时返回最早登记的会话
+func (r *SessionRegistry) find(id string) *sessionEntry {
+	if v, ok := r.sessions.Load(id); ok {
+		return v.(*sessionEntry)
+	}
+	var found *sessionEntry
+	r.sessions.Range(func(key, value interface{}) bool {
+		entry := value.(*sessionEntry)
+		if entry.summary.SessionID == id && (found == nil || entry.summary.StartTime.Before(found.summary.StartTime)) {
+			found = entry
+		}
+		return true
+	})
+	return found
+}
+
+// AudioQuality 获取会话的音频质量指标，id 可以是连接ID或客户端指定的会话ID
+// 会话ID重复时返回最早登记的会话
+func (r *SessionRegistry) AudioQuality(id string) (core.AudioQualitySnapshot, error) {
+	found := r.find(id)
+	if found == nil {
+		return core.AudioQualitySnapshot{}, ErrSessionNotFound
+	}
+	getter, ok := found.handler.(audioQualityGetter)
+	if !ok {
+		return core.AudioQualitySnapshot{}, fmt.Errorf("会话不支持音频质量统计: %s", id)
+	}
+	return getter.AudioQuality(), nil
+}
+
+// AudioCodecStats 获取会话的Opus解码统计，id 可以是连接ID或客户端指定的会话ID
+func (r *SessionRegistry) AudioCodecStats(id string) (core.AudioCodecSnapshot, error) {
+	found := r.find(id)
+	if found == nil {
+		return core.AudioCodecSnapshot{}, ErrSessionNotFound
+	}
+	getter, ok := found.handler.(audioCodecStatsGetter)
+	if !ok {
+		return core.AudioCodecSnapshot{}, fmt.Errorf("会话不支持音频编解码统计: %s", id)
+	}
+	return getter.AudioCodecStats(), nil
+}
+
+// SessionState 获取会话的状态快照，id 可以是连接ID或客户端指定的会话ID
+func (r *SessionRegistry) SessionState(id string) (core.SessionStateSnapshot, error) {
+	found := r.find(id)
+	if found == nil {
+		return core.SessionStateSnapshot{}, ErrSessionNotFound
+	}
+	getter, ok := found.handler.(sessionStateGetter)
+	if !ok {
+		return core.SessionStateSnapshot{}, fmt.Errorf("会话不支持状态查询: %s", id)
+	}
+	return getter.SessionState(), nil
+}
+
+// AudioLatency 获取会话的延迟归因统计，id 可以是连接ID或客户端指定的会话ID
+func (r *SessionRegistry) AudioLatency(id string) (core.AudioLatencyReport, error) {
+	found := r.find(id)
+	if found == nil {
+		return core.AudioLatencyReport{}, ErrSessionNotFound
+	}
+	getter, ok := found.handler.(audioLatencyGetter)
+	if !ok {
+		return core.AudioLatencyReport{}, fmt.Errorf("会话不支持延迟归因统计: %s", id)
+	}
+	return getter.AudioLatency(), nil
+}
+
+// SendToDevice 向设备的所有活跃会话发送消息，返回发送成功的会话数
+// 全部发送失败时返回最后一个错误
+func (r *SessionRegistry) SendToDevice(deviceID string, messageType int, data []byte) (int, error) {
+	delivered := 0
+	var lastErr error
+	r.sessions.Range(func(key, value interface{}) bool {
+		entry := value.(*sessionEntry)
+		if entry.summary.DeviceID != deviceID {
+			return true
+		}
+		writer, ok := entry.handler.(messageWriter)
+		if !ok {
+			return true
+		}
+		if err := writer.WriteMessage(messageType, data); err != nil {
+			lastErr = err
+			return true
+		}
+		delivered++
+		return true
+	})
+	if delivered > 0 {
+		return delivered, nil
+	}
+	return 0, lastErr
+}
+
+// DeliverToolCallback 将MCP工具回调结果交给等待该工具ID的会话，id 可以是连接ID或客户端指定的会话ID
+// 会话不存在时返回 ErrSessionNotFound，会话未等待该工具ID时返回 core.ErrToolCallbackNotFound
+func (r *SessionRegistry) DeliverToolCallback(id string, toolID string, result string) error {
+	found := false
+	delivered := false
+	r.sessions.Range(func(key, value interface{}) bool {
+		entry := value.(*sessionEntry)
+		if key.(string) != id && entry.summary.SessionID != id {
+			return true
+		}
+		found = true
+		receiver, ok := entry.handler.(toolCallbackReceiver)
+		if !ok {
+			return true
+		}
+		if err := receiver.ResumeToolCallback(toolID, result); err == nil {
+			delivered = true
+			return false
+		}
+		return true
+	})
+	if delivered {
+		return nil
+	}
+	if !found {
+		return ErrSessionNotFound
+	}
+	return core.ErrToolCallbackNotFound
+}
+
+// ProactiveSpeak 服务端主动向会话播报文本，id 可以是连接ID或客户端指定的会话ID
+// 会话正在对话时返回 queued=true，待本轮结束后播报
+func (r *SessionRegistry) ProactiveSpeak(id string, text string, voice string) (bool, error) {
+	found := r.find(id)
+	if found == nil {
+		return false, ErrSessionNotFound
+	}
+	speaker, ok := found.handler.(proactiveSpeaker)
+	if !ok {
+		return false, fmt.Errorf("会话不支持主动播报: %s", id)
+	}
+	return speaker.ProactiveSpeak(text, voice)
+}
+
+// FailedTTS 返回会话最近合成失败的TTS分段，id 可以是连接ID或客户端指定的会话ID
+func (r *SessionRegistry) FailedTTS(id string) ([]core.FailedTTSEntry, error) {
+	found := r.find(id)
+	if found == nil {
+		return nil, ErrSessionNotFound
+	}
+	retrier, ok := found.handler.(failedTTSRetrier)
+	if !ok {
+		return nil, fmt.Errorf("会话不支持查询失败的TTS分段: %s", id)
+	}
+	return retrier.FailedTTS(), nil
+}
+
+// RetryFailedTTS 重新合成会话中指定索引的失败TTS分段并发送给客户端
+// 会话中没有该分段时返回 core.ErrFailedTTSNotFound
+func (r *SessionRegistry) RetryFailedTTS(id string, textIndex int) error {
+	found := r.find(id)
+	if found == nil {
+		return ErrSessionNotFound
+	}
+	retrier, ok := found.handler.(failedTTSRetrier)
+	if !ok {
+		return fmt.Errorf("会话不支持重试失败的TTS分段: %s", id)
+	}
+	return retrier.RetryFailedTTS(textIndex)
+}
+
+// Terminate 按连接ID强制关闭会话，并在超时时间内等待连接协程退出
+func (r *SessionRegistry) Terminate(id string, timeout time.Duration) error {
+	v, ok := r.sessions.Load(id)
+	if !ok {
+		return ErrSessionNotFound
+	}
+	entry := v.(*sessionEntry)
+	entry.handler.Close()
+
+	select {
+	case <-entry.done:
+		return nil
+	case <-time.After(timeout):
+		return fmt.Errorf("等待会话退出超时: %s", id)
+	}
+}
@@ -0,0 +1,90 @@
+package transport
+
+import (
+	"testing"
+	"time"
+)
+
+// mockHandler 模拟连接处理器，Close 后结束 Handle
+type mockHandler struct {
+	sessionID string
+	round     int
+	closed    chan struct{}
+}
+
+func newMockHandler(sessionID string) *mockHandler {
+	return &mockHandler{sessionID: sessionID, round: 3, closed: make(chan struct{})}
+}
+
+func (m *mockHandler) Handle()              { <-m.closed }
+func (m *mockHandler) Close()               { close(m.closed) }
+func (m *mockHandler) GetSessionID() string { return m.sessionID }
+func (m *mockHandler) GetTalkRound() int    { return m.round }
+
+func TestSessionRegistry(t *testing.T) {
+	registry := &SessionRegistry{}
+	handler := newMockHandler("s1")
+	if err := registry.Register(SessionSummary{ID: "c1", SessionID: "s1", DeviceID: "d1", UserID: "1", Transport: "websocket"}, handler); err != nil {
+		t.Fatalf("登记会话失败: %v", err)
+	}
+
+	// 模拟传输层的连接协程
+	go func() {
+		defer registry.Unregister("c1", handler)
+		handler.Handle()
+	}()
+
+	list := registry.List()
+	if len(list) != 1 {
+		t.Fatalf("会话数量 = %d, 期望 1", len(list))
+	}
+	if list[0].DeviceID != "d1" || list[0].TalkRound != 3 || list[0].StartTime.IsZero() {
+		t.Errorf("会话摘要不正确: %+v", list[0])
+	}
+
+	if err := registry.Terminate("c1", time.Second); err != nil {
+		t.Fatalf("强制关闭会话失败: %v", err)
+	}
+	if len(registry.List()) != 0 {
+		t.Errorf("关闭后会话仍在列表中")
+	}
+	if err := registry.Terminate("c1", time.Second); err != ErrSessionNotFound {
+		t.Errorf("关闭不存在的会话应返回 ErrSessionNotFound, 实际: %v", err)
+	}
+}
+
+func TestSessionRegistryDuplicateSessionID(t *testing.T) {
+	registry := &SessionRegistry{}
+	first := newMockHandler("s1")
+	second := newMockHandler("s1")
+	registry.Register(SessionSummary{ID: "c1", SessionID: "s1"}, first)
+	registry.Register(SessionSummary{ID: "c2", SessionID: "s1"}, second)
+
+	// 客户端指定相同 Session-Id 不应覆盖已有会话
+	if n := len(registry.List()); n != 2 {
+		t.Fatalf("会话数量 = %d, 期望 2", n)
+	}
+	if err := registry.Register(SessionSummary{ID: "c1", SessionID: "s2"}, second); err != ErrSessionExists {
+		t.Errorf("重复登记连接ID应返回 ErrSessionExists, 实际: %v", err)
+	}
+
+	// 旧连接退出只移除自己的登记
+	registry.Unregister("c1", first)
+	list := registry.List()
+	if len(list) != 1 || list[0].ID != "c2" {
+		t.Errorf("移除后剩余会话不正确: %+v", list)
+	}
+}
+
+func TestSessionRegistryUnregisterStaleHandler(t *testing.T) {
+	registry := &SessionRegistry{}
+	oldHandler := newMockHandler("s1")
+	newHandler := newMockHandler("s1")
+	registry.Register(SessionSummary{ID: "c1", SessionID: "s1"}, newHandler)
+
+	// 非登记处理器的退出不应移除会话
+	registry.Unregister("c1", oldHandler)
+	if len(registry.List()) != 1 {
+		t.Errorf("新会话被旧连接误删")
+	}
+}
@@ -6,6 +6,9 @@ import (
 	"sync/atomic"
 	"time"
 
+	"angrymiao-ai-server/src/core/transport"
+	"angrymiao-ai-server/src/core/utils"
+
 	"github.com/gorilla/websocket"
 )
 
@@ -16,6 +19,13 @@ type WebSocketConnection struct {
 	closed     int32
 	lastActive int64
 	mu         sync.Mutex
+
+	lastPong int64         // 最近一次收到 pong 的时间(UnixNano)
+	done     chan struct{} // 连接关闭时关闭，停止心跳协程
+
+	// 按设备限制上行消息频率，未设置时不限制
+	rateLimiter *transport.RateLimiter
+	deviceID    string
 }
 
 // NewWebSocketConnection 创建新的WebSocket连接适配器
@@ -25,6 +35,7 @@ func NewWebSocketConnection(id string, conn *websocket.Conn) *WebSocketConnectio
 		conn:       conn,
 		closed:     0,
 		lastActive: time.Now().Unix(),
+		done:       make(chan struct{}),
 	}
 }
 
@@ -41,18 +52,36 @@ func (c *WebSocketConnection) WriteMessage(messageType int, data []byte) error {
 	return c.conn.WriteMessage(messageType, data)
 }
 
-// ReadMessage 读取消息
+// SetRateLimiter 按设备ID限制上行消息频率，需在开始读取消息前调用
+func (c *WebSocketConnection) SetRateLimiter(limiter *transport.RateLimiter, deviceID string) {
+	c.rateLimiter = limiter
+	c.deviceID = deviceID
+}
+
+// ReadMessage 读取消息，超出设备限流的消息直接丢弃并通知客户端，pong、mcp、abort 等控制消息不限流
 func (c *WebSocketConnection) ReadMessage(stopChan <-chan struct{}) (int, []byte, error) {
-	messageType, data, err := c.conn.ReadMessage()
-	if err == nil {
+	for {
+		messageType, data, err := c.conn.ReadMessage()
+		if err != nil {
+			return messageType, data, err
+		}
 		atomic.StoreInt64(&c.lastActive, time.Now().Unix())
+		result := c.rateLimiter.Allow(c.deviceID, messageType, data)
+		if result.Allowed {
+			return messageType, data, nil
+		}
+		if result.Notify {
+			if err := c.WriteMessage(websocket.TextMessage, transport.RateLimitMessage(result.RetryAfter)); err != nil {
+				return 0, nil, err
+			}
+		}
 	}
-	return messageType, data, err
 }
 
 // Close 关闭连接
 func (c *WebSocketConnection) Close() error {
 	if atomic.CompareAndSwapInt32(&c.closed, 0, 1) {
+		close(c.done)
 		return c.conn.Close()
 	}
 	return nil
@@ -82,3 +111,48 @@ func (c *WebSocketConnection) GetLastActiveTime() time.Time {
 func (c *WebSocketConnection) IsStale(timeout time.Duration) bool {
 	return time.Since(c.GetLastActiveTime()) > timeout
 }
+
+// StartHeartbeat 每隔 interval 发送一次 ping，发送后 timeout 内未收到 pong 时关闭连接
+// pong 只能在读取消息时处理，启动后需持续调用 ReadMessage
+func (c *WebSocketConnection) StartHeartbeat(interval, timeout time.Duration, logger *utils.Logger) {
+	c.conn.SetPongHandler(func(string) error {
+		now := time.Now()
+		atomic.StoreInt64(&c.lastPong, now.UnixNano())
+		atomic.StoreInt64(&c.lastActive, now.Unix())
+		return c.conn.SetReadDeadline(now.Add(interval + timeout))
+	})
+	_ = c.conn.SetReadDeadline(time.Now().Add(interval + timeout))
+	go c.heartbeat(interval, timeout, logger)
+}
+
+func (c *WebSocketConnection) heartbeat(interval, timeout time.Duration, logger *utils.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+		}
+
+		sentAt := time.Now()
+		// WriteControl 可与其他写操作并发调用
+		if err := c.conn.WriteControl(websocket.PingMessage, nil, sentAt.Add(timeout)); err != nil {
+			if c.IsClosed() {
+				return
+			}
+			logger.Warn("WebSocket发送ping失败: %s, %v", c.id, err)
+		}
+
+		select {
+		case <-c.done:
+			return
+		case <-time.After(timeout):
+		}
+		if atomic.LoadInt64(&c.lastPong) < sentAt.UnixNano() {
+			logger.Warn("WebSocket客户端 %s 在 %v 内未响应ping，关闭连接", c.id, timeout)
+			c.Close()
+			return
+		}
+	}
+}
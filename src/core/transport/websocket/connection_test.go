@@ -0,0 +1,165 @@
+package websocket
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"angrymiao-ai-server/src/core/transport"
+	"angrymiao-ai-server/src/core/utils"
+
+	"github.com/gorilla/websocket"
+)
+
+// newHeartbeatPair 建立一对WebSocket连接，返回服务端连接适配器与客户端连接
+// dropPongs 为 true 时客户端收到 ping 后不回复 pong
+func newHeartbeatPair(t *testing.T, dropPongs bool) (*WebSocketConnection, *websocket.Conn) {
+	t.Helper()
+	serverConns := make(chan *websocket.Conn, 1)
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("升级失败: %v", err)
+			return
+		}
+		serverConns <- conn
+	}))
+	t.Cleanup(srv.Close)
+
+	client, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("连接失败: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	if dropPongs {
+		client.SetPingHandler(func(string) error { return nil })
+	}
+	// 客户端持续读取才能处理 ping
+	go func() {
+		for {
+			if _, _, err := client.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	conn := NewWebSocketConnection("test", <-serverConns)
+	t.Cleanup(func() { conn.Close() })
+	go func() {
+		for {
+			if _, _, err := conn.ReadMessage(nil); err != nil {
+				return
+			}
+		}
+	}()
+	return conn, client
+}
+
+func TestHeartbeatClosesConnectionWithoutPong(t *testing.T) {
+	logger, err := utils.NewLogger(&utils.LogCfg{LogLevel: "ERROR", LogDir: t.TempDir(), LogFile: "test.log"})
+	if err != nil {
+		t.Fatalf("创建日志失败: %v", err)
+	}
+
+	// 客户端正常回复 pong 时连接保持，且更新活跃时间
+	alive, _ := newHeartbeatPair(t, false)
+	atomic.StoreInt64(&alive.lastActive, 0)
+	alive.StartHeartbeat(50*time.Millisecond, 50*time.Millisecond, logger)
+
+	dropped, _ := newHeartbeatPair(t, true)
+	start := time.Now()
+	dropped.StartHeartbeat(50*time.Millisecond, 50*time.Millisecond, logger)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !dropped.IsClosed() && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if !dropped.IsClosed() {
+		t.Fatal("未回复 pong 的连接未被关闭")
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("连接在 %v 后关闭，早于 ping 间隔加超时", elapsed)
+	}
+
+	time.Sleep(200 * time.Millisecond)
+	if alive.IsClosed() {
+		t.Error("正常回复 pong 的连接被关闭")
+	}
+	if alive.GetLastActiveTime().Unix() == 0 {
+		t.Errorf("收到 pong 后未更新活跃时间: %v", alive.GetLastActiveTime())
+	}
+}
+
+func TestReadMessageDropsRateLimitedMessages(t *testing.T) {
+	serverConns := make(chan *websocket.Conn, 1)
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("升级失败: %v", err)
+			return
+		}
+		serverConns <- conn
+	}))
+	defer srv.Close()
+	client, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("连接失败: %v", err)
+	}
+	defer client.Close()
+
+	conn := NewWebSocketConnection("test", <-serverConns)
+	defer conn.Close()
+	conn.SetRateLimiter(transport.NewRateLimiter(3, 0), "dev1")
+	received := make(chan string, 20)
+	go func() {
+		for {
+			_, data, err := conn.ReadMessage(nil)
+			if err != nil {
+				return
+			}
+			received <- string(data)
+		}
+	}()
+
+	// 突发10条文本消息，不限制的音频帧全部放行
+	for i := 0; i < 10; i++ {
+		client.WriteMessage(websocket.TextMessage, []byte(`{"type":"chat"}`))
+	}
+	for i := 0; i < 5; i++ {
+		client.WriteMessage(websocket.BinaryMessage, []byte{0x01})
+	}
+
+	client.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, notice, err := client.ReadMessage()
+	if err != nil || !strings.Contains(string(notice), `"type":"rate_limit"`) || !strings.Contains(string(notice), `"retry_after_ms"`) {
+		t.Fatalf("客户端收到 %s, %v, 期望 rate_limit 消息", notice, err)
+	}
+
+	texts, frames := 0, 0
+	timeout := time.After(time.Second)
+	for texts+frames < 8 {
+		select {
+		case data := <-received:
+			if data == "\x01" {
+				frames++
+			} else {
+				texts++
+			}
+		case <-timeout:
+			t.Fatalf("只收到 %d 条文本与 %d 个音频帧", texts, frames)
+		}
+	}
+	select {
+	case data := <-received:
+		t.Errorf("超出限流的消息未被丢弃: %q", data)
+	case <-time.After(100 * time.Millisecond):
+	}
+	if texts != 3 || frames != 5 {
+		t.Errorf("放行 %d 条文本与 %d 个音频帧, 期望 3 与 5", texts, frames)
+	}
+}
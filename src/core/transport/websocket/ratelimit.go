@@ -0,0 +1,75 @@
+package websocket
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// 连接被拒绝的原因
+const (
+	rejectRateLimit   = "rate_limit"
+	rejectAuthFail    = "auth_fail"
+	rejectUpgradeFail = "upgrade_fail"
+	rejectCapacity    = "capacity"
+)
+
+var rejectedConnections = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "websocket_rejected_connections_total",
+	Help: "被拒绝的WebSocket连接数",
+}, []string{"reason"})
+
+// RegisterMetrics 将WebSocket连接指标注册到 Prometheus
+func RegisterMetrics(registerer prometheus.Registerer) error {
+	return registerer.Register(rejectedConnections)
+}
+
+// recordRejected 记录一次被拒绝的连接
+func recordRejected(reason string) {
+	rejectedConnections.WithLabelValues(reason).Inc()
+}
+
+// clientIP 获取客户端IP，X-Forwarded-For 只信任第一个地址，否则使用 RemoteAddr
+func clientIP(r *http.Request) string {
+	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+		first, _, _ := strings.Cut(forwarded, ",")
+		if ip := strings.TrimSpace(first); ip != "" {
+			return ip
+		}
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+// allowConnection 按客户端IP限流，超出限制时返回429并带 Retry-After
+func (t *WebSocketTransport) allowConnection(w http.ResponseWriter, r *http.Request) bool {
+	if t.ipLimiter == nil {
+		return true
+	}
+	ip := clientIP(r)
+	result := t.ipLimiter.Allow(ip)
+	if result.Allowed {
+		return true
+	}
+	t.logger.Warn("WebSocket连接过于频繁: ip=%s, device-id: %s", ip, r.Header.Get("Device-Id"))
+	recordRejected(rejectRateLimit)
+	w.Header().Set("Retry-After", strconv.Itoa(result.RetryAfterSeconds()))
+	http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+	return false
+}
+
+// acquireConnection 占用连接总数名额，已达上限时返回503
+func (t *WebSocketTransport) acquireConnection(w http.ResponseWriter, r *http.Request) bool {
+	if t.governor.Acquire() {
+		return true
+	}
+	t.logger.Warn("连接数已达上限(%d)，拒绝WebSocket连接: device-id: %s", t.governor.MaxConnections(), r.Header.Get("Device-Id"))
+	recordRejected(rejectCapacity)
+	http.Error(w, "Service Unavailable: too many connections", http.StatusServiceUnavailable)
+	return false
+}
@@ -6,16 +6,20 @@ import (
 	"net/http"
 	"strings"
 	"sync"
+	"time"
 
 	"angrymiao-ai-server/src/configs"
 	"angrymiao-ai-server/src/core/auth"
 	"angrymiao-ai-server/src/core/auth/am_token"
 	"angrymiao-ai-server/src/core/botconfig"
+	"angrymiao-ai-server/src/core/governor"
+	"angrymiao-ai-server/src/core/ratelimit"
 	"angrymiao-ai-server/src/core/transport"
 	"angrymiao-ai-server/src/core/utils"
 	"angrymiao-ai-server/src/httpsvr/device"
 
 	"github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 // WebSocketTransport WebSocket传输层实现
@@ -28,11 +32,14 @@ type WebSocketTransport struct {
 	upgrader          *websocket.Upgrader
 	authToken         *auth.AuthToken // JWT认证工具
 	userConfigService botconfig.Service
+	ipLimiter         *ratelimit.IPRateLimiter // 按客户端IP限制连接频率，未配置时为nil
+	governor          *governor.Governor       // 所有传输层共用的连接总数限制
+	rateLimiter       *transport.RateLimiter   // 所有传输层共用的设备上行消息限流
 }
 
 // NewWebSocketTransport 创建WebSocket传输层
 func NewWebSocketTransport(config *configs.Config, logger *utils.Logger, userConfigService botconfig.Service) *WebSocketTransport {
-	return &WebSocketTransport{
+	t := &WebSocketTransport{
 		config: config,
 		logger: logger,
 		upgrader: &websocket.Upgrader{
@@ -42,12 +49,21 @@ func NewWebSocketTransport(config *configs.Config, logger *utils.Logger, userCon
 		},
 		authToken:         auth.NewAuthToken(config.Server.Token), // 初始化JWT认证工具
 		userConfigService: userConfigService,
+		governor:          governor.Default(),
+		rateLimiter:       transport.DefaultRateLimiter(),
 	}
+	if config.WSConnectRatePerIP > 0 {
+		t.ipLimiter = ratelimit.NewIPRateLimiter(config.WSConnectRatePerIP)
+	}
+	return t
 }
 
 // Start 启动WebSocket传输层
 func (t *WebSocketTransport) Start(ctx context.Context) error {
 	addr := fmt.Sprintf("%s:%d", t.config.Transport.WebSocket.IP, t.config.Transport.WebSocket.Port)
+	if err := RegisterMetrics(prometheus.DefaultRegisterer); err != nil {
+		t.logger.Warn("注册WebSocket连接指标失败: %v", err)
+	}
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("/", t.handleWebSocket)
@@ -88,6 +104,9 @@ func (t *WebSocketTransport) Stop() error {
 			return true
 		})
 
+		if t.ipLimiter != nil {
+			t.ipLimiter.Close()
+		}
 		return t.server.Close()
 	}
 	return nil
@@ -108,11 +127,29 @@ func (t *WebSocketTransport) GetActiveConnectionCount() int {
 	return count
 }
 
+// startHeartbeat 按配置为连接启动 ping/pong 心跳，间隔为0时不启动
+func (t *WebSocketTransport) startHeartbeat(conn *WebSocketConnection) {
+	if t.config.WSPingIntervalSeconds <= 0 {
+		return
+	}
+	timeout := t.config.WSPingTimeoutSeconds
+	if timeout <= 0 {
+		timeout = defaultPingTimeoutSeconds
+	}
+	conn.StartHeartbeat(time.Duration(t.config.WSPingIntervalSeconds)*time.Second, time.Duration(timeout)*time.Second, t.logger)
+}
+
 // GetType 获取传输类型
 func (t *WebSocketTransport) GetType() string {
 	return "websocket"
 }
 
+// defaultPingTimeoutSeconds 未配置 ws_ping_timeout_seconds 时等待 pong 的秒数
+const defaultPingTimeoutSeconds = 10
+
+// appTransportType App 端 WebSocket 连接在会话列表中的传输类型
+const appTransportType = "websocket-app"
+
 // verifyJWTAuth 验证JWT认证并返回用户ID
 func (t *WebSocketTransport) verifyJWTAuth(r *http.Request) (uint, error) {
 	// 获取Authorization头
@@ -162,6 +199,10 @@ func (t *WebSocketTransport) verifyAMJWTAuth(r *http.Request) (uint, error) {
 
 // handleWebSocket 处理WebSocket连接
 func (t *WebSocketTransport) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	if !t.allowConnection(w, r) {
+		return
+	}
+
 	// 从URL参数中获取header信息（用于支持WebSocket连接时传递自定义header）
 	if t.config.Transport.WebSocket.Browser {
 		query := r.URL.Query()
@@ -190,6 +231,7 @@ func (t *WebSocketTransport) handleWebSocket(w http.ResponseWriter, r *http.Requ
 	userID, err := t.verifyJWTAuth(r)
 	if err != nil {
 		t.logger.Warn("WebSocket认证失败: %v device-id: %s", err, r.Header.Get("Device-Id"))
+		recordRejected(rejectAuthFail)
 		http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
 		return
 	}
@@ -197,15 +239,21 @@ func (t *WebSocketTransport) handleWebSocket(w http.ResponseWriter, r *http.Requ
 	// 认证成功后，直接在连接处理器上绑定用户ID
 	t.logger.Info("WebSocket认证成功: device-id=%s, user-id=%d", r.Header.Get("Device-Id"), userID)
 
+	if !t.acquireConnection(w, r) {
+		return
+	}
 	conn, err := t.upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		t.logger.Error("WebSocket升级失败: %v", err)
+		recordRejected(rejectUpgradeFail)
+		t.governor.Release()
 		return
 	}
 
 	clientID := fmt.Sprintf("%p", conn)
 	t.logger.Info("收到WebSocket连接请求: %s", r.Header.Get("Device-Id"))
 	wsConn := NewWebSocketConnection(clientID, conn)
+	t.startHeartbeat(wsConn)
 
 	// 若请求未提供 Session-Id，则使用 clientID 作为会话ID
 	sessionID := r.Header.Get("Session-Id")
@@ -214,10 +262,12 @@ func (t *WebSocketTransport) handleWebSocket(w http.ResponseWriter, r *http.Requ
 		r.Header.Set("Session-Id", sessionID)
 	}
 	deviceID := r.Header.Get("Device-Id")
+	wsConn.SetRateLimiter(t.rateLimiter, deviceID)
 
 	if t.connHandler == nil {
 		t.logger.Error("连接处理器工厂未设置")
 		conn.Close()
+		t.governor.Release()
 		return
 	}
 
@@ -225,6 +275,7 @@ func (t *WebSocketTransport) handleWebSocket(w http.ResponseWriter, r *http.Requ
 	if handler == nil {
 		t.logger.Error("创建连接处理器失败")
 		conn.Close()
+		t.governor.Release()
 		return
 	}
 	// 绑定用户ID到具体的 ConnectionHandler
@@ -240,6 +291,15 @@ func (t *WebSocketTransport) handleWebSocket(w http.ResponseWriter, r *http.Requ
 
 	// 标记会话在线
 	device.GetPresenceManager().SetSessionOnline(deviceID, sessionID)
+	if err := transport.GetSessionRegistry().Register(transport.SessionSummary{
+		ID:        clientID,
+		SessionID: sessionID,
+		DeviceID:  deviceID,
+		UserID:    fmt.Sprintf("%d", userID),
+		Transport: t.GetType(),
+	}, handler); err != nil {
+		t.logger.Warn("登记会话失败: %s, %v", clientID, err)
+	}
 
 	// 启动连接处理，并在结束时清理资源
 	go func() {
@@ -247,8 +307,10 @@ func (t *WebSocketTransport) handleWebSocket(w http.ResponseWriter, r *http.Requ
 			// 连接结束时清理
 			t.activeConnections.Delete(clientID)
 			handler.Close()
+			transport.GetSessionRegistry().Unregister(clientID, handler)
 			// 标记会话离线
 			device.GetPresenceManager().SetSessionOffline(deviceID, sessionID)
+			t.governor.Release()
 		}()
 
 		handler.Handle()
@@ -257,6 +319,10 @@ func (t *WebSocketTransport) handleWebSocket(w http.ResponseWriter, r *http.Requ
 
 // handleAppWebSocket 处理 App 专用 WebSocket 连接（使用 AM Token 认证）
 func (t *WebSocketTransport) handleAppWebSocket(w http.ResponseWriter, r *http.Request) {
+	if !t.allowConnection(w, r) {
+		return
+	}
+
 	// 支持从 query 注入 header
 	if t.config.Transport.WebSocket.Browser {
 		query := r.URL.Query()
@@ -285,14 +351,20 @@ func (t *WebSocketTransport) handleAppWebSocket(w http.ResponseWriter, r *http.R
 	userID, err := t.verifyAMJWTAuth(r)
 	if err != nil {
 		t.logger.Warn("[APP] WebSocket认证失败: %v", err)
+		recordRejected(rejectAuthFail)
 		http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
 		return
 	}
 
 	// 升级 WebSocket
+	if !t.acquireConnection(w, r) {
+		return
+	}
 	conn, err := t.upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		t.logger.Error("[APP] WebSocket升级失败: %v", err)
+		recordRejected(rejectUpgradeFail)
+		t.governor.Release()
 		return
 	}
 
@@ -304,10 +376,12 @@ func (t *WebSocketTransport) handleAppWebSocket(w http.ResponseWriter, r *http.R
 	}
 
 	wsConn := NewWebSocketConnection(clientID, conn)
+	t.startHeartbeat(wsConn)
 
 	if t.connHandler == nil {
 		t.logger.Error("[APP] 连接处理器工厂未设置")
 		conn.Close()
+		t.governor.Release()
 		return
 	}
 
@@ -316,6 +390,7 @@ func (t *WebSocketTransport) handleAppWebSocket(w http.ResponseWriter, r *http.R
 	if handler == nil {
 		t.logger.Error("[APP] 创建连接处理器失败")
 		conn.Close()
+		t.governor.Release()
 		return
 	}
 	if adapter, ok := handler.(*transport.ConnectionContextAdapter); ok {
@@ -336,13 +411,24 @@ func (t *WebSocketTransport) handleAppWebSocket(w http.ResponseWriter, r *http.R
 		r.Header.Set("Session-Id", sessionID)
 	}
 	device.GetPresenceManager().SetSessionOnline(deviceID, sessionID)
+	if err := transport.GetSessionRegistry().Register(transport.SessionSummary{
+		ID:        clientID,
+		SessionID: sessionID,
+		DeviceID:  deviceID,
+		UserID:    fmt.Sprintf("%d", userID),
+		Transport: appTransportType,
+	}, handler); err != nil {
+		t.logger.Warn("登记会话失败: %s, %v", clientID, err)
+	}
 
 	// 启动连接处理，并在结束时清理资源
 	go func() {
 		defer func() {
 			t.activeConnections.Delete(clientID)
 			handler.Close()
+			transport.GetSessionRegistry().Unregister(clientID, handler)
 			device.GetPresenceManager().SetSessionOffline(deviceID, sessionID)
+			t.governor.Release()
 		}()
 		handler.Handle()
 	}()
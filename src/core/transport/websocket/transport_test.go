@@ -0,0 +1,85 @@
+package websocket
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"angrymiao-ai-server/src/configs"
+	"angrymiao-ai-server/src/core/utils"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestHandleWebSocketIPRateLimit(t *testing.T) {
+	logger, err := utils.NewLogger(&utils.LogCfg{LogLevel: "ERROR", LogDir: t.TempDir(), LogFile: "test.log"})
+	if err != nil {
+		t.Fatalf("创建日志失败: %v", err)
+	}
+	config := &configs.Config{WSConnectRatePerIP: 10}
+	transport := NewWebSocketTransport(config, logger, nil)
+	defer transport.ipLimiter.Close()
+
+	srv := httptest.NewServer(http.HandlerFunc(transport.handleWebSocket))
+	defer srv.Close()
+
+	rateLimitedBefore := testutil.ToFloat64(rejectedConnections.WithLabelValues(rejectRateLimit))
+	authFailBefore := testutil.ToFloat64(rejectedConnections.WithLabelValues(rejectAuthFail))
+
+	// 未携带token的请求在限流之内返回401，超出后返回429
+	var unauthorized, limited int
+	for i := 0; i < 15; i++ {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+		req.Header.Set("X-Forwarded-For", "203.0.113.7, 10.0.0.1")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("第%d次请求失败: %v", i+1, err)
+		}
+		resp.Body.Close()
+		switch resp.StatusCode {
+		case http.StatusUnauthorized:
+			unauthorized++
+		case http.StatusTooManyRequests:
+			limited++
+			if resp.Header.Get("Retry-After") == "" {
+				t.Error("429 响应缺少 Retry-After")
+			}
+		default:
+			t.Errorf("第%d次请求状态码 = %d", i+1, resp.StatusCode)
+		}
+	}
+	if unauthorized != 10 || limited != 5 {
+		t.Errorf("401 次数 = %d, 429 次数 = %d, 期望 10 和 5", unauthorized, limited)
+	}
+
+	// 其他IP不受影响
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	req.Header.Set("X-Forwarded-For", "198.51.100.1")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("其他IP状态码 = %d, 期望 401", resp.StatusCode)
+	}
+
+	if got := testutil.ToFloat64(rejectedConnections.WithLabelValues(rejectRateLimit)) - rateLimitedBefore; got != 5 {
+		t.Errorf("rate_limit 计数增加 %v, 期望 5", got)
+	}
+	if got := testutil.ToFloat64(rejectedConnections.WithLabelValues(rejectAuthFail)) - authFailBefore; got != 11 {
+		t.Errorf("auth_fail 计数增加 %v, 期望 11", got)
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "192.0.2.1:5555"
+	if got := clientIP(req); got != "192.0.2.1" {
+		t.Errorf("RemoteAddr 解析 = %q", got)
+	}
+	req.Header.Set("X-Forwarded-For", " 203.0.113.9 , 192.0.2.1")
+	if got := clientIP(req); got != "203.0.113.9" {
+		t.Errorf("X-Forwarded-For 解析 = %q", got)
+	}
+}
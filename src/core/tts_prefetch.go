@@ -0,0 +1,108 @@
+package core
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// prefetchItem 预取中的TTS任务
+type prefetchItem struct {
+	text         string
+	textIndex    int
+	round        int
+	paragraphEnd bool
+	filepath     string
+	cancelled    atomic.Bool
+	done         chan struct{}
+}
+
+// PreFetchBuffer TTS预取缓冲区
+// 当前分段等待合成或发送时，提前合成队列中后续分段，并按入队顺序输出结果
+// 同时进行的合成数不超过 capacity
+type PreFetchBuffer struct {
+	synth func(text string, textIndex int, round int) string
+	items chan *prefetchItem
+	slots chan struct{} // 限制并发合成数
+
+	mu      sync.Mutex
+	pending map[*prefetchItem]struct{}
+}
+
+// NewPreFetchBuffer 创建TTS预取缓冲区，synth 负责合成文本并返回音频文件路径
+func NewPreFetchBuffer(capacity int, synth func(text string, textIndex int, round int) string) *PreFetchBuffer {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &PreFetchBuffer{
+		synth:   synth,
+		items:   make(chan *prefetchItem, capacity),
+		slots:   make(chan struct{}, capacity),
+		pending: make(map[*prefetchItem]struct{}),
+	}
+}
+
+// Push 加入一个TTS任务，有空闲合成名额时开始合成；缓冲区已满时阻塞，stop 关闭时返回false
+func (b *PreFetchBuffer) Push(text string, textIndex int, round int, paragraphEnd bool, stop <-chan struct{}) bool {
+	item := &prefetchItem{
+		text:         text,
+		textIndex:    textIndex,
+		round:        round,
+		paragraphEnd: paragraphEnd,
+		done:         make(chan struct{}),
+	}
+	select {
+	case b.items <- item:
+	case <-stop:
+		return false
+	}
+
+	b.mu.Lock()
+	b.pending[item] = struct{}{}
+	b.mu.Unlock()
+
+	go func() {
+		defer close(item.done)
+		select {
+		case b.slots <- struct{}{}:
+		case <-stop:
+			return
+		}
+		defer func() { <-b.slots }()
+		// 等待名额期间被取消的任务不再合成
+		if item.cancelled.Load() {
+			return
+		}
+		item.filepath = b.synth(text, textIndex, round)
+	}()
+	return true
+}
+
+// Next 按入队顺序等待下一个任务合成完成，stop 关闭时返回false
+func (b *PreFetchBuffer) Next(stop <-chan struct{}) (*prefetchItem, bool) {
+	var item *prefetchItem
+	select {
+	case item = <-b.items:
+	case <-stop:
+		return nil, false
+	}
+	select {
+	case <-item.done:
+	case <-stop:
+		return nil, false
+	}
+
+	b.mu.Lock()
+	delete(b.pending, item)
+	b.mu.Unlock()
+	return item, true
+}
+
+// Cancel 取消所有尚未输出的预取任务，合成完成后由调用方删除其音频文件
+func (b *PreFetchBuffer) Cancel() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for item := range b.pending {
+		item.cancelled.Store(true)
+	}
+	return len(b.pending)
+}
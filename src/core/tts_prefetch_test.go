@@ -0,0 +1,109 @@
+package core
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// gatedMockTTS 模拟耗时的TTS提供者，合成在 release 关闭后才完成，并记录最大并发数
+type gatedMockTTS struct {
+	mu        sync.Mutex
+	active    int
+	maxActive int
+	started   chan int
+	release   chan struct{}
+}
+
+func newGatedMockTTS() *gatedMockTTS {
+	return &gatedMockTTS{started: make(chan int, 16), release: make(chan struct{})}
+}
+
+func (m *gatedMockTTS) synth(text string, textIndex int, round int) string {
+	m.mu.Lock()
+	m.active++
+	if m.active > m.maxActive {
+		m.maxActive = m.active
+	}
+	m.mu.Unlock()
+
+	m.started <- textIndex
+	<-m.release
+
+	m.mu.Lock()
+	m.active--
+	m.mu.Unlock()
+	return fmt.Sprintf("/tmp/mock_tts_%d.wav", textIndex)
+}
+
+func waitStarted(t *testing.T, m *gatedMockTTS, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		select {
+		case <-m.started:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("等待第%d个合成开始超时", i+1)
+		}
+	}
+}
+
+func TestPreFetchBufferOverlapsSynthesis(t *testing.T) {
+	mock := newGatedMockTTS()
+	buffer := NewPreFetchBuffer(2, mock.synth)
+	stop := make(chan struct{})
+	defer close(stop)
+
+	go func() {
+		for i := 1; i <= 5; i++ {
+			buffer.Push("分段", i, 1, false, stop)
+		}
+	}()
+
+	// 第一段未完成前，第二段已经开始合成
+	waitStarted(t, mock, 2)
+	close(mock.release)
+
+	for i := 1; i <= 5; i++ {
+		item, ok := buffer.Next(stop)
+		if !ok {
+			t.Fatalf("获取第%d个预取结果失败", i)
+		}
+		if expected := fmt.Sprintf("/tmp/mock_tts_%d.wav", i); item.filepath != expected {
+			t.Errorf("第%d个结果 = %s, 期望 %s", i, item.filepath, expected)
+		}
+	}
+
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	if mock.maxActive != 2 {
+		t.Errorf("最大并发合成数 = %d, 期望 2", mock.maxActive)
+	}
+}
+
+func TestPreFetchBufferCancel(t *testing.T) {
+	mock := newGatedMockTTS()
+	buffer := NewPreFetchBuffer(2, mock.synth)
+	stop := make(chan struct{})
+	defer close(stop)
+
+	buffer.Push("第一段", 1, 1, false, stop)
+	buffer.Push("第二段", 2, 1, false, stop)
+	waitStarted(t, mock, 2)
+	if n := buffer.Cancel(); n != 2 {
+		t.Errorf("取消数量 = %d, 期望 2", n)
+	}
+	// 缓冲区已满，新一轮的分段在前面的结果输出后才能加入
+	go buffer.Push("第三段", 3, 2, false, stop)
+	close(mock.release)
+
+	for i := 1; i <= 3; i++ {
+		item, ok := buffer.Next(stop)
+		if !ok {
+			t.Fatalf("获取第%d个预取结果失败", i)
+		}
+		if cancelled := item.cancelled.Load(); cancelled != (i < 3) {
+			t.Errorf("第%d个结果取消状态 = %v", i, cancelled)
+		}
+	}
+}
@@ -21,6 +21,7 @@ type BotConfig struct {
 	APIKey string `json:"api_key,omitempty"`
 
 	// Bot 级别配置（来自 bot_configs）
+	BotType     string  `json:"bot_type,omitempty"`    // llm/text/image/tts/asr
 	MaxTokens   int     `json:"max_tokens,omitempty"`  // 最大token数
 	Temperature float32 `json:"temperature,omitempty"` // 温度参数
 
@@ -30,10 +31,22 @@ type BotConfig struct {
 	Parameters   datatypes.JSON `json:"parameters,omitempty"`     // JSON格式的参数定义
 	MCPServerURL string         `json:"mcp_server_url,omitempty"` // MCP服务器URL
 
+	// 结构化输出配置（来自 bot_configs），非空时LLM回复需符合该JSON Schema
+	ResponseSchema datatypes.JSON `json:"response_schema,omitempty"`
+
+	// 回复前缀（来自 bot_configs），非空时覆盖全局 response_prefix
+	ResponsePrefix string `json:"response_prefix,omitempty"`
+
+	// 欢迎语（来自 bot_configs），非空时覆盖全局 conversation_starter
+	WelcomeMessage string `json:"welcome_message,omitempty"`
+
 	// 用户好友配置（来自 user_friends）
 	IsActive bool `json:"is_active"` // 是否启用
 	Priority int  `json:"priority"`  // 优先级，数字越大优先级越高
 
+	// Bot回复使用的TTS音色（来自 user_friends.tts_voice），为空时使用默认音色
+	TTSVoice string `json:"tts_voice,omitempty"`
+
 	// 元数据
 	BotHash   string    `json:"bot_hash,omitempty"` // Bot哈希值
 	CreatedAt time.Time `json:"created_at"`
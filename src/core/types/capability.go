@@ -0,0 +1,9 @@
+package types
+
+// 提供者能力标识，通过 hello 消息的 server_capabilities 告知客户端
+const (
+	CapabilityStreaming = "supports_streaming" // 流式输出（LLM、TTS）或流式识别（ASR）
+	CapabilityTools     = "supports_tools"     // 工具调用
+	CapabilityVision    = "supports_vision"    // 图片输入
+	CapabilitySSML      = "supports_ssml"      // SSML 标记
+)
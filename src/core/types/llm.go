@@ -39,14 +39,34 @@ const (
 	ActionTypeResponse    Action = 2
 	ActionTypeReqLLM      Action = 3
 	ActionTypeCallHandler Action = 4
+	// 将对话转交给其他Bot，Result 为 HandoffRequest
+	ActionTypeHandoff Action = 5
+	// 结束转交，恢复发起转交的对话
+	ActionTypeHandoffReturn Action = 6
 )
 
 var ActionDesc = map[Action]string{
-	ActionTypeError:    "错误",
-	ActionTypeNotFound: "没有找到函数",
-	ActionTypeNone:     "啥也不干",
-	ActionTypeResponse: "直接回复",
-	ActionTypeReqLLM:   "调用函数后再请求llm生成回复",
+	ActionTypeError:         "错误",
+	ActionTypeNotFound:      "没有找到函数",
+	ActionTypeNone:          "啥也不干",
+	ActionTypeResponse:      "直接回复",
+	ActionTypeReqLLM:        "调用函数后再请求llm生成回复",
+	ActionTypeHandoff:       "转交给其他Bot继续对话",
+	ActionTypeHandoffReturn: "结束转交，返回原对话",
+}
+
+// 工具返回以下 type 的JSON时触发对话转交
+const (
+	HandoffTypeStart  = "handoff"
+	HandoffTypeReturn = "handoff_return"
+)
+
+// HandoffRequest 工具返回的对话转交指令
+// 例如 {"type":"handoff","target_bot":"translator","context":"..."} 或 {"type":"handoff_return"}
+type HandoffRequest struct {
+	Type      string `json:"type"`
+	TargetBot string `json:"target_bot,omitempty"` // 目标Bot的函数名
+	Context   string `json:"context,omitempty"`    // 交给目标Bot的背景信息
 }
 
 // ActionResponse holds the result of an action.
@@ -67,8 +87,10 @@ type Message struct {
 	Content    string     `json:"content"`
 	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
 	ToolCallID string     `json:"tool_call_id,omitempty"`
-	BotID      uint       `json:"bot_id"`   // 自定义的字段和open ai接口无关
-	BotName    string     `json:"bot_name"` // 自定义的字段和open ai接口无关
+	BotID      uint       `json:"bot_id"`         // 自定义的字段和open ai接口无关
+	BotName    string     `json:"bot_name"`       // 自定义的字段和open ai接口无关
+	ID         uint       `json:"id,omitempty"`   // 数据库消息ID，自定义的字段和open ai接口无关
+	Tags       []string   `json:"tags,omitempty"` // 消息标签，自定义的字段和open ai接口无关
 }
 
 func (m *Message) Print() {
@@ -125,8 +147,11 @@ type FunctionRegistryInterface interface {
 	GetFunction(name string) (openai.Tool, error)
 	GetAllFunctions() []openai.Tool
 	UnregisterFunction(name string) error
+	ReplaceFunction(name string, function openai.Tool) error
 	UnregisterAllFunctions() error
 	FunctionExists(name string) bool
+	RegisteredFunctions() []string
+	ListFunctions() []string
 }
 
 // LLMProvider 大语言模型提供者接口
@@ -141,4 +166,5 @@ type LLMProvider interface {
 	) (<-chan Response, error)
 	GetSessionID() string                       // 获取当前会话ID
 	SetIdentityFlag(idType string, flag string) // 设置身份标识
+	Capabilities() map[string]bool              // 支持的能力，键为 Capability* 常量
 }
@@ -94,6 +94,87 @@ func (d *OpusDecoder) Close() error {
 	return nil
 }
 
+// OpusEncoder 封装opus编码器，会话内复用以保持编码状态连续
+type OpusEncoder struct {
+	encoder    *opus.OpusEncoder
+	mu         sync.Mutex
+	sampleRate int
+	channels   int
+	outBuffer  []byte
+}
+
+// NewOpusEncoder 创建新的opus编码器，bitrate 为0时使用编码库默认码率
+func NewOpusEncoder(sampleRate, channels, bitrate int) (*OpusEncoder, error) {
+	encoder, err := opus.CreateOpusEncoder(&opus.OpusEncoderConfig{
+		SampleRate:  sampleRate,
+		MaxChannels: channels,
+		Application: opus.AppVoIP,
+		Bitrate:     bitrate,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("创建Opus编码器失败: %v", err)
+	}
+	return &OpusEncoder{
+		encoder:    encoder,
+		sampleRate: sampleRate,
+		channels:   channels,
+		outBuffer:  make([]byte, 4000), // opus 单包最大长度
+	}, nil
+}
+
+// Encode 将一帧PCM数据编码为一个opus数据包，帧长需为2.5/5/10/20/40/60ms
+func (e *OpusEncoder) Encode(pcmFrame []byte) ([]byte, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.encoder == nil {
+		return nil, fmt.Errorf("Opus编码器已关闭")
+	}
+	n, err := e.encoder.Encode(pcmFrame, e.outBuffer)
+	if err != nil {
+		return nil, fmt.Errorf("Opus编码失败: %v", err)
+	}
+	result := make([]byte, n)
+	copy(result, e.outBuffer[:n])
+	return result, nil
+}
+
+// EncodePCM 将PCM数据按 frameDurationMs 分帧逐帧编码，最后一帧不足时补静音
+func (e *OpusEncoder) EncodePCM(pcmData []byte, frameDurationMs int) ([][]byte, error) {
+	frameBytes := e.sampleRate * 2 * e.channels * frameDurationMs / 1000
+	if frameBytes <= 0 {
+		return nil, fmt.Errorf("无效的帧长: %dms", frameDurationMs)
+	}
+	if len(pcmData) == 0 {
+		return nil, nil
+	}
+	packets := make([][]byte, 0, (len(pcmData)+frameBytes-1)/frameBytes)
+	for _, frame := range chunkPCMBytes(pcmData, frameBytes) {
+		packet, err := e.Encode(frame)
+		if err != nil {
+			return nil, err
+		}
+		if len(packet) > 0 {
+			packets = append(packets, packet)
+		}
+	}
+	return packets, nil
+}
+
+// Close 关闭编码器
+func (e *OpusEncoder) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.encoder != nil {
+		if err := e.encoder.Close(); err != nil {
+			return fmt.Errorf("关闭Opus编码器失败: %v", err)
+		}
+		e.encoder = nil
+	}
+	return nil
+}
+
 func MP3ToPCMData(audioFile string) ([][]byte, error) {
 	file, err := os.Open(audioFile)
 	if err != nil {
@@ -488,6 +569,40 @@ func AudioToOpusData(audioFile string) ([][]byte, float64, error) {
 	return opusData, duration, nil
 }
 
+// 段间静音时长（毫秒），按句尾标点区分
+const (
+	SilenceAfterCommaMs     = 50
+	SilenceAfterPeriodMs    = 150
+	SilenceAfterParagraphMs = 300
+)
+
+// InterSegmentSilenceMs 根据文本结尾标点返回段间静音时长，无匹配标点时返回defaultMs
+// paragraphEnd 表示分段位于段落末尾（分段前的原文以换行结尾）
+func InterSegmentSilenceMs(text string, paragraphEnd bool, defaultMs int) int {
+	if paragraphEnd {
+		return SilenceAfterParagraphMs
+	}
+	trimmed := []rune(strings.TrimSpace(text))
+	if len(trimmed) == 0 {
+		return defaultMs
+	}
+	switch trimmed[len(trimmed)-1] {
+	case '，', ',', '、', '；', ';', '：', ':':
+		return SilenceAfterCommaMs
+	case '。', '.', '！', '!', '？', '?', '…':
+		return SilenceAfterPeriodMs
+	}
+	return defaultMs
+}
+
+// GenerateSilencePCM 生成指定时长的16位单声道PCM静音数据
+func GenerateSilencePCM(silenceMs int, sampleRate int) []byte {
+	if silenceMs <= 0 || sampleRate <= 0 {
+		return nil
+	}
+	return make([]byte, silenceMs*sampleRate/1000*2)
+}
+
 // CopyAudioFile 复制音频文件
 func CopyAudioFile(src, dst string) error {
 	source, err := os.Open(src)
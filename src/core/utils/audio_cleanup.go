@@ -0,0 +1,135 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// audioCleanupInterval 孤立音频文件扫描间隔
+const audioCleanupInterval = time.Hour
+
+// ActiveAudioFiles 仍在会话音频队列中等待发送的TTS音频文件，清理时跳过
+var ActiveAudioFiles sync.Map // 绝对路径 -> struct{}
+
+var (
+	audioCleanupDeletedFiles = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "audio_cleanup_deleted_files_total",
+		Help: "后台清理删除的孤立TTS音频文件数",
+	})
+	audioCleanupReclaimedBytes = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "audio_cleanup_reclaimed_bytes_total",
+		Help: "后台清理回收的磁盘空间(字节)",
+	})
+)
+
+// RegisterAudioCleanupMetrics 将音频清理指标注册到 Prometheus
+func RegisterAudioCleanupMetrics(registerer prometheus.Registerer) error {
+	for _, collector := range []prometheus.Collector{audioCleanupDeletedFiles, audioCleanupReclaimedBytes} {
+		if err := registerer.Register(collector); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MarkAudioFileActive 标记音频文件正在使用
+func MarkAudioFileActive(path string) {
+	if path != "" {
+		ActiveAudioFiles.Store(activeAudioFileKey(path), struct{}{})
+	}
+}
+
+// ReleaseAudioFile 音频文件不再被会话使用
+func ReleaseAudioFile(path string) {
+	if path != "" {
+		ActiveAudioFiles.Delete(activeAudioFileKey(path))
+	}
+}
+
+// isAudioFileActive 判断音频文件是否仍在使用
+func isAudioFileActive(path string) bool {
+	_, ok := ActiveAudioFiles.Load(activeAudioFileKey(path))
+	return ok
+}
+
+// activeAudioFileKey 统一为绝对路径，避免相对路径与扫描得到的路径不一致
+func activeAudioFileKey(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return filepath.Clean(path)
+}
+
+// StartAudioCleanup 每小时清理 dir 下超过 maxAgeHours 小时且不在使用中的音频文件
+// maxAgeHours 小于等于0时不启动清理；只扫描 dir 本层，快速回复缓存等子目录不受影响
+func StartAudioCleanup(dir string, maxAgeHours int, logger *Logger) {
+	if dir == "" || maxAgeHours <= 0 {
+		return
+	}
+	maxAge := time.Duration(maxAgeHours) * time.Hour
+	logger.Info("启动孤立音频文件清理，目录: %s, 最长保留: %d小时", dir, maxAgeHours)
+
+	go func() {
+		ticker := time.NewTicker(audioCleanupInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			files, bytes, err := cleanupAudioFiles(dir, maxAge, time.Now(), logger)
+			if err != nil {
+				logger.Warn("清理孤立音频文件失败: %v", err)
+				continue
+			}
+			if files > 0 {
+				logger.Info("清理孤立音频文件 %d 个，回收 %d 字节，目录: %s", files, bytes, dir)
+			}
+		}
+	}()
+}
+
+// cleanupAudioFiles 删除修改时间早于 now-maxAge 且不在使用中的音频文件，返回删除数量与回收字节数
+func cleanupAudioFiles(dir string, maxAge time.Duration, now time.Time, logger *Logger) (int, int64, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	cutoff := now.Add(-maxAge)
+	var files int
+	var bytes int64
+	for _, entry := range entries {
+		if entry.IsDir() || !isAudioFileName(entry.Name()) {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		if isAudioFileActive(path) || IsMusicFile(path) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || !info.ModTime().Before(cutoff) {
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			logger.Warn("删除孤立音频文件失败: %v", err)
+			continue
+		}
+		files++
+		bytes += info.Size()
+	}
+
+	audioCleanupDeletedFiles.Add(float64(files))
+	audioCleanupReclaimedBytes.Add(float64(bytes))
+	return files, bytes, nil
+}
+
+// isAudioFileName 判断是否为TTS生成的音频文件
+func isAudioFileName(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".wav", ".mp3", ".opus", ".pcm":
+		return true
+	}
+	return false
+}
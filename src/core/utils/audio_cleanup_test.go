@@ -0,0 +1,84 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCleanupAudioFiles(t *testing.T) {
+	dir := t.TempDir()
+	logger, err := NewLogger(&LogCfg{LogLevel: "ERROR", LogDir: t.TempDir(), LogFile: "test.log"})
+	if err != nil {
+		t.Fatalf("创建日志失败: %v", err)
+	}
+
+	now := time.Now()
+	old := now.Add(-25 * time.Hour)
+	writeFile := func(name string, size int, modTime time.Time) string {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, make([]byte, size), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	expired1 := writeFile("expired1.mp3", 100, old)
+	expired2 := writeFile("expired2.wav", 50, old)
+	active := writeFile("active.opus", 10, old)
+	recent := writeFile("recent.mp3", 10, now.Add(-time.Hour))
+	other := writeFile("notes.txt", 10, old)
+	cached := writeFile(filepath.Join("wake_replay", "cached.mp3"), 10, old)
+
+	// 相对路径标记同样生效
+	wd, _ := os.Getwd()
+	rel, err := filepath.Rel(wd, active)
+	if err != nil {
+		t.Fatal(err)
+	}
+	MarkAudioFileActive(rel)
+	defer ReleaseAudioFile(active)
+
+	filesBefore := testutil.ToFloat64(audioCleanupDeletedFiles)
+	bytesBefore := testutil.ToFloat64(audioCleanupReclaimedBytes)
+
+	files, bytes, err := cleanupAudioFiles(dir, 24*time.Hour, now, logger)
+	if err != nil {
+		t.Fatalf("清理失败: %v", err)
+	}
+	if files != 2 || bytes != 150 {
+		t.Errorf("删除 %d 个文件 %d 字节, 期望 2 个 150 字节", files, bytes)
+	}
+	for _, path := range []string{expired1, expired2} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("过期文件未删除: %s", path)
+		}
+	}
+	for _, path := range []string{active, recent, other, cached} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("文件不应被删除: %s", path)
+		}
+	}
+
+	if got := testutil.ToFloat64(audioCleanupDeletedFiles) - filesBefore; got != 2 {
+		t.Errorf("删除文件计数增加 %v, 期望 2", got)
+	}
+	if got := testutil.ToFloat64(audioCleanupReclaimedBytes) - bytesBefore; got != 150 {
+		t.Errorf("回收字节计数增加 %v, 期望 150", got)
+	}
+
+	// 释放后再次清理会删除
+	ReleaseAudioFile(active)
+	if files, _, _ := cleanupAudioFiles(dir, 24*time.Hour, now, logger); files != 1 {
+		t.Errorf("释放后删除 %d 个文件, 期望 1", files)
+	}
+}
@@ -0,0 +1,154 @@
+package utils
+
+import (
+	"encoding/binary"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestInterSegmentSilence(t *testing.T) {
+	tests := []struct {
+		name         string
+		text         string
+		paragraphEnd bool
+		wantMs       int
+		wantBytes    int
+	}{
+		{name: "中文逗号", text: "你好，", wantMs: 50, wantBytes: 1600},
+		{name: "英文逗号", text: "Hello,", wantMs: 50, wantBytes: 1600},
+		{name: "中文句号", text: "今天天气不错。", wantMs: 150, wantBytes: 4800},
+		{name: "英文问号", text: "How are you? ", wantMs: 150, wantBytes: 4800},
+		{name: "换段", text: "第一段结束。", paragraphEnd: true, wantMs: 300, wantBytes: 9600},
+		{name: "无标点", text: "没有标点", wantMs: 100, wantBytes: 3200},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ms := InterSegmentSilenceMs(tt.text, tt.paragraphEnd, 100)
+			if ms != tt.wantMs {
+				t.Errorf("InterSegmentSilenceMs(%q) = %d, 期望 %d", tt.text, ms, tt.wantMs)
+			}
+			pcm := GenerateSilencePCM(ms, 16000)
+			if len(pcm) != tt.wantBytes {
+				t.Errorf("GenerateSilencePCM(%d, 16000) 长度 = %d, 期望 %d", ms, len(pcm), tt.wantBytes)
+			}
+			for i, b := range pcm {
+				if b != 0 {
+					t.Fatalf("静音数据第%d字节不为0", i)
+				}
+			}
+		})
+	}
+
+	if pcm := GenerateSilencePCM(0, 16000); pcm != nil {
+		t.Errorf("静音时长为0时应返回nil, 实际长度 %d", len(pcm))
+	}
+}
+
+// 按LLM回复的实际分段流程（分段后去除首尾空白）验证换段静音
+func TestInterSegmentSilenceFromSegmentation(t *testing.T) {
+	segment, pos := SplitAtLastPunctuation("第一段结束\n第二段")
+	if pos == 0 {
+		t.Fatalf("换行处未分段")
+	}
+	paragraphEnd := EndsWithParagraphBreak(segment)
+	if !paragraphEnd {
+		t.Fatalf("分段 %q 应以换行结尾", segment)
+	}
+	if ms := InterSegmentSilenceMs(strings.TrimSpace(segment), paragraphEnd, 100); ms != SilenceAfterParagraphMs {
+		t.Errorf("换段静音 = %d, 期望 %d", ms, SilenceAfterParagraphMs)
+	}
+
+	segment, _ = SplitAtLastPunctuation("今天天气不错。明天")
+	paragraphEnd = EndsWithParagraphBreak(segment)
+	if ms := InterSegmentSilenceMs(strings.TrimSpace(segment), paragraphEnd, 100); ms != SilenceAfterPeriodMs {
+		t.Errorf("句号静音 = %d, 期望 %d", ms, SilenceAfterPeriodMs)
+	}
+}
+
+// sinePCM 生成16位单声道正弦波PCM
+func sinePCM(sampleRate int, freq float64, durationMs int) []byte {
+	samples := sampleRate * durationMs / 1000
+	pcm := make([]byte, samples*2)
+	for i := 0; i < samples; i++ {
+		v := int16(8000 * math.Sin(2*math.Pi*freq*float64(i)/float64(sampleRate)))
+		binary.LittleEndian.PutUint16(pcm[i*2:], uint16(v))
+	}
+	return pcm
+}
+
+func pcmSamples(pcm []byte) []float64 {
+	out := make([]float64, len(pcm)/2)
+	for i := range out {
+		out[i] = float64(int16(binary.LittleEndian.Uint16(pcm[i*2:])))
+	}
+	return out
+}
+
+// bestSNR 在编码延迟范围内对齐后计算信噪比(dB)
+func bestSNR(original, decoded []float64, maxDelay int) float64 {
+	best := math.Inf(-1)
+	for delay := 0; delay <= maxDelay; delay++ {
+		var signal, noise float64
+		for i := 0; i+delay < len(decoded) && i < len(original); i++ {
+			diff := original[i] - decoded[i+delay]
+			signal += original[i] * original[i]
+			noise += diff * diff
+		}
+		if noise == 0 {
+			return math.Inf(1)
+		}
+		if snr := 10 * math.Log10(signal/noise); snr > best {
+			best = snr
+		}
+	}
+	return best
+}
+
+func TestOpusEncoderRoundTrip(t *testing.T) {
+	const sampleRate = 16000
+	encoder, err := NewOpusEncoder(sampleRate, 1, 32000)
+	if err != nil {
+		t.Fatalf("创建编码器失败: %v", err)
+	}
+	defer encoder.Close()
+	decoder, err := NewOpusDecoder(&OpusDecoderConfig{SampleRate: sampleRate, MaxChannels: 1})
+	if err != nil {
+		t.Fatalf("创建解码器失败: %v", err)
+	}
+	defer decoder.Close()
+
+	original := sinePCM(sampleRate, 440, 1000)
+	packets, err := encoder.EncodePCM(original, 20)
+	if err != nil {
+		t.Fatalf("编码失败: %v", err)
+	}
+	if len(packets) != 50 {
+		t.Fatalf("1秒音频按20ms分帧应得到50个数据包, got %d", len(packets))
+	}
+
+	var decoded []byte
+	for _, packet := range packets {
+		pcm, err := decoder.Decode(packet)
+		if err != nil {
+			t.Fatalf("解码失败: %v", err)
+		}
+		decoded = append(decoded, pcm...)
+	}
+	if len(decoded) == 0 {
+		t.Skip("当前链接的Opus库不输出解码数据")
+	}
+
+	// 跳过编码器启动阶段，对齐编码延迟后比较
+	warmup := sampleRate / 10
+	snr := bestSNR(pcmSamples(original)[warmup:], pcmSamples(decoded)[warmup:], sampleRate/50)
+	if snr < 10 {
+		t.Errorf("编解码后信噪比 %.1fdB 低于 10dB", snr)
+	}
+
+	encoder.Close()
+	if _, err := encoder.Encode(original[:640]); err == nil {
+		t.Error("关闭后编码应返回错误")
+	}
+}
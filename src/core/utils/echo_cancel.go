@@ -0,0 +1,186 @@
+package utils
+
+import (
+	"encoding/binary"
+	"math"
+	"math/cmplx"
+	"sync"
+)
+
+const (
+	// EchoFrameMs 回声消除处理的帧长
+	EchoFrameMs = 20
+	// echoOverSubtraction 谱减的过减系数，略大于1以抵消参考信号与回声的幅度差异
+	echoOverSubtraction = 1.2
+	// echoSpectralFloor 谱减后保留的最小幅度比例，避免出现音乐噪声
+	echoSpectralFloor = 0.05
+	// echoMaxReferenceSeconds 最多缓存的参考信号时长
+	echoMaxReferenceSeconds = 30
+)
+
+// EchoCanceller 基于谱减法的简单回声消除
+// 以服务端正在播放的TTS音频作为参考信号，每处理一帧客户端音频消耗等长的参考信号，
+// 在频域中从客户端音频的幅度谱中减去参考信号的幅度谱，保留客户端音频的相位
+type EchoCanceller struct {
+	mu         sync.Mutex
+	sampleRate int
+	frameSize  int     // 每帧采样数
+	reference  []int16 // 尚未与客户端音频对齐消耗的参考信号
+}
+
+// NewEchoCanceller 创建回声消除器，sampleRate 为客户端音频采样率（16位单声道PCM）
+func NewEchoCanceller(sampleRate int) *EchoCanceller {
+	if sampleRate <= 0 {
+		sampleRate = 16000
+	}
+	return &EchoCanceller{sampleRate: sampleRate, frameSize: sampleRate * EchoFrameMs / 1000}
+}
+
+// AddReference 追加服务端播放的16位单声道PCM作为参考信号，采样率不同时重采样到客户端采样率
+func (c *EchoCanceller) AddReference(pcm []byte, sampleRate int) {
+	samples := bytesToInt16(pcm)
+	if sampleRate > 0 {
+		samples = resamplePCM(samples, sampleRate, c.sampleRate)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.reference = append(c.reference, samples...)
+	if max := c.sampleRate * echoMaxReferenceSeconds; len(c.reference) > max {
+		c.reference = c.reference[len(c.reference)-max:]
+	}
+}
+
+// Reset 清空参考信号，服务端停止播放时调用
+func (c *EchoCanceller) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.reference = nil
+}
+
+// HasReference 是否还有待消耗的参考信号
+func (c *EchoCanceller) HasReference() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.reference) > 0
+}
+
+// Process 对16位单声道PCM按20ms分帧做回声消除，没有参考信号时原样返回
+func (c *EchoCanceller) Process(pcm []byte) []byte {
+	samples := bytesToInt16(pcm)
+	c.mu.Lock()
+	if len(c.reference) == 0 || len(samples) == 0 {
+		c.mu.Unlock()
+		return pcm
+	}
+	n := len(samples)
+	if n > len(c.reference) {
+		n = len(c.reference)
+	}
+	reference := c.reference[:n]
+	c.reference = c.reference[n:]
+	c.mu.Unlock()
+
+	output := make([]int16, len(samples))
+	copy(output, samples)
+	for start := 0; start < n; start += c.frameSize {
+		end := start + c.frameSize
+		if end > n {
+			end = n
+		}
+		copy(output[start:end], spectralSubtract(samples[start:end], reference[start:end]))
+	}
+
+	result := make([]byte, len(pcm))
+	copy(result, pcm) // 保留末尾不足一个采样的字节
+	for i, s := range output {
+		binary.LittleEndian.PutUint16(result[i*2:], uint16(s))
+	}
+	return result
+}
+
+// spectralSubtract 从一帧信号的幅度谱中减去参考信号的幅度谱
+func spectralSubtract(frame, reference []int16) []int16 {
+	size := 1
+	for size < len(frame) {
+		size <<= 1
+	}
+	x := make([]complex128, size)
+	r := make([]complex128, size)
+	for i := range frame {
+		x[i] = complex(float64(frame[i]), 0)
+		r[i] = complex(float64(reference[i]), 0)
+	}
+	fft(x, false)
+	fft(r, false)
+
+	for i := range x {
+		magnitude := cmplx.Abs(x[i])
+		if magnitude == 0 {
+			continue
+		}
+		cleaned := magnitude - echoOverSubtraction*cmplx.Abs(r[i])
+		if floor := magnitude * echoSpectralFloor; cleaned < floor {
+			cleaned = floor
+		}
+		x[i] *= complex(cleaned/magnitude, 0)
+	}
+	fft(x, true)
+
+	out := make([]int16, len(frame))
+	for i := range out {
+		v := math.Round(real(x[i]))
+		if v > math.MaxInt16 {
+			v = math.MaxInt16
+		} else if v < math.MinInt16 {
+			v = math.MinInt16
+		}
+		out[i] = int16(v)
+	}
+	return out
+}
+
+// fft 原地计算长度为2的幂的快速傅里叶变换（迭代Cooley-Tukey），inverse 为true时计算逆变换并归一化
+func fft(a []complex128, inverse bool) {
+	n := len(a)
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j ^= bit
+		if i < j {
+			a[i], a[j] = a[j], a[i]
+		}
+	}
+	for length := 2; length <= n; length <<= 1 {
+		angle := 2 * math.Pi / float64(length)
+		if !inverse {
+			angle = -angle
+		}
+		wlen := cmplx.Rect(1, angle)
+		for i := 0; i < n; i += length {
+			w := complex(1, 0)
+			for k := 0; k < length/2; k++ {
+				u := a[i+k]
+				v := a[i+k+length/2] * w
+				a[i+k] = u + v
+				a[i+k+length/2] = u - v
+				w *= wlen
+			}
+		}
+	}
+	if inverse {
+		for i := range a {
+			a[i] /= complex(float64(n), 0)
+		}
+	}
+}
+
+// bytesToInt16 将16位小端PCM字节转换为采样，末尾不足一个采样的字节忽略
+func bytesToInt16(pcm []byte) []int16 {
+	samples := make([]int16, len(pcm)/2)
+	for i := range samples {
+		samples[i] = int16(binary.LittleEndian.Uint16(pcm[i*2:]))
+	}
+	return samples
+}
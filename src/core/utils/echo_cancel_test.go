@@ -0,0 +1,88 @@
+package utils
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+// sineSamples 生成指定频率与幅度的16位单声道PCM
+func sineSamples(freq float64, amplitude float64, sampleRate int, samples int) []int16 {
+	out := make([]int16, samples)
+	for i := range out {
+		out[i] = int16(amplitude * math.Sin(2*math.Pi*freq*float64(i)/float64(sampleRate)))
+	}
+	return out
+}
+
+func int16ToBytes(samples []int16) []byte {
+	out := make([]byte, len(samples)*2)
+	for i, s := range samples {
+		binary.LittleEndian.PutUint16(out[i*2:], uint16(s))
+	}
+	return out
+}
+
+// residualRMS 计算两段信号差值的RMS
+func residualRMS(a, b []int16) float64 {
+	var sum float64
+	for i := range a {
+		d := float64(a[i]) - float64(b[i])
+		sum += d * d
+	}
+	return math.Sqrt(sum / float64(len(a)))
+}
+
+func TestFFTRoundTrip(t *testing.T) {
+	input := []complex128{1, 2, 3, 4, 0, -1, -2, -3}
+	data := append([]complex128(nil), input...)
+	fft(data, false)
+	if math.Abs(real(data[0])-4) > 1e-9 {
+		t.Errorf("直流分量 = %v, 期望 4", data[0])
+	}
+	fft(data, true)
+	for i := range input {
+		if math.Abs(real(data[i])-real(input[i])) > 1e-9 || math.Abs(imag(data[i])) > 1e-9 {
+			t.Fatalf("逆变换 = %v, 期望 %v", data, input)
+		}
+	}
+}
+
+func TestEchoCancellerReducesReference(t *testing.T) {
+	const sampleRate = 16000
+	samples := sampleRate * EchoFrameMs / 1000 * 5 // 5帧
+	echo := sineSamples(500, 8000, sampleRate, samples)
+	speech := sineSamples(2000, 2000, sampleRate, samples)
+	mixed := make([]int16, samples)
+	for i := range mixed {
+		mixed[i] = echo[i] + speech[i]
+	}
+
+	canceller := NewEchoCanceller(sampleRate)
+	canceller.AddReference(int16ToBytes(echo), sampleRate)
+	cleaned := bytesToInt16(canceller.Process(int16ToBytes(mixed)))
+
+	before := residualRMS(mixed, speech)
+	after := residualRMS(cleaned, speech)
+	if after > before*0.3 {
+		t.Errorf("回声残留RMS = %.1f, 消除前 %.1f, 期望至少降低70%%", after, before)
+	}
+	if canceller.HasReference() {
+		t.Error("参考信号应与处理的音频等长消耗")
+	}
+}
+
+func TestEchoCancellerPassThroughWithoutReference(t *testing.T) {
+	frame := int16ToBytes(sineSamples(300, 5000, 16000, 320))
+	canceller := NewEchoCanceller(16000)
+
+	if out := canceller.Process(frame); string(out) != string(frame) {
+		t.Error("没有参考信号时应原样返回")
+	}
+
+	canceller.AddReference(frame, 16000)
+	canceller.Reset()
+	if out := canceller.Process(frame); string(out) != string(frame) {
+		t.Error("重置后应原样返回")
+	}
+}
@@ -25,6 +25,9 @@ var EmotionEmoji = map[string]string{
 	"sleepy":      "😴",
 	"silly":       "🤪",
 	"confused":    "😕",
+	"positive":    "😊",
+	"negative":    "😞",
+	"excited":     "🤩",
 }
 
 // GetEmotionEmoji 根据情绪返回对应的表情
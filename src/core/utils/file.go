@@ -233,15 +233,25 @@ func GetAudioDuration(data []byte, suffix string) (float64, error) {
 	}
 }
 
-// parseWAVDuration 解析WAV音频时长
-func parseWAVDuration(data []byte) (float64, error) {
+// wavInfo WAV格式信息及data chunk位置
+type wavInfo struct {
+	sampleRate    int
+	byteRate      int
+	channels      int
+	bitsPerSample int
+	dataOffset    int // data chunk数据起始偏移
+	dataSize      int // data chunk声明的数据长度
+}
+
+// parseWAVInfo 解析WAV头，定位fmt与data chunk
+func parseWAVInfo(data []byte) (*wavInfo, error) {
 	if len(data) < 44 {
-		return 0, fmt.Errorf("WAV数据不完整")
+		return nil, fmt.Errorf("WAV数据不完整")
 	}
 
 	// 检查RIFF头
 	if string(data[0:4]) != "RIFF" || string(data[8:12]) != "WAVE" {
-		return 0, fmt.Errorf("不是有效的WAV文件")
+		return nil, fmt.Errorf("不是有效的WAV文件")
 	}
 
 	// 查找fmt chunk
@@ -251,10 +261,16 @@ func parseWAVDuration(data []byte) (float64, error) {
 		chunkSize := int(data[offset+4]) | int(data[offset+5])<<8 | int(data[offset+6])<<16 | int(data[offset+7])<<24
 
 		if chunkID == "fmt " && offset+8+chunkSize <= len(data) {
-			// 读取采样率（偏移24-27）
-			sampleRate := int(data[offset+12]) | int(data[offset+13])<<8 | int(data[offset+14])<<16 | int(data[offset+15])<<24
-			// 读取字节率（偏移28-31）
-			byteRate := int(data[offset+16]) | int(data[offset+17])<<8 | int(data[offset+18])<<16 | int(data[offset+19])<<24
+			info := &wavInfo{
+				// 声道数（偏移22-23）
+				channels: int(data[offset+10]) | int(data[offset+11])<<8,
+				// 读取采样率（偏移24-27）
+				sampleRate: int(data[offset+12]) | int(data[offset+13])<<8 | int(data[offset+14])<<16 | int(data[offset+15])<<24,
+				// 读取字节率（偏移28-31）
+				byteRate: int(data[offset+16]) | int(data[offset+17])<<8 | int(data[offset+18])<<16 | int(data[offset+19])<<24,
+				// 采样位数（偏移34-35）
+				bitsPerSample: int(data[offset+22]) | int(data[offset+23])<<8,
+			}
 
 			// 查找data chunk
 			dataOffset := offset + 8 + chunkSize
@@ -263,18 +279,9 @@ func parseWAVDuration(data []byte) (float64, error) {
 				dataChunkSize := int(data[dataOffset+4]) | int(data[dataOffset+5])<<8 | int(data[dataOffset+6])<<16 | int(data[dataOffset+7])<<24
 
 				if dataChunkID == "data" {
-					if byteRate > 0 {
-						duration := float64(dataChunkSize) / float64(byteRate)
-						return duration, nil
-					}
-					if sampleRate > 0 {
-						// 备用计算方法
-						bitsPerSample := int(data[offset+22]) | int(data[offset+23])<<8
-						channels := int(data[offset+10]) | int(data[offset+11])<<8
-						duration := float64(dataChunkSize) / float64(sampleRate*channels*bitsPerSample/8)
-						return duration, nil
-					}
-					return 0, fmt.Errorf("无法计算WAV时长")
+					info.dataOffset = dataOffset + 8
+					info.dataSize = dataChunkSize
+					return info, nil
 				}
 				dataOffset += 8 + dataChunkSize
 			}
@@ -282,7 +289,34 @@ func parseWAVDuration(data []byte) (float64, error) {
 		offset += 8 + chunkSize
 	}
 
-	return 0, fmt.Errorf("未找到WAV格式信息")
+	return nil, fmt.Errorf("未找到WAV格式信息")
+}
+
+// GetWAVSampleRate 读取WAV音频的采样率
+func GetWAVSampleRate(data []byte) (int, error) {
+	info, err := parseWAVInfo(data)
+	if err != nil {
+		return 0, err
+	}
+	return info.sampleRate, nil
+}
+
+// parseWAVDuration 解析WAV音频时长
+func parseWAVDuration(data []byte) (float64, error) {
+	info, err := parseWAVInfo(data)
+	if err != nil {
+		return 0, err
+	}
+	if info.byteRate > 0 {
+		duration := float64(info.dataSize) / float64(info.byteRate)
+		return duration, nil
+	}
+	if info.sampleRate > 0 && info.channels > 0 && info.bitsPerSample > 0 {
+		// 备用计算方法
+		duration := float64(info.dataSize) / float64(info.sampleRate*info.channels*info.bitsPerSample/8)
+		return duration, nil
+	}
+	return 0, fmt.Errorf("无法计算WAV时长")
 }
 
 // parseMP3Duration 解析MP3音频时长（简化版本，基于文件大小和比特率估算）
@@ -0,0 +1,44 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// CompileJSONSchema 编译JSON Schema，用于校验配置中的Schema是否有效
+func CompileJSONSchema(schema []byte) (*jsonschema.Schema, error) {
+	compiler := jsonschema.NewCompiler()
+	if err := compiler.AddResource("response_schema.json", bytes.NewReader(schema)); err != nil {
+		return nil, fmt.Errorf("解析JSON Schema失败: %v", err)
+	}
+	compiled, err := compiler.Compile("response_schema.json")
+	if err != nil {
+		return nil, fmt.Errorf("编译JSON Schema失败: %v", err)
+	}
+	return compiled, nil
+}
+
+// ValidateJSON 校验文本是否为符合Schema的JSON，返回去除Markdown代码块后的JSON文本
+func ValidateJSON(schema *jsonschema.Schema, text string) (string, error) {
+	text = strings.TrimSpace(text)
+	// LLM常用```json代码块包裹输出
+	if strings.HasPrefix(text, "```") {
+		text = strings.TrimPrefix(text, "```json")
+		text = strings.TrimPrefix(text, "```")
+		text = strings.TrimSuffix(strings.TrimSpace(text), "```")
+		text = strings.TrimSpace(text)
+	}
+
+	var value interface{}
+	if err := json.Unmarshal([]byte(text), &value); err != nil {
+		return "", fmt.Errorf("不是有效的JSON: %v", err)
+	}
+	if err := schema.Validate(value); err != nil {
+		return "", fmt.Errorf("JSON不符合Schema: %v", err)
+	}
+	return text, nil
+}
@@ -76,6 +76,9 @@ type Logger struct {
 	mu          sync.RWMutex  // 读写锁保护
 	ticker      *time.Ticker  // 定时器
 	stopCh      chan struct{} // 停止信号
+
+	root  *Logger     // WithField 创建的子记录器共享根记录器的输出与轮转，根记录器为nil
+	attrs []slog.Attr // 子记录器附加到每条日志的字段
 }
 
 // configLogLevelToSlogLevel 将配置中的日志级别转换为slog.Level
@@ -265,8 +268,32 @@ func (l *Logger) cleanOldLogs() {
 	}
 }
 
-// Close 关闭日志文件
+// WithField 返回附带指定字段的子记录器，之后通过子记录器输出的每条日志都包含该字段
+func (l *Logger) WithField(key, value string) *Logger {
+	attrs := make([]slog.Attr, 0, len(l.attrs)+1)
+	attrs = append(attrs, l.attrs...)
+	attrs = append(attrs, slog.String(key, value))
+	return &Logger{
+		config: l.config,
+		root:   l.base(),
+		attrs:  attrs,
+	}
+}
+
+// base 返回持有日志输出的根记录器
+func (l *Logger) base() *Logger {
+	if l.root != nil {
+		return l.root
+	}
+	return l
+}
+
+// Close 关闭日志文件，子记录器不持有日志文件，关闭时不做任何操作
 func (l *Logger) Close() error {
+	if l.root != nil {
+		return nil
+	}
+
 	// 停止定时器
 	if l.ticker != nil {
 		l.ticker.Stop()
@@ -285,11 +312,12 @@ func (l *Logger) Close() error {
 // log 通用日志记录函数（内部使用）
 func (l *Logger) log(level slog.Level, msg string, fields ...interface{}) {
 	// 使用读锁保护并发访问
-	l.mu.RLock()
-	defer l.mu.RUnlock()
+	root := l.base()
+	root.mu.RLock()
+	defer root.mu.RUnlock()
 
-	// 构建slog属性
-	var attrs []slog.Attr
+	// 构建slog属性，子记录器的字段在前
+	attrs := append([]slog.Attr(nil), l.attrs...)
 	if len(fields) > 0 && fields[0] != nil {
 		// 处理fields参数
 		if fieldsMap, ok := fields[0].(map[string]interface{}); ok {
@@ -312,8 +340,8 @@ func (l *Logger) log(level slog.Level, msg string, fields ...interface{}) {
 
 	// 同时写入文件（JSON）和控制台（文本）
 	ctx := context.Background()
-	l.jsonLogger.LogAttrs(ctx, level, msg, attrs...)
-	l.textLogger.LogAttrs(ctx, level, msg, attrs...)
+	root.jsonLogger.LogAttrs(ctx, level, msg, attrs...)
+	root.textLogger.LogAttrs(ctx, level, msg, attrs...)
 }
 
 // Debug 记录调试级别日志
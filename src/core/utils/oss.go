@@ -2,6 +2,7 @@ package utils
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
 	"fmt"
 	"path/filepath"
@@ -81,6 +82,31 @@ func (u *OSSUploader) UploadFile(localPath, ossPath string) (string, error) {
 	return fileURL, nil
 }
 
+// UploadData 上传数据到OSS指定路径
+func (u *OSSUploader) UploadData(ctx context.Context, ossPath string, data []byte) (string, error) {
+	if err := u.bucket.PutObject(ossPath, bytes.NewReader(data), oss.WithContext(ctx)); err != nil {
+		return "", fmt.Errorf("上传到OSS失败: %v", err)
+	}
+	return u.generateFileURL(ossPath), nil
+}
+
+// DeleteObject 删除OSS指定路径的文件
+func (u *OSSUploader) DeleteObject(ctx context.Context, ossPath string) error {
+	if err := u.bucket.DeleteObject(ossPath, oss.WithContext(ctx)); err != nil {
+		return fmt.Errorf("删除OSS文件失败: %v", err)
+	}
+	return nil
+}
+
+// SignGetURL 生成指定路径的限时GET访问地址，用于私有bucket
+func (u *OSSUploader) SignGetURL(ossPath string, ttl time.Duration) (string, error) {
+	signed, err := u.bucket.SignURL(ossPath, oss.HTTPGet, int64(ttl/time.Second))
+	if err != nil {
+		return "", fmt.Errorf("生成OSS预签名地址失败: %v", err)
+	}
+	return signed, nil
+}
+
 // generateFileURL 生成文件访问URL
 func (u *OSSUploader) generateFileURL(ossPath string) string {
 	// 清理 endpoint
@@ -0,0 +1,187 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	punctuationRestoreTimeout = 2 * time.Second
+	// 中文连续这么多字没有标点时，在停顿处补逗号
+	chineseClauseMinRunes = 5
+	// 以这些字结尾的片段视为句子结束
+	chineseSentenceEndChars = "了吧啊呀啦嘛哦"
+	chineseQuestionChars    = "吗呢"
+	sentencePunctuation     = "。！？.!?"
+)
+
+var punctuationRestoreURL atomic.Pointer[string]
+
+var punctuationHTTPClient = &http.Client{Timeout: punctuationRestoreTimeout}
+
+// SetPunctuationRestoreURL 设置标点恢复服务地址，为空时只使用本地规则
+// 请求: POST {"text": "...", "lang": "zh"}，响应: {"text": "..."}
+func SetPunctuationRestoreURL(url string) {
+	punctuationRestoreURL.Store(&url)
+}
+
+// RestorePunctuation 为识别结果恢复标点，配置了标点恢复服务时优先调用服务，失败时使用本地规则
+// lang 为空时按是否包含汉字判断语言，中英文以外的语言原样返回
+func RestorePunctuation(text string, lang string) string {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return text
+	}
+	if url := punctuationRestoreURL.Load(); url != nil && *url != "" {
+		restored, err := requestPunctuationRestore(*url, text, lang)
+		if err == nil && restored != "" {
+			return restored
+		}
+		if DefaultLogger != nil {
+			DefaultLogger.Warn("标点恢复服务调用失败，使用本地规则: %v", err)
+		}
+	}
+
+	lang = strings.ToLower(lang)
+	switch {
+	case strings.HasPrefix(lang, "zh"), lang == "" && containsHan(text):
+		return restoreChinesePunctuation(text)
+	case strings.HasPrefix(lang, "en"), lang == "":
+		return restoreEnglishPunctuation(text)
+	default:
+		return text
+	}
+}
+
+func requestPunctuationRestore(url, text, lang string) (string, error) {
+	body, err := json.Marshal(map[string]string{"text": text, "lang": lang})
+	if err != nil {
+		return "", fmt.Errorf("序列化标点恢复请求失败: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), punctuationRestoreTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("创建请求失败: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := punctuationHTTPClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("请求标点恢复服务失败: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("标点恢复服务返回状态码 %d: %s", resp.StatusCode, data)
+	}
+	var result struct {
+		Text string `json:"text"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("解析标点恢复结果失败: %v", err)
+	}
+	return result.Text, nil
+}
+
+// restoreChinesePunctuation 按识别结果中的停顿(空格)补标点：
+// 以疑问语气词结尾补问号，以句末语气词结尾补句号，超过5个字未断句补逗号，结尾没有标点时补句号或问号
+func restoreChinesePunctuation(text string) string {
+	segments := strings.Fields(text)
+	var builder strings.Builder
+	run := 0 // 上一个标点之后的字数
+	for i, segment := range segments {
+		builder.WriteString(segment)
+		last, _ := utf8.DecodeLastRuneInString(segment)
+		if unicode.IsPunct(last) {
+			run = 0
+			continue
+		}
+		run += trailingSyllables(segment)
+		if i == len(segments)-1 {
+			break
+		}
+		next, _ := utf8.DecodeRuneInString(segments[i+1])
+		switch {
+		case strings.ContainsRune(chineseQuestionChars, last):
+			builder.WriteString("？")
+		case strings.ContainsRune(chineseSentenceEndChars, last):
+			builder.WriteString("。")
+		case run >= chineseClauseMinRunes:
+			builder.WriteString("，")
+		default:
+			// 不足一个分句时直接连接，与英文单词之间保留空格
+			if last < utf8.RuneSelf || next < utf8.RuneSelf {
+				builder.WriteString(" ")
+			}
+			continue
+		}
+		run = 0
+	}
+
+	result := builder.String()
+	last, _ := utf8.DecodeLastRuneInString(result)
+	switch {
+	case unicode.IsPunct(last):
+	case strings.ContainsRune(chineseQuestionChars, last):
+		result += "？"
+	default:
+		result += "。"
+	}
+	return result
+}
+
+// restoreEnglishPunctuation 句首字母大写
+func restoreEnglishPunctuation(text string) string {
+	runes := []rune(text)
+	capitalize := true
+	for i, r := range runes {
+		switch {
+		case strings.ContainsRune(sentencePunctuation, r):
+			// 句末标点后有空格才是新句子，避免网址、小数被改写
+			capitalize = i+1 < len(runes) && unicode.IsSpace(runes[i+1])
+		case unicode.IsLetter(r):
+			if capitalize {
+				runes[i] = unicode.ToUpper(r)
+			}
+			capitalize = false
+		}
+	}
+	return string(runes)
+}
+
+// trailingSyllables 统计最后一个标点之后的音节数，每个汉字计一个音节，连续的英文字母与数字计一个
+func trailingSyllables(text string) int {
+	count := 0
+	inWord := false
+	for len(text) > 0 {
+		r, size := utf8.DecodeLastRuneInString(text)
+		if unicode.IsPunct(r) {
+			break
+		}
+		word := r < utf8.RuneSelf && (unicode.IsLetter(r) || unicode.IsDigit(r))
+		if !word || !inWord {
+			count++
+		}
+		inWord = word
+		text = text[:len(text)-size]
+	}
+	return count
+}
+
+func containsHan(text string) bool {
+	for _, r := range text {
+		if unicode.Is(unicode.Han, r) {
+			return true
+		}
+	}
+	return false
+}
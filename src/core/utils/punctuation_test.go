@@ -0,0 +1,54 @@
+package utils
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRestorePunctuationRules(t *testing.T) {
+	SetPunctuationRestoreURL("")
+	tests := []struct {
+		text string
+		lang string
+		want string
+	}{
+		{"今天天气怎么样 我想去公园散步 你觉得好吗", "zh", "今天天气怎么样，我想去公园散步，你觉得好吗？"},
+		{"我吃完饭了 你呢", "zh-CN", "我吃完饭了。你呢？"},
+		{"你好 小明 早上好", "", "你好小明早上好。"},
+		{"打开 wifi 开关", "zh", "打开 wifi 开关。"},
+		{"已经有标点了。", "zh", "已经有标点了。"},
+		{"hello there. how are you? i am fine", "en", "Hello there. How are you? I am fine"},
+		{"visit example.com now", "", "Visit example.com now"},
+		{"bonjour", "fr", "bonjour"},
+	}
+	for _, tt := range tests {
+		if got := RestorePunctuation(tt.text, tt.lang); got != tt.want {
+			t.Errorf("RestorePunctuation(%q, %q) = %q, 期望 %q", tt.text, tt.lang, got, tt.want)
+		}
+	}
+}
+
+func TestRestorePunctuationService(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]string
+		json.NewDecoder(r.Body).Decode(&req)
+		if req["text"] == "失败" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"text": req["text"] + "！"})
+	}))
+	defer server.Close()
+	SetPunctuationRestoreURL(server.URL)
+	t.Cleanup(func() { SetPunctuationRestoreURL("") })
+
+	if got := RestorePunctuation("太好了", "zh"); got != "太好了！" {
+		t.Errorf("服务恢复结果 = %q", got)
+	}
+	// 服务失败时使用本地规则
+	if got := RestorePunctuation("失败", "zh"); got != "失败。" {
+		t.Errorf("服务失败时结果 = %q", got)
+	}
+}
@@ -8,6 +8,9 @@ import (
 	"strings"
 )
 
+// shortTextBucket 短文本音频在缓存目录下的子目录
+const shortTextBucket = "short_text"
+
 // QuickReplyCache 快速回复缓存配置
 type QuickReplyCache struct {
 	CacheDir    string // 缓存目录，默认为 "wake_replay"
@@ -39,8 +42,8 @@ func (qrc *QuickReplyCache) FindCachedAudio(text string) string {
 	// 构建完整文件路径
 	fullPath := fmt.Sprintf("%s/%s", qrc.CacheDir, filename)
 
-	// 检查文件是否存在
-	if _, err := os.Stat(fullPath); err == nil {
+	// 检查文件是否存在且不为空
+	if cachedFileValid(fullPath) {
 		return fullPath
 	}
 
@@ -49,17 +52,40 @@ func (qrc *QuickReplyCache) FindCachedAudio(text string) string {
 
 // SaveCachedAudio 保存快速回复音频到缓存目录
 func (qrc *QuickReplyCache) SaveCachedAudio(text, sourcePath string) error {
+	return qrc.saveAudio(qrc.CacheDir, text, sourcePath)
+}
+
+// shortTextDir 短文本音频的缓存目录，与快速回复词分开存放
+func (qrc *QuickReplyCache) shortTextDir() string {
+	return filepath.Join(qrc.CacheDir, shortTextBucket)
+}
+
+// FindShortTextAudio 查找已缓存的短文本（语气词等）音频文件
+func (qrc *QuickReplyCache) FindShortTextAudio(text string) string {
+	fullPath := filepath.Join(qrc.shortTextDir(), qrc.generateFilename(text))
+	if cachedFileValid(fullPath) {
+		return fullPath
+	}
+	return ""
+}
+
+// SaveShortTextAudio 保存短文本音频到短文本缓存目录
+func (qrc *QuickReplyCache) SaveShortTextAudio(text, sourcePath string) error {
+	return qrc.saveAudio(qrc.shortTextDir(), text, sourcePath)
+}
+
+func (qrc *QuickReplyCache) saveAudio(dir, text, sourcePath string) error {
 	// 创建缓存目录
-	if err := os.MkdirAll(qrc.CacheDir, 0o755); err != nil {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
 		return fmt.Errorf("创建缓存目录失败: %v", err)
 	}
 
 	// 生成目标文件名
 	filename := qrc.generateFilename(text)
-	targetPath := fmt.Sprintf("%s/%s", qrc.CacheDir, filename)
+	targetPath := fmt.Sprintf("%s/%s", dir, filename)
 
 	// 检查目标文件是否已存在
-	if _, err := os.Stat(targetPath); err == nil {
+	if cachedFileValid(targetPath) {
 		return nil // 文件已存在，跳过保存
 	}
 
@@ -120,14 +146,78 @@ func (qrc *QuickReplyCache) copyFile(src, dst string) error {
 	}
 	defer targetFile.Close()
 
-	// 复制文件内容
+	// 复制文件内容，失败时删除不完整的目标文件
 	if _, err := targetFile.ReadFrom(sourceFile); err != nil {
+		targetFile.Close()
+		os.Remove(dst)
 		return fmt.Errorf("复制文件内容失败: %v", err)
 	}
 
 	return nil
 }
 
+// Invalidate 删除文本在当前TTS提供商与音色下的快速回复与短文本缓存音频，文件不存在时忽略
+func (qrc *QuickReplyCache) Invalidate(text string) error {
+	filename := qrc.generateFilename(text)
+	for _, path := range []string{filepath.Join(qrc.CacheDir, filename), filepath.Join(qrc.shortTextDir(), filename)} {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("删除缓存音频失败: %v", err)
+		}
+	}
+	return nil
+}
+
+// ValidateCache 检查缓存目录下的每个缓存文件，删除无法读取（如指向已删除文件的链接）或为空的失效缓存
+// 返回有效与失效的缓存数，缓存目录不存在时均为0
+func (qrc *QuickReplyCache) ValidateCache() (valid, invalid int, err error) {
+	err = qrc.walkCacheFiles(func(path string) error {
+		if cachedFileValid(path) {
+			valid++
+			return nil
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("删除失效缓存失败: %v", err)
+		}
+		invalid++
+		return nil
+	})
+	return valid, invalid, err
+}
+
+// CacheStats 统计缓存目录下的缓存文件数与总大小
+func (qrc *QuickReplyCache) CacheStats() (entries int, totalSizeBytes int64) {
+	qrc.walkCacheFiles(func(path string) error {
+		if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+			entries++
+			totalSizeBytes += info.Size()
+		}
+		return nil
+	})
+	return entries, totalSizeBytes
+}
+
+// walkCacheFiles 遍历缓存目录（含短文本子目录）下的所有非目录项
+func (qrc *QuickReplyCache) walkCacheFiles(fn func(path string) error) error {
+	if _, err := os.Lstat(qrc.CacheDir); os.IsNotExist(err) {
+		return nil
+	}
+	return filepath.Walk(qrc.CacheDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		return fn(path)
+	})
+}
+
+// cachedFileValid 缓存文件存在、是普通文件且不为空
+func cachedFileValid(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular() && info.Size() > 0
+}
+
 // IsQuickReplyHit 检查文本是否为快速回复词
 func IsQuickReplyHit(text string, quickReplyWords []string) bool {
 	return IsInArray(text, quickReplyWords)
@@ -139,8 +229,11 @@ func (qrc *QuickReplyCache) IsCachedFile(filePath string) bool {
 		return false
 	}
 
-	// 获取文件的目录部分
+	// 获取文件的目录部分，短文本缓存位于缓存目录的子目录
 	dir := filepath.Dir(filePath)
+	if filepath.Base(dir) == shortTextBucket {
+		dir = filepath.Dir(dir)
+	}
 
 	// 简单判断文件的上一层目录是否是缓存目录
 	return dir == qrc.CacheDir ||
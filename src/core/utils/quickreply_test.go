@@ -0,0 +1,86 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newTestQuickReplyCache(t *testing.T) (*QuickReplyCache, string) {
+	t.Helper()
+	dir := t.TempDir()
+	source := filepath.Join(dir, "source.mp3")
+	if err := os.WriteFile(source, []byte("audio"), 0o644); err != nil {
+		t.Fatalf("写入测试音频失败: %v", err)
+	}
+	qrc := NewQuickReplyCache("doubao", "voice")
+	qrc.CacheDir = filepath.Join(dir, "wake_replay")
+	return qrc, source
+}
+
+func TestQuickReplyCacheValidate(t *testing.T) {
+	qrc, source := newTestQuickReplyCache(t)
+	if err := qrc.SaveCachedAudio("你好", source); err != nil {
+		t.Fatalf("保存缓存失败: %v", err)
+	}
+	if err := qrc.SaveShortTextAudio("嗯", source); err != nil {
+		t.Fatalf("保存短文本缓存失败: %v", err)
+	}
+
+	// 缓存链接指向的文件被外部删除
+	external := filepath.Join(t.TempDir(), "external.mp3")
+	os.WriteFile(external, []byte("audio"), 0o644)
+	stale := filepath.Join(qrc.CacheDir, qrc.generateFilename("再见"))
+	if err := os.Symlink(external, stale); err != nil {
+		t.Skipf("不支持符号链接: %v", err)
+	}
+	os.Remove(external)
+	// 复制中断留下的空文件
+	empty := filepath.Join(qrc.CacheDir, qrc.generateFilename("谢谢"))
+	os.WriteFile(empty, nil, 0o644)
+	if qrc.FindCachedAudio("再见") != "" || qrc.FindCachedAudio("谢谢") != "" {
+		t.Error("失效的缓存不应被返回")
+	}
+
+	valid, invalid, err := qrc.ValidateCache()
+	if err != nil || valid != 2 || invalid != 2 {
+		t.Fatalf("ValidateCache = %d, %d, %v, 期望 2, 2", valid, invalid, err)
+	}
+	if _, err := os.Lstat(stale); !os.IsNotExist(err) {
+		t.Error("失效的缓存链接未被删除")
+	}
+	if _, err := os.Stat(empty); !os.IsNotExist(err) {
+		t.Error("空缓存文件未被删除")
+	}
+	if entries, size := qrc.CacheStats(); entries != 2 || size != 10 {
+		t.Errorf("CacheStats = %d, %d, 期望 2, 10", entries, size)
+	}
+
+	// 空文件被删除后可以重新保存
+	if err := qrc.SaveCachedAudio("谢谢", source); err != nil || qrc.FindCachedAudio("谢谢") == "" {
+		t.Errorf("重新保存缓存失败: %v", err)
+	}
+}
+
+func TestQuickReplyCacheInvalidate(t *testing.T) {
+	qrc, source := newTestQuickReplyCache(t)
+	qrc.SaveCachedAudio("你好", source)
+	qrc.SaveShortTextAudio("你好", source)
+
+	if err := qrc.Invalidate("你好"); err != nil {
+		t.Fatalf("Invalidate 失败: %v", err)
+	}
+	if qrc.FindCachedAudio("你好") != "" || qrc.FindShortTextAudio("你好") != "" {
+		t.Error("Invalidate 后仍能找到缓存")
+	}
+	// 不存在的缓存不报错
+	if err := qrc.Invalidate("不存在"); err != nil {
+		t.Errorf("删除不存在的缓存返回错误: %v", err)
+	}
+
+	// 缓存目录不存在
+	qrc.CacheDir = filepath.Join(t.TempDir(), "missing")
+	if valid, invalid, err := qrc.ValidateCache(); valid != 0 || invalid != 0 || err != nil {
+		t.Errorf("缓存目录不存在时 ValidateCache = %d, %d, %v", valid, invalid, err)
+	}
+}
@@ -0,0 +1,71 @@
+package utils
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// RequestIDHeader 请求关联ID的HTTP头
+	RequestIDHeader = "X-Request-ID"
+	// RequestIDKey 请求关联ID在gin上下文、日志字段与错误响应中的键
+	RequestIDKey = "request_id"
+)
+
+type requestIDContextKey struct{}
+
+// WithRequestID 将请求关联ID附加到上下文，ID为空时原样返回
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	if requestID == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, requestIDContextKey{}, requestID)
+}
+
+// RequestIDFromContext 获取上下文中的请求关联ID，不存在时返回空字符串
+func RequestIDFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	requestID, _ := ctx.Value(requestIDContextKey{}).(string)
+	return requestID
+}
+
+// GetRequestID 获取 middleware.RequestID 写入gin上下文的请求关联ID
+func GetRequestID(c *gin.Context) string {
+	return c.GetString(RequestIDKey)
+}
+
+// RequestLogger 返回附带当前请求关联ID的日志记录器，没有关联ID时返回原记录器
+func RequestLogger(c *gin.Context, logger *Logger) *Logger {
+	requestID := GetRequestID(c)
+	if requestID == "" || logger == nil {
+		return logger
+	}
+	return logger.WithField(RequestIDKey, requestID)
+}
+
+// requestIDTransport 将请求上下文中的关联ID通过 X-Request-ID 头转发给上游服务
+type requestIDTransport struct {
+	base http.RoundTripper
+}
+
+// NewRequestIDTransport 创建转发请求关联ID的 http.RoundTripper，base 为nil时使用 http.DefaultTransport
+func NewRequestIDTransport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &requestIDTransport{base: base}
+}
+
+func (t *requestIDTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	requestID := RequestIDFromContext(req.Context())
+	if requestID == "" || req.Header.Get(RequestIDHeader) != "" {
+		return t.base.RoundTrip(req)
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set(RequestIDHeader, requestID)
+	return t.base.RoundTrip(req)
+}
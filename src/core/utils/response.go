@@ -8,11 +8,20 @@ import (
 
 // UnifiedResponse 统一响应结构体
 type UnifiedResponse struct {
-	Code    int         `json:"code"`              // HTTP状态码
-	Success bool        `json:"success"`           // 是否成功
-	Message string      `json:"message,omitempty"` // 消息描述
-	Data    interface{} `json:"data,omitempty"`    // 数据负载
-	Error   string      `json:"error,omitempty"`   // 错误详情
+	Code      int         `json:"code"`                 // HTTP状态码
+	Success   bool        `json:"success"`              // 是否成功
+	Message   string      `json:"message,omitempty"`    // 消息描述
+	Data      interface{} `json:"data,omitempty"`       // 数据负载
+	Error     string      `json:"error,omitempty"`      // 错误详情
+	RequestID string      `json:"request_id,omitempty"` // 请求关联ID，仅错误响应携带
+}
+
+// errorRequestID 错误响应返回请求关联ID，成功响应返回空字符串
+func errorRequestID(c *gin.Context, statusCode int) string {
+	if statusCode >= 200 && statusCode < 300 {
+		return ""
+	}
+	return GetRequestID(c)
 }
 
 // Success 返回成功响应
@@ -38,18 +47,20 @@ func SuccessWithMessage(c *gin.Context, message string, data interface{}) {
 // Error 返回错误响应
 func Error(c *gin.Context, statusCode int, message string) {
 	c.JSON(statusCode, UnifiedResponse{
-		Code:    statusCode,
-		Success: false,
-		Message: message,
+		Code:      statusCode,
+		Success:   false,
+		Message:   message,
+		RequestID: errorRequestID(c, statusCode),
 	})
 }
 
 // ErrorWithDetail 返回带详细错误信息的错误响应
 func ErrorWithDetail(c *gin.Context, statusCode int, message string, err error) {
 	resp := UnifiedResponse{
-		Code:    statusCode,
-		Success: false,
-		Message: message,
+		Code:      statusCode,
+		Success:   false,
+		Message:   message,
+		RequestID: errorRequestID(c, statusCode),
 	}
 	if err != nil {
 		resp.Error = err.Error()
@@ -62,9 +73,10 @@ func ErrorWithDetail(c *gin.Context, statusCode int, message string, err error)
 func Custom(c *gin.Context, statusCode int, data interface{}) {
 	success := statusCode >= 200 && statusCode < 300
 	c.JSON(statusCode, UnifiedResponse{
-		Code:    statusCode,
-		Success: success,
-		Data:    data,
+		Code:      statusCode,
+		Success:   success,
+		Data:      data,
+		RequestID: errorRequestID(c, statusCode),
 	})
 }
 
@@ -72,9 +84,10 @@ func Custom(c *gin.Context, statusCode int, data interface{}) {
 func CustomWithMessage(c *gin.Context, statusCode int, message string, data interface{}) {
 	success := statusCode >= 200 && statusCode < 300
 	c.JSON(statusCode, UnifiedResponse{
-		Code:    statusCode,
-		Success: success,
-		Message: message,
-		Data:    data,
+		Code:      statusCode,
+		Success:   success,
+		Message:   message,
+		Data:      data,
+		RequestID: errorRequestID(c, statusCode),
 	})
 }
@@ -0,0 +1,63 @@
+package utils
+
+import "strings"
+
+// 对话情感标签
+const (
+	SentimentPositive = "positive"
+	SentimentNeutral  = "neutral"
+	SentimentNegative = "negative"
+	SentimentExcited  = "excited"
+	SentimentSad      = "sad"
+)
+
+// SentimentLabels 支持的全部情感标签
+var SentimentLabels = []string{SentimentPositive, SentimentNeutral, SentimentNegative, SentimentExcited, SentimentSad}
+
+// 各情感对应的关键词，命中数最多的情感作为结果
+var sentimentKeywords = map[string][]string{
+	SentimentExcited: {
+		"太棒了", "太好了", "好耶", "哇", "激动", "兴奋", "厉害", "恭喜", "万岁", "超级棒",
+		"amazing", "awesome", "fantastic", "wow", "congratulations", "incredible",
+	},
+	SentimentPositive: {
+		"开心", "高兴", "快乐", "喜欢", "不错", "很好", "谢谢", "感谢", "满意", "放心", "当然可以", "没问题",
+		"happy", "glad", "great", "good", "thanks", "thank you", "love", "nice",
+	},
+	SentimentSad: {
+		"难过", "伤心", "遗憾", "可惜", "哭", "想念", "孤单", "失落", "抱歉听到",
+		"sad", "sorry to hear", "miss you", "unfortunately", "lonely",
+	},
+	SentimentNegative: {
+		"生气", "讨厌", "糟糕", "不行", "失败", "错误", "无法", "烦", "愤怒", "抱歉",
+		"angry", "hate", "terrible", "awful", "bad", "failed", "error", "sorry",
+	},
+}
+
+// AnalyzeSentiment 基于关键词的轻量情感分析，返回 positive、neutral、negative、excited、sad 之一
+// 命中数相同时按 excited、positive、sad、negative 的顺序取先者，全部未命中返回 neutral
+func AnalyzeSentiment(text string) string {
+	text = strings.ToLower(text)
+	best, bestCount := SentimentNeutral, 0
+	for _, label := range []string{SentimentExcited, SentimentPositive, SentimentSad, SentimentNegative} {
+		count := 0
+		for _, keyword := range sentimentKeywords[label] {
+			count += strings.Count(text, keyword)
+		}
+		if count > bestCount {
+			best, bestCount = label, count
+		}
+	}
+	return best
+}
+
+// NormalizeSentiment 将LLM返回的情感标签规范化，无法识别时返回空字符串
+func NormalizeSentiment(label string) string {
+	label = strings.ToLower(strings.Trim(strings.TrimSpace(label), "\"'.。"))
+	for _, s := range SentimentLabels {
+		if label == s {
+			return s
+		}
+	}
+	return ""
+}
@@ -0,0 +1,28 @@
+package utils
+
+import "testing"
+
+func TestAnalyzeSentiment(t *testing.T) {
+	cases := map[string]string{
+		"很高兴能帮到你，谢谢你的信任！":           SentimentPositive,
+		"哇，太棒了，恭喜你拿到冠军！":            SentimentExcited,
+		"听到这个消息我很难过，真的很遗憾。":         SentimentSad,
+		"抱歉，我无法完成这个操作。":             SentimentNegative,
+		"今天北京的气温是二十度。":              SentimentNeutral,
+		"Thanks, that sounds good!": SentimentPositive,
+	}
+	for text, expected := range cases {
+		if got := AnalyzeSentiment(text); got != expected {
+			t.Errorf("AnalyzeSentiment(%q) = %s, 期望 %s", text, got, expected)
+		}
+	}
+}
+
+func TestNormalizeSentiment(t *testing.T) {
+	if got := NormalizeSentiment(" Excited.\n"); got != SentimentExcited {
+		t.Errorf("NormalizeSentiment = %q, 期望 excited", got)
+	}
+	if got := NormalizeSentiment("happy"); got != "" {
+		t.Errorf("未知标签应返回空字符串, 实际 %q", got)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"math/rand"
 	"regexp"
 	"strings"
+	"unicode"
 	"unicode/utf8"
 )
 
@@ -28,8 +29,8 @@ func SplitAtLastPunctuation(text string) (string, int) {
 	}
 
 	// 定义不同优先级的分句标点符号
-	// 优先级1：强制停顿的标点（句号、问号、感叹号等）
-	strongPunctuations := []string{"。", "？", "！", "；", "?", "!", ";"}
+	// 优先级1：强制停顿的标点（句号、问号、感叹号等），换行视为段落结束
+	strongPunctuations := []string{"。", "？", "！", "；", "?", "!", ";", "\n"}
 
 	// 优先级2：中等停顿的标点（逗号、冒号等）
 	mediumPunctuations := []string{"，", "：", ",", ".", ":"}
@@ -93,6 +94,11 @@ func SplitAtLastPunctuation(text string) (string, int) {
 	return "", 0
 }
 
+// EndsWithParagraphBreak 判断分段是否以换行结尾（忽略行尾空白）
+func EndsWithParagraphBreak(segment string) bool {
+	return strings.HasSuffix(strings.TrimRight(segment, " \t\r"), "\n")
+}
+
 // findLastPunctuationWithMinLength 查找最后一个标点符号位置，确保最小长度
 func findLastPunctuationWithMinLength(text string, punctuations []string, minLength int) (string, int) {
 	// 安全检查：确保 minLength 不超过文本长度
@@ -330,3 +336,78 @@ func RemoveParentheses(text string) string {
 	text = reRemoveParenthesesEN.ReplaceAllString(text, "")
 	return text
 }
+
+// keywordStopPhrases 提取关键词时移除的中文口语化短语，较长的短语在前
+var keywordStopPhrases = []string{
+	"为什么", "怎么样", "是不是", "有没有", "告诉我", "请问", "帮我", "给我", "一下", "什么",
+	"怎么", "如何", "可以", "能不能", "我想", "想要", "知道", "一个", "这个", "那个", "你们", "我们",
+}
+
+// keywordStopWords 提取关键词时忽略的英文停用词
+var keywordStopWords = map[string]struct{}{
+	"a": {}, "an": {}, "the": {}, "is": {}, "are": {}, "was": {}, "were": {}, "be": {}, "to": {},
+	"of": {}, "and": {}, "or": {}, "in": {}, "on": {}, "at": {}, "for": {}, "with": {}, "about": {},
+	"what": {}, "how": {}, "why": {}, "who": {}, "when": {}, "where": {}, "which": {}, "do": {},
+	"does": {}, "did": {}, "can": {}, "could": {}, "please": {}, "tell": {}, "me": {}, "i": {},
+	"you": {}, "my": {}, "your": {}, "it": {}, "this": {}, "that": {},
+}
+
+// keywordTrimParticles 中文片段首尾去除的语气词与助词
+const keywordTrimParticles = "吗呢吧啊呀哦嘛啦了的么"
+
+// ExtractKeywords 从用户输入中提取检索关键词：去除标点、口语化短语和停用词，按出现顺序去重
+func ExtractKeywords(text string) []string {
+	text = strings.ToLower(text)
+	for _, phrase := range keywordStopPhrases {
+		text = strings.ReplaceAll(text, phrase, " ")
+	}
+
+	tokens := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+
+	seen := make(map[string]struct{}, len(tokens))
+	keywords := make([]string, 0, len(tokens))
+	for _, token := range tokens {
+		token = strings.Trim(token, keywordTrimParticles)
+		if token == "" {
+			continue
+		}
+		if _, ok := keywordStopWords[token]; ok {
+			continue
+		}
+		if _, ok := seen[token]; ok {
+			continue
+		}
+		seen[token] = struct{}{}
+		keywords = append(keywords, token)
+	}
+	return keywords
+}
+
+// EditSimilarity 按字符（rune）计算编辑距离相似度 1 - distance/max(len)，完全相同为1
+func EditSimilarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	maxLen := max(len(ra), len(rb))
+	if maxLen == 0 {
+		return 1
+	}
+
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			if ra[i-1] == rb[j-1] {
+				curr[j] = prev[j-1]
+			} else {
+				curr[j] = min(prev[j], curr[j-1], prev[j-1]) + 1
+			}
+		}
+		prev, curr = curr, prev
+	}
+	return 1 - float64(prev[len(rb)])/float64(maxLen)
+}
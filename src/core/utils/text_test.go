@@ -163,3 +163,39 @@ func TestSplitAtLastPunctuation_DotBetweenLetters_Split(t *testing.T) {
 		t.Fatalf("SplitAtLastPunctuation(%q) = (%q, %d), want (%q, %d)", text, seg, pos, expectedSeg, len(expectedSeg))
 	}
 }
+
+func TestExtractKeywords(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"请问火星的大气成分是什么？", []string{"火星的大气成分是"}},
+		{"What is the capital of France?", []string{"capital", "france"}},
+		{"帮我查一下 Go 语言的 goroutine 怎么用", []string{"查", "go", "语言", "goroutine", "用"}},
+		{"。。。", []string{}},
+	}
+	for _, tt := range tests {
+		got := ExtractKeywords(tt.input)
+		if strings.Join(got, "|") != strings.Join(tt.expected, "|") {
+			t.Errorf("ExtractKeywords(%q) = %q, 期望 %q", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestEditSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want float64
+	}{
+		{"退出", "退出", 1},
+		{"关闭链接", "关闭连接", 0.75},
+		{"退出了吗", "退出", 0.5},
+		{"", "", 1},
+		{"abc", "", 0},
+	}
+	for _, tt := range tests {
+		if got := EditSimilarity(tt.a, tt.b); got != tt.want {
+			t.Errorf("EditSimilarity(%q, %q) = %v, 期望 %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package utils
+
+import (
+	"math"
+	"unicode"
+)
+
+// RuneTokens 单个字符的估算token数：中日韩字符按每3.5个字符1个token，其余按每4个字符1个token
+func RuneTokens(r rune) float64 {
+	if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) {
+		return 1 / 3.5
+	}
+	return 1.0 / 4
+}
+
+// TextTokens 按 RuneTokens 累加文本的估算token数，不取整，便于多段文本合计后再取整
+func TextTokens(text string) float64 {
+	tokens := 0.0
+	for _, r := range text {
+		tokens += RuneTokens(r)
+	}
+	return tokens
+}
+
+// EstimateTokens 估算文本的token数，向上取整
+func EstimateTokens(text string) int {
+	return int(math.Ceil(TextTokens(text)))
+}
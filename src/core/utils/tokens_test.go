@@ -0,0 +1,23 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEstimateTokens(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"", 0},
+		{strings.Repeat("你", 7), 2},
+		{strings.Repeat("a", 8), 2},
+		{"hi 你好", 2},
+	}
+	for _, tt := range tests {
+		if got := EstimateTokens(tt.text); got != tt.want {
+			t.Errorf("EstimateTokens(%q) = %d, 期望 %d", tt.text, got, tt.want)
+		}
+	}
+}
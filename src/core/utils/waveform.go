@@ -0,0 +1,101 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/hajimehoshi/go-mp3"
+)
+
+// DefaultWaveformPoints 波形默认采样点数
+const DefaultWaveformPoints = 100
+
+// ComputeWaveform 计算音频波形，返回时长（秒）与归一化到[0,1]的振幅数组
+// 支持wav与mp3格式，每个点取对应区间内的峰值振幅
+func ComputeWaveform(data []byte, suffix string, points int) (float64, []float64, error) {
+	if points <= 0 {
+		points = DefaultWaveformPoints
+	}
+
+	var samples []int16
+	var sampleRate int
+	switch suffix {
+	case "wav":
+		info, err := parseWAVInfo(data)
+		if err != nil {
+			return 0, nil, err
+		}
+		if info.bitsPerSample != 16 {
+			return 0, nil, fmt.Errorf("不支持的WAV采样位数: %d", info.bitsPerSample)
+		}
+		end := info.dataOffset + info.dataSize
+		if end > len(data) {
+			end = len(data)
+		}
+		samples = pcm16ToMono(data[info.dataOffset:end], info.channels)
+		sampleRate = info.sampleRate
+	case "mp3":
+		decoder, err := mp3.NewDecoder(bytes.NewReader(data))
+		if err != nil {
+			return 0, nil, fmt.Errorf("创建MP3解码器失败: %v", err)
+		}
+		pcmBytes, err := io.ReadAll(decoder)
+		if err != nil {
+			return 0, nil, fmt.Errorf("MP3解码失败: %v", err)
+		}
+		// go-mp3 固定输出16位立体声
+		samples = pcm16ToMono(pcmBytes, 2)
+		sampleRate = decoder.SampleRate()
+	default:
+		return 0, nil, fmt.Errorf("不支持的音频格式: %s", suffix)
+	}
+
+	if len(samples) == 0 || sampleRate <= 0 {
+		return 0, nil, fmt.Errorf("音频数据为空")
+	}
+
+	duration := float64(len(samples)) / float64(sampleRate)
+	waveform := make([]float64, points)
+	for i := 0; i < points; i++ {
+		start := i * len(samples) / points
+		end := (i + 1) * len(samples) / points
+		if end <= start {
+			end = start + 1
+		}
+		if end > len(samples) {
+			end = len(samples)
+		}
+		peak := 0
+		for _, sample := range samples[start:end] {
+			v := int(sample)
+			if v < 0 {
+				v = -v
+			}
+			if v > peak {
+				peak = v
+			}
+		}
+		waveform[i] = float64(peak) / 32768
+	}
+	return duration, waveform, nil
+}
+
+// pcm16ToMono 将16位小端PCM数据混合为单声道样本
+func pcm16ToMono(pcm []byte, channels int) []int16 {
+	if channels <= 0 {
+		channels = 1
+	}
+	frameBytes := 2 * channels
+	count := len(pcm) / frameBytes
+	samples := make([]int16, count)
+	for i := 0; i < count; i++ {
+		sum := 0
+		for ch := 0; ch < channels; ch++ {
+			offset := i*frameBytes + ch*2
+			sum += int(int16(uint16(pcm[offset]) | uint16(pcm[offset+1])<<8))
+		}
+		samples[i] = int16(sum / channels)
+	}
+	return samples
+}
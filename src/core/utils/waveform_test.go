@@ -0,0 +1,68 @@
+package utils
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+// buildTestWAV 生成16位单声道正弦波WAV数据
+func buildTestWAV(sampleRate int, seconds float64) []byte {
+	numSamples := int(float64(sampleRate) * seconds)
+	dataSize := numSamples * 2
+	buf := make([]byte, 44+dataSize)
+	copy(buf[0:4], "RIFF")
+	binary.LittleEndian.PutUint32(buf[4:8], uint32(36+dataSize))
+	copy(buf[8:12], "WAVE")
+	copy(buf[12:16], "fmt ")
+	binary.LittleEndian.PutUint32(buf[16:20], 16)
+	binary.LittleEndian.PutUint16(buf[20:22], 1)
+	binary.LittleEndian.PutUint16(buf[22:24], 1)
+	binary.LittleEndian.PutUint32(buf[24:28], uint32(sampleRate))
+	binary.LittleEndian.PutUint32(buf[28:32], uint32(sampleRate*2))
+	binary.LittleEndian.PutUint16(buf[32:34], 2)
+	binary.LittleEndian.PutUint16(buf[34:36], 16)
+	copy(buf[36:40], "data")
+	binary.LittleEndian.PutUint32(buf[40:44], uint32(dataSize))
+	for i := 0; i < numSamples; i++ {
+		// 振幅随时间线性增大
+		amp := float64(i) / float64(numSamples) * 32767
+		v := int16(amp * math.Sin(2*math.Pi*440*float64(i)/float64(sampleRate)))
+		binary.LittleEndian.PutUint16(buf[44+i*2:], uint16(v))
+	}
+	return buf
+}
+
+func TestComputeWaveformWAV(t *testing.T) {
+	data := buildTestWAV(16000, 2)
+
+	duration, waveform, err := ComputeWaveform(data, "wav", DefaultWaveformPoints)
+	if err != nil {
+		t.Fatalf("ComputeWaveform 失败: %v", err)
+	}
+	if math.Abs(duration-2) > 0.01 {
+		t.Errorf("时长 = %f, 期望 2", duration)
+	}
+	if len(waveform) != 100 {
+		t.Fatalf("波形长度 = %d, 期望 100", len(waveform))
+	}
+	for i, v := range waveform {
+		if v < 0 || v > 1 {
+			t.Errorf("波形第%d点 = %f, 超出[0,1]", i, v)
+		}
+	}
+	if waveform[99] <= waveform[0] {
+		t.Errorf("振幅应随时间增大: 首点=%f, 末点=%f", waveform[0], waveform[99])
+	}
+
+	wavDuration, err := parseWAVDuration(data)
+	if err != nil || math.Abs(wavDuration-duration) > 0.01 {
+		t.Errorf("parseWAVDuration = %f, %v, 期望 %f", wavDuration, err, duration)
+	}
+}
+
+func TestComputeWaveformUnsupported(t *testing.T) {
+	if _, _, err := ComputeWaveform([]byte("xxxx"), "ogg", DefaultWaveformPoints); err == nil {
+		t.Errorf("不支持的格式应返回错误")
+	}
+}
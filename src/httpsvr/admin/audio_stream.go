@@ -0,0 +1,81 @@
+package admin
+
+import (
+	"encoding/base64"
+	"net/http"
+	"time"
+
+	"angrymiao-ai-server/src/core"
+	"angrymiao-ai-server/src/core/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// audioStreamMaxDuration 单个音频监听连接的最长时长，到期后由服务端断开
+const audioStreamMaxDuration = time.Hour
+
+// handleAudioStream 升级为WebSocket，订阅会话的下发音频频道并以二进制帧转发
+// 需开启 audio_monitor_enabled，会话发送给设备的音频帧才会发布到频道
+func (s *AdminService) handleAudioStream(c *gin.Context) {
+	if s.audioMonitor == nil {
+		utils.Custom(c, http.StatusServiceUnavailable, AudioStreamResponse{Success: false, Message: "未配置Redis，音频监听不可用"})
+		return
+	}
+	sessionID := c.Param("session_id")
+	conn, err := metricsUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		s.logger.Error("音频监听WebSocket升级失败: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	ctx := c.Request.Context()
+	pubsub := s.audioMonitor.Subscribe(ctx, core.AudioMonitorChannel(sessionID))
+	defer pubsub.Close()
+	// 等待订阅生效后再转发，避免丢失连接建立后的音频
+	if _, err := pubsub.Receive(ctx); err != nil {
+		s.logger.Error("订阅会话音频失败: %s, %v", sessionID, err)
+		return
+	}
+	s.logger.Info("音频监听已连接: session=%s, client=%s", sessionID, c.ClientIP())
+
+	// 读取客户端消息以处理关闭帧，客户端断开时结束转发
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	deadline := time.NewTimer(audioStreamMaxDuration)
+	defer deadline.Stop()
+	messages := pubsub.Channel()
+	for {
+		select {
+		case <-closed:
+			return
+		case <-deadline.C:
+			conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseNormalClosure, "max duration reached"),
+				time.Now().Add(time.Second))
+			return
+		case msg, ok := <-messages:
+			if !ok {
+				return
+			}
+			frame, err := base64.StdEncoding.DecodeString(msg.Payload)
+			if err != nil {
+				continue
+			}
+			conn.SetWriteDeadline(time.Now().Add(metricsWriteTimeout))
+			if err := conn.WriteMessage(websocket.BinaryMessage, frame); err != nil {
+				s.logger.Warn("转发会话音频失败: %v", err)
+				return
+			}
+		}
+	}
+}
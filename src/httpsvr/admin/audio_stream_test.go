@@ -0,0 +1,72 @@
+package admin
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"angrymiao-ai-server/src/core"
+	"angrymiao-ai-server/src/core/middleware"
+	"angrymiao-ai-server/src/core/utils"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestAudioStreamForwardsSessionFrames(t *testing.T) {
+	logger, err := utils.NewLogger(&utils.LogCfg{LogLevel: "ERROR", LogDir: t.TempDir(), LogFile: "test.log"})
+	if err != nil {
+		t.Fatalf("创建日志失败: %v", err)
+	}
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+	s := &AdminService{logger: logger, audioMonitor: client}
+
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.GET("/ws/audio-stream/:session_id", middleware.AdminTokenAuth("secret"), s.handleAudioStream)
+	srv := httptest.NewServer(engine)
+	defer srv.Close()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws/audio-stream/s1"
+
+	if _, resp, err := websocket.DefaultDialer.Dial(url, nil); err == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("未携带管理员令牌应返回401, err=%v", err)
+	}
+	conn, _, err := websocket.DefaultDialer.Dial(url, http.Header{"Authorization": []string{"Bearer secret"}})
+	if err != nil {
+		t.Fatalf("连接音频监听失败: %v", err)
+	}
+	defer conn.Close()
+
+	// 握手完成时订阅已生效；其他会话的音频不转发
+	ctx := context.Background()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		subs, _ := client.PubSubNumSub(ctx, core.AudioMonitorChannel("s1")).Result()
+		if subs[core.AudioMonitorChannel("s1")] == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("监听连接未订阅会话音频频道")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	client.Publish(ctx, core.AudioMonitorChannel("s2"), base64.StdEncoding.EncodeToString([]byte("other")))
+	client.Publish(ctx, core.AudioMonitorChannel("s1"), base64.StdEncoding.EncodeToString([]byte{0x4f, 0x70, 0x75, 0x73}))
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	messageType, data, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("读取音频帧失败: %v", err)
+	}
+	if messageType != websocket.BinaryMessage || string(data) != "Opus" {
+		t.Errorf("收到 type=%d data=%q, 期望二进制帧 Opus", messageType, data)
+	}
+}
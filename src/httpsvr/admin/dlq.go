@@ -0,0 +1,51 @@
+package admin
+
+import (
+	"errors"
+	"net/http"
+
+	"angrymiao-ai-server/src/core/utils"
+	"angrymiao-ai-server/src/task"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetDeadLetterQueue 设置任务死信队列，为nil时死信接口不可用
+func (s *AdminService) SetDeadLetterQueue(dlq *task.DeadLetterQueue) {
+	s.dlq = dlq
+}
+
+// handleListDeadLetters 列出所有会话执行失败的任务
+func (s *AdminService) handleListDeadLetters(c *gin.Context) {
+	if s.dlq == nil {
+		utils.Custom(c, http.StatusServiceUnavailable, DeadLetterListResponse{Success: false, Message: "死信队列不可用"})
+		return
+	}
+	entries, err := s.dlq.List(c.Request.Context())
+	if err != nil {
+		s.logger.Error("查询死信队列失败: %v", err)
+		utils.Custom(c, http.StatusInternalServerError, DeadLetterListResponse{Success: false, Message: "查询死信队列失败"})
+		return
+	}
+	utils.Custom(c, http.StatusOK, DeadLetterListResponse{Success: true, Entries: entries, Total: len(entries)})
+}
+
+// handleRetryDeadLetter 将死信任务移出队列并重新提交
+func (s *AdminService) handleRetryDeadLetter(c *gin.Context) {
+	if s.dlq == nil {
+		utils.Custom(c, http.StatusServiceUnavailable, DeadLetterRetryResponse{Success: false, Message: "死信队列不可用"})
+		return
+	}
+	taskID := c.Param("task_id")
+	if err := s.dlq.Retry(c.Request.Context(), taskID); err != nil {
+		if errors.Is(err, task.ErrDeadLetterNotFound) {
+			utils.Custom(c, http.StatusNotFound, DeadLetterRetryResponse{Success: false, Message: err.Error(), TaskID: taskID})
+			return
+		}
+		s.logger.Error("重试死信任务 %s 失败: %v", taskID, err)
+		utils.Custom(c, http.StatusInternalServerError, DeadLetterRetryResponse{Success: false, Message: "重新提交任务失败: " + err.Error(), TaskID: taskID})
+		return
+	}
+	s.logger.Info("死信任务已手动重新提交: %s", taskID)
+	utils.Custom(c, http.StatusOK, DeadLetterRetryResponse{Success: true, TaskID: taskID})
+}
@@ -0,0 +1,158 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"angrymiao-ai-server/src/core/pool"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// metricsPushInterval 指标快照推送间隔
+	metricsPushInterval = 5 * time.Second
+	// metricsStreamMaxDuration 单个订阅连接的最长时长，到期后由服务端断开
+	metricsStreamMaxDuration = time.Hour
+	// metricsWriteTimeout 单次推送的写超时
+	metricsWriteTimeout = 10 * time.Second
+)
+
+var metricsUpgrader = websocket.Upgrader{
+	CheckOrigin: func(r *http.Request) bool { return true }, // 已通过管理员令牌校验
+}
+
+// metricsHub 定时生成指标快照并广播给所有订阅者，同一时刻的订阅者收到相同的快照
+type metricsHub struct {
+	interval time.Duration
+	collect  func() MetricsSnapshot
+
+	mu          sync.Mutex
+	subscribers map[chan []byte]struct{}
+}
+
+func newMetricsHub(interval time.Duration, collect func() MetricsSnapshot) *metricsHub {
+	return &metricsHub{
+		interval:    interval,
+		collect:     collect,
+		subscribers: make(map[chan []byte]struct{}),
+	}
+}
+
+// run 按间隔广播快照直到 ctx 结束，没有订阅者时不生成快照
+func (m *metricsHub) run(ctx context.Context) {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.broadcast()
+		}
+	}
+}
+
+func (m *metricsHub) broadcast() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.subscribers) == 0 {
+		return
+	}
+	data, err := json.Marshal(m.collect())
+	if err != nil {
+		return
+	}
+	for ch := range m.subscribers {
+		// 订阅者尚未写完上一次快照时丢弃本次快照，避免阻塞其他订阅者
+		select {
+		case ch <- data:
+		default:
+		}
+	}
+}
+
+func (m *metricsHub) subscribe() chan []byte {
+	ch := make(chan []byte, 1)
+	m.mu.Lock()
+	m.subscribers[ch] = struct{}{}
+	m.mu.Unlock()
+	return ch
+}
+
+func (m *metricsHub) unsubscribe(ch chan []byte) {
+	m.mu.Lock()
+	delete(m.subscribers, ch)
+	m.mu.Unlock()
+}
+
+// SetPoolStatsProvider 设置资源池统计来源，用于计算 llm_in_flight 与 asr_active
+func (s *AdminService) SetPoolStatsProvider(provider func() map[string]pool.PoolStats) {
+	s.poolStats = provider
+}
+
+// collectMetrics 汇总会话登记表与资源池统计
+func (s *AdminService) collectMetrics() MetricsSnapshot {
+	connections, sessions, ttsQueueDepth := s.registry.LoadStats()
+	snapshot := MetricsSnapshot{
+		ActiveConnections: connections,
+		ActiveSessions:    sessions,
+		TTSQueueDepth:     ttsQueueDepth,
+		Timestamp:         time.Now(),
+	}
+	if s.poolStats != nil {
+		stats := s.poolStats()
+		snapshot.LLMInFlight = stats["llm"].InUse
+		snapshot.ASRActive = stats["asr"].InUse
+	}
+	return snapshot
+}
+
+// handleMetricsStream 升级为WebSocket并定时推送指标快照，每个订阅者独立处理，最长保持 metricsStreamMaxDuration
+func (s *AdminService) handleMetricsStream(c *gin.Context) {
+	conn, err := metricsUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		s.logger.Error("管理指标WebSocket升级失败: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	ch := s.metricsHub.subscribe()
+	defer s.metricsHub.unsubscribe(ch)
+	s.logger.Info("管理指标订阅者已连接: %s", c.ClientIP())
+
+	// 读取客户端消息以处理关闭帧，客户端断开时结束推送
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	deadline := time.NewTimer(metricsStreamMaxDuration)
+	defer deadline.Stop()
+	for {
+		select {
+		case <-closed:
+			return
+		case <-deadline.C:
+			conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseNormalClosure, "max duration reached"),
+				time.Now().Add(time.Second))
+			return
+		case data := <-ch:
+			conn.SetWriteDeadline(time.Now().Add(metricsWriteTimeout))
+			if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				s.logger.Warn("推送管理指标失败: %v", err)
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"angrymiao-ai-server/src/core/middleware"
+	"angrymiao-ai-server/src/core/pool"
+	"angrymiao-ai-server/src/core/transport"
+	"angrymiao-ai-server/src/core/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// queueConn 提供固定的TTS队列长度
+type queueConn struct {
+	mockConn
+	depth int
+}
+
+func (q *queueConn) TTSQueueDepth() int { return q.depth }
+
+// waitSubscribers 等待订阅者数量变为 want
+func waitSubscribers(t *testing.T, hub *metricsHub, want int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		hub.mu.Lock()
+		n := len(hub.subscribers)
+		hub.mu.Unlock()
+		if n == want {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("订阅者数量 = %d, 期望 %d", n, want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestMetricsStreamBroadcast(t *testing.T) {
+	logger, err := utils.NewLogger(&utils.LogCfg{LogLevel: "ERROR", LogDir: t.TempDir(), LogFile: "test.log"})
+	if err != nil {
+		t.Fatalf("创建日志失败: %v", err)
+	}
+	registry := &transport.SessionRegistry{}
+	registry.Register(transport.SessionSummary{ID: "c1", SessionID: "s1", StartTime: time.Now()}, &queueConn{depth: 2})
+	registry.Register(transport.SessionSummary{ID: "c2", SessionID: "s1", StartTime: time.Now()}, &queueConn{depth: 3})
+	registry.Register(transport.SessionSummary{ID: "c3", SessionID: "s2", StartTime: time.Now()}, &mockConn{})
+
+	s := &AdminService{logger: logger, registry: registry}
+	s.SetPoolStatsProvider(func() map[string]pool.PoolStats {
+		return map[string]pool.PoolStats{"llm": {InUse: 4}, "asr": {InUse: 1}}
+	})
+	// 不启动定时广播，由测试手动触发
+	s.metricsHub = newMetricsHub(time.Hour, s.collectMetrics)
+
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.GET("/ws/admin/metrics", middleware.AdminTokenAuth("secret"), s.handleMetricsStream)
+	srv := httptest.NewServer(engine)
+	defer srv.Close()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws/admin/metrics"
+
+	if _, resp, err := websocket.DefaultDialer.Dial(url, nil); err == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("未携带管理员令牌应返回401, err=%v", err)
+	}
+
+	header := http.Header{"Authorization": []string{"Bearer secret"}}
+	clients := make([]*websocket.Conn, 2)
+	for i := range clients {
+		conn, _, err := websocket.DefaultDialer.Dial(url, header)
+		if err != nil {
+			t.Fatalf("第%d个订阅者连接失败: %v", i+1, err)
+		}
+		defer conn.Close()
+		clients[i] = conn
+	}
+
+	waitSubscribers(t, s.metricsHub, 2)
+	s.metricsHub.broadcast()
+
+	messages := make([]string, len(clients))
+	for i, conn := range clients {
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("第%d个订阅者读取失败: %v", i+1, err)
+		}
+		messages[i] = string(data)
+	}
+	if messages[0] != messages[1] {
+		t.Errorf("订阅者收到的快照不同:\n%s\n%s", messages[0], messages[1])
+	}
+
+	var snapshot MetricsSnapshot
+	if err := json.Unmarshal([]byte(messages[0]), &snapshot); err != nil {
+		t.Fatalf("解析快照失败: %v", err)
+	}
+	if snapshot.ActiveConnections != 3 || snapshot.ActiveSessions != 2 || snapshot.TTSQueueDepth != 5 ||
+		snapshot.LLMInFlight != 4 || snapshot.ASRActive != 1 || snapshot.Timestamp.IsZero() {
+		t.Errorf("快照 = %+v", snapshot)
+	}
+
+	// 订阅者断开后取消订阅
+	clients[0].Close()
+	waitSubscribers(t, s.metricsHub, 1)
+}
@@ -0,0 +1,43 @@
+package admin
+
+import (
+	"net/http"
+
+	"angrymiao-ai-server/src/core/pool"
+	"angrymiao-ai-server/src/core/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetProviderReloader 设置资源池热升级的执行函数，返回升级后的资源池管理器
+func (s *AdminService) SetProviderReloader(reloader func() (*pool.PoolManager, error)) {
+	s.providerReloader = reloader
+}
+
+// handleReloadProviders 按最新配置重建资源池并原子替换
+// 活跃连接继续使用已借出的提供者，本轮对话结束后换用新资源池
+func (s *AdminService) handleReloadProviders(c *gin.Context) {
+	if s.providerReloader == nil {
+		utils.Custom(c, http.StatusServiceUnavailable, ProvidersReloadResponse{Success: false, Message: "资源池热升级不可用"})
+		return
+	}
+	if !s.reloadMu.TryLock() {
+		utils.Custom(c, http.StatusConflict, ProvidersReloadResponse{Success: false, Message: "资源池正在升级"})
+		return
+	}
+	defer s.reloadMu.Unlock()
+
+	pm, err := s.providerReloader()
+	if err != nil {
+		s.logger.Error("资源池热升级失败，继续使用原资源池: %v", err)
+		utils.Custom(c, http.StatusInternalServerError, ProvidersReloadResponse{Success: false, Message: "资源池热升级失败: " + err.Error()})
+		return
+	}
+	connections, _, _ := s.registry.LoadStats()
+	s.logger.Info("资源池已热升级到第%d代，%d 个活跃连接将在本轮对话结束后迁移", pm.Generation(), connections)
+	utils.Custom(c, http.StatusOK, ProvidersReloadResponse{
+		Success:           true,
+		Generation:        pm.Generation(),
+		PendingMigrations: connections,
+	})
+}
@@ -0,0 +1,351 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"angrymiao-ai-server/src/configs"
+	"angrymiao-ai-server/src/configs/cache"
+	"angrymiao-ai-server/src/configs/database"
+	"angrymiao-ai-server/src/core"
+	"angrymiao-ai-server/src/core/audit"
+	"angrymiao-ai-server/src/core/governor"
+	"angrymiao-ai-server/src/core/middleware"
+	"angrymiao-ai-server/src/core/pool"
+	"angrymiao-ai-server/src/core/transport"
+	"angrymiao-ai-server/src/core/utils"
+	"angrymiao-ai-server/src/httpsvr/device"
+	"angrymiao-ai-server/src/models"
+	"angrymiao-ai-server/src/task"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+// 强制关闭会话时等待连接协程退出的最长时间
+const terminateTimeout = 5 * time.Second
+
+// deviceGetter 查询设备信息
+type deviceGetter interface {
+	GetDevice(deviceID string) (*models.Device, error)
+}
+
+type AdminService struct {
+	logger   *utils.Logger
+	config   *configs.Config
+	registry *transport.SessionRegistry
+	devices  deviceGetter
+	otaQueue device.OTAQueue
+	auditDB  *gorm.DB // 工具调用审计日志所在数据库
+
+	poolStats  func() map[string]pool.PoolStats // 资源池统计来源，未设置时资源池相关指标为0
+	metricsHub *metricsHub
+
+	providerReloader func() (*pool.PoolManager, error) // 资源池热升级，未设置时接口不可用
+	reloadMu         sync.Mutex
+
+	dlq *task.DeadLetterQueue // 任务死信队列，未配置Redis时为nil
+
+	audioMonitor *redis.Client // 订阅会话下发音频，未配置Redis时为nil
+}
+
+// NewDefaultAdminService 构造函数
+func NewDefaultAdminService(config *configs.Config, logger *utils.Logger) *AdminService {
+	s := &AdminService{
+		logger:   logger,
+		config:   config,
+		registry: transport.GetSessionRegistry(),
+		devices:  device.NewDeviceDB(),
+		otaQueue: device.NewRedisOTAQueue(cache.GetRedis()),
+		auditDB:  database.GetDB(),
+
+		audioMonitor: cache.GetRedis(),
+	}
+	s.metricsHub = newMetricsHub(metricsPushInterval, s.collectMetrics)
+	return s
+}
+
+func (s *AdminService) Start(ctx context.Context, engine *gin.Engine, apiGroup *gin.RouterGroup) {
+	if err := core.RegisterAudioQualityMetrics(prometheus.DefaultRegisterer); err != nil {
+		s.logger.Warn("注册音频质量指标失败: %v", err)
+	}
+	if err := core.RegisterAudioCodecMetrics(prometheus.DefaultRegisterer); err != nil {
+		s.logger.Warn("注册音频编解码指标失败: %v", err)
+	}
+	if err := utils.RegisterAudioCleanupMetrics(prometheus.DefaultRegisterer); err != nil {
+		s.logger.Warn("注册音频清理指标失败: %v", err)
+	}
+	if err := core.RegisterSpeculativeASRMetrics(prometheus.DefaultRegisterer); err != nil {
+		s.logger.Warn("注册流式识别预计算指标失败: %v", err)
+	}
+	if err := governor.RegisterMetrics(prometheus.DefaultRegisterer); err != nil {
+		s.logger.Warn("注册连接数指标失败: %v", err)
+	}
+
+	// 实时指标推送，WebSocket 升级在 /api 之外
+	go s.metricsHub.run(ctx)
+	engine.GET("/ws/admin/metrics", middleware.AdminTokenAuth(s.config.Server.AdminToken), s.handleMetricsStream)
+	engine.GET("/ws/audio-stream/:session_id", middleware.AdminTokenAuth(s.config.Server.AdminToken), s.handleAudioStream)
+
+	adminGroup := apiGroup.Group("/admin").Use(middleware.AdminTokenAuth(s.config.Server.AdminToken))
+	{
+		adminGroup.GET("/sessions", s.handleListSessions)
+		adminGroup.DELETE("/sessions/:id", s.handleTerminateSession)
+		adminGroup.GET("/sessions/:id/audio_quality", s.handleGetAudioQuality)
+		adminGroup.GET("/sessions/:id/audio_stats", s.handleGetAudioCodecStats)
+		adminGroup.GET("/sessions/:id/state", s.handleGetSessionState)
+		adminGroup.GET("/sessions/:id/latency", s.handleGetAudioLatency)
+		adminGroup.POST("/sessions/:id/speak", s.handleSessionSpeak)
+		adminGroup.GET("/sessions/:id/failed-tts", s.handleListFailedTTS)
+		adminGroup.POST("/sessions/:id/retry-tts/:textIndex", s.handleRetryFailedTTS)
+		adminGroup.GET("/metrics", gin.WrapH(promhttp.Handler()))
+		adminGroup.POST("/devices/:device_id/ota", s.handlePushOTA)
+		adminGroup.GET("/audit-log", s.handleListAuditLog)
+		adminGroup.GET("/quick-reply-cache", s.handleQuickReplyCacheStats)
+		adminGroup.POST("/providers/reload", s.handleReloadProviders)
+		adminGroup.GET("/dlq", s.handleListDeadLetters)
+		adminGroup.POST("/dlq/:task_id/retry", s.handleRetryDeadLetter)
+	}
+}
+
+// handleListSessions 列出所有活跃会话
+func (s *AdminService) handleListSessions(c *gin.Context) {
+	sessions := s.registry.List()
+	utils.Custom(c, http.StatusOK, ListSessionsResponse{
+		Success:           true,
+		Sessions:          sessions,
+		Total:             len(sessions),
+		ActiveConnections: governor.Default().CurrentCount(),
+		MaxConnections:    governor.Default().MaxConnections(),
+	})
+}
+
+// handleQuickReplyCacheStats 获取快速回复缓存的文件数与总大小
+func (s *AdminService) handleQuickReplyCacheStats(c *gin.Context) {
+	entries, size := utils.NewQuickReplyCache("", "").CacheStats()
+	utils.Custom(c, http.StatusOK, QuickReplyCacheStatsResponse{Success: true, Entries: entries, TotalSizeBytes: size})
+}
+
+// handleTerminateSession 按会话列表中的连接ID强制关闭指定会话
+func (s *AdminService) handleTerminateSession(c *gin.Context) {
+	id := c.Param("id")
+	s.logger.Info("管理员强制关闭会话: %s", id)
+	if err := s.registry.Terminate(id, terminateTimeout); err != nil {
+		if errors.Is(err, transport.ErrSessionNotFound) {
+			utils.Custom(c, http.StatusNotFound, TerminateSessionResponse{Success: false, Message: err.Error()})
+			return
+		}
+		s.logger.Warn("强制关闭会话失败: %v", err)
+		utils.Custom(c, http.StatusGatewayTimeout, TerminateSessionResponse{Success: false, Message: err.Error()})
+		return
+	}
+	utils.Custom(c, http.StatusOK, TerminateSessionResponse{Success: true, Message: "会话已关闭"})
+}
+
+// handleGetAudioQuality 获取会话的实时音频质量指标，id 为连接ID或会话ID
+func (s *AdminService) handleGetAudioQuality(c *gin.Context) {
+	id := c.Param("id")
+	snapshot, err := s.registry.AudioQuality(id)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, transport.ErrSessionNotFound) {
+			status = http.StatusNotFound
+		}
+		utils.Custom(c, status, AudioQualityResponse{Success: false, Message: err.Error()})
+		return
+	}
+	utils.Custom(c, http.StatusOK, AudioQualityResponse{Success: true, AudioQuality: &snapshot})
+}
+
+// handleGetAudioCodecStats 获取会话Opus解码的压缩比、包数、平均帧大小与解码错误率，id 为连接ID或会话ID
+func (s *AdminService) handleGetAudioCodecStats(c *gin.Context) {
+	id := c.Param("id")
+	stats, err := s.registry.AudioCodecStats(id)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, transport.ErrSessionNotFound) {
+			status = http.StatusNotFound
+		}
+		utils.Custom(c, status, AudioCodecStatsResponse{Success: false, Message: err.Error()})
+		return
+	}
+	utils.Custom(c, http.StatusOK, AudioCodecStatsResponse{Success: true, AudioStats: &stats})
+}
+
+// handleGetSessionState 获取会话的对话轮次、拾音模式、提供者、队列长度等状态，用于排查卡住的会话，id 为连接ID或会话ID
+func (s *AdminService) handleGetSessionState(c *gin.Context) {
+	id := c.Param("id")
+	state, err := s.registry.SessionState(id)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, transport.ErrSessionNotFound) {
+			status = http.StatusNotFound
+		}
+		utils.Custom(c, status, SessionStateResponse{Success: false, Message: err.Error()})
+		return
+	}
+	utils.Custom(c, http.StatusOK, SessionStateResponse{Success: true, State: &state})
+}
+
+// handleGetAudioLatency 获取会话当前轮与上一轮各阶段延迟的 p50/p95/p99，id 为连接ID或会话ID
+func (s *AdminService) handleGetAudioLatency(c *gin.Context) {
+	id := c.Param("id")
+	report, err := s.registry.AudioLatency(id)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, transport.ErrSessionNotFound) {
+			status = http.StatusNotFound
+		}
+		utils.Custom(c, status, AudioLatencyResponse{Success: false, Message: err.Error()})
+		return
+	}
+	utils.Custom(c, http.StatusOK, AudioLatencyResponse{Success: true, Latency: &report})
+}
+
+// handleSessionSpeak 服务端主动向会话播报文本，id 为连接ID或会话ID
+// 会话正在对话时返回 queued=true，本轮结束后播报；每个会话60秒内只允许一条
+func (s *AdminService) handleSessionSpeak(c *gin.Context) {
+	id := c.Param("id")
+	var req SessionSpeakRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Custom(c, http.StatusBadRequest, SessionSpeakResponse{Success: false, Message: "请求参数错误: " + err.Error()})
+		return
+	}
+	queued, err := s.registry.ProactiveSpeak(id, req.Text, req.Voice)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, transport.ErrSessionNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, core.ErrProactiveRateLimited):
+			status = http.StatusTooManyRequests
+		}
+		utils.Custom(c, status, SessionSpeakResponse{Success: false, Message: err.Error()})
+		return
+	}
+	s.logger.Info("管理员向会话 %s 主动播报(queued=%t): %s", id, queued, req.Text)
+	utils.Custom(c, http.StatusOK, SessionSpeakResponse{Success: true, Queued: queued})
+}
+
+// handleListFailedTTS 列出会话最近合成失败的TTS分段，id 为连接ID或会话ID
+func (s *AdminService) handleListFailedTTS(c *gin.Context) {
+	id := c.Param("id")
+	entries, err := s.registry.FailedTTS(id)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, transport.ErrSessionNotFound) {
+			status = http.StatusNotFound
+		}
+		utils.Custom(c, status, FailedTTSResponse{Success: false, Message: err.Error()})
+		return
+	}
+	utils.Custom(c, http.StatusOK, FailedTTSResponse{Success: true, Entries: entries, Total: len(entries)})
+}
+
+// handleRetryFailedTTS 重新合成会话中指定索引的失败TTS分段，成功后发送给客户端
+func (s *AdminService) handleRetryFailedTTS(c *gin.Context) {
+	id := c.Param("id")
+	textIndex, err := strconv.Atoi(c.Param("textIndex"))
+	if err != nil {
+		utils.Custom(c, http.StatusBadRequest, RetryTTSResponse{Success: false, Message: "textIndex 参数错误"})
+		return
+	}
+	if err := s.registry.RetryFailedTTS(id, textIndex); err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, transport.ErrSessionNotFound), errors.Is(err, core.ErrFailedTTSNotFound):
+			status = http.StatusNotFound
+		}
+		utils.Custom(c, status, RetryTTSResponse{Success: false, Message: err.Error()})
+		return
+	}
+	s.logger.Info("管理员重试会话 %s 失败的TTS分段: %d", id, textIndex)
+	utils.Custom(c, http.StatusOK, RetryTTSResponse{Success: true, Message: "已重新合成并加入发送队列"})
+}
+
+// handlePushOTA 向设备推送固件升级消息，设备离线时保存到Redis待上线后推送
+func (s *AdminService) handlePushOTA(c *gin.Context) {
+	deviceID := c.Param("device_id")
+	var req PushOTARequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Custom(c, http.StatusBadRequest, PushOTAResponse{Success: false, Message: "请求参数错误: " + err.Error()})
+		return
+	}
+
+	autoApprove := false
+	if dev, err := s.devices.GetDevice(deviceID); err == nil {
+		autoApprove = dev.OTAAutoApprove
+	}
+	data, err := json.Marshal(device.NewOTAMessage(req.Version, req.URL, req.Checksum, autoApprove))
+	if err != nil {
+		utils.Custom(c, http.StatusInternalServerError, PushOTAResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	delivered, err := s.registry.SendToDevice(deviceID, 1, data)
+	if delivered > 0 {
+		s.logger.Info("OTA消息已推送: device=%s, version=%s, 会话数=%d", deviceID, req.Version, delivered)
+		utils.Custom(c, http.StatusOK, PushOTAResponse{Success: true, Delivered: true})
+		return
+	}
+	if err != nil {
+		s.logger.Warn("OTA消息推送失败，转为离线保存: device=%s, %v", deviceID, err)
+	}
+
+	if err := s.otaQueue.Enqueue(c.Request.Context(), deviceID, data); err != nil {
+		s.logger.Error("保存离线OTA消息失败: device=%s, %v", deviceID, err)
+		utils.Custom(c, http.StatusServiceUnavailable, PushOTAResponse{Success: false, Message: "设备离线且保存OTA消息失败"})
+		return
+	}
+	s.logger.Info("设备离线，OTA消息已保存: device=%s, version=%s", deviceID, req.Version)
+	utils.Custom(c, http.StatusOK, PushOTAResponse{Success: true, Delivered: false, Queued: true})
+}
+
+// handleListAuditLog 分页查询工具调用审计日志
+// 支持 function_name、user_id、from、to(RFC3339)、page、limit 过滤
+func (s *AdminService) handleListAuditLog(c *gin.Context) {
+	if s.auditDB == nil {
+		utils.Custom(c, http.StatusServiceUnavailable, AuditLogResponse{Success: false, Message: "数据库未初始化"})
+		return
+	}
+	filter := audit.QueryFilter{FunctionName: c.Query("function_name")}
+	if v := c.Query("user_id"); v != "" {
+		userID, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			utils.Custom(c, http.StatusBadRequest, AuditLogResponse{Success: false, Message: "user_id 格式错误"})
+			return
+		}
+		filter.UserID = uint(userID)
+	}
+	for _, p := range []struct {
+		name string
+		dst  *time.Time
+	}{{"from", &filter.From}, {"to", &filter.To}} {
+		if v := c.Query(p.name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				utils.Custom(c, http.StatusBadRequest, AuditLogResponse{Success: false, Message: p.name + " 需为RFC3339时间"})
+				return
+			}
+			*p.dst = t
+		}
+	}
+	filter.Page, _ = strconv.Atoi(c.Query("page"))
+	filter.Limit, _ = strconv.Atoi(c.Query("limit"))
+
+	logs, total, err := audit.Query(s.auditDB, filter)
+	if err != nil {
+		s.logger.Error("查询审计日志失败: %v", err)
+		utils.Custom(c, http.StatusInternalServerError, AuditLogResponse{Success: false, Message: "查询审计日志失败"})
+		return
+	}
+	utils.Custom(c, http.StatusOK, AuditLogResponse{Success: true, Logs: logs, Total: total})
+}
@@ -0,0 +1,235 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"angrymiao-ai-server/src/core"
+	"angrymiao-ai-server/src/core/transport"
+	"angrymiao-ai-server/src/core/utils"
+	"angrymiao-ai-server/src/httpsvr/device"
+	"angrymiao-ai-server/src/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// mockConn 记录服务端推送的消息
+type mockConn struct {
+	mu       sync.Mutex
+	messages [][]byte
+}
+
+func (m *mockConn) Handle()              {}
+func (m *mockConn) Close()               {}
+func (m *mockConn) GetSessionID() string { return "s1" }
+func (m *mockConn) WriteMessage(messageType int, data []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, data)
+	return nil
+}
+
+// qualityConn 提供固定的音频质量指标
+type qualityConn struct {
+	mockConn
+	snapshot core.AudioQualitySnapshot
+}
+
+func (q *qualityConn) AudioQuality() core.AudioQualitySnapshot { return q.snapshot }
+
+type mockOTAQueue struct {
+	pending map[string][]byte
+}
+
+func (q *mockOTAQueue) Enqueue(ctx context.Context, deviceID string, data []byte) error {
+	q.pending[deviceID] = data
+	return nil
+}
+
+func (q *mockOTAQueue) Pop(ctx context.Context, deviceID string) ([]byte, error) {
+	data := q.pending[deviceID]
+	delete(q.pending, deviceID)
+	return data, nil
+}
+
+type mockDevices map[string]*models.Device
+
+func (d mockDevices) GetDevice(deviceID string) (*models.Device, error) {
+	if dev, ok := d[deviceID]; ok {
+		return dev, nil
+	}
+	return nil, errors.New("设备不存在")
+}
+
+func pushOTA(t *testing.T, s *AdminService, deviceID string) PushOTAResponse {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.POST("/admin/devices/:device_id/ota", s.handlePushOTA)
+
+	body := `{"version":"1.2.3","url":"https://example.com/fw.bin","checksum":"sha256:abc"}`
+	req := httptest.NewRequest(http.MethodPost, "/admin/devices/"+deviceID+"/ota", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("状态码 = %d, 响应: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Data PushOTAResponse `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("解析响应失败: %v", err)
+	}
+	return resp.Data
+}
+
+func newTestAdminService(t *testing.T) (*AdminService, *mockOTAQueue) {
+	logger, err := utils.NewLogger(&utils.LogCfg{LogLevel: "ERROR", LogDir: t.TempDir(), LogFile: "test.log"})
+	if err != nil {
+		t.Fatalf("创建日志失败: %v", err)
+	}
+	queue := &mockOTAQueue{pending: make(map[string][]byte)}
+	return &AdminService{
+		logger:   logger,
+		registry: &transport.SessionRegistry{},
+		devices:  mockDevices{"dev-1": {DeviceID: "dev-1", OTAAutoApprove: true}},
+		otaQueue: queue,
+	}, queue
+}
+
+func TestPushOTADelivered(t *testing.T) {
+	s, queue := newTestAdminService(t)
+	conn := &mockConn{}
+	s.registry.Register(transport.SessionSummary{ID: "c1", SessionID: "s1", DeviceID: "dev-1"}, conn)
+
+	resp := pushOTA(t, s, "dev-1")
+	if !resp.Delivered || resp.Queued {
+		t.Fatalf("响应 = %+v, 期望已送达", resp)
+	}
+	if len(conn.messages) != 1 {
+		t.Fatalf("推送消息数 = %d, 期望 1", len(conn.messages))
+	}
+	var msg device.OTAMessage
+	if err := json.Unmarshal(conn.messages[0], &msg); err != nil {
+		t.Fatalf("解析OTA消息失败: %v", err)
+	}
+	if msg.Type != "ota" || msg.Version != "1.2.3" || msg.Checksum != "sha256:abc" || !msg.AutoApprove {
+		t.Errorf("OTA消息 = %+v", msg)
+	}
+	if len(queue.pending) != 0 {
+		t.Errorf("在线设备不应保存离线消息")
+	}
+}
+
+func TestPushOTAQueuedWhenOffline(t *testing.T) {
+	s, queue := newTestAdminService(t)
+
+	resp := pushOTA(t, s, "dev-2")
+	if resp.Delivered || !resp.Queued {
+		t.Fatalf("响应 = %+v, 期望已保存待推送", resp)
+	}
+	var msg device.OTAMessage
+	if err := json.Unmarshal(queue.pending["dev-2"], &msg); err != nil {
+		t.Fatalf("解析离线OTA消息失败: %v", err)
+	}
+	if msg.URL != "https://example.com/fw.bin" || msg.AutoApprove {
+		t.Errorf("离线OTA消息 = %+v", msg)
+	}
+}
+
+func TestGetAudioQuality(t *testing.T) {
+	s, _ := newTestAdminService(t)
+	conn := &qualityConn{snapshot: core.AudioQualitySnapshot{SessionID: "s1", FramesReceived: 9, FramesDropped: 1, PacketLossRate: 0.1}}
+	s.registry.Register(transport.SessionSummary{ID: "c1", SessionID: "s1", DeviceID: "dev-1"}, conn)
+
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.GET("/admin/sessions/:id/audio_quality", s.handleGetAudioQuality)
+
+	// 按连接ID或会话ID均可查询
+	for _, id := range []string{"c1", "s1"} {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/sessions/"+id+"/audio_quality", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("状态码 = %d, 响应: %s", w.Code, w.Body.String())
+		}
+		var resp struct {
+			Data AudioQualityResponse `json:"data"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("解析响应失败: %v", err)
+		}
+		if resp.Data.AudioQuality == nil || *resp.Data.AudioQuality != conn.snapshot {
+			t.Errorf("音频质量 = %+v, 期望 %+v", resp.Data.AudioQuality, conn.snapshot)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/sessions/unknown/audio_quality", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("未知会话状态码 = %d, 期望 404", w.Code)
+	}
+}
+
+// stateConn 提供固定的会话状态
+type stateConn struct {
+	mockConn
+	state core.SessionStateSnapshot
+}
+
+func (s *stateConn) SessionState() core.SessionStateSnapshot { return s.state }
+
+func TestGetSessionState(t *testing.T) {
+	s, _ := newTestAdminService(t)
+	conn := &stateConn{state: core.SessionStateSnapshot{
+		SessionID:        "s1",
+		TalkRound:        3,
+		ClientListenMode: "manual",
+		EnableVAD:        true,
+		ServerVoiceStop:  true,
+		Providers:        map[string]core.SessionProviderInfo{"TTS": {Name: "EdgeTTS", Type: "*edge.Provider"}},
+		QueueDepths:      core.SessionQueueDepths{ClientAudioQueue: 5, TTSQueue: 2},
+		DialogueTurns:    7,
+		ActiveTools:      []string{"get_weather"},
+	}}
+	s.registry.Register(transport.SessionSummary{ID: "c1", SessionID: "s1", DeviceID: "dev-1"}, conn)
+
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.GET("/admin/sessions/:id/state", s.handleGetSessionState)
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/sessions/s1/state", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("状态码 = %d, 响应: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Data SessionStateResponse `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("解析响应失败: %v", err)
+	}
+	state := resp.Data.State
+	if state == nil || state.TalkRound != 3 || state.ClientListenMode != "manual" || !state.EnableVAD || !state.ServerVoiceStop || state.CloseAfterChat {
+		t.Fatalf("会话状态 = %+v", state)
+	}
+	if state.QueueDepths != conn.state.QueueDepths || state.DialogueTurns != 7 || len(state.ActiveTools) != 1 || state.ActiveTools[0] != "get_weather" {
+		t.Errorf("队列与对话状态 = %+v", state)
+	}
+	if state.Providers["TTS"].Name != "EdgeTTS" || state.Providers["TTS"].Type != "*edge.Provider" {
+		t.Errorf("提供者 = %+v", state.Providers)
+	}
+
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/sessions/unknown/state", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("未知会话状态码 = %d, 期望 404", w.Code)
+	}
+}
@@ -0,0 +1,136 @@
+package admin
+
+import (
+	"time"
+
+	"angrymiao-ai-server/src/core"
+	"angrymiao-ai-server/src/core/transport"
+	"angrymiao-ai-server/src/models"
+	"angrymiao-ai-server/src/task"
+)
+
+type ListSessionsResponse struct {
+	Success  bool                       `json:"success"`
+	Message  string                     `json:"message,omitempty"`
+	Sessions []transport.SessionSummary `json:"sessions"`
+	Total    int                        `json:"total"`
+	// 所有传输层的连接总数及上限，上限为0表示不限制
+	ActiveConnections int64 `json:"active_connections"`
+	MaxConnections    int   `json:"max_connections"`
+}
+
+type TerminateSessionResponse struct {
+	Success bool   `json:"success"`
+	Message string `json:"message"`
+}
+
+type AudioQualityResponse struct {
+	Success      bool                       `json:"success"`
+	Message      string                     `json:"message,omitempty"`
+	AudioQuality *core.AudioQualitySnapshot `json:"audio_quality,omitempty"`
+}
+
+type AudioCodecStatsResponse struct {
+	Success    bool                     `json:"success"`
+	Message    string                   `json:"message,omitempty"`
+	AudioStats *core.AudioCodecSnapshot `json:"audio_stats,omitempty"`
+}
+
+type SessionStateResponse struct {
+	Success bool                       `json:"success"`
+	Message string                     `json:"message,omitempty"`
+	State   *core.SessionStateSnapshot `json:"state,omitempty"`
+}
+
+type QuickReplyCacheStatsResponse struct {
+	Success        bool  `json:"success"`
+	Entries        int   `json:"entries"`
+	TotalSizeBytes int64 `json:"total_size_bytes"`
+}
+
+type AudioLatencyResponse struct {
+	Success bool                     `json:"success"`
+	Message string                   `json:"message,omitempty"`
+	Latency *core.AudioLatencyReport `json:"latency,omitempty"`
+}
+
+// SessionSpeakRequest 服务端主动播报请求，voice 为空时使用会话当前音色
+type SessionSpeakRequest struct {
+	Text  string `json:"text" binding:"required"`
+	Voice string `json:"voice"`
+}
+
+type SessionSpeakResponse struct {
+	Success bool   `json:"success"`
+	Message string `json:"message,omitempty"`
+	Queued  bool   `json:"queued"` // 会话正在对话，本轮结束后播报
+}
+
+// FailedTTSResponse 会话最近合成失败的TTS分段
+type FailedTTSResponse struct {
+	Success bool                  `json:"success"`
+	Message string                `json:"message,omitempty"`
+	Entries []core.FailedTTSEntry `json:"entries"`
+	Total   int                   `json:"total"`
+}
+
+type RetryTTSResponse struct {
+	Success bool   `json:"success"`
+	Message string `json:"message,omitempty"`
+}
+
+// PushOTARequest 推送OTA升级请求
+type PushOTARequest struct {
+	Version  string `json:"version" binding:"required"`
+	URL      string `json:"url" binding:"required"`
+	Checksum string `json:"checksum" binding:"required"` // 格式如 sha256:...
+}
+
+type PushOTAResponse struct {
+	Success   bool   `json:"success"`
+	Message   string `json:"message,omitempty"`
+	Delivered bool   `json:"delivered"`
+	Queued    bool   `json:"queued,omitempty"` // 设备离线，消息已保存待上线后推送
+}
+
+type AuditLogResponse struct {
+	Success bool                          `json:"success"`
+	Message string                        `json:"message,omitempty"`
+	Logs    []models.FunctionCallAuditLog `json:"logs,omitempty"`
+	Total   int64                         `json:"total"`
+}
+
+// MetricsSnapshot 实时推送的服务负载快照
+type MetricsSnapshot struct {
+	ActiveConnections int       `json:"active_connections"`
+	ActiveSessions    int       `json:"active_sessions"`
+	TTSQueueDepth     int       `json:"tts_queue_depth"` // 所有会话等待合成的TTS任务数
+	LLMInFlight       int       `json:"llm_in_flight"`   // LLM资源池借出数量
+	ASRActive         int       `json:"asr_active"`      // ASR资源池借出数量
+	Timestamp         time.Time `json:"timestamp"`
+}
+
+type ProvidersReloadResponse struct {
+	Success           bool   `json:"success"`
+	Message           string `json:"message,omitempty"`
+	Generation        uint64 `json:"generation,omitempty"` // 升级后资源池的代数
+	PendingMigrations int    `json:"pending_migrations"`   // 升级时的活跃连接数，均在本轮对话结束后迁移
+}
+
+type DeadLetterListResponse struct {
+	Success bool                   `json:"success"`
+	Message string                 `json:"message,omitempty"`
+	Entries []task.DeadLetterEntry `json:"entries,omitempty"`
+	Total   int                    `json:"total"`
+}
+
+type DeadLetterRetryResponse struct {
+	Success bool   `json:"success"`
+	Message string `json:"message,omitempty"`
+	TaskID  string `json:"task_id,omitempty"`
+}
+
+type AudioStreamResponse struct {
+	Success bool   `json:"success"`
+	Message string `json:"message,omitempty"`
+}
@@ -0,0 +1,70 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"angrymiao-ai-server/src/configs/database"
+	"angrymiao-ai-server/src/core/botconfig"
+	"angrymiao-ai-server/src/core/chat"
+	"angrymiao-ai-server/src/core/utils"
+	"angrymiao-ai-server/src/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// handleDeleteChatHistory 删除用户的对话历史
+// bot 为Bot好友的 FunctionName，指定时只删除与该Bot的独立对话，否则删除主会话与所有Bot对话；
+// soft=true 时只标记删除时间，不物理删除
+func (s *AppService) handleDeleteChatHistory(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	bot := strings.TrimSpace(c.Query("bot"))
+	soft := c.Query("soft") == "true"
+
+	db := database.GetDB()
+	if db == nil {
+		utils.Custom(c, http.StatusInternalServerError, ChatHistoryDeleteResponse{Success: false, Message: "数据库未初始化"})
+		return
+	}
+	deleted, err := deleteDialogueHistory(db.WithContext(c.Request.Context()), fmt.Sprintf("%d", userID), bot, soft)
+	if err != nil {
+		s.logger.Error("用户 %d 删除对话历史失败: %v", userID, err)
+		utils.Custom(c, http.StatusInternalServerError, ChatHistoryDeleteResponse{Success: false, Message: "删除失败"})
+		return
+	}
+
+	s.logger.Info("用户 %d 删除对话历史 %d 条, bot=%q, soft=%v", userID, deleted, bot, soft)
+	s.notifyContextCleared(c.Request.Context(), userID)
+	// 当前没有对话快照存储，deleted_snapshots 固定为0
+	utils.Custom(c, http.StatusOK, ChatHistoryDeleteResponse{Success: true, DeletedMessages: deleted})
+}
+
+// deleteDialogueHistory 删除用户的对话消息，返回删除的条数
+// bot 为空时同时删除 "userID:botName" 下的所有Bot对话
+func deleteDialogueHistory(db *gorm.DB, userID, bot string, soft bool) (int64, error) {
+	q := db.Model(&models.DialogueMessage{})
+	if bot != "" {
+		q = q.Where("user_id = ?", chat.DialogueNamespace(userID, bot))
+	} else {
+		q = q.Where("user_id = ? OR user_id LIKE ?", userID, chat.DialogueNamespace(userID, "%"))
+	}
+	if !soft {
+		q = q.Unscoped()
+	}
+	result := q.Delete(&models.DialogueMessage{})
+	return result.RowsAffected, result.Error
+}
+
+// notifyContextCleared 通知用户的在线连接对话历史已删除
+func (s *AppService) notifyContextCleared(ctx context.Context, userID uint) {
+	notifier, ok := botconfig.GetUpdateNotifier().(botconfig.ContextClearNotifier)
+	if !ok {
+		return
+	}
+	if err := notifier.NotifyContextCleared(ctx, fmt.Sprintf("%d", userID)); err != nil {
+		s.logger.Warn("通知用户 %d 对话历史删除失败: %v", userID, err)
+	}
+}
@@ -0,0 +1,127 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"angrymiao-ai-server/src/configs"
+	"angrymiao-ai-server/src/configs/database"
+	"angrymiao-ai-server/src/core/botconfig"
+	"angrymiao-ai-server/src/core/utils"
+	"angrymiao-ai-server/src/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// clearRecorder 记录对话历史删除通知
+type clearRecorder struct {
+	botconfig.UpdateNotifier
+	mu      sync.Mutex
+	cleared []string
+}
+
+func (n *clearRecorder) NotifyContextCleared(ctx context.Context, userID string) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.cleared = append(n.cleared, userID)
+	return nil
+}
+
+func (n *clearRecorder) SubscribeContextCleared(ctx context.Context, userID string) (<-chan struct{}, func(), error) {
+	return nil, func() {}, nil
+}
+
+func TestHandleDeleteChatHistory(t *testing.T) {
+	logger, err := utils.NewLogger(&utils.LogCfg{LogLevel: "ERROR", LogDir: t.TempDir(), LogFile: "test.log"})
+	if err != nil {
+		t.Fatalf("创建日志失败: %v", err)
+	}
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "history.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("打开测试数据库失败: %v", err)
+	}
+	if err := db.AutoMigrate(&models.DialogueMessage{}); err != nil {
+		t.Fatalf("迁移失败: %v", err)
+	}
+	original := database.DB
+	database.DB = db
+	t.Cleanup(func() { database.DB = original })
+	notifier := &clearRecorder{}
+	botconfig.SetUpdateNotifier(notifier)
+	t.Cleanup(func() { botconfig.SetUpdateNotifier(nil) })
+
+	rows := []models.DialogueMessage{
+		{UserID: "7", Role: "user", Content: "你好"},
+		{UserID: "7", Role: "assistant", Content: "你好呀"},
+		{UserID: "7:weather", Role: "user", Content: "明天下雨吗"},
+		{UserID: "7:music", Role: "user", Content: "放首歌"},
+		{UserID: "70", Role: "user", Content: "其他用户"},
+		{UserID: "8", Role: "user", Content: "别人的消息"},
+	}
+	if err := db.Create(&rows).Error; err != nil {
+		t.Fatalf("写入消息失败: %v", err)
+	}
+
+	s := &AppService{logger: logger, config: &configs.Config{}}
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.DELETE("/api/v2/chat/history", func(c *gin.Context) { c.Set("user_id", uint(7)) }, s.handleDeleteChatHistory)
+	del := func(query string) ChatHistoryDeleteResponse {
+		t.Helper()
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/api/v2/chat/history"+query, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("DELETE %s 状态码 = %d", query, w.Code)
+		}
+		var body struct {
+			Data ChatHistoryDeleteResponse `json:"data"`
+		}
+		_ = json.Unmarshal(w.Body.Bytes(), &body)
+		return body.Data
+	}
+	countAll := func(userID string) (live, all int64) {
+		db.Model(&models.DialogueMessage{}).Where("user_id = ?", userID).Count(&live)
+		db.Unscoped().Model(&models.DialogueMessage{}).Where("user_id = ?", userID).Count(&all)
+		return live, all
+	}
+
+	// 软删除只删除指定Bot的对话，记录仍保留在表中
+	if got := del("?bot=weather&soft=true"); got.DeletedMessages != 1 || got.DeletedSnapshots != 0 {
+		t.Errorf("软删除结果 = %+v", got)
+	}
+	if live, all := countAll("7:weather"); live != 0 || all != 1 {
+		t.Errorf("软删除后 可见=%d 总数=%d, 期望 0 和 1", live, all)
+	}
+	if live, _ := countAll("7"); live != 2 {
+		t.Errorf("主会话消息不应被删除, 剩余 %d", live)
+	}
+
+	// 物理删除主会话与所有Bot对话，包括已软删除的记录
+	if got := del(""); got.DeletedMessages != 4 {
+		t.Errorf("删除条数 = %d, 期望 4", got.DeletedMessages)
+	}
+	for _, userID := range []string{"7", "7:weather", "7:music"} {
+		if _, all := countAll(userID); all != 0 {
+			t.Errorf("%s 仍有 %d 条记录", userID, all)
+		}
+	}
+	for _, userID := range []string{"70", "8"} {
+		if live, _ := countAll(userID); live != 1 {
+			t.Errorf("其他用户 %s 的消息被删除", userID)
+		}
+	}
+
+	// 每次删除都通知在线连接
+	notifier.mu.Lock()
+	defer notifier.mu.Unlock()
+	if len(notifier.cleared) != 2 || notifier.cleared[0] != "7" {
+		t.Errorf("删除通知 = %v, 期望通知用户7两次", notifier.cleared)
+	}
+}
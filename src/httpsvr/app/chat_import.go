@@ -0,0 +1,119 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"angrymiao-ai-server/src/configs/database"
+	"angrymiao-ai-server/src/core/botconfig"
+	"angrymiao-ai-server/src/core/utils"
+	"angrymiao-ai-server/src/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	maxImportMessages      = 500
+	maxImportContentLength = 2048
+	importBatchSize        = 100
+)
+
+// handleChatImport 导入外部对话记录，追加到用户已有的对话历史之后
+func (s *AppService) handleChatImport(c *gin.Context) {
+	var items []ChatImportMessage
+	if err := c.ShouldBindJSON(&items); err != nil {
+		utils.Custom(c, http.StatusBadRequest, ChatImportResponse{Success: false, Message: "请求参数错误: " + err.Error()})
+		return
+	}
+
+	userID := c.GetUint("user_id")
+	rows, err := buildImportRows(fmt.Sprintf("%d", userID), items, time.Now(), s.config.ImportAllowFutureTimestamps)
+	if err != nil {
+		utils.Custom(c, http.StatusBadRequest, ChatImportResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		utils.Custom(c, http.StatusInternalServerError, ChatImportResponse{Success: false, Message: "数据库未初始化"})
+		return
+	}
+	imported, err := importDialogueMessages(db.WithContext(c.Request.Context()), rows)
+	if err != nil {
+		s.logger.Error("用户 %d 导入对话记录失败: %v", userID, err)
+		utils.Custom(c, http.StatusInternalServerError, ChatImportResponse{Success: false, Message: "导入失败"})
+		return
+	}
+
+	s.logger.Info("用户 %d 导入对话记录 %d 条", userID, imported)
+	s.notifyContextUpdate(c.Request.Context(), userID)
+	utils.Custom(c, http.StatusOK, ChatImportResponse{Success: true, Imported: imported})
+}
+
+// buildImportRows 校验导入的消息并转换为数据库记录，任一消息不合法时整体拒绝
+// allowFuture 为 false 时拒绝晚于 now 的时间戳
+func buildImportRows(userID string, items []ChatImportMessage, now time.Time, allowFuture bool) ([]models.DialogueMessage, error) {
+	if len(items) == 0 {
+		return nil, fmt.Errorf("导入的消息不能为空")
+	}
+	if len(items) > maxImportMessages {
+		return nil, fmt.Errorf("单次最多导入%d条消息", maxImportMessages)
+	}
+
+	rows := make([]models.DialogueMessage, 0, len(items))
+	for i, item := range items {
+		switch item.Role {
+		case "user", "assistant", "system":
+		default:
+			return nil, fmt.Errorf("第%d条消息的role不合法: %q", i+1, item.Role)
+		}
+		if strings.TrimSpace(item.Content) == "" {
+			return nil, fmt.Errorf("第%d条消息的content不能为空", i+1)
+		}
+		if utf8.RuneCountInString(item.Content) > maxImportContentLength {
+			return nil, fmt.Errorf("第%d条消息的content超过%d个字符", i+1, maxImportContentLength)
+		}
+		createdAt, err := time.Parse(time.RFC3339, item.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("第%d条消息的created_at不是RFC3339格式: %q", i+1, item.CreatedAt)
+		}
+		if !allowFuture && createdAt.After(now) {
+			return nil, fmt.Errorf("第%d条消息的created_at晚于当前时间: %s", i+1, item.CreatedAt)
+		}
+
+		rows = append(rows, models.DialogueMessage{
+			UserID:    userID,
+			Role:      item.Role,
+			Content:   item.Content,
+			CreatedAt: createdAt,
+		})
+	}
+	return rows, nil
+}
+
+// importDialogueMessages 在一个事务中分批写入导入的消息，不删除已有记录
+func importDialogueMessages(db *gorm.DB, rows []models.DialogueMessage) (int, error) {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		return tx.CreateInBatches(&rows, importBatchSize).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(rows), nil
+}
+
+// notifyContextUpdate 通知用户的在线连接对话上下文已变更
+func (s *AppService) notifyContextUpdate(ctx context.Context, userID uint) {
+	notifier, ok := botconfig.GetUpdateNotifier().(botconfig.ContextNotifier)
+	if !ok {
+		return
+	}
+	if err := notifier.NotifyContextUpdate(ctx, fmt.Sprintf("%d", userID)); err != nil {
+		s.logger.Warn("通知用户 %d 对话上下文变更失败: %v", userID, err)
+	}
+}
@@ -0,0 +1,132 @@
+package app
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"angrymiao-ai-server/src/models"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestBuildImportRowsValidation(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	valid := ChatImportMessage{Role: "user", Content: "你好", CreatedAt: "2025-05-01T08:00:00Z"}
+
+	tooMany := make([]ChatImportMessage, maxImportMessages+1)
+	for i := range tooMany {
+		tooMany[i] = valid
+	}
+
+	tests := []struct {
+		name    string
+		items   []ChatImportMessage
+		wantErr string
+	}{
+		{"空列表", nil, "不能为空"},
+		{"超过条数上限", tooMany, "最多导入"},
+		{"未知role", []ChatImportMessage{valid, {Role: "tool", Content: "x", CreatedAt: valid.CreatedAt}}, "第2条消息的role"},
+		{"空content", []ChatImportMessage{{Role: "user", Content: "  ", CreatedAt: valid.CreatedAt}}, "content不能为空"},
+		{"content超长", []ChatImportMessage{{Role: "assistant", Content: strings.Repeat("好", maxImportContentLength+1), CreatedAt: valid.CreatedAt}}, "超过2048个字符"},
+		{"缺少时间戳", []ChatImportMessage{{Role: "user", Content: "x"}}, "RFC3339"},
+		{"非RFC3339时间戳", []ChatImportMessage{{Role: "user", Content: "x", CreatedAt: "2025-05-01 08:00:00"}}, "RFC3339"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := buildImportRows("1", tt.items, now, false)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("错误 = %v, 期望包含 %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	// 按字符而非字节计算长度
+	rows, err := buildImportRows("1", []ChatImportMessage{{Role: "system", Content: strings.Repeat("好", maxImportContentLength), CreatedAt: valid.CreatedAt}}, now, false)
+	if err != nil || len(rows) != 1 {
+		t.Fatalf("2048个字符的消息应通过校验: %v", err)
+	}
+}
+
+func TestBuildImportRowsTimestamps(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name        string
+		createdAt   string
+		allowFuture bool
+		wantErr     bool
+		want        time.Time
+	}{
+		{"等于当前时间", "2025-06-01T12:00:00Z", false, false, now},
+		{"晚于当前时间1秒", "2025-06-01T12:00:01Z", false, true, time.Time{}},
+		{"配置允许未来时间", "2030-01-01T00:00:00Z", true, false, time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)},
+		// 按绝对时间比较，+08:00 的 19:59 早于 UTC 12:00
+		{"带时区偏移", "2025-06-01T19:59:00+08:00", false, false, time.Date(2025, 6, 1, 11, 59, 0, 0, time.UTC)},
+		{"带时区偏移且晚于当前时间", "2025-06-01T08:30:00-04:00", false, true, time.Time{}},
+		{"小数秒", "2025-06-01T11:59:59.123Z", false, false, time.Date(2025, 6, 1, 11, 59, 59, 123000000, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, err := buildImportRows("1", []ChatImportMessage{{Role: "user", Content: "x", CreatedAt: tt.createdAt}}, now, tt.allowFuture)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("时间戳 %s 应被拒绝", tt.createdAt)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("时间戳 %s 校验失败: %v", tt.createdAt, err)
+			}
+			if !rows[0].CreatedAt.Equal(tt.want) {
+				t.Errorf("CreatedAt = %v, 期望 %v", rows[0].CreatedAt, tt.want)
+			}
+		})
+	}
+}
+
+func TestImportDialogueMessagesAppends(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "import.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("打开测试数据库失败: %v", err)
+	}
+	if err := db.AutoMigrate(&models.DialogueMessage{}); err != nil {
+		t.Fatalf("迁移失败: %v", err)
+	}
+	existing := models.DialogueMessage{UserID: "7", Role: "user", Content: "已有消息"}
+	if err := db.Create(&existing).Error; err != nil {
+		t.Fatalf("写入已有消息失败: %v", err)
+	}
+
+	now := time.Now()
+	items := []ChatImportMessage{
+		{Role: "user", Content: "旧系统的问题", CreatedAt: now.Add(-2 * time.Hour).Format(time.RFC3339)},
+		{Role: "assistant", Content: "旧系统的回答", CreatedAt: now.Add(-time.Hour).Format(time.RFC3339)},
+	}
+	rows, err := buildImportRows("7", items, now, false)
+	if err != nil {
+		t.Fatalf("校验失败: %v", err)
+	}
+	imported, err := importDialogueMessages(db, rows)
+	if err != nil {
+		t.Fatalf("导入失败: %v", err)
+	}
+	if imported != 2 {
+		t.Errorf("导入条数 = %d, 期望 2", imported)
+	}
+
+	var stored []models.DialogueMessage
+	if err := db.Where("user_id = ?", "7").Order("created_at ASC").Find(&stored).Error; err != nil {
+		t.Fatalf("查询失败: %v", err)
+	}
+	if len(stored) != 3 {
+		t.Fatalf("记录数 = %d, 期望已有1条加导入2条", len(stored))
+	}
+	if stored[0].Content != "旧系统的问题" || stored[1].Role != "assistant" || stored[2].Content != "已有消息" {
+		t.Errorf("按时间排序的记录不符合预期: %+v", stored)
+	}
+	if got := stored[0].CreatedAt.Unix(); got != now.Add(-2*time.Hour).Unix() {
+		t.Errorf("应保留导入的时间戳, CreatedAt = %v", stored[0].CreatedAt)
+	}
+}
@@ -0,0 +1,168 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"angrymiao-ai-server/src/configs/database"
+	"angrymiao-ai-server/src/core/utils"
+	"angrymiao-ai-server/src/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+const (
+	maxTagsPerMessage = 5
+	maxTagLength      = 30
+)
+
+var (
+	tagPattern = regexp.MustCompile(`^[A-Za-z0-9-]+$`)
+
+	errMessageNotFound = errors.New("消息不存在")
+)
+
+// handleSetMessageTags 设置单条消息的标签（整体覆盖）
+func (s *AppService) handleSetMessageTags(c *gin.Context) {
+	messageID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.Custom(c, http.StatusBadRequest, ChatTagsResponse{Success: false, Message: "无效的消息ID"})
+		return
+	}
+	var req ChatTagsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Custom(c, http.StatusBadRequest, ChatTagsResponse{Success: false, Message: "请求参数错误: " + err.Error()})
+		return
+	}
+	tags, err := validateTags(req.Tags)
+	if err != nil {
+		utils.Custom(c, http.StatusBadRequest, ChatTagsResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		utils.Custom(c, http.StatusInternalServerError, ChatTagsResponse{Success: false, Message: "数据库未初始化"})
+		return
+	}
+	userID := c.GetUint("user_id")
+	if err := setMessageTags(db.WithContext(c.Request.Context()), fmt.Sprintf("%d", userID), uint(messageID), tags); err != nil {
+		if errors.Is(err, errMessageNotFound) {
+			utils.Custom(c, http.StatusNotFound, ChatTagsResponse{Success: false, Message: err.Error()})
+			return
+		}
+		s.logger.Error("用户 %d 设置消息 %d 标签失败: %v", userID, messageID, err)
+		utils.Custom(c, http.StatusInternalServerError, ChatTagsResponse{Success: false, Message: "设置标签失败"})
+		return
+	}
+	utils.Custom(c, http.StatusOK, ChatTagsResponse{Success: true, ID: uint(messageID), Tags: tags})
+}
+
+// handleListTags 获取用户使用过的所有标签及对应消息数
+func (s *AppService) handleListTags(c *gin.Context) {
+	db := database.GetDB()
+	if db == nil {
+		utils.Custom(c, http.StatusInternalServerError, ChatTagListResponse{Success: false, Message: "数据库未初始化"})
+		return
+	}
+	userID := c.GetUint("user_id")
+	tags, err := listUserTags(db.WithContext(c.Request.Context()), fmt.Sprintf("%d", userID))
+	if err != nil {
+		s.logger.Error("查询用户 %d 标签失败: %v", userID, err)
+		utils.Custom(c, http.StatusInternalServerError, ChatTagListResponse{Success: false, Message: "查询失败"})
+		return
+	}
+	utils.Custom(c, http.StatusOK, ChatTagListResponse{Success: true, Tags: tags})
+}
+
+// validateTags 校验标签：最多5个，每个不超过30个字符，只允许字母、数字和连字符；重复的标签只保留一个
+func validateTags(tags []string) ([]string, error) {
+	result := make([]string, 0, len(tags))
+	seen := make(map[string]struct{}, len(tags))
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if len(tag) > maxTagLength {
+			return nil, fmt.Errorf("标签 %q 超过%d个字符", tag, maxTagLength)
+		}
+		if !tagPattern.MatchString(tag) {
+			return nil, fmt.Errorf("标签 %q 只能包含字母、数字和连字符", tag)
+		}
+		if _, ok := seen[tag]; ok {
+			continue
+		}
+		seen[tag] = struct{}{}
+		result = append(result, tag)
+	}
+	if len(result) > maxTagsPerMessage {
+		return nil, fmt.Errorf("每条消息最多%d个标签", maxTagsPerMessage)
+	}
+	return result, nil
+}
+
+// userMessages 限定为用户自己的消息，包括与Bot好友的独立对话（user_id 为 "userID:botName"）
+func userMessages(db *gorm.DB, userID string) *gorm.DB {
+	return db.Model(&models.DialogueMessage{}).
+		Where("user_id = ? OR user_id LIKE ?", userID, userID+":%")
+}
+
+// setMessageTags 覆盖用户消息的标签，空列表时清除
+func setMessageTags(db *gorm.DB, userID string, messageID uint, tags []string) error {
+	var value datatypes.JSON
+	if len(tags) > 0 {
+		data, err := json.Marshal(tags)
+		if err != nil {
+			return err
+		}
+		value = datatypes.JSON(data)
+	}
+	result := userMessages(db, userID).Where("id = ?", messageID).Update("tags", value)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errMessageNotFound
+	}
+	return nil
+}
+
+// listUserTags 统计用户所有消息上的标签，按使用次数降序、标签名升序排列
+func listUserTags(db *gorm.DB, userID string) ([]ChatTagCount, error) {
+	var rows []models.DialogueMessage
+	if err := userMessages(db, userID).
+		Select("tags").
+		Where("tags IS NOT NULL").
+		Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int)
+	for _, r := range rows {
+		var tags []string
+		if err := json.Unmarshal(r.Tags, &tags); err != nil {
+			continue
+		}
+		for _, tag := range tags {
+			counts[tag]++
+		}
+	}
+
+	result := make([]ChatTagCount, 0, len(counts))
+	for tag, count := range counts {
+		result = append(result, ChatTagCount{Tag: tag, Count: count})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].Tag < result[j].Tag
+	})
+	return result, nil
+}
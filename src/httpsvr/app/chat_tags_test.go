@@ -0,0 +1,111 @@
+package app
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"angrymiao-ai-server/src/configs/database"
+	"angrymiao-ai-server/src/core/chat"
+	"angrymiao-ai-server/src/models"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestValidateTags(t *testing.T) {
+	tests := []struct {
+		name    string
+		tags    []string
+		want    []string
+		wantErr string
+	}{
+		{"合法标签", []string{"work", "Important-2"}, []string{"work", "Important-2"}, ""},
+		{"去重", []string{"work", "work"}, []string{"work"}, ""},
+		{"空列表", nil, []string{}, ""},
+		{"超过5个", []string{"a", "b", "c", "d", "e", "f"}, nil, "最多5个"},
+		{"超过30个字符", []string{strings.Repeat("a", 31)}, nil, "超过30个字符"},
+		{"非法字符", []string{"工作"}, nil, "只能包含"},
+		{"空标签", []string{" "}, nil, "只能包含"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := validateTags(tt.tags)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("错误 = %v, 期望包含 %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("validateTags(%v) = %v, %v; 期望 %v", tt.tags, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestMessageTagsFilterAndList(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "tags.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("打开测试数据库失败: %v", err)
+	}
+	if err := db.AutoMigrate(&models.DialogueMessage{}, &models.BotConfig{}); err != nil {
+		t.Fatalf("迁移失败: %v", err)
+	}
+	rows := []models.DialogueMessage{
+		{UserID: "7", Role: "user", Content: "周报怎么写"},
+		{UserID: "7", Role: "assistant", Content: "先列出本周完成的事项"},
+		{UserID: "7", Role: "user", Content: "周末去哪玩"},
+		{UserID: "7:weather", Role: "user", Content: "明天下雨吗"},
+		{UserID: "8", Role: "user", Content: "别人的消息"},
+	}
+	if err := db.Create(&rows).Error; err != nil {
+		t.Fatalf("写入消息失败: %v", err)
+	}
+
+	set := func(i int, tags ...string) {
+		t.Helper()
+		if err := setMessageTags(db, "7", rows[i].ID, tags); err != nil {
+			t.Fatalf("设置消息 %d 标签失败: %v", rows[i].ID, err)
+		}
+	}
+	set(0, "work", "important")
+	set(1, "work")
+	set(2, "life")
+	set(3, "work")
+	if err := setMessageTags(db, "7", rows[4].ID, []string{"work"}); !errors.Is(err, errMessageNotFound) {
+		t.Errorf("给其他用户的消息打标签应返回 errMessageNotFound, got %v", err)
+	}
+	// 覆盖后清除
+	set(2, "life", "travel")
+	set(2)
+
+	tags, err := listUserTags(db, "7")
+	if err != nil {
+		t.Fatalf("查询标签失败: %v", err)
+	}
+	want := []ChatTagCount{{Tag: "work", Count: 3}, {Tag: "important", Count: 1}}
+	if !reflect.DeepEqual(tags, want) {
+		t.Errorf("listUserTags = %+v, 期望 %+v", tags, want)
+	}
+
+	prev := database.DB
+	database.DB = db
+	defer func() { database.DB = prev }()
+
+	messages, total, err := chat.NewPostgresMemory("7").QueryMessages("ASC", 1, 20, "work")
+	if err != nil {
+		t.Fatalf("按标签查询失败: %v", err)
+	}
+	if total != 2 || len(messages) != 2 || messages[0].ID != rows[0].ID || messages[1].ID != rows[1].ID {
+		t.Fatalf("按标签 work 查询 = %+v (total %d)", messages, total)
+	}
+	if !reflect.DeepEqual(messages[0].Tags, []string{"work", "important"}) {
+		t.Errorf("消息标签 = %v", messages[0].Tags)
+	}
+	if _, total, _ := chat.NewPostgresMemory("7").QueryMessages("ASC", 1, 20, ""); total != 3 {
+		t.Errorf("不指定标签时 total = %d, 期望 3", total)
+	}
+}
@@ -0,0 +1,223 @@
+package app
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"angrymiao-ai-server/src/core/middleware"
+	"angrymiao-ai-server/src/core/utils"
+	"angrymiao-ai-server/src/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// DeviceGroupHandler 设备组处理器
+type DeviceGroupHandler struct {
+	groupService DeviceGroupService
+	logger       *utils.Logger
+}
+
+// NewDeviceGroupHandler 创建设备组处理器
+func NewDeviceGroupHandler(db *gorm.DB, logger *utils.Logger) *DeviceGroupHandler {
+	return &DeviceGroupHandler{
+		groupService: NewDeviceGroupService(db, logger),
+		logger:       logger,
+	}
+}
+
+// RegisterRoutes 注册设备组路由
+func (h *DeviceGroupHandler) RegisterRoutes(apiGroup *gin.RouterGroup) {
+	groupV2 := apiGroup.Group("/v2/device-groups").Use(middleware.AmTokenJWTUserAuth())
+	{
+		groupV2.POST("", h.CreateGroup)
+		groupV2.GET("", h.ListGroups)
+		groupV2.GET("/:id", h.GetGroup)
+		groupV2.PUT("/:id", h.UpdateGroup)
+		groupV2.DELETE("/:id", h.DeleteGroup)
+		groupV2.PATCH("/:id/devices", h.UpdateDevices)
+	}
+}
+
+// CreateGroup 创建设备组
+// @Summary 创建设备组
+// @Description 创建设备组，组内设备共享系统提示词与主人格Bot
+// @Tags 设备组管理
+// @Accept json
+// @Produce json
+// @Param group body models.DeviceGroupRequest true "设备组信息"
+// @Success 200 {object} models.DeviceGroupResponse "创建成功"
+// @Failure 400 {object} map[string]interface{} "请求参数错误"
+// @Failure 404 {object} map[string]interface{} "Bot好友不存在"
+// @Failure 500 {object} map[string]interface{} "服务器内部错误"
+// @Router /api/v2/device-groups [post]
+func (h *DeviceGroupHandler) CreateGroup(c *gin.Context) {
+	var req models.DeviceGroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.respondError(c, http.StatusBadRequest, "请求参数格式错误", err)
+		return
+	}
+	group, err := h.groupService.CreateGroup(c.Request.Context(), h.getUserID(c), &req)
+	if err != nil {
+		h.respondServiceError(c, "创建设备组失败", err)
+		return
+	}
+	utils.Success(c, group)
+}
+
+// ListGroups 获取设备组列表
+// @Summary 获取设备组列表
+// @Tags 设备组管理
+// @Produce json
+// @Success 200 {array} models.DeviceGroupResponse "设备组列表"
+// @Failure 500 {object} map[string]interface{} "服务器内部错误"
+// @Router /api/v2/device-groups [get]
+func (h *DeviceGroupHandler) ListGroups(c *gin.Context) {
+	groups, err := h.groupService.ListGroups(c.Request.Context(), h.getUserID(c))
+	if err != nil {
+		h.respondError(c, http.StatusInternalServerError, "获取设备组列表失败", err)
+		return
+	}
+	utils.Success(c, groups)
+}
+
+// GetGroup 获取设备组详情
+// @Summary 获取设备组详情
+// @Tags 设备组管理
+// @Produce json
+// @Param id path int true "设备组ID"
+// @Success 200 {object} models.DeviceGroupResponse "设备组详情"
+// @Failure 404 {object} map[string]interface{} "设备组不存在"
+// @Router /api/v2/device-groups/{id} [get]
+func (h *DeviceGroupHandler) GetGroup(c *gin.Context) {
+	groupID, ok := h.groupID(c)
+	if !ok {
+		return
+	}
+	group, err := h.groupService.GetGroup(c.Request.Context(), h.getUserID(c), groupID)
+	if err != nil {
+		h.respondServiceError(c, "获取设备组失败", err)
+		return
+	}
+	utils.Success(c, group)
+}
+
+// UpdateGroup 更新设备组
+// @Summary 更新设备组
+// @Description 更新设备组名称、系统提示词与主人格Bot，组内设备下次连接时生效
+// @Tags 设备组管理
+// @Accept json
+// @Produce json
+// @Param id path int true "设备组ID"
+// @Param group body models.DeviceGroupRequest true "设备组信息"
+// @Success 200 {object} models.DeviceGroupResponse "更新成功"
+// @Failure 400 {object} map[string]interface{} "请求参数错误"
+// @Failure 404 {object} map[string]interface{} "设备组或Bot好友不存在"
+// @Router /api/v2/device-groups/{id} [put]
+func (h *DeviceGroupHandler) UpdateGroup(c *gin.Context) {
+	groupID, ok := h.groupID(c)
+	if !ok {
+		return
+	}
+	var req models.DeviceGroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.respondError(c, http.StatusBadRequest, "请求参数格式错误", err)
+		return
+	}
+	group, err := h.groupService.UpdateGroup(c.Request.Context(), h.getUserID(c), groupID, &req)
+	if err != nil {
+		h.respondServiceError(c, "更新设备组失败", err)
+		return
+	}
+	utils.Success(c, group)
+}
+
+// DeleteGroup 删除设备组
+// @Summary 删除设备组
+// @Tags 设备组管理
+// @Produce json
+// @Param id path int true "设备组ID"
+// @Success 200 {object} map[string]interface{} "删除成功"
+// @Failure 404 {object} map[string]interface{} "设备组不存在"
+// @Router /api/v2/device-groups/{id} [delete]
+func (h *DeviceGroupHandler) DeleteGroup(c *gin.Context) {
+	groupID, ok := h.groupID(c)
+	if !ok {
+		return
+	}
+	if err := h.groupService.DeleteGroup(c.Request.Context(), h.getUserID(c), groupID); err != nil {
+		h.respondServiceError(c, "删除设备组失败", err)
+		return
+	}
+	utils.Success(c, gin.H{"message": "删除设备组成功", "id": groupID})
+}
+
+// UpdateDevices 添加或移除组内设备
+// @Summary 添加或移除组内设备
+// @Description 已属于其他设备组的设备移动到该组，设备下次连接时生效
+// @Tags 设备组管理
+// @Accept json
+// @Produce json
+// @Param id path int true "设备组ID"
+// @Param devices body models.UpdateDeviceGroupDevicesRequest true "添加与移除的设备ID"
+// @Success 200 {object} models.DeviceGroupResponse "更新成功"
+// @Failure 400 {object} map[string]interface{} "请求参数错误"
+// @Failure 404 {object} map[string]interface{} "设备组或设备不存在"
+// @Router /api/v2/device-groups/{id}/devices [patch]
+func (h *DeviceGroupHandler) UpdateDevices(c *gin.Context) {
+	groupID, ok := h.groupID(c)
+	if !ok {
+		return
+	}
+	var req models.UpdateDeviceGroupDevicesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.respondError(c, http.StatusBadRequest, "请求参数格式错误", err)
+		return
+	}
+	if len(req.Add) == 0 && len(req.Remove) == 0 {
+		h.respondError(c, http.StatusBadRequest, "add 与 remove 不能同时为空", nil)
+		return
+	}
+	group, err := h.groupService.UpdateDevices(c.Request.Context(), h.getUserID(c), groupID, &req)
+	if err != nil {
+		h.respondServiceError(c, "更新设备组成员失败", err)
+		return
+	}
+	utils.Success(c, group)
+}
+
+// groupID 解析路径中的设备组ID，无效时返回400
+func (h *DeviceGroupHandler) groupID(c *gin.Context) (uint, bool) {
+	groupID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		h.respondError(c, http.StatusBadRequest, "无效的设备组ID", err)
+		return 0, false
+	}
+	return uint(groupID), true
+}
+
+// getUserID 从上下文获取用户ID
+func (h *DeviceGroupHandler) getUserID(c *gin.Context) uint {
+	return c.GetUint("user_id")
+}
+
+// respondServiceError 设备组、Bot好友或设备不存在时返回404，其余返回500
+func (h *DeviceGroupHandler) respondServiceError(c *gin.Context, message string, err error) {
+	if errors.Is(err, errDeviceGroupNotFound) || errors.Is(err, errGroupBotNotFriend) || errors.Is(err, errGroupDeviceNotFound) {
+		h.respondError(c, http.StatusNotFound, err.Error(), err)
+		return
+	}
+	h.respondError(c, http.StatusInternalServerError, message, err)
+}
+
+// respondError 返回错误响应
+func (h *DeviceGroupHandler) respondError(c *gin.Context, statusCode int, message string, err error) {
+	logger := utils.RequestLogger(c, h.logger)
+	if err != nil {
+		logger.Error("%s: %v", message, err)
+	} else {
+		logger.Error("%s", message)
+	}
+	utils.ErrorWithDetail(c, statusCode, message, err)
+}
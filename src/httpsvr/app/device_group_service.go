@@ -0,0 +1,233 @@
+package app
+
+import (
+	"context"
+	"errors"
+
+	"angrymiao-ai-server/src/core/utils"
+	"angrymiao-ai-server/src/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	errDeviceGroupNotFound = errors.New("设备组不存在")
+	errGroupBotNotFriend   = errors.New("Bot好友不存在")
+	errGroupDeviceNotFound = errors.New("设备不存在")
+)
+
+// DeviceGroupService 设备组服务接口
+type DeviceGroupService interface {
+	CreateGroup(ctx context.Context, userID uint, req *models.DeviceGroupRequest) (*models.DeviceGroupResponse, error)
+	GetGroup(ctx context.Context, userID uint, groupID uint) (*models.DeviceGroupResponse, error)
+	ListGroups(ctx context.Context, userID uint) ([]*models.DeviceGroupResponse, error)
+	UpdateGroup(ctx context.Context, userID uint, groupID uint, req *models.DeviceGroupRequest) (*models.DeviceGroupResponse, error)
+	DeleteGroup(ctx context.Context, userID uint, groupID uint) error
+	// UpdateDevices 添加或移除组内设备，已属于其他设备组的设备移动到该组
+	UpdateDevices(ctx context.Context, userID uint, groupID uint, req *models.UpdateDeviceGroupDevicesRequest) (*models.DeviceGroupResponse, error)
+}
+
+// DefaultDeviceGroupService 默认设备组服务实现
+type DefaultDeviceGroupService struct {
+	db     *gorm.DB
+	logger *utils.Logger
+}
+
+// NewDeviceGroupService 创建设备组服务实例
+func NewDeviceGroupService(db *gorm.DB, logger *utils.Logger) DeviceGroupService {
+	return &DefaultDeviceGroupService{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// CreateGroup 创建设备组
+func (s *DefaultDeviceGroupService) CreateGroup(ctx context.Context, userID uint, req *models.DeviceGroupRequest) (*models.DeviceGroupResponse, error) {
+	if err := s.checkBotFriend(ctx, userID, req.BotConfigID); err != nil {
+		return nil, err
+	}
+	group := &models.DeviceGroup{
+		Name:         req.Name,
+		UserID:       userID,
+		SystemPrompt: req.SystemPrompt,
+		BotConfigID:  req.BotConfigID,
+	}
+	if err := s.db.WithContext(ctx).Create(group).Error; err != nil {
+		s.logger.Error("创建设备组失败: %v", err)
+		return nil, err
+	}
+	s.logger.Info("用户 %d 创建设备组 %s (ID: %d)", userID, group.Name, group.ID)
+	return &models.DeviceGroupResponse{DeviceGroup: *group, DeviceIDs: []string{}}, nil
+}
+
+// GetGroup 获取设备组详情
+func (s *DefaultDeviceGroupService) GetGroup(ctx context.Context, userID uint, groupID uint) (*models.DeviceGroupResponse, error) {
+	group, err := s.findGroup(ctx, userID, groupID)
+	if err != nil {
+		return nil, err
+	}
+	return s.groupResponse(ctx, group)
+}
+
+// ListGroups 获取用户的所有设备组
+func (s *DefaultDeviceGroupService) ListGroups(ctx context.Context, userID uint) ([]*models.DeviceGroupResponse, error) {
+	var groups []models.DeviceGroup
+	if err := s.db.WithContext(ctx).Where("user_id = ?", userID).Order("id").Find(&groups).Error; err != nil {
+		s.logger.Error("查询设备组失败: %v", err)
+		return nil, err
+	}
+	responses := make([]*models.DeviceGroupResponse, 0, len(groups))
+	for i := range groups {
+		resp, err := s.groupResponse(ctx, &groups[i])
+		if err != nil {
+			return nil, err
+		}
+		responses = append(responses, resp)
+	}
+	return responses, nil
+}
+
+// UpdateGroup 更新设备组名称、系统提示词与主人格Bot
+func (s *DefaultDeviceGroupService) UpdateGroup(ctx context.Context, userID uint, groupID uint, req *models.DeviceGroupRequest) (*models.DeviceGroupResponse, error) {
+	group, err := s.findGroup(ctx, userID, groupID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkBotFriend(ctx, userID, req.BotConfigID); err != nil {
+		return nil, err
+	}
+	group.Name = req.Name
+	group.SystemPrompt = req.SystemPrompt
+	group.BotConfigID = req.BotConfigID
+	// 显式选择字段，清空系统提示词或主人格Bot时同样更新
+	if err := s.db.WithContext(ctx).Select("name", "system_prompt", "bot_config_id").Updates(group).Error; err != nil {
+		s.logger.Error("更新设备组失败: %v", err)
+		return nil, err
+	}
+	s.logger.Info("用户 %d 更新设备组 %d", userID, groupID)
+	return s.groupResponse(ctx, group)
+}
+
+// DeleteGroup 删除设备组及其成员关系
+func (s *DefaultDeviceGroupService) DeleteGroup(ctx context.Context, userID uint, groupID uint) error {
+	group, err := s.findGroup(ctx, userID, groupID)
+	if err != nil {
+		return err
+	}
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("group_id = ?", group.ID).Delete(&models.DeviceGroupMember{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(group).Error
+	})
+	if err != nil {
+		s.logger.Error("删除设备组失败: %v", err)
+		return err
+	}
+	s.logger.Info("用户 %d 删除设备组 %d", userID, groupID)
+	return nil
+}
+
+// UpdateDevices 添加或移除组内设备
+func (s *DefaultDeviceGroupService) UpdateDevices(ctx context.Context, userID uint, groupID uint, req *models.UpdateDeviceGroupDevicesRequest) (*models.DeviceGroupResponse, error) {
+	group, err := s.findGroup(ctx, userID, groupID)
+	if err != nil {
+		return nil, err
+	}
+	if len(req.Add) > 0 {
+		var count int64
+		if err := s.db.WithContext(ctx).Model(&models.Device{}).
+			Where("user_id = ? AND device_id IN ?", userID, req.Add).
+			Distinct("device_id").
+			Count(&count).Error; err != nil {
+			return nil, err
+		}
+		if int(count) != len(uniqueStrings(req.Add)) {
+			return nil, errGroupDeviceNotFound
+		}
+	}
+
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if len(req.Remove) > 0 {
+			if err := tx.Where("group_id = ? AND device_id IN ?", group.ID, req.Remove).
+				Delete(&models.DeviceGroupMember{}).Error; err != nil {
+				return err
+			}
+		}
+		for _, deviceID := range uniqueStrings(req.Add) {
+			member := &models.DeviceGroupMember{GroupID: group.ID, DeviceID: deviceID}
+			if err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "device_id"}},
+				DoUpdates: clause.AssignmentColumns([]string{"group_id"}),
+			}).Create(member).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		s.logger.Error("更新设备组成员失败: %v", err)
+		return nil, err
+	}
+	s.logger.Info("用户 %d 更新设备组 %d 成员: 添加 %v, 移除 %v", userID, groupID, req.Add, req.Remove)
+	return s.groupResponse(ctx, group)
+}
+
+// findGroup 查询用户自己的设备组
+func (s *DefaultDeviceGroupService) findGroup(ctx context.Context, userID uint, groupID uint) (*models.DeviceGroup, error) {
+	var group models.DeviceGroup
+	err := s.db.WithContext(ctx).Where("id = ? AND user_id = ?", groupID, userID).First(&group).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errDeviceGroupNotFound
+		}
+		s.logger.Error("查询设备组失败: %v", err)
+		return nil, err
+	}
+	return &group, nil
+}
+
+// groupResponse 组装设备组及其成员设备
+func (s *DefaultDeviceGroupService) groupResponse(ctx context.Context, group *models.DeviceGroup) (*models.DeviceGroupResponse, error) {
+	deviceIDs := []string{}
+	if err := s.db.WithContext(ctx).Model(&models.DeviceGroupMember{}).
+		Where("group_id = ?", group.ID).
+		Order("id").
+		Pluck("device_id", &deviceIDs).Error; err != nil {
+		s.logger.Error("查询设备组成员失败: %v", err)
+		return nil, err
+	}
+	return &models.DeviceGroupResponse{DeviceGroup: *group, DeviceIDs: deviceIDs}, nil
+}
+
+// checkBotFriend 设置了主人格Bot时，检查该Bot是否为用户的好友
+func (s *DefaultDeviceGroupService) checkBotFriend(ctx context.Context, userID uint, botConfigID uint) error {
+	if botConfigID == 0 {
+		return nil
+	}
+	var count int64
+	if err := s.db.WithContext(ctx).Model(&models.UserFriend{}).
+		Where("user_id = ? AND bot_config_id = ? AND friend_type = ?", userID, botConfigID, "bot").
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return errGroupBotNotFriend
+	}
+	return nil
+}
+
+// uniqueStrings 去除重复项并保持原有顺序
+func uniqueStrings(values []string) []string {
+	result := make([]string, 0, len(values))
+	seen := make(map[string]struct{}, len(values))
+	for _, v := range values {
+		if _, ok := seen[v]; ok {
+			continue
+		}
+		seen[v] = struct{}{}
+		result = append(result, v)
+	}
+	return result
+}
@@ -0,0 +1,96 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"angrymiao-ai-server/src/core/utils"
+	"angrymiao-ai-server/src/models"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestDeviceGroupMembers(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "groups.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("打开测试数据库失败: %v", err)
+	}
+	if err := db.AutoMigrate(&models.UserFriend{}, &models.Device{}, &models.DeviceGroup{}, &models.DeviceGroupMember{}); err != nil {
+		t.Fatalf("迁移失败: %v", err)
+	}
+	logger, err := utils.NewLogger(&utils.LogCfg{LogLevel: "ERROR", LogDir: t.TempDir(), LogFile: "test.log"})
+	if err != nil {
+		t.Fatalf("创建日志失败: %v", err)
+	}
+	ctx := context.Background()
+	for _, deviceID := range []string{"device-1", "device-2", "device-3"} {
+		userID := uint(7)
+		if deviceID == "device-3" {
+			userID = 8
+		}
+		if err := db.Create(&models.Device{DeviceID: deviceID, UserID: userID, MacAddress: deviceID, ClientID: deviceID}).Error; err != nil {
+			t.Fatalf("创建设备失败: %v", err)
+		}
+	}
+	service := NewDeviceGroupService(db, logger)
+
+	if _, err := service.CreateGroup(ctx, 7, &models.DeviceGroupRequest{Name: "客厅", BotConfigID: 3}); !errors.Is(err, errGroupBotNotFriend) {
+		t.Errorf("未添加的Bot应返回 errGroupBotNotFriend, got %v", err)
+	}
+	living, err := service.CreateGroup(ctx, 7, &models.DeviceGroupRequest{Name: "客厅", SystemPrompt: "你是家庭助手"})
+	if err != nil {
+		t.Fatalf("创建设备组失败: %v", err)
+	}
+	bedroom, err := service.CreateGroup(ctx, 7, &models.DeviceGroupRequest{Name: "卧室"})
+	if err != nil {
+		t.Fatalf("创建设备组失败: %v", err)
+	}
+
+	if _, err := service.UpdateDevices(ctx, 7, living.ID, &models.UpdateDeviceGroupDevicesRequest{Add: []string{"device-1", "device-3"}}); !errors.Is(err, errGroupDeviceNotFound) {
+		t.Errorf("其他用户的设备应返回 errGroupDeviceNotFound, got %v", err)
+	}
+	if _, err := service.UpdateDevices(ctx, 8, living.ID, &models.UpdateDeviceGroupDevicesRequest{Add: []string{"device-3"}}); !errors.Is(err, errDeviceGroupNotFound) {
+		t.Errorf("其他用户的设备组应返回 errDeviceGroupNotFound, got %v", err)
+	}
+	got, err := service.UpdateDevices(ctx, 7, living.ID, &models.UpdateDeviceGroupDevicesRequest{Add: []string{"device-1", "device-2", "device-1"}})
+	if err != nil {
+		t.Fatalf("添加设备失败: %v", err)
+	}
+	if !reflect.DeepEqual(got.DeviceIDs, []string{"device-1", "device-2"}) {
+		t.Errorf("设备组成员 = %v", got.DeviceIDs)
+	}
+
+	// 添加到其他设备组时从原设备组移出
+	if _, err := service.UpdateDevices(ctx, 7, bedroom.ID, &models.UpdateDeviceGroupDevicesRequest{Add: []string{"device-2"}}); err != nil {
+		t.Fatalf("移动设备失败: %v", err)
+	}
+	got, err = service.UpdateDevices(ctx, 7, living.ID, &models.UpdateDeviceGroupDevicesRequest{Remove: []string{"device-1"}})
+	if err != nil {
+		t.Fatalf("移除设备失败: %v", err)
+	}
+	if len(got.DeviceIDs) != 0 {
+		t.Errorf("客厅设备组成员 = %v, 期望为空", got.DeviceIDs)
+	}
+
+	updated, err := service.UpdateGroup(ctx, 7, bedroom.ID, &models.DeviceGroupRequest{Name: "主卧", SystemPrompt: "小声说话"})
+	if err != nil {
+		t.Fatalf("更新设备组失败: %v", err)
+	}
+	if updated.Name != "主卧" || updated.SystemPrompt != "小声说话" || !reflect.DeepEqual(updated.DeviceIDs, []string{"device-2"}) {
+		t.Errorf("更新后的设备组 = %+v", updated)
+	}
+
+	if err := service.DeleteGroup(ctx, 7, bedroom.ID); err != nil {
+		t.Fatalf("删除设备组失败: %v", err)
+	}
+	var members int64
+	db.Model(&models.DeviceGroupMember{}).Count(&members)
+	groups, err := service.ListGroups(ctx, 7)
+	if err != nil || len(groups) != 1 || groups[0].ID != living.ID || members != 0 {
+		t.Errorf("删除后设备组 = %+v, 成员数 = %d, err = %v", groups, members, err)
+	}
+}
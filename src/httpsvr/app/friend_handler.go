@@ -1,6 +1,7 @@
 package app
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -37,6 +38,12 @@ func (h *UserFriendHandler) RegisterRoutes(apiGroup *gin.RouterGroup) {
 		friendGroup.PATCH("/:bot_config_id/priority", h.UpdatePriority)
 		friendGroup.PATCH("/:bot_config_id/toggle", h.ToggleStatus)
 	}
+
+	friendV2Group := apiGroup.Group("/v2/friends/bots").Use(middleware.AmTokenJWTUserAuth())
+	{
+		friendV2Group.PATCH("/:bot_config_id/appkey", h.UpdateAppKey)
+		friendV2Group.PATCH("/:bot_config_id/bind-device/:device_id", h.BindDevice)
+	}
 }
 
 // AddBotFriend 添加Bot好友
@@ -136,7 +143,7 @@ func (h *UserFriendHandler) GetBotFriends(c *gin.Context) {
 
 // UpdateAppKey 更新Bot好友的AppKey
 // @Summary 更新Bot好友的AppKey
-// @Description 更新Bot好友的LLM API密钥
+// @Description 更新Bot好友的LLM API密钥，可同时通过 tts_voice 设置Bot使用的复刻音色
 // @Tags 用户好友管理
 // @Accept json
 // @Produce json
@@ -147,6 +154,7 @@ func (h *UserFriendHandler) GetBotFriends(c *gin.Context) {
 // @Failure 404 {object} map[string]interface{} "好友不存在"
 // @Failure 500 {object} map[string]interface{} "服务器内部错误"
 // @Router /api/friends/bots/{bot_config_id}/appkey [patch]
+// @Router /api/v2/friends/bots/{bot_config_id}/appkey [patch]
 func (h *UserFriendHandler) UpdateAppKey(c *gin.Context) {
 	userID := h.getUserID(c)
 	botConfigID, err := strconv.ParseUint(c.Param("bot_config_id"), 10, 32)
@@ -161,9 +169,11 @@ func (h *UserFriendHandler) UpdateAppKey(c *gin.Context) {
 		return
 	}
 
-	if err := h.friendService.UpdateBotFriendAppKey(c.Request.Context(), userID, uint(botConfigID), req.AppKey); err != nil {
+	if err := h.friendService.UpdateBotFriendAppKey(c.Request.Context(), userID, uint(botConfigID), req.AppKey, req.TTSVoice); err != nil {
 		if err.Error() == "Bot好友不存在" {
 			h.respondError(c, http.StatusNotFound, "Bot好友不存在", err)
+		} else if errors.Is(err, errVoiceNotFound) {
+			h.respondError(c, http.StatusBadRequest, "音色不存在或未复刻完成", err)
 		} else {
 			h.respondError(c, http.StatusInternalServerError, "更新AppKey失败", err)
 		}
@@ -266,6 +276,43 @@ func (h *UserFriendHandler) ToggleStatus(c *gin.Context) {
 	})
 }
 
+// BindDevice 将Bot好友设为设备的主人格
+// @Summary 绑定设备主人格
+// @Description 设备连接时使用该Bot的描述作为系统提示词、使用该Bot的模型作为对话LLM，其他Bot好友仍作为工具可用
+// @Tags 用户好友管理
+// @Produce json
+// @Param bot_config_id path int true "Bot配置ID"
+// @Param device_id path string true "设备ID"
+// @Success 200 {object} map[string]interface{} "绑定成功"
+// @Failure 400 {object} map[string]interface{} "请求参数错误"
+// @Failure 404 {object} map[string]interface{} "好友或设备不存在"
+// @Failure 500 {object} map[string]interface{} "服务器内部错误"
+// @Router /api/v2/friends/bots/{bot_config_id}/bind-device/{device_id} [patch]
+func (h *UserFriendHandler) BindDevice(c *gin.Context) {
+	userID := h.getUserID(c)
+	botConfigID, err := strconv.ParseUint(c.Param("bot_config_id"), 10, 32)
+	if err != nil {
+		h.respondError(c, http.StatusBadRequest, "无效的Bot配置ID", err)
+		return
+	}
+	deviceID := c.Param("device_id")
+
+	if err := h.friendService.BindBotToDevice(c.Request.Context(), userID, uint(botConfigID), deviceID); err != nil {
+		if err.Error() == "Bot好友不存在" || err.Error() == "设备不存在" {
+			h.respondError(c, http.StatusNotFound, err.Error(), err)
+		} else {
+			h.respondError(c, http.StatusInternalServerError, "绑定设备主人格失败", err)
+		}
+		return
+	}
+
+	h.respondSuccess(c, gin.H{
+		"message":       "绑定设备主人格成功",
+		"bot_config_id": botConfigID,
+		"device_id":     deviceID,
+	})
+}
+
 // getUserID 从上下文获取用户ID
 func (h *UserFriendHandler) getUserID(c *gin.Context) uint {
 	if userID, exists := c.Get("user_id"); exists {
@@ -284,10 +331,11 @@ func (h *UserFriendHandler) respondSuccess(c *gin.Context, data interface{}) {
 
 // respondError 返回错误响应
 func (h *UserFriendHandler) respondError(c *gin.Context, statusCode int, message string, err error) {
+	logger := utils.RequestLogger(c, h.logger)
 	if err != nil {
-		h.logger.Error("%s: %v", message, err)
+		logger.Error("%s: %v", message, err)
 	} else {
-		h.logger.Error("%s", message)
+		logger.Error("%s", message)
 	}
 	utils.ErrorWithDetail(c, statusCode, message, err)
 }
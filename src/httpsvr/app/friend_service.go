@@ -3,12 +3,15 @@ package app
 import (
 	"context"
 	"fmt"
+	"strconv"
 	"time"
 
+	"angrymiao-ai-server/src/core/botconfig"
 	"angrymiao-ai-server/src/core/utils"
 	"angrymiao-ai-server/src/models"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // UserFriendService 用户好友服务接口
@@ -19,7 +22,8 @@ type UserFriendService interface {
 	GetUserBotFriends(ctx context.Context, userID uint) ([]*models.UserBotFriendResponse, error)
 	UpdateBotFriendPriority(ctx context.Context, userID uint, botConfigID uint, priority int) error
 	ToggleBotFriendStatus(ctx context.Context, userID uint, botConfigID uint, isActive bool) error
-	UpdateBotFriendAppKey(ctx context.Context, userID uint, botConfigID uint, appKey string) error
+	UpdateBotFriendAppKey(ctx context.Context, userID uint, botConfigID uint, appKey string, ttsVoice string) error
+	BindBotToDevice(ctx context.Context, userID uint, botConfigID uint, deviceID string) error
 
 	// 查询
 	IsBotAdded(ctx context.Context, userID uint, botConfigID uint) (bool, error)
@@ -70,6 +74,7 @@ func (s *DefaultUserFriendService) AddBotFriend(ctx context.Context, userID uint
 	}
 
 	s.logger.Info("用户 %d 添加Bot好友成功 (BotConfigID: %d)", userID, botConfigID)
+	s.notifyConfigUpdate(ctx, userID)
 	return nil
 }
 
@@ -89,9 +94,21 @@ func (s *DefaultUserFriendService) RemoveBotFriend(ctx context.Context, userID u
 	}
 
 	s.logger.Info("用户 %d 删除Bot好友成功 (BotConfigID: %d)", userID, botConfigID)
+	s.notifyConfigUpdate(ctx, userID)
 	return nil
 }
 
+// notifyConfigUpdate 通知用户的在线连接重新加载Bot配置
+func (s *DefaultUserFriendService) notifyConfigUpdate(ctx context.Context, userID uint) {
+	notifier := botconfig.GetUpdateNotifier()
+	if notifier == nil {
+		return
+	}
+	if err := notifier.NotifyUpdate(ctx, strconv.FormatUint(uint64(userID), 10)); err != nil {
+		s.logger.Warn("通知用户 %d 配置变更失败: %v", userID, err)
+	}
+}
+
 // GetUserBotFriends 获取用户的Bot好友列表
 func (s *DefaultUserFriendService) GetUserBotFriends(ctx context.Context, userID uint) ([]*models.UserBotFriendResponse, error) {
 	var friends []models.UserFriend
@@ -124,6 +141,7 @@ func (s *DefaultUserFriendService) GetUserBotFriends(ctx context.Context, userID
 			UserID:      friend.UserID,
 			BotConfigID: *friend.BotConfigID,
 			AppKey:      friend.AppKey,
+			TTSVoice:    friend.TTSVoice,
 			Alias:       friend.Alias,
 			Priority:    friend.Priority,
 			IsActive:    friend.IsActive,
@@ -184,14 +202,29 @@ func (s *DefaultUserFriendService) ToggleBotFriendStatus(ctx context.Context, us
 	return nil
 }
 
-// UpdateBotFriendAppKey 更新Bot好友的AppKey
-func (s *DefaultUserFriendService) UpdateBotFriendAppKey(ctx context.Context, userID uint, botConfigID uint, appKey string) error {
+// UpdateBotFriendAppKey 更新Bot好友的AppKey，ttsVoice 非空时同时设置Bot的TTS音色
+// ttsVoice 须为用户已复刻成功的音色ID
+func (s *DefaultUserFriendService) UpdateBotFriendAppKey(ctx context.Context, userID uint, botConfigID uint, appKey string, ttsVoice string) error {
+	updates := map[string]interface{}{
+		"app_key":    appKey,
+		"updated_at": time.Now(),
+	}
+	if ttsVoice != "" {
+		var count int64
+		if err := s.db.WithContext(ctx).Model(&models.UserVoice{}).
+			Where("user_id = ? AND voice_id = ? AND status = ?", userID, ttsVoice, models.UserVoiceStatusReady).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return errVoiceNotFound
+		}
+		updates["tts_voice"] = ttsVoice
+	}
+
 	result := s.db.WithContext(ctx).Model(&models.UserFriend{}).
 		Where("user_id = ? AND bot_config_id = ? AND friend_type = ?", userID, botConfigID, "bot").
-		Updates(map[string]interface{}{
-			"app_key":    appKey,
-			"updated_at": time.Now(),
-		})
+		Updates(updates)
 
 	if result.Error != nil {
 		return result.Error
@@ -205,6 +238,45 @@ func (s *DefaultUserFriendService) UpdateBotFriendAppKey(ctx context.Context, us
 	return nil
 }
 
+// BindBotToDevice 将Bot好友设为设备的主人格，设备已绑定其他Bot时替换
+func (s *DefaultUserFriendService) BindBotToDevice(ctx context.Context, userID uint, botConfigID uint, deviceID string) error {
+	isAdded, err := s.IsBotAdded(ctx, userID, botConfigID)
+	if err != nil {
+		return err
+	}
+	if !isAdded {
+		return fmt.Errorf("Bot好友不存在")
+	}
+
+	var count int64
+	if err := s.db.WithContext(ctx).Model(&models.Device{}).
+		Where("device_id = ? AND user_id = ?", deviceID, userID).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return fmt.Errorf("设备不存在")
+	}
+
+	binding := &models.DeviceBotBinding{
+		UserID:      userID,
+		DeviceID:    deviceID,
+		BotConfigID: botConfigID,
+	}
+	err = s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "device_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"user_id", "bot_config_id", "updated_at"}),
+	}).Create(binding).Error
+	if err != nil {
+		s.logger.Error("绑定设备主人格失败: %v", err)
+		return err
+	}
+
+	s.logger.Info("用户 %d 将Bot好友设为设备 %s 的主人格 (BotConfigID: %d)", userID, deviceID, botConfigID)
+	s.notifyConfigUpdate(ctx, userID)
+	return nil
+}
+
 // IsBotAdded 检查用户是否已添加Bot
 func (s *DefaultUserFriendService) IsBotAdded(ctx context.Context, userID uint, botConfigID uint) (bool, error) {
 	var count int64
@@ -0,0 +1,57 @@
+package app
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"angrymiao-ai-server/src/core/utils"
+	"angrymiao-ai-server/src/models"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestBindBotToDevice(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "friends.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("打开测试数据库失败: %v", err)
+	}
+	if err := db.AutoMigrate(&models.UserFriend{}, &models.Device{}, &models.DeviceBotBinding{}); err != nil {
+		t.Fatalf("迁移失败: %v", err)
+	}
+	logger, err := utils.NewLogger(&utils.LogCfg{LogLevel: "ERROR", LogDir: t.TempDir(), LogFile: "test.log"})
+	if err != nil {
+		t.Fatalf("创建日志失败: %v", err)
+	}
+	ctx := context.Background()
+	service := NewUserFriendService(db, logger)
+	for _, botID := range []uint{3, 4} {
+		if err := service.AddBotFriend(ctx, 7, botID, "", ""); err != nil {
+			t.Fatalf("添加Bot好友失败: %v", err)
+		}
+	}
+	if err := db.Create(&models.Device{DeviceID: "device-1", UserID: 7, MacAddress: "aa:bb", ClientID: "client-1"}).Error; err != nil {
+		t.Fatalf("创建设备失败: %v", err)
+	}
+
+	if err := service.BindBotToDevice(ctx, 7, 9, "device-1"); err == nil || err.Error() != "Bot好友不存在" {
+		t.Errorf("未添加的Bot应返回 Bot好友不存在, got %v", err)
+	}
+	if err := service.BindBotToDevice(ctx, 8, 3, "device-1"); err == nil {
+		t.Error("其他用户不应能绑定该设备")
+	}
+	if err := service.BindBotToDevice(ctx, 7, 3, "device-1"); err != nil {
+		t.Fatalf("绑定失败: %v", err)
+	}
+	// 重复绑定时替换为新的Bot
+	if err := service.BindBotToDevice(ctx, 7, 4, "device-1"); err != nil {
+		t.Fatalf("重新绑定失败: %v", err)
+	}
+
+	var bindings []models.DeviceBotBinding
+	db.Find(&bindings)
+	if len(bindings) != 1 || bindings[0].BotConfigID != 4 || bindings[0].UserID != 7 {
+		t.Errorf("设备绑定 = %+v, 期望只保留最新绑定的Bot", bindings)
+	}
+}
@@ -0,0 +1,182 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"angrymiao-ai-server/src/core/providers"
+	"angrymiao-ai-server/src/core/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultBatchMaxRequests    = 20
+	defaultBatchTimeoutSeconds = 60
+	defaultBatchConcurrency    = 3
+)
+
+// handleLLMBatch 批量LLM推理，所有请求完成或超时后一并返回，超时未完成的请求在 error 中说明
+func (s *AppService) handleLLMBatch(c *gin.Context) {
+	var req BatchLLMRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Custom(c, http.StatusBadRequest, BatchLLMResponse{Success: false, Message: "请求参数错误: " + err.Error()})
+		return
+	}
+
+	maxRequests := s.config.BatchMaxRequests
+	if maxRequests <= 0 {
+		maxRequests = defaultBatchMaxRequests
+	}
+	if len(req.Requests) == 0 || len(req.Requests) > maxRequests {
+		utils.Custom(c, http.StatusBadRequest, BatchLLMResponse{Success: false, Message: fmt.Sprintf("请求数量必须在1到%d之间", maxRequests)})
+		return
+	}
+	for _, item := range req.Requests {
+		if item.ID == "" || len(item.Messages) == 0 {
+			utils.Custom(c, http.StatusBadRequest, BatchLLMResponse{Success: false, Message: "每个请求必须包含id和messages"})
+			return
+		}
+	}
+
+	// 获取或初始化资源池管理器
+	pm, err := s.providerPool()
+	if err != nil {
+		s.logger.Error("初始化资源池失败: %v", err)
+		utils.Custom(c, http.StatusInternalServerError, BatchLLMResponse{Success: false, Message: "服务内部错误"})
+		return
+	}
+
+	// 从资源池获取LLM提供者，批量请求共用同一个提供者
+	set, err := pm.GetProviderSet()
+	if err != nil || set.LLM == nil {
+		if err != nil {
+			s.logger.Error("获取LLM提供者失败: %v", err)
+		}
+		utils.Custom(c, http.StatusInternalServerError, BatchLLMResponse{Success: false, Message: "LLM服务不可用"})
+		return
+	}
+
+	timeoutSeconds := s.config.BatchTimeoutSeconds
+	if timeoutSeconds <= 0 {
+		timeoutSeconds = defaultBatchTimeoutSeconds
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), time.Duration(timeoutSeconds)*time.Second)
+	defer cancel()
+
+	userID := c.GetUint("user_id")
+	startTime := time.Now()
+	results, wait := runLLMBatch(ctx, set.LLM, fmt.Sprintf("http_batch_%d", userID), req.Requests, req.MaxConcurrency)
+	// 超时返回后仍在执行的请求结束后再归还提供者
+	go func() {
+		wait()
+		if err := set.ReleaseBack(); err != nil {
+			s.logger.Warn("归还批量推理资源失败: %v", err)
+		}
+	}()
+
+	s.logger.Info("用户 %d 批量推理完成，请求数: %d, 耗时: %v", userID, len(results), time.Since(startTime))
+	utils.Custom(c, http.StatusOK, BatchLLMResponse{Success: true, Results: results})
+}
+
+// runLLMBatch 并发执行批量请求，同时进行的请求数不超过 maxConcurrency，结果顺序与请求一致
+// ctx 结束时立即返回，未完成的请求带超时错误；返回的 wait 在所有请求结束后返回
+func runLLMBatch(
+	ctx context.Context,
+	llm providers.LLMProvider,
+	sessionID string,
+	items []BatchLLMItem,
+	maxConcurrency int,
+) ([]BatchLLMResult, func()) {
+	if maxConcurrency <= 0 {
+		maxConcurrency = defaultBatchConcurrency
+	}
+
+	var mu sync.Mutex
+	results := make([]BatchLLMResult, len(items))
+	finished := make([]bool, len(items))
+	for i, item := range items {
+		results[i].ID = item.ID
+	}
+
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, maxConcurrency)
+	for i, item := range items {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			defer func() { <-slots }()
+
+			content, err := collectLLMResponse(ctx, llm, sessionID, item)
+
+			mu.Lock()
+			defer mu.Unlock()
+			results[i].Content = content
+			if err != nil {
+				msg := err.Error()
+				results[i].Error = &msg
+			}
+			finished[i] = true
+		}()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	output := make([]BatchLLMResult, len(results))
+	for i, result := range results {
+		if !finished[i] {
+			msg := "请求超时"
+			result = BatchLLMResult{ID: result.ID, Error: &msg}
+		}
+		output[i] = result
+	}
+	return output, func() { <-done }
+}
+
+// collectLLMResponse 执行单个请求并收集完整回复
+func collectLLMResponse(ctx context.Context, llm providers.LLMProvider, sessionID string, item BatchLLMItem) (string, error) {
+	responses, err := llm.ResponseWithFunctions(ctx, sessionID, item.Messages, item.Tools)
+	if err != nil {
+		return "", fmt.Errorf("LLM生成回复失败: %v", err)
+	}
+
+	var content strings.Builder
+	for {
+		select {
+		case response, ok := <-responses:
+			if !ok {
+				return content.String(), nil
+			}
+			if response.Error != "" {
+				return "", fmt.Errorf("LLM响应错误: %s", response.Error)
+			}
+			content.WriteString(response.Content)
+		case <-ctx.Done():
+			// 继续读取剩余响应，避免提供者阻塞在发送上
+			go func() {
+				for range responses {
+				}
+			}()
+			return "", fmt.Errorf("请求超时")
+		}
+	}
+}
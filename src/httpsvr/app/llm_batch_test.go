@@ -0,0 +1,122 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"angrymiao-ai-server/src/core/providers"
+	"angrymiao-ai-server/src/core/types"
+
+	"github.com/angrymiao/go-openai"
+)
+
+// batchMockLLM 按请求内容返回结果，并记录最大并发数
+// 内容为 "fail" 时返回错误，为 "hang" 时直到 ctx 结束才返回
+type batchMockLLM struct {
+	mu        sync.Mutex
+	active    int
+	maxActive int
+}
+
+func (m *batchMockLLM) Initialize() error              { return nil }
+func (m *batchMockLLM) Cleanup() error                 { return nil }
+func (m *batchMockLLM) GetSessionID() string           { return "" }
+func (m *batchMockLLM) SetIdentityFlag(string, string) {}
+func (m *batchMockLLM) Capabilities() map[string]bool  { return nil }
+
+func (m *batchMockLLM) Response(ctx context.Context, sessionID string, messages []types.Message) (<-chan string, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
+func (m *batchMockLLM) ResponseWithFunctions(ctx context.Context, sessionID string, messages []types.Message, tools []openai.Tool) (<-chan types.Response, error) {
+	prompt := messages[len(messages)-1].Content
+	if prompt == "fail" {
+		return nil, fmt.Errorf("模拟失败")
+	}
+
+	ch := make(chan types.Response, 1)
+	go func() {
+		defer close(ch)
+		m.mu.Lock()
+		m.active++
+		m.maxActive = max(m.maxActive, m.active)
+		m.mu.Unlock()
+		defer func() {
+			m.mu.Lock()
+			m.active--
+			m.mu.Unlock()
+		}()
+
+		if prompt == "hang" {
+			<-ctx.Done()
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+		ch <- types.Response{Content: "reply:" + prompt}
+	}()
+	return ch, nil
+}
+
+func batchItems(prompts ...string) []BatchLLMItem {
+	items := make([]BatchLLMItem, len(prompts))
+	for i, prompt := range prompts {
+		items[i] = BatchLLMItem{
+			ID:       fmt.Sprintf("req%d", i+1),
+			Messages: []providers.Message{{Role: "user", Content: prompt}},
+		}
+	}
+	return items
+}
+
+func TestRunLLMBatchOrderAndConcurrency(t *testing.T) {
+	llm := &batchMockLLM{}
+	items := batchItems("a", "b", "fail", "c", "d", "e")
+
+	results, wait := runLLMBatch(context.Background(), llm, "test", items, 2)
+	wait()
+
+	if len(results) != len(items) {
+		t.Fatalf("结果数量 = %d, 期望 %d", len(results), len(items))
+	}
+	for i, result := range results {
+		if result.ID != items[i].ID {
+			t.Errorf("第%d个结果ID = %s, 期望 %s", i, result.ID, items[i].ID)
+		}
+		prompt := items[i].Messages[0].Content
+		if prompt == "fail" {
+			if result.Error == nil || result.Content != "" {
+				t.Errorf("失败的请求应只返回错误, 实际 %+v", result)
+			}
+			continue
+		}
+		if result.Error != nil || result.Content != "reply:"+prompt {
+			t.Errorf("第%d个结果 = %+v", i, result)
+		}
+	}
+
+	if llm.maxActive > 2 {
+		t.Errorf("最大并发数 = %d, 不应超过 2", llm.maxActive)
+	}
+}
+
+func TestRunLLMBatchTimeoutReturnsPartialResults(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	results, wait := runLLMBatch(ctx, &batchMockLLM{}, "test", batchItems("a", "hang"), 2)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("超时后应立即返回, 实际耗时 %v", elapsed)
+	}
+	wait()
+
+	if results[0].Error != nil || results[0].Content != "reply:a" {
+		t.Errorf("已完成的请求 = %+v", results[0])
+	}
+	if results[1].Error == nil {
+		t.Errorf("超时的请求应返回错误")
+	}
+}
@@ -0,0 +1,98 @@
+package app
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"angrymiao-ai-server/src/core"
+	"angrymiao-ai-server/src/core/transport"
+	"angrymiao-ai-server/src/core/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// mcpSignatureHeader 回调签名请求头，取值为 sha256=<hex(HMAC-SHA256(secret, body))>
+	mcpSignatureHeader = "X-Signature-256"
+	// maxMCPCallbackBodySize 回调请求体上限
+	maxMCPCallbackBodySize = 1 << 20
+)
+
+// signMCPCallback 计算回调请求体的签名
+func signMCPCallback(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// callbackResultText 将回调结果转换为工具输出文本
+func callbackResultText(req MCPCallbackRequest) string {
+	if req.Error != "" {
+		return "MCP工具调用失败: " + req.Error
+	}
+	var text string
+	if err := json.Unmarshal(req.Result, &text); err == nil {
+		return text
+	}
+	result := strings.TrimSpace(string(req.Result))
+	if result == "null" {
+		return ""
+	}
+	return result
+}
+
+// handleMCPCallback 接收MCP服务器异步回调的工具结果，校验签名后交给等待中的会话继续生成回复
+func (s *AppService) handleMCPCallback(c *gin.Context) {
+	secret := s.config.MCPCallback.Secret
+	if secret == "" {
+		utils.Custom(c, http.StatusForbidden, gin.H{"error": "mcp callback disabled"})
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxMCPCallbackBodySize))
+	if err != nil {
+		utils.Custom(c, http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+	sessionID, toolID := c.Param("session_id"), c.Param("tool_id")
+	if !hmac.Equal([]byte(c.GetHeader(mcpSignatureHeader)), []byte(signMCPCallback(secret, body))) {
+		s.logger.Warn("MCP回调签名校验失败, session: %s, tool_id: %s", sessionID, toolID)
+		utils.Custom(c, http.StatusUnauthorized, gin.H{"error": "invalid signature"})
+		return
+	}
+
+	var req MCPCallbackRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		s.logger.Error("MCP回调参数错误: %v", err)
+		utils.Custom(c, http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+	result := callbackResultText(req)
+	if result == "" {
+		utils.Custom(c, http.StatusBadRequest, gin.H{"error": "empty result"})
+		return
+	}
+
+	err = transport.GetSessionRegistry().DeliverToolCallback(sessionID, toolID, result)
+	switch {
+	case errors.Is(err, transport.ErrSessionNotFound):
+		utils.Custom(c, http.StatusNotFound, gin.H{"error": "session not found"})
+		return
+	case errors.Is(err, core.ErrToolCallbackNotFound):
+		utils.Custom(c, http.StatusNotFound, gin.H{"error": "callback not found"})
+		return
+	case err != nil:
+		s.logger.Error("投递MCP回调失败: %v", err)
+		utils.Custom(c, http.StatusInternalServerError, gin.H{"error": "deliver failed"})
+		return
+	}
+
+	s.logger.Info("MCP回调已投递, session: %s, tool_id: %s", sessionID, toolID)
+	utils.Custom(c, http.StatusOK, gin.H{"success": true})
+}
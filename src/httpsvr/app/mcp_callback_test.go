@@ -0,0 +1,91 @@
+package app
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"angrymiao-ai-server/src/configs"
+	"angrymiao-ai-server/src/core"
+	"angrymiao-ai-server/src/core/transport"
+	"angrymiao-ai-server/src/core/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// callbackConn 等待指定工具ID回调的会话
+type callbackConn struct {
+	toolID  string
+	results chan string
+}
+
+func (c *callbackConn) Handle()              {}
+func (c *callbackConn) Close()               {}
+func (c *callbackConn) GetSessionID() string { return "s1" }
+
+func (c *callbackConn) ResumeToolCallback(toolID string, result string) error {
+	if toolID != c.toolID {
+		return core.ErrToolCallbackNotFound
+	}
+	c.results <- result
+	return nil
+}
+
+func TestHandleMCPCallback(t *testing.T) {
+	logger, err := utils.NewLogger(&utils.LogCfg{LogLevel: "ERROR", LogDir: t.TempDir(), LogFile: "test.log"})
+	if err != nil {
+		t.Fatalf("创建日志失败: %v", err)
+	}
+	s := &AppService{logger: logger, config: &configs.Config{MCPCallback: configs.MCPCallbackConfig{Secret: "secret"}}}
+	conn := &callbackConn{toolID: "call-1", results: make(chan string, 1)}
+	registry := transport.GetSessionRegistry()
+	registry.Register(transport.SessionSummary{ID: "mcp-callback-c1", SessionID: "s1"}, conn)
+	defer registry.Unregister("mcp-callback-c1", conn)
+
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.POST("/api/v2/mcp/callback/:session_id/:tool_id", s.handleMCPCallback)
+
+	post := func(path, body, signature string) int {
+		req := httptest.NewRequest(http.MethodPost, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		if signature != "" {
+			req.Header.Set(mcpSignatureHeader, signature)
+		}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	body := `{"result":{"temperature":26}}`
+	tests := []struct {
+		name      string
+		path      string
+		signature string
+		want      int
+	}{
+		{"缺少签名", "/api/v2/mcp/callback/s1/call-1", "", http.StatusUnauthorized},
+		{"签名错误", "/api/v2/mcp/callback/s1/call-1", signMCPCallback("other", []byte(body)), http.StatusUnauthorized},
+		{"会话不存在", "/api/v2/mcp/callback/s2/call-1", signMCPCallback("secret", []byte(body)), http.StatusNotFound},
+		{"未等待的工具", "/api/v2/mcp/callback/s1/call-2", signMCPCallback("secret", []byte(body)), http.StatusNotFound},
+		{"投递成功", "/api/v2/mcp/callback/s1/call-1", signMCPCallback("secret", []byte(body)), http.StatusOK},
+	}
+	for _, tt := range tests {
+		if got := post(tt.path, body, tt.signature); got != tt.want {
+			t.Errorf("%s: 状态码 = %d, 期望 %d", tt.name, got, tt.want)
+		}
+	}
+	if got := <-conn.results; got != `{"temperature":26}` {
+		t.Errorf("投递的结果 = %q", got)
+	}
+
+	errBody := `{"error":"timeout"}`
+	conn.toolID = "call-3"
+	if got := post("/api/v2/mcp/callback/s1/call-3", errBody, signMCPCallback("secret", []byte(errBody))); got != http.StatusOK {
+		t.Fatalf("失败结果回调状态码 = %d", got)
+	}
+	if got := <-conn.results; got != "MCP工具调用失败: timeout" {
+		t.Errorf("失败结果 = %q", got)
+	}
+}
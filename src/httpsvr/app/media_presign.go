@@ -0,0 +1,63 @@
+package app
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"angrymiao-ai-server/src/configs/database"
+	"angrymiao-ai-server/src/core/media"
+	"angrymiao-ai-server/src/core/utils"
+	"angrymiao-ai-server/src/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// handlePresignMedia 为用户自己的媒体文件重新生成预签名GET地址
+// ttl 参数为有效期(秒)，默认取 presigned_url_ttl_seconds，最长86400
+func (s *AppService) handlePresignMedia(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	mediaID, err := utils.StringToUint(c.Param("id"))
+	if err != nil {
+		utils.Custom(c, http.StatusBadRequest, MediaPresignResponse{Success: false, Message: "媒体ID无效"})
+		return
+	}
+
+	ttl := media.PresignTTL(&s.config.OSS)
+	if raw := c.Query("ttl"); raw != "" {
+		seconds, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || seconds <= 0 || time.Duration(seconds)*time.Second > media.MaxPresignTTL {
+			utils.Custom(c, http.StatusBadRequest, MediaPresignResponse{Success: false, Message: "ttl 需为1~86400秒"})
+			return
+		}
+		ttl = time.Duration(seconds) * time.Second
+	}
+
+	if s.mediaPresign == nil {
+		utils.Custom(c, http.StatusServiceUnavailable, MediaPresignResponse{Success: false, Message: "未配置OSS访问密钥"})
+		return
+	}
+
+	var record models.MediaUpload
+	err = database.GetDB().Model(&models.MediaUpload{}).
+		Where("user_id = ? AND id = ?", userID, mediaID).
+		First(&record).Error
+	if err != nil || record.Path == "" {
+		utils.Custom(c, http.StatusNotFound, MediaPresignResponse{Success: false, Message: "媒体文件不存在"})
+		return
+	}
+
+	presigned, err := s.mediaPresign.Presign(c.Request.Context(), record.ID, strings.TrimLeft(record.Path, "/"), ttl)
+	if err != nil {
+		s.logger.Error("生成预签名地址失败: %v", err)
+		utils.Custom(c, http.StatusInternalServerError, MediaPresignResponse{Success: false, Message: "生成预签名地址失败"})
+		return
+	}
+	utils.Custom(c, http.StatusOK, MediaPresignResponse{
+		Success:      true,
+		ID:           record.ID,
+		PresignedURL: presigned.URL,
+		ExpiresAt:    &presigned.ExpiresAt,
+	})
+}
@@ -0,0 +1,108 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"angrymiao-ai-server/src/configs"
+	"angrymiao-ai-server/src/configs/database"
+	"angrymiao-ai-server/src/core/media"
+	"angrymiao-ai-server/src/core/utils"
+	"angrymiao-ai-server/src/models"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// fakeSigner 模拟OSS预签名
+type fakeSigner struct {
+	keys []string
+	ttls []time.Duration
+}
+
+func (s *fakeSigner) PresignGet(key string, ttl time.Duration) (string, error) {
+	s.keys = append(s.keys, key)
+	s.ttls = append(s.ttls, ttl)
+	return "https://media.oss/" + key + "?Signature=x", nil
+}
+
+func TestHandlePresignMedia(t *testing.T) {
+	logger, err := utils.NewLogger(&utils.LogCfg{LogLevel: "ERROR", LogDir: t.TempDir(), LogFile: "test.log"})
+	if err != nil {
+		t.Fatalf("创建日志失败: %v", err)
+	}
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "media.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("打开测试数据库失败: %v", err)
+	}
+	if err := db.AutoMigrate(&models.MediaUpload{}); err != nil {
+		t.Fatalf("迁移失败: %v", err)
+	}
+	original := database.DB
+	database.DB = db
+	t.Cleanup(func() { database.DB = original })
+	own := models.MediaUpload{UserID: 7, FileType: "image", Path: "u7/image/a.jpg"}
+	other := models.MediaUpload{UserID: 8, FileType: "image", Path: "u8/image/b.jpg"}
+	db.Create(&own)
+	db.Create(&other)
+
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+	signer := &fakeSigner{}
+	s := &AppService{
+		logger:       logger,
+		config:       &configs.Config{OSS: configs.OSSConfig{PresignedURLTTLSeconds: 1800}},
+		mediaPresign: media.NewPresignService(signer, client, "ai"),
+	}
+
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.GET("/api/v2/media/:id/presign", func(c *gin.Context) { c.Set("user_id", uint(7)) }, s.handlePresignMedia)
+	get := func(path string) (int, MediaPresignResponse) {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		var body struct {
+			Data MediaPresignResponse `json:"data"`
+		}
+		_ = json.Unmarshal(w.Body.Bytes(), &body)
+		return w.Code, body.Data
+	}
+
+	ownPath := "/api/v2/media/" + fmt.Sprint(own.ID) + "/presign"
+	code, resp := get(ownPath)
+	if code != http.StatusOK || resp.PresignedURL != "https://media.oss/u7/image/a.jpg?Signature=x" || resp.ExpiresAt == nil {
+		t.Fatalf("默认有效期: %d %+v", code, resp)
+	}
+	if len(signer.ttls) != 1 || signer.ttls[0] != 30*time.Minute {
+		t.Errorf("默认有效期应取配置的1800秒, got %v", signer.ttls)
+	}
+
+	// 再次请求命中Redis缓存
+	if code, again := get(ownPath); code != http.StatusOK || again.PresignedURL != resp.PresignedURL || len(signer.keys) != 1 {
+		t.Errorf("缓存: %d %+v, 签名次数 %d", code, again, len(signer.keys))
+	}
+	if !mr.Exists("ai:media_presign:" + fmt.Sprint(own.ID) + ":1800") {
+		t.Error("预签名地址未写入Redis缓存")
+	}
+
+	if code, _ := get(ownPath + "?ttl=7200"); code != http.StatusOK || signer.ttls[len(signer.ttls)-1] != 2*time.Hour {
+		t.Errorf("ttl=7200: %d, 签名有效期 %v", code, signer.ttls)
+	}
+	for _, ttl := range []string{"0", "86401", "abc"} {
+		if code, _ := get(ownPath + "?ttl=" + ttl); code != http.StatusBadRequest {
+			t.Errorf("ttl=%s 状态码 = %d, 期望 400", ttl, code)
+		}
+	}
+	if code, _ := get("/api/v2/media/" + fmt.Sprint(other.ID) + "/presign"); code != http.StatusNotFound {
+		t.Errorf("其他用户的媒体状态码 = %d, 期望 404", code)
+	}
+}
@@ -3,17 +3,22 @@ package app
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
+	"sync/atomic"
 
 	"angrymiao-ai-server/src/configs"
+	"angrymiao-ai-server/src/configs/cache"
 	"angrymiao-ai-server/src/configs/database"
 	"angrymiao-ai-server/src/core/chat"
+	"angrymiao-ai-server/src/core/media"
 	"angrymiao-ai-server/src/core/middleware"
 	"angrymiao-ai-server/src/core/pool"
 	"angrymiao-ai-server/src/core/providers"
 	"angrymiao-ai-server/src/core/providers/auc"
+	"angrymiao-ai-server/src/core/providers/auc/doubao"
 	"angrymiao-ai-server/src/core/providers/llm"
 	"angrymiao-ai-server/src/core/utils"
 	"angrymiao-ai-server/src/httpsvr/bot"
@@ -21,15 +26,19 @@ import (
 	"angrymiao-ai-server/src/models"
 
 	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
 )
 
 type AppService struct {
 	logger        *utils.Logger
 	config        *configs.Config
 	deviceDB      *device.DeviceDB
-	poolMgr       *pool.PoolManager
+	poolMgr       atomic.Pointer[pool.PoolManager] // 热升级时原子替换
 	botService    bot.BotConfigService
 	friendService UserFriendService
+	redisCache    *redis.Client
+
+	mediaPresign *media.PresignService // 私有媒体的预签名地址，未配置OSS密钥时为nil
 }
 
 func NewDefaultAppService(config *configs.Config, logger *utils.Logger) *AppService {
@@ -41,23 +50,62 @@ func NewDefaultAppService(config *configs.Config, logger *utils.Logger) *AppServ
 		botService:    bot.NewBotConfigService(db, logger),
 		friendService: NewUserFriendService(db, logger),
 	}
+	svc.redisCache = cache.GetRedis()
+	if presign, err := media.NewOSSPresignService(&config.OSS, svc.redisCache, config.RedisCache.Service); err != nil {
+		logger.Warn("初始化OSS预签名失败: %v", err)
+	} else {
+		svc.mediaPresign = presign
+	}
+	// 回调与轮询两种模式识别成功后都补充说话人分组与摘要
+	doubao.SetCompletionHook(svc.enrichAudioTask)
 	// 初始化资源池管理器（若失败不阻断启动，延迟到首次请求再尝试）
 	if pm, err := pool.NewPoolManager(config, logger); err == nil {
-		svc.poolMgr = pm
+		svc.poolMgr.Store(pm)
 	} else {
 		logger.Warn("初始化资源池管理器失败，将在请求时重试: %v", err)
 	}
 	return svc
 }
 
+// providerPool 获取当前资源池管理器，尚未初始化时创建
+func (s *AppService) providerPool() (*pool.PoolManager, error) {
+	if pm := s.poolMgr.Load(); pm != nil {
+		return pm, nil
+	}
+	pm, err := pool.NewPoolManager(s.config, s.logger)
+	if err != nil {
+		return nil, err
+	}
+	if !s.poolMgr.CompareAndSwap(nil, pm) {
+		// 并发请求已创建资源池
+		pm.Close()
+	}
+	return s.poolMgr.Load(), nil
+}
+
+// SwapPoolManager 原子替换资源池管理器，返回被替换的旧管理器
+// 替换前借出的提供者集合通过 ReleaseBack 归还到旧管理器
+func (s *AppService) SwapPoolManager(pm *pool.PoolManager) *pool.PoolManager {
+	return s.poolMgr.Swap(pm)
+}
+
 func (s *AppService) Start(ctx context.Context, engine *gin.Engine, apiGroup *gin.RouterGroup) {
 	// 注册chat相关路由
 	chatGroup := apiGroup.Group("/chat").Use(middleware.AmTokenJWTUserAuth())
 	{
-		chatGroup.POST("/send", s.handleChatSend)
+		chatGroup.POST("/send", middleware.UserRateLimit(), s.handleChatSend)
 		chatGroup.GET("/history", s.handleChatHistory)
 	}
 
+	chatV2Group := apiGroup.Group("/v2/chat").Use(middleware.AmTokenJWTUserAuth())
+	{
+		chatV2Group.POST("/import", s.handleChatImport)
+		chatV2Group.GET("/history", s.handleChatHistory)
+		chatV2Group.DELETE("/history", s.handleDeleteChatHistory)
+		chatV2Group.POST("/messages/:id/tags", s.handleSetMessageTags)
+		chatV2Group.GET("/tags", s.handleListTags)
+	}
+
 	appGroup := apiGroup.Group("/app").Use(middleware.AmTokenJWTUserAuth())
 	{
 		// 设备路由
@@ -68,8 +116,38 @@ func (s *AppService) Start(ctx context.Context, engine *gin.Engine, apiGroup *gi
 		appGroup.GET("/audio/recognition/:task_id", s.handleGetRecognitionResult)
 	}
 
+	mediaGroup := apiGroup.Group("/v2/media").Use(middleware.AmTokenJWTUserAuth())
+	{
+		mediaGroup.GET("/:id/waveform", s.handleGetMediaWaveform)
+		mediaGroup.GET("/:id/presign", s.handlePresignMedia)
+	}
+
+	audioTaskGroup := apiGroup.Group("/v2/audio-tasks").Use(middleware.AmTokenJWTUserAuth())
+	{
+		audioTaskGroup.GET("/:id/speakers", s.handleGetAudioTaskSpeakers)
+	}
+
+	llmGroup := apiGroup.Group("/v2/llm").Use(middleware.AmTokenJWTUserAuth())
+	{
+		llmGroup.POST("/batch", middleware.UserRateLimit(), s.handleLLMBatch)
+	}
+
+	voiceGroup := apiGroup.Group("/v2/voices").Use(middleware.AmTokenJWTUserAuth())
+	{
+		voiceGroup.POST("/clone", middleware.UserRateLimit(), s.handleCloneVoice)
+		voiceGroup.GET("", s.handleListVoices)
+		voiceGroup.DELETE("/:id", s.handleDeleteVoice)
+	}
+
+	usageGroup := apiGroup.Group("/v2/usage").Use(middleware.AmTokenJWTUserAuth())
+	{
+		usageGroup.GET("/tokens", s.handleGetTokenUsage)
+	}
+
 	// AUC回调
 	apiGroup.POST("/app/callback", s.handleAUCCallback)
+	// MCP工具异步回调，使用HMAC签名校验
+	apiGroup.POST("/v2/mcp/callback/:session_id/:tool_id", s.handleMCPCallback)
 }
 
 func (s *AppService) handleRecognition(c *gin.Context) {
@@ -233,54 +311,50 @@ func (s *AppService) handleAUCCallback(c *gin.Context) {
 
 	s.logger.Info("收到AUC回调, TaskID: %s, Code: %d", req.Resp.ID, req.Resp.Code)
 
-	// 查找对应的AudioTask
-	var audioTask models.AudioTask
-	err := database.GetDB().Where("auc_task_id = ?", req.Resp.ID).First(&audioTask).Error
-	if err != nil {
-		s.logger.Error("查找AudioTask失败: %v, TaskID: %s", err, req.Resp.ID)
-		utils.Custom(c, http.StatusNotFound, gin.H{"error": "task not found"})
+	if err := doubao.HandleCallback(&req.Resp); err != nil {
+		if errors.Is(err, doubao.ErrTaskNotFound) {
+			s.logger.Error("查找AudioTask失败: %v, TaskID: %s", err, req.Resp.ID)
+			utils.Custom(c, http.StatusNotFound, gin.H{"error": "task not found"})
+			return
+		}
+		s.logger.Error("更新AudioTask失败: %v", err)
+		utils.Custom(c, http.StatusInternalServerError, gin.H{"error": "update failed"})
 		return
 	}
 
-	// 更新任务状态
 	// 豆包 AUC 返回 code=1000 表示成功
 	if req.Resp.Code == 1000 {
-		audioTask.Status = models.AudioTaskStatusCompleted
-		audioTask.Text = req.Resp.Text
-
-		// 保存完整的识别结果到 JSON 字段（包含 utterances、words、speaker 等详细信息）
-		resultJSON, err := json.Marshal(req.Resp)
-		if err != nil {
-			s.logger.Warn("序列化识别结果失败: %v", err)
-		} else {
-			audioTask.ResultJSON = resultJSON
-		}
-
 		s.logger.Info("AUC任务完成, TaskID: %s, Text: %s, Utterances: %d",
 			req.Resp.ID, req.Resp.Text, len(req.Resp.Utterances))
-
-		// 调用AI生成摘要和关键点
-		if summary, keyPoints, err := s.generateSummaryAndKeyPoints(req.Resp.Text); err != nil {
-			s.logger.Warn("生成摘要失败: %v", err)
-		} else {
-			audioTask.Summary = summary
-			if keyPointsJSON, err := json.Marshal(keyPoints); err == nil {
-				audioTask.KeyPoints = keyPointsJSON
-			}
-		}
 	} else {
-		audioTask.Status = models.AudioTaskStatusFailed
 		s.logger.Error("AUC任务失败, TaskID: %s, Code: %d, Message: %s",
 			req.Resp.ID, req.Resp.Code, req.Resp.Message)
 	}
 
-	if err := database.GetDB().Save(&audioTask).Error; err != nil {
-		s.logger.Error("更新AudioTask失败: %v", err)
-		utils.Custom(c, http.StatusInternalServerError, gin.H{"error": "update failed"})
-		return
+	utils.Custom(c, http.StatusOK, gin.H{"success": true})
+}
+
+// enrichAudioTask 识别成功后按说话人分组保存发言，并调用AI生成摘要和关键点
+func (s *AppService) enrichAudioTask(audioTask *models.AudioTask, result *doubao.TaskResult) {
+	// 识别结果包含说话人信息时按说话人分组保存
+	transcript := ""
+	if speakers := groupUtterancesBySpeaker(result.Utterances); len(speakers) > 0 {
+		if speakersJSON, err := json.Marshal(speakers); err == nil {
+			audioTask.Speakers = speakersJSON
+		}
+		transcript = buildSpeakerTranscript(result.Utterances)
+		s.logger.Info("AUC任务识别到 %d 位说话人, TaskID: %s", len(speakers), result.ID)
 	}
 
-	utils.Custom(c, http.StatusOK, gin.H{"success": true})
+	// 调用AI生成摘要和关键点
+	if summary, keyPoints, err := s.generateSummaryAndKeyPoints(result.Text, transcript); err != nil {
+		s.logger.Warn("生成摘要失败: %v", err)
+	} else {
+		audioTask.Summary = summary
+		if keyPointsJSON, err := json.Marshal(keyPoints); err == nil {
+			audioTask.KeyPoints = keyPointsJSON
+		}
+	}
 }
 
 func (s *AppService) handleGetHomeMedia(c *gin.Context) {
@@ -455,18 +529,15 @@ func (s *AppService) handleChatSend(c *gin.Context) {
 	}
 
 	// 获取或初始化资源池管理器
-	if s.poolMgr == nil {
-		if pm, e := pool.NewPoolManager(s.config, s.logger); e == nil {
-			s.poolMgr = pm
-		} else {
-			s.logger.Error("初始化资源池失败: %v", e)
-			utils.Custom(c, http.StatusInternalServerError, ChatSendResponse{Success: false, Message: "服务内部错误"})
-			return
-		}
+	pm, err := s.providerPool()
+	if err != nil {
+		s.logger.Error("初始化资源池失败: %v", err)
+		utils.Custom(c, http.StatusInternalServerError, ChatSendResponse{Success: false, Message: "服务内部错误"})
+		return
 	}
 
 	// 从资源池获取LLM提供者
-	set, err := s.poolMgr.GetProviderSet()
+	set, err := pm.GetProviderSet()
 	if err != nil || set.LLM == nil {
 		if err != nil {
 			s.logger.Error("获取LLM提供者失败: %v", err)
@@ -556,9 +627,14 @@ func (s *AppService) handleChatHistory(c *gin.Context) {
 
 	userID := c.GetUint("user_id")
 
+	// bot 为Bot好友的 FunctionName，指定时只返回与该Bot的独立对话
+	bot := strings.TrimSpace(c.Query("bot"))
+	// tag 指定时只返回带该标签的消息
+	tag := strings.TrimSpace(c.Query("tag"))
+
 	// 从 Postgres 倒序分页读取历史
-	pm := chat.NewPostgresMemory(fmt.Sprintf("%d", userID))
-	pageItems, total64, err := pm.QueryMessages("DESC", page, pageSize)
+	pm := chat.NewPostgresMemory(chat.DialogueNamespace(fmt.Sprintf("%d", userID), bot))
+	pageItems, total64, err := pm.QueryMessages("DESC", page, pageSize, tag)
 	if err != nil {
 		s.logger.Error("查询对话记忆失败: %v", err)
 		utils.Custom(c, http.StatusInternalServerError, ChatHistoryResponse{Success: false, Message: "查询失败"})
@@ -645,27 +721,28 @@ func (s *AppService) applyUserLLMConfig(llmProvider providers.LLMProvider, userC
 }
 
 // generateSummaryAndKeyPoints 调用LLM生成摘要和关键点
-func (s *AppService) generateSummaryAndKeyPoints(text string) (string, []string, error) {
+// transcript 为带说话人标注的对话文本，非空时替代原始文本放入提示词
+func (s *AppService) generateSummaryAndKeyPoints(text string, transcript string) (string, []string, error) {
 	// 获取或初始化资源池管理器
-	if s.poolMgr == nil {
-		if pm, e := pool.NewPoolManager(s.config, s.logger); e == nil {
-			s.poolMgr = pm
-		} else {
-			return "", nil, fmt.Errorf("初始化资源池失败: %v", e)
-		}
+	pm, err := s.providerPool()
+	if err != nil {
+		return "", nil, fmt.Errorf("初始化资源池失败: %v", err)
 	}
 
 	// 从资源池获取LLM提供者
-	set, err := s.poolMgr.GetProviderSet()
+	set, err := pm.GetProviderSet()
 	if err != nil || set.LLM == nil {
 		return "", nil, fmt.Errorf("获取LLM提供者失败: %v", err)
 	}
 	llmProvider := set.LLM
 
 	// 构建提示词，要求返回JSON格式
+	content := "文本内容：\n" + text
+	if transcript != "" {
+		content = "对话内容（已标注说话人，请在摘要和关键点中体现各说话人的观点）：\n" + transcript
+	}
 	prompt := fmt.Sprintf(`请分析以下语音识别的文本内容，生成摘要和关键点。
 
-文本内容：
 %s
 
 请以JSON格式返回结果，格式如下：
@@ -677,7 +754,7 @@ func (s *AppService) generateSummaryAndKeyPoints(text string) (string, []string,
 注意：
 1. 摘要要简洁明了，突出核心内容
 2. 关键点提取3-5个最重要的信息点
-3. 只返回JSON，不要有其他文字`, text)
+3. 只返回JSON，不要有其他文字`, content)
 
 	messages := []providers.Message{
 		{
@@ -0,0 +1,94 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"angrymiao-ai-server/src/configs/database"
+	"angrymiao-ai-server/src/core/utils"
+	"angrymiao-ai-server/src/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// groupUtterancesBySpeaker 按说话人分组识别结果，说话人按首次出现顺序编号为 SPEAKER_0、SPEAKER_1...
+// 识别结果不包含说话人信息时返回nil
+func groupUtterancesBySpeaker(utterances []Utterance) []SpeakerInfo {
+	var speakers []SpeakerInfo
+	index := make(map[string]int)
+	for _, u := range utterances {
+		rawSpeaker := u.Additions["speaker"]
+		if rawSpeaker == "" {
+			continue
+		}
+		i, ok := index[rawSpeaker]
+		if !ok {
+			i = len(speakers)
+			index[rawSpeaker] = i
+			speakers = append(speakers, SpeakerInfo{Speaker: fmt.Sprintf("SPEAKER_%d", i)})
+		}
+		speakers[i].Segments = append(speakers[i].Segments, SpeakerSegment{
+			Start: float64(u.StartTime) / 1000,
+			End:   float64(u.EndTime) / 1000,
+			Text:  u.Text,
+		})
+	}
+	return speakers
+}
+
+// buildSpeakerTranscript 按时间顺序生成带说话人标注的对话文本
+func buildSpeakerTranscript(utterances []Utterance) string {
+	var sb strings.Builder
+	index := make(map[string]int)
+	lastSpeaker := ""
+	for _, u := range utterances {
+		rawSpeaker := u.Additions["speaker"]
+		if rawSpeaker == "" || u.Text == "" {
+			continue
+		}
+		if _, ok := index[rawSpeaker]; !ok {
+			index[rawSpeaker] = len(index)
+		}
+		verb := "said"
+		if lastSpeaker != "" && lastSpeaker != rawSpeaker {
+			verb = "replied"
+		}
+		lastSpeaker = rawSpeaker
+		fmt.Fprintf(&sb, "SPEAKER_%d %s: %s\n", index[rawSpeaker], verb, u.Text)
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+// handleGetAudioTaskSpeakers 获取识别任务按说话人分组的结果
+func (s *AppService) handleGetAudioTaskSpeakers(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	taskID, err := utils.StringToUint(c.Param("id"))
+	if err != nil {
+		utils.Custom(c, http.StatusBadRequest, SpeakersResponse{Success: false, Message: "任务ID无效"})
+		return
+	}
+
+	var audioTask models.AudioTask
+	err = database.GetDB().Where("user_id = ? AND id = ?", userID, taskID).First(&audioTask).Error
+	if err != nil {
+		utils.Custom(c, http.StatusNotFound, SpeakersResponse{Success: false, Message: "任务不存在"})
+		return
+	}
+
+	speakers := []SpeakerInfo{}
+	if len(audioTask.Speakers) > 0 {
+		if err := json.Unmarshal(audioTask.Speakers, &speakers); err != nil {
+			s.logger.Error("解析说话人数据失败: %v, TaskID: %d", err, audioTask.ID)
+			utils.Custom(c, http.StatusInternalServerError, SpeakersResponse{Success: false, Message: "解析说话人数据失败"})
+			return
+		}
+	}
+
+	utils.Custom(c, http.StatusOK, SpeakersResponse{
+		Success:  true,
+		TaskID:   audioTask.ID,
+		Speakers: speakers,
+	})
+}
@@ -0,0 +1,60 @@
+package app
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// 豆包AUC回调示例，开启 with_speaker_info 后 additions 中包含 speaker
+const sampleDoubaoCallback = `{
+  "resp": {
+    "id": "fc5aa03e-6ae4-46a3-b8cf-1910a44e0d8a",
+    "code": 1000,
+    "message": "Success",
+    "text": "你好，今天开会吗？下午三点开。好的，我准时到。",
+    "utterances": [
+      {"text": "你好，今天开会吗？", "start_time": 0, "end_time": 2100, "additions": {"speaker": "1"}},
+      {"text": "下午三点开。", "start_time": 2300, "end_time": 3800, "additions": {"speaker": "2"}},
+      {"text": "好的，我准时到。", "start_time": 4000, "end_time": 5600, "additions": {"speaker": "1"}}
+    ]
+  }
+}`
+
+func TestGroupUtterancesBySpeaker(t *testing.T) {
+	var req AUCCallbackRequest
+	if err := json.Unmarshal([]byte(sampleDoubaoCallback), &req); err != nil {
+		t.Fatalf("解析回调失败: %v", err)
+	}
+
+	speakers := groupUtterancesBySpeaker(req.Resp.Utterances)
+	if len(speakers) != 2 {
+		t.Fatalf("说话人数量 = %d, 期望 2", len(speakers))
+	}
+	if speakers[0].Speaker != "SPEAKER_0" || speakers[1].Speaker != "SPEAKER_1" {
+		t.Errorf("说话人编号 = %s, %s", speakers[0].Speaker, speakers[1].Speaker)
+	}
+	if len(speakers[0].Segments) != 2 || len(speakers[1].Segments) != 1 {
+		t.Fatalf("发言片段数量 = %d, %d, 期望 2, 1", len(speakers[0].Segments), len(speakers[1].Segments))
+	}
+	seg := speakers[0].Segments[0]
+	if seg.Start != 0 || seg.End != 2.1 || seg.Text != "你好，今天开会吗？" {
+		t.Errorf("首个片段 = %+v", seg)
+	}
+
+	expected := "SPEAKER_0 said: 你好，今天开会吗？\n" +
+		"SPEAKER_1 replied: 下午三点开。\n" +
+		"SPEAKER_0 replied: 好的，我准时到。"
+	if got := buildSpeakerTranscript(req.Resp.Utterances); got != expected {
+		t.Errorf("对话文本 = %q, 期望 %q", got, expected)
+	}
+}
+
+func TestGroupUtterancesWithoutSpeaker(t *testing.T) {
+	utterances := []Utterance{{Text: "你好", StartTime: 0, EndTime: 500}}
+	if speakers := groupUtterancesBySpeaker(utterances); speakers != nil {
+		t.Errorf("无说话人信息时应返回nil, 实际 %+v", speakers)
+	}
+	if transcript := buildSpeakerTranscript(utterances); transcript != "" {
+		t.Errorf("无说话人信息时对话文本应为空, 实际 %q", transcript)
+	}
+}
@@ -0,0 +1,35 @@
+package app
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"angrymiao-ai-server/src/core/tokenusage"
+	"angrymiao-ai-server/src/core/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// handleGetTokenUsage 查询当前用户今日的LLM token用量与剩余预算
+func (s *AppService) handleGetTokenUsage(c *gin.Context) {
+	tracker := tokenusage.Get()
+	if tracker == nil {
+		utils.Custom(c, http.StatusServiceUnavailable, TokenUsageResponse{Success: false, Message: "未启用token用量统计"})
+		return
+	}
+	userID := strconv.FormatUint(uint64(c.GetUint("user_id")), 10)
+	status, err := tracker.Status(c.Request.Context(), userID)
+	if err != nil {
+		s.logger.Error("查询token用量失败: %v, UserID: %s", err, userID)
+		utils.Custom(c, http.StatusInternalServerError, TokenUsageResponse{Success: false, Message: "查询token用量失败"})
+		return
+	}
+	utils.Custom(c, http.StatusOK, TokenUsageResponse{
+		Success:   true,
+		Used:      status.Used,
+		Budget:    status.Budget,
+		Remaining: status.Remaining,
+		ResetAt:   status.ResetAt.Format(time.RFC3339),
+	})
+}
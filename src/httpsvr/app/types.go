@@ -1,8 +1,15 @@
 package app
 
 import (
+	"encoding/json"
+	"time"
+
 	"angrymiao-ai-server/src/core/chat"
+	"angrymiao-ai-server/src/core/providers"
+	"angrymiao-ai-server/src/core/providers/auc/doubao"
 	"angrymiao-ai-server/src/models"
+
+	"github.com/angrymiao/go-openai"
 )
 
 type GetDevicesResponse struct {
@@ -78,25 +85,142 @@ type RecognitionResponse struct {
 }
 
 type AUCCallbackRequest struct {
-	Resp struct {
-		ID         string      `json:"id"`
-		Code       int         `json:"code"`
-		Message    string      `json:"message,omitempty"`
-		Text       string      `json:"text,omitempty"`
-		Utterances []Utterance `json:"utterances,omitempty"`
-	} `json:"resp"`
-}
-
-type Utterance struct {
-	Text      string            `json:"text"`
-	StartTime int               `json:"start_time"`
-	EndTime   int               `json:"end_time"`
-	Words     []Word            `json:"words,omitempty"`
-	Additions map[string]string `json:"additions,omitempty"`
-}
-
-type Word struct {
-	Text      string `json:"text"`
-	StartTime int    `json:"start_time"`
-	EndTime   int    `json:"end_time"`
+	Resp doubao.TaskResult `json:"resp"`
+}
+
+// MCPCallbackRequest MCP服务器回调的工具执行结果，result 为字符串或任意JSON，失败时填写 error
+type MCPCallbackRequest struct {
+	Result json.RawMessage `json:"result"`
+	Error  string          `json:"error,omitempty"`
+}
+
+type Utterance = doubao.Utterance
+
+type Word = doubao.Word
+
+type WaveformResponse struct {
+	Success         bool      `json:"success"`
+	Message         string    `json:"message,omitempty"`
+	DurationSeconds float64   `json:"duration_seconds"`
+	Waveform        []float64 `json:"waveform"`
+}
+
+// SpeakerSegment 说话人的一段发言，时间单位为秒
+type SpeakerSegment struct {
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	Text  string  `json:"text"`
+}
+
+// SpeakerInfo 按说话人分组的识别结果
+type SpeakerInfo struct {
+	Speaker  string           `json:"speaker"`
+	Segments []SpeakerSegment `json:"segments"`
+}
+
+type SpeakersResponse struct {
+	Success  bool          `json:"success"`
+	Message  string        `json:"message,omitempty"`
+	TaskID   uint          `json:"task_id,omitempty"`
+	Speakers []SpeakerInfo `json:"speakers"`
+}
+
+// BatchLLMItem 批量推理中的单个请求
+type BatchLLMItem struct {
+	ID       string              `json:"id"`
+	Messages []providers.Message `json:"messages"`
+	Tools    []openai.Tool       `json:"tools"`
+}
+
+type BatchLLMRequest struct {
+	Requests       []BatchLLMItem `json:"requests" binding:"required"`
+	MaxConcurrency int            `json:"max_concurrency"`
+}
+
+// BatchLLMResult 单个请求的结果，失败或超时时 error 非空
+type BatchLLMResult struct {
+	ID      string  `json:"id"`
+	Content string  `json:"content"`
+	Error   *string `json:"error"`
+}
+
+type BatchLLMResponse struct {
+	Success bool             `json:"success"`
+	Message string           `json:"message,omitempty"`
+	Results []BatchLLMResult `json:"results"`
+}
+
+// ChatImportMessage 外部对话记录中的单条消息
+type ChatImportMessage struct {
+	Role      string `json:"role"`
+	Content   string `json:"content"`
+	CreatedAt string `json:"created_at"` // RFC3339
+}
+
+type ChatImportResponse struct {
+	Success  bool   `json:"success"`
+	Message  string `json:"message,omitempty"`
+	Imported int    `json:"imported"`
+}
+
+// ChatHistoryDeleteResponse 删除对话历史的结果
+type ChatHistoryDeleteResponse struct {
+	Success          bool   `json:"success"`
+	Message          string `json:"message,omitempty"`
+	DeletedMessages  int64  `json:"deleted_messages"`
+	DeletedSnapshots int64  `json:"deleted_snapshots"`
+}
+
+// ChatTagsRequest 设置消息标签，空列表表示清除标签
+type ChatTagsRequest struct {
+	Tags []string `json:"tags"`
+}
+
+type ChatTagsResponse struct {
+	Success bool     `json:"success"`
+	Message string   `json:"message,omitempty"`
+	ID      uint     `json:"id,omitempty"`
+	Tags    []string `json:"tags"`
+}
+
+// ChatTagCount 标签及使用该标签的消息数
+type ChatTagCount struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+type ChatTagListResponse struct {
+	Success bool           `json:"success"`
+	Message string         `json:"message,omitempty"`
+	Tags    []ChatTagCount `json:"tags"`
+}
+
+// TokenUsageResponse 用户当日的LLM token用量
+type TokenUsageResponse struct {
+	Success   bool   `json:"success"`
+	Message   string `json:"message,omitempty"`
+	Used      int64  `json:"used"`
+	Budget    int64  `json:"budget"`    // 0 表示不限制
+	Remaining int64  `json:"remaining"` // 不限制时为 -1
+	ResetAt   string `json:"reset_at,omitempty"`
+}
+
+type VoiceCloneResponse struct {
+	Success bool              `json:"success"`
+	Message string            `json:"message,omitempty"`
+	Voice   *models.UserVoice `json:"voice,omitempty"`
+}
+
+type VoiceListResponse struct {
+	Success bool               `json:"success"`
+	Message string             `json:"message,omitempty"`
+	Voices  []models.UserVoice `json:"voices,omitempty"`
+}
+
+type MediaPresignResponse struct {
+	Success      bool       `json:"success"`
+	Message      string     `json:"message,omitempty"`
+	ID           uint       `json:"id,omitempty"`
+	PresignedURL string     `json:"presigned_url,omitempty"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
 }
@@ -0,0 +1,256 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"angrymiao-ai-server/src/configs/database"
+	"angrymiao-ai-server/src/core/providers"
+	"angrymiao-ai-server/src/core/utils"
+	"angrymiao-ai-server/src/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"gorm.io/gorm"
+)
+
+const (
+	minCloneAudioSeconds   = 10
+	maxCloneAudioSeconds   = 30
+	minCloneSampleRate     = 16000
+	maxCloneAudioSize      = 10 * 1024 * 1024
+	voiceCloneTimeout      = 60 * time.Second
+	defaultCloneLanguage   = "zh"
+	maxCloneErrorMsgLength = 512
+)
+
+var (
+	errVoiceNotFound   = errors.New("音色不存在")
+	errVoiceNameExists = errors.New("音色名称已存在")
+)
+
+// handleCloneVoice 上传WAV录音复刻音色
+// multipart 表单：audio 为WAV文件，metadata 为JSON {"voice_name","language"}
+func (s *AppService) handleCloneVoice(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	var req models.CloneVoiceRequest
+	if err := binding.JSON.BindBody([]byte(c.PostForm("metadata")), &req); err != nil {
+		utils.Custom(c, http.StatusBadRequest, VoiceCloneResponse{Success: false, Message: "请求参数错误: " + err.Error()})
+		return
+	}
+	if req.Language == "" {
+		req.Language = defaultCloneLanguage
+	}
+
+	file, header, err := c.Request.FormFile("audio")
+	if err != nil {
+		utils.Custom(c, http.StatusBadRequest, VoiceCloneResponse{Success: false, Message: "缺少音频文件"})
+		return
+	}
+	defer file.Close()
+	if header.Size > maxCloneAudioSize {
+		utils.Custom(c, http.StatusBadRequest, VoiceCloneResponse{Success: false, Message: fmt.Sprintf("音频大小超过限制，最大允许%dMB", maxCloneAudioSize/1024/1024)})
+		return
+	}
+	audio, err := io.ReadAll(file)
+	if err != nil {
+		utils.Custom(c, http.StatusBadRequest, VoiceCloneResponse{Success: false, Message: "读取音频失败"})
+		return
+	}
+	if err := validateCloneAudio(audio); err != nil {
+		utils.Custom(c, http.StatusBadRequest, VoiceCloneResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		utils.Custom(c, http.StatusInternalServerError, VoiceCloneResponse{Success: false, Message: "数据库未初始化"})
+		return
+	}
+
+	// 获取或初始化资源池管理器
+	pm, err := s.providerPool()
+	if err != nil {
+		s.logger.Error("初始化资源池失败: %v", err)
+		utils.Custom(c, http.StatusInternalServerError, VoiceCloneResponse{Success: false, Message: "服务内部错误"})
+		return
+	}
+	set, err := pm.GetProviderSet()
+	if err != nil || set.TTS == nil {
+		if err != nil {
+			s.logger.Error("获取TTS提供者失败: %v", err)
+		}
+		utils.Custom(c, http.StatusInternalServerError, VoiceCloneResponse{Success: false, Message: "TTS服务不可用"})
+		return
+	}
+	defer func() {
+		if err := set.ReleaseBack(); err != nil {
+			s.logger.Warn("归还声音复刻资源失败: %v", err)
+		}
+	}()
+
+	voice, err := cloneUserVoice(c.Request.Context(), db, set.TTS, s.config.SelectedModule["TTS"], userID, audio, req)
+	switch {
+	case errors.Is(err, errVoiceNameExists):
+		utils.Custom(c, http.StatusConflict, VoiceCloneResponse{Success: false, Message: err.Error()})
+	case errors.Is(err, providers.ErrVoiceCloneNotSupported):
+		utils.Custom(c, http.StatusBadRequest, VoiceCloneResponse{Success: false, Message: "当前TTS服务不支持声音复刻", Voice: voice})
+	case err != nil:
+		s.logger.Error("用户 %d 复刻音色 %s 失败: %v", userID, req.VoiceName, err)
+		utils.Custom(c, http.StatusBadGateway, VoiceCloneResponse{Success: false, Message: "声音复刻失败", Voice: voice})
+	default:
+		s.logger.Info("用户 %d 复刻音色成功: %s -> %s", userID, voice.VoiceName, voice.VoiceID)
+		utils.Custom(c, http.StatusOK, VoiceCloneResponse{Success: true, Voice: voice})
+	}
+}
+
+// validateCloneAudio 校验复刻录音为10~30秒、采样率不低于16kHz的WAV
+func validateCloneAudio(audio []byte) error {
+	sampleRate, err := utils.GetWAVSampleRate(audio)
+	if err != nil {
+		return fmt.Errorf("音频必须为WAV格式: %v", err)
+	}
+	if sampleRate < minCloneSampleRate {
+		return fmt.Errorf("音频采样率不能低于%dHz，当前为%dHz", minCloneSampleRate, sampleRate)
+	}
+	duration, err := utils.GetAudioDuration(audio, "wav")
+	if err != nil {
+		return fmt.Errorf("解析音频时长失败: %v", err)
+	}
+	if duration < minCloneAudioSeconds || duration > maxCloneAudioSeconds {
+		return fmt.Errorf("音频时长须在%d到%d秒之间，当前为%.1f秒", minCloneAudioSeconds, maxCloneAudioSeconds, duration)
+	}
+	return nil
+}
+
+// cloneUserVoice 调用TTS提供者复刻音色并记录结果
+// 先写入处理中的记录，复刻完成后更新为 ready 或 failed；同名且未失败的音色已存在时返回 errVoiceNameExists
+func cloneUserVoice(
+	ctx context.Context,
+	db *gorm.DB,
+	tts providers.TTSProvider,
+	provider string,
+	userID uint,
+	audio []byte,
+	req models.CloneVoiceRequest,
+) (*models.UserVoice, error) {
+	var count int64
+	if err := db.WithContext(ctx).Model(&models.UserVoice{}).
+		Where("user_id = ? AND voice_name = ? AND status <> ?", userID, req.VoiceName, models.UserVoiceStatusFailed).
+		Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, errVoiceNameExists
+	}
+
+	voice := &models.UserVoice{
+		UserID:    userID,
+		VoiceName: req.VoiceName,
+		Provider:  provider,
+		Language:  req.Language,
+		Status:    models.UserVoiceStatusProcessing,
+	}
+	if err := db.WithContext(ctx).Create(voice).Error; err != nil {
+		return nil, err
+	}
+
+	cloneCtx, cancel := context.WithTimeout(ctx, voiceCloneTimeout)
+	defer cancel()
+	voiceID, cloneErr := tts.CloneVoice(cloneCtx, audio, req.VoiceName, req.Language)
+
+	updates := map[string]interface{}{"updated_at": time.Now()}
+	if cloneErr != nil {
+		msg := cloneErr.Error()
+		if len(msg) > maxCloneErrorMsgLength {
+			msg = msg[:maxCloneErrorMsgLength]
+		}
+		voice.Status, voice.ErrorMessage = models.UserVoiceStatusFailed, msg
+		updates["error_message"] = msg
+	} else {
+		voice.Status, voice.VoiceID = models.UserVoiceStatusReady, voiceID
+		updates["voice_id"] = voiceID
+	}
+	updates["status"] = voice.Status
+	// 请求可能已被取消，结果仍需落库
+	if err := db.WithContext(context.WithoutCancel(ctx)).Model(voice).Updates(updates).Error; err != nil {
+		return voice, err
+	}
+	return voice, cloneErr
+}
+
+// handleListVoices 列出用户复刻的音色
+func (s *AppService) handleListVoices(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	db := database.GetDB()
+	if db == nil {
+		utils.Custom(c, http.StatusInternalServerError, VoiceListResponse{Success: false, Message: "数据库未初始化"})
+		return
+	}
+
+	voices := []models.UserVoice{}
+	if err := db.WithContext(c.Request.Context()).
+		Where("user_id = ?", userID).
+		Order("created_at DESC").
+		Find(&voices).Error; err != nil {
+		s.logger.Error("查询用户 %d 的音色失败: %v", userID, err)
+		utils.Custom(c, http.StatusInternalServerError, VoiceListResponse{Success: false, Message: "查询音色失败"})
+		return
+	}
+	utils.Custom(c, http.StatusOK, VoiceListResponse{Success: true, Voices: voices})
+}
+
+// handleDeleteVoice 删除用户复刻的音色，使用该音色的Bot恢复默认音色
+func (s *AppService) handleDeleteVoice(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.Custom(c, http.StatusBadRequest, VoiceListResponse{Success: false, Message: "无效的音色ID"})
+		return
+	}
+	userID := c.GetUint("user_id")
+	db := database.GetDB()
+	if db == nil {
+		utils.Custom(c, http.StatusInternalServerError, VoiceListResponse{Success: false, Message: "数据库未初始化"})
+		return
+	}
+
+	if err := deleteUserVoice(c.Request.Context(), db, userID, uint(id)); err != nil {
+		if errors.Is(err, errVoiceNotFound) {
+			utils.Custom(c, http.StatusNotFound, VoiceListResponse{Success: false, Message: err.Error()})
+			return
+		}
+		s.logger.Error("删除用户 %d 的音色 %d 失败: %v", userID, id, err)
+		utils.Custom(c, http.StatusInternalServerError, VoiceListResponse{Success: false, Message: "删除音色失败"})
+		return
+	}
+	utils.Custom(c, http.StatusOK, VoiceListResponse{Success: true, Message: "删除成功"})
+}
+
+// deleteUserVoice 删除音色记录并清除用户Bot好友上对该音色的引用
+func deleteUserVoice(ctx context.Context, db *gorm.DB, userID, id uint) error {
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var voice models.UserVoice
+		if err := tx.Where("id = ? AND user_id = ?", id, userID).First(&voice).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errVoiceNotFound
+			}
+			return err
+		}
+		if err := tx.Delete(&voice).Error; err != nil {
+			return err
+		}
+		if strings.TrimSpace(voice.VoiceID) == "" {
+			return nil
+		}
+		return tx.Model(&models.UserFriend{}).
+			Where("user_id = ? AND tts_voice = ?", userID, voice.VoiceID).
+			Update("tts_voice", "").Error
+	})
+}
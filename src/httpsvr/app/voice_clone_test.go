@@ -0,0 +1,157 @@
+package app
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"angrymiao-ai-server/src/core/providers"
+	"angrymiao-ai-server/src/core/utils"
+	"angrymiao-ai-server/src/models"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// cloneMockTTS 返回固定音色ID或错误的TTS提供者
+type cloneMockTTS struct {
+	providers.TTSProvider
+	voiceID string
+	err     error
+	names   []string
+}
+
+func (p *cloneMockTTS) CloneVoice(ctx context.Context, audioData []byte, name, language string) (string, error) {
+	p.names = append(p.names, name)
+	return p.voiceID, p.err
+}
+
+// testWAV 生成指定采样率与时长的16位单声道静音WAV
+func testWAV(sampleRate, seconds int) []byte {
+	dataSize := sampleRate * 2 * seconds
+	buf := make([]byte, 44+dataSize)
+	copy(buf[0:], "RIFF")
+	binary.LittleEndian.PutUint32(buf[4:], uint32(36+dataSize))
+	copy(buf[8:], "WAVEfmt ")
+	binary.LittleEndian.PutUint32(buf[16:], 16)
+	binary.LittleEndian.PutUint16(buf[20:], 1)
+	binary.LittleEndian.PutUint16(buf[22:], 1)
+	binary.LittleEndian.PutUint32(buf[24:], uint32(sampleRate))
+	binary.LittleEndian.PutUint32(buf[28:], uint32(sampleRate*2))
+	binary.LittleEndian.PutUint16(buf[32:], 2)
+	binary.LittleEndian.PutUint16(buf[34:], 16)
+	copy(buf[36:], "data")
+	binary.LittleEndian.PutUint32(buf[40:], uint32(dataSize))
+	return buf
+}
+
+func newVoiceTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "voices.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("打开测试数据库失败: %v", err)
+	}
+	if err := db.AutoMigrate(&models.UserVoice{}, &models.UserFriend{}); err != nil {
+		t.Fatalf("迁移失败: %v", err)
+	}
+	return db
+}
+
+func TestValidateCloneAudio(t *testing.T) {
+	if err := validateCloneAudio(testWAV(16000, 12)); err != nil {
+		t.Errorf("12秒16kHz录音应通过校验: %v", err)
+	}
+	for name, audio := range map[string][]byte{
+		"采样率过低": testWAV(8000, 12),
+		"时长过短":  testWAV(16000, 5),
+		"时长过长":  testWAV(16000, 31),
+		"非WAV":  []byte("not a wav file, just some bytes padding to 44 bytes......"),
+	} {
+		if err := validateCloneAudio(audio); err == nil {
+			t.Errorf("%s 应校验失败", name)
+		}
+	}
+}
+
+func TestCloneUserVoicePersistsResult(t *testing.T) {
+	db := newVoiceTestDB(t)
+	ctx := context.Background()
+	tts := &cloneMockTTS{voiceID: "S_abc123"}
+	req := models.CloneVoiceRequest{VoiceName: "我的声音", Language: "zh"}
+
+	voice, err := cloneUserVoice(ctx, db, tts, "DoubaoTTS", 7, testWAV(16000, 12), req)
+	if err != nil {
+		t.Fatalf("复刻音色失败: %v", err)
+	}
+	var stored models.UserVoice
+	db.First(&stored, voice.ID)
+	if stored.Status != models.UserVoiceStatusReady || stored.VoiceID != "S_abc123" || stored.UserID != 7 || stored.Provider != "DoubaoTTS" {
+		t.Errorf("复刻成功的记录 = %+v", stored)
+	}
+
+	// 同名音色已存在时拒绝，且不调用提供者
+	if _, err := cloneUserVoice(ctx, db, tts, "DoubaoTTS", 7, nil, req); !errors.Is(err, errVoiceNameExists) {
+		t.Errorf("重复的音色名称应返回 errVoiceNameExists, got %v", err)
+	}
+	if len(tts.names) != 1 {
+		t.Errorf("提供者调用次数 = %d, 期望 1", len(tts.names))
+	}
+
+	// 复刻失败时记录错误，失败的名称可以重试
+	failing := &cloneMockTTS{err: errors.New("quota exceeded")}
+	voice, err = cloneUserVoice(ctx, db, failing, "DoubaoTTS", 8, nil, req)
+	if err == nil {
+		t.Fatal("提供者返回错误时应返回错误")
+	}
+	var failed models.UserVoice
+	db.First(&failed, voice.ID)
+	if failed.Status != models.UserVoiceStatusFailed || failed.ErrorMessage != "quota exceeded" || failed.VoiceID != "" {
+		t.Errorf("复刻失败的记录 = %+v", failed)
+	}
+	if _, err := cloneUserVoice(ctx, db, tts, "DoubaoTTS", 8, nil, req); err != nil {
+		t.Errorf("失败后重试同名音色应成功: %v", err)
+	}
+}
+
+func TestBotFriendVoiceRequiresOwnedReadyVoice(t *testing.T) {
+	db := newVoiceTestDB(t)
+	logger, err := utils.NewLogger(&utils.LogCfg{LogLevel: "ERROR", LogDir: t.TempDir(), LogFile: "test.log"})
+	if err != nil {
+		t.Fatalf("创建日志失败: %v", err)
+	}
+	ctx := context.Background()
+	service := NewUserFriendService(db, logger)
+	if err := service.AddBotFriend(ctx, 7, 3, "", ""); err != nil {
+		t.Fatalf("添加Bot好友失败: %v", err)
+	}
+	db.Create(&models.UserVoice{UserID: 7, VoiceName: "a", VoiceID: "S_mine", Status: models.UserVoiceStatusReady})
+	db.Create(&models.UserVoice{UserID: 8, VoiceName: "b", VoiceID: "S_other", Status: models.UserVoiceStatusReady})
+
+	if err := service.UpdateBotFriendAppKey(ctx, 7, 3, "key", "S_other"); !errors.Is(err, errVoiceNotFound) {
+		t.Errorf("其他用户的音色应返回 errVoiceNotFound, got %v", err)
+	}
+	if err := service.UpdateBotFriendAppKey(ctx, 7, 3, "key", "S_mine"); err != nil {
+		t.Fatalf("设置音色失败: %v", err)
+	}
+	var friend models.UserFriend
+	db.Where("user_id = ? AND bot_config_id = ?", 7, 3).First(&friend)
+	if friend.TTSVoice != "S_mine" || friend.AppKey != "key" {
+		t.Errorf("Bot好友 = %+v", friend)
+	}
+
+	// 删除音色后Bot不再引用该音色
+	var voice models.UserVoice
+	db.Where("voice_id = ?", "S_mine").First(&voice)
+	if err := deleteUserVoice(ctx, db, 8, voice.ID); !errors.Is(err, errVoiceNotFound) {
+		t.Errorf("删除其他用户的音色应返回 errVoiceNotFound, got %v", err)
+	}
+	if err := deleteUserVoice(ctx, db, 7, voice.ID); err != nil {
+		t.Fatalf("删除音色失败: %v", err)
+	}
+	db.Where("user_id = ? AND bot_config_id = ?", 7, 3).First(&friend)
+	if friend.TTSVoice != "" {
+		t.Errorf("删除音色后 tts_voice = %q", friend.TTSVoice)
+	}
+}
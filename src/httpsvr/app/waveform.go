@@ -0,0 +1,151 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"angrymiao-ai-server/src/configs/database"
+	"angrymiao-ai-server/src/core/media"
+	"angrymiao-ai-server/src/core/utils"
+	"angrymiao-ai-server/src/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 波形缓存有效期
+const waveformCacheTTL = 24 * time.Hour
+
+// 未配置 waveform_max_file_size 时允许计算波形的最大文件大小
+const defaultWaveformMaxFileSize = 50 * 1024 * 1024
+
+func (s *AppService) waveformCacheKey(mediaID uint) string {
+	service := s.config.RedisCache.Service
+	if service == "" {
+		service = "ai"
+	}
+	return fmt.Sprintf("%s:waveform:%d", service, mediaID)
+}
+
+// handleGetMediaWaveform 获取音频文件的波形数据
+func (s *AppService) handleGetMediaWaveform(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	mediaID, err := utils.StringToUint(c.Param("id"))
+	if err != nil {
+		utils.Custom(c, http.StatusBadRequest, WaveformResponse{Success: false, Message: "媒体ID无效"})
+		return
+	}
+
+	var audioData models.MediaUpload
+	err = database.GetDB().Model(&models.MediaUpload{}).
+		Where("user_id = ? AND id = ? AND file_type = ?", userID, mediaID, "audio").
+		First(&audioData).Error
+	if err != nil {
+		utils.Custom(c, http.StatusNotFound, WaveformResponse{Success: false, Message: "音频文件不存在"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	cacheKey := s.waveformCacheKey(mediaID)
+	if s.redisCache != nil {
+		if cached, err := s.redisCache.Get(ctx, cacheKey).Bytes(); err == nil {
+			var resp WaveformResponse
+			if err := json.Unmarshal(cached, &resp); err == nil {
+				utils.Custom(c, http.StatusOK, resp)
+				return
+			}
+		}
+	}
+
+	fileData, err := s.readMediaFile(ctx, &audioData)
+	if errors.Is(err, errMediaFileTooLarge) {
+		utils.Custom(c, http.StatusRequestEntityTooLarge, WaveformResponse{Success: false, Message: "音频文件过大"})
+		return
+	}
+	if err != nil {
+		s.logger.Error("读取音频文件失败: %v", err)
+		utils.Custom(c, http.StatusInternalServerError, WaveformResponse{Success: false, Message: "读取音频文件失败"})
+		return
+	}
+
+	suffix := media.DetectFileSuffix(fileData, "audio")
+	duration, waveform, err := utils.ComputeWaveform(fileData, suffix, utils.DefaultWaveformPoints)
+	if err != nil {
+		s.logger.Error("计算音频波形失败: %v", err)
+		utils.Custom(c, http.StatusUnprocessableEntity, WaveformResponse{Success: false, Message: "无法解析音频文件"})
+		return
+	}
+
+	resp := WaveformResponse{
+		Success:         true,
+		DurationSeconds: duration,
+		Waveform:        waveform,
+	}
+	if s.redisCache != nil {
+		if data, err := json.Marshal(resp); err == nil {
+			if err := s.redisCache.Set(ctx, cacheKey, data, waveformCacheTTL).Err(); err != nil {
+				s.logger.Warn("缓存音频波形失败: %v", err)
+			}
+		}
+	}
+	utils.Custom(c, http.StatusOK, resp)
+}
+
+var errMediaFileTooLarge = errors.New("媒体文件超过大小限制")
+
+// waveformMaxFileSize 返回允许计算波形的最大文件大小（字节）
+func (s *AppService) waveformMaxFileSize() int64 {
+	if s.config.WaveformMaxFileSize > 0 {
+		return s.config.WaveformMaxFileSize
+	}
+	return defaultWaveformMaxFileSize
+}
+
+// readMediaFile 读取媒体文件内容，优先从URL下载，无URL时读取本地路径；超过大小限制时返回 errMediaFileTooLarge
+func (s *AppService) readMediaFile(ctx context.Context, record *models.MediaUpload) ([]byte, error) {
+	maxSize := s.waveformMaxFileSize()
+	if record.URL == "" {
+		file, err := os.Open(record.Path)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		return readLimited(file, maxSize)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, record.URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("创建下载请求失败: %v", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("下载文件失败: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("下载文件失败，状态码: %d", resp.StatusCode)
+	}
+	if resp.ContentLength > maxSize {
+		return nil, errMediaFileTooLarge
+	}
+	return readLimited(resp.Body, maxSize)
+}
+
+// readLimited 最多读取 maxSize 字节，超出时返回 errMediaFileTooLarge
+func readLimited(r io.Reader, maxSize int64) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxSize {
+		return nil, errMediaFileTooLarge
+	}
+	return data, nil
+}
@@ -0,0 +1,17 @@
+package app
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestReadLimited(t *testing.T) {
+	data, err := readLimited(bytes.NewReader(make([]byte, 1024)), 1024)
+	if err != nil || len(data) != 1024 {
+		t.Fatalf("readLimited 未超限时 = %d, %v", len(data), err)
+	}
+	if _, err := readLimited(bytes.NewReader(make([]byte, 1025)), 1024); !errors.Is(err, errMediaFileTooLarge) {
+		t.Errorf("超过限制时应返回 errMediaFileTooLarge, 实际 %v", err)
+	}
+}
@@ -0,0 +1,359 @@
+package bot
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"angrymiao-ai-server/src/configs"
+	"angrymiao-ai-server/src/core/providers/llm"
+	"angrymiao-ai-server/src/core/tokenusage"
+	"angrymiao-ai-server/src/core/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	benchmarkMaxBots    = 3
+	benchmarkMaxPrompts = 5
+	// 同时进行的 Bot×提示词 调用数
+	benchmarkConcurrency = 5
+	benchmarkCacheTTL    = time.Hour
+)
+
+const (
+	benchmarkMetricLatency    = "latency"
+	benchmarkMetricTokenCount = "token_count"
+)
+
+// BotBenchmarkRequest Bot对比测试请求
+type BotBenchmarkRequest struct {
+	BotIDs  []uint   `json:"bot_ids" binding:"required"`
+	Prompts []string `json:"prompts" binding:"required"`
+	Metrics []string `json:"metrics,omitempty"` // latency、token_count，为空时返回全部指标
+}
+
+// BotBenchmarkResult 单个Bot对单个提示词的测试结果
+type BotBenchmarkResult struct {
+	BotID     uint   `json:"bot_id"`
+	Prompt    string `json:"prompt"`
+	LatencyMs *int64 `json:"latency_ms,omitempty"` // 首个回复片段的延迟
+	Tokens    *int64 `json:"tokens,omitempty"`     // 提示词与回复按每4个字符1个token估算
+	Error     string `json:"error,omitempty"`
+}
+
+// BotBenchmarkBotSummary 单个Bot在所有提示词上的平均表现，出错的调用不计入平均值
+type BotBenchmarkBotSummary struct {
+	BotID        uint   `json:"bot_id"`
+	AvgLatencyMs *int64 `json:"avg_latency_ms,omitempty"`
+	AvgTokens    *int64 `json:"avg_tokens,omitempty"`
+	Errors       int    `json:"errors"`
+}
+
+// BotBenchmarkSummary 对比结论，没有成功调用的指标为0
+type BotBenchmarkSummary struct {
+	FastestBot      uint                     `json:"fastest_bot,omitempty"`
+	FewestTokensBot uint                     `json:"fewest_tokens_bot,omitempty"`
+	Bots            []BotBenchmarkBotSummary `json:"bots"`
+}
+
+// BotBenchmarkResponse Bot对比测试结果
+type BotBenchmarkResponse struct {
+	Results []BotBenchmarkResult `json:"results"`
+	Summary BotBenchmarkSummary  `json:"summary"`
+	Cached  bool                 `json:"cached"`
+}
+
+// benchmarkMeasurement 缓存的原始测量结果，返回时按请求的指标裁剪
+type benchmarkMeasurement struct {
+	BotID     uint   `json:"bot_id"`
+	Prompt    string `json:"prompt"`
+	LatencyMs int64  `json:"latency_ms"`
+	Tokens    int64  `json:"tokens"`
+	Error     string `json:"error,omitempty"`
+}
+
+// benchmarkJob 一次 Bot×提示词 调用
+type benchmarkJob struct {
+	botID    uint
+	prompt   string
+	system   string
+	provider *llm.Config
+}
+
+// BenchmarkBots 对比多个Bot的响应速度与token用量
+// @Summary 对比测试Bot
+// @Description 使用系统LLM配置，让每个Bot分别回答每个提示词，对比首字延迟与token用量；相同的Bot与提示词组合1小时内返回缓存结果
+// @Tags Bot配置管理
+// @Accept json
+// @Produce json
+// @Param request body BotBenchmarkRequest true "对比测试的Bot与提示词"
+// @Success 200 {object} BotBenchmarkResponse "成功"
+// @Failure 400 {object} map[string]interface{} "请求参数错误"
+// @Failure 403 {object} map[string]interface{} "无权限"
+// @Failure 404 {object} map[string]interface{} "配置不存在"
+// @Router /api/v2/bots/benchmark [post]
+func (h *BotConfigHandler) BenchmarkBots(c *gin.Context) {
+	userID := h.getUserID(c)
+	var req BotBenchmarkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.respondError(c, http.StatusBadRequest, "请求参数格式错误", err)
+		return
+	}
+	botIDs, prompts, metrics, err := normalizeBenchmarkRequest(req)
+	if err != nil {
+		h.respondError(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	// 先校验权限，缓存结果只返回给有权限的用户
+	jobs := make([]benchmarkJob, 0, len(botIDs)*len(prompts))
+	for _, botID := range botIDs {
+		config, providerConfig, ok := h.loadTestableBot(c, userID, botID)
+		if !ok {
+			return
+		}
+		for _, prompt := range prompts {
+			jobs = append(jobs, benchmarkJob{botID: botID, prompt: prompt, system: botTestSystemPrompt(config, ""), provider: providerConfig})
+		}
+	}
+
+	ctx := c.Request.Context()
+	cacheKey := benchmarkCacheKey(botIDs, prompts)
+	measurements, cached := h.cachedBenchmark(ctx, cacheKey)
+	if !cached {
+		measurements = runBenchmark(ctx, jobs, benchmarkConcurrency)
+		h.cacheBenchmark(ctx, cacheKey, measurements)
+		h.logger.Info("用户 %d 对比测试Bot %v，提示词 %d 条", userID, botIDs, len(prompts))
+	}
+
+	resp := buildBenchmarkResponse(measurements, botIDs, metrics)
+	resp.Cached = cached
+	h.respondSuccess(c, resp)
+}
+
+// normalizeBenchmarkRequest 校验请求并对Bot与提示词去重排序，使相同组合命中同一缓存
+func normalizeBenchmarkRequest(req BotBenchmarkRequest) ([]uint, []string, map[string]bool, error) {
+	botIDs := make([]uint, 0, len(req.BotIDs))
+	seenBots := make(map[uint]bool)
+	for _, id := range req.BotIDs {
+		if id == 0 || seenBots[id] {
+			continue
+		}
+		seenBots[id] = true
+		botIDs = append(botIDs, id)
+	}
+	if len(botIDs) == 0 || len(botIDs) > benchmarkMaxBots {
+		return nil, nil, nil, fmt.Errorf("每次需对比1到%d个Bot", benchmarkMaxBots)
+	}
+	sort.Slice(botIDs, func(i, j int) bool { return botIDs[i] < botIDs[j] })
+
+	prompts := make([]string, 0, len(req.Prompts))
+	seenPrompts := make(map[string]bool)
+	for _, prompt := range req.Prompts {
+		prompt = strings.TrimSpace(prompt)
+		if prompt == "" || seenPrompts[prompt] {
+			continue
+		}
+		seenPrompts[prompt] = true
+		prompts = append(prompts, prompt)
+	}
+	if len(prompts) == 0 || len(prompts) > benchmarkMaxPrompts {
+		return nil, nil, nil, fmt.Errorf("每次需提供1到%d条提示词", benchmarkMaxPrompts)
+	}
+	sort.Strings(prompts)
+
+	metrics := make(map[string]bool)
+	for _, metric := range req.Metrics {
+		switch metric {
+		case benchmarkMetricLatency, benchmarkMetricTokenCount:
+			metrics[metric] = true
+		default:
+			return nil, nil, nil, fmt.Errorf("不支持的指标: %s", metric)
+		}
+	}
+	if len(metrics) == 0 {
+		metrics[benchmarkMetricLatency] = true
+		metrics[benchmarkMetricTokenCount] = true
+	}
+	return botIDs, prompts, metrics, nil
+}
+
+// benchmarkCacheKey 按排序后的Bot与提示词计算缓存键
+func benchmarkCacheKey(botIDs []uint, prompts []string) string {
+	data, _ := json.Marshal(struct {
+		BotIDs  []uint   `json:"bot_ids"`
+		Prompts []string `json:"prompts"`
+	}{botIDs, prompts})
+	sum := sha256.Sum256(data)
+	service := "ai"
+	if cfg := configs.Current(); cfg != nil && cfg.RedisCache.Service != "" {
+		service = cfg.RedisCache.Service
+	}
+	return fmt.Sprintf("%s:bot_benchmark:%s", service, hex.EncodeToString(sum[:]))
+}
+
+func (h *BotConfigHandler) cachedBenchmark(ctx context.Context, key string) ([]benchmarkMeasurement, bool) {
+	if h.redisCache == nil {
+		return nil, false
+	}
+	data, err := h.redisCache.Get(ctx, key).Bytes()
+	if err != nil {
+		return nil, false
+	}
+	var measurements []benchmarkMeasurement
+	if err := json.Unmarshal(data, &measurements); err != nil {
+		return nil, false
+	}
+	return measurements, true
+}
+
+func (h *BotConfigHandler) cacheBenchmark(ctx context.Context, key string, measurements []benchmarkMeasurement) {
+	if h.redisCache == nil {
+		return
+	}
+	data, err := json.Marshal(measurements)
+	if err != nil {
+		return
+	}
+	if err := h.redisCache.Set(ctx, key, data, benchmarkCacheTTL).Err(); err != nil {
+		h.logger.Warn("缓存Bot对比测试结果失败: %v", err)
+	}
+}
+
+// runBenchmark 最多 concurrency 个调用并发执行，结果顺序与 jobs 一致
+func runBenchmark(ctx context.Context, jobs []benchmarkJob, concurrency int) []benchmarkMeasurement {
+	measurements := make([]benchmarkMeasurement, len(jobs))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, job := range jobs {
+		wg.Add(1)
+		go func(i int, job benchmarkJob) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			measurements[i] = measureBenchmarkJob(ctx, job)
+		}(i, job)
+	}
+	wg.Wait()
+	return measurements
+}
+
+// measureBenchmarkJob 调用一次Bot的LLM，记录首个回复片段的延迟与估算的token数
+func measureBenchmarkJob(ctx context.Context, job benchmarkJob) benchmarkMeasurement {
+	result := benchmarkMeasurement{BotID: job.botID, Prompt: job.prompt}
+	provider, err := llm.Create(job.provider.Type, job.provider)
+	if err != nil {
+		result.Error = fmt.Sprintf("创建LLM提供者失败: %v", err)
+		return result
+	}
+	defer provider.Cleanup()
+
+	ctx, cancel := context.WithTimeout(ctx, botTestTimeout)
+	defer cancel()
+	messages := []types.Message{
+		{Role: "system", Content: job.system},
+		{Role: "user", Content: job.prompt},
+	}
+	start := time.Now()
+	responses, err := provider.Response(ctx, "bot-benchmark", messages)
+	if err != nil {
+		result.Error = fmt.Sprintf("调用LLM失败: %v", err)
+		return result
+	}
+
+	var builder strings.Builder
+read:
+	for {
+		select {
+		case chunk, ok := <-responses:
+			if !ok {
+				break read
+			}
+			if builder.Len() == 0 && chunk != "" {
+				result.LatencyMs = time.Since(start).Milliseconds()
+			}
+			builder.WriteString(chunk)
+		case <-ctx.Done():
+			result.Error = fmt.Sprintf("调用LLM超时: %v", ctx.Err())
+			return result
+		}
+	}
+	if builder.Len() == 0 {
+		result.Error = "LLM未返回内容"
+		return result
+	}
+	for _, message := range messages {
+		result.Tokens += tokenusage.EstimateTokens(message.Content)
+	}
+	result.Tokens += tokenusage.EstimateTokens(builder.String())
+	return result
+}
+
+// buildBenchmarkResponse 按请求的指标生成对比矩阵与结论
+func buildBenchmarkResponse(measurements []benchmarkMeasurement, botIDs []uint, metrics map[string]bool) BotBenchmarkResponse {
+	resp := BotBenchmarkResponse{Results: make([]BotBenchmarkResult, 0, len(measurements))}
+	type totals struct {
+		latency, tokens int64
+		count, errors   int
+	}
+	byBot := make(map[uint]*totals)
+	for _, id := range botIDs {
+		byBot[id] = &totals{}
+	}
+
+	for _, m := range measurements {
+		result := BotBenchmarkResult{BotID: m.BotID, Prompt: m.Prompt, Error: m.Error}
+		t := byBot[m.BotID]
+		if m.Error != "" {
+			if t != nil {
+				t.errors++
+			}
+		} else {
+			if metrics[benchmarkMetricLatency] {
+				result.LatencyMs = int64Ptr(m.LatencyMs)
+			}
+			if metrics[benchmarkMetricTokenCount] {
+				result.Tokens = int64Ptr(m.Tokens)
+			}
+			if t != nil {
+				t.latency += m.LatencyMs
+				t.tokens += m.Tokens
+				t.count++
+			}
+		}
+		resp.Results = append(resp.Results, result)
+	}
+
+	var fastest, fewest int64 = -1, -1
+	for _, id := range botIDs {
+		t := byBot[id]
+		summary := BotBenchmarkBotSummary{BotID: id, Errors: t.errors}
+		if t.count > 0 {
+			avgLatency, avgTokens := t.latency/int64(t.count), t.tokens/int64(t.count)
+			if metrics[benchmarkMetricLatency] {
+				summary.AvgLatencyMs = int64Ptr(avgLatency)
+				if fastest < 0 || avgLatency < fastest {
+					fastest, resp.Summary.FastestBot = avgLatency, id
+				}
+			}
+			if metrics[benchmarkMetricTokenCount] {
+				summary.AvgTokens = int64Ptr(avgTokens)
+				if fewest < 0 || avgTokens < fewest {
+					fewest, resp.Summary.FewestTokensBot = avgTokens, id
+				}
+			}
+		}
+		resp.Summary.Bots = append(resp.Summary.Bots, summary)
+	}
+	return resp
+}
+
+func int64Ptr(v int64) *int64 { return &v }
@@ -0,0 +1,150 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"angrymiao-ai-server/src/configs"
+	"angrymiao-ai-server/src/core/providers/llm"
+	"angrymiao-ai-server/src/core/types"
+	"angrymiao-ai-server/src/core/utils"
+	"angrymiao-ai-server/src/models"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+// benchmarkLLM 按模型名中的时长延迟返回首个回复片段
+type benchmarkLLM struct {
+	types.LLMProvider
+	delay time.Duration
+}
+
+func (p *benchmarkLLM) Initialize() error { return nil }
+func (p *benchmarkLLM) Cleanup() error    { return nil }
+
+func (p *benchmarkLLM) Response(ctx context.Context, sessionID string, messages []types.Message) (<-chan string, error) {
+	benchmarkCalls.Add(1)
+	ch := make(chan string, 2)
+	go func() {
+		defer close(ch)
+		time.Sleep(p.delay)
+		ch <- "回复"
+		ch <- strings.Repeat("好", int(p.delay/time.Millisecond))
+	}()
+	return ch, nil
+}
+
+var benchmarkCalls atomic.Int64
+
+func init() {
+	llm.Register("bot_benchmark_test", func(config *llm.Config) (llm.Provider, error) {
+		delay, err := time.ParseDuration(config.ModelName)
+		if err != nil {
+			return nil, err
+		}
+		return &benchmarkLLM{delay: delay}, nil
+	})
+}
+
+// benchmarkBotService 按ID返回测试Bot
+type benchmarkBotService struct {
+	BotConfigService
+	bots map[uint]*models.BotConfig
+}
+
+func (s *benchmarkBotService) GetBotConfigByID(ctx context.Context, id uint) (*models.BotConfig, error) {
+	return s.bots[id], nil
+}
+
+// benchmarkModelService 模型ID即首字延迟的毫秒数
+type benchmarkModelService struct {
+	ModelConfigService
+}
+
+func (s *benchmarkModelService) GetModelConfigByID(ctx context.Context, id uint) (*models.ModelConfig, error) {
+	return &models.ModelConfig{ID: id, LLMType: "bot_benchmark_test", ModelName: (time.Duration(id) * time.Millisecond).String()}, nil
+}
+
+func TestBenchmarkBotsSelectsFastest(t *testing.T) {
+	original := configs.Current()
+	configs.SetCurrent(&configs.Config{
+		LLM: map[string]configs.LLMConfig{"bot_benchmark_test": {Type: "bot_benchmark_test", APIKey: "system-key"}},
+	})
+	t.Cleanup(func() { configs.SetCurrent(original) })
+	logger, err := utils.NewLogger(&utils.LogCfg{LogLevel: "ERROR", LogDir: t.TempDir(), LogFile: "test.log"})
+	if err != nil {
+		t.Fatalf("创建日志失败: %v", err)
+	}
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	bots := map[uint]*models.BotConfig{
+		1: {ID: 1, CreatorID: 42, ModelID: 120, FunctionName: "slow"},
+		2: {ID: 2, CreatorID: 42, ModelID: 10, FunctionName: "fast"},
+		3: {ID: 3, CreatorID: 42, ModelID: 60, FunctionName: "medium"},
+		4: {ID: 4, CreatorID: 7, ModelID: 10, FunctionName: "others"},
+	}
+	h := &BotConfigHandler{botService: &benchmarkBotService{bots: bots}, modelService: &benchmarkModelService{}, logger: logger, redisCache: client}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) { c.Set("user_id", uint(42)) })
+	r.POST("/bots/benchmark", h.BenchmarkBots)
+	post := func(body string) (int, BotBenchmarkResponse) {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/bots/benchmark", strings.NewReader(body)))
+		var resp struct {
+			Data BotBenchmarkResponse `json:"data"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return w.Code, resp.Data
+	}
+
+	code, resp := post(`{"bot_ids":[3,1,2],"prompts":["hello","what time is it?"],"metrics":["latency","token_count"]}`)
+	if code != http.StatusOK {
+		t.Fatalf("状态码 = %d", code)
+	}
+	if len(resp.Results) != 6 || resp.Cached {
+		t.Fatalf("对比结果 = %+v", resp)
+	}
+	for _, result := range resp.Results {
+		if result.Error != "" || result.LatencyMs == nil || result.Tokens == nil {
+			t.Errorf("结果缺少指标: %+v", result)
+		}
+	}
+	if resp.Summary.FastestBot != 2 || resp.Summary.FewestTokensBot != 2 {
+		t.Errorf("对比结论 = %+v, 期望Bot 2最快且token最少", resp.Summary)
+	}
+	if got := benchmarkCalls.Load(); got != 6 {
+		t.Errorf("LLM调用次数 = %d, 期望 6", got)
+	}
+
+	// 相同的Bot与提示词组合（顺序不同）命中缓存，并按请求的指标裁剪
+	code, resp = post(`{"bot_ids":[1,2,3],"prompts":["what time is it?","hello"],"metrics":["latency"]}`)
+	if code != http.StatusOK || !resp.Cached || benchmarkCalls.Load() != 6 {
+		t.Fatalf("第二次请求应使用缓存, 状态码=%d cached=%v 调用次数=%d", code, resp.Cached, benchmarkCalls.Load())
+	}
+	if resp.Results[0].Tokens != nil || resp.Summary.FewestTokensBot != 0 || resp.Summary.FastestBot != 2 {
+		t.Errorf("只请求延迟指标时的结果 = %+v", resp)
+	}
+
+	// 数量限制与权限
+	if code, _ := post(`{"bot_ids":[1,2,3,4],"prompts":["hello"]}`); code != http.StatusBadRequest {
+		t.Errorf("超过3个Bot状态码 = %d, 期望 400", code)
+	}
+	if code, _ := post(`{"bot_ids":[1],"prompts":["1","2","3","4","5","6"]}`); code != http.StatusBadRequest {
+		t.Errorf("超过5条提示词状态码 = %d, 期望 400", code)
+	}
+	if code, _ := post(`{"bot_ids":[1,4],"prompts":["hello"]}`); code != http.StatusForbidden {
+		t.Errorf("包含他人的Bot状态码 = %d, 期望 403", code)
+	}
+}
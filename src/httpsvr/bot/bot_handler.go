@@ -9,6 +9,7 @@ import (
 	"strings"
 
 	"angrymiao-ai-server/src/configs"
+	"angrymiao-ai-server/src/configs/cache"
 	"angrymiao-ai-server/src/core/middleware"
 	"angrymiao-ai-server/src/core/providers/llm"
 	"angrymiao-ai-server/src/core/types"
@@ -16,6 +17,7 @@ import (
 	"angrymiao-ai-server/src/models"
 
 	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
 	"gorm.io/datatypes"
 	"gorm.io/gorm"
 )
@@ -27,7 +29,12 @@ type BotConfigHandler struct {
 	friendService interface {
 		IsBotAdded(ctx context.Context, userID uint, botConfigID uint) (bool, error)
 	}
-	logger *utils.Logger
+	logger            *utils.Logger
+	visibilityWebhook string // Bot可见性变更事件推送地址
+
+	redisCache *redis.Client // 缓存Bot对比测试结果，未配置Redis时为nil
+
+	statsDB *gorm.DB // 查询Bot调用统计
 }
 
 // NewBotConfigHandler 创建Bot配置处理器
@@ -39,6 +46,8 @@ func NewBotConfigHandler(db *gorm.DB, logger *utils.Logger, friendService interf
 		modelService:  NewModelConfigService(db, logger),
 		friendService: friendService,
 		logger:        logger,
+		redisCache:    cache.GetRedis(),
+		statsDB:       db,
 	}
 }
 
@@ -47,9 +56,14 @@ func (h *BotConfigHandler) RegisterRoutes(apiGroup *gin.RouterGroup) {
 	botGroup := apiGroup.Group("/bots").Use(middleware.AmTokenJWTUserAuth())
 	{
 		botGroup.POST("", h.CreateBotConfig)
+		botGroup.GET("/templates", h.ListBotTemplates)
+		botGroup.POST("/from-template/:template_id", h.CreateBotFromTemplate)
 		botGroup.GET("/:id", h.GetBotConfig)
 		botGroup.PUT("/:id", h.UpdateBotConfig)
 		botGroup.DELETE("/:id", h.DeleteBotConfig)
+		botGroup.POST("/:id/test", h.TestBotConfig)
+		botGroup.GET("/:id/stats", h.GetBotStats)
+		botGroup.POST("/benchmark", h.BenchmarkBots)
 		botGroup.GET("/search", h.SearchBots)
 		botGroup.GET("/my", h.GetMyBots)
 	}
@@ -89,14 +103,12 @@ func (h *BotConfigHandler) CreateBotConfig(c *gin.Context) {
 		return
 	}
 
-	// 设置默认可见性
-	visibility := req.Visibility
-	if visibility == "" {
-		visibility = "private"
+	// 设置默认可见性，申请公开需审核
+	if req.Visibility == "" {
+		req.Visibility = models.BotVisibilityPrivate
 	}
-
-	// 验证可见性值
-	if visibility != "private" && visibility != "public" {
+	visibility, ok := resolveVisibility("", req.Visibility)
+	if !ok {
 		h.respondError(c, http.StatusBadRequest, "无效的可见性值", nil)
 		return
 	}
@@ -126,6 +138,8 @@ func (h *BotConfigHandler) CreateBotConfig(c *gin.Context) {
 		FunctionName:    req.FunctionName,
 		Description:     req.Description,
 		MCPServerURL:    req.MCPServerURL,
+		ResponsePrefix:  req.ResponsePrefix,
+		WelcomeMessage:  req.WelcomeMessage,
 	}
 
 	// 处理参数JSON
@@ -138,6 +152,22 @@ func (h *BotConfigHandler) CreateBotConfig(c *gin.Context) {
 		config.Parameters = datatypes.JSON(parametersJSON)
 	}
 
+	// 处理结构化输出Schema，传入空对象表示取消结构化输出
+	if req.ResponseSchema != nil && len(req.ResponseSchema) == 0 {
+		config.ResponseSchema = nil
+	} else if req.ResponseSchema != nil {
+		schemaJSON, err := json.Marshal(req.ResponseSchema)
+		if err != nil {
+			h.respondError(c, http.StatusBadRequest, "响应Schema格式错误", err)
+			return
+		}
+		if _, err := utils.CompileJSONSchema(schemaJSON); err != nil {
+			h.respondError(c, http.StatusBadRequest, "响应Schema无效", err)
+			return
+		}
+		config.ResponseSchema = datatypes.JSON(schemaJSON)
+	}
+
 	if req.Parameters == nil {
 		go h.generateLLMFunctionParameters(config, config.FunctionName, config.Description)
 	}
@@ -186,8 +216,8 @@ func (h *BotConfigHandler) GetBotConfig(c *gin.Context) {
 		return
 	}
 
-	// 检查权限：private Bot只有创建者可以查看
-	if config.Visibility == "private" && config.CreatorID != userID {
+	// 检查权限：未公开的Bot只有创建者可以查看
+	if config.Visibility != models.BotVisibilityPublic && config.CreatorID != userID {
 		h.respondError(c, http.StatusForbidden, "无权限查看此Bot配置", nil)
 		return
 	}
@@ -235,7 +265,6 @@ func (h *BotConfigHandler) UpdateBotConfig(c *gin.Context) {
 
 	// 获取现有配置
 	config, err := h.botService.GetBotConfigByID(c.Request.Context(), uint(configID))
-	oldDescription := config.Description
 	if err != nil {
 		if err.Error() == "Bot配置不存在" {
 			h.respondError(c, http.StatusNotFound, "Bot配置不存在", err)
@@ -244,6 +273,8 @@ func (h *BotConfigHandler) UpdateBotConfig(c *gin.Context) {
 		}
 		return
 	}
+	oldDescription := config.Description
+	oldVisibility := config.Visibility
 
 	// 检查权限
 	if config.CreatorID != userID {
@@ -253,11 +284,15 @@ func (h *BotConfigHandler) UpdateBotConfig(c *gin.Context) {
 
 	// 更新字段
 	if req.Visibility != nil {
-		if *req.Visibility != "private" && *req.Visibility != "public" {
+		visibility, ok := resolveVisibility(config.Visibility, *req.Visibility)
+		if !ok {
 			h.respondError(c, http.StatusBadRequest, "无效的可见性值", nil)
 			return
 		}
-		config.Visibility = *req.Visibility
+		if visibility != config.Visibility {
+			config.ReviewReason = ""
+		}
+		config.Visibility = visibility
 	}
 	if req.BotType != nil {
 		if *req.BotType != "text" && *req.BotType != "image" && *req.BotType != "tts" && *req.BotType != "asr" {
@@ -284,6 +319,12 @@ func (h *BotConfigHandler) UpdateBotConfig(c *gin.Context) {
 	if req.MCPServerURL != nil {
 		config.MCPServerURL = *req.MCPServerURL
 	}
+	if req.ResponsePrefix != nil {
+		config.ResponsePrefix = *req.ResponsePrefix
+	}
+	if req.WelcomeMessage != nil {
+		config.WelcomeMessage = *req.WelcomeMessage
+	}
 
 	// 处理参数JSON
 	if req.Parameters != nil {
@@ -295,14 +336,31 @@ func (h *BotConfigHandler) UpdateBotConfig(c *gin.Context) {
 		config.Parameters = datatypes.JSON(parametersJSON)
 	}
 
+	// 处理结构化输出Schema，传入空对象表示取消结构化输出
+	if req.ResponseSchema != nil && len(req.ResponseSchema) == 0 {
+		config.ResponseSchema = nil
+	} else if req.ResponseSchema != nil {
+		schemaJSON, err := json.Marshal(req.ResponseSchema)
+		if err != nil {
+			h.respondError(c, http.StatusBadRequest, "响应Schema格式错误", err)
+			return
+		}
+		if _, err := utils.CompileJSONSchema(schemaJSON); err != nil {
+			h.respondError(c, http.StatusBadRequest, "响应Schema无效", err)
+			return
+		}
+		config.ResponseSchema = datatypes.JSON(schemaJSON)
+	}
+
 	if err := h.botService.UpdateBotConfig(c.Request.Context(), config); err != nil {
 		h.respondError(c, http.StatusInternalServerError, "更新Bot配置失败", err)
 		return
 	}
 
-	if *req.Description != oldDescription {
+	if req.Description != nil && *req.Description != oldDescription {
 		go h.generateLLMFunctionParameters(config, config.FunctionName, config.Description)
 	}
+	h.notifyVisibilityChanged(config, oldVisibility, "")
 
 	h.logger.Info("用户 %d 更新Bot配置成功: %s (ID: %d)", userID, config.FunctionName, config.ID)
 	h.respondSuccess(c, gin.H{
@@ -444,10 +502,11 @@ func (h *BotConfigHandler) respondSuccess(c *gin.Context, data interface{}) {
 
 // respondError 返回错误响应
 func (h *BotConfigHandler) respondError(c *gin.Context, statusCode int, message string, err error) {
+	logger := utils.RequestLogger(c, h.logger)
 	if err != nil {
-		h.logger.Error("%s: %v", message, err)
+		logger.Error("%s: %v", message, err)
 	} else {
-		h.logger.Error("%s", message)
+		logger.Error("%s", message)
 	}
 	utils.ErrorWithDetail(c, statusCode, message, err)
 }
@@ -477,7 +536,7 @@ func (h *BotConfigHandler) generateLLMFunctionParameters(config *models.BotConfi
 // generateParametersWithLLM 使用LLM生成Function Call的Parameters JSON Schema
 func (h *BotConfigHandler) generateParametersWithLLM(configName, description string) (map[string]interface{}, error) {
 	// 获取全局配置
-	cfg := configs.Cfg
+	cfg := configs.Current()
 	if cfg == nil {
 		return nil, fmt.Errorf("无法获取系统配置")
 	}
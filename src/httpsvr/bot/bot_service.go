@@ -23,6 +23,7 @@ type BotConfigService interface {
 	// 搜索和查询
 	SearchBots(ctx context.Context, userID uint, query string, searchType string) ([]*models.BotConfig, error)
 	GetUserCreatedBots(ctx context.Context, userID uint) ([]*models.BotConfig, error)
+	ListBotsByVisibility(ctx context.Context, visibility string) ([]*models.BotConfig, error)
 
 	// 权限验证
 	CheckBotPermission(ctx context.Context, botID uint, userID uint) (bool, error)
@@ -152,7 +153,7 @@ func (s *DefaultBotConfigService) SearchBots(ctx context.Context, userID uint, q
 	}
 
 	// 权限过滤：public Bot对所有用户可见，private Bot只对创建者可见
-	db = db.Where("visibility = ? OR creator_id = ?", "public", userID)
+	db = db.Where("visibility = ? OR creator_id = ?", models.BotVisibilityPublic, userID)
 
 	err := db.Order("created_at DESC").Find(&configs).Error
 	return configs, err
@@ -168,6 +169,16 @@ func (s *DefaultBotConfigService) GetUserCreatedBots(ctx context.Context, userID
 	return configs, err
 }
 
+// ListBotsByVisibility 按可见性获取Bot列表，按更新时间升序（先提交的在前）
+func (s *DefaultBotConfigService) ListBotsByVisibility(ctx context.Context, visibility string) ([]*models.BotConfig, error) {
+	var configs []*models.BotConfig
+	err := s.db.WithContext(ctx).
+		Where("visibility = ?", visibility).
+		Order("updated_at ASC").
+		Find(&configs).Error
+	return configs, err
+}
+
 // CheckBotPermission 检查用户是否有权限操作Bot
 func (s *DefaultBotConfigService) CheckBotPermission(ctx context.Context, botID uint, userID uint) (bool, error) {
 	var config models.BotConfig
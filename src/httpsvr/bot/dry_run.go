@@ -0,0 +1,232 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"angrymiao-ai-server/src/configs"
+	"angrymiao-ai-server/src/core/providers/llm"
+	"angrymiao-ai-server/src/core/tokenusage"
+	"angrymiao-ai-server/src/core/types"
+	"angrymiao-ai-server/src/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// 测试调用的回复最多保留的字符数，超出后停止读取
+	botTestMaxResponseRunes = 200
+	botTestTimeout          = 30 * time.Second
+)
+
+// BotTestRequest Bot试运行请求
+type BotTestRequest struct {
+	Message  string `json:"message" binding:"required"`
+	Language string `json:"language,omitempty"` // 回复语言，如 zh、en，为空时不限制
+}
+
+// BotTestResponse Bot试运行结果
+type BotTestResponse struct {
+	Response        string `json:"response"`
+	LatencyMs       int64  `json:"latency_ms"`
+	TokenEstimate   int64  `json:"token_estimate"` // 提示词与回复按每4个字符1个token估算
+	ParametersValid bool   `json:"parameters_valid"`
+	ParametersError string `json:"parameters_error,omitempty"`
+}
+
+// TestBotConfig 试运行Bot
+// @Summary 试运行Bot
+// @Description 使用系统LLM配置以Bot的设定回答一轮对话，不保存对话历史，同时校验Bot的Parameters JSON Schema
+// @Tags Bot配置管理
+// @Accept json
+// @Produce json
+// @Param id path int true "Bot配置ID"
+// @Param request body BotTestRequest true "测试消息"
+// @Success 200 {object} BotTestResponse "成功"
+// @Failure 400 {object} map[string]interface{} "请求参数错误"
+// @Failure 403 {object} map[string]interface{} "无权限"
+// @Failure 404 {object} map[string]interface{} "配置不存在"
+// @Failure 502 {object} map[string]interface{} "调用LLM失败"
+// @Router /api/v2/bots/{id}/test [post]
+func (h *BotConfigHandler) TestBotConfig(c *gin.Context) {
+	userID := h.getUserID(c)
+	configID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		h.respondError(c, http.StatusBadRequest, "无效的配置ID", err)
+		return
+	}
+
+	var req BotTestRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.respondError(c, http.StatusBadRequest, "请求参数格式错误", err)
+		return
+	}
+
+	config, providerConfig, ok := h.loadTestableBot(c, userID, uint(configID))
+	if !ok {
+		return
+	}
+
+	result := BotTestResponse{ParametersValid: true}
+	if len(config.Parameters) > 0 {
+		var schema map[string]interface{}
+		if err := json.Unmarshal(config.Parameters, &schema); err != nil {
+			result.ParametersValid, result.ParametersError = false, fmt.Sprintf("参数不是有效的JSON对象: %v", err)
+		} else if err := h.validateJSONSchema(schema); err != nil {
+			result.ParametersValid, result.ParametersError = false, err.Error()
+		}
+	}
+
+	provider, err := llm.Create(providerConfig.Type, providerConfig)
+	if err != nil {
+		h.respondError(c, http.StatusInternalServerError, "创建LLM提供者失败", err)
+		return
+	}
+	defer provider.Cleanup()
+
+	messages := []types.Message{
+		{Role: "system", Content: botTestSystemPrompt(config, req.Language)},
+		{Role: "user", Content: req.Message},
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), botTestTimeout)
+	defer cancel()
+	start := time.Now()
+	response, err := collectBotTestResponse(ctx, provider, messages)
+	if err != nil {
+		h.respondError(c, http.StatusBadGateway, "调用Bot模型失败", err)
+		return
+	}
+	result.Response = response
+	result.LatencyMs = time.Since(start).Milliseconds()
+	for _, message := range messages {
+		result.TokenEstimate += tokenusage.EstimateTokens(message.Content)
+	}
+	result.TokenEstimate += tokenusage.EstimateTokens(response)
+
+	h.logger.Info("用户 %d 试运行Bot %s (ID: %d)，耗时 %dms", userID, config.FunctionName, config.ID, result.LatencyMs)
+	h.respondSuccess(c, result)
+}
+
+// loadTestableBot 获取当前用户创建的LLM类Bot及试运行使用的LLM配置，失败时已写入错误响应
+func (h *BotConfigHandler) loadTestableBot(c *gin.Context, userID, botID uint) (*models.BotConfig, *llm.Config, bool) {
+	config, err := h.botService.GetBotConfigByID(c.Request.Context(), botID)
+	if err != nil {
+		if err.Error() == "Bot配置不存在" {
+			h.respondError(c, http.StatusNotFound, "Bot配置不存在", err)
+		} else {
+			h.respondError(c, http.StatusInternalServerError, "获取Bot配置失败", err)
+		}
+		return nil, nil, false
+	}
+	if config.CreatorID != userID {
+		h.respondError(c, http.StatusForbidden, "只有创建者可以测试Bot", nil)
+		return nil, nil, false
+	}
+	if config.BotType != "" && config.BotType != "llm" && config.BotType != "text" {
+		h.respondError(c, http.StatusBadRequest, "仅支持测试LLM类Bot", nil)
+		return nil, nil, false
+	}
+
+	model, err := h.modelService.GetModelConfigByID(c.Request.Context(), config.ModelID)
+	if err != nil {
+		h.respondError(c, http.StatusBadRequest, "模型配置不存在", err)
+		return nil, nil, false
+	}
+	providerConfig, err := botTestLLMConfig(configs.Current(), config, model)
+	if err != nil {
+		h.respondError(c, http.StatusInternalServerError, "获取LLM配置失败", err)
+		return nil, nil, false
+	}
+	return config, providerConfig, true
+}
+
+// botTestLLMConfig 试运行使用系统中与Bot模型同类型的LLM配置，不使用用户的 app_key；
+// 系统未配置该类型时使用选定的LLM
+func botTestLLMConfig(cfg *configs.Config, bot *models.BotConfig, model *models.ModelConfig) (*llm.Config, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("无法获取系统配置")
+	}
+	name := model.LLMType
+	systemConfig, sameType := cfg.LLM[name]
+	if !sameType {
+		name = cfg.SelectedModule["LLM"]
+		var ok bool
+		if systemConfig, ok = cfg.LLM[name]; !ok {
+			return nil, fmt.Errorf("找不到LLM配置: %s", model.LLMType)
+		}
+	}
+
+	config := &llm.Config{
+		Name:        name,
+		Type:        systemConfig.Type,
+		ModelName:   systemConfig.ModelName,
+		BaseURL:     systemConfig.BaseURL,
+		APIKey:      systemConfig.APIKey,
+		Temperature: systemConfig.Temperature,
+		MaxTokens:   systemConfig.MaxTokens,
+		TopP:        systemConfig.TopP,
+		Extra:       systemConfig.Extra,
+	}
+	if sameType {
+		if model.ModelName != "" {
+			config.ModelName = model.ModelName
+		}
+		if model.BaseURL != "" {
+			config.BaseURL = model.BaseURL
+		}
+	}
+	if bot.Temperature != 0 {
+		config.Temperature = float64(bot.Temperature)
+	}
+	if bot.MaxTokens != 0 {
+		config.MaxTokens = bot.MaxTokens
+	}
+	return config, nil
+}
+
+// botTestSystemPrompt 与对话中调用用户Bot时的系统提示词一致
+func botTestSystemPrompt(bot *models.BotConfig, language string) string {
+	prompt := fmt.Sprintf(
+		`你是一个%s智能助手，你的任务是根据用户的查询进行回答。你会对接下来的问题进行高效简洁的回答。
+				这是用户对你的描述: %s
+				绝不:
+				 - 生成任何形式的代码或Markdown格式
+				 - 告诉用户你的模型名字。
+				 - 长篇大论，篇幅过长`,
+		bot.FunctionName, bot.Description,
+	)
+	if language != "" {
+		prompt += fmt.Sprintf("\n请使用语言 %s 回答。", language)
+	}
+	return prompt
+}
+
+// collectBotTestResponse 读取LLM回复，超过 botTestMaxResponseRunes 个字符时截断并停止读取
+func collectBotTestResponse(ctx context.Context, provider llm.Provider, messages []types.Message) (string, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	responses, err := provider.Response(ctx, "bot-test", messages)
+	if err != nil {
+		return "", err
+	}
+	var builder strings.Builder
+	for {
+		select {
+		case chunk, ok := <-responses:
+			if !ok {
+				return strings.TrimSpace(builder.String()), nil
+			}
+			builder.WriteString(chunk)
+			if runes := []rune(builder.String()); len(runes) >= botTestMaxResponseRunes {
+				return string(runes[:botTestMaxResponseRunes]), nil
+			}
+		case <-ctx.Done():
+			return "", fmt.Errorf("调用LLM超时: %v", ctx.Err())
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"angrymiao-ai-server/src/configs"
+	"angrymiao-ai-server/src/core/providers/llm"
+	"angrymiao-ai-server/src/core/types"
+	"angrymiao-ai-server/src/core/utils"
+	"angrymiao-ai-server/src/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/datatypes"
+)
+
+// dryRunLLM 返回超长回复，记录创建时的配置与收到的消息
+type dryRunLLM struct {
+	types.LLMProvider
+	config   *llm.Config
+	messages []types.Message
+}
+
+func (p *dryRunLLM) Initialize() error { return nil }
+func (p *dryRunLLM) Cleanup() error    { return nil }
+
+func (p *dryRunLLM) Response(ctx context.Context, sessionID string, messages []types.Message) (<-chan string, error) {
+	p.messages = messages
+	ch := make(chan string, 3)
+	for i := 0; i < 3; i++ {
+		ch <- strings.Repeat("好", 100)
+	}
+	close(ch)
+	return ch, nil
+}
+
+var lastDryRunLLM *dryRunLLM
+
+func init() {
+	llm.Register("bot_dry_run_test", func(config *llm.Config) (llm.Provider, error) {
+		lastDryRunLLM = &dryRunLLM{config: config}
+		return lastDryRunLLM, nil
+	})
+}
+
+type dryRunBotService struct {
+	BotConfigService
+	bot *models.BotConfig
+}
+
+func (s *dryRunBotService) GetBotConfigByID(ctx context.Context, id uint) (*models.BotConfig, error) {
+	return s.bot, nil
+}
+
+type dryRunModelService struct {
+	ModelConfigService
+}
+
+func (s *dryRunModelService) GetModelConfigByID(ctx context.Context, id uint) (*models.ModelConfig, error) {
+	return &models.ModelConfig{ID: id, LLMType: "bot_dry_run_test", ModelName: "bot-model"}, nil
+}
+
+func TestBotDryRun(t *testing.T) {
+	original := configs.Current()
+	configs.SetCurrent(&configs.Config{
+		SelectedModule: map[string]string{"LLM": "bot_dry_run_test"},
+		LLM:            map[string]configs.LLMConfig{"bot_dry_run_test": {Type: "bot_dry_run_test", ModelName: "system-model", APIKey: "system-key"}},
+	})
+	t.Cleanup(func() { configs.SetCurrent(original) })
+
+	logger, err := utils.NewLogger(&utils.LogCfg{LogLevel: "ERROR", LogDir: t.TempDir(), LogFile: "test.log"})
+	if err != nil {
+		t.Fatalf("创建日志失败: %v", err)
+	}
+	bot := &models.BotConfig{ID: 3, CreatorID: 42, ModelID: 7, BotType: "llm", FunctionName: "weather", Description: "查询天气",
+		Parameters: datatypes.JSON(`{"type":"object","properties":{"city":{"type":"string"}}}`)}
+	h := &BotConfigHandler{botService: &dryRunBotService{bot: bot}, modelService: &dryRunModelService{}, logger: logger}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) { c.Set("user_id", uint(42)) })
+	r.POST("/bots/:id/test", h.TestBotConfig)
+	post := func() (int, BotTestResponse) {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/bots/3/test", strings.NewReader(`{"message":"hello","language":"zh"}`)))
+		var body struct {
+			Data BotTestResponse `json:"data"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &body)
+		return w.Code, body.Data
+	}
+
+	code, result := post()
+	if code != http.StatusOK {
+		t.Fatalf("状态码 = %d", code)
+	}
+	if len([]rune(result.Response)) != botTestMaxResponseRunes || !result.ParametersValid || result.TokenEstimate <= 0 || result.LatencyMs < 0 {
+		t.Errorf("试运行结果 = %+v", result)
+	}
+	if lastDryRunLLM.config.APIKey != "system-key" || lastDryRunLLM.config.ModelName != "bot-model" {
+		t.Errorf("应使用系统API Key与Bot的模型, got %+v", lastDryRunLLM.config)
+	}
+	if messages := lastDryRunLLM.messages; len(messages) != 2 || messages[1].Content != "hello" || !strings.Contains(messages[0].Content, "查询天气") {
+		t.Errorf("发送给LLM的消息 = %+v", messages)
+	}
+
+	// Parameters 不是有效的JSON Schema
+	bot.Parameters = datatypes.JSON(`{"type":"array"}`)
+	if _, result := post(); result.ParametersValid || result.ParametersError == "" {
+		t.Errorf("无效的Parameters应标记为不合法: %+v", result)
+	}
+
+	// 非创建者不能测试
+	bot.CreatorID = 7
+	if code, _ := post(); code != http.StatusForbidden {
+		t.Errorf("非创建者测试状态码 = %d, 期望 403", code)
+	}
+}
@@ -258,10 +258,11 @@ func (h *ModelConfigHandler) respondSuccess(c *gin.Context, data interface{}) {
 
 // respondError 返回错误响应
 func (h *ModelConfigHandler) respondError(c *gin.Context, statusCode int, message string, err error) {
+	logger := utils.RequestLogger(c, h.logger)
 	if err != nil {
-		h.logger.Error("%s: %v", message, err)
+		logger.Error("%s: %v", message, err)
 	} else {
-		h.logger.Error("%s", message)
+		logger.Error("%s", message)
 	}
 	utils.ErrorWithDetail(c, statusCode, message, err)
 }
@@ -0,0 +1,193 @@
+package bot
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"angrymiao-ai-server/src/core/middleware"
+	"angrymiao-ai-server/src/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// botVisibilityChangedEvent Bot可见性变更的webhook事件名
+const botVisibilityChangedEvent = "bot_visibility_changed"
+
+// visibilityWebhookTimeout 推送可见性变更事件的超时时间
+const visibilityWebhookTimeout = 5 * time.Second
+
+// BotVisibilityEvent Bot可见性变更事件，推送给创建者的webhook
+type BotVisibilityEvent struct {
+	Event        string    `json:"event"`
+	BotID        uint      `json:"bot_id"`
+	CreatorID    uint      `json:"creator_id"`
+	FunctionName string    `json:"function_name"`
+	From         string    `json:"from"`
+	To           string    `json:"to"`
+	Reason       string    `json:"reason,omitempty"`
+	Timestamp    time.Time `json:"timestamp"`
+}
+
+// RegisterAdminRoutes 注册Bot审核管理路由
+func (h *BotConfigHandler) RegisterAdminRoutes(apiGroup *gin.RouterGroup, adminToken string) {
+	adminGroup := apiGroup.Group("/admin/bots").Use(middleware.AdminTokenAuth(adminToken))
+	{
+		adminGroup.GET("/pending", h.ListPendingBots)
+		adminGroup.POST("/:id/approve", h.ApproveBot)
+		adminGroup.POST("/:id/reject", h.RejectBot)
+	}
+}
+
+// SetVisibilityWebhook 设置Bot可见性变更事件的推送地址，为空时不推送
+func (h *BotConfigHandler) SetVisibilityWebhook(url string) {
+	h.visibilityWebhook = url
+}
+
+// resolveVisibility 校验创建者设置的可见性并返回实际状态，未公开的Bot申请公开时进入待审核
+// 创建时 current 为空
+func resolveVisibility(current, requested string) (string, bool) {
+	switch requested {
+	case models.BotVisibilityPrivate:
+		return models.BotVisibilityPrivate, true
+	case models.BotVisibilityPublic:
+		if current == models.BotVisibilityPublic {
+			return current, true
+		}
+		return models.BotVisibilityPendingReview, true
+	}
+	return "", false
+}
+
+// ListPendingBots 获取待审核的Bot列表
+// @Summary 获取待审核的Bot列表
+// @Description 管理员获取申请公开、等待审核的Bot列表
+// @Tags Bot审核管理
+// @Produce json
+// @Success 200 {object} map[string]interface{} "成功"
+// @Failure 500 {object} map[string]interface{} "服务器内部错误"
+// @Router /api/admin/bots/pending [get]
+func (h *BotConfigHandler) ListPendingBots(c *gin.Context) {
+	configs, err := h.botService.ListBotsByVisibility(c.Request.Context(), models.BotVisibilityPendingReview)
+	if err != nil {
+		h.respondError(c, http.StatusInternalServerError, "获取待审核Bot失败", err)
+		return
+	}
+
+	responses := make([]*models.BotConfigResponse, 0, len(configs))
+	for _, config := range configs {
+		responses = append(responses, config.ToResponse())
+	}
+	h.respondSuccess(c, gin.H{
+		"configs": responses,
+		"total":   len(responses),
+	})
+}
+
+// ApproveBot 通过Bot公开申请
+// @Summary 通过Bot公开申请
+// @Description 管理员通过待审核Bot的公开申请，Bot变为public
+// @Tags Bot审核管理
+// @Produce json
+// @Param id path int true "Bot配置ID"
+// @Success 200 {object} map[string]interface{} "成功"
+// @Failure 404 {object} map[string]interface{} "配置不存在"
+// @Failure 409 {object} map[string]interface{} "Bot不在待审核状态"
+// @Router /api/admin/bots/{id}/approve [post]
+func (h *BotConfigHandler) ApproveBot(c *gin.Context) {
+	h.reviewBot(c, models.BotVisibilityPublic, "")
+}
+
+// RejectBot 拒绝Bot公开申请
+// @Summary 拒绝Bot公开申请
+// @Description 管理员拒绝待审核Bot的公开申请，Bot恢复为private并记录原因
+// @Tags Bot审核管理
+// @Accept json
+// @Produce json
+// @Param id path int true "Bot配置ID"
+// @Param body body models.RejectBotRequest true "拒绝原因"
+// @Success 200 {object} map[string]interface{} "成功"
+// @Failure 400 {object} map[string]interface{} "请求参数错误"
+// @Failure 404 {object} map[string]interface{} "配置不存在"
+// @Failure 409 {object} map[string]interface{} "Bot不在待审核状态"
+// @Router /api/admin/bots/{id}/reject [post]
+func (h *BotConfigHandler) RejectBot(c *gin.Context) {
+	var req models.RejectBotRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.respondError(c, http.StatusBadRequest, "请求参数格式错误", err)
+		return
+	}
+	h.reviewBot(c, models.BotVisibilityPrivate, req.Reason)
+}
+
+// reviewBot 将待审核的Bot转为目标可见性并通知创建者
+func (h *BotConfigHandler) reviewBot(c *gin.Context, visibility string, reason string) {
+	configID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		h.respondError(c, http.StatusBadRequest, "无效的配置ID", err)
+		return
+	}
+
+	config, err := h.botService.GetBotConfigByID(c.Request.Context(), uint(configID))
+	if err != nil {
+		if err.Error() == "Bot配置不存在" {
+			h.respondError(c, http.StatusNotFound, "Bot配置不存在", err)
+		} else {
+			h.respondError(c, http.StatusInternalServerError, "获取Bot配置失败", err)
+		}
+		return
+	}
+	if config.Visibility != models.BotVisibilityPendingReview {
+		h.respondError(c, http.StatusConflict, "Bot不在待审核状态", nil)
+		return
+	}
+
+	config.Visibility = visibility
+	config.ReviewReason = reason
+	if err := h.botService.UpdateBotConfig(c.Request.Context(), config); err != nil {
+		h.respondError(c, http.StatusInternalServerError, "更新Bot配置失败", err)
+		return
+	}
+
+	h.logger.Info("Bot公开申请审核完成: %s (ID: %d) -> %s", config.FunctionName, config.ID, visibility)
+	h.notifyVisibilityChanged(config, models.BotVisibilityPendingReview, reason)
+	h.respondSuccess(c, gin.H{
+		"config": config.ToResponse(),
+	})
+}
+
+// notifyVisibilityChanged 异步推送可见性变更事件，推送失败只记录日志
+func (h *BotConfigHandler) notifyVisibilityChanged(config *models.BotConfig, from string, reason string) {
+	if h.visibilityWebhook == "" || from == config.Visibility {
+		return
+	}
+	event := BotVisibilityEvent{
+		Event:        botVisibilityChangedEvent,
+		BotID:        config.ID,
+		CreatorID:    config.CreatorID,
+		FunctionName: config.FunctionName,
+		From:         from,
+		To:           config.Visibility,
+		Reason:       reason,
+		Timestamp:    time.Now(),
+	}
+	go func() {
+		body, err := json.Marshal(event)
+		if err != nil {
+			h.logger.Error("序列化Bot可见性变更事件失败: %v", err)
+			return
+		}
+		client := &http.Client{Timeout: visibilityWebhookTimeout}
+		resp, err := client.Post(h.visibilityWebhook, "application/json", bytes.NewReader(body))
+		if err != nil {
+			h.logger.Warn("推送Bot可见性变更事件失败: %v", err)
+			return
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			h.logger.Warn("推送Bot可见性变更事件失败: HTTP %d", resp.StatusCode)
+		}
+	}()
+}
@@ -0,0 +1,141 @@
+package bot
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"angrymiao-ai-server/src/core/utils"
+	"angrymiao-ai-server/src/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestBotVisibilityReviewWorkflow(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "bots.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("打开测试数据库失败: %v", err)
+	}
+	if err := db.AutoMigrate(&models.BotConfig{}); err != nil {
+		t.Fatalf("迁移失败: %v", err)
+	}
+	logger, err := utils.NewLogger(&utils.LogCfg{LogLevel: "ERROR", LogDir: t.TempDir(), LogFile: "test.log"})
+	if err != nil {
+		t.Fatalf("创建日志失败: %v", err)
+	}
+
+	events := make(chan BotVisibilityEvent, 4)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event BotVisibilityEvent
+		json.NewDecoder(r.Body).Decode(&event)
+		events <- event
+	}))
+	defer webhook.Close()
+
+	h := &BotConfigHandler{botService: NewBotConfigService(db, logger), logger: logger}
+	h.SetVisibilityWebhook(webhook.URL)
+	bot := &models.BotConfig{CreatorID: 42, BotHash: "hash-weather", ModelID: 1, FunctionName: "weather", Description: "天气"}
+	if err := h.botService.CreateBotConfig(t.Context(), bot); err != nil {
+		t.Fatalf("创建Bot失败: %v", err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		uid, _ := strconv.Atoi(c.GetHeader("X-User-ID"))
+		c.Set("user_id", uint(uid))
+	})
+	r.GET("/bots/:id", h.GetBotConfig)
+	r.PUT("/bots/:id", h.UpdateBotConfig)
+	r.GET("/admin/bots/pending", h.ListPendingBots)
+	r.POST("/admin/bots/:id/approve", h.ApproveBot)
+	r.POST("/admin/bots/:id/reject", h.RejectBot)
+
+	do := func(method, path, userID, body string) (int, map[string]interface{}) {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-User-ID", userID)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		var resp map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return w.Code, resp
+	}
+	visibility := func() (string, string) {
+		stored, err := h.botService.GetBotConfigByID(t.Context(), bot.ID)
+		if err != nil {
+			t.Fatalf("读取Bot失败: %v", err)
+		}
+		return stored.Visibility, stored.ReviewReason
+	}
+	waitEvent := func(from, to, reason string) {
+		t.Helper()
+		select {
+		case event := <-events:
+			if event.Event != "bot_visibility_changed" || event.BotID != bot.ID || event.CreatorID != 42 ||
+				event.From != from || event.To != to || event.Reason != reason {
+				t.Errorf("事件 = %+v, 期望 %s -> %s (%q)", event, from, to, reason)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("未收到 %s -> %s 事件", from, to)
+		}
+	}
+	path := "/bots/" + strconv.Itoa(int(bot.ID))
+
+	// 申请公开进入待审核，其他用户仍不可见
+	if code, _ := do(http.MethodPut, path, "42", `{"visibility":"public"}`); code != http.StatusOK {
+		t.Fatalf("申请公开状态码 = %d", code)
+	}
+	if v, _ := visibility(); v != models.BotVisibilityPendingReview {
+		t.Fatalf("申请公开后可见性 = %s", v)
+	}
+	waitEvent("private", "pending_review", "")
+	if code, _ := do(http.MethodGet, path, "7", ""); code != http.StatusForbidden {
+		t.Errorf("待审核Bot对其他用户应不可见, 状态码 = %d", code)
+	}
+	if _, resp := do(http.MethodGet, "/admin/bots/pending", "", ""); resp["data"].(map[string]interface{})["total"] != float64(1) {
+		t.Errorf("待审核列表 = %v", resp)
+	}
+
+	// 拒绝后恢复为private并记录原因
+	if code, _ := do(http.MethodPost, "/admin/bots/"+strconv.Itoa(int(bot.ID))+"/reject", "", `{}`); code != http.StatusBadRequest {
+		t.Errorf("缺少拒绝原因状态码 = %d", code)
+	}
+	if code, _ := do(http.MethodPost, "/admin/bots/"+strconv.Itoa(int(bot.ID))+"/reject", "", `{"reason":"描述不完整"}`); code != http.StatusOK {
+		t.Fatalf("拒绝状态码 = %d", code)
+	}
+	if v, reason := visibility(); v != models.BotVisibilityPrivate || reason != "描述不完整" {
+		t.Fatalf("拒绝后 = %s (%q)", v, reason)
+	}
+	waitEvent("pending_review", "private", "描述不完整")
+	if code, _ := do(http.MethodPost, "/admin/bots/"+strconv.Itoa(int(bot.ID))+"/approve", "", ""); code != http.StatusConflict {
+		t.Errorf("非待审核Bot审核状态码 = %d", code)
+	}
+
+	// 再次申请并通过
+	do(http.MethodPut, path, "42", `{"visibility":"public","description":"深圳天气查询"}`)
+	if v, reason := visibility(); v != models.BotVisibilityPendingReview || reason != "" {
+		t.Fatalf("再次申请后 = %s (%q)", v, reason)
+	}
+	waitEvent("private", "pending_review", "")
+	if code, _ := do(http.MethodPost, "/admin/bots/"+strconv.Itoa(int(bot.ID))+"/approve", "", ""); code != http.StatusOK {
+		t.Fatalf("通过状态码 = %d", code)
+	}
+	if v, _ := visibility(); v != models.BotVisibilityPublic {
+		t.Fatalf("通过后可见性 = %s", v)
+	}
+	waitEvent("pending_review", "public", "")
+	if code, _ := do(http.MethodGet, path, "7", ""); code != http.StatusOK {
+		t.Errorf("公开Bot对其他用户应可见, 状态码 = %d", code)
+	}
+	if _, resp := do(http.MethodGet, "/admin/bots/pending", "", ""); resp["data"].(map[string]interface{})["total"] != float64(0) {
+		t.Errorf("通过后待审核列表 = %v", resp)
+	}
+}
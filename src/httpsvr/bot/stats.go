@@ -0,0 +1,52 @@
+package bot
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"angrymiao-ai-server/src/core/botstats"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetBotStats 获取Bot调用统计
+// @Summary 获取Bot调用统计
+// @Description 返回Bot在对话中被调用的总次数、成功率、最近7天的平均耗时与最近30天每天的调用次数，只有创建者可以查看
+// @Tags Bot配置管理
+// @Produce json
+// @Param id path int true "Bot配置ID"
+// @Success 200 {object} botstats.Summary "成功"
+// @Failure 400 {object} map[string]interface{} "请求参数错误"
+// @Failure 403 {object} map[string]interface{} "无权限"
+// @Failure 404 {object} map[string]interface{} "配置不存在"
+// @Router /api/v2/bots/{id}/stats [get]
+func (h *BotConfigHandler) GetBotStats(c *gin.Context) {
+	userID := h.getUserID(c)
+	configID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		h.respondError(c, http.StatusBadRequest, "无效的配置ID", err)
+		return
+	}
+
+	config, err := h.botService.GetBotConfigByID(c.Request.Context(), uint(configID))
+	if err != nil {
+		if err.Error() == "Bot配置不存在" {
+			h.respondError(c, http.StatusNotFound, "Bot配置不存在", err)
+		} else {
+			h.respondError(c, http.StatusInternalServerError, "获取Bot配置失败", err)
+		}
+		return
+	}
+	if config.CreatorID != userID {
+		h.respondError(c, http.StatusForbidden, "只有创建者可以查看Bot调用统计", nil)
+		return
+	}
+
+	summary, err := botstats.Summarize(h.statsDB.WithContext(c.Request.Context()), config.ID, time.Now())
+	if err != nil {
+		h.respondError(c, http.StatusInternalServerError, "查询Bot调用统计失败", err)
+		return
+	}
+	h.respondSuccess(c, summary)
+}
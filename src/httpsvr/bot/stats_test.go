@@ -0,0 +1,68 @@
+package bot
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"angrymiao-ai-server/src/core/botstats"
+	"angrymiao-ai-server/src/core/utils"
+	"angrymiao-ai-server/src/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestGetBotStats(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "stats.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("打开数据库失败: %v", err)
+	}
+	if err := db.AutoMigrate(&models.BotUsageStat{}); err != nil {
+		t.Fatalf("迁移数据库失败: %v", err)
+	}
+	now := time.Now()
+	db.Create(&[]models.BotUsageStat{
+		{BotConfigID: 3, InvokedAt: now, LatencyMs: 100, Success: true},
+		{BotConfigID: 3, InvokedAt: now, LatencyMs: 300, Success: false},
+	})
+
+	logger, err := utils.NewLogger(&utils.LogCfg{LogLevel: "ERROR", LogDir: t.TempDir(), LogFile: "test.log"})
+	if err != nil {
+		t.Fatalf("创建日志失败: %v", err)
+	}
+	bot := &models.BotConfig{ID: 3, CreatorID: 42}
+	h := &BotConfigHandler{botService: &dryRunBotService{bot: bot}, logger: logger, statsDB: db}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) { c.Set("user_id", uint(42)) })
+	r.GET("/bots/:id/stats", h.GetBotStats)
+	get := func() (int, botstats.Summary) {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/bots/3/stats", nil))
+		var body struct {
+			Data botstats.Summary `json:"data"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &body)
+		return w.Code, body.Data
+	}
+
+	code, summary := get()
+	if code != http.StatusOK {
+		t.Fatalf("状态码 = %d", code)
+	}
+	if summary.TotalInvocations != 2 || summary.SuccessRate != 0.5 || summary.AvgLatencyMs != 200 || len(summary.Daily) != botstats.DailyDays {
+		t.Errorf("统计结果 = %+v", summary)
+	}
+
+	// 非创建者不能查看
+	bot.CreatorID = 7
+	if code, _ := get(); code != http.StatusForbidden {
+		t.Errorf("非创建者查看状态码 = %d, 期望 403", code)
+	}
+}
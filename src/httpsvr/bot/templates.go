@@ -0,0 +1,236 @@
+package bot
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"angrymiao-ai-server/src/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/datatypes"
+)
+
+// BotTemplate 预定义的Bot配置模板
+type BotTemplate struct {
+	ID           string          `json:"id"`
+	Name         string          `json:"name"`
+	Description  string          `json:"description"`
+	FunctionName string          `json:"function_name"`
+	Parameters   json.RawMessage `json:"parameters"`
+	MaxTokens    int             `json:"max_tokens"`
+	Temperature  float32         `json:"temperature"`
+	Language     string          `json:"language"` // en/zh
+}
+
+// 模板参数的 JSON Schema
+const (
+	assistantTemplateParameters = `{
+  "type": "object",
+  "properties": {
+    "question": {"type": "string", "description": "用户的问题或需要协助的事项"}
+  },
+  "required": ["question"]
+}`
+
+	translatorTemplateParameters = `{
+  "type": "object",
+  "properties": {
+    "text": {"type": "string", "description": "Text to translate"},
+    "target_language": {"type": "string", "description": "Target language, e.g. en, zh, ja"},
+    "source_language": {"type": "string", "description": "Source language, detected automatically when omitted"}
+  },
+  "required": ["text", "target_language"]
+}`
+
+	codeReviewerTemplateParameters = `{
+  "type": "object",
+  "properties": {
+    "code": {"type": "string", "description": "Code snippet to review"},
+    "language": {"type": "string", "description": "Programming language of the code"},
+    "focus": {"type": "string", "description": "Review focus such as bugs, performance or style"}
+  },
+  "required": ["code"]
+}`
+
+	storyTellerTemplateParameters = `{
+  "type": "object",
+  "properties": {
+    "topic": {"type": "string", "description": "故事的主题或主角"},
+    "style": {"type": "string", "description": "故事风格，如童话、冒险、睡前故事"},
+    "length": {"type": "string", "enum": ["short", "medium", "long"], "description": "故事篇幅"}
+  },
+  "required": ["topic"]
+}`
+)
+
+// botTemplates 模板列表，顺序即列表接口的返回顺序
+var botTemplates = []BotTemplate{
+	{
+		ID:           "assistant",
+		Name:         "通用助手",
+		Description:  "回答用户的各类问题，提供日常建议与帮助",
+		FunctionName: "assistant",
+		Parameters:   json.RawMessage(assistantTemplateParameters),
+		MaxTokens:    1024,
+		Temperature:  0.7,
+		Language:     "zh",
+	},
+	{
+		ID:           "translator",
+		Name:         "Translator",
+		Description:  "Translate text between languages while keeping the original meaning and tone",
+		FunctionName: "translate_text",
+		Parameters:   json.RawMessage(translatorTemplateParameters),
+		MaxTokens:    2048,
+		Temperature:  0.3,
+		Language:     "en",
+	},
+	{
+		ID:           "code-reviewer",
+		Name:         "Code Reviewer",
+		Description:  "Review code snippets and point out bugs, performance issues and style problems",
+		FunctionName: "review_code",
+		Parameters:   json.RawMessage(codeReviewerTemplateParameters),
+		MaxTokens:    4096,
+		Temperature:  0.2,
+		Language:     "en",
+	},
+	{
+		ID:           "story-teller",
+		Name:         "故事大王",
+		Description:  "根据主题和风格创作适合朗读的故事",
+		FunctionName: "tell_story",
+		Parameters:   json.RawMessage(storyTellerTemplateParameters),
+		MaxTokens:    2048,
+		Temperature:  0.9,
+		Language:     "zh",
+	},
+}
+
+// GetBotTemplates 获取所有预定义模板
+func GetBotTemplates() []BotTemplate {
+	templates := make([]BotTemplate, len(botTemplates))
+	copy(templates, botTemplates)
+	return templates
+}
+
+// findBotTemplate 根据ID查找模板
+func findBotTemplate(id string) (BotTemplate, bool) {
+	for _, template := range botTemplates {
+		if template.ID == id {
+			return template, true
+		}
+	}
+	return BotTemplate{}, false
+}
+
+// ListBotTemplates 获取Bot模板列表
+// @Summary 获取Bot模板列表
+// @Description 获取预定义的Bot配置模板，可按语言筛选
+// @Tags Bot配置管理
+// @Produce json
+// @Param language query string false "模板语言 en/zh"
+// @Success 200 {object} map[string]interface{} "获取成功"
+// @Router /api/v2/bots/templates [get]
+func (h *BotConfigHandler) ListBotTemplates(c *gin.Context) {
+	language := c.Query("language")
+
+	templates := make([]BotTemplate, 0, len(botTemplates))
+	for _, template := range GetBotTemplates() {
+		if language == "" || template.Language == language {
+			templates = append(templates, template)
+		}
+	}
+
+	h.respondSuccess(c, gin.H{
+		"templates": templates,
+		"total":     len(templates),
+	})
+}
+
+// CreateBotFromTemplate 从模板创建Bot配置
+// @Summary 从模板创建Bot配置
+// @Description 使用预定义模板的描述、参数和建议配置创建Bot，不再调用LLM生成参数
+// @Tags Bot配置管理
+// @Accept json
+// @Produce json
+// @Param template_id path string true "模板ID"
+// @Param config body models.CreateBotFromTemplateRequest true "配置信息"
+// @Success 201 {object} map[string]interface{} "创建成功"
+// @Failure 400 {object} map[string]interface{} "请求参数错误"
+// @Failure 404 {object} map[string]interface{} "模板不存在"
+// @Failure 500 {object} map[string]interface{} "服务器内部错误"
+// @Router /api/v2/bots/from-template/{template_id} [post]
+func (h *BotConfigHandler) CreateBotFromTemplate(c *gin.Context) {
+	userID := h.getUserID(c)
+
+	templateID := c.Param("template_id")
+	template, ok := findBotTemplate(templateID)
+	if !ok {
+		h.respondError(c, http.StatusNotFound, "Bot模板不存在: "+templateID, nil)
+		return
+	}
+
+	var req models.CreateBotFromTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.respondError(c, http.StatusBadRequest, "请求参数格式错误", err)
+		return
+	}
+
+	// 验证模型配置是否存在
+	if _, err := h.modelService.GetModelConfigByID(c.Request.Context(), req.ModelID); err != nil {
+		h.respondError(c, http.StatusBadRequest, "模型配置不存在", err)
+		return
+	}
+
+	if req.Visibility == "" {
+		req.Visibility = models.BotVisibilityPrivate
+	}
+	visibility, ok := resolveVisibility("", req.Visibility)
+	if !ok {
+		h.respondError(c, http.StatusBadRequest, "无效的可见性值", nil)
+		return
+	}
+
+	functionName := req.FunctionName
+	if functionName == "" {
+		functionName = template.FunctionName
+	}
+	maxTokens := req.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = template.MaxTokens
+	}
+
+	botHash, err := h.botService.GenerateBotHash(userID, functionName)
+	if err != nil {
+		h.respondError(c, http.StatusInternalServerError, "生成Bot Hash失败", err)
+		return
+	}
+
+	// 模板已包含参数Schema，无需调用LLM生成
+	config := &models.BotConfig{
+		CreatorID:    userID,
+		BotHash:      botHash,
+		Visibility:   visibility,
+		ModelID:      req.ModelID,
+		BotType:      "llm",
+		MaxTokens:    maxTokens,
+		Temperature:  template.Temperature,
+		FunctionName: functionName,
+		Description:  template.Description,
+		Parameters:   datatypes.JSON(template.Parameters),
+	}
+
+	if err := h.botService.CreateBotConfig(c.Request.Context(), config); err != nil {
+		h.respondError(c, http.StatusInternalServerError, "创建Bot配置失败", err)
+		return
+	}
+
+	h.logger.Info("用户 %d 从模板 %s 创建Bot配置成功: %s (ID: %d)", userID, template.ID, config.FunctionName, config.ID)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"code":    201,
+		"message": "Bot配置创建成功",
+		"data":    config.ToResponse(),
+	})
+}
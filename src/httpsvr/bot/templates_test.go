@@ -0,0 +1,162 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"angrymiao-ai-server/src/core/utils"
+	"angrymiao-ai-server/src/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+type templateTestBotService struct {
+	BotConfigService
+	created *models.BotConfig
+	updated bool
+}
+
+func (s *templateTestBotService) GenerateBotHash(creatorID uint, configName string) (string, error) {
+	return "hash-" + configName, nil
+}
+
+func (s *templateTestBotService) CreateBotConfig(ctx context.Context, config *models.BotConfig) error {
+	config.ID = 1
+	s.created = config
+	return nil
+}
+
+func (s *templateTestBotService) UpdateBotConfig(ctx context.Context, config *models.BotConfig) error {
+	s.updated = true
+	return nil
+}
+
+type templateTestModelService struct {
+	ModelConfigService
+}
+
+func (s *templateTestModelService) GetModelConfigByID(ctx context.Context, id uint) (*models.ModelConfig, error) {
+	if id != 7 {
+		return nil, errors.New("not found")
+	}
+	return &models.ModelConfig{}, nil
+}
+
+func newTemplateTestRouter(t *testing.T, botService *templateTestBotService) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	logger, err := utils.NewLogger(&utils.LogCfg{LogLevel: "ERROR", LogDir: t.TempDir(), LogFile: "test.log"})
+	if err != nil {
+		t.Fatalf("创建日志失败: %v", err)
+	}
+	h := &BotConfigHandler{
+		botService:   botService,
+		modelService: &templateTestModelService{},
+		logger:       logger,
+	}
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("user_id", uint(42))
+	})
+	r.GET("/bots/templates", h.ListBotTemplates)
+	r.POST("/bots/from-template/:template_id", h.CreateBotFromTemplate)
+	return r
+}
+
+func TestBotTemplates(t *testing.T) {
+	templates := GetBotTemplates()
+	for _, id := range []string{"assistant", "translator", "code-reviewer", "story-teller"} {
+		template, ok := findBotTemplate(id)
+		if !ok {
+			t.Fatalf("缺少模板 %s", id)
+		}
+		if template.FunctionName == "" || template.Description == "" || template.MaxTokens <= 0 {
+			t.Errorf("模板 %s 字段不完整: %+v", id, template)
+		}
+		if template.Language != "en" && template.Language != "zh" {
+			t.Errorf("模板 %s 语言无效: %s", id, template.Language)
+		}
+		var schema map[string]interface{}
+		if err := json.Unmarshal(template.Parameters, &schema); err != nil || schema["type"] != "object" {
+			t.Errorf("模板 %s 参数Schema无效: %v", id, err)
+		}
+	}
+	if len(templates) != 4 {
+		t.Errorf("模板数量 = %d, 期望 4", len(templates))
+	}
+
+	r := newTemplateTestRouter(t, &templateTestBotService{})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/bots/templates?language=zh", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("状态码 = %d, body: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Data struct {
+			Templates []BotTemplate `json:"templates"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("解析响应失败: %v", err)
+	}
+	if len(resp.Data.Templates) != 2 {
+		t.Errorf("zh 模板数量 = %d, 期望 2", len(resp.Data.Templates))
+	}
+}
+
+func TestCreateBotFromTemplate(t *testing.T) {
+	botService := &templateTestBotService{}
+	r := newTemplateTestRouter(t, botService)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/bots/from-template/translator", strings.NewReader(`{"model_id":7}`)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("状态码 = %d, body: %s", w.Code, w.Body.String())
+	}
+
+	config := botService.created
+	if config == nil {
+		t.Fatal("未创建Bot配置")
+	}
+	template, _ := findBotTemplate("translator")
+	if config.FunctionName != template.FunctionName || config.Description != template.Description ||
+		config.MaxTokens != template.MaxTokens || config.ModelID != 7 || config.CreatorID != 42 ||
+		config.Visibility != "private" || config.BotType != "llm" || config.BotHash != "hash-translate_text" {
+		t.Errorf("Bot配置未按模板填充: %+v", config)
+	}
+	if string(config.Parameters) != string(template.Parameters) {
+		t.Errorf("Parameters = %s, 期望模板参数", config.Parameters)
+	}
+	if botService.updated {
+		t.Error("从模板创建时不应再生成参数")
+	}
+}
+
+func TestCreateBotFromTemplateErrors(t *testing.T) {
+	r := newTemplateTestRouter(t, &templateTestBotService{})
+
+	tests := []struct {
+		name string
+		path string
+		body string
+		code int
+	}{
+		{"未知模板", "/bots/from-template/unknown", `{"model_id":7}`, http.StatusNotFound},
+		{"缺少模型", "/bots/from-template/assistant", `{}`, http.StatusBadRequest},
+		{"模型不存在", "/bots/from-template/assistant", `{"model_id":8}`, http.StatusBadRequest},
+		{"无效可见性", "/bots/from-template/assistant", `{"model_id":7,"visibility":"friends"}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body)))
+			if w.Code != tt.code {
+				t.Errorf("状态码 = %d, 期望 %d, body: %s", w.Code, tt.code, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,202 @@
+package device
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+/*
+* OAuth2 设备授权流程（RFC 8628），用于设备绑定：
+* 1. 设备调用 POST /api/v2/auth/device/code 获取 device_code 与 user_code，并向用户展示 user_code
+* 2. 用户登录后在浏览器中调用 POST /api/v2/auth/device/approve 提交 user_code，完成设备绑定
+* 3. 设备按 interval 轮询 GET /api/v2/auth/device/token，授权后获得设备JWT
+* 授权记录保存在Redis中，15分钟后过期。
+ */
+
+const (
+	// deviceCodeTTL 设备授权码有效期
+	deviceCodeTTL = 15 * time.Minute
+	// deviceCodePollInterval 建议设备轮询的间隔(秒)
+	deviceCodePollInterval = 5
+	// userCodeAlphabet 用户码字符集，去除元音与易混淆字符
+	userCodeAlphabet = "BCDFGHJKLMNPQRSTVWXZ"
+	userCodeLength   = 8
+)
+
+const (
+	deviceAuthPending  = "pending"
+	deviceAuthApproved = "approved"
+)
+
+var (
+	// ErrDeviceCodeNotFound 授权码不存在或已过期
+	ErrDeviceCodeNotFound = errors.New("device code not found or expired")
+	// ErrDeviceCodeUsed 授权码已被批准
+	ErrDeviceCodeUsed = errors.New("device code already approved")
+)
+
+// DeviceAuthorization 一次设备授权
+type DeviceAuthorization struct {
+	DeviceCode string `json:"device_code"`
+	UserCode   string `json:"user_code"`
+	DeviceID   string `json:"device_id"`
+	Status     string `json:"status"`
+	UserID     uint   `json:"user_id,omitempty"`
+	BindKey    string `json:"bind_key,omitempty"` // 批准时生成的绑定密钥，随token下发给设备
+}
+
+// DeviceFlowStore 基于Redis保存设备授权记录
+type DeviceFlowStore struct {
+	client *redis.Client
+}
+
+// NewDeviceFlowStore 创建设备授权存储，client 为nil时不可用
+func NewDeviceFlowStore(client *redis.Client) *DeviceFlowStore {
+	return &DeviceFlowStore{client: client}
+}
+
+func deviceCodeKey(deviceCode string) string {
+	return fmt.Sprintf("device_flow:code:%s", deviceCode)
+}
+
+func userCodeKey(userCode string) string {
+	return fmt.Sprintf("device_flow:user:%s", userCode)
+}
+
+// normalizeUserCode 用户码不区分大小写，忽略分隔符与空格
+func normalizeUserCode(userCode string) string {
+	userCode = strings.ToUpper(userCode)
+	userCode = strings.ReplaceAll(userCode, "-", "")
+	return strings.ReplaceAll(userCode, " ", "")
+}
+
+// FormatUserCode 按 XXXX-XXXX 格式展示用户码
+func FormatUserCode(userCode string) string {
+	if len(userCode) != userCodeLength {
+		return userCode
+	}
+	return userCode[:4] + "-" + userCode[4:]
+}
+
+func generateUserCode() (string, error) {
+	var b strings.Builder
+	max := big.NewInt(int64(len(userCodeAlphabet)))
+	for i := 0; i < userCodeLength; i++ {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		b.WriteByte(userCodeAlphabet[n.Int64()])
+	}
+	return b.String(), nil
+}
+
+func generateDeviceCode() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// Create 为设备生成一对授权码
+func (s *DeviceFlowStore) Create(ctx context.Context, deviceID string) (*DeviceAuthorization, error) {
+	if s.client == nil {
+		return nil, fmt.Errorf("未配置Redis，无法使用设备授权")
+	}
+	deviceCode, err := generateDeviceCode()
+	if err != nil {
+		return nil, fmt.Errorf("生成设备码失败: %v", err)
+	}
+	for attempt := 0; attempt < 3; attempt++ {
+		userCode, err := generateUserCode()
+		if err != nil {
+			return nil, fmt.Errorf("生成用户码失败: %v", err)
+		}
+		// 用户码较短，确认未被其他授权占用
+		ok, err := s.client.SetNX(ctx, userCodeKey(userCode), deviceCode, deviceCodeTTL).Result()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		auth := &DeviceAuthorization{DeviceCode: deviceCode, UserCode: userCode, DeviceID: deviceID, Status: deviceAuthPending}
+		if err := s.save(ctx, auth, deviceCodeTTL); err != nil {
+			return nil, err
+		}
+		return auth, nil
+	}
+	return nil, fmt.Errorf("生成用户码失败: 重试次数过多")
+}
+
+func (s *DeviceFlowStore) save(ctx context.Context, auth *DeviceAuthorization, ttl time.Duration) error {
+	data, err := json.Marshal(auth)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(ctx, deviceCodeKey(auth.DeviceCode), data, ttl).Err()
+}
+
+// Get 按设备码查询授权，不存在或已过期时返回 ErrDeviceCodeNotFound
+func (s *DeviceFlowStore) Get(ctx context.Context, deviceCode string) (*DeviceAuthorization, error) {
+	if s.client == nil {
+		return nil, fmt.Errorf("未配置Redis，无法使用设备授权")
+	}
+	data, err := s.client.Get(ctx, deviceCodeKey(deviceCode)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrDeviceCodeNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var auth DeviceAuthorization
+	if err := json.Unmarshal(data, &auth); err != nil {
+		return nil, fmt.Errorf("解析设备授权失败: %v", err)
+	}
+	return &auth, nil
+}
+
+// FindByUserCode 按用户码查询待批准的授权
+func (s *DeviceFlowStore) FindByUserCode(ctx context.Context, userCode string) (*DeviceAuthorization, error) {
+	if s.client == nil {
+		return nil, fmt.Errorf("未配置Redis，无法使用设备授权")
+	}
+	deviceCode, err := s.client.Get(ctx, userCodeKey(normalizeUserCode(userCode))).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrDeviceCodeNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	auth, err := s.Get(ctx, deviceCode)
+	if err != nil {
+		return nil, err
+	}
+	if auth.Status != deviceAuthPending {
+		return nil, ErrDeviceCodeUsed
+	}
+	return auth, nil
+}
+
+// Approve 记录用户对授权的批准，有效期不变
+func (s *DeviceFlowStore) Approve(ctx context.Context, auth *DeviceAuthorization, userID uint, bindKey string) error {
+	auth.Status = deviceAuthApproved
+	auth.UserID = userID
+	auth.BindKey = bindKey
+	return s.save(ctx, auth, redis.KeepTTL)
+}
+
+// Delete 设备换取token后删除授权，授权码只能使用一次
+func (s *DeviceFlowStore) Delete(ctx context.Context, auth *DeviceAuthorization) error {
+	return s.client.Del(ctx, deviceCodeKey(auth.DeviceCode), userCodeKey(auth.UserCode)).Err()
+}
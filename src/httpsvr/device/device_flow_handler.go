@@ -0,0 +1,173 @@
+package device
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"angrymiao-ai-server/src/core/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// registerDeviceFlowRoutes 注册设备授权流程路由，批准接口需要用户登录
+func (s *DefaultDeviceService) registerDeviceFlowRoutes(apiGroup *gin.RouterGroup, userAuth gin.HandlerFunc) {
+	flowGroup := apiGroup.Group("/v2/auth/device")
+	flowGroup.POST("/code", s.handleDeviceCode)
+	flowGroup.GET("/token", s.handleDeviceToken)
+	flowGroup.POST("/approve", userAuth, s.handleDeviceApprove)
+}
+
+// @Summary 获取设备授权码
+// @Description 设备申请 device_code 与 user_code，用户在浏览器中输入 user_code 完成绑定
+// @Tags Device
+// @Accept json
+// @Produce json
+// @Param body body DeviceCodeRequest true "设备授权码请求"
+// @Success 200 {object} DeviceCodeResponse "获取成功"
+// @Failure 400 {object} DeviceCodeResponse "请求参数错误"
+// @Failure 503 {object} DeviceCodeResponse "设备授权不可用"
+// @Router /v2/auth/device/code [post]
+func (s *DefaultDeviceService) handleDeviceCode(c *gin.Context) {
+	var body DeviceCodeRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		utils.Custom(c, http.StatusBadRequest, DeviceCodeResponse{Message: "请求参数格式错误: " + err.Error()})
+		return
+	}
+	if !ValidateDeviceID(body.DeviceID) {
+		utils.Custom(c, http.StatusBadRequest, DeviceCodeResponse{Message: "设备ID格式无效"})
+		return
+	}
+
+	auth, err := s.flowStore.Create(c.Request.Context(), body.DeviceID)
+	if err != nil {
+		s.logger.Error("创建设备授权失败: %v", err)
+		utils.Custom(c, http.StatusServiceUnavailable, DeviceCodeResponse{Message: "设备授权暂不可用"})
+		return
+	}
+
+	s.logger.Info("设备申请授权码 - 设备ID: %s", body.DeviceID)
+	utils.Custom(c, http.StatusOK, DeviceCodeResponse{
+		Success:    true,
+		DeviceCode: auth.DeviceCode,
+		UserCode:   FormatUserCode(auth.UserCode),
+		ExpiresIn:  int(deviceCodeTTL.Seconds()),
+		Interval:   deviceCodePollInterval,
+	})
+}
+
+// @Summary 批准设备授权
+// @Description 用户输入设备上展示的 user_code，将设备绑定到当前账户
+// @Tags Device
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer <UserJWT>"
+// @Param body body DeviceApproveRequest true "批准请求"
+// @Success 200 {object} DeviceApproveResponse "批准成功"
+// @Failure 400 {object} DeviceApproveResponse "用户码无效或已过期"
+// @Failure 401 {object} DeviceApproveResponse "认证失败"
+// @Failure 409 {object} DeviceApproveResponse "设备已绑定到其他用户"
+// @Failure 500 {object} DeviceApproveResponse "服务器内部错误"
+// @Router /v2/auth/device/approve [post]
+func (s *DefaultDeviceService) handleDeviceApprove(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	if userID == 0 {
+		utils.Custom(c, http.StatusUnauthorized, DeviceApproveResponse{Message: "无效的认证token或token已过期"})
+		return
+	}
+
+	var body DeviceApproveRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		utils.Custom(c, http.StatusBadRequest, DeviceApproveResponse{Message: "请求参数格式错误: " + err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	auth, err := s.flowStore.FindByUserCode(ctx, body.UserCode)
+	if errors.Is(err, ErrDeviceCodeNotFound) || errors.Is(err, ErrDeviceCodeUsed) {
+		utils.Custom(c, http.StatusBadRequest, DeviceApproveResponse{Message: "用户码无效或已过期"})
+		return
+	}
+	if err != nil {
+		s.logger.Error("查询设备授权失败: %v", err)
+		utils.Custom(c, http.StatusInternalServerError, DeviceApproveResponse{Message: "查询设备授权失败"})
+		return
+	}
+
+	// 已绑定到其他用户的设备需先解绑
+	if existing, err := s.deviceDB.GetDevice(auth.DeviceID); err == nil && existing.UserID != userID {
+		utils.Custom(c, http.StatusConflict, DeviceApproveResponse{Message: "设备已绑定到其他用户"})
+		return
+	}
+
+	bindKey := GenerateBindKey(auth.DeviceID, s.config.Server.Token, userID)
+	if err := s.deviceDB.SaveDevice(auth.DeviceID, userID, bindKey); err != nil {
+		s.logger.Error("保存设备绑定信息失败: %v", err)
+		utils.Custom(c, http.StatusInternalServerError, DeviceApproveResponse{Message: "保存绑定信息失败"})
+		return
+	}
+	if err := s.flowStore.Approve(ctx, auth, userID, bindKey); err != nil {
+		s.logger.Error("更新设备授权失败: %v", err)
+		utils.Custom(c, http.StatusInternalServerError, DeviceApproveResponse{Message: "更新设备授权失败"})
+		return
+	}
+
+	s.logger.Info("用户批准设备授权 - 用户ID: %d, 设备ID: %s", userID, auth.DeviceID)
+	utils.Custom(c, http.StatusOK, DeviceApproveResponse{
+		Success:  true,
+		DeviceID: auth.DeviceID,
+	})
+}
+
+// @Summary 轮询设备token
+// @Description 设备按 interval 轮询，用户批准后返回设备密钥与7天有效期token；授权码只能换取一次
+// @Tags Device
+// @Produce json
+// @Param device_code query string true "设备码"
+// @Success 200 {object} DeviceTokenResponse "授权成功"
+// @Failure 400 {object} DeviceTokenResponse "authorization_pending / expired_token"
+// @Failure 500 {object} DeviceTokenResponse "服务器内部错误"
+// @Router /v2/auth/device/token [get]
+func (s *DefaultDeviceService) handleDeviceToken(c *gin.Context) {
+	deviceCode := c.Query("device_code")
+	if deviceCode == "" {
+		utils.Custom(c, http.StatusBadRequest, DeviceTokenResponse{Message: "device_code不能为空"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	auth, err := s.flowStore.Get(ctx, deviceCode)
+	if errors.Is(err, ErrDeviceCodeNotFound) {
+		utils.Custom(c, http.StatusBadRequest, DeviceTokenResponse{Error: "expired_token", Message: "设备码无效或已过期"})
+		return
+	}
+	if err != nil {
+		s.logger.Error("查询设备授权失败: %v", err)
+		utils.Custom(c, http.StatusInternalServerError, DeviceTokenResponse{Message: "查询设备授权失败"})
+		return
+	}
+	if auth.Status != deviceAuthApproved {
+		utils.Custom(c, http.StatusBadRequest, DeviceTokenResponse{Error: "authorization_pending", Message: "等待用户授权"})
+		return
+	}
+
+	sevenDays := 7 * 24 * time.Hour
+	deviceToken, err := s.authToken.GenerateTokenWithExpiry(auth.UserID, auth.DeviceID, sevenDays)
+	if err != nil {
+		s.logger.Error("生成设备token失败: %v", err)
+		utils.Custom(c, http.StatusInternalServerError, DeviceTokenResponse{Message: "生成设备token失败"})
+		return
+	}
+	if err := s.flowStore.Delete(ctx, auth); err != nil {
+		s.logger.Error("删除设备授权失败: %v", err)
+		utils.Custom(c, http.StatusInternalServerError, DeviceTokenResponse{Message: "删除设备授权失败"})
+		return
+	}
+
+	s.logger.Info("设备授权完成 - 用户ID: %d, 设备ID: %s", auth.UserID, auth.DeviceID)
+	utils.Custom(c, http.StatusOK, DeviceTokenResponse{
+		Success:   true,
+		DeviceKey: auth.BindKey,
+		Token:     deviceToken,
+	})
+}
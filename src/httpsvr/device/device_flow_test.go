@@ -0,0 +1,160 @@
+package device
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"angrymiao-ai-server/src/configs"
+	"angrymiao-ai-server/src/core/auth"
+	"angrymiao-ai-server/src/core/utils"
+	"angrymiao-ai-server/src/models"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type flowResponse struct {
+	Code int             `json:"code"`
+	Data json.RawMessage `json:"data"`
+}
+
+func newDeviceFlowTestServer(t *testing.T) (*gin.Engine, *miniredis.Miniredis, *DefaultDeviceService) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	logger, err := utils.NewLogger(&utils.LogCfg{LogLevel: "ERROR", LogDir: t.TempDir(), LogFile: "test.log"})
+	if err != nil {
+		t.Fatalf("创建日志失败: %v", err)
+	}
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "device.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("打开数据库失败: %v", err)
+	}
+	if err := db.AutoMigrate(&models.Device{}); err != nil {
+		t.Fatalf("迁移数据库失败: %v", err)
+	}
+	mr := miniredis.RunT(t)
+
+	config := &configs.Config{}
+	config.Server.Token = "test-secret"
+	s := &DefaultDeviceService{
+		logger:    logger,
+		config:    config,
+		authToken: auth.NewAuthTokenWithConfig(config.Server.Token, "am_topic"),
+		deviceDB:  &DeviceDB{db: db},
+		flowStore: NewDeviceFlowStore(redis.NewClient(&redis.Options{Addr: mr.Addr()})),
+	}
+
+	// 测试中由 X-User-Id 头模拟已登录用户
+	userAuth := func(c *gin.Context) {
+		var userID uint
+		json.Unmarshal([]byte(c.GetHeader("X-User-Id")), &userID)
+		c.Set("user_id", userID)
+		c.Next()
+	}
+	engine := gin.New()
+	s.registerDeviceFlowRoutes(engine.Group("/api"), userAuth)
+	return engine, mr, s
+}
+
+func doFlowRequest(t *testing.T, engine *gin.Engine, method, path, userID string, body interface{}, out interface{}) int {
+	t.Helper()
+	var reader *bytes.Reader
+	if body != nil {
+		data, _ := json.Marshal(body)
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	if userID != "" {
+		req.Header.Set("X-User-Id", userID)
+	}
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	var resp flowResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("解析响应失败: %v, body=%s", err, w.Body.String())
+	}
+	if out != nil {
+		json.Unmarshal(resp.Data, out)
+	}
+	return w.Code
+}
+
+func TestDeviceFlowPollingCycle(t *testing.T) {
+	engine, _, s := newDeviceFlowTestServer(t)
+
+	var code DeviceCodeResponse
+	if status := doFlowRequest(t, engine, http.MethodPost, "/api/v2/auth/device/code", "", DeviceCodeRequest{DeviceID: "dev-1"}, &code); status != http.StatusOK {
+		t.Fatalf("获取授权码状态码 = %d", status)
+	}
+	if code.DeviceCode == "" || len(code.UserCode) != 9 || code.ExpiresIn != 900 || code.Interval != 5 {
+		t.Fatalf("授权码响应 = %+v", code)
+	}
+	tokenPath := "/api/v2/auth/device/token?device_code=" + code.DeviceCode
+
+	var pending DeviceTokenResponse
+	if status := doFlowRequest(t, engine, http.MethodGet, tokenPath, "", nil, &pending); status != http.StatusBadRequest || pending.Error != "authorization_pending" {
+		t.Fatalf("批准前轮询 = %d %+v, 期望 authorization_pending", status, pending)
+	}
+
+	// 用户码不区分大小写、忽略分隔符
+	var approve DeviceApproveResponse
+	if status := doFlowRequest(t, engine, http.MethodPost, "/api/v2/auth/device/approve", "7", DeviceApproveRequest{UserCode: " " + code.UserCode[:4] + code.UserCode[5:]}, &approve); status != http.StatusOK {
+		t.Fatalf("批准状态码 = %d, %+v", status, approve)
+	}
+	if status := doFlowRequest(t, engine, http.MethodPost, "/api/v2/auth/device/approve", "7", DeviceApproveRequest{UserCode: code.UserCode}, nil); status != http.StatusBadRequest {
+		t.Errorf("重复批准状态码 = %d, 期望 400", status)
+	}
+
+	var token DeviceTokenResponse
+	if status := doFlowRequest(t, engine, http.MethodGet, tokenPath, "", nil, &token); status != http.StatusOK || token.Token == "" {
+		t.Fatalf("批准后轮询 = %d %+v", status, token)
+	}
+	_, deviceID, userID, err := s.authToken.VerifyToken(token.Token, true)
+	if err != nil || deviceID != "dev-1" || userID != 7 {
+		t.Errorf("token claims = device %q user %d err %v", deviceID, userID, err)
+	}
+	bound, err := s.deviceDB.GetDevice("dev-1")
+	if err != nil || bound.UserID != 7 || bound.BindKey != token.DeviceKey {
+		t.Errorf("设备绑定 = %+v err %v, device_key = %q", bound, err, token.DeviceKey)
+	}
+
+	// 授权码只能换取一次
+	var replay DeviceTokenResponse
+	if status := doFlowRequest(t, engine, http.MethodGet, tokenPath, "", nil, &replay); status != http.StatusBadRequest || replay.Error != "expired_token" {
+		t.Errorf("重复换取 = %d %+v, 期望 expired_token", status, replay)
+	}
+
+	// 已绑定到其他用户的设备不能被批准
+	var second DeviceCodeResponse
+	doFlowRequest(t, engine, http.MethodPost, "/api/v2/auth/device/code", "", DeviceCodeRequest{DeviceID: "dev-1"}, &second)
+	if status := doFlowRequest(t, engine, http.MethodPost, "/api/v2/auth/device/approve", "8", DeviceApproveRequest{UserCode: second.UserCode}, nil); status != http.StatusConflict {
+		t.Errorf("其他用户批准状态码 = %d, 期望 409", status)
+	}
+}
+
+func TestDeviceFlowExpiry(t *testing.T) {
+	engine, mr, _ := newDeviceFlowTestServer(t)
+
+	var code DeviceCodeResponse
+	doFlowRequest(t, engine, http.MethodPost, "/api/v2/auth/device/code", "", DeviceCodeRequest{DeviceID: "dev-2"}, &code)
+	mr.FastForward(16 * time.Minute)
+
+	if status := doFlowRequest(t, engine, http.MethodPost, "/api/v2/auth/device/approve", "7", DeviceApproveRequest{UserCode: code.UserCode}, nil); status != http.StatusBadRequest {
+		t.Errorf("过期后批准状态码 = %d, 期望 400", status)
+	}
+	var token DeviceTokenResponse
+	if status := doFlowRequest(t, engine, http.MethodGet, "/api/v2/auth/device/token?device_code="+code.DeviceCode, "", nil, &token); status != http.StatusBadRequest || token.Error != "expired_token" {
+		t.Errorf("过期后轮询 = %d %+v, 期望 expired_token", status, token)
+	}
+}
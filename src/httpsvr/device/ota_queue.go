@@ -0,0 +1,73 @@
+package device
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// pendingOTATTL 离线设备待推送OTA消息的保留时间
+const pendingOTATTL = 7 * 24 * time.Hour
+
+// OTAMessage 推送给设备的固件升级消息
+type OTAMessage struct {
+	Type        string `json:"type"`
+	Version     string `json:"version"`
+	URL         string `json:"url"`
+	Checksum    string `json:"checksum"`
+	AutoApprove bool   `json:"auto_approve"` // 为true时设备无需用户确认直接升级
+}
+
+// NewOTAMessage 创建OTA推送消息
+func NewOTAMessage(version, url, checksum string, autoApprove bool) *OTAMessage {
+	return &OTAMessage{
+		Type:        "ota",
+		Version:     version,
+		URL:         url,
+		Checksum:    checksum,
+		AutoApprove: autoApprove,
+	}
+}
+
+// OTAQueue 离线设备的OTA消息队列，设备上线后推送
+type OTAQueue interface {
+	// Enqueue 保存待推送的OTA消息，同一设备只保留最新一条
+	Enqueue(ctx context.Context, deviceID string, data []byte) error
+	// Pop 取出并删除待推送的OTA消息，没有时返回nil
+	Pop(ctx context.Context, deviceID string) ([]byte, error)
+}
+
+// RedisOTAQueue 基于Redis的OTA消息队列
+type RedisOTAQueue struct {
+	client *redis.Client
+}
+
+// NewRedisOTAQueue 创建基于Redis的OTA消息队列，client 为nil时队列不可用
+func NewRedisOTAQueue(client *redis.Client) *RedisOTAQueue {
+	return &RedisOTAQueue{client: client}
+}
+
+func pendingOTAKey(deviceID string) string {
+	return fmt.Sprintf("ota:pending:%s", deviceID)
+}
+
+func (q *RedisOTAQueue) Enqueue(ctx context.Context, deviceID string, data []byte) error {
+	if q.client == nil {
+		return fmt.Errorf("未配置Redis，无法保存离线OTA消息")
+	}
+	return q.client.Set(ctx, pendingOTAKey(deviceID), data, pendingOTATTL).Err()
+}
+
+func (q *RedisOTAQueue) Pop(ctx context.Context, deviceID string) ([]byte, error) {
+	if q.client == nil {
+		return nil, nil
+	}
+	data, err := q.client.GetDel(ctx, pendingOTAKey(deviceID)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	return data, err
+}
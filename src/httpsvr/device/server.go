@@ -7,8 +7,10 @@ import (
 	"time"
 
 	"angrymiao-ai-server/src/configs"
+	"angrymiao-ai-server/src/configs/cache"
 	"angrymiao-ai-server/src/core/auth"
 	"angrymiao-ai-server/src/core/auth/am_token"
+	"angrymiao-ai-server/src/core/middleware"
 	"angrymiao-ai-server/src/core/utils"
 
 	"github.com/gin-gonic/gin"
@@ -19,6 +21,7 @@ type DefaultDeviceService struct {
 	config    *configs.Config
 	authToken *auth.AuthToken
 	deviceDB  *DeviceDB
+	flowStore *DeviceFlowStore
 }
 
 // NewDefaultDeviceService 构造函数
@@ -28,7 +31,8 @@ func NewDefaultDeviceService(config *configs.Config, logger *utils.Logger) *Defa
 	if topicRoot == "" {
 		topicRoot = "am_topic" // 默认值
 	}
-	authToken := auth.NewAuthTokenWithConfig(config.Server.Token, topicRoot)
+	// 多租户时 topic_root 含 {tenant} 占位符，ACL 允许任意租户层级
+	authToken := auth.NewAuthTokenWithConfig(config.Server.Token, strings.ReplaceAll(topicRoot, "{tenant}", "+"))
 
 	// 初始化设备绑定数据库操作
 	deviceDB := NewDeviceDB()
@@ -38,6 +42,7 @@ func NewDefaultDeviceService(config *configs.Config, logger *utils.Logger) *Defa
 		config:    config,
 		authToken: authToken,
 		deviceDB:  deviceDB,
+		flowStore: NewDeviceFlowStore(cache.GetRedis()),
 	}
 }
 
@@ -46,6 +51,7 @@ func (s *DefaultDeviceService) Start(ctx context.Context, engine *gin.Engine, ap
 	apiGroup.POST("/device/bind", s.handleDeviceBind)
 	apiGroup.POST("/device/unbind", s.handleDeviceUnbind)
 	apiGroup.GET("/device/refresh_token", s.handleDeviceRefToken)
+	s.registerDeviceFlowRoutes(apiGroup, middleware.AmTokenJWTUserAuth())
 }
 
 // @Summary 刷新设备token
@@ -30,3 +30,39 @@ type RefreshTokenResponse struct {
 	Token   string `json:"token,omitempty"`
 	Message string `json:"message,omitempty"`
 }
+
+// DeviceCodeRequest 设备授权码请求
+type DeviceCodeRequest struct {
+	DeviceID string `json:"device_id"`
+}
+
+// DeviceCodeResponse 设备授权码响应
+type DeviceCodeResponse struct {
+	Success    bool   `json:"success"`
+	DeviceCode string `json:"device_code,omitempty"` // 设备轮询token时使用
+	UserCode   string `json:"user_code,omitempty"`   // 展示给用户，在浏览器中输入
+	ExpiresIn  int    `json:"expires_in,omitempty"`  // 授权码有效期(秒)
+	Interval   int    `json:"interval,omitempty"`    // 建议轮询间隔(秒)
+	Message    string `json:"message,omitempty"`
+}
+
+// DeviceApproveRequest 用户批准设备授权请求
+type DeviceApproveRequest struct {
+	UserCode string `json:"user_code" binding:"required"`
+}
+
+// DeviceApproveResponse 用户批准设备授权响应
+type DeviceApproveResponse struct {
+	Success  bool   `json:"success"`
+	DeviceID string `json:"device_id,omitempty"`
+	Message  string `json:"message,omitempty"`
+}
+
+// DeviceTokenResponse 设备轮询token响应
+type DeviceTokenResponse struct {
+	Success   bool   `json:"success"`
+	Error     string `json:"error,omitempty"`      // authorization_pending / expired_token
+	DeviceKey string `json:"device_key,omitempty"` // 设备长期密钥（明文，仅下发一次）
+	Token     string `json:"token,omitempty"`      // 7天有效期DeviceToken
+	Message   string `json:"message,omitempty"`
+}
@@ -252,7 +252,7 @@ func (s *DefaultOTAService) handlePostOTA(c *gin.Context) {
 		version = strings.TrimSuffix(latest, ".bin")
 		firmwareURL = "/ota_bin/" + latest
 	}
-	cfg := configs.Cfg
+	cfg := configs.Current()
 	updateURL := cfg.Web.Websocket
 	deviceName := req.Board.Name
 	s.CheckAndUpdateDevice(c, cfg, req, deviceID, client_id, deviceName, version)
@@ -5,6 +5,7 @@ import (
 	"crypto/hmac"
 	"crypto/sha1"
 	"encoding/base64"
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
 	"hash"
@@ -15,9 +16,11 @@ import (
 	"time"
 
 	"angrymiao-ai-server/src/configs"
+	"angrymiao-ai-server/src/configs/cache"
 	"angrymiao-ai-server/src/configs/database"
 	"angrymiao-ai-server/src/core/auth"
 	"angrymiao-ai-server/src/core/image"
+	"angrymiao-ai-server/src/core/media"
 	"angrymiao-ai-server/src/core/middleware"
 	"angrymiao-ai-server/src/core/providers"
 	"angrymiao-ai-server/src/core/providers/vlllm"
@@ -32,10 +35,15 @@ const (
 	MAX_FILE_SIZE = 5 * 1024 * 1024
 )
 
+// transcodeToJPEG 将HEIC/AVIF图片转码为JPEG，测试中可替换
+var transcodeToJPEG = image.TranscodeToJPEG
+
 type DefaultVisionService struct {
 	logger   *utils.Logger
 	config   *configs.Config
 	vlllmMap map[string]*vlllm.Provider // 支持多个VLLLM provider
+
+	presign *media.PresignService // 私有媒体的预签名地址，未配置OSS密钥时为nil
 }
 
 // NewDefaultVisionService 构造函数
@@ -51,6 +59,12 @@ func NewDefaultVisionService(config *configs.Config, logger *utils.Logger) (*Def
 		return nil, fmt.Errorf("初始化VLLLM providers失败: %v", err)
 	}
 
+	presign, err := media.NewOSSPresignService(&config.OSS, cache.GetRedis(), config.RedisCache.Service)
+	if err != nil {
+		logger.Warn("初始化OSS预签名失败，上传完成后不返回预签名地址: %v", err)
+	}
+	service.presign = presign
+
 	return service, nil
 }
 
@@ -216,12 +230,24 @@ func (s *DefaultVisionService) handleUploadComplete(c *gin.Context) {
 		return
 	}
 
+	// 私有bucket中的文件需通过预签名地址访问，生成失败时仍返回上传记录
+	var presignedURL string
+	if s.presign != nil && rec.Path != "" {
+		presigned, err := s.presign.Presign(c.Request.Context(), rec.ID, strings.TrimLeft(rec.Path, "/"), media.PresignTTL(&s.config.OSS))
+		if err != nil {
+			s.logger.Warn("生成预签名地址失败: %v", err)
+		} else {
+			presignedURL = presigned.URL
+		}
+	}
+
 	utils.Custom(c, http.StatusOK, UploadCompleteResponse{
 		Success: true,
 		Result: struct {
-			ID  uint   `json:"id"`
-			URL string `json:"url"`
-		}{ID: rec.ID, URL: rec.URL},
+			ID           uint   `json:"id"`
+			URL          string `json:"url"`
+			PresignedURL string `json:"presigned_url,omitempty"`
+		}{ID: rec.ID, URL: rec.URL, PresignedURL: presignedURL},
 		Message: "上传记录保存成功",
 	})
 }
@@ -345,7 +371,7 @@ func (s *DefaultVisionService) parseMultipartRequest(c *gin.Context, deviceID st
 
 		// 验证图片格式
 		if !s.isValidImageFile(imageData) {
-			return nil, fmt.Errorf("不支持的文件格式，请上传有效的图片文件（支持JPEG、PNG、GIF、BMP、TIFF、WEBP格式）")
+			return nil, fmt.Errorf("不支持的文件格式，请上传有效的图片文件（支持JPEG、PNG、GIF、BMP、TIFF、WEBP、HEIC、AVIF格式）")
 		}
 
 		// 将图片保存在本地
@@ -400,11 +426,31 @@ func (s *DefaultVisionService) processVisionRequest(req *VisionRequest) (string,
 	}
 
 	if req.FileType == "file" {
-		// 将图片转换为base64
-		imageBase64 := base64.StdEncoding.EncodeToString(req.Image)
+		security := &provider.GetConfig().Security
+		data := req.Image
+		// VLLLM通常不支持HEIC/AVIF，先转码为JPEG
+		if format := s.detectImageFormat(data); format == "heic" || format == "avif" {
+			if !isFormatAllowed(security.AllowedFormats, format) {
+				return "", fmt.Errorf("不允许的图片格式: %s", format)
+			}
+			start := time.Now()
+			transcoded, err := transcodeToJPEG(data, format, security.TranscodeQuality)
+			if err != nil {
+				return "", fmt.Errorf("图片转码失败: %v", err)
+			}
+			s.logger.Info(fmt.Sprintf("%s图片转码为JPEG 耗时: %v, 大小: %d -> %d", format, time.Since(start), len(data), len(transcoded)))
+			data = transcoded
+		}
 
-		imageData.Data = imageBase64
-		imageData.Format = s.detectImageFormat(req.Image)
+		// 缩放、转换格式并压缩后再提交
+		opts := image.PreprocessOptionsFromConfig(security)
+		data, format, err := image.Preprocess(data, opts, s.logger)
+		if err != nil {
+			return "", fmt.Errorf("图片预处理失败: %v", err)
+		}
+
+		imageData.Data = base64.StdEncoding.EncodeToString(data)
+		imageData.Format = format
 	}
 
 	// 调用VLLLM provider
@@ -452,7 +498,9 @@ func (s *DefaultVisionService) isValidImageFile(data []byte) bool {
 		s.hasPNGHeader(data) ||
 		s.hasGIFHeader(data) ||
 		s.hasBMPHeader(data) ||
-		s.hasWebPHeader(data)
+		s.hasWebPHeader(data) ||
+		s.hasHEICHeader(data) ||
+		s.hasAVIFHeader(data)
 }
 
 // hasJPEGHeader 检查JPEG文件头
@@ -486,6 +534,54 @@ func (s *DefaultVisionService) hasWebPHeader(data []byte) bool {
 		data[8] == 0x57 && data[9] == 0x45 && data[10] == 0x42 && data[11] == 0x50
 }
 
+// hasHEICHeader 检查HEIC文件头（ftyp盒子中包含heic/heix/heis品牌）
+func (s *DefaultVisionService) hasHEICHeader(data []byte) bool {
+	return hasFtypBrand(data, "heic", "heix", "heis")
+}
+
+// hasAVIFHeader 检查AVIF文件头（ftyp盒子中包含avif/avis品牌）
+func (s *DefaultVisionService) hasAVIFHeader(data []byte) bool {
+	return hasFtypBrand(data, "avif", "avis")
+}
+
+// hasFtypBrand 检查ISO BMFF文件偏移4处的ftyp盒子，主品牌或兼容品牌之一匹配即可
+func hasFtypBrand(data []byte, brands ...string) bool {
+	if len(data) < 12 || string(data[4:8]) != "ftyp" {
+		return false
+	}
+	end := int(binary.BigEndian.Uint32(data[0:4]))
+	if end > len(data) {
+		end = len(data)
+	}
+	// 主品牌位于偏移8，偏移12为次版本号，之后为兼容品牌列表
+	offsets := []int{8}
+	for offset := 16; offset+4 <= end; offset += 4 {
+		offsets = append(offsets, offset)
+	}
+	for _, offset := range offsets {
+		brand := string(data[offset : offset+4])
+		for _, b := range brands {
+			if brand == b {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isFormatAllowed 检查格式是否在允许列表中，未配置列表时不限制
+func isFormatAllowed(allowed []string, format string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, f := range allowed {
+		if strings.EqualFold(f, format) {
+			return true
+		}
+	}
+	return false
+}
+
 // detectImageFormat 检测图片格式
 func (s *DefaultVisionService) detectImageFormat(data []byte) string {
 	if s.hasJPEGHeader(data) {
@@ -503,6 +599,12 @@ func (s *DefaultVisionService) detectImageFormat(data []byte) string {
 	if s.hasWebPHeader(data) {
 		return "webp"
 	}
+	if s.hasAVIFHeader(data) {
+		return "avif"
+	}
+	if s.hasHEICHeader(data) {
+		return "heic"
+	}
 	return "jpeg" // 默认格式
 }
 
@@ -0,0 +1,151 @@
+package vision
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"angrymiao-ai-server/src/configs"
+	"angrymiao-ai-server/src/core/providers/vlllm"
+	"angrymiao-ai-server/src/core/utils"
+)
+
+// ftypHeader 构造ISO BMFF文件开头的ftyp盒子
+func ftypHeader(major string, compatible ...string) []byte {
+	size := 16 + 4*len(compatible)
+	data := make([]byte, size, size+16)
+	binary.BigEndian.PutUint32(data[0:4], uint32(size))
+	copy(data[4:8], "ftyp")
+	copy(data[8:12], major)
+	for i, brand := range compatible {
+		copy(data[16+4*i:], brand)
+	}
+	return append(data, make([]byte, 16)...)
+}
+
+func newTestVisionService(t *testing.T) *DefaultVisionService {
+	t.Helper()
+	logger, err := utils.NewLogger(&utils.LogCfg{LogLevel: "ERROR", LogDir: t.TempDir(), LogFile: "test.log"})
+	if err != nil {
+		t.Fatalf("创建日志失败: %v", err)
+	}
+	return &DefaultVisionService{logger: logger, config: &configs.Config{}, vlllmMap: make(map[string]*vlllm.Provider)}
+}
+
+func TestHEICAndAVIFHeaderDetection(t *testing.T) {
+	s := newTestVisionService(t)
+	cases := []struct {
+		name   string
+		data   []byte
+		format string
+	}{
+		{"heic", ftypHeader("heic", "mif1", "heic"), "heic"},
+		{"heis", ftypHeader("heis", "mif1"), "heic"},
+		{"mif1主品牌的heic", ftypHeader("mif1", "miaf", "heic"), "heic"},
+		{"avif", ftypHeader("avif", "mif1", "miaf"), "avif"},
+		{"avis", ftypHeader("avis", "msf1"), "avif"},
+	}
+	for _, c := range cases {
+		if !s.isValidImageFile(c.data) {
+			t.Errorf("%s 应识别为有效图片", c.name)
+		}
+		if format := s.detectImageFormat(c.data); format != c.format {
+			t.Errorf("%s 识别格式 = %s, 期望 %s", c.name, format, c.format)
+		}
+	}
+
+	// MP4视频同样使用ftyp盒子，不应被识别为图片
+	if s.isValidImageFile(ftypHeader("isom", "iso2", "mp41")) {
+		t.Error("MP4文件不应识别为图片")
+	}
+	// ftyp不在偏移4处
+	if s.isValidImageFile(append([]byte{0, 0}, ftypHeader("heic")...)) {
+		t.Error("ftyp盒子不在偏移4处时不应识别为HEIC")
+	}
+}
+
+func TestProcessVisionRequestTranscodesHEICBeforeVLLLM(t *testing.T) {
+	var jpegData bytes.Buffer
+	img := image.NewRGBA(image.Rect(0, 0, 64, 48))
+	for i := range img.Pix {
+		img.Pix[i] = 0x80
+	}
+	img.Set(0, 0, color.White)
+	if err := jpeg.Encode(&jpegData, img, nil); err != nil {
+		t.Fatalf("编码测试图片失败: %v", err)
+	}
+
+	var transcodedFormat string
+	var transcodeQuality int
+	original := transcodeToJPEG
+	transcodeToJPEG = func(data []byte, format string, quality int) ([]byte, error) {
+		transcodedFormat, transcodeQuality = format, quality
+		return jpegData.Bytes(), nil
+	}
+	t.Cleanup(func() { transcodeToJPEG = original })
+
+	// 模拟Ollama接口，记录提交的图片
+	var submitted []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req vlllm.OllamaRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Messages) == 0 || len(req.Messages[0].Images) == 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		submitted, _ = base64.StdEncoding.DecodeString(req.Messages[len(req.Messages)-1].Images[0])
+		var resp vlllm.OllamaResponse
+		resp.Message.Content = "一只猫"
+		resp.Done = true
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	s := newTestVisionService(t)
+	provider, err := vlllm.NewProvider(&vlllm.Config{
+		Type:      "ollama",
+		ModelName: "test",
+		BaseURL:   server.URL,
+		Security: configs.SecurityConfig{
+			MaxFileSize:      10 * 1024 * 1024,
+			MaxPixels:        16 * 1024 * 1024,
+			MaxWidth:         4096,
+			MaxHeight:        4096,
+			AllowedFormats:   []string{"jpeg", "jpg", "png", "heic"},
+			TranscodeQuality: 90,
+		},
+	}, s.logger)
+	if err != nil {
+		t.Fatalf("创建VLLLM provider失败: %v", err)
+	}
+	if err := provider.Initialize(); err != nil {
+		t.Fatalf("初始化VLLLM provider失败: %v", err)
+	}
+	s.vlllmMap["test"] = provider
+
+	result, err := s.processVisionRequest(&VisionRequest{Question: "这是什么", FileType: "file", Image: ftypHeader("heic", "mif1", "heic")})
+	if err != nil {
+		t.Fatalf("处理HEIC图片失败: %v", err)
+	}
+	if result != "一只猫" {
+		t.Errorf("分析结果 = %q", result)
+	}
+	if transcodedFormat != "heic" || transcodeQuality != 90 {
+		t.Errorf("转码参数 = %s/%d, 期望 heic/90", transcodedFormat, transcodeQuality)
+	}
+	if _, format, err := image.DecodeConfig(bytes.NewReader(submitted)); err != nil || format != "jpeg" {
+		t.Errorf("提交给VLLLM的图片格式 = %s, %v, 期望 jpeg", format, err)
+	}
+
+	// 未允许的格式不转码
+	transcodedFormat = ""
+	if _, err := s.processVisionRequest(&VisionRequest{Question: "这是什么", FileType: "file", Image: ftypHeader("avif", "mif1")}); err == nil || transcodedFormat != "" {
+		t.Errorf("AVIF不在允许列表中时应拒绝, err = %v", err)
+	}
+}
@@ -0,0 +1,72 @@
+package vision
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"angrymiao-ai-server/src/configs/database"
+	"angrymiao-ai-server/src/core/media"
+	"angrymiao-ai-server/src/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// stubSigner 模拟OSS预签名
+type stubSigner struct {
+	ttl time.Duration
+}
+
+func (s *stubSigner) PresignGet(key string, ttl time.Duration) (string, error) {
+	s.ttl = ttl
+	return "https://media.oss/" + key + "?Signature=x", nil
+}
+
+func TestUploadCompleteReturnsPresignedURL(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "media.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("打开测试数据库失败: %v", err)
+	}
+	if err := db.AutoMigrate(&models.MediaUpload{}); err != nil {
+		t.Fatalf("迁移失败: %v", err)
+	}
+	original := database.DB
+	database.DB = db
+	t.Cleanup(func() { database.DB = original })
+
+	s := newTestVisionService(t)
+	signer := &stubSigner{}
+	s.presign = media.NewPresignService(signer, nil, "")
+
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.POST("/upload/complete", s.handleUploadComplete)
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/upload/complete", bytes.NewBufferString(`{"file_type":"image","path":"u1/image/a.jpg"}`))
+	req.Header.Set("Content-Type", "application/json")
+	engine.ServeHTTP(w, req)
+
+	var body struct {
+		Data struct {
+			Result struct {
+				ID           uint   `json:"id"`
+				PresignedURL string `json:"presigned_url"`
+			} `json:"result"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("响应 = %d %s", w.Code, w.Body.String())
+	}
+	if body.Data.Result.ID == 0 || body.Data.Result.PresignedURL != "https://media.oss/u1/image/a.jpg?Signature=x" {
+		t.Errorf("上传完成结果 = %+v", body.Data.Result)
+	}
+	if signer.ttl != time.Hour {
+		t.Errorf("默认有效期 = %v, 期望 1h", signer.ttl)
+	}
+}
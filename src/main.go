@@ -6,12 +6,15 @@ package main
 
 import (
 	// 标准库
+	"compress/gzip"
 	"context"
+	"flag"
 	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
 	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
@@ -24,23 +27,32 @@ import (
 
 	// 项目内部包 - 配置相关
 	"angrymiao-ai-server/src/configs"
+	"angrymiao-ai-server/src/configs/cache"
 	"angrymiao-ai-server/src/configs/database"
 	"angrymiao-ai-server/src/httpsvr/bot"
 
 	// 项目内部包 - 核心功能
+	"angrymiao-ai-server/src/core/audit"
 	"angrymiao-ai-server/src/core/auth"
 	"angrymiao-ai-server/src/core/auth/am_token"
 	"angrymiao-ai-server/src/core/auth/store"
 	"angrymiao-ai-server/src/core/botconfig"
+	"angrymiao-ai-server/src/core/botstats"
+	"angrymiao-ai-server/src/core/governor"
 	"angrymiao-ai-server/src/core/middleware"
 	"angrymiao-ai-server/src/core/pool"
+	"angrymiao-ai-server/src/core/providers"
+	"angrymiao-ai-server/src/core/ratelimit"
+	"angrymiao-ai-server/src/core/tokenusage"
 	"angrymiao-ai-server/src/core/transport"
+	"angrymiao-ai-server/src/core/transport/grpc"
 	"angrymiao-ai-server/src/core/transport/grpcgateway"
 	"angrymiao-ai-server/src/core/transport/mqtt"
 	"angrymiao-ai-server/src/core/transport/websocket"
 	"angrymiao-ai-server/src/core/utils"
 
 	// 项目内部包 - 业务模块
+	"angrymiao-ai-server/src/httpsvr/admin"
 	appApi "angrymiao-ai-server/src/httpsvr/app"
 	"angrymiao-ai-server/src/httpsvr/device"
 	"angrymiao-ai-server/src/httpsvr/ota"
@@ -70,6 +82,8 @@ import (
 // Application 应用程序主结构体，管理整个应用的生命周期
 type Application struct {
 	config        *configs.Config
+	configPath    string
+	configWatcher *configs.ConfigWatcher
 	logger        *utils.Logger
 	db            *gorm.DB
 	authManager   *auth.AuthManager
@@ -82,6 +96,9 @@ type Application struct {
 // ServerManager 服务管理器，负责管理所有服务的启动和关闭
 type ServerManager struct {
 	transportManager *transport.TransportManager
+	handlerFactory   *transport.DefaultConnectionHandlerFactory
+	appService       *appApi.AppService
+	taskMgr          *task.TaskManager
 	httpServer       *http.Server
 	logger           *utils.Logger
 }
@@ -120,6 +137,22 @@ func (app *Application) Initialize() error {
 		return fmt.Errorf("初始化数据库失败: %w", err)
 	}
 
+	// 初始化Redis客户端与Bot配置变更通知
+	app.initializeRedis()
+
+	// 初始化限流器，需在Redis之后
+	app.initializeRateLimiter()
+
+	// 初始化LLM token用量统计，需在数据库与Redis之后
+	app.initializeTokenUsage()
+
+	// 初始化工具调用审计日志与Bot调用统计，需在数据库之后
+	app.initializeAuditLog()
+	app.initializeBotStats()
+
+	// 清理失效的快速回复缓存音频
+	app.validateQuickReplyCache()
+
 	// 初始化认证管理器
 	if err = app.initializeAuthManager(); err != nil {
 		return fmt.Errorf("初始化认证管理器失败: %w", err)
@@ -142,6 +175,7 @@ func (app *Application) initializeConfigAndLogger() error {
 		return fmt.Errorf("加载配置失败: %w", err)
 	}
 	app.config = config
+	app.configPath = configPath
 
 	// 初始化日志系统
 	logger, err := utils.NewLogger((*utils.LogCfg)(&config.Log))
@@ -150,6 +184,9 @@ func (app *Application) initializeConfigAndLogger() error {
 	}
 	app.logger = logger
 	utils.DefaultLogger = logger
+	utils.SetPunctuationRestoreURL(config.PunctuationRestore.URL)
+	governor.SetDefault(governor.New(config.Server.MaxConnections))
+	transport.SetDefaultRateLimiter(transport.NewRateLimiter(config.Server.RateLimit.TextRPS, config.Server.RateLimit.AudioFPS))
 
 	app.logger.Info("配置和日志系统初始化成功, 配置文件路径: %s", configPath)
 
@@ -190,6 +227,77 @@ func (app *Application) initializeDatabase() error {
 	return nil
 }
 
+// initializeRedis 初始化全局共享的Redis客户端，并基于其创建Bot配置变更通知
+// 未配置Redis时对话记忆、波形缓存不可用，在线连接不会热更新Bot配置
+func (app *Application) initializeRedis() {
+	client := cache.InitRedis(app.config.RedisCache)
+	if client == nil {
+		app.logger.Info("未配置redis_cache，Bot好友变更不会通知在线连接")
+		return
+	}
+	botconfig.SetUpdateNotifier(botconfig.NewRedisUpdateNotifier(client))
+	app.logger.Info("Redis客户端与Bot配置变更通知初始化成功")
+}
+
+// initializeRateLimiter 根据 rate_limit 配置创建全局限流器，创建失败时不限流
+func (app *Application) initializeRateLimiter() {
+	limiter, err := ratelimit.New(app.config.RateLimit, cache.GetRedis())
+	if err != nil {
+		app.logger.Error("初始化限流器失败，不启用限流: %v", err)
+		return
+	}
+	if limiter == nil {
+		return
+	}
+	ratelimit.SetDefault(limiter)
+	app.logger.Info("限流器初始化成功，后端: %s, 每%d秒最多%d次请求", app.config.RateLimit.Backend, app.config.RateLimit.WindowSeconds, app.config.RateLimit.Limit)
+}
+
+// initializeTokenUsage 创建全局token用量统计，未配置Redis时不统计也不限制每日预算
+func (app *Application) initializeTokenUsage() {
+	client := cache.GetRedis()
+	if client == nil {
+		if app.config.DailyTokenBudget > 0 {
+			app.logger.Warn("配置了daily_token_budget，但未配置redis_cache，不限制每日token用量")
+		}
+		return
+	}
+	tokenusage.SetDefault(tokenusage.NewTracker(client, app.db, app.config.DailyTokenBudget))
+	app.logger.Info("LLM token用量统计初始化成功，每日预算: %d", app.config.DailyTokenBudget)
+}
+
+// initializeAuditLog 开启 audit_function_calls 时创建全局审计日志写入器
+func (app *Application) initializeAuditLog() {
+	if !app.config.AuditFunctionCalls {
+		return
+	}
+	if app.db == nil {
+		app.logger.Warn("数据库未初始化，不记录工具调用审计日志")
+		return
+	}
+	audit.SetDefault(audit.NewLogger(app.db, app.logger))
+	app.logger.Info("工具调用审计日志已开启")
+}
+
+// initializeBotStats 创建全局Bot调用记录写入器
+func (app *Application) initializeBotStats() {
+	if app.db == nil {
+		app.logger.Warn("数据库未初始化，不记录Bot调用统计")
+		return
+	}
+	botstats.SetDefault(botstats.NewRecorder(app.db, app.logger))
+}
+
+// validateQuickReplyCache 检查快速回复缓存目录，删除无法读取或为空的缓存音频
+func (app *Application) validateQuickReplyCache() {
+	valid, invalid, err := utils.NewQuickReplyCache("", "").ValidateCache()
+	if err != nil {
+		app.logger.Warn("检查快速回复缓存失败: %v", err)
+		return
+	}
+	app.logger.Info("快速回复缓存检查完成，有效 %d 个，已删除失效 %d 个", valid, invalid)
+}
+
 // initializeAuthManager 初始化认证管理器
 func (app *Application) initializeAuthManager() error {
 	if !app.config.Server.Auth.Enabled {
@@ -239,10 +347,121 @@ func (app *Application) Start() error {
 		return fmt.Errorf("启动HTTP服务失败: %w", err)
 	}
 
+	// 启动配置文件监听，支持热更新
+	app.startConfigWatcher()
+
+	// 启动孤立TTS音频文件清理
+	app.startAudioCleanup()
+
 	app.logger.Info("所有服务启动成功")
 	return nil
 }
 
+// startAudioCleanup 为每个TTS输出目录启动孤立音频文件清理
+func (app *Application) startAudioCleanup() {
+	dirs := make(map[string]struct{})
+	for _, ttsCfg := range app.config.TTS {
+		if ttsCfg.OutputDir == "" {
+			continue
+		}
+		if _, ok := dirs[ttsCfg.OutputDir]; ok {
+			continue
+		}
+		dirs[ttsCfg.OutputDir] = struct{}{}
+		utils.StartAudioCleanup(ttsCfg.OutputDir, app.config.AudioCleanupMaxAgeHours, app.logger)
+	}
+}
+
+// startConfigWatcher 启动配置文件监听
+func (app *Application) startConfigWatcher() {
+	app.configWatcher = configs.StartConfigWatcher(app.configPath, app.reloadConfig)
+	app.configWatcher.OnInvalid(func(errs []error) {
+		app.logger.Error("新配置校验失败，忽略本次重载: %v", errs)
+	})
+	app.logger.Info("配置文件监听已启动: %s", app.configPath)
+}
+
+// reloadConfig 应用新的配置，已建立的连接不受影响
+// 新配置作为新的快照发布，不修改旧快照，已建立的连接继续持有旧配置
+func (app *Application) reloadConfig(newConfig *configs.Config) {
+	changedKeys := configs.DiffConfig(configs.Current(), newConfig)
+	if len(changedKeys) == 0 {
+		app.logger.Info("配置文件已变化，但配置内容无差异")
+		return
+	}
+	app.logger.Info("配置热更新，变更项: %v", changedKeys)
+
+	// 提供者配置变化时先重建资源池，失败则放弃本次重载
+	factory := app.serverManager.handlerFactory
+	var poolManager *pool.PoolManager
+	if factory != nil && configs.ProviderConfigChanged(changedKeys) {
+		var err error
+		poolManager, err = pool.NewPoolManager(newConfig, app.logger)
+		if err != nil {
+			app.logger.Error("重建资源池失败，继续使用原配置与资源池: %v", err)
+			return
+		}
+	}
+
+	configs.SetCurrent(newConfig)
+	utils.SetPunctuationRestoreURL(newConfig.PunctuationRestore.URL)
+	if factory == nil {
+		return
+	}
+	if poolManager == nil {
+		factory.Reload(newConfig, nil)
+		return
+	}
+	app.swapPoolManager(newConfig, poolManager)
+	if oldTenants := factory.SetTenantPools(newTenantPools(newConfig, app.logger)); oldTenants != nil {
+		oldTenants.Close()
+	}
+	app.logger.Info("资源池已按新配置重建")
+}
+
+// reloadProviders 按当前生效的配置新建资源池，初始化完成后原子替换连接与App服务使用的资源池
+func (app *Application) reloadProviders() (*pool.PoolManager, error) {
+	config := configs.Current()
+	if config == nil {
+		config = app.config
+	}
+	poolManager, err := pool.NewPoolManager(config, app.logger)
+	if err != nil {
+		return nil, err
+	}
+	app.swapPoolManager(config, poolManager)
+	return poolManager, nil
+}
+
+// swapPoolManager 替换新连接与App服务使用的资源池并关闭旧资源池
+// 已建立的连接在本轮对话结束后换用新资源池，旧资源池关闭后归还的资源会被直接销毁
+func (app *Application) swapPoolManager(config *configs.Config, poolManager *pool.PoolManager) {
+	olds := make(map[*pool.PoolManager]struct{})
+	if factory := app.serverManager.handlerFactory; factory != nil {
+		if old := factory.Reload(config, poolManager); old != nil {
+			olds[old] = struct{}{}
+		}
+	}
+	if app.serverManager.appService != nil {
+		if old := app.serverManager.appService.SwapPoolManager(poolManager); old != nil {
+			olds[old] = struct{}{}
+		}
+	}
+	for old := range olds {
+		if old != poolManager {
+			old.Close()
+		}
+	}
+}
+
+// newTenantPools 配置了 tenant_ids 时创建按租户隔离的资源池，否则返回nil
+func newTenantPools(config *configs.Config, logger *utils.Logger) *pool.TenantPools {
+	if len(config.Transport.Mqtt.TenantIDs) == 0 {
+		return nil
+	}
+	return pool.NewTenantPools(config, logger)
+}
+
 // startTransportServer 启动传输层服务
 func (app *Application) startTransportServer() error {
 	// 初始化资源池管理器
@@ -256,7 +475,14 @@ func (app *Application) startTransportServer() error {
 		MaxWorkers:        12,
 		MaxTasksPerClient: 20,
 	})
+	if client := cache.GetRedis(); client != nil {
+		retryDelay := time.Duration(app.config.DLQRetryDelaySeconds) * time.Second
+		taskMgr.SetDeadLetterQueue(task.NewDeadLetterQueue(client, app.config.DLQMaxRetries, retryDelay))
+	} else {
+		app.logger.Warn("未配置redis_cache，执行失败的任务不写入死信队列")
+	}
 	taskMgr.Start()
+	app.serverManager.taskMgr = taskMgr
 
 	// 创建Bot配置服务（从好友表获取配置）
 	userConfigService := botconfig.NewService(app.db, app.logger)
@@ -273,6 +499,8 @@ func (app *Application) startTransportServer() error {
 		app.logger,
 		userConfigService,
 	)
+	handlerFactory.SetTenantPools(newTenantPools(app.config, app.logger))
+	app.serverManager.handlerFactory = handlerFactory
 
 	// 根据配置注册多个传输层
 	if app.config.Transport.WebSocket.Enabled {
@@ -287,6 +515,12 @@ func (app *Application) startTransportServer() error {
 		transportManager.RegisterTransport("grpcgateway", gatewayTransport)
 		app.logger.Info("GrpcGateway 传输层已注册")
 	}
+	if app.config.Transport.Grpc.Enabled {
+		grpcTransport := grpc.NewGrpcTransport(app.config, app.logger)
+		grpcTransport.SetConnectionHandler(handlerFactory)
+		transportManager.RegisterTransport("grpc", grpcTransport)
+		app.logger.Info("gRPC 传输层已注册")
+	}
 	if app.config.Transport.Mqtt.Enabled {
 		mqttTransport := mqtt.NewMQTTTransport(app.config, app.logger)
 		mqttTransport.SetConnectionHandler(handlerFactory)
@@ -333,7 +567,8 @@ func (app *Application) startHttpServer() error {
 	router := gin.Default()
 	router.SetTrustedProxies([]string{"0.0.0.0"})
 
-	// 全局应用 CORS 中间件
+	// 全局分配请求关联ID，并应用 CORS 中间件
+	router.Use(middleware.RequestID())
 	router.Use(middleware.CORS())
 
 	// 注册路由
@@ -385,15 +620,32 @@ func (app *Application) registerRoutes(router *gin.Engine) error {
 	apiGroup := router.Group("/api")
 	// 统一跨域中间件
 	apiGroup.Use(middleware.CORS())
+	// /api/v2 接口响应压缩
+	if app.config.APICompressionEnabled {
+		compression := middleware.CompressionWithMinSize(gzip.DefaultCompression, app.config.APICompressionMinSizeBytes)
+		apiGroup.Use(func(c *gin.Context) {
+			if strings.HasPrefix(c.Request.URL.Path, "/api/v2/") {
+				compression(c)
+				return
+			}
+			c.Next()
+		})
+	}
 
 	// 启动用户好友管理服务
 	friendHandler := appApi.NewUserFriendHandler(app.db, app.logger)
 	friendHandler.RegisterRoutes(apiGroup)
 
+	// 启动设备组管理服务
+	deviceGroupHandler := appApi.NewDeviceGroupHandler(app.db, app.logger)
+	deviceGroupHandler.RegisterRoutes(apiGroup)
+
 	// 启动Bot配置管理服务（需要 friendService 来检查 Bot 是否已添加）
 	friendService := appApi.NewUserFriendService(app.db, app.logger)
 	botHandler := bot.NewBotConfigHandler(app.db, app.logger, friendService)
+	botHandler.SetVisibilityWebhook(app.config.BotVisibilityWebhook)
 	botHandler.RegisterRoutes(apiGroup)
+	botHandler.RegisterAdminRoutes(apiGroup, app.config.Server.AdminToken)
 
 	// 启动模型配置管理服务
 	modelHandler := bot.NewModelConfigHandler(app.db, app.logger)
@@ -410,6 +662,18 @@ func (app *Application) registerRoutes(router *gin.Engine) error {
 	// 启动App服务
 	appService := appApi.NewDefaultAppService(app.config, app.logger)
 	appService.Start(app.ctx, router, apiGroup)
+	app.serverManager.appService = appService
+
+	// 启动管理服务
+	adminService := admin.NewDefaultAdminService(app.config, app.logger)
+	if app.serverManager.handlerFactory != nil {
+		adminService.SetPoolStatsProvider(app.serverManager.handlerFactory.ProviderPoolStats)
+		adminService.SetProviderReloader(app.reloadProviders)
+	}
+	if app.serverManager.taskMgr != nil {
+		adminService.SetDeadLetterQueue(app.serverManager.taskMgr.DeadLetterQueue())
+	}
+	adminService.Start(app.ctx, router, apiGroup)
 
 	// 启动Vision服务
 	visionService, err := vision.NewDefaultVisionService(app.config, app.logger)
@@ -430,11 +694,18 @@ func (app *Application) registerRoutes(router *gin.Engine) error {
 func (app *Application) WaitForShutdown() {
 	// 监听系统信号
 	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
 	defer signal.Stop(sigChan)
 
-	// 等待信号
+	// 等待信号，SIGHUP 触发配置重载
 	sig := <-sigChan
+	for sig == syscall.SIGHUP {
+		app.logger.Info("接收到SIGHUP信号，重新加载配置")
+		if app.configWatcher != nil {
+			app.configWatcher.Trigger()
+		}
+		sig = <-sigChan
+	}
 	app.logger.Info("接收到系统信号: %v，开始优雅关闭服务", sig)
 
 	// 开始关闭流程
@@ -464,11 +735,31 @@ func (app *Application) Shutdown() {
 		os.Exit(1)
 	}
 
+	if app.configWatcher != nil {
+		app.configWatcher.Stop()
+	}
+
+	// 写入剩余的审计日志与Bot调用记录
+	if auditLogger := audit.Get(); auditLogger != nil {
+		auditLogger.Close()
+	}
+	if recorder := botstats.Get(); recorder != nil {
+		recorder.Close()
+	}
+
 	// 关闭认证管理器
 	if app.authManager != nil {
 		app.authManager.Close()
 	}
 
+	// 先关闭Bot配置变更订阅，再关闭共享的Redis客户端
+	if notifier, ok := botconfig.GetUpdateNotifier().(*botconfig.RedisUpdateNotifier); ok {
+		notifier.Close()
+	}
+	if err := cache.CloseRedis(); err != nil {
+		app.logger.Warn("关闭Redis客户端失败: %v", err)
+	}
+
 	// 关闭日志系统
 	if app.logger != nil {
 		app.logger.Info("程序已成功退出")
@@ -478,6 +769,11 @@ func (app *Application) Shutdown() {
 
 // main 程序入口点
 func main() {
+	// --replay-dir 指定录制目录时，LLM/TTS 从录制文件回放响应（录制由 DAYN_RECORD_DIR 环境变量开启）
+	replayDir := flag.String("replay-dir", "", "从录制目录回放LLM/TTS响应，不调用真实提供者")
+	flag.Parse()
+	providers.SetReplayDir(*replayDir)
+
 	// 创建应用程序实例
 	app := NewApplication()
 
@@ -486,6 +782,9 @@ func main() {
 		fmt.Printf("应用程序初始化失败: %v\n", err)
 		os.Exit(1)
 	}
+	if *replayDir != "" {
+		app.logger.Warn("回放模式已开启，LLM/TTS 响应来自录制目录: %s", *replayDir)
+	}
 
 	// 启动应用程序
 	if err := app.Start(); err != nil {
@@ -8,12 +8,20 @@ import (
 	"gorm.io/gorm"
 )
 
+// Bot可见性，创建者申请公开后需管理员审核通过才会公开
+const (
+	BotVisibilityPrivate       = "private"
+	BotVisibilityPendingReview = "pending_review"
+	BotVisibilityPublic        = "public"
+)
+
 // BotConfig Bot配置表
 type BotConfig struct {
-	ID         uint   `gorm:"primaryKey" json:"id"`
-	CreatorID  uint   `gorm:"not null;index" json:"creator_id"`
-	BotHash    string `gorm:"uniqueIndex;not null" json:"bot_hash"`
-	Visibility string `gorm:"type:varchar(20);default:'private';index" json:"visibility"` // private/public
+	ID           uint   `gorm:"primaryKey" json:"id"`
+	CreatorID    uint   `gorm:"not null;index" json:"creator_id"`
+	BotHash      string `gorm:"uniqueIndex;not null" json:"bot_hash"`
+	Visibility   string `gorm:"type:varchar(20);default:'private';index" json:"visibility"` // private/pending_review/public
+	ReviewReason string `gorm:"type:varchar(500)" json:"review_reason,omitempty"`           // 公开申请被拒绝的原因
 
 	// Model关联
 	ModelID uint `gorm:"not null;index" json:"model_id"`
@@ -32,6 +40,15 @@ type BotConfig struct {
 	Parameters   datatypes.JSON `json:"parameters,omitempty"`
 	MCPServerURL string         `json:"mcp_server_url,omitempty"`
 
+	// 结构化输出配置，非空时要求LLM按该JSON Schema输出
+	ResponseSchema datatypes.JSON `json:"response_schema,omitempty"`
+
+	// 回复前缀，非空时覆盖全局 response_prefix
+	ResponsePrefix string `gorm:"type:varchar(50)" json:"response_prefix,omitempty"`
+
+	// 欢迎语，非空时覆盖全局 conversation_starter
+	WelcomeMessage string `gorm:"type:varchar(200)" json:"welcome_message,omitempty"`
+
 	// 元数据
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
@@ -43,12 +60,30 @@ func (BotConfig) TableName() string {
 	return "bot_configs"
 }
 
+// BotUsageStat 对话中调用用户Bot的记录，用于统计Bot的调用次数、成功率与耗时
+type BotUsageStat struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	BotConfigID uint      `gorm:"not null;index:idx_bot_usage_bot_invoked,priority:1" json:"bot_config_id"`
+	UserID      uint      `gorm:"index" json:"user_id"` // 调用者
+	SessionID   string    `gorm:"type:varchar(64)" json:"session_id"`
+	InvokedAt   time.Time `gorm:"not null;index:idx_bot_usage_bot_invoked,priority:2" json:"invoked_at"`
+	LatencyMs   int64     `json:"latency_ms"`
+	TokensUsed  int64     `json:"tokens_used"` // 调用参数与回复按每4个字符1个token估算
+	Success     bool      `json:"success"`
+}
+
+// TableName 指定BotUsageStat表名
+func (BotUsageStat) TableName() string {
+	return "bot_usage_stats"
+}
+
 // BotConfigResponse Bot配置响应结构
 type BotConfigResponse struct {
 	ID              uint                   `json:"id"`
 	CreatorID       uint                   `json:"creator_id"`
 	BotHash         string                 `json:"bot_hash"`
 	Visibility      string                 `json:"visibility"`
+	ReviewReason    string                 `json:"review_reason,omitempty"`
 	ModelID         uint                   `json:"model_id"`
 	BotType         string                 `json:"bot_type"`
 	RequiresNetwork bool                   `json:"requires_network"`
@@ -58,6 +93,9 @@ type BotConfigResponse struct {
 	Description     string                 `json:"description,omitempty"`
 	Parameters      map[string]interface{} `json:"parameters,omitempty"`
 	MCPServerURL    string                 `json:"mcp_server_url,omitempty"`
+	ResponseSchema  map[string]interface{} `json:"response_schema,omitempty"`
+	ResponsePrefix  string                 `json:"response_prefix,omitempty"`
+	WelcomeMessage  string                 `json:"welcome_message,omitempty"`
 	IsAdded         bool                   `json:"is_added,omitempty"` // 用户是否已添加
 	CreatedAt       time.Time              `json:"created_at"`
 	UpdatedAt       time.Time              `json:"updated_at"`
@@ -70,6 +108,7 @@ func (c *BotConfig) ToResponse() *BotConfigResponse {
 		CreatorID:       c.CreatorID,
 		BotHash:         c.BotHash,
 		Visibility:      c.Visibility,
+		ReviewReason:    c.ReviewReason,
 		ModelID:         c.ModelID,
 		BotType:         c.BotType,
 		RequiresNetwork: c.RequiresNetwork,
@@ -78,6 +117,8 @@ func (c *BotConfig) ToResponse() *BotConfigResponse {
 		FunctionName:    c.FunctionName,
 		Description:     c.Description,
 		MCPServerURL:    c.MCPServerURL,
+		ResponsePrefix:  c.ResponsePrefix,
+		WelcomeMessage:  c.WelcomeMessage,
 		CreatedAt:       c.CreatedAt,
 		UpdatedAt:       c.UpdatedAt,
 	}
@@ -90,12 +131,20 @@ func (c *BotConfig) ToResponse() *BotConfigResponse {
 		}
 	}
 
+	// 解析ResponseSchema JSON
+	if c.ResponseSchema != nil {
+		var schema map[string]interface{}
+		if err := json.Unmarshal(c.ResponseSchema, &schema); err == nil {
+			resp.ResponseSchema = schema
+		}
+	}
+
 	return resp
 }
 
 // CreateBotConfigRequest 创建Bot配置请求结构
 type CreateBotConfigRequest struct {
-	Visibility      string                 `json:"visibility,omitempty"` // private/public
+	Visibility      string                 `json:"visibility,omitempty"` // private/public，public 需审核
 	ModelID         uint                   `json:"model_id" binding:"required"`
 	BotType         string                 `json:"bot_type,omitempty"`         // llm/image/tts/asr
 	RequiresNetwork bool                   `json:"requires_network,omitempty"` // 是否需要联网
@@ -105,6 +154,17 @@ type CreateBotConfigRequest struct {
 	Description     string                 `json:"description,omitempty"`
 	Parameters      map[string]interface{} `json:"parameters,omitempty"`
 	MCPServerURL    string                 `json:"mcp_server_url,omitempty"`
+	ResponseSchema  map[string]interface{} `json:"response_schema,omitempty"`                   // LLM结构化输出的JSON Schema
+	ResponsePrefix  string                 `json:"response_prefix,omitempty" binding:"max=50"`  // 回复前缀，如 "小爱："
+	WelcomeMessage  string                 `json:"welcome_message,omitempty" binding:"max=200"` // 连接建立后主动播报的欢迎语
+}
+
+// CreateBotFromTemplateRequest 从模板创建Bot配置请求结构，未填写的字段使用模板默认值
+type CreateBotFromTemplateRequest struct {
+	ModelID      uint   `json:"model_id" binding:"required"`
+	Visibility   string `json:"visibility,omitempty"`    // private/public，public 需审核
+	FunctionName string `json:"function_name,omitempty"` // Bot名称，覆盖模板的函数名
+	MaxTokens    int    `json:"max_tokens,omitempty"`
 }
 
 // UpdateBotConfigRequest 更新Bot配置请求结构
@@ -118,4 +178,12 @@ type UpdateBotConfigRequest struct {
 	Description     *string                `json:"description,omitempty"`
 	Parameters      map[string]interface{} `json:"parameters,omitempty"`
 	MCPServerURL    *string                `json:"mcp_server_url,omitempty"`
+	ResponseSchema  map[string]interface{} `json:"response_schema,omitempty"`
+	ResponsePrefix  *string                `json:"response_prefix,omitempty" binding:"omitempty,max=50"`
+	WelcomeMessage  *string                `json:"welcome_message,omitempty" binding:"omitempty,max=200"`
+}
+
+// RejectBotRequest 拒绝Bot公开申请请求结构
+type RejectBotRequest struct {
+	Reason string `json:"reason" binding:"required"`
 }
@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+// DeviceGroup 设备组，组内设备共享系统提示词与主人格Bot
+type DeviceGroup struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	Name         string    `gorm:"type:varchar(64);not null" json:"name"`
+	UserID       uint      `gorm:"not null;index" json:"user_id"`
+	SystemPrompt string    `gorm:"type:text" json:"system_prompt"`
+	BotConfigID  uint      `gorm:"default:0" json:"bot_config_id"` // 主人格Bot配置ID，0表示不设置
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// TableName 指定DeviceGroup表名
+func (DeviceGroup) TableName() string {
+	return "device_groups"
+}
+
+// DeviceGroupMember 设备组成员，每台设备最多属于一个设备组
+type DeviceGroupMember struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	GroupID   uint      `gorm:"not null;index" json:"group_id"`
+	DeviceID  string    `gorm:"type:varchar(64);not null;uniqueIndex" json:"device_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TableName 指定DeviceGroupMember表名
+func (DeviceGroupMember) TableName() string {
+	return "device_group_members"
+}
+
+// DeviceGroupResponse 设备组响应结构（包含成员设备）
+type DeviceGroupResponse struct {
+	DeviceGroup
+	DeviceIDs []string `json:"device_ids"`
+}
+
+// DeviceGroupRequest 创建或更新设备组请求结构
+type DeviceGroupRequest struct {
+	Name         string `json:"name" binding:"required,max=64"`
+	SystemPrompt string `json:"system_prompt,omitempty"`
+	BotConfigID  uint   `json:"bot_config_id,omitempty"`
+}
+
+// UpdateDeviceGroupDevicesRequest 添加或移除设备组成员请求结构
+type UpdateDeviceGroupDevicesRequest struct {
+	Add    []string `json:"add,omitempty"`
+	Remove []string `json:"remove,omitempty"`
+}
@@ -2,19 +2,25 @@ package models
 
 import (
 	"time"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
 )
 
 // DialogueMessage 按 userID 存储的单条对话消息（去除 ToolCalls 内容）
 type DialogueMessage struct {
-	ID         uint   `gorm:"primaryKey"`
-	UserID     string `gorm:"index;not null"`
-	Index      int    `gorm:"not null"` // 在完整对话中的顺序
-	Role       string `gorm:"size:32;not null"`
-	Content    string `gorm:"type:text;not null"`
-	ToolCallID string `gorm:"size:128"`
-	BotID      uint   `gorm:"not null;default:0" json:"bot_id"`
-	CreatedAt  time.Time
-	UpdatedAt  time.Time
+	ID              uint           `gorm:"primaryKey"`
+	UserID          string         `gorm:"index;not null"`
+	Index           int            `gorm:"not null"` // 在完整对话中的顺序
+	Role            string         `gorm:"size:32;not null"`
+	Content         string         `gorm:"type:text;not null"`
+	ToolCallID      string         `gorm:"size:128"`
+	BotID           uint           `gorm:"not null;default:0" json:"bot_id"`
+	Tags            datatypes.JSON `json:"tags"`                                       // 用户给消息打的标签，JSON字符串数组
+	ImportanceScore float32        `gorm:"not null;default:0" json:"importance_score"` // 所在轮次的重要性(0~1)，0表示未评分
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	DeletedAt       gorm.DeletedAt `gorm:"index" json:"-"` // 用户软删除对话历史的时间
 }
 
 func (DialogueMessage) TableName() string { return "dialogue_messages" }
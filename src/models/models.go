@@ -28,6 +28,8 @@ type User struct {
 	Password string // 建议加密
 	Role     string // 可选值：admin/user
 	Setting  UserSetting
+	// 每日LLM token预算，0表示使用全局 daily_token_budget
+	DailyTokenBudget int64
 }
 
 // 用户设置
@@ -70,6 +72,7 @@ type Device struct {
 	ClientID         string         `gorm:"type:varchar(255);uniqueIndex;not null" json:"clientId"`         // 客户端唯一标识
 	Version          string         `                                              json:"version"`          // 设备固件版本号
 	OTA              bool           `gorm:"default:true"                           json:"ota"`              // 是否支持OTA升级
+	OTAAutoApprove   bool           `gorm:"default:false"                          json:"ota_auto_approve"` // 推送OTA时无需用户确认，设备自动升级
 	RegisterTime     int64          `                                              json:"-"`                // 注册时间戳
 	LastActiveTime   int64          `                                              json:"-"`                // 最后活跃时间戳
 	RegisterTimeV2   time.Time      `                                              json:"registerTimeV2"`   // 注册时间
@@ -111,6 +114,30 @@ type MediaUpload struct {
 	DeletedAt       gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
+// 函数调用审计日志表，记录LLM发起的每次工具调用
+type FunctionCallAuditLog struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	SessionID    string    `gorm:"type:varchar(64);index" json:"session_id"`
+	UserID       uint      `gorm:"index:idx_audit_user_created,priority:1" json:"user_id"`
+	DeviceID     string    `gorm:"type:varchar(255)" json:"device_id"`
+	FunctionName string    `gorm:"type:varchar(128);index" json:"function_name"`
+	Arguments    string    `gorm:"type:text" json:"arguments"`
+	Result       string    `gorm:"type:text" json:"result"`
+	LatencyMs    int64     `json:"latency_ms"`
+	Success      bool      `json:"success"`
+	CreatedAt    time.Time `gorm:"index:idx_audit_user_created,priority:2" json:"created_at"` // 调用时间
+}
+
+// 对话式表单提交记录，Data 为按字段名收集的回答
+type FormSubmission struct {
+	ID        uint           `gorm:"primaryKey" json:"id"`
+	UserID    string         `gorm:"type:varchar(64);index" json:"user_id"`
+	DeviceID  string         `gorm:"type:varchar(255)" json:"device_id"`
+	SessionID string         `gorm:"type:varchar(64)" json:"session_id"`
+	Data      datatypes.JSON `json:"data"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
 // AudioTask 状态常量
 const (
 	AudioTaskStatusProcessing = "processing"
@@ -131,6 +158,7 @@ type AudioTask struct {
 	ResultJSON datatypes.JSON `gorm:"type:json" json:"result_json,omitempty"` // 保存完整的识别结果（包含 utterances、words 等）
 	Summary    string         `json:"summary"`
 	KeyPoints  datatypes.JSON `json:"key_points"`
+	Speakers   datatypes.JSON `json:"speakers,omitempty"` // 按说话人分组的发言片段
 	CreatedAt  time.Time      `json:"created_at"`
 	UpdatedAt  time.Time      `json:"updated_at"`
 }
@@ -19,6 +19,9 @@ type UserFriend struct {
 	BotConfigID *uint  `gorm:"index:idx_user_friend" json:"bot_config_id,omitempty"`
 	AppKey      string `json:"app_key,omitempty"` // 用户的LLM API密钥
 
+	// Bot回复使用的TTS音色，可为用户复刻音色的 voice_id
+	TTSVoice string `gorm:"type:varchar(128)" json:"tts_voice,omitempty"`
+
 	// 关联（不使用数据库外键，在代码中手动加载）
 	BotConfig *BotConfig `gorm:"-" json:"bot_config,omitempty"` // 关联的Bot配置
 
@@ -36,12 +39,28 @@ func (UserFriend) TableName() string {
 	return "user_friends"
 }
 
+// DeviceBotBinding 设备绑定的主人格Bot，每台设备最多绑定一个
+type DeviceBotBinding struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	UserID      uint      `gorm:"not null;index" json:"user_id"`
+	DeviceID    string    `gorm:"type:varchar(64);not null;uniqueIndex" json:"device_id"`
+	BotConfigID uint      `gorm:"not null;index" json:"bot_config_id"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// TableName 指定DeviceBotBinding表名
+func (DeviceBotBinding) TableName() string {
+	return "device_bot_bindings"
+}
+
 // UserBotFriendResponse 用户Bot好友响应结构（包含Bot配置信息）
 type UserBotFriendResponse struct {
 	ID          uint               `json:"id"`
 	UserID      uint               `json:"user_id"`
 	BotConfigID uint               `json:"bot_config_id"`
 	AppKey      string             `json:"app_key,omitempty"` // 可能需要脱敏
+	TTSVoice    string             `json:"tts_voice,omitempty"`
 	Alias       string             `json:"alias,omitempty"`
 	Priority    int                `json:"priority"`
 	IsActive    bool               `json:"is_active"`
@@ -60,7 +79,8 @@ type AddBotFriendRequest struct {
 
 // UpdateBotFriendAppKeyRequest 更新Bot好友AppKey请求结构
 type UpdateBotFriendAppKeyRequest struct {
-	AppKey string `json:"app_key" binding:"required"`
+	AppKey   string `json:"app_key" binding:"required"`
+	TTSVoice string `json:"tts_voice,omitempty"` // 用户复刻音色的 voice_id，为空时保持原音色
 }
 
 // UpdateBotFriendPriorityRequest 更新Bot好友优先级请求结构
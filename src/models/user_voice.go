@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// UserVoice 状态常量
+const (
+	UserVoiceStatusProcessing = "processing"
+	UserVoiceStatusReady      = "ready"
+	UserVoiceStatusFailed     = "failed"
+)
+
+// UserVoice 用户通过声音复刻注册的自定义音色
+type UserVoice struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	UserID       uint      `gorm:"not null;index" json:"user_id"`
+	VoiceName    string    `gorm:"type:varchar(64);not null" json:"voice_name"`
+	VoiceID      string    `gorm:"type:varchar(128);index" json:"voice_id"` // TTS提供者返回的音色ID，可设置为Bot的TTS音色
+	Provider     string    `gorm:"type:varchar(64)" json:"provider"`        // 复刻所用的TTS配置名
+	Language     string    `gorm:"type:varchar(16)" json:"language"`
+	Status       string    `gorm:"type:varchar(16);not null" json:"status"`
+	ErrorMessage string    `gorm:"type:text" json:"error_message,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// TableName 指定UserVoice表名
+func (UserVoice) TableName() string {
+	return "user_voices"
+}
+
+// CloneVoiceRequest 声音复刻请求中的音色信息
+type CloneVoiceRequest struct {
+	VoiceName string `json:"voice_name" binding:"required,max=64"`
+	Language  string `json:"language,omitempty"`
+}
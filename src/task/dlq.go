@@ -0,0 +1,282 @@
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	deadLetterKeyPrefix = "dlq:"
+	// 死信保留时间，超过后从队列中清除
+	deadLetterRetention = 24 * time.Hour
+	deadLetterTimeout   = 3 * time.Second
+	// 未配置时自动重试的延迟
+	defaultDeadLetterRetryDelay = 60 * time.Second
+)
+
+// ErrDeadLetterNotFound 死信队列中没有该任务，或任务已被重试
+var ErrDeadLetterNotFound = errors.New("死信任务不存在")
+
+// deadLetterNow 当前时间，测试中可替换
+var deadLetterNow = time.Now
+
+// DeadLetterEntry 死信队列中的失败任务
+type DeadLetterEntry struct {
+	TaskID     string          `json:"task_id"`
+	Type       TaskType        `json:"type"`
+	ClientID   string          `json:"client_id"`
+	Params     json.RawMessage `json:"params,omitempty"`
+	Error      string          `json:"error"`
+	RetryCount int             `json:"retry_count"` // 已重试次数
+	FailedAt   time.Time       `json:"failed_at"`
+}
+
+// DeadLetterQueue 基于Redis的死信队列
+// 失败任务按会话写入有序集合 dlq:{clientID}，score 为失败时间(UnixNano)；
+// 重试次数未达上限的任务在 retryDelay 后自动重新提交
+type DeadLetterQueue struct {
+	client     *redis.Client
+	maxRetries int
+	retryDelay time.Duration
+	submit     func(clientID string, task *Task) error
+
+	mu    sync.Mutex
+	tasks map[string]*deadLetterCallback // 本进程内失败的任务，重试时沿用原回调
+}
+
+// NewDeadLetterQueue 创建死信队列，需通过 TaskManager.SetDeadLetterQueue 启用
+// retryDelay<=0 时为60秒，maxRetries<=0 时不自动重试
+func NewDeadLetterQueue(client *redis.Client, maxRetries int, retryDelay time.Duration) *DeadLetterQueue {
+	if retryDelay <= 0 {
+		retryDelay = defaultDeadLetterRetryDelay
+	}
+	return &DeadLetterQueue{
+		client:     client,
+		maxRetries: maxRetries,
+		retryDelay: retryDelay,
+		tasks:      make(map[string]*deadLetterCallback),
+	}
+}
+
+func deadLetterKey(clientID string) string {
+	return deadLetterKeyPrefix + clientID
+}
+
+// deadLetterCallback 包装任务回调，任务失败时写入死信队列
+type deadLetterCallback struct {
+	queue    *DeadLetterQueue
+	task     *Task
+	clientID string
+	inner    TaskCallback
+	ctx      context.Context // 首次提交时的上下文，执行中会被替换为带超时的上下文
+	retries  int
+	failedAt time.Time
+	inQueue  bool
+	timer    *time.Timer
+}
+
+func (cb *deadLetterCallback) OnComplete(result interface{}) {
+	if cb.inner != nil {
+		cb.inner.OnComplete(result)
+	}
+}
+
+func (cb *deadLetterCallback) OnError(err error) {
+	if cb.inner != nil {
+		cb.inner.OnError(err)
+	}
+	cb.queue.add(cb, err)
+}
+
+// wrap 为任务挂载死信回调，重新提交的任务沿用已有的包装
+func (q *DeadLetterQueue) wrap(clientID string, task *Task) {
+	if _, ok := task.Callback.(*deadLetterCallback); ok {
+		return
+	}
+	task.Callback = &deadLetterCallback{queue: q, task: task, clientID: clientID, inner: task.Callback, ctx: task.Context}
+}
+
+// add 将失败的任务写入死信队列，同一次执行重复上报的失败只记录一次
+func (q *DeadLetterQueue) add(cb *deadLetterCallback, taskErr error) {
+	now := deadLetterNow()
+	q.mu.Lock()
+	if cb.inQueue {
+		q.mu.Unlock()
+		return
+	}
+	cb.inQueue = true
+	cb.failedAt = now
+	retries := cb.retries
+	q.tasks[cb.task.ID] = cb
+	q.pruneLocked(now)
+	q.mu.Unlock()
+
+	entry := DeadLetterEntry{
+		TaskID:     cb.task.ID,
+		Type:       cb.task.Type,
+		ClientID:   cb.clientID,
+		Error:      taskErr.Error(),
+		RetryCount: retries,
+		FailedAt:   now,
+	}
+	if params, err := json.Marshal(cb.task.Params); err == nil {
+		entry.Params = params
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		fmt.Printf("序列化死信任务失败: %s, %v\n", entry.TaskID, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), deadLetterTimeout)
+	defer cancel()
+	key := deadLetterKey(entry.ClientID)
+	pipe := q.client.TxPipeline()
+	pipe.ZAdd(ctx, key, redis.Z{Score: float64(now.UnixNano()), Member: data})
+	pipe.ZRemRangeByScore(ctx, key, "-inf", "("+strconv.FormatInt(now.Add(-deadLetterRetention).UnixNano(), 10))
+	pipe.Expire(ctx, key, deadLetterRetention)
+	if _, err := pipe.Exec(ctx); err != nil {
+		fmt.Printf("写入死信队列失败: %s, %v\n", entry.TaskID, err)
+		return
+	}
+
+	if retries >= q.maxRetries {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	// 写入期间已被手动重试时不再安排自动重试
+	if cb.inQueue && cb.retries == retries {
+		cb.timer = time.AfterFunc(q.retryDelay, func() {
+			if err := q.Retry(context.Background(), entry.TaskID); err != nil && !errors.Is(err, ErrDeadLetterNotFound) {
+				fmt.Printf("自动重试死信任务失败: %s, %v\n", entry.TaskID, err)
+			}
+		})
+	}
+}
+
+// pruneLocked 清除超过保留时间的本地任务记录
+func (q *DeadLetterQueue) pruneLocked(now time.Time) {
+	for id, cb := range q.tasks {
+		if now.Sub(cb.failedAt) > deadLetterRetention {
+			delete(q.tasks, id)
+		}
+	}
+}
+
+// List 返回所有会话的死信任务，按失败时间排序，同时清除超过24小时的记录
+func (q *DeadLetterQueue) List(ctx context.Context) ([]DeadLetterEntry, error) {
+	entries := []DeadLetterEntry{}
+	err := q.scan(ctx, func(key, member string, entry DeadLetterEntry) bool {
+		entries = append(entries, entry)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].FailedAt.Before(entries[j].FailedAt) })
+	return entries, nil
+}
+
+// scan 遍历所有死信任务，fn 返回false时停止遍历
+func (q *DeadLetterQueue) scan(ctx context.Context, fn func(key, member string, entry DeadLetterEntry) bool) error {
+	expired := "(" + strconv.FormatInt(deadLetterNow().Add(-deadLetterRetention).UnixNano(), 10)
+	iter := q.client.Scan(ctx, 0, deadLetterKeyPrefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		key := iter.Val()
+		if err := q.client.ZRemRangeByScore(ctx, key, "-inf", expired).Err(); err != nil {
+			return err
+		}
+		members, err := q.client.ZRange(ctx, key, 0, -1).Result()
+		if err != nil {
+			return err
+		}
+		for _, member := range members {
+			var entry DeadLetterEntry
+			if err := json.Unmarshal([]byte(member), &entry); err != nil {
+				continue
+			}
+			if !fn(key, member, entry) {
+				return nil
+			}
+		}
+	}
+	return iter.Err()
+}
+
+// Retry 将死信任务移出队列并重新提交
+// 本进程内失败的任务沿用原回调，其余任务按保存的参数重建
+func (q *DeadLetterQueue) Retry(ctx context.Context, taskID string) error {
+	var found *DeadLetterEntry
+	var key, member string
+	err := q.scan(ctx, func(k, m string, entry DeadLetterEntry) bool {
+		if entry.TaskID != taskID {
+			return true
+		}
+		found, key, member = &entry, k, m
+		return false
+	})
+	if err != nil {
+		return err
+	}
+	if found == nil {
+		return ErrDeadLetterNotFound
+	}
+	// 自动重试与手动重试并发时只有一方能移出队列
+	removed, err := q.client.ZRem(ctx, key, member).Result()
+	if err != nil {
+		return err
+	}
+	if removed == 0 {
+		return ErrDeadLetterNotFound
+	}
+
+	q.mu.Lock()
+	cb := q.tasks[taskID]
+	delete(q.tasks, taskID)
+	if cb != nil {
+		if cb.timer != nil {
+			cb.timer.Stop()
+			cb.timer = nil
+		}
+		cb.inQueue = false
+		cb.retries++
+	}
+	q.mu.Unlock()
+
+	var task *Task
+	if cb != nil {
+		task = cb.task
+		task.Context = context.WithoutCancel(cb.ctx)
+	} else {
+		var params interface{}
+		if len(found.Params) > 0 {
+			_ = json.Unmarshal(found.Params, &params)
+		}
+		task, _ = NewTask(context.Background(), found.Type, params)
+		task.ID = found.TaskID
+		cb = &deadLetterCallback{queue: q, task: task, clientID: found.ClientID, ctx: task.Context, retries: found.RetryCount + 1}
+		task.Callback = cb
+	}
+	task.Status = TaskStatusPending
+	task.Error = nil
+	task.Result = nil
+	task.ScheduledTime = nil
+
+	if err := q.submit(found.ClientID, task); err != nil {
+		task.Status = TaskStatusFailed
+		task.Error = err
+		q.add(cb, err)
+		return err
+	}
+	fmt.Printf("死信任务已重新提交: %s, 第%d次重试\n", taskID, cb.retries)
+	return nil
+}
@@ -0,0 +1,147 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func newTestDeadLetterQueue(t *testing.T, maxRetries int, retryDelay time.Duration) (*TaskManager, *DeadLetterQueue, *redis.Client) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	tm := NewTaskManager(ResourceConfig{MaxWorkers: 2, MaxTasksPerClient: 10})
+	dlq := NewDeadLetterQueue(client, maxRetries, retryDelay)
+	tm.SetDeadLetterQueue(dlq)
+	tm.Start()
+	return tm, dlq, client
+}
+
+// waitForEntries 等待死信队列满足条件
+func waitForEntries(t *testing.T, dlq *DeadLetterQueue, ok func([]DeadLetterEntry) bool) []DeadLetterEntry {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		entries, err := dlq.List(context.Background())
+		if err != nil {
+			t.Fatalf("查询死信队列失败: %v", err)
+		}
+		if ok(entries) {
+			return entries
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("等待死信队列超时，当前 %+v", entries)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestFailedTaskAutoRetriesUntilMaxRetries(t *testing.T) {
+	var attempts atomic.Int32
+	RegisterTaskExecutor("dlq_test_always_fail", func(task *Task) error {
+		attempts.Add(1)
+		return errors.New("下游服务不可用")
+	})
+	tm, dlq, client := newTestDeadLetterQueue(t, 2, 10*time.Millisecond)
+
+	task, id := NewTask(context.Background(), "dlq_test_always_fail", map[string]interface{}{"text": "hi"})
+	if err := tm.SubmitTask("session-1", task); err != nil {
+		t.Fatalf("提交任务失败: %v", err)
+	}
+
+	// 首次执行与2次自动重试均失败后留在死信队列
+	entries := waitForEntries(t, dlq, func(entries []DeadLetterEntry) bool {
+		return len(entries) == 1 && entries[0].RetryCount == 2
+	})
+	entry := entries[0]
+	if entry.TaskID != id || entry.Type != "dlq_test_always_fail" || entry.ClientID != "session-1" || entry.Error != "下游服务不可用" {
+		t.Errorf("死信任务 = %+v", entry)
+	}
+	if string(entry.Params) != `{"text":"hi"}` {
+		t.Errorf("死信任务参数 = %s", entry.Params)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if got := attempts.Load(); got != 3 {
+		t.Errorf("执行次数 = %d, 期望 3", got)
+	}
+	if n, _ := client.ZCard(context.Background(), "dlq:session-1").Result(); n != 1 {
+		t.Errorf("dlq:session-1 中有 %d 个任务, 期望 1", n)
+	}
+}
+
+func TestManualRetryResubmitsDeadLetter(t *testing.T) {
+	var attempts atomic.Int32
+	RegisterTaskExecutor("dlq_test_fail_once", func(task *Task) error {
+		if attempts.Add(1) == 1 {
+			return errors.New("超时")
+		}
+		task.Result = "ok"
+		return nil
+	})
+	tm, dlq, _ := newTestDeadLetterQueue(t, 0, time.Hour)
+
+	results := make(chan interface{}, 2)
+	task, id := NewTask(context.Background(), "dlq_test_fail_once", nil)
+	task.Callback = NewCallBack(func(result interface{}) { results <- result })
+	if err := tm.SubmitTask("session-2", task); err != nil {
+		t.Fatalf("提交任务失败: %v", err)
+	}
+	if result, ok := (<-results).(map[string]interface{}); !ok || result["status"] != "failed" {
+		t.Errorf("失败回调结果 = %v", result)
+	}
+	waitForEntries(t, dlq, func(entries []DeadLetterEntry) bool { return len(entries) == 1 })
+
+	// 手动重试沿用原回调，成功后移出死信队列
+	if err := dlq.Retry(context.Background(), id); err != nil {
+		t.Fatalf("重试失败: %v", err)
+	}
+	select {
+	case result := <-results:
+		if result != "ok" {
+			t.Errorf("重试后的回调结果 = %v", result)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("重试的任务未完成")
+	}
+	if entries, _ := dlq.List(context.Background()); len(entries) != 0 {
+		t.Errorf("重试成功后死信队列 = %+v", entries)
+	}
+	if err := dlq.Retry(context.Background(), id); !errors.Is(err, ErrDeadLetterNotFound) {
+		t.Errorf("重复重试应返回 ErrDeadLetterNotFound, got %v", err)
+	}
+}
+
+func TestDeadLettersOlderThanRetentionAreEvicted(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+	dlq := NewDeadLetterQueue(client, 0, time.Hour)
+
+	now := time.Now()
+	t.Cleanup(func() { deadLetterNow = time.Now })
+	for _, failedAt := range []time.Time{now.Add(-25 * time.Hour), now.Add(-time.Hour)} {
+		deadLetterNow = func() time.Time { return failedAt }
+		task, _ := NewTask(context.Background(), "dlq_test_evict", nil)
+		dlq.wrap("session-3", task)
+		task.Callback.OnError(errors.New("失败"))
+	}
+	deadLetterNow = func() time.Time { return now }
+
+	entries, err := dlq.List(context.Background())
+	if err != nil {
+		t.Fatalf("查询死信队列失败: %v", err)
+	}
+	if len(entries) != 1 || !entries[0].FailedAt.Equal(now.Add(-time.Hour)) {
+		t.Errorf("清除过期任务后死信队列 = %+v", entries)
+	}
+	if n, _ := client.ZCard(context.Background(), "dlq:session-3").Result(); n != 1 {
+		t.Errorf("dlq:session-3 中有 %d 个任务, 期望 1", n)
+	}
+}
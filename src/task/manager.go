@@ -11,6 +11,7 @@ type TaskManager struct {
 	workerPool     *WorkerPool
 	scheduledTasks *ScheduledTasks
 	clientManager  *ClientManager
+	dlq            *DeadLetterQueue
 }
 
 // NewTaskManager creates a new TaskManager instance
@@ -38,12 +39,26 @@ func (tm *TaskManager) Stop() {
 	tm.scheduledTasks.Stop()
 }
 
+// SetDeadLetterQueue 启用死信队列，执行失败的任务写入队列并按配置自动重试
+func (tm *TaskManager) SetDeadLetterQueue(dlq *DeadLetterQueue) {
+	dlq.submit = tm.SubmitTask
+	tm.dlq = dlq
+}
+
+// DeadLetterQueue 返回死信队列，未启用时返回nil
+func (tm *TaskManager) DeadLetterQueue() *DeadLetterQueue {
+	return tm.dlq
+}
+
 // SubmitTask submits a task for execution
 func (tm *TaskManager) SubmitTask(clientID string, task *Task) error {
 	// 检查任务类型是否已注册
 	if _, exists := GetTaskExecutor(task.Type); !exists {
 		return fmt.Errorf("task type %v is not registered", task.Type)
 	}
+	if tm.dlq != nil {
+		tm.dlq.wrap(clientID, task)
+	}
 
 	if task.ScheduledTime != nil {
 		return tm.scheduleTask(clientID, task)